@@ -0,0 +1,56 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"tg_mexc/internal/mexc/sandbox"
+
+	"github.com/lmittmann/tint"
+)
+
+// Локальный mock MEXC для интеграционных прогонов: приложения с MEXC_SANDBOX=true ходят сюда вместо mexc.com
+func main() {
+	logger := slog.New(tint.NewHandler(os.Stdout, &tint.Options{
+		Level:      slog.LevelDebug,
+		TimeFormat: time.Kitchen, // "3:04PM"
+	}))
+
+	addr := cmp.Or(os.Getenv("MEXC_SANDBOX_ADDR"), "127.0.0.1:8090")
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           sandbox.New(logger).Handler(),
+		ReadHeaderTimeout: 15 * time.Second,
+	}
+
+	go func() {
+		logger.Info("🧪 MEXC sandbox starting",
+			slog.String("address", addr),
+			slog.String("push", "POST http://"+addr+"/sandbox/push"))
+
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("Sandbox failed to start", slog.Any("error", err))
+			os.Exit(1)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("Sandbox forced to shutdown", slog.Any("error", err))
+	}
+
+	logger.Info("✅ Sandbox stopped")
+}
@@ -10,7 +10,19 @@ import (
 	"time"
 
 	"tg_mexc/internal/config"
+	"tg_mexc/internal/exposure"
+	"tg_mexc/internal/features"
+	"tg_mexc/internal/fees"
+	"tg_mexc/internal/health"
+	"tg_mexc/internal/httpmiddleware"
+	"tg_mexc/internal/mailer"
+	"tg_mexc/internal/mexc"
 	"tg_mexc/internal/mexc/copytrading"
+	mexcws "tg_mexc/internal/mexc/websocket"
+	"tg_mexc/internal/notifier"
+	"tg_mexc/internal/pnl"
+	"tg_mexc/internal/reconcile"
+	"tg_mexc/internal/sessioncheck"
 	"tg_mexc/internal/storage"
 	"tg_mexc/internal/telegram"
 	telegramcopytrading "tg_mexc/internal/telegram/copytrading"
@@ -36,6 +48,24 @@ func main() {
 
 	// Загрузка конфигурации
 	cfg := config.Load(logger)
+	mexc.SetRateLimit(cfg.MexcRateLimitRPS, cfg.MexcRateLimitBurst)
+	mexc.SetEndpoints(cfg.MexcBaseURL, cfg.MexcWSURL)
+	mexc.SetFeeRateCacheTTL(cfg.MexcFeeRateCacheTTL)
+	mexcws.SetPongTimeout(cfg.MexcWSPongTimeout)
+	mexcws.SetStopOrderMatchWindow(cfg.CopyStopMatchWindow)
+	mexcws.SetCompression(cfg.MexcWSCompression)
+	mexcws.SetPersonalFilter(cfg.MexcWSPersonalFilter)
+	queuePolicy, err := mexcws.ParseOverflowPolicy(cfg.MexcWSQueuePolicy)
+	if err != nil {
+		logger.Error("Invalid MEXC_WS_QUEUE_POLICY", slog.Any("error", err))
+		os.Exit(1)
+	}
+	mexcws.SetEventQueue(mexcws.EventQueueConfig{Size: cfg.MexcWSQueueSize, Policy: queuePolicy})
+	mexc.SetTransportConfig(httpmiddleware.TransportConfig{
+		MaxIdleConnsPerHost: cfg.MexcMaxIdleConnsPerHost,
+		DisableHTTP2:        !cfg.MexcHTTP2,
+		TLSSessionCacheSize: cfg.MexcTLSSessionCache,
+	})
 
 	// Инициализация хранилища (используем WebStorage для единой базы с web-app)
 	webStorage, err := storage.NewWeb(cfg.DBPath, logger)
@@ -45,6 +75,25 @@ func main() {
 	}
 	defer webStorage.Close()
 
+	// Запись сырых push.personal.* сообщений WebSocket для отладки и offline replay
+	var frameRecorders mexcws.FrameRecorders
+	if cfg.WSRecordFile != "" {
+		fileRecorder, err := mexcws.NewFileRecorder(cfg.WSRecordFile)
+		if err != nil {
+			logger.Error("Failed to open WebSocket record file", slog.Any("error", err))
+			os.Exit(1)
+		}
+		defer fileRecorder.Close()
+		frameRecorders = append(frameRecorders, fileRecorder)
+	}
+	if cfg.WSRecordDB {
+		frameRecorders = append(frameRecorders, webStorage)
+	}
+	if len(frameRecorders) > 0 {
+		mexcws.SetFrameRecorder(frameRecorders, logger)
+		defer mexcws.StopFrameRecorder()
+	}
+
 	// Инициализация Telegram сервиса
 	tgService, err := telegram.New(cfg.TelegramToken, logger)
 	if err != nil {
@@ -52,13 +101,124 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Учет реализованного PnL (fill'ы master аккаунта + backfill истории позиций)
+	pnlSvc := pnl.New(webStorage, logger)
+
+	// Учет уплаченных комиссий (fill'ы master аккаунта + backfill истории ордеров)
+	feesSvc := fees.New(webStorage, logger)
+
+	// Суммарная экспозиция по символам с лимитами концентрации
+	exposureSvc := exposure.New(webStorage, exposure.Limits{
+		MaxNotional: cfg.ExposureMaxNotional,
+		MaxShare:    cfg.ExposureMaxShare,
+	}, logger)
+
+	// Оценка здоровья аккаунтов (авторизация, ошибки, задержка, прокси, маржа)
+	healthSvc := health.New(webStorage, logger)
+
 	// Инициализация Copy Trading
+	// Общий пул MEXC клиентов: соединения переиспользуются engine и командами бота
+	mexcClients := mexc.NewClientPool(logger)
+
 	engine := copytrading.NewEngine(webStorage, webStorage, webStorage, webStorage, logger, cfg.DryRun)
+	engine.SetClientPool(mexcClients)
+	engine.AddDealRecorder(pnlSvc)
+	engine.AddDealRecorder(feesSvc)
+	engine.SetEventStorage(webStorage)
+
+	// Уведомления о скопированных сделках в каналы пользователя (все сессии engine)
+	notifierSvc := notifier.New(webStorage, logger)
+	notifierSvc.AddSink(notifier.ChannelTelegram, notifier.NewTelegramSink(webStorage, tgService))
+	notifierSvc.AddSink(notifier.ChannelDiscord, notifier.NewDiscordSink(webStorage))
+	notifierSvc.AddSink(notifier.ChannelSlack, notifier.NewSlackSink(webStorage))
+	engine.AddTradeNotifier(notifierSvc)
+	engine.SetTimeouts(copytrading.Timeouts{
+		Open:      cfg.CopyTimeoutOpen,
+		Close:     cfg.CopyTimeoutClose,
+		StopOrder: cfg.CopyTimeoutStopOrder,
+		Leverage:  cfg.CopyTimeoutLeverage,
+		PerSlave:  cfg.CopyTimeoutPerSlave,
+		Command:   cfg.BotCommandTimeout,
+	})
+	engine.SetMaxSlippage(cfg.CopyMaxSlippage)
+	sizingMode, err := copytrading.ParseSizingMode(cfg.CopySizingMode)
+	if err != nil {
+		logger.Error("Invalid COPY_SIZING_MODE", slog.Any("error", err))
+		os.Exit(1)
+	}
+	engine.SetSizingMode(sizingMode)
+	engine.SetFeatureFlags(features.NewService(cfg.FeatureFlags, webStorage, logger))
+	engine.SetSymbolFilterStorage(webStorage)
+	dailyLossAction, err := copytrading.ParseDailyLossAction(cfg.CopyDailyLossAction)
+	if err != nil {
+		logger.Error("Invalid COPY_DAILY_LOSS_ACTION", slog.Any("error", err))
+		os.Exit(1)
+	}
+	engine.SetDailyLossLimit(cfg.CopyDailyLossLimit, dailyLossAction)
+	engine.SetRetryQueue(webStorage, copytrading.RetryConfig{
+		MaxAttempts: cfg.CopyRetryMaxAttempts,
+		BaseDelay:   cfg.CopyRetryBaseDelay,
+		MaxAge:      cfg.CopyRetryMaxAge,
+	})
+	engine.SetBalanceDropAlert(cfg.MasterBalanceDropPct, cfg.MasterBalanceDropWindow)
+	engine.SetOrderProtection(mexc.OrderProtection{
+		PriceProtect:   cfg.CopyPriceProtect,
+		MarketCeiling:  cfg.CopyMarketCeiling,
+		MaxSlippagePct: cfg.CopySlippageLimit,
+	})
+	if cfg.CopySlaveFillWS {
+		engine.SetFillWatcher(copytrading.NewFillWatcher(webStorage, logger))
+	}
 	manager := copytrading.NewManager(engine, cfg.DryRun, logger)
-	copyTradingSvc := telegramcopytrading.New(manager, webStorage, logger)
+
+	// Критические уведомления (истекшая авторизация, anti-bot challenge, остановка сессии, выход из DRY_RUN):
+	// Telegram, при неудаче или без привязки - email
+	alerter := mailer.NewAlerter(webStorage, mailer.New(mailer.Config{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	}, logger), logger)
+	alerter.SetTelegram(tgService)
+	go alerter.CheckTradingMode(context.Background(), webStorage, "tg-bot", cfg.DryRun)
+	engine.SetAlerter(alerter)
+
+	copyTradingSvc := telegramcopytrading.New(manager, webStorage, alerter, logger)
+	engine.SetHaltHandler(func(userID int) {
+		if err := copyTradingSvc.StopUser(userID); err != nil {
+			logger.Error("Failed to halt copy trading", slog.Int("user_id", userID), slog.Any("error", err))
+		}
+	})
+
+	// Сверка позиций slave с master с кнопками исправления в Telegram
+	reconcileSvc := reconcile.New(webStorage, manager, cfg.ReconcileInterval, cfg.ReconcileGrace, logger)
+	reconcileSvc.SetTelegram(tgService)
+	reconcileSvc.SetNotifier(notifierSvc)
+	reconcileSvc.SetEntryGate(engine)
+	reconcileSvc.SetAutoFix(cfg.ReconcileAutoFix)
+	// Пропорциональный объём slave зависит от equity, а не от объёма мастера: объём сверяется только в режиме fixed
+	if sizingMode == copytrading.SizingFixed {
+		reconcileSvc.SetSizeTolerance(cfg.ReconcileSizeTolerance)
+	}
+
+	// Проверка сессий аккаунтов: истекший uc_token отмечается и пользователь получает уведомление
+	sessionCheckSvc := sessioncheck.New(webStorage, alerter, cfg.SessionCheckInterval, logger)
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go reconcileSvc.Run(jobsCtx)
+	go manager.RunRetries(jobsCtx)
+	go sessionCheckSvc.Run(jobsCtx)
+	go mexc.RunProxyHealthChecks(jobsCtx, cfg.ProxyHealthInterval, logger)
+	go mexc.RunTimeSync(jobsCtx, cfg.MexcTimeSyncInterval, logger)
+	go notifierSvc.Run(jobsCtx)
 
 	// Создание обработчика
-	handler := handlers.New(webStorage, tgService, copyTradingSvc, logger)
+	handler := handlers.New(webStorage, tgService, copyTradingSvc, pnlSvc, feesSvc, exposureSvc, healthSvc, alerter, reconcileSvc, logger)
+	handler.SetTimeouts(engine.Timeouts())
+	handler.SetClientPool(mexcClients)
+	handler.SetBackupAllowPlaintext(cfg.BackupAllowPlaintext)
 
 	// Запуск бота
 	logger.Info("🚀 Starting bot...")
@@ -7,15 +7,33 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"tg_mexc/internal/alerts"
 	"tg_mexc/internal/api"
 	"tg_mexc/internal/api/auth"
 	apicopytrading "tg_mexc/internal/api/copytrading"
+	"tg_mexc/internal/cluster"
 	"tg_mexc/internal/config"
+	"tg_mexc/internal/equity"
+	"tg_mexc/internal/exposure"
+	"tg_mexc/internal/features"
+	"tg_mexc/internal/fees"
+	"tg_mexc/internal/health"
+	"tg_mexc/internal/httpmiddleware"
+	"tg_mexc/internal/mailer"
+	"tg_mexc/internal/mexc"
 	"tg_mexc/internal/mexc/copytrading"
+	mexcws "tg_mexc/internal/mexc/websocket"
+	"tg_mexc/internal/notifier"
+	"tg_mexc/internal/pnl"
+	"tg_mexc/internal/reconcile"
+	"tg_mexc/internal/reports"
+	"tg_mexc/internal/sessioncheck"
 	"tg_mexc/internal/storage"
+	"tg_mexc/internal/telegram"
 
 	"github.com/lmittmann/tint"
 )
@@ -35,6 +53,24 @@ func main() {
 	})
 
 	cfg := config.Load(logger)
+	mexc.SetRateLimit(cfg.MexcRateLimitRPS, cfg.MexcRateLimitBurst)
+	mexc.SetEndpoints(cfg.MexcBaseURL, cfg.MexcWSURL)
+	mexc.SetFeeRateCacheTTL(cfg.MexcFeeRateCacheTTL)
+	mexcws.SetPongTimeout(cfg.MexcWSPongTimeout)
+	mexcws.SetStopOrderMatchWindow(cfg.CopyStopMatchWindow)
+	mexcws.SetCompression(cfg.MexcWSCompression)
+	mexcws.SetPersonalFilter(cfg.MexcWSPersonalFilter)
+	queuePolicy, err := mexcws.ParseOverflowPolicy(cfg.MexcWSQueuePolicy)
+	if err != nil {
+		logger.Error("Invalid MEXC_WS_QUEUE_POLICY", slog.Any("error", err))
+		os.Exit(1)
+	}
+	mexcws.SetEventQueue(mexcws.EventQueueConfig{Size: cfg.MexcWSQueueSize, Policy: queuePolicy})
+	mexc.SetTransportConfig(httpmiddleware.TransportConfig{
+		MaxIdleConnsPerHost: cfg.MexcMaxIdleConnsPerHost,
+		DisableHTTP2:        !cfg.MexcHTTP2,
+		TLSSessionCacheSize: cfg.MexcTLSSessionCache,
+	})
 
 	// Инициализация БД
 	webStorage, err := storage.NewWeb(cfg.DBPath, logger)
@@ -44,18 +80,234 @@ func main() {
 	}
 	defer webStorage.Close()
 
+	// Запись сырых push.personal.* сообщений WebSocket для отладки и offline replay
+	var frameRecorders mexcws.FrameRecorders
+	if cfg.WSRecordFile != "" {
+		fileRecorder, err := mexcws.NewFileRecorder(cfg.WSRecordFile)
+		if err != nil {
+			logger.Error("Failed to open WebSocket record file", slog.Any("error", err))
+			os.Exit(1)
+		}
+		defer fileRecorder.Close()
+		frameRecorders = append(frameRecorders, fileRecorder)
+	}
+	if cfg.WSRecordDB {
+		frameRecorders = append(frameRecorders, webStorage)
+	}
+	if len(frameRecorders) > 0 {
+		mexcws.SetFrameRecorder(frameRecorders, logger)
+		defer mexcws.StopFrameRecorder()
+	}
+
 	// Инициализация auth сервиса
-	authService := auth.NewService(cfg.JWTSecret, 24*time.Hour) // Токен действителен 24 часа
+	authService := auth.NewService(cfg.JWTSecret, cfg.JWTAccessTTL)
+	authService.SetClaimsConfig(auth.ClaimsConfig{
+		Issuer:    cfg.JWTIssuer,
+		Audience:  cfg.JWTAudience,
+		ClockSkew: cfg.JWTClockSkew,
+	})
+	authService.SetSessionTTL(cfg.RefreshTokenTTL, cfg.SessionMaxLifetime)
+
+	passwordParams := auth.DefaultArgon2Params()
+	passwordParams.Memory = uint32(cfg.Argon2Memory)
+	passwordParams.Iterations = uint32(cfg.Argon2Iterations)
+	passwordParams.Parallelism = uint8(cfg.Argon2Parallelism)
+	authService.SetPasswordParams(passwordParams)
+
+	// Первый администратор из окружения
+	if err := auth.BootstrapAdmin(webStorage, authService, cfg.AdminUsername, cfg.AdminPassword, logger); err != nil {
+		logger.Error("Failed to bootstrap admin user", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	// Защита входа от перебора паролей
+	loginGuard := auth.NewGuard(auth.LockoutConfig{
+		MaxUsernameFailures: cfg.LoginMaxFailures,
+		MaxIPFailures:       cfg.LoginMaxIPFailures,
+		Window:              cfg.LoginFailureWindow,
+		LockoutDuration:     cfg.LoginLockoutDuration,
+	}, webStorage)
+
+	// Вход через Telegram Login Widget (подпись проверяется токеном бота)
+	telegramLogin := auth.NewTelegramVerifier(cfg.TelegramToken, cfg.TelegramLoginMaxAge)
+
+	// Email (без SMTP письма только логируются)
+	mail := mailer.New(mailer.Config{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	}, logger)
+
+	// Учет реализованного PnL (fill'ы master аккаунта + backfill истории позиций)
+	pnlSvc := pnl.New(webStorage, logger)
+
+	// Учет уплаченных комиссий (fill'ы master аккаунта + backfill истории ордеров)
+	feesSvc := fees.New(webStorage, logger)
+
+	// Суммарная экспозиция по символам с лимитами концентрации
+	exposureSvc := exposure.New(webStorage, exposure.Limits{
+		MaxNotional: cfg.ExposureMaxNotional,
+		MaxShare:    cfg.ExposureMaxShare,
+	}, logger)
+
+	// Оценка здоровья аккаунтов (авторизация, ошибки, задержка, прокси, маржа)
+	healthSvc := health.New(webStorage, logger)
+
+	// Снимки баланса для кривой equity (фоновая задача)
+	equitySvc := equity.New(webStorage, cfg.EquitySnapshotInterval, cfg.EquitySnapshotRetention, logger)
 
 	// Инициализация copy trading сервисов
+	// Общий пул MEXC клиентов: соединения переиспользуются engine и API
+	mexcClients := mexc.NewClientPool(logger)
+
 	engine := copytrading.NewEngine(webStorage, webStorage, webStorage, webStorage, logger, cfg.DryRun)
+	engine.SetClientPool(mexcClients)
+	engine.AddDealRecorder(pnlSvc)
+	engine.AddDealRecorder(feesSvc)
+	engine.SetEventStorage(webStorage)
+	engine.SetTimeouts(copytrading.Timeouts{
+		Open:      cfg.CopyTimeoutOpen,
+		Close:     cfg.CopyTimeoutClose,
+		StopOrder: cfg.CopyTimeoutStopOrder,
+		Leverage:  cfg.CopyTimeoutLeverage,
+		PerSlave:  cfg.CopyTimeoutPerSlave,
+		Command:   cfg.BotCommandTimeout,
+	})
+	engine.SetMaxSlippage(cfg.CopyMaxSlippage)
+	sizingMode, err := copytrading.ParseSizingMode(cfg.CopySizingMode)
+	if err != nil {
+		logger.Error("Invalid COPY_SIZING_MODE", slog.Any("error", err))
+		os.Exit(1)
+	}
+	engine.SetSizingMode(sizingMode)
+	engine.SetSymbolFilterStorage(webStorage)
+	dailyLossAction, err := copytrading.ParseDailyLossAction(cfg.CopyDailyLossAction)
+	if err != nil {
+		logger.Error("Invalid COPY_DAILY_LOSS_ACTION", slog.Any("error", err))
+		os.Exit(1)
+	}
+	engine.SetDailyLossLimit(cfg.CopyDailyLossLimit, dailyLossAction)
+	engine.SetRetryQueue(webStorage, copytrading.RetryConfig{
+		MaxAttempts: cfg.CopyRetryMaxAttempts,
+		BaseDelay:   cfg.CopyRetryBaseDelay,
+		MaxAge:      cfg.CopyRetryMaxAge,
+	})
+	engine.SetBalanceDropAlert(cfg.MasterBalanceDropPct, cfg.MasterBalanceDropWindow)
+	engine.SetOrderProtection(mexc.OrderProtection{
+		PriceProtect:   cfg.CopyPriceProtect,
+		MarketCeiling:  cfg.CopyMarketCeiling,
+		MaxSlippagePct: cfg.CopySlippageLimit,
+	})
+	if cfg.CopySlaveFillWS {
+		engine.SetFillWatcher(copytrading.NewFillWatcher(webStorage, logger))
+	}
 	manager := copytrading.NewManager(engine, cfg.DryRun, logger)
 
+	// Реестр инстансов: Redis для нескольких инстансов web-app, иначе состояние в памяти процесса
+	var registry cluster.Registry = cluster.NewLocal()
+	if cfg.RedisURL != "" {
+		connectCtx, cancelConnect := context.WithTimeout(context.Background(), 10*time.Second)
+		redisRegistry, err := cluster.NewRedis(connectCtx, cfg.RedisURL, cfg.InstanceID, logger)
+		cancelConnect()
+		if err != nil {
+			logger.Error("Failed to connect to Redis", slog.Any("error", err))
+			os.Exit(1)
+		}
+		defer redisRegistry.Close()
+
+		registry = redisRegistry
+		logger.Info("🔗 Multi-instance mode (Redis)", slog.String("instance", registry.Instance()))
+	}
+
+	// Правила алертов (фоновая задача, доставка в Telegram/webhook/email).
+	// Алерты считает лидер, поэтому активные сессии берутся из реестра всех инстансов
+	alertsSvc := alerts.New(webStorage, cluster.NewSessions(registry), mail, cfg.AlertEvalInterval, logger)
+
+	// Периодические отчеты по подпискам (Telegram документ / email)
+	reportsSvc := reports.New(webStorage, pnlSvc, feesSvc, mail, cfg.ReportHour, logger)
+
+	// Сверка позиций slave с master (кнопки исправления обрабатывает tg-bot)
+	reconcileSvc := reconcile.New(webStorage, manager, cfg.ReconcileInterval, cfg.ReconcileGrace, logger)
+	reconcileSvc.SetEntryGate(engine)
+	reconcileSvc.SetAutoFix(cfg.ReconcileAutoFix)
+	// Пропорциональный объём slave зависит от equity, а не от объёма мастера: объём сверяется только в режиме fixed
+	if sizingMode == copytrading.SizingFixed {
+		reconcileSvc.SetSizeTolerance(cfg.ReconcileSizeTolerance)
+	}
+
+	// Уведомления о скопированных сделках в каналы пользователя (все сессии engine)
+	notifierSvc := notifier.New(webStorage, logger)
+	notifierSvc.AddSink(notifier.ChannelDiscord, notifier.NewDiscordSink(webStorage))
+	notifierSvc.AddSink(notifier.ChannelSlack, notifier.NewSlackSink(webStorage))
+	engine.AddTradeNotifier(notifierSvc)
+	reconcileSvc.SetNotifier(notifierSvc)
+
+	alertsSvc.AddSink(alerts.ChannelDiscord, notifier.NewDiscordSink(webStorage))
+	alertsSvc.AddSink(alerts.ChannelSlack, notifier.NewSlackSink(webStorage))
+
+	// Критические уведомления (истекшая авторизация, anti-bot challenge, остановка сессии, выход из DRY_RUN):
+	// Telegram, при неудаче или без привязки - email
+	alerter := mailer.NewAlerter(webStorage, mail, logger)
+
+	if cfg.TelegramToken != "" {
+		sender, err := telegram.NewSender(cfg.TelegramToken, logger)
+		if err != nil {
+			logger.Warn("Telegram delivery of alerts and reports disabled", slog.Any("error", err))
+		} else {
+			alertsSvc.SetTelegram(sender)
+			reportsSvc.SetTelegram(sender)
+			reconcileSvc.SetTelegram(sender)
+			notifierSvc.AddSink(notifier.ChannelTelegram, notifier.NewTelegramSink(webStorage, sender))
+			alerter.SetTelegram(sender)
+		}
+	}
+	go alerter.CheckTradingMode(context.Background(), webStorage, "web-app", cfg.DryRun)
+	engine.SetAlerter(alerter)
+
+	// Проверка сессий аккаунтов: истекший uc_token отмечается и пользователь получает уведомление
+	sessionCheckSvc := sessioncheck.New(webStorage, alerter, cfg.SessionCheckInterval, logger)
+
 	// Создаём главный сервис copy trading
-	copyTradingSvc := apicopytrading.NewService(manager, webStorage, cfg.APIURL, logger)
+	copyTradingSvc := apicopytrading.NewService(manager, webStorage, registry, alerter, cfg.APIURL, logger)
+	engine.SetHaltHandler(func(userID int) {
+		err := copyTradingSvc.SetMode(context.Background(), userID, "", apicopytrading.ModeOff, apicopytrading.ModeOptions{})
+		if err != nil {
+			logger.Error("Failed to halt copy trading", slog.Int("user_id", userID), slog.Any("error", err))
+		}
+	})
+
+	// Feature flags (глобальные значения из конфига + per-user переопределения)
+	featureSvc := features.NewService(cfg.FeatureFlags, webStorage, logger)
+	engine.SetFeatureFlags(featureSvc)
 
 	// Инициализация API handler
-	apiHandler := api.New(webStorage, authService, copyTradingSvc, cfg.APIURL, logger)
+	apiHandler := api.New(webStorage, authService, copyTradingSvc, featureSvc, pnlSvc, equitySvc, feesSvc, exposureSvc, reportsSvc, healthSvc, loginGuard, telegramLogin, mail,
+		cfg.APIURL, cfg.TelegramBot, logger)
+
+	apiHandler.SetRegistrationEnabled(cfg.RegistrationEnabled)
+	apiHandler.SetTrustedProxies(cfg.TrustedProxies)
+	apiHandler.SetClientPool(mexcClients)
+
+	// OpenID Connect SSO (опционально)
+	if cfg.OIDCIssuerURL != "" {
+		oidcProvider, err := auth.NewOIDCProvider(context.Background(), auth.OIDCConfig{
+			IssuerURL:     cfg.OIDCIssuerURL,
+			ClientID:      cfg.OIDCClientID,
+			ClientSecret:  cfg.OIDCClientSecret,
+			RedirectURL:   cfg.OIDCRedirectURL,
+			Scopes:        cfg.OIDCScopes,
+			UsernameClaim: cfg.OIDCUsernameClaim,
+		})
+		if err != nil {
+			logger.Error("Failed to initialize OIDC provider", slog.Any("error", err))
+			os.Exit(1)
+		}
+
+		apiHandler.SetOIDC(oidcProvider, cfg.OIDCLinkByUsername)
+		logger.Info("🔐 OIDC login enabled", slog.String("issuer", cfg.OIDCIssuerURL))
+	}
 
 	// Настройка роутинга (статика встроена через go:embed)
 	router := apiHandler.SetupRouter()
@@ -69,6 +321,27 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// Фоновые задачи (останавливаются при shutdown)
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	go registry.Run(jobsCtx)
+	go copyTradingSvc.Run(jobsCtx)
+	go manager.RunRetries(jobsCtx)
+	go reconcileSvc.Run(jobsCtx)
+	go notifierSvc.Run(jobsCtx)
+	go mexc.RunProxyHealthChecks(jobsCtx, cfg.ProxyHealthInterval, logger)
+	go mexc.RunTimeSync(jobsCtx, cfg.MexcTimeSyncInterval, logger)
+
+	// Периодические задачи по всем пользователям выполняет один инстанс
+	go cluster.RunLeader(jobsCtx, registry, "jobs", func(ctx context.Context) {
+		var wg sync.WaitGroup
+		wg.Go(func() { equitySvc.Run(ctx) })
+		wg.Go(func() { pnlSvc.RunFundingSync(ctx, cfg.FundingSyncInterval) })
+		wg.Go(func() { alertsSvc.Run(ctx) })
+		wg.Go(func() { reportsSvc.Run(ctx) })
+		wg.Go(func() { sessionCheckSvc.Run(ctx) })
+		wg.Wait()
+	})
+
 	// Запускаем сервер в горутине
 	go func() {
 		logger.Info("🚀 Server starting...", slog.String("address", cfg.Address))
@@ -87,6 +360,8 @@ func main() {
 
 	logger.Info("🛑 Shutting down server...")
 
+	stopJobs()
+
 	// Останавливаем все активные сессии copy trading
 	copyTradingSvc.StopAll()
 
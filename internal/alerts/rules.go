@@ -0,0 +1,89 @@
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+
+	"tg_mexc/internal/models"
+)
+
+// Типы правил
+const (
+	// RuleSlaveFailures - slave аккаунт не исполнил Threshold сделок за WindowMinutes
+	RuleSlaveFailures = "slave_failures"
+	// RuleBalanceBelow - equity аккаунта (по последнему снимку баланса) ниже Threshold USDT
+	RuleBalanceBelow = "balance_below"
+	// RuleMasterSilence - нет событий master аккаунта WindowMinutes при активной сессии
+	RuleMasterSilence = "master_silence"
+	// RuleAuthExpired - авторизация аккаунта истекла (проверяется раз в WindowMinutes)
+	RuleAuthExpired = "auth_expired"
+)
+
+// Каналы доставки
+const (
+	ChannelTelegram = "telegram"
+	ChannelWebhook  = "webhook"
+	ChannelEmail    = "email"
+	ChannelDiscord  = "discord" // Discord webhook из настроек уведомлений пользователя
+	ChannelSlack    = "slack"   // Slack webhook из настроек уведомлений пользователя
+)
+
+const (
+	defaultCooldownMinutes  = 60
+	defaultAuthCheckMinutes = 30
+)
+
+// ErrInvalidRule возвращается для некорректного правила
+var ErrInvalidRule = errors.New("invalid alert rule")
+
+// Validate проверяет правило и подставляет значения по умолчанию
+func Validate(rule *models.AlertRule) error {
+	switch rule.Type {
+	case RuleSlaveFailures:
+		if rule.Threshold < 1 || rule.WindowMinutes < 1 {
+			return fmt.Errorf("%w: threshold and window_minutes must be positive", ErrInvalidRule)
+		}
+	case RuleBalanceBelow:
+		if rule.Threshold <= 0 {
+			return fmt.Errorf("%w: threshold must be positive", ErrInvalidRule)
+		}
+	case RuleMasterSilence:
+		if rule.WindowMinutes < 1 {
+			return fmt.Errorf("%w: window_minutes must be positive", ErrInvalidRule)
+		}
+	case RuleAuthExpired:
+		if rule.WindowMinutes < 1 {
+			rule.WindowMinutes = defaultAuthCheckMinutes
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidRule, rule.Type)
+	}
+
+	if rule.CooldownMinutes < 1 {
+		rule.CooldownMinutes = defaultCooldownMinutes
+	}
+
+	if len(rule.Channels) == 0 {
+		return fmt.Errorf("%w: at least one channel is required", ErrInvalidRule)
+	}
+	for _, channel := range rule.Channels {
+		switch channel {
+		case ChannelTelegram, ChannelWebhook, ChannelEmail, ChannelDiscord, ChannelSlack:
+		default:
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalidRule, channel)
+		}
+	}
+
+	if slices.Contains(rule.Channels, ChannelWebhook) {
+		u, err := url.Parse(rule.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: webhook_url must be an http(s) URL", ErrInvalidRule)
+		}
+	} else {
+		rule.WebhookURL = ""
+	}
+
+	return nil
+}
@@ -0,0 +1,439 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"tg_mexc/internal/clock"
+	"tg_mexc/internal/mailer"
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+	"tg_mexc/internal/notifier"
+)
+
+const (
+	deliveryTimeout = 10 * time.Second
+	probeTimeout    = 15 * time.Second
+)
+
+// Storage - хранилище правил и данных для их проверки
+type Storage interface {
+	GetEnabledAlertRules() ([]models.AlertRule, error)
+	GetAccounts(userID int) ([]models.Account, error)
+	AddAlertEvent(event models.AlertEvent) error
+	GetLastAlertTime(ruleID int, accountID int) (time.Time, error)
+	GetSlaveFailureCounts(userID int, since time.Time) ([]models.AccountCount, error)
+	GetLatestBalanceSnapshots(userID int) ([]models.BalanceSnapshot, error)
+	GetLastMasterActivity(userID int) (time.Time, error)
+	GetTelegramChatID(userID int) (int64, error)
+	GetUserContact(userID int) (email string, emailVerified bool, telegramLinked bool, err error)
+}
+
+// SessionChecker - источник активных сессий copy trading
+type SessionChecker interface {
+	ActiveSince(userID int) (time.Time, bool)
+}
+
+// TelegramSender отправляет сообщения в Telegram
+type TelegramSender interface {
+	SendMessage(chatID int64, text string) error
+}
+
+// firing - сработавшее правило (по аккаунту или без привязки к аккаунту)
+type firing struct {
+	accountID   int
+	accountName string
+	message     string
+}
+
+// webhookPayload - тело POST запроса на webhook правила
+type webhookPayload struct {
+	RuleID      int       `json:"rule_id"`
+	Type        string    `json:"type"`
+	AccountID   int       `json:"account_id,omitempty"`
+	AccountName string    `json:"account_name,omitempty"`
+	Message     string    `json:"message"`
+	FiredAt     time.Time `json:"fired_at"`
+}
+
+// Service периодически проверяет правила алертов и доставляет срабатывания
+type Service struct {
+	storage    Storage
+	sessions   SessionChecker
+	telegram   TelegramSender           // nil - доставка в Telegram недоступна
+	sinks      map[string]notifier.Sink // Discord, Slack
+	mailer     mailer.Mailer
+	httpClient *http.Client
+	interval   time.Duration
+	logger     *slog.Logger
+	clock      clock.Clock
+
+	mu         sync.Mutex
+	lastProbes map[int]time.Time // rule ID -> время последней проверки авторизации
+}
+
+// New создает сервис алертов.
+// interval - период проверки правил (0 отключает фоновую задачу)
+func New(storage Storage, sessions SessionChecker, mail mailer.Mailer, interval time.Duration, logger *slog.Logger) *Service {
+	return &Service{
+		storage:    storage,
+		sessions:   sessions,
+		sinks:      make(map[string]notifier.Sink),
+		mailer:     mail,
+		httpClient: &http.Client{Timeout: deliveryTimeout},
+		interval:   interval,
+		logger:     logger,
+		clock:      clock.Real,
+		lastProbes: make(map[int]time.Time),
+	}
+}
+
+// SetTelegram включает доставку алертов в Telegram
+func (s *Service) SetTelegram(sender TelegramSender) {
+	s.telegram = sender
+}
+
+// AddSink подключает канал доставки алертов через webhook из настроек уведомлений (ChannelDiscord, ChannelSlack)
+func (s *Service) AddSink(channel string, sink notifier.Sink) {
+	s.sinks[channel] = sink
+}
+
+// SetClock подменяет источник времени
+func (s *Service) SetClock(clk clock.Clock) {
+	s.clock = clk
+}
+
+// Run проверяет правила каждые interval до отмены ctx
+func (s *Service) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		s.logger.Info("Alert rules evaluation disabled")
+		return
+	}
+
+	for {
+		s.EvaluateAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.interval):
+		}
+	}
+}
+
+// EvaluateAll проверяет все включенные правила и доставляет новые срабатывания
+func (s *Service) EvaluateAll(ctx context.Context) {
+	rules, err := s.storage.GetEnabledAlertRules()
+	if err != nil {
+		s.logger.Error("Failed to get alert rules", slog.Any("error", err))
+		return
+	}
+
+	for _, rule := range rules {
+		if ctx.Err() != nil {
+			return
+		}
+
+		firings, err := s.evaluate(ctx, rule)
+		if err != nil {
+			s.logger.Warn("Failed to evaluate alert rule",
+				slog.Int("rule_id", rule.ID),
+				slog.String("type", rule.Type),
+				slog.Any("error", err))
+			continue
+		}
+
+		for _, f := range firings {
+			s.fire(ctx, rule, f)
+		}
+	}
+}
+
+func (s *Service) evaluate(ctx context.Context, rule models.AlertRule) ([]firing, error) {
+	switch rule.Type {
+	case RuleSlaveFailures:
+		return s.evaluateSlaveFailures(rule)
+	case RuleBalanceBelow:
+		return s.evaluateBalance(rule)
+	case RuleMasterSilence:
+		return s.evaluateMasterSilence(rule)
+	case RuleAuthExpired:
+		return s.evaluateAuth(ctx, rule)
+	}
+
+	return nil, fmt.Errorf("unknown rule type %q", rule.Type)
+}
+
+func (s *Service) evaluateSlaveFailures(rule models.AlertRule) ([]firing, error) {
+	window := time.Duration(rule.WindowMinutes) * time.Minute
+
+	counts, err := s.storage.GetSlaveFailureCounts(rule.UserID, s.clock.Now().Add(-window))
+	if err != nil {
+		return nil, err
+	}
+
+	var firings []firing
+	for _, count := range counts {
+		if !matchesAccount(rule, count.AccountID) || float64(count.Count) < rule.Threshold {
+			continue
+		}
+
+		firings = append(firings, firing{
+			accountID:   count.AccountID,
+			accountName: count.AccountName,
+			message: fmt.Sprintf("Slave %s failed %d times in the last %d minutes",
+				count.AccountName, count.Count, rule.WindowMinutes),
+		})
+	}
+
+	return firings, nil
+}
+
+func (s *Service) evaluateBalance(rule models.AlertRule) ([]firing, error) {
+	accounts, err := s.storage.GetAccounts(rule.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots, err := s.storage.GetLatestBalanceSnapshots(rule.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var firings []firing
+	for _, snapshot := range snapshots {
+		i := slices.IndexFunc(accounts, func(acc models.Account) bool { return acc.ID == snapshot.AccountID })
+		if i < 0 || !matchesAccount(rule, snapshot.AccountID) || snapshot.Equity >= rule.Threshold {
+			continue
+		}
+
+		firings = append(firings, firing{
+			accountID:   snapshot.AccountID,
+			accountName: accounts[i].Name,
+			message: fmt.Sprintf("Account %s equity %.2f USDT is below %.2f USDT",
+				accounts[i].Name, snapshot.Equity, rule.Threshold),
+		})
+	}
+
+	return firings, nil
+}
+
+func (s *Service) evaluateMasterSilence(rule models.AlertRule) ([]firing, error) {
+	startedAt, active := s.sessions.ActiveSince(rule.UserID)
+	if !active {
+		return nil, nil
+	}
+
+	last, err := s.storage.GetLastMasterActivity(rule.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Тишина считается не раньше старта сессии
+	if last.Before(startedAt) {
+		last = startedAt
+	}
+
+	silence := s.clock.Now().Sub(last)
+	if silence < time.Duration(rule.WindowMinutes)*time.Minute {
+		return nil, nil
+	}
+
+	return []firing{{
+		message: fmt.Sprintf("No master events for %d minutes while copy trading is active", int(silence.Minutes())),
+	}}, nil
+}
+
+func (s *Service) evaluateAuth(ctx context.Context, rule models.AlertRule) ([]firing, error) {
+	now := s.clock.Now()
+
+	// Проверка авторизации ходит на биржу - не чаще раза в WindowMinutes
+	s.mu.Lock()
+	last := s.lastProbes[rule.ID]
+	if now.Sub(last) < time.Duration(rule.WindowMinutes)*time.Minute {
+		s.mu.Unlock()
+		return nil, nil
+	}
+	s.lastProbes[rule.ID] = now
+	s.mu.Unlock()
+
+	accounts, err := s.storage.GetAccounts(rule.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	var firings []firing
+	for _, acc := range accounts {
+		if !matchesAccount(rule, acc.ID) {
+			continue
+		}
+
+		if !s.authExpired(ctx, acc) {
+			continue
+		}
+
+		firings = append(firings, firing{
+			accountID:   acc.ID,
+			accountName: acc.Name,
+			message: fmt.Sprintf("Authorization of MEXC account %s has expired - re-add it with fresh browser data",
+				acc.Name),
+		})
+	}
+
+	return firings, nil
+}
+
+// authExpired проверяет авторизацию аккаунта (mexc.Client.ValidateSession)
+func (s *Service) authExpired(ctx context.Context, acc models.Account) bool {
+	client, err := mexc.NewClient(acc, s.logger)
+	if err != nil {
+		return false
+	}
+	client.SetClock(s.clock)
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	return errors.Is(client.ValidateSession(ctx), mexc.ErrSessionExpired)
+}
+
+func matchesAccount(rule models.AlertRule, accountID int) bool {
+	return rule.AccountID == nil || *rule.AccountID == accountID
+}
+
+// fire доставляет срабатывание по каналам правила, если не действует cooldown
+func (s *Service) fire(ctx context.Context, rule models.AlertRule, f firing) {
+	now := s.clock.Now()
+
+	last, err := s.storage.GetLastAlertTime(rule.ID, f.accountID)
+	if err != nil {
+		s.logger.Error("Failed to get last alert time", slog.Int("rule_id", rule.ID), slog.Any("error", err))
+		return
+	}
+	if !last.IsZero() && now.Sub(last) < time.Duration(rule.CooldownMinutes)*time.Minute {
+		return
+	}
+
+	event := models.AlertEvent{
+		RuleID:      rule.ID,
+		UserID:      rule.UserID,
+		AccountID:   f.accountID,
+		AccountName: f.accountName,
+		Type:        rule.Type,
+		Message:     f.message,
+		FiredAt:     now,
+	}
+
+	// Событие сохраняется до доставки: cooldown действует, даже если канал недоступен
+	if err := s.storage.AddAlertEvent(event); err != nil {
+		s.logger.Error("Failed to save alert event", slog.Int("rule_id", rule.ID), slog.Any("error", err))
+		return
+	}
+
+	s.logger.Info("🚨 Alert fired",
+		slog.Int("user_id", rule.UserID),
+		slog.Int("rule_id", rule.ID),
+		slog.String("type", rule.Type),
+		slog.String("message", f.message))
+
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	for _, channel := range rule.Channels {
+		var err error
+		switch channel {
+		case ChannelTelegram:
+			err = s.sendTelegram(rule.UserID, event)
+		case ChannelEmail:
+			err = s.sendEmail(ctx, rule.UserID, event)
+		case ChannelWebhook:
+			err = s.sendWebhook(ctx, rule.WebhookURL, event)
+		case ChannelDiscord, ChannelSlack:
+			err = s.sendSink(ctx, channel, rule.UserID, event)
+		}
+
+		if err != nil {
+			s.logger.Warn("Failed to deliver alert",
+				slog.Int("rule_id", rule.ID),
+				slog.String("channel", channel),
+				slog.Any("error", err))
+		}
+	}
+}
+
+func (s *Service) sendTelegram(userID int, event models.AlertEvent) error {
+	if s.telegram == nil {
+		return fmt.Errorf("telegram is not configured")
+	}
+
+	chatID, err := s.storage.GetTelegramChatID(userID)
+	if err != nil {
+		return err
+	}
+	if chatID == 0 {
+		return fmt.Errorf("telegram is not linked")
+	}
+
+	return s.telegram.SendMessage(chatID, "🚨 "+event.Message)
+}
+
+func (s *Service) sendSink(ctx context.Context, channel string, userID int, event models.AlertEvent) error {
+	sink, ok := s.sinks[channel]
+	if !ok {
+		return fmt.Errorf("%s is not configured", channel)
+	}
+
+	return sink.Send(ctx, userID, "🚨 "+event.Message)
+}
+
+func (s *Service) sendEmail(ctx context.Context, userID int, event models.AlertEvent) error {
+	email, verified, _, err := s.storage.GetUserContact(userID)
+	if err != nil {
+		return err
+	}
+	if email == "" || !verified {
+		return fmt.Errorf("no verified email")
+	}
+
+	return s.mailer.Send(ctx, email, "MEXC alert: "+event.Type, event.Message)
+}
+
+func (s *Service) sendWebhook(ctx context.Context, webhookURL string, event models.AlertEvent) error {
+	body, err := json.Marshal(webhookPayload{
+		RuleID:      event.RuleID,
+		Type:        event.Type,
+		AccountID:   event.AccountID,
+		AccountName: event.AccountName,
+		Message:     event.Message,
+		FiredAt:     event.FiredAt.UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package analytics
+
+import (
+	"cmp"
+	"math"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"tg_mexc/internal/models"
+)
+
+// directProxy - ключ группы для аккаунтов без прокси
+const directProxy = "direct"
+
+// Percentiles - распределение задержки в миллисекундах
+type Percentiles struct {
+	Count int     `json:"count"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// LatencyStages - задержки по этапам копирования
+type LatencyStages struct {
+	EventToDispatch Percentiles `json:"event_to_dispatch"` // Событие у мастера → engine начал обработку slave
+	DispatchToAck   Percentiles `json:"dispatch_to_ack"`   // Engine → ответ биржи (включая прокси)
+	EndToEnd        Percentiles `json:"end_to_end"`        // Событие у мастера → ответ биржи
+}
+
+// LatencyGroup - задержки группы исполнений (slave аккаунт или прокси)
+type LatencyGroup struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	LatencyStages
+}
+
+// LatencyReport - percentile разбивка задержек копирования
+type LatencyReport struct {
+	Overall LatencyStages  `json:"overall"`
+	Slaves  []LatencyGroup `json:"slaves"`
+	Proxies []LatencyGroup `json:"proxies"`
+}
+
+// latencyBucket накапливает задержки по этапам
+type latencyBucket struct {
+	name            string
+	eventToDispatch []float64
+	dispatchToAck   []float64
+	endToEnd        []float64
+}
+
+func (b *latencyBucket) add(sample models.LatencySample) {
+	b.dispatchToAck = append(b.dispatchToAck, ms(sample.AckedAt.Sub(sample.DispatchedAt)))
+
+	// Без времени события (ручные команды) доступен только этап engine → биржа
+	if sample.MasterEventAt == nil {
+		return
+	}
+
+	b.eventToDispatch = append(b.eventToDispatch, ms(sample.DispatchedAt.Sub(*sample.MasterEventAt)))
+	b.endToEnd = append(b.endToEnd, ms(sample.AckedAt.Sub(*sample.MasterEventAt)))
+}
+
+func (b *latencyBucket) stages() LatencyStages {
+	return LatencyStages{
+		EventToDispatch: NewPercentiles(b.eventToDispatch),
+		DispatchToAck:   NewPercentiles(b.dispatchToAck),
+		EndToEnd:        NewPercentiles(b.endToEnd),
+	}
+}
+
+// BuildLatencyReport считает percentile задержек в целом, по slave аккаунтам и по прокси.
+// Группы отсортированы по p90 end-to-end (самые медленные первыми).
+func BuildLatencyReport(samples []models.LatencySample) LatencyReport {
+	var overall latencyBucket
+	slaves := make(map[string]*latencyBucket)
+	proxies := make(map[string]*latencyBucket)
+
+	for _, sample := range samples {
+		overall.add(sample)
+
+		slaveKey := strconv.Itoa(sample.AccountID)
+		if slaves[slaveKey] == nil {
+			slaves[slaveKey] = &latencyBucket{name: sample.AccountName}
+		}
+		slaves[slaveKey].add(sample)
+
+		proxyKey := proxyHost(sample.Proxy)
+		if proxies[proxyKey] == nil {
+			proxies[proxyKey] = &latencyBucket{name: proxyKey}
+		}
+		proxies[proxyKey].add(sample)
+	}
+
+	return LatencyReport{
+		Overall: overall.stages(),
+		Slaves:  latencyGroups(slaves),
+		Proxies: latencyGroups(proxies),
+	}
+}
+
+func latencyGroups(buckets map[string]*latencyBucket) []LatencyGroup {
+	groups := make([]LatencyGroup, 0, len(buckets))
+	for key, bucket := range buckets {
+		groups = append(groups, LatencyGroup{Key: key, Name: bucket.name, LatencyStages: bucket.stages()})
+	}
+
+	slices.SortFunc(groups, func(a, b LatencyGroup) int {
+		if c := cmp.Compare(slowness(b), slowness(a)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+
+	return groups
+}
+
+// slowness - метрика для сортировки: p90 end-to-end, если известен, иначе p90 engine → биржа
+func slowness(g LatencyGroup) float64 {
+	if g.EndToEnd.Count > 0 {
+		return g.EndToEnd.P90
+	}
+	return g.DispatchToAck.P90
+}
+
+// NewPercentiles считает percentile значений в миллисекундах методом nearest-rank
+func NewPercentiles(values []float64) Percentiles {
+	if len(values) == 0 {
+		return Percentiles{}
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	return Percentiles{
+		Count: len(sorted),
+		P50:   quantile(sorted, 0.50),
+		P90:   quantile(sorted, 0.90),
+		P99:   quantile(sorted, 0.99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// quantile возвращает percentile p отсортированных значений методом nearest-rank
+func quantile(sorted []float64, p float64) float64 {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(i, 0)]
+}
+
+// proxyHost возвращает scheme://host прокси без учетных данных
+func proxyHost(proxy string) string {
+	if proxy == "" {
+		return directProxy
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return "invalid"
+	}
+
+	return u.Scheme + "://" + u.Host
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
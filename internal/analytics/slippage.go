@@ -0,0 +1,120 @@
+package analytics
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+
+	"tg_mexc/internal/models"
+)
+
+// SlippageDistribution - распределение проскальзывания в базисных пунктах.
+// Положительное значение - slave исполнился хуже мастера (дороже для long, дешевле для short).
+type SlippageDistribution struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean_bps"`
+	P50   float64 `json:"p50_bps"`
+	P90   float64 `json:"p90_bps"`
+	P99   float64 `json:"p99_bps"`
+	Min   float64 `json:"min_bps"`
+	Max   float64 `json:"max_bps"`
+}
+
+// SlippageGroup - проскальзывание группы входов (символ или slave аккаунт)
+type SlippageGroup struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	SlippageDistribution
+}
+
+// SlippageReport - распределение проскальзывания slave относительно мастера
+type SlippageReport struct {
+	Overall SlippageDistribution `json:"overall"`
+	Symbols []SlippageGroup      `json:"symbols"`
+	Slaves  []SlippageGroup      `json:"slaves"`
+}
+
+// slippageBucket накапливает проскальзывание группы
+type slippageBucket struct {
+	name   string
+	values []float64
+}
+
+// BuildSlippageReport считает распределение проскальзывания в целом, по символам и по slave аккаунтам.
+// Группы отсортированы по p90 (худшие первыми).
+func BuildSlippageReport(samples []models.SlippageSample) SlippageReport {
+	var overall []float64
+	symbols := make(map[string]*slippageBucket)
+	slaves := make(map[string]*slippageBucket)
+
+	for _, sample := range samples {
+		bps := slippageBps(sample)
+		overall = append(overall, bps)
+
+		if symbols[sample.Symbol] == nil {
+			symbols[sample.Symbol] = &slippageBucket{name: sample.Symbol}
+		}
+		symbols[sample.Symbol].values = append(symbols[sample.Symbol].values, bps)
+
+		slaveKey := strconv.Itoa(sample.AccountID)
+		if slaves[slaveKey] == nil {
+			slaves[slaveKey] = &slippageBucket{name: sample.AccountName}
+		}
+		slaves[slaveKey].values = append(slaves[slaveKey].values, bps)
+	}
+
+	return SlippageReport{
+		Overall: distribution(overall),
+		Symbols: slippageGroups(symbols),
+		Slaves:  slippageGroups(slaves),
+	}
+}
+
+func slippageGroups(buckets map[string]*slippageBucket) []SlippageGroup {
+	groups := make([]SlippageGroup, 0, len(buckets))
+	for key, bucket := range buckets {
+		groups = append(groups, SlippageGroup{Key: key, Name: bucket.name, SlippageDistribution: distribution(bucket.values)})
+	}
+
+	slices.SortFunc(groups, func(a, b SlippageGroup) int {
+		if c := cmp.Compare(b.P90, a.P90); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+
+	return groups
+}
+
+// slippageBps - проскальзывание входа в базисных пунктах относительно цены мастера (3 - open short)
+func slippageBps(sample models.SlippageSample) float64 {
+	bps := (sample.FillPrice - sample.MasterPrice) / sample.MasterPrice * 10000
+	if sample.Side == 3 {
+		bps = -bps
+	}
+	return bps
+}
+
+func distribution(values []float64) SlippageDistribution {
+	if len(values) == 0 {
+		return SlippageDistribution{}
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+
+	return SlippageDistribution{
+		Count: len(sorted),
+		Mean:  sum / float64(len(sorted)),
+		P50:   quantile(sorted, 0.50),
+		P90:   quantile(sorted, 0.90),
+		P99:   quantile(sorted, 0.99),
+		Min:   sorted[0],
+		Max:   sorted[len(sorted)-1],
+	}
+}
@@ -6,7 +6,8 @@ import (
 	"strconv"
 
 	"tg_mexc/internal/api/middleware"
-	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/health"
+	"tg_mexc/internal/httpmiddleware"
 	"tg_mexc/internal/models"
 
 	"github.com/gorilla/mux"
@@ -19,16 +20,23 @@ type AddAccountRequest struct {
 }
 
 type AccountResponse struct {
-	ID       int     `json:"id"`
-	Name     string  `json:"name"`
-	Token    string  `json:"token"`
-	DeviceID string  `json:"device_id"`
-	Proxy    string  `json:"proxy,omitempty"`
-	IsMaster bool    `json:"is_master"`
-	Disabled bool    `json:"disabled"`
-	MakerFee float64 `json:"maker_fee,omitempty"`
-	TakerFee float64 `json:"taker_fee,omitempty"`
-	Balance  float64 `json:"balance,omitempty"`
+	ID             int             `json:"id"`
+	Name           string          `json:"name"`
+	Token          string          `json:"token"`
+	DeviceID       string          `json:"device_id"`
+	Proxy          string          `json:"proxy,omitempty"`
+	IsMaster       bool            `json:"is_master"`
+	Disabled       bool            `json:"disabled"`
+	SessionInvalid bool            `json:"session_invalid"`
+	CopyMultiplier float64         `json:"copy_multiplier"`
+	Caps           models.CopyCaps `json:"caps"`
+	MakerFee       float64         `json:"maker_fee,omitempty"`
+	TakerFee       float64         `json:"taker_fee,omitempty"`
+	Balance        float64         `json:"balance,omitempty"`
+	Health         *health.Score   `json:"health,omitempty"`
+
+	Status      *models.AccountStatus `json:"status,omitempty"`
+	StatusError string                `json:"status_error,omitempty"`
 }
 
 // HandleGetAccounts возвращает список всех аккаунтов пользователя
@@ -43,24 +51,31 @@ func (h *Handler) HandleGetAccounts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	scores := h.healthScores(userID)
+
 	// Преобразуем в response
 	var response []AccountResponse
 	for _, acc := range accounts {
 		response = append(response, AccountResponse{
-			ID:       acc.ID,
-			Name:     acc.Name,
-			Token:    acc.Token[:10] + "...", // Показываем только начало токена
-			DeviceID: acc.DeviceID,
-			Proxy:    acc.Proxy,
-			IsMaster: acc.IsMaster,
-			Disabled: acc.Disabled,
+			ID:             acc.ID,
+			Name:           acc.Name,
+			Token:          acc.Token[:10] + "...", // Показываем только начало токена
+			DeviceID:       acc.DeviceID,
+			Proxy:          acc.Proxy,
+			IsMaster:       acc.IsMaster,
+			Disabled:       acc.Disabled,
+			SessionInvalid: acc.SessionInvalid,
+			CopyMultiplier: acc.VolumeMultiplier(),
+			Caps:           acc.Caps,
+			Health:         scores.get(acc.ID),
 		})
 	}
 
 	h.respondSuccess(w, "", response)
 }
 
-// HandleGetAccountsWithDetails возвращает аккаунты с балансами и комиссиями
+// HandleGetAccountsWithDetails возвращает аккаунты с балансами и комиссиями.
+// Комиссии берутся из кэша MEXC клиента, ?refresh=true - запрашиваются заново
 func (h *Handler) HandleGetAccountsWithDetails(w http.ResponseWriter, r *http.Request) {
 	userID, _ := middleware.GetUserID(r.Context())
 
@@ -73,23 +88,29 @@ func (h *Handler) HandleGetAccountsWithDetails(w http.ResponseWriter, r *http.Re
 	}
 
 	ctx := r.Context()
+	refresh := r.URL.Query().Get("refresh") == "true"
+	scores := h.healthScores(userID)
 	var response []AccountResponse
 
 	for _, acc := range accounts {
-		client, err := mexc.NewClient(acc, h.logger)
+		client, err := h.clients.Get(acc)
 		if err != nil {
 			h.logger.Error("Failed to create MEXC client", "account", acc.Name, "error", err)
 			continue
 		}
 
 		accResp := AccountResponse{
-			ID:       acc.ID,
-			Name:     acc.Name,
-			Token:    acc.Token[:10] + "...",
-			DeviceID: acc.DeviceID,
-			Proxy:    acc.Proxy,
-			IsMaster: acc.IsMaster,
-			Disabled: acc.Disabled,
+			ID:             acc.ID,
+			Name:           acc.Name,
+			Token:          acc.Token[:10] + "...",
+			DeviceID:       acc.DeviceID,
+			Proxy:          acc.Proxy,
+			IsMaster:       acc.IsMaster,
+			Disabled:       acc.Disabled,
+			SessionInvalid: acc.SessionInvalid,
+			CopyMultiplier: acc.VolumeMultiplier(),
+			Caps:           acc.Caps,
+			Health:         scores.get(acc.ID),
 		}
 
 		// Получаем баланс
@@ -104,18 +125,70 @@ func (h *Handler) HandleGetAccountsWithDetails(w http.ResponseWriter, r *http.Re
 		}
 
 		// Получаем комиссии
-		feeRate, err := client.GetTieredFeeRate(ctx, "")
+		getFeeRate := client.GetTieredFeeRateCached
+		if refresh {
+			getFeeRate = client.RefreshTieredFeeRate
+		}
+		feeRate, err := getFeeRate(ctx, "")
 		if err == nil {
 			accResp.MakerFee = feeRate.OriginalMakerFee
 			accResp.TakerFee = feeRate.OriginalTakerFee
 		}
 
+		// Состояние аккаунта на бирже (KYC, ограничения, фьючерсы)
+		getStatus := client.GetAccountStatusCached
+		if refresh {
+			getStatus = client.RefreshAccountStatus
+		}
+		if status, err := getStatus(ctx); err == nil {
+			accResp.Status = status
+		} else {
+			accResp.StatusError = err.Error()
+		}
+
 		response = append(response, accResp)
 	}
 
 	h.respondSuccess(w, "", response)
 }
 
+// HandleGetAccountsHealth возвращает оценки здоровья аккаунтов от самого слабого к самому здоровому
+func (h *Handler) HandleGetAccountsHealth(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	list, err := h.health.List(userID)
+	if err != nil {
+		h.logger.Error("Failed to get account health", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get account health")
+		return
+	}
+
+	h.respondSuccess(w, "", list)
+}
+
+// accountScores - оценки здоровья аккаунтов по ID
+type accountScores map[int]health.Score
+
+func (s accountScores) get(accountID int) *health.Score {
+	score, ok := s[accountID]
+	if !ok {
+		return nil
+	}
+
+	return &score
+}
+
+// healthScores возвращает оценки здоровья аккаунтов (ошибка логируется - список аккаунтов отдается без оценок)
+func (h *Handler) healthScores(userID int) accountScores {
+	scores, err := h.health.Scores(userID)
+	if err != nil {
+		h.logger.Error("Failed to get account health", "error", err)
+		return nil
+	}
+
+	return scores
+}
+
 // HandleAddAccount добавляет новый аккаунт
 func (h *Handler) HandleAddAccount(w http.ResponseWriter, r *http.Request) {
 	userID, _ := middleware.GetUserID(r.Context())
@@ -137,6 +210,12 @@ func (h *Handler) HandleAddAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Прокси: один или список через запятую (пул с ротацией)
+	if _, err := httpmiddleware.ParseProxies(req.Proxy); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	// Проверяем, существует ли уже аккаунт с таким MEXC UID
 	exists, err := h.storage.AccountExistsByMexcUID(userID, req.BrowserData.UID)
 	if err != nil {
@@ -180,6 +259,8 @@ func (h *Handler) HandleDeleteAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.clients.Invalidate(accountID)
+
 	h.respondSuccess(w, "Account deleted successfully", nil)
 }
 
@@ -250,6 +331,76 @@ func (h *Handler) HandleToggleDisabled(w http.ResponseWriter, r *http.Request) {
 	h.respondSuccess(w, "Account status updated successfully", nil)
 }
 
+// HandleSetCopyMultiplier задает множитель объёма входов мастера на slave аккаунте
+// (применяется к следующим входам, в том числе в запущенной сессии)
+func (h *Handler) HandleSetCopyMultiplier(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	vars := mux.Vars(r)
+	accountID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid account ID")
+		return
+	}
+
+	var req struct {
+		Multiplier float64 `json:"multiplier"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := models.ValidateCopyMultiplier(req.Multiplier); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = h.storage.UpdateCopyMultiplier(userID, accountID, req.Multiplier)
+	if err != nil {
+		h.logger.Error("Failed to update copy multiplier", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update copy multiplier")
+
+		return
+	}
+
+	h.respondSuccess(w, "Copy multiplier updated successfully", nil)
+}
+
+// HandleSetCopyCaps задает ограничения объёма входов мастера на slave аккаунте (0 - без ограничения)
+func (h *Handler) HandleSetCopyCaps(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	vars := mux.Vars(r)
+	accountID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid account ID")
+		return
+	}
+
+	var caps models.CopyCaps
+	if err := json.NewDecoder(r.Body).Decode(&caps); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := caps.Validate(); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = h.storage.UpdateCopyCaps(userID, accountID, caps)
+	if err != nil {
+		h.logger.Error("Failed to update copy caps", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update copy caps")
+
+		return
+	}
+
+	h.respondSuccess(w, "Copy caps updated successfully", nil)
+}
+
 // HandleGetScript возвращает JS скрипт для извлечения данных из браузера
 func (h *Handler) HandleGetScript(w http.ResponseWriter, r *http.Request) {
 	script := `function downloadJSON(data, filename) {
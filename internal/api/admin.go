@@ -0,0 +1,102 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"tg_mexc/internal/api/auth"
+	"tg_mexc/internal/api/middleware"
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/mexc/websocket"
+	"tg_mexc/internal/models"
+)
+
+// UnlockRequest - запрос на снятие блокировки входа (username и/или ip)
+type UnlockRequest struct {
+	Username string `json:"username"`
+	IP       string `json:"ip"`
+}
+
+// HandleGetLockouts возвращает действующие блокировки входа
+func (h *Handler) HandleGetLockouts(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := h.loginGuard.ActiveLockouts()
+	if err != nil {
+		h.logger.Error("Failed to get lockouts", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get lockouts")
+
+		return
+	}
+
+	if lockouts == nil {
+		lockouts = []models.LoginLockout{}
+	}
+
+	h.respondSuccess(w, "", lockouts)
+}
+
+// HandleUnlock снимает блокировку входа по username и/или IP
+func (h *Handler) HandleUnlock(w http.ResponseWriter, r *http.Request) {
+	adminID, _ := middleware.GetUserID(r.Context())
+	adminName, _ := middleware.GetUsername(r.Context())
+
+	var req UnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.Username == "" && req.IP == "" {
+		h.respondError(w, http.StatusBadRequest, "Username or IP is required")
+		return
+	}
+
+	if req.Username != "" {
+		if err := h.loginGuard.Unlock(auth.ScopeUsername, req.Username); err != nil {
+			h.logger.Error("Failed to unlock username", "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to unlock")
+
+			return
+		}
+
+		// Пишем в лог заблокированного пользователя, если он существует
+		if user, err := h.storage.GetUserByUsername(req.Username); err == nil {
+			h.storage.AddLog(r.Context(), models.ActivityLog{
+				UserID:  &user.ID,
+				Level:   "info",
+				Action:  "login_unlocked",
+				Message: fmt.Sprintf("Login unlocked by admin %s", adminName),
+			})
+		}
+	}
+
+	if req.IP != "" {
+		if err := h.loginGuard.Unlock(auth.ScopeIP, req.IP); err != nil {
+			h.logger.Error("Failed to unlock IP", "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to unlock")
+
+			return
+		}
+	}
+
+	h.storage.AddLog(r.Context(), models.ActivityLog{
+		UserID:  &adminID,
+		Level:   "info",
+		Action:  "login_unlock",
+		Message: fmt.Sprintf("Unlocked login (username: %q, ip: %q)", req.Username, req.IP),
+	})
+
+	h.respondSuccess(w, "Unlocked", nil)
+}
+
+// HandleGetTransportStats возвращает статистику соединений MEXC клиентов: доля переиспользованных
+// соединений, новые соединения и TLS handshakes (для настройки MEXC_MAX_IDLE_CONNS_PER_HOST и др.)
+func (h *Handler) HandleGetTransportStats(w http.ResponseWriter, r *http.Request) {
+	h.respondSuccess(w, "", mexc.TransportStats())
+}
+
+// HandleGetWebSocketStats возвращает статистику WebSocket соединений MEXC: сообщения по каналам,
+// разрывы и переподключения, задержку от события на бирже до передачи обработчику
+func (h *Handler) HandleGetWebSocketStats(w http.ResponseWriter, r *http.Request) {
+	h.respondSuccess(w, "", websocket.GetStats())
+}
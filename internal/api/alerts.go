@@ -0,0 +1,150 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"tg_mexc/internal/alerts"
+	"tg_mexc/internal/api/middleware"
+	"tg_mexc/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// HandleGetAlertRules возвращает правила алертов пользователя
+func (h *Handler) HandleGetAlertRules(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	rules, err := h.storage.GetAlertRules(userID)
+	if err != nil {
+		h.logger.Error("Failed to get alert rules", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get alert rules")
+		return
+	}
+
+	h.respondSuccess(w, "", rules)
+}
+
+// HandleCreateAlertRule создает правило алерта
+func (h *Handler) HandleCreateAlertRule(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	rule, ok := h.decodeAlertRule(w, r, userID)
+	if !ok {
+		return
+	}
+
+	id, err := h.storage.CreateAlertRule(rule)
+	if err != nil {
+		h.logger.Error("Failed to create alert rule", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to create alert rule")
+		return
+	}
+	rule.ID = id
+
+	h.respondSuccess(w, "Alert rule created", rule)
+}
+
+// HandleUpdateAlertRule обновляет правило алерта
+func (h *Handler) HandleUpdateAlertRule(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	ruleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	rule, ok := h.decodeAlertRule(w, r, userID)
+	if !ok {
+		return
+	}
+	rule.ID = ruleID
+
+	if err := h.storage.UpdateAlertRule(rule); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondError(w, http.StatusNotFound, "Alert rule not found")
+			return
+		}
+		h.logger.Error("Failed to update alert rule", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update alert rule")
+		return
+	}
+
+	h.respondSuccess(w, "Alert rule updated", rule)
+}
+
+// HandleDeleteAlertRule удаляет правило алерта
+func (h *Handler) HandleDeleteAlertRule(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	ruleID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	if err := h.storage.DeleteAlertRule(userID, ruleID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			h.respondError(w, http.StatusNotFound, "Alert rule not found")
+			return
+		}
+		h.logger.Error("Failed to delete alert rule", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to delete alert rule")
+		return
+	}
+
+	h.respondSuccess(w, "Alert rule deleted", nil)
+}
+
+// HandleGetAlertEvents возвращает последние срабатывания алертов (?limit=, по умолчанию 50)
+func (h *Handler) HandleGetAlertEvents(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	limit, _ := parsePagination(r, 50, 500)
+
+	events, err := h.storage.GetAlertEvents(userID, limit)
+	if err != nil {
+		h.logger.Error("Failed to get alert events", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get alert events")
+		return
+	}
+
+	h.respondSuccess(w, "", events)
+}
+
+// decodeAlertRule читает и валидирует правило из тела запроса.
+// При ошибке ответ уже отправлен.
+func (h *Handler) decodeAlertRule(w http.ResponseWriter, r *http.Request, userID int) (models.AlertRule, bool) {
+	rule := models.AlertRule{Enabled: true} // enabled можно не указывать
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return rule, false
+	}
+	rule.UserID = userID
+
+	if err := alerts.Validate(&rule); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return rule, false
+	}
+
+	if rule.AccountID != nil {
+		accounts, err := h.storage.GetAccounts(userID)
+		if err != nil {
+			h.logger.Error("Failed to get accounts", "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to get accounts")
+			return rule, false
+		}
+
+		if !slices.ContainsFunc(accounts, func(acc models.Account) bool { return acc.ID == *rule.AccountID }) {
+			h.respondError(w, http.StatusBadRequest, "Account not found")
+			return rule, false
+		}
+	}
+
+	return rule, true
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"tg_mexc/internal/analytics"
+	"tg_mexc/internal/api/middleware"
+)
+
+const (
+	defaultAnalyticsDays = 7
+	maxAnalyticsDays     = 90
+)
+
+// HandleGetLatency возвращает percentile задержек копирования по slave аккаунтам и прокси (?days=7)
+func (h *Handler) HandleGetLatency(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	days := parseDays(r, defaultAnalyticsDays, maxAnalyticsDays)
+	since := time.Now().AddDate(0, 0, -days)
+
+	samples, err := h.storage.GetLatencySamples(userID, since)
+	if err != nil {
+		h.logger.Error("Failed to get latency samples", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get latency analytics")
+		return
+	}
+
+	h.respondSuccess(w, "", analytics.BuildLatencyReport(samples))
+}
+
+// HandleGetSlippage возвращает распределение проскальзывания slave относительно мастера по символам и аккаунтам (?days=7)
+func (h *Handler) HandleGetSlippage(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	days := parseDays(r, defaultAnalyticsDays, maxAnalyticsDays)
+	since := time.Now().AddDate(0, 0, -days)
+
+	samples, err := h.storage.GetSlippageSamples(userID, since)
+	if err != nil {
+		h.logger.Error("Failed to get slippage samples", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get slippage analytics")
+		return
+	}
+
+	h.respondSuccess(w, "", analytics.BuildSlippageReport(samples))
+}
+
+// HandleGetExposure возвращает суммарную экспозицию по символам и направлениям на всех аккаунтах
+// с отметкой превышения лимитов концентрации
+func (h *Handler) HandleGetExposure(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	ctx, cancel := context.WithTimeout(r.Context(), historySyncTimeout)
+	defer cancel()
+
+	report, err := h.exposure.Exposure(ctx, userID)
+	if err != nil {
+		h.logger.Error("Failed to get exposure", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get exposure")
+		return
+	}
+
+	h.respondSuccess(w, "", report)
+}
@@ -1,11 +1,22 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
 	"time"
+
+	"tg_mexc/internal/api/auth"
+	"tg_mexc/internal/models"
 )
 
 type LoginRequest struct {
@@ -16,6 +27,7 @@ type LoginRequest struct {
 type LoginResponse struct {
 	Token        string `json:"token"`
 	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // Время жизни access token в секундах
 	Username     string `json:"username"`
 	UserID       int    `json:"user_id"`
 }
@@ -27,6 +39,7 @@ type RefreshRequest struct {
 type RefreshResponse struct {
 	Token        string `json:"token"`
 	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
 }
 
 type RegisterRequest struct {
@@ -48,11 +61,29 @@ func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Проверяем блокировку по username и IP
+	ip := h.clientIP(r)
+	if retryAfter, err := h.loginGuard.Check(req.Username, ip); err != nil {
+		if errors.Is(err, auth.ErrLockedOut) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			h.respondError(w, http.StatusTooManyRequests, "Too many failed login attempts, try again later")
+
+			return
+		}
+
+		h.logger.Error("Failed to check login lockout", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Internal server error")
+
+		return
+	}
+
 	// Получаем пользователя из БД
 	user, err := h.storage.GetUserByUsername(req.Username)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			h.registerLoginFailure(r.Context(), req.Username, ip, nil)
 			h.respondError(w, http.StatusUnauthorized, "Invalid credentials")
+
 			return
 		}
 
@@ -64,47 +95,226 @@ func (h *Handler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 
 	// Проверяем пароль
 	if err := h.authService.VerifyPassword(user.PasswordHash, req.Password); err != nil {
+		h.registerLoginFailure(r.Context(), req.Username, ip, &user.ID)
 		h.respondError(w, http.StatusUnauthorized, "Invalid credentials")
+
 		return
 	}
 
-	// Генерируем JWT токен
-	token, err := h.authService.GenerateToken(user.ID, user.Username)
+	if err := h.loginGuard.RegisterSuccess(req.Username, ip); err != nil {
+		h.logger.Error("Failed to register login success", "error", err)
+	}
+
+	// Прозрачно переводим старые bcrypt хеши на Argon2id (пароль известен только сейчас)
+	if h.authService.NeedsRehash(user.PasswordHash) {
+		h.rehashPassword(user.ID, req.Password)
+	}
+
+	h.respondWithTokens(w, "Login successful", user)
+}
+
+// HandleTelegramLogin обрабатывает вход через Telegram Login Widget (маршрут есть только при TELEGRAM_BOT_USERNAME).
+// Пользователь ищется по telegram_chat_id - для личных чатов он совпадает с Telegram user ID.
+// Входят только пользователи с уже привязанным chat_id: виджет не создает аккаунтов
+func (h *Handler) HandleTelegramLogin(w http.ResponseWriter, r *http.Request) {
+	var req auth.TelegramLoginData
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if req.ID == 0 || req.Hash == "" {
+		h.respondError(w, http.StatusBadRequest, "Telegram id and hash are required")
+		return
+	}
+
+	if err := h.telegramLogin.Verify(req); err != nil {
+		h.logger.Warn("Telegram login rejected",
+			slog.Int64("telegram_id", req.ID),
+			slog.String("ip", h.clientIP(r)),
+			slog.Any("error", err))
+		h.respondError(w, http.StatusUnauthorized, "Invalid Telegram login data")
+
+		return
+	}
+
+	userID, err := h.storage.GetUserIDByTelegramChatID(req.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		h.respondError(w, http.StatusForbidden, "Telegram account is not linked to any user")
+		return
+	}
 	if err != nil {
-		h.logger.Error("Failed to generate token", "error", err)
+		h.logger.Error("Failed to get telegram user", "error", err)
 		h.respondError(w, http.StatusInternalServerError, "Internal server error")
 
 		return
 	}
 
-	// Генерируем refresh token
-	refreshToken, err := h.authService.GenerateRefreshToken()
+	user, err := h.storage.GetUserByID(userID)
 	if err != nil {
-		h.logger.Error("Failed to generate refresh token", "error", err)
+		h.logger.Error("Failed to get user", "error", err)
 		h.respondError(w, http.StatusInternalServerError, "Internal server error")
 
 		return
 	}
 
-	// Сохраняем refresh token в БД
-	expiresAt := time.Now().Add(h.authService.RefreshTokenTTL())
-	if err := h.storage.SaveRefreshToken(user.ID, refreshToken, expiresAt); err != nil {
-		h.logger.Error("Failed to save refresh token", "error", err)
+	h.respondWithTokens(w, "Login successful", user)
+}
+
+// respondWithTokens выдает access и refresh токены пользователю (начало новой сессии)
+func (h *Handler) respondWithTokens(w http.ResponseWriter, message string, user *models.User) {
+	resp, err := h.issueTokens(user, h.authService.Now())
+	if err != nil {
+		h.logger.Error("Failed to issue tokens", "error", err)
 		h.respondError(w, http.StatusInternalServerError, "Internal server error")
 
 		return
 	}
 
-	h.respondSuccess(w, "Login successful", LoginResponse{
+	h.respondSuccess(w, message, resp)
+}
+
+// issueTokens генерирует access token и refresh token. sessionStart - момент входа,
+// от которого отсчитывается абсолютное время жизни сессии (сохраняется при ротации)
+func (h *Handler) issueTokens(user *models.User, sessionStart time.Time) (*LoginResponse, error) {
+	// Генерируем JWT токен
+	token, err := h.authService.GenerateToken(user.ID, user.Username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	// Генерируем refresh token
+	refreshToken, err := h.authService.GenerateRefreshToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	// Сохраняем refresh token в БД
+	expiresAt := h.authService.RefreshTokenExpiry(sessionStart)
+	if err := h.storage.SaveRefreshToken(user.ID, refreshToken, expiresAt, sessionStart); err != nil {
+		return nil, fmt.Errorf("failed to save refresh token: %w", err)
+	}
+
+	return &LoginResponse{
 		Token:        token,
 		RefreshToken: refreshToken,
+		ExpiresIn:    int(h.authService.TokenTTL().Seconds()),
 		Username:     user.Username,
 		UserID:       user.ID,
-	})
+	}, nil
+}
+
+// registerLoginFailure учитывает неудачную попытку входа и пишет её в audit log.
+// userID = nil если пользователь не найден (запись в activity_log не создается,
+// чтобы не показывать её всем пользователям)
+func (h *Handler) registerLoginFailure(ctx context.Context, username, ip string, userID *int) {
+	lockouts, err := h.loginGuard.RegisterFailure(username, ip)
+	if err != nil {
+		h.logger.Error("Failed to register login failure", "error", err)
+	}
+
+	h.logger.Warn("Failed login attempt",
+		slog.String("username", username),
+		slog.String("ip", ip))
+
+	if userID != nil {
+		h.storage.AddLog(ctx, models.ActivityLog{
+			UserID:  userID,
+			Level:   "warn",
+			Action:  "login_failed",
+			Message: fmt.Sprintf("Failed login attempt from %s", ip),
+		})
+	}
+
+	for _, lockout := range lockouts {
+		h.logger.Warn("Login locked",
+			slog.String("scope", lockout.Scope),
+			slog.String("key", lockout.Key),
+			slog.Time("until", lockout.LockedUntil))
+
+		if userID != nil {
+			h.storage.AddLog(ctx, models.ActivityLog{
+				UserID:  userID,
+				Level:   "warn",
+				Action:  "login_locked",
+				Message: fmt.Sprintf("Login locked by %s %s until %s", lockout.Scope, lockout.Key, lockout.LockedUntil.Format(time.RFC3339)),
+			})
+		}
+	}
+}
+
+// rehashPassword пересчитывает хеш пароля с текущими параметрами.
+// Ошибки не прерывают вход - старый хеш остается валидным
+func (h *Handler) rehashPassword(userID int, password string) {
+	passwordHash, err := h.authService.HashPassword(password)
+	if err != nil {
+		h.logger.Error("Failed to rehash password", "error", err)
+		return
+	}
+
+	if err := h.storage.UpdatePasswordHash(userID, passwordHash); err != nil {
+		h.logger.Error("Failed to update password hash", "error", err)
+		return
+	}
+
+	h.logger.Info("Password hash upgraded", slog.Int("user_id", userID))
+}
+
+// clientIP возвращает IP клиента: адрес соединения, а за доверенным reverse proxy - ближайший к нему
+// недоверенный адрес X-Forwarded-For (или X-Real-IP). Заголовки от остальных клиентов игнорируются - иначе
+// клиент подменил бы IP и обошел блокировку входа по IP
+func (h *Handler) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	peer, err := netip.ParseAddr(host)
+	if err != nil || !h.trustedProxy(peer) {
+		return host
+	}
+
+	// Каждый proxy дописывает адрес своего клиента в конец: идем справа до первого недоверенного
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		if !h.trustedProxy(addr) || i == 0 {
+			return addr.Unmap().String()
+		}
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+
+	return host
+}
+
+// trustedProxy сообщает, входит ли addr в TRUSTED_PROXIES
+func (h *Handler) trustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range h.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
 }
 
 // HandleRegister обрабатывает регистрацию нового пользователя
 func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
+	if !h.registration {
+		h.respondError(w, http.StatusForbidden, "Registration is disabled")
+		return
+	}
+
 	var req RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.respondError(w, http.StatusBadRequest, "Invalid request body")
@@ -146,39 +356,7 @@ func (h *Handler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Генерируем JWT токен
-	token, err := h.authService.GenerateToken(user.ID, user.Username)
-	if err != nil {
-		h.logger.Error("Failed to generate token", "error", err)
-		h.respondError(w, http.StatusInternalServerError, "Internal server error")
-
-		return
-	}
-
-	// Генерируем refresh token
-	refreshToken, err := h.authService.GenerateRefreshToken()
-	if err != nil {
-		h.logger.Error("Failed to generate refresh token", "error", err)
-		h.respondError(w, http.StatusInternalServerError, "Internal server error")
-
-		return
-	}
-
-	// Сохраняем refresh token в БД
-	expiresAt := time.Now().Add(h.authService.RefreshTokenTTL())
-	if err := h.storage.SaveRefreshToken(user.ID, refreshToken, expiresAt); err != nil {
-		h.logger.Error("Failed to save refresh token", "error", err)
-		h.respondError(w, http.StatusInternalServerError, "Internal server error")
-
-		return
-	}
-
-	h.respondSuccess(w, "Registration successful", LoginResponse{
-		Token:        token,
-		RefreshToken: refreshToken,
-		Username:     user.Username,
-		UserID:       user.ID,
-	})
+	h.respondWithTokens(w, "Registration successful", user)
 }
 
 // HandleRefresh обновляет access token используя refresh token
@@ -195,50 +373,40 @@ func (h *Handler) HandleRefresh(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Проверяем refresh token в БД
-	userID, err := h.storage.GetRefreshToken(req.RefreshToken)
+	userID, sessionStart, err := h.storage.GetRefreshToken(req.RefreshToken)
 	if err != nil {
 		h.respondError(w, http.StatusUnauthorized, "Invalid refresh token")
 		return
 	}
 
-	// Получаем пользователя
-	user, err := h.storage.GetUserByID(userID)
-	if err != nil {
-		h.logger.Error("Failed to get user", "error", err)
-		h.respondError(w, http.StatusInternalServerError, "Internal server error")
-		return
-	}
-
-	// Удаляем старый refresh token
+	// Удаляем старый refresh token (ротация - каждый токен одноразовый)
 	h.storage.DeleteRefreshToken(req.RefreshToken)
 
-	// Генерируем новый access token
-	token, err := h.authService.GenerateToken(user.ID, user.Username)
-	if err != nil {
-		h.logger.Error("Failed to generate token", "error", err)
-		h.respondError(w, http.StatusInternalServerError, "Internal server error")
+	// Абсолютный лимит сессии - после него нужен повторный вход
+	if h.authService.SessionExpired(sessionStart) {
+		h.respondError(w, http.StatusUnauthorized, "Session expired")
 		return
 	}
 
-	// Генерируем новый refresh token
-	refreshToken, err := h.authService.GenerateRefreshToken()
+	// Получаем пользователя
+	user, err := h.storage.GetUserByID(userID)
 	if err != nil {
-		h.logger.Error("Failed to generate refresh token", "error", err)
+		h.logger.Error("Failed to get user", "error", err)
 		h.respondError(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
-	// Сохраняем новый refresh token
-	expiresAt := time.Now().Add(h.authService.RefreshTokenTTL())
-	if err := h.storage.SaveRefreshToken(user.ID, refreshToken, expiresAt); err != nil {
-		h.logger.Error("Failed to save refresh token", "error", err)
+	resp, err := h.issueTokens(user, sessionStart)
+	if err != nil {
+		h.logger.Error("Failed to issue tokens", "error", err)
 		h.respondError(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
 	h.respondSuccess(w, "Token refreshed", RefreshResponse{
-		Token:        token,
-		RefreshToken: refreshToken,
+		Token:        resp.Token,
+		RefreshToken: resp.RefreshToken,
+		ExpiresIn:    resp.ExpiresIn,
 	})
 }
 
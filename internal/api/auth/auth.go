@@ -4,10 +4,12 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"strconv"
 	"time"
 
+	"tg_mexc/internal/clock"
+
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var (
@@ -15,18 +17,42 @@ var (
 	ErrInvalidToken       = errors.New("invalid token")
 )
 
+// TokenTypeAccess - тип access токена. Только такие токены принимаются AuthMiddleware
+const TokenTypeAccess = "access"
+
 // Claims представляет JWT claims
 type Claims struct {
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
+	UserID    int    `json:"user_id"`
+	Username  string `json:"username"`
+	TokenType string `json:"token_type"`
 	jwt.RegisteredClaims
 }
 
+// ClaimsConfig - параметры выпуска и проверки JWT
+type ClaimsConfig struct {
+	Issuer    string        // iss
+	Audience  string        // aud
+	ClockSkew time.Duration // Допустимое расхождение часов при проверке exp/nbf/iat
+}
+
+// DefaultClaimsConfig возвращает параметры по умолчанию
+func DefaultClaimsConfig() ClaimsConfig {
+	return ClaimsConfig{
+		Issuer:    "tg_mexc",
+		Audience:  "tg_mexc-web",
+		ClockSkew: 30 * time.Second,
+	}
+}
+
 // Service управляет аутентификацией
 type Service struct {
 	jwtSecret       []byte
 	tokenTTL        time.Duration
 	refreshTokenTTL time.Duration
+	sessionMaxAge   time.Duration // Абсолютное время жизни сессии (0 - без ограничения)
+	passwordParams  Argon2Params
+	claims          ClaimsConfig
+	clock           clock.Clock
 }
 
 // NewService создает новый auth сервис
@@ -35,9 +61,23 @@ func NewService(jwtSecret string, tokenTTL time.Duration) *Service {
 		jwtSecret:       []byte(jwtSecret),
 		tokenTTL:        tokenTTL,
 		refreshTokenTTL: 7 * 24 * time.Hour, // 7 дней
+		sessionMaxAge:   30 * 24 * time.Hour,
+		passwordParams:  DefaultArgon2Params(),
+		claims:          DefaultClaimsConfig(),
+		clock:           clock.Real,
 	}
 }
 
+// SetClaimsConfig задает issuer/audience и допуск расхождения часов
+func (s *Service) SetClaimsConfig(cfg ClaimsConfig) {
+	s.claims = cfg
+}
+
+// SetClock подменяет источник времени (для проверки истечения токенов)
+func (s *Service) SetClock(clk clock.Clock) {
+	s.clock = clk
+}
+
 // GenerateRefreshToken генерирует случайный refresh token
 func (s *Service) GenerateRefreshToken() (string, error) {
 	bytes := make([]byte, 32)
@@ -47,34 +87,61 @@ func (s *Service) GenerateRefreshToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// SetSessionTTL задает время жизни refresh token (скользящее, продлевается при каждом обновлении)
+// и абсолютное время жизни сессии с момента входа
+func (s *Service) SetSessionTTL(refreshTokenTTL, sessionMaxAge time.Duration) {
+	s.refreshTokenTTL = refreshTokenTTL
+	s.sessionMaxAge = sessionMaxAge
+}
+
+// TokenTTL возвращает время жизни access token
+func (s *Service) TokenTTL() time.Duration {
+	return s.tokenTTL
+}
+
 // RefreshTokenTTL возвращает время жизни refresh token
 func (s *Service) RefreshTokenTTL() time.Duration {
 	return s.refreshTokenTTL
 }
 
-// HashPassword хеширует пароль
-func (s *Service) HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
+// Now возвращает текущее время по часам сервиса: начало новой сессии отсчитывается по ним,
+// как и ее истечение (SessionExpired)
+func (s *Service) Now() time.Time {
+	return s.clock.Now()
+}
+
+// RefreshTokenExpiry возвращает срок действия нового refresh token: не позже конца сессии
+func (s *Service) RefreshTokenExpiry(sessionStart time.Time) time.Time {
+	expiresAt := s.clock.Now().Add(s.refreshTokenTTL)
+	if s.sessionMaxAge > 0 {
+		if sessionEnd := sessionStart.Add(s.sessionMaxAge); sessionEnd.Before(expiresAt) {
+			return sessionEnd
+		}
 	}
 
-	return string(hash), nil
+	return expiresAt
 }
 
-// VerifyPassword проверяет пароль
-func (s *Service) VerifyPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+// SessionExpired проверяет абсолютный лимит сессии
+func (s *Service) SessionExpired(sessionStart time.Time) bool {
+	return s.sessionMaxAge > 0 && s.clock.Since(sessionStart) > s.sessionMaxAge
 }
 
 // GenerateToken создает JWT токен
 func (s *Service) GenerateToken(userID int, username string) (string, error) {
+	now := s.clock.Now()
+
 	claims := &Claims{
-		UserID:   userID,
-		Username: username,
+		UserID:    userID,
+		Username:  username,
+		TokenType: TokenTypeAccess,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.tokenTTL)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    s.claims.Issuer,
+			Subject:   strconv.Itoa(userID),
+			Audience:  jwt.ClaimStrings{s.claims.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.tokenTTL)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
@@ -83,22 +150,32 @@ func (s *Service) GenerateToken(userID int, username string) (string, error) {
 	return token.SignedString(s.jwtSecret)
 }
 
-// ValidateToken проверяет JWT токен и возвращает claims
+// ValidateToken строго проверяет access токен (алгоритм, iss, aud, exp, nbf, iat, тип) и возвращает claims
 func (s *Service) ValidateToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (any, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
-		}
-
 		return s.jwtSecret, nil
-	})
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.claims.Issuer),
+		jwt.WithAudience(s.claims.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(s.claims.ClockSkew),
+		jwt.WithTimeFunc(s.clock.Now),
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	// Принимаем только access токены - токены другого типа не должны открывать API
+	if claims.TokenType != TokenTypeAccess || claims.UserID == 0 || claims.Subject != strconv.Itoa(claims.UserID) {
+		return nil, ErrInvalidToken
 	}
 
-	return nil, ErrInvalidToken
+	return claims, nil
 }
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"tg_mexc/internal/models"
+)
+
+// AdminStorage - операции с пользователями, нужные для создания первого администратора
+type AdminStorage interface {
+	CountAdmins() (int, error)
+	GetUserByUsername(username string) (*models.User, error)
+	CreateUser(username, passwordHash string) (*models.User, error)
+	SetUserAdmin(userID int, isAdmin bool) error
+}
+
+// BootstrapAdmin создает администратора, если в БД еще нет ни одного.
+// Существующий пользователь с тем же username не повышается - его мог зарегистрировать кто угодно
+func BootstrapAdmin(storage AdminStorage, svc *Service, username, password string, logger *slog.Logger) error {
+	if username == "" {
+		return nil
+	}
+
+	admins, err := storage.CountAdmins()
+	if err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+
+	if admins > 0 {
+		return nil
+	}
+
+	if password == "" {
+		return errors.New("ADMIN_PASSWORD or ADMIN_PASSWORD_FILE is required to create the admin user")
+	}
+
+	_, err = storage.GetUserByUsername(username)
+	if err == nil {
+		return fmt.Errorf("user %s already exists and is not an admin", username)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	passwordHash, err := svc.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user, err := storage.CreateUser(username, passwordHash)
+	if err != nil {
+		return fmt.Errorf("failed to create admin: %w", err)
+	}
+
+	if err := storage.SetUserAdmin(user.ID, true); err != nil {
+		return fmt.Errorf("failed to grant admin: %w", err)
+	}
+
+	logger.Info("👑 Admin user created", slog.String("username", username), slog.Int("user_id", user.ID))
+
+	return nil
+}
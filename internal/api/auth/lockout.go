@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"tg_mexc/internal/clock"
+	"tg_mexc/internal/models"
+)
+
+// ErrLockedOut возвращается, когда вход временно заблокирован
+var ErrLockedOut = errors.New("too many failed login attempts")
+
+// Scope определяет, по какому ключу считаются неудачные попытки входа
+type Scope string
+
+const (
+	ScopeUsername Scope = "username"
+	ScopeIP       Scope = "ip"
+)
+
+// LockoutConfig - пороги блокировки входа
+type LockoutConfig struct {
+	MaxUsernameFailures int           // Неудачных попыток на username до блокировки
+	MaxIPFailures       int           // Неудачных попыток с одного IP до блокировки
+	Window              time.Duration // Окно, в котором считаются неудачные попытки
+	LockoutDuration     time.Duration // Длительность блокировки
+}
+
+// LockoutStorage - хранилище попыток входа и блокировок
+type LockoutStorage interface {
+	RecordLoginAttempt(username, ip string, success bool, at time.Time) error
+	CountFailedLoginAttempts(scope string, key string, since time.Time) (int, error)
+	ClearFailedLoginAttempts(scope string, key string) error
+	SetLoginLockout(scope string, key string, until time.Time) error
+	GetLoginLockout(scope string, key string) (time.Time, error)
+	DeleteLoginLockout(scope string, key string) error
+	GetActiveLoginLockouts(now time.Time) ([]models.LoginLockout, error)
+}
+
+// Guard отслеживает неудачные попытки входа по username и IP и временно блокирует вход
+type Guard struct {
+	cfg     LockoutConfig
+	storage LockoutStorage
+	clock   clock.Clock
+}
+
+// NewGuard создает новый Guard
+func NewGuard(cfg LockoutConfig, storage LockoutStorage) *Guard {
+	return &Guard{
+		cfg:     cfg,
+		storage: storage,
+		clock:   clock.Real,
+	}
+}
+
+// SetClock подменяет источник времени
+func (g *Guard) SetClock(clk clock.Clock) {
+	g.clock = clk
+}
+
+// Check возвращает ErrLockedOut и оставшееся время блокировки, если вход запрещен
+func (g *Guard) Check(username, ip string) (time.Duration, error) {
+	now := g.clock.Now().UTC()
+
+	var retryAfter time.Duration
+	for scope, key := range g.keys(username, ip) {
+		until, err := g.storage.GetLoginLockout(string(scope), key)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get lockout: %w", err)
+		}
+
+		if until.After(now) && until.Sub(now) > retryAfter {
+			retryAfter = until.Sub(now)
+		}
+	}
+
+	if retryAfter > 0 {
+		return retryAfter, ErrLockedOut
+	}
+
+	return 0, nil
+}
+
+// RegisterFailure записывает неудачную попытку и возвращает блокировки, созданные ею
+func (g *Guard) RegisterFailure(username, ip string) ([]models.LoginLockout, error) {
+	now := g.clock.Now().UTC()
+
+	if err := g.storage.RecordLoginAttempt(username, ip, false, now); err != nil {
+		return nil, fmt.Errorf("failed to record login attempt: %w", err)
+	}
+
+	limits := map[Scope]int{
+		ScopeUsername: g.cfg.MaxUsernameFailures,
+		ScopeIP:       g.cfg.MaxIPFailures,
+	}
+
+	var created []models.LoginLockout
+	for scope, key := range g.keys(username, ip) {
+		limit := limits[scope]
+		if limit <= 0 {
+			continue
+		}
+
+		failures, err := g.storage.CountFailedLoginAttempts(string(scope), key, now.Add(-g.cfg.Window))
+		if err != nil {
+			return created, fmt.Errorf("failed to count login attempts: %w", err)
+		}
+
+		if failures < limit {
+			continue
+		}
+
+		until := now.Add(g.cfg.LockoutDuration)
+		if err := g.storage.SetLoginLockout(string(scope), key, until); err != nil {
+			return created, fmt.Errorf("failed to set lockout: %w", err)
+		}
+
+		// Счетчик начинается заново после блокировки
+		if err := g.storage.ClearFailedLoginAttempts(string(scope), key); err != nil {
+			return created, fmt.Errorf("failed to clear login attempts: %w", err)
+		}
+
+		created = append(created, models.LoginLockout{Scope: string(scope), Key: key, LockedUntil: until})
+	}
+
+	return created, nil
+}
+
+// RegisterSuccess записывает успешный вход и сбрасывает счетчик по username
+func (g *Guard) RegisterSuccess(username, ip string) error {
+	if err := g.storage.RecordLoginAttempt(username, ip, true, g.clock.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+
+	return g.storage.ClearFailedLoginAttempts(string(ScopeUsername), username)
+}
+
+// Unlock снимает блокировку и сбрасывает счетчик неудачных попыток
+func (g *Guard) Unlock(scope Scope, key string) error {
+	if err := g.storage.DeleteLoginLockout(string(scope), key); err != nil {
+		return fmt.Errorf("failed to delete lockout: %w", err)
+	}
+
+	return g.storage.ClearFailedLoginAttempts(string(scope), key)
+}
+
+// ActiveLockouts возвращает все действующие блокировки
+func (g *Guard) ActiveLockouts() ([]models.LoginLockout, error) {
+	return g.storage.GetActiveLoginLockouts(g.clock.Now().UTC())
+}
+
+func (g *Guard) keys(username, ip string) map[Scope]string {
+	keys := make(map[Scope]string, 2)
+	if username != "" {
+		keys[ScopeUsername] = username
+	}
+	if ip != "" {
+		keys[ScopeIP] = ip
+	}
+
+	return keys
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tg_mexc/internal/clock"
+)
+
+var (
+	ErrInvalidTelegramHash = errors.New("invalid telegram login hash")
+	ErrTelegramAuthExpired = errors.New("telegram login data expired")
+)
+
+// TelegramLoginData - данные, которые Telegram Login Widget передает после авторизации
+type TelegramLoginData struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Username  string `json:"username"`
+	PhotoURL  string `json:"photo_url"`
+	AuthDate  int64  `json:"auth_date"`
+	Hash      string `json:"hash"`
+}
+
+// TelegramVerifier проверяет подпись Telegram Login Widget.
+// См. https://core.telegram.org/widgets/login#checking-authorization
+type TelegramVerifier struct {
+	secretKey []byte
+	maxAge    time.Duration
+	clock     clock.Clock
+}
+
+// NewTelegramVerifier создает верификатор; maxAge ограничивает возраст auth_date
+func NewTelegramVerifier(botToken string, maxAge time.Duration) *TelegramVerifier {
+	secret := sha256.Sum256([]byte(botToken))
+
+	return &TelegramVerifier{
+		secretKey: secret[:],
+		maxAge:    maxAge,
+		clock:     clock.Real,
+	}
+}
+
+// SetClock подменяет источник времени
+func (v *TelegramVerifier) SetClock(clk clock.Clock) {
+	v.clock = clk
+}
+
+// Verify проверяет hash и свежесть данных виджета
+func (v *TelegramVerifier) Verify(data TelegramLoginData) error {
+	mac := hmac.New(sha256.New, v.secretKey)
+	mac.Write([]byte(data.checkString()))
+
+	expected := mac.Sum(nil)
+	actual, err := hex.DecodeString(data.Hash)
+	if err != nil || !hmac.Equal(expected, actual) {
+		return ErrInvalidTelegramHash
+	}
+
+	if v.maxAge > 0 && v.clock.Since(time.Unix(data.AuthDate, 0)) > v.maxAge {
+		return ErrTelegramAuthExpired
+	}
+
+	return nil
+}
+
+// checkString собирает data-check-string: непустые поля кроме hash, "key=value", по алфавиту, через \n
+func (d TelegramLoginData) checkString() string {
+	fields := map[string]string{
+		"id":         strconv.FormatInt(d.ID, 10),
+		"first_name": d.FirstName,
+		"last_name":  d.LastName,
+		"username":   d.Username,
+		"photo_url":  d.PhotoURL,
+		"auth_date":  strconv.FormatInt(d.AuthDate, 10),
+	}
+
+	pairs := make([]string, 0, len(fields))
+	for key, value := range fields {
+		if value == "" {
+			continue
+		}
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "\n")
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"tg_mexc/internal/mexc/copytrading/benchmark"
+)
+
+// HandleBenchmark прогоняет синтетические события мастера через конвейер копирования без отправки в сеть
+// и возвращает задержки по этапам (?events=100&slaves=5)
+func (h *Handler) HandleBenchmark(w http.ResponseWriter, r *http.Request) {
+	opts := benchmark.Options{
+		Events: parseIntParam(r, "events", benchmark.DefaultEvents, benchmark.MaxEvents),
+		Slaves: parseIntParam(r, "slaves", benchmark.DefaultSlaves, benchmark.MaxSlaves),
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), historySyncTimeout)
+	defer cancel()
+
+	// Логи конвейера (по строке на каждый ордер) не пишутся
+	report, err := benchmark.Run(ctx, opts, slog.New(slog.DiscardHandler))
+	if err != nil {
+		h.logger.Error("Benchmark failed", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Benchmark failed: "+err.Error())
+		return
+	}
+
+	h.respondSuccess(w, "", report)
+}
+
+// parseIntParam читает положительный query параметр не больше maxValue (иначе def)
+func parseIntParam(r *http.Request, name string, def, maxValue int) int {
+	if v := r.URL.Query().Get(name); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed > 0 && parsed <= maxValue {
+			return parsed
+		}
+	}
+
+	return def
+}
@@ -0,0 +1,40 @@
+package copytrading
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"tg_mexc/internal/cluster"
+)
+
+// ErrSessionElsewhere - сессия пользователя работает на другом инстансе web-app
+var ErrSessionElsewhere = errors.New("copy trading session is running on another instance")
+
+// lockSession закрепляет сессию пользователя в режиме mode за текущим инстансом
+func lockSession(ctx context.Context, registry cluster.Registry, userID int, mode Mode) error {
+	err := cluster.LockSession(ctx, registry, userID, string(mode), time.Now())
+	if errors.Is(err, cluster.ErrLockHeld) {
+		return ErrSessionElsewhere
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock session: %w", err)
+	}
+
+	return nil
+}
+
+func unlockSession(ctx context.Context, registry cluster.Registry, userID int) error {
+	return cluster.UnlockSession(ctx, registry, userID)
+}
+
+// sessionOwner возвращает инстанс и режим сессии пользователя (false - сессии нет ни на одном инстансе)
+func sessionOwner(ctx context.Context, registry cluster.Registry, userID int) (string, Mode, bool) {
+	owner, ok, err := cluster.LookupSession(ctx, registry, userID)
+	if err != nil || !ok {
+		return "", ModeOff, false
+	}
+
+	return owner.Instance, Mode(owner.Mode), true
+}
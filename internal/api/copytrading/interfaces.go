@@ -1,6 +1,12 @@
 package copytrading
 
-import "context"
+import (
+	"context"
+	"time"
+
+	corecopytrade "tg_mexc/internal/mexc/copytrading"
+	"tg_mexc/internal/mexc/websocket"
+)
 
 // Mode - режим copy trading
 type Mode string
@@ -13,7 +19,11 @@ const (
 
 // ModeOptions - опции для режима
 type ModeOptions struct {
-	IgnoreFees bool `json:"ignore_fees"` // только для websocket
+	IgnoreFees      bool          `json:"ignore_fees"`       // только для websocket
+	StopMatchWindow time.Duration `json:"stop_match_window"` // только для websocket, 0 - по умолчанию (COPY_STOP_MATCH_WINDOW)
+	ExtraMasterIDs  []int         `json:"extra_master_ids"`  // только для websocket: дополнительные master аккаунты
+
+	CopyDelay corecopytrade.CopyDelay `json:"copy_delay"` // только для websocket: задержка копирования перед отправкой slave
 }
 
 // WebSocketService управляет WebSocket режимом copy trading
@@ -29,8 +39,8 @@ type MirrorService interface {
 	Stop(ctx context.Context, userID int) error
 	IsActive(userID int) bool
 	ProcessRequest(ctx context.Context, token string, path string, body []byte) error
-	ValidateToken(token string) (userID int, username string, ok bool)
-	GetToken(userID int, username string) string
+	ValidateToken(ctx context.Context, token string) (userID int, username string, ok bool)
+	GetToken(ctx context.Context, userID int, username string) string
 }
 
 // CopyTradingService - главный сервис для управления copy trading
@@ -41,20 +51,32 @@ type CopyTradingService interface {
 	GetStatus(ctx context.Context, userID int, username string) Status
 	// StopAll останавливает все сессии (для graceful shutdown)
 	StopAll()
+	// Run обрабатывает сообщения других инстансов (остановка сессии, пересланные mirror запросы) до отмены ctx
+	Run(ctx context.Context)
 	// GetMirrorScript возвращает JS скрипт для mirror режима
-	GetMirrorScript(userID int, username string) string
+	GetMirrorScript(ctx context.Context, userID int, username string) string
 	// ValidateMirrorToken валидирует токен mirror режима
-	ValidateMirrorToken(token string) (userID int, username string, ok bool)
+	ValidateMirrorToken(ctx context.Context, token string) (userID int, username string, ok bool)
 	// ProcessMirrorRequest обрабатывает запрос от mirror
 	ProcessMirrorRequest(ctx context.Context, token string, path string, body []byte) error
 }
 
+// FeedHealth - задержки WebSocket соединения master аккаунта
+type FeedHealth struct {
+	Master string `json:"master"`
+	websocket.Health
+}
+
 // Status - статус copy trading
 type Status struct {
-	Mode             Mode   `json:"mode"`
-	MasterName       string `json:"master_name,omitempty"`
-	ActiveSlaveCount int    `json:"active_slave_count"`
-	DryRun           bool   `json:"dry_run"`
+	Mode             Mode     `json:"mode"`
+	MasterName       string   `json:"master_name,omitempty"`
+	Masters          []string `json:"masters,omitempty"` // Подключенные master аккаунты websocket режима
+	ActiveSlaveCount int      `json:"active_slave_count"`
+	DryRun           bool     `json:"dry_run"`
+	// WebSocket-specific: маржа и equity подключенных мастеров по событиям счета
+	MasterMargins []corecopytrade.MarginUsage `json:"master_margins,omitempty"`
+	Feeds         []FeedHealth                `json:"feeds,omitempty"` // Задержки WebSocket соединений мастеров
 	// Mirror-specific
 	MirrorToken  string `json:"mirror_token,omitempty"`
 	MirrorURL    string `json:"mirror_url,omitempty"`
@@ -2,51 +2,45 @@ package copytrading
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"tg_mexc/internal/cluster"
+	"tg_mexc/internal/mexc"
 	copytrading "tg_mexc/internal/mexc/copytrading"
 )
 
-// mirrorToken - токен для идентификации пользователя
-type mirrorToken struct {
-	Token     string
-	UserID    int
-	Username  string
-	CreatedAt time.Time
-}
-
 // mirrorService реализует MirrorService
 type mirrorService struct {
-	manager *copytrading.Manager
-	storage AccountStorage
-	logger  *slog.Logger
-	apiURL  string
-	tokens  map[string]*mirrorToken
-	active  map[int]bool
-	mu      sync.RWMutex
+	manager  *copytrading.Manager
+	storage  AccountStorage
+	registry cluster.Registry
+	logger   *slog.Logger
+	apiURL   string
+	active   map[int]bool
+	mu       sync.RWMutex
 }
 
 // NewMirrorService создаёт новый Mirror сервис
 func NewMirrorService(
 	manager *copytrading.Manager,
 	storage AccountStorage,
+	registry cluster.Registry,
 	apiURL string,
 	logger *slog.Logger,
 ) MirrorService {
 	return &mirrorService{
-		manager: manager,
-		storage: storage,
-		apiURL:  apiURL,
-		logger:  logger,
-		tokens:  make(map[string]*mirrorToken),
-		active:  make(map[int]bool),
+		manager:  manager,
+		storage:  storage,
+		registry: registry,
+		apiURL:   apiURL,
+		logger:   logger,
+		active:   make(map[int]bool),
 	}
 }
 
@@ -59,13 +53,24 @@ func (s *mirrorService) Start(ctx context.Context, userID int, username string)
 		return "", fmt.Errorf("master account not set: %w", err)
 	}
 
+	// Захватываем сессию пользователя для этого инстанса
+	if err := lockSession(ctx, s.registry, userID, ModeMirror); err != nil {
+		return "", err
+	}
+
 	// Создаём сессию в manager
 	if _, err := s.manager.CreateOrGetActiveSession(userID, "mirror"); err != nil {
+		_ = unlockSession(ctx, s.registry, userID)
 		return "", fmt.Errorf("failed to create session: %w", err)
 	}
 
 	// Генерируем или получаем токен
-	token := s.getOrCreateTokenLocked(userID, username)
+	token, err := s.registry.MirrorToken(ctx, userID, username)
+	if err != nil {
+		_ = s.manager.StopSession(userID, "mirror")
+		_ = unlockSession(ctx, s.registry, userID)
+		return "", fmt.Errorf("failed to get mirror token: %w", err)
+	}
 	s.active[userID] = true
 
 	s.logger.Info("Mirror copy trading started",
@@ -85,6 +90,10 @@ func (s *mirrorService) Stop(ctx context.Context, userID int) error {
 
 	delete(s.active, userID)
 
+	if err := unlockSession(ctx, s.registry, userID); err != nil {
+		s.logger.Warn("Failed to release session lock", slog.Int("user_id", userID), slog.Any("error", err))
+	}
+
 	s.logger.Info("Mirror copy trading stopped", slog.Int("user_id", userID))
 
 	return nil
@@ -96,69 +105,67 @@ func (s *mirrorService) IsActive(userID int) bool {
 	return s.active[userID]
 }
 
-func (s *mirrorService) GetToken(userID int, username string) string {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.getOrCreateTokenLocked(userID, username)
+func (s *mirrorService) GetToken(ctx context.Context, userID int, username string) string {
+	token, err := s.registry.MirrorToken(ctx, userID, username)
+	if err != nil {
+		s.logger.Error("Failed to get mirror token", slog.Int("user_id", userID), slog.Any("error", err))
+	}
+
+	return token
 }
 
 func (s *mirrorService) ProcessRequest(ctx context.Context, token string, path string, body []byte) error {
-	userID, _, ok := s.ValidateToken(token)
+	userID, _, ok := s.ValidateToken(ctx, token)
 	if !ok {
 		return fmt.Errorf("invalid token")
 	}
 
+	// Момент перехвата запроса браузера - точка отсчета latency для mirror режима
+	eventAt := time.Now()
+
 	session, err := s.manager.GetSession(userID, "mirror")
 	if err != nil {
+		// Сессия может работать на другом инстансе - пересылаем запрос владельцу
+		instance, mode, held := sessionOwner(ctx, s.registry, userID)
+		if held && mode == ModeMirror && instance != s.registry.Instance() {
+			return s.registry.Publish(ctx, instance, cluster.Message{
+				Kind:    cluster.KindMirrorRequest,
+				UserID:  userID,
+				Path:    path,
+				Body:    body,
+				EventAt: eventAt,
+			})
+		}
+
 		s.logger.Debug("Mirror request ignored - not active", slog.Int("user_id", userID))
 		return nil
 	}
 
-	return s.processRequest(ctx, session, path, body)
+	return s.processRequest(copytrading.WithEventTime(ctx, eventAt), session, path, body)
 }
 
-func (s *mirrorService) ValidateToken(token string) (userID int, username string, ok bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	mt, exists := s.tokens[token]
-	if !exists {
-		return 0, "", false
+// processForwarded обрабатывает mirror запрос, пересланный другим инстансом
+func (s *mirrorService) processForwarded(ctx context.Context, msg cluster.Message) error {
+	session, err := s.manager.GetSession(msg.UserID, "mirror")
+	if err != nil {
+		s.logger.Debug("Forwarded mirror request ignored - not active", slog.Int("user_id", msg.UserID))
+		return nil
 	}
 
-	return mt.UserID, mt.Username, true
+	return s.processRequest(copytrading.WithEventTime(ctx, msg.EventAt), session, msg.Path, msg.Body)
 }
 
-// getOrCreateTokenLocked - вызывать только с удержанным lock
-func (s *mirrorService) getOrCreateTokenLocked(userID int, username string) string {
-	// Ищем существующий токен
-	for _, mt := range s.tokens {
-		if mt.UserID == userID {
-			return mt.Token
-		}
-	}
-
-	// Удаляем старый токен если есть
-	for token, mt := range s.tokens {
-		if mt.UserID == userID {
-			delete(s.tokens, token)
-			break
-		}
+func (s *mirrorService) ValidateToken(ctx context.Context, token string) (userID int, username string, ok bool) {
+	mt, ok, err := s.registry.LookupMirrorToken(ctx, token)
+	if err != nil {
+		s.logger.Error("Failed to validate mirror token", slog.Any("error", err))
+		return 0, "", false
 	}
-
-	// Генерируем новый
-	bytes := make([]byte, 16)
-	rand.Read(bytes)
-	token := hex.EncodeToString(bytes)
-
-	s.tokens[token] = &mirrorToken{
-		Token:     token,
-		UserID:    userID,
-		Username:  username,
-		CreatedAt: time.Now(),
+	if !ok {
+		return 0, "", false
 	}
 
-	return token
+	return mt.UserID, mt.Username, true
 }
 
 func (s *mirrorService) getAPIURL() string {
@@ -171,6 +178,7 @@ func (s *mirrorService) stopAll() {
 
 	for userID := range s.active {
 		_ = s.manager.StopSession(userID, "mirror")
+		_ = unlockSession(context.Background(), s.registry, userID)
 		s.logger.Info("Mirror stopped (shutdown)", slog.Int("user_id", userID))
 	}
 
@@ -190,6 +198,16 @@ func (s *mirrorService) processRequest(ctx context.Context, session *copytrading
 		return s.handleChangePlanPrice(ctx, session, body)
 	case strings.HasSuffix(path, "/change_leverage"):
 		return s.handleChangeLeverage(ctx, session, body)
+	case strings.HasSuffix(path, "/order/cancel"):
+		return s.handleOrderCancel(ctx, session, body)
+	case strings.HasSuffix(path, "/order/cancel_all"):
+		return s.handleOrderCancelAll(ctx, session, body)
+	case strings.HasSuffix(path, "/trackorder/place"):
+		return s.handleTrailingStopPlace(ctx, session, body)
+	case strings.HasSuffix(path, "/trackorder/change_order"):
+		return s.handleTrailingStopChange(ctx, session, body)
+	case strings.HasSuffix(path, "/trackorder/cancel"):
+		return s.handleTrailingStopCancel(ctx, session, body)
 	default:
 		return fmt.Errorf("unknown mirror path: %s", path)
 	}
@@ -276,15 +294,96 @@ func (s *mirrorService) handleChangeLeverage(ctx context.Context, session *copyt
 	return nil
 }
 
+func (s *mirrorService) handleOrderCancel(ctx context.Context, session *copytrading.Session, body []byte) error {
+	orderIDs, err := s.parseOrderCancel(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse order cancel: %w", err)
+	}
+
+	for _, orderID := range orderIDs {
+		result, err := session.CancelOrders(ctx, copytrading.CancelOrderRequest{MasterOrderID: orderID})
+		if err != nil {
+			return fmt.Errorf("failed to cancel order: %w", err)
+		}
+		s.logResult("cancel order", result)
+	}
+
+	return nil
+}
+
+func (s *mirrorService) handleOrderCancelAll(ctx context.Context, session *copytrading.Session, body []byte) error {
+	req, err := s.parseOrderCancelAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse order cancel all: %w", err)
+	}
+
+	result, err := session.CancelOrders(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to cancel orders: %w", err)
+	}
+	s.logResult("cancel all orders", result)
+
+	return nil
+}
+
+func (s *mirrorService) handleTrailingStopPlace(ctx context.Context, session *copytrading.Session, body []byte) error {
+	req, err := s.parseTrailingStopPlace(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse trailing stop: %w", err)
+	}
+
+	result, err := session.PlaceTrailingStop(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to place trailing stop: %w", err)
+	}
+	s.logResult("place trailing stop", result)
+
+	return nil
+}
+
+func (s *mirrorService) handleTrailingStopChange(ctx context.Context, session *copytrading.Session, body []byte) error {
+	req, err := s.parseTrailingStopChange(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse trailing stop change: %w", err)
+	}
+
+	result, err := session.ChangeTrailingStop(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to change trailing stop: %w", err)
+	}
+	s.logResult("change trailing stop", result)
+
+	return nil
+}
+
+func (s *mirrorService) handleTrailingStopCancel(ctx context.Context, session *copytrading.Session, body []byte) error {
+	symbols, err := s.parseTrailingStopCancel(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse trailing stop cancel: %w", err)
+	}
+
+	// Отменяются все trailing stop символа: ID мастера не совпадают с ID slave
+	for _, symbol := range symbols {
+		if _, err := session.CancelTrailingStop(ctx, copytrading.CancelTrailingStopRequest{Symbol: symbol}); err != nil {
+			return fmt.Errorf("failed to cancel trailing stop: %w", err)
+		}
+	}
+
+	return nil
+}
+
 // === Parsers ===
 
 type orderCreateRequest struct {
-	Symbol        string `json:"symbol"`
-	Side          int    `json:"side"`
-	Vol           int    `json:"vol"`
-	Leverage      int    `json:"leverage"`
-	StopLossPrice string `json:"stopLossPrice,omitempty"`
-	PositionID    int64  `json:"positionId,omitempty"`
+	Symbol        string      `json:"symbol"`
+	Side          int         `json:"side"`
+	Type          json.Number `json:"type"`  // Строка или число: "1" limit, "5" market
+	Price         json.Number `json:"price"` // Цена limit ордера (строка или число)
+	Vol           int         `json:"vol"`
+	Leverage      int         `json:"leverage"`
+	OpenType      int         `json:"openType"` // 1 isolated, 2 cross
+	StopLossPrice string      `json:"stopLossPrice,omitempty"`
+	PositionID    int64       `json:"positionId,omitempty"`
 }
 
 func (s *mirrorService) parseOrderCreate(body []byte) (*copytrading.OpenPositionRequest, *copytrading.ClosePositionRequest, error) {
@@ -299,12 +398,19 @@ func (s *mirrorService) parseOrderCreate(body []byte) (*copytrading.OpenPosition
 		if raw.StopLossPrice != "" {
 			fmt.Sscanf(raw.StopLossPrice, "%f", &stopLoss)
 		}
+		// Limit и post only ордера копируются limit ордером по той же цене
+		var limitPrice float64
+		if orderType, _ := raw.Type.Int64(); orderType == mexc.OrderTypeLimit || orderType == mexc.OrderTypePostOnly {
+			limitPrice, _ = raw.Price.Float64()
+		}
 		return &copytrading.OpenPositionRequest{
 			Symbol:        raw.Symbol,
 			Side:          raw.Side,
 			Volume:        float64(raw.Vol),
 			Leverage:      raw.Leverage,
+			OpenType:      raw.OpenType,
 			StopLossPrice: stopLoss,
+			LimitPrice:    limitPrice,
 		}, nil, nil
 	case 2, 4:
 		return nil, &copytrading.ClosePositionRequest{
@@ -418,3 +524,123 @@ func (s *mirrorService) logResult(operation string, result copytrading.Execution
 		}
 	}
 }
+
+// parseOrderCancel возвращает ID отменяемых ордеров мастера (в теле - строки или числа)
+func (s *mirrorService) parseOrderCancel(body []byte) ([]string, error) {
+	var raw []json.Number
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(raw))
+	for _, id := range raw {
+		ids = append(ids, id.String())
+	}
+
+	return ids, nil
+}
+
+type orderCancelAllRequest struct {
+	Symbol string `json:"symbol"` // Пустой - все символы
+}
+
+func (s *mirrorService) parseOrderCancelAll(body []byte) (copytrading.CancelOrderRequest, error) {
+	var raw orderCancelAllRequest
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return copytrading.CancelOrderRequest{}, err
+		}
+	}
+
+	return copytrading.CancelOrderRequest{Symbol: raw.Symbol}, nil
+}
+
+type trailingStopPlaceRequest struct {
+	Symbol      string      `json:"symbol"`
+	Side        int         `json:"side"`
+	Vol         json.Number `json:"vol"`
+	Leverage    int         `json:"leverage"`
+	OpenType    int         `json:"openType"`
+	Trend       int         `json:"trend"`
+	ActivePrice json.Number `json:"activePrice"`
+	BackType    int         `json:"backType"`
+	BackValue   json.Number `json:"backValue"`
+}
+
+func (s *mirrorService) parseTrailingStopPlace(body []byte) (copytrading.PlaceTrailingStopRequest, error) {
+	var raw trailingStopPlaceRequest
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return copytrading.PlaceTrailingStopRequest{}, err
+	}
+
+	vol, _ := raw.Vol.Float64()
+	activePrice, _ := raw.ActivePrice.Float64()
+	backValue, _ := raw.BackValue.Float64()
+
+	return copytrading.PlaceTrailingStopRequest{
+		Symbol:      raw.Symbol,
+		Side:        raw.Side,
+		Volume:      vol,
+		Leverage:    raw.Leverage,
+		OpenType:    raw.OpenType,
+		Trend:       raw.Trend,
+		ActivePrice: activePrice,
+		BackType:    raw.BackType,
+		BackValue:   backValue,
+	}, nil
+}
+
+type trailingStopChangeRequest struct {
+	TrackOrderID json.Number `json:"trackOrderId"`
+	Vol          json.Number `json:"vol"`
+	Trend        int         `json:"trend"`
+	ActivePrice  json.Number `json:"activePrice"`
+	BackType     int         `json:"backType"`
+	BackValue    json.Number `json:"backValue"`
+}
+
+func (s *mirrorService) parseTrailingStopChange(body []byte) (copytrading.ChangeTrailingStopRequest, error) {
+	var raw trailingStopChangeRequest
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return copytrading.ChangeTrailingStopRequest{}, err
+	}
+
+	trackOrderID, err := raw.TrackOrderID.Int64()
+	if err != nil {
+		return copytrading.ChangeTrailingStopRequest{}, fmt.Errorf("invalid trackOrderId: %w", err)
+	}
+
+	vol, _ := raw.Vol.Float64()
+	activePrice, _ := raw.ActivePrice.Float64()
+	backValue, _ := raw.BackValue.Float64()
+
+	return copytrading.ChangeTrailingStopRequest{
+		TrackOrderID: trackOrderID,
+		Volume:       vol,
+		Trend:        raw.Trend,
+		ActivePrice:  activePrice,
+		BackType:     raw.BackType,
+		BackValue:    backValue,
+	}, nil
+}
+
+type trailingStopCancelRequest struct {
+	Symbol string `json:"symbol"`
+}
+
+// parseTrailingStopCancel возвращает уникальные символы отменяемых trailing stop
+func (s *mirrorService) parseTrailingStopCancel(body []byte) ([]string, error) {
+	var raw []trailingStopCancelRequest
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+
+	symbols := make([]string, 0, len(raw))
+	for _, r := range raw {
+		if r.Symbol != "" && !slices.Contains(symbols, r.Symbol) {
+			symbols = append(symbols, r.Symbol)
+		}
+	}
+
+	return symbols, nil
+}
@@ -4,47 +4,54 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
+	"time"
 
+	"tg_mexc/internal/cluster"
+	"tg_mexc/internal/mailer"
 	corecopytrade "tg_mexc/internal/mexc/copytrading"
-	"tg_mexc/internal/mexc/copytrading/websocket"
 )
 
+// remoteStopTimeout - сколько ждать остановки сессии на другом инстансе
+const remoteStopTimeout = 10 * time.Second
+
 // service реализует CopyTradingService
 type service struct {
 	manager   *corecopytrade.Manager
 	storage   AccountStorage
+	registry  cluster.Registry
 	wsService *webSocketService
 	mirrorSvc *mirrorService
 	apiURL    string
 	logger    *slog.Logger
 }
 
-// NewService создаёт главный сервис copy trading
+// NewService создаёт главный сервис copy trading.
+// registry - состояние, общее для инстансов web-app (cluster.Local для одного инстанса),
+// alerter - критические уведомления об автоматической остановке сессий (nil - без уведомлений)
 func NewService(
 	manager *corecopytrade.Manager,
 	storage AccountStorage,
+	registry cluster.Registry,
+	alerter *mailer.Alerter,
 	apiURL string,
 	logger *slog.Logger,
 ) CopyTradingService {
-	wsSvc := &webSocketService{
-		manager:     manager,
-		storage:     storage,
-		logger:      logger,
-		connections: make(map[int]*wscopytrading.Service),
-	}
+	wsSvc := newWebSocketService(manager, storage, registry, alerter, logger)
 
 	mirrorSvc := &mirrorService{
-		manager: manager,
-		storage: storage,
-		apiURL:  apiURL,
-		logger:  logger,
-		tokens:  make(map[string]*mirrorToken),
-		active:  make(map[int]bool),
+		manager:  manager,
+		storage:  storage,
+		registry: registry,
+		apiURL:   apiURL,
+		logger:   logger,
+		active:   make(map[int]bool),
 	}
 
 	return &service{
 		manager:   manager,
 		storage:   storage,
+		registry:  registry,
 		wsService: wsSvc,
 		mirrorSvc: mirrorSvc,
 		apiURL:    apiURL,
@@ -54,7 +61,7 @@ func NewService(
 
 func (s *service) SetMode(ctx context.Context, userID int, username string, mode Mode, opts ModeOptions) error {
 	// Определяем текущий режим
-	currentMode := s.getCurrentMode(userID)
+	currentMode, instance := s.getCurrentMode(ctx, userID)
 
 	// Если режим тот же - ничего не делаем
 	if currentMode == mode {
@@ -65,7 +72,11 @@ func (s *service) SetMode(ctx context.Context, userID int, username string, mode
 	}
 
 	// Останавливаем текущий режим
-	if err := s.stopCurrentMode(ctx, userID, currentMode); err != nil {
+	if instance != s.registry.Instance() {
+		if err := s.stopRemote(ctx, userID, instance); err != nil {
+			return fmt.Errorf("failed to stop current mode: %w", err)
+		}
+	} else if err := s.stopCurrentMode(ctx, userID, currentMode); err != nil {
 		return fmt.Errorf("failed to stop current mode: %w", err)
 	}
 
@@ -84,8 +95,9 @@ func (s *service) SetMode(ctx context.Context, userID int, username string, mode
 }
 
 func (s *service) GetStatus(ctx context.Context, userID int, username string) Status {
+	mode, _ := s.getCurrentMode(ctx, userID)
 	status := Status{
-		Mode:   s.getCurrentMode(userID),
+		Mode:   mode,
 		DryRun: s.manager.IsDryRun(),
 	}
 
@@ -95,49 +107,180 @@ func (s *service) GetStatus(ctx context.Context, userID int, username string) St
 		status.MasterName = master.Name
 	}
 
-	slaves, err := s.storage.GetSlaveAccounts(userID, false)
+	slaves, err := s.manager.Slaves(userID)
 	if err == nil {
 		status.ActiveSlaveCount = len(slaves)
 	}
 
+	if status.Mode == ModeWebSocket {
+		status.Masters = s.masterNames(userID)
+		status.MasterMargins = s.masterMargins(userID)
+		status.Feeds = s.feeds(userID)
+	}
+
 	// Mirror-specific данные
 	if status.Mode == ModeMirror {
-		status.MirrorToken = s.mirrorSvc.GetToken(userID, username)
+		status.MirrorToken = s.mirrorSvc.GetToken(ctx, userID, username)
 		status.MirrorURL = s.apiURL
-		status.MirrorScript = s.GetMirrorScript(userID, username)
+		status.MirrorScript = generateMirrorScript(s.apiURL, status.MirrorToken)
 	}
 
 	return status
 }
 
+// masterMargins возвращает состояние счетов master аккаунтов, подключенных в websocket режиме
+func (s *service) masterMargins(userID int) []corecopytrade.MarginUsage {
+	var margins []corecopytrade.MarginUsage
+	for _, id := range s.wsService.Masters(userID) {
+		if usage, ok := s.manager.MasterMargin(id); ok {
+			margins = append(margins, usage)
+		}
+	}
+
+	return margins
+}
+
+// feeds возвращает задержки WebSocket соединений master аккаунтов, подключенных в websocket режиме
+func (s *service) feeds(userID int) []FeedHealth {
+	health := s.wsService.Health(userID)
+	if len(health) == 0 {
+		return nil
+	}
+
+	accounts, err := s.storage.GetAccounts(userID)
+	if err != nil {
+		return nil
+	}
+
+	var feeds []FeedHealth
+	for _, acc := range accounts {
+		if h, ok := health[acc.ID]; ok {
+			feeds = append(feeds, FeedHealth{Master: acc.Name, Health: h})
+		}
+	}
+
+	return feeds
+}
+
+// masterNames возвращает имена master аккаунтов, подключенных в websocket режиме
+func (s *service) masterNames(userID int) []string {
+	ids := s.wsService.Masters(userID)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	accounts, err := s.storage.GetAccounts(userID)
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, acc := range accounts {
+		if slices.Contains(ids, acc.ID) {
+			names = append(names, acc.Name)
+		}
+	}
+
+	return names
+}
+
 func (s *service) StopAll() {
 	s.wsService.stopAll()
 	s.mirrorSvc.stopAll()
 	s.logger.Info("All copy trading sessions stopped")
 }
 
-func (s *service) GetMirrorScript(userID int, username string) string {
-	token := s.mirrorSvc.GetToken(userID, username)
+func (s *service) Run(ctx context.Context) {
+	messages, err := s.registry.Subscribe(ctx)
+	if err != nil {
+		s.logger.Error("Failed to subscribe to cluster messages", slog.Any("error", err))
+		return
+	}
+
+	for {
+		var msg cluster.Message
+		var ok bool
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok = <-messages:
+			if !ok {
+				return
+			}
+		}
+
+		switch msg.Kind {
+		case cluster.KindStopSession:
+			mode, instance := s.getCurrentMode(ctx, msg.UserID)
+			if instance != s.registry.Instance() {
+				continue
+			}
+			if err := s.stopCurrentMode(ctx, msg.UserID, mode); err != nil {
+				s.logger.Error("Failed to stop session on request of another instance",
+					slog.Int("user_id", msg.UserID),
+					slog.Any("error", err))
+			}
+		case cluster.KindSessionLost:
+			// Блокировку держит другой инстанс или она истекла: локальная сессия не должна копировать сделки.
+			// getCurrentMode не подходит - владельцем в реестре уже может быть другой инстанс
+			s.logger.Error("Session lock lost, stopping local session", slog.Int("user_id", msg.UserID))
+			if s.wsService.IsActive(msg.UserID) {
+				if err := s.wsService.Stop(ctx, msg.UserID); err != nil {
+					s.logger.Error("Failed to stop session after lock loss",
+						slog.Int("user_id", msg.UserID),
+						slog.Any("error", err))
+				}
+			}
+			if s.mirrorSvc.IsActive(msg.UserID) {
+				if err := s.mirrorSvc.Stop(ctx, msg.UserID); err != nil {
+					s.logger.Error("Failed to stop session after lock loss",
+						slog.Int("user_id", msg.UserID),
+						slog.Any("error", err))
+				}
+			}
+		case cluster.KindMirrorRequest:
+			// Обрабатываем в горутине, как и mirror запросы, принятые этим инстансом напрямую
+			go func() {
+				if err := s.mirrorSvc.processForwarded(context.WithoutCancel(ctx), msg); err != nil {
+					s.logger.Error("Forwarded mirror request failed",
+						slog.String("path", msg.Path),
+						slog.Int("user_id", msg.UserID),
+						slog.Any("error", err))
+				}
+			}()
+		default:
+			s.logger.Warn("Unknown cluster message", slog.String("kind", msg.Kind))
+		}
+	}
+}
+
+func (s *service) GetMirrorScript(ctx context.Context, userID int, username string) string {
+	token := s.mirrorSvc.GetToken(ctx, userID, username)
 	return generateMirrorScript(s.apiURL, token)
 }
 
-func (s *service) ValidateMirrorToken(token string) (userID int, username string, ok bool) {
-	return s.mirrorSvc.ValidateToken(token)
+func (s *service) ValidateMirrorToken(ctx context.Context, token string) (userID int, username string, ok bool) {
+	return s.mirrorSvc.ValidateToken(ctx, token)
 }
 
 func (s *service) ProcessMirrorRequest(ctx context.Context, token string, path string, body []byte) error {
 	return s.mirrorSvc.ProcessRequest(ctx, token, path, body)
 }
 
-// getCurrentMode возвращает текущий активный режим
-func (s *service) getCurrentMode(userID int) Mode {
+// getCurrentMode возвращает текущий активный режим и инстанс, на котором работает сессия
+func (s *service) getCurrentMode(ctx context.Context, userID int) (Mode, string) {
 	if s.wsService.IsActive(userID) {
-		return ModeWebSocket
+		return ModeWebSocket, s.registry.Instance()
 	}
 	if s.mirrorSvc.IsActive(userID) {
-		return ModeMirror
+		return ModeMirror, s.registry.Instance()
 	}
-	return ModeOff
+
+	if instance, mode, ok := sessionOwner(ctx, s.registry, userID); ok {
+		return mode, instance
+	}
+
+	return ModeOff, s.registry.Instance()
 }
 
 // stopCurrentMode останавливает текущий режим
@@ -152,6 +295,32 @@ func (s *service) stopCurrentMode(ctx context.Context, userID int, mode Mode) er
 	}
 }
 
+// stopRemote просит инстанс-владельца остановить сессию и ждет освобождения блокировки
+func (s *service) stopRemote(ctx context.Context, userID int, instance string) error {
+	err := s.registry.Publish(ctx, instance, cluster.Message{
+		Kind:   cluster.KindStopSession,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to request stop from instance %s: %w", instance, err)
+	}
+
+	deadline := time.Now().Add(remoteStopTimeout)
+	for time.Now().Before(deadline) {
+		if _, _, held := sessionOwner(ctx, s.registry, userID); !held {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+
+	return fmt.Errorf("instance %s did not stop the session: %w", instance, ErrSessionElsewhere)
+}
+
 // generateMirrorScript генерирует JS скрипт для mirror режима
 func generateMirrorScript(mirrorURL, token string) string {
 	return `(function() {
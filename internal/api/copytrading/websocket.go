@@ -5,40 +5,65 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 
+	"tg_mexc/internal/cluster"
+	"tg_mexc/internal/mailer"
 	corecopytrade "tg_mexc/internal/mexc/copytrading"
 	"tg_mexc/internal/mexc/copytrading/websocket"
+	"tg_mexc/internal/mexc/websocket"
 	"tg_mexc/internal/models"
 )
 
 // AccountStorage - интерфейс для получения аккаунтов
 type AccountStorage interface {
 	GetMasterAccount(userID int) (models.Account, error)
-	GetSlaveAccounts(userID int, includeDisabled bool) ([]models.Account, error)
+	GetAccounts(userID int) ([]models.Account, error)
 }
 
-// webSocketService реализует WebSocketService
+// webSocketService реализует WebSocketService. Соединения мастеров держит wscopytrading.Manager:
+// master аккаунт пользователя и дополнительные мастера (ModeOptions.ExtraMasterIDs) в одной сессии
 type webSocketService struct {
-	manager     *corecopytrade.Manager
-	storage     AccountStorage
-	logger      *slog.Logger
-	connections map[int]*wscopytrading.Service
-	mu          sync.RWMutex
+	manager  *corecopytrade.Manager
+	storage  AccountStorage
+	registry cluster.Registry
+	alerter  *mailer.Alerter
+	logger   *slog.Logger
+	masters  *wscopytrading.Manager
+	mu       sync.Mutex
 }
 
 // NewWebSocketService создаёт новый WebSocket сервис
 func NewWebSocketService(
 	manager *corecopytrade.Manager,
 	storage AccountStorage,
+	registry cluster.Registry,
+	alerter *mailer.Alerter,
 	logger *slog.Logger,
 ) WebSocketService {
-	return &webSocketService{
-		manager:     manager,
-		storage:     storage,
-		logger:      logger,
-		connections: make(map[int]*wscopytrading.Service),
+	return newWebSocketService(manager, storage, registry, alerter, logger)
+}
+
+func newWebSocketService(
+	manager *corecopytrade.Manager,
+	storage AccountStorage,
+	registry cluster.Registry,
+	alerter *mailer.Alerter,
+	logger *slog.Logger,
+) *webSocketService {
+	s := &webSocketService{
+		manager:  manager,
+		storage:  storage,
+		registry: registry,
+		alerter:  alerter,
+		logger:   logger,
+		masters:  wscopytrading.NewManager(logger),
 	}
+	s.masters.SetCloseHandler(s.autoStop)
+	s.masters.SetStateHandler(s.connectionState)
+
+	return s
 }
 
 func (s *webSocketService) Start(ctx context.Context, userID int, opts ModeOptions) error {
@@ -46,45 +71,153 @@ func (s *webSocketService) Start(ctx context.Context, userID int, opts ModeOptio
 	defer s.mu.Unlock()
 
 	// Проверяем что есть master аккаунт
-	if _, err := s.storage.GetMasterAccount(userID); err != nil {
+	master, err := s.storage.GetMasterAccount(userID)
+	if err != nil {
 		return fmt.Errorf("master account not set: %w", err)
 	}
 
+	extraMasters, err := s.extraMasters(userID, master, opts.ExtraMasterIDs)
+	if err != nil {
+		return err
+	}
+
+	// Захватываем сессию пользователя для этого инстанса
+	if err := lockSession(ctx, s.registry, userID, ModeWebSocket); err != nil {
+		return err
+	}
+
 	// Создаём сессию в manager
 	session, err := s.manager.CreateOrGetActiveSession(userID, "websocket")
 	if err != nil {
+		_ = unlockSession(ctx, s.registry, userID)
 		return fmt.Errorf("failed to create session: %w", err)
 	}
+	session.SetCopyDelay(opts.CopyDelay)
 
-	// Создаём WebSocket сервис
-	wsService := wscopytrading.NewService(session, s.logger)
-
-	if err := wsService.Start(); err != nil {
-		_ = s.manager.StopSession(userID, "websocket")
-		return fmt.Errorf("failed to start websocket: %w", err)
+	// Подключаем мастеров: сначала master аккаунт пользователя, затем дополнительных
+	for _, acc := range append([]models.Account{master}, extraMasters...) {
+		if err := s.masters.Start(session, acc, opts.StopMatchWindow); err != nil {
+			_ = s.masters.StopUser(userID)
+			_ = s.manager.StopSession(userID, "websocket")
+			_ = unlockSession(ctx, s.registry, userID)
+			return fmt.Errorf("failed to start websocket: %w", err)
+		}
 	}
 
-	s.connections[userID] = wsService
-
 	s.logger.Info("WebSocket copy trading started",
 		slog.Int("user_id", userID),
-		slog.Bool("ignore_fees", opts.IgnoreFees))
+		slog.Int("masters", 1+len(extraMasters)),
+		slog.Bool("ignore_fees", opts.IgnoreFees),
+		slog.Duration("stop_match_window", opts.StopMatchWindow),
+		slog.Duration("copy_delay", opts.CopyDelay.Delay),
+		slog.Duration("copy_jitter", opts.CopyDelay.Jitter))
 
 	return nil
 }
 
+// extraMasters находит дополнительные master аккаунты пользователя по ID
+func (s *webSocketService) extraMasters(userID int, master models.Account, ids []int) ([]models.Account, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	accounts, err := s.storage.GetAccounts(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	byID := make(map[int]models.Account, len(accounts))
+	for _, acc := range accounts {
+		byID[acc.ID] = acc
+	}
+
+	extra := make([]models.Account, 0, len(ids))
+	for _, id := range ids {
+		acc, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("account %d not found", id)
+		}
+		if acc.ID == master.ID || slices.ContainsFunc(extra, func(a models.Account) bool { return a.ID == id }) {
+			continue
+		}
+		extra = append(extra, acc)
+	}
+
+	return extra, nil
+}
+
 func (s *webSocketService) Stop(ctx context.Context, userID int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	wsService, ok := s.connections[userID]
-	if !ok {
+	return s.stopLocked(ctx, userID)
+}
+
+// Masters возвращает ID подключенных master аккаунтов пользователя
+func (s *webSocketService) Masters(userID int) []int {
+	return s.masters.Masters(userID)
+}
+
+// Health возвращает задержки соединений master аккаунтов пользователя (master accountID -> задержки)
+func (s *webSocketService) Health(userID int) map[int]websocket.Health {
+	return s.masters.Health(userID)
+}
+
+// autoStop обрабатывает окончательный разрыв WebSocket мастера: без оставшихся мастеров сессия
+// останавливается. Пользователь получает уведомление в обоих случаях
+func (s *webSocketService) autoStop(userID int, master models.Account, cause error) {
+	ctx := context.Background()
+
+	s.mu.Lock()
+	stopped := !s.masters.IsActive(userID)
+	if stopped {
+		if err := s.stopLocked(ctx, userID); err != nil {
+			s.logger.Error("Failed to auto-stop session", slog.Int("user_id", userID), slog.Any("error", err))
+		}
+	}
+	s.mu.Unlock()
+
+	s.logger.Warn("WebSocket master stopped automatically",
+		slog.Int("user_id", userID),
+		slog.String("master", master.Name),
+		slog.Bool("session_stopped", stopped),
+		slog.Any("cause", cause))
+
+	if errors.Is(cause, websocket.ErrAuthFailed) {
+		s.alerter.AccountAuthExpired(ctx, userID, master.Name)
+		return
+	}
+
+	s.alerter.SessionStopped(ctx, userID, master.Name)
+}
+
+// connectionState уведомляет пользователя о разрыве и восстановлении WebSocket master аккаунта.
+// Окончательный разрыв обрабатывает autoStop
+func (s *webSocketService) connectionState(userID int, master models.Account, state websocket.State, cause error) {
+	s.logger.Info("WebSocket connection state changed",
+		slog.Int("user_id", userID),
+		slog.String("master", master.Name),
+		slog.String("state", string(state)),
+		slog.Any("cause", cause))
+
+	ctx := context.Background()
+	switch state {
+	case websocket.StateReconnecting:
+		s.alerter.SessionReconnecting(ctx, userID, master.Name)
+	case websocket.StateConnected:
+		s.alerter.SessionReconnected(ctx, userID, master.Name)
+	}
+}
+
+// stopLocked останавливает WebSocket сессию пользователя (s.mu захвачен)
+func (s *webSocketService) stopLocked(ctx context.Context, userID int) error {
+	if _, err := s.manager.GetSession(userID, "websocket"); err != nil && !s.masters.IsActive(userID) {
 		return nil
 	}
 
 	var errs []error
 
-	if err := wsService.Stop(); err != nil {
+	if err := s.masters.StopUser(userID); err != nil {
 		errs = append(errs, fmt.Errorf("failed to stop websocket: %w", err))
 	}
 
@@ -92,7 +225,9 @@ func (s *webSocketService) Stop(ctx context.Context, userID int) error {
 		errs = append(errs, fmt.Errorf("failed to stop session: %w", err))
 	}
 
-	delete(s.connections, userID)
+	if err := unlockSession(ctx, s.registry, userID); err != nil {
+		errs = append(errs, fmt.Errorf("failed to release session lock: %w", err))
+	}
 
 	s.logger.Info("WebSocket copy trading stopped", slog.Int("user_id", userID))
 
@@ -100,21 +235,22 @@ func (s *webSocketService) Stop(ctx context.Context, userID int) error {
 }
 
 func (s *webSocketService) IsActive(userID int) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	_, ok := s.connections[userID]
-	return ok
+	return s.masters.IsActive(userID)
 }
 
 func (s *webSocketService) stopAll() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for userID, wsService := range s.connections {
-		_ = wsService.Stop()
+	for _, userID := range s.manager.ActiveUserIDs() {
+		if _, err := s.manager.GetSession(userID, "websocket"); err != nil {
+			continue
+		}
+		_ = s.masters.StopUser(userID)
 		_ = s.manager.StopSession(userID, "websocket")
+		_ = unlockSession(context.Background(), s.registry, userID)
 		s.logger.Info("WebSocket stopped (shutdown)", slog.Int("user_id", userID))
 	}
 
-	s.connections = make(map[int]*wscopytrading.Service)
+	s.masters.StopAll()
 }
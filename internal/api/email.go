@@ -0,0 +1,325 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"tg_mexc/internal/api/auth"
+	"tg_mexc/internal/api/middleware"
+	"tg_mexc/internal/models"
+)
+
+const (
+	emailTokenVerify = "verify_email"
+	emailTokenReset  = "password_reset"
+
+	emailVerifyTTL   = 24 * time.Hour
+	passwordResetTTL = 1 * time.Hour
+)
+
+// ProfileResponse - профиль текущего пользователя
+type ProfileResponse struct {
+	UserID         int    `json:"user_id"`
+	Username       string `json:"username"`
+	Email          string `json:"email,omitempty"`
+	EmailVerified  bool   `json:"email_verified"`
+	IsAdmin        bool   `json:"is_admin"`
+	TelegramLinked bool   `json:"telegram_linked"`
+}
+
+type SetEmailRequest struct {
+	Email string `json:"email"`
+}
+
+type PasswordResetRequest struct {
+	Email string `json:"email"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// HandleGetProfile возвращает профиль текущего пользователя
+func (h *Handler) HandleGetProfile(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	user, err := h.storage.GetUserByID(userID)
+	if err != nil {
+		h.logger.Error("Failed to get user", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Internal server error")
+
+		return
+	}
+
+	_, _, telegramLinked, err := h.storage.GetUserContact(userID)
+	if err != nil {
+		h.logger.Error("Failed to get user contact", "error", err)
+	}
+
+	h.respondSuccess(w, "", ProfileResponse{
+		UserID:         user.ID,
+		Username:       user.Username,
+		Email:          user.Email,
+		EmailVerified:  user.EmailVerified,
+		IsAdmin:        user.IsAdmin,
+		TelegramLinked: telegramLinked,
+	})
+}
+
+// HandleSetEmail задает email и отправляет ссылку для подтверждения
+func (h *Handler) HandleSetEmail(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	var req SetEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid email")
+		return
+	}
+
+	if err := h.storage.SetUserEmail(userID, email); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			h.respondError(w, http.StatusConflict, "Email already in use")
+			return
+		}
+
+		h.logger.Error("Failed to set email", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to set email")
+
+		return
+	}
+
+	// Старые ссылки подтверждения больше не действуют
+	h.storage.DeleteEmailTokens(userID, emailTokenVerify)
+
+	if err := h.sendVerificationEmail(r, userID, email); err != nil {
+		h.logger.Error("Failed to send verification email", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to send verification email")
+
+		return
+	}
+
+	h.respondSuccess(w, "Verification email sent", nil)
+}
+
+// HandleResendVerification повторно отправляет ссылку подтверждения email
+func (h *Handler) HandleResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	user, err := h.storage.GetUserByID(userID)
+	if err != nil {
+		h.logger.Error("Failed to get user", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Internal server error")
+
+		return
+	}
+
+	if user.Email == "" {
+		h.respondError(w, http.StatusBadRequest, "Email is not set")
+		return
+	}
+
+	if user.EmailVerified {
+		h.respondSuccess(w, "Email already verified", nil)
+		return
+	}
+
+	if err := h.sendVerificationEmail(r, userID, user.Email); err != nil {
+		h.logger.Error("Failed to send verification email", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to send verification email")
+
+		return
+	}
+
+	h.respondSuccess(w, "Verification email sent", nil)
+}
+
+// HandleVerifyEmail подтверждает email по ссылке из письма
+func (h *Handler) HandleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	userID, email, err := h.storage.ConsumeEmailToken(hashToken(r.URL.Query().Get("token")), emailTokenVerify)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			h.logger.Error("Failed to consume email token", "error", err)
+		}
+		http.Redirect(w, r, "/#"+url.Values{"error": {"Verification link is invalid or expired"}}.Encode(), http.StatusFound)
+
+		return
+	}
+
+	verified, err := h.storage.MarkEmailVerified(userID, email)
+	if err != nil || !verified {
+		http.Redirect(w, r, "/#"+url.Values{"error": {"Verification link is invalid or expired"}}.Encode(), http.StatusFound)
+		return
+	}
+
+	h.storage.AddLog(r.Context(), models.ActivityLog{
+		UserID:  &userID,
+		Level:   "info",
+		Action:  "email_verified",
+		Message: fmt.Sprintf("Email %s verified", email),
+	})
+
+	http.Redirect(w, r, "/#email_verified=1", http.StatusFound)
+}
+
+// HandlePasswordReset отправляет ссылку для сброса пароля на подтвержденный email.
+// Ответ всегда одинаковый, чтобы не раскрывать, зарегистрирован ли email
+func (h *Handler) HandlePasswordReset(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	const message = "If this email is registered, a reset link has been sent"
+
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		h.respondSuccess(w, message, nil)
+		return
+	}
+
+	user, err := h.storage.GetUserByVerifiedEmail(email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			h.logger.Error("Failed to get user by email", "error", err)
+		}
+		h.respondSuccess(w, message, nil)
+
+		return
+	}
+
+	token, err := h.createEmailToken(user.ID, emailTokenReset, email, passwordResetTTL)
+	if err != nil {
+		h.logger.Error("Failed to create reset token", "error", err)
+		h.respondSuccess(w, message, nil)
+
+		return
+	}
+
+	link := strings.TrimSuffix(h.apiURL, "/") + "/#" + url.Values{"reset_token": {token}}.Encode()
+	body := fmt.Sprintf("A password reset was requested for %s.\n\nReset your password: %s\n\n"+
+		"The link expires in %s. If you didn't request this, ignore this email.", user.Username, link, passwordResetTTL)
+
+	if err := h.mailer.Send(r.Context(), email, "Password reset", body); err != nil {
+		h.logger.Error("Failed to send reset email", "error", err)
+	}
+
+	h.respondSuccess(w, message, nil)
+}
+
+// HandlePasswordResetConfirm задает новый пароль по токену из письма
+func (h *Handler) HandlePasswordResetConfirm(w http.ResponseWriter, r *http.Request) {
+	var req PasswordResetConfirmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if len(req.Password) < 6 {
+		h.respondError(w, http.StatusBadRequest, "Password must be at least 6 characters")
+		return
+	}
+
+	userID, _, err := h.storage.ConsumeEmailToken(hashToken(req.Token), emailTokenReset)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Reset link is invalid or expired")
+		return
+	}
+
+	passwordHash, err := h.authService.HashPassword(req.Password)
+	if err != nil {
+		h.logger.Error("Failed to hash password", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Internal server error")
+
+		return
+	}
+
+	if err := h.storage.UpdatePasswordHash(userID, passwordHash); err != nil {
+		h.logger.Error("Failed to update password", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Internal server error")
+
+		return
+	}
+
+	// Завершаем все сессии и снимаем блокировку входа
+	h.storage.DeleteUserRefreshTokens(userID)
+	h.storage.DeleteEmailTokens(userID, emailTokenReset)
+	if user, err := h.storage.GetUserByID(userID); err == nil {
+		if err := h.loginGuard.Unlock(auth.ScopeUsername, user.Username); err != nil {
+			h.logger.Error("Failed to unlock login", "error", err)
+		}
+	}
+
+	h.storage.AddLog(r.Context(), models.ActivityLog{
+		UserID:  &userID,
+		Level:   "warn",
+		Action:  "password_reset",
+		Message: fmt.Sprintf("Password reset via email from %s", h.clientIP(r)),
+	})
+
+	h.logger.Info("Password reset", slog.Int("user_id", userID))
+	h.respondSuccess(w, "Password updated", nil)
+}
+
+func (h *Handler) sendVerificationEmail(r *http.Request, userID int, email string) error {
+	token, err := h.createEmailToken(userID, emailTokenVerify, email, emailVerifyTTL)
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimSuffix(h.apiURL, "/") + "/api/auth/verify-email?" + url.Values{"token": {token}}.Encode()
+	body := fmt.Sprintf("Confirm your email for MEXC Copy Trading: %s\n\nThe link expires in %s.", link, emailVerifyTTL)
+
+	return h.mailer.Send(r.Context(), email, "Confirm your email", body)
+}
+
+// createEmailToken создает одноразовый токен; в БД хранится только его хеш
+func (h *Handler) createEmailToken(userID int, purpose, email string, ttl time.Duration) (string, error) {
+	token, err := h.authService.GenerateRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	if err := h.storage.SaveEmailToken(hashToken(token), userID, purpose, email, time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeEmail проверяет адрес и приводит его к нижнему регистру
+func normalizeEmail(raw string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(raw))
+	if err != nil {
+		return "", err
+	}
+
+	// Принимаем только "голый" адрес, без display name
+	if addr.Name != "" || addr.Address != strings.TrimSpace(raw) {
+		return "", errors.New("invalid email")
+	}
+
+	return strings.ToLower(addr.Address), nil
+}
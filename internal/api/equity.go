@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"tg_mexc/internal/api/middleware"
+	"tg_mexc/internal/equity"
+
+	"github.com/gorilla/mux"
+)
+
+// HandleGetAccountEquity возвращает кривую equity аккаунта (?range=30d)
+func (h *Handler) HandleGetAccountEquity(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	vars := mux.Vars(r)
+	accountID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid account ID")
+		return
+	}
+
+	period, err := equity.ParseRange(r.URL.Query().Get("range"))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid range (e.g. 7d, 30d, 12h)")
+		return
+	}
+
+	points, err := h.equity.AccountCurve(userID, accountID, period)
+	if err != nil {
+		h.logger.Error("Failed to get equity curve", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get equity curve")
+		return
+	}
+
+	h.respondSuccess(w, "", points)
+}
+
+// HandleGetSlavesEquity возвращает суммарную кривую equity всех slave аккаунтов (?range=30d)
+func (h *Handler) HandleGetSlavesEquity(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	period, err := equity.ParseRange(r.URL.Query().Get("range"))
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid range (e.g. 7d, 30d, 12h)")
+		return
+	}
+
+	points, err := h.equity.SlavesCurve(userID, period)
+	if err != nil {
+		h.logger.Error("Failed to get slaves equity curve", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get equity curve")
+		return
+	}
+
+	h.respondSuccess(w, "", points)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"tg_mexc/internal/api/middleware"
+	"tg_mexc/internal/features"
+	"tg_mexc/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// SetFeatureRequest - запрос на переопределение feature flag
+type SetFeatureRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// HandleGetFeatures возвращает состояние feature flags для текущего пользователя
+func (h *Handler) HandleGetFeatures(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	h.respondSuccess(w, "", h.features.Effective(userID))
+}
+
+// HandleGetUserFeatures возвращает состояние feature flags пользователя {id} (admin)
+func (h *Handler) HandleGetUserFeatures(w http.ResponseWriter, r *http.Request) {
+	userID, ok := h.featureUser(w, r)
+	if !ok {
+		return
+	}
+
+	h.respondSuccess(w, "", h.features.Effective(userID))
+}
+
+// HandleSetFeature включает/выключает feature flag пользователю {id} (admin)
+func (h *Handler) HandleSetFeature(w http.ResponseWriter, r *http.Request) {
+	adminName, _ := middleware.GetUsername(r.Context())
+
+	userID, ok := h.featureUser(w, r)
+	if !ok {
+		return
+	}
+
+	flag := features.Flag(mux.Vars(r)["flag"])
+	if !features.IsKnown(flag) {
+		h.respondError(w, http.StatusNotFound, "Unknown feature flag")
+		return
+	}
+
+	var req SetFeatureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.features.SetOverride(userID, flag, req.Enabled); err != nil {
+		h.logger.Error("Failed to set feature override", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to set feature flag")
+
+		return
+	}
+
+	h.storage.AddLog(r.Context(), models.ActivityLog{
+		UserID:  &userID,
+		Level:   "info",
+		Action:  "feature_flag_set",
+		Message: fmt.Sprintf("Feature flag %s set to %t by admin %s", flag, req.Enabled, adminName),
+	})
+
+	h.respondSuccess(w, "Feature flag updated", h.features.Effective(userID))
+}
+
+// HandleResetFeature сбрасывает переопределение feature flag пользователя {id} к глобальному значению (admin)
+func (h *Handler) HandleResetFeature(w http.ResponseWriter, r *http.Request) {
+	adminName, _ := middleware.GetUsername(r.Context())
+
+	userID, ok := h.featureUser(w, r)
+	if !ok {
+		return
+	}
+
+	flag := features.Flag(mux.Vars(r)["flag"])
+	if !features.IsKnown(flag) {
+		h.respondError(w, http.StatusNotFound, "Unknown feature flag")
+		return
+	}
+
+	if err := h.features.ResetOverride(userID, flag); err != nil {
+		h.logger.Error("Failed to reset feature override", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to reset feature flag")
+
+		return
+	}
+
+	h.storage.AddLog(r.Context(), models.ActivityLog{
+		UserID:  &userID,
+		Level:   "info",
+		Action:  "feature_flag_reset",
+		Message: fmt.Sprintf("Feature flag %s reset by admin %s", flag, adminName),
+	})
+
+	h.respondSuccess(w, "Feature flag reset", h.features.Effective(userID))
+}
+
+// featureUser возвращает пользователя {id} маршрутов admin feature flags (false - ответ уже отправлен)
+func (h *Handler) featureUser(w http.ResponseWriter, r *http.Request) (int, bool) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid user ID")
+		return 0, false
+	}
+
+	if _, err := h.storage.GetUserByID(userID); err != nil {
+		h.respondError(w, http.StatusNotFound, "User not found")
+		return 0, false
+	}
+
+	return userID, true
+}
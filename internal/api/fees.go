@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"tg_mexc/internal/api/middleware"
+)
+
+const (
+	defaultFeesDays = 30
+	maxFeesDays     = 365
+)
+
+// HandleGetFees возвращает уплаченные комиссии по аккаунтам за последние N дней (?days=, по умолчанию 30)
+func (h *Handler) HandleGetFees(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	summary, err := h.fees.Summary(userID, parseDays(r, defaultFeesDays, maxFeesDays))
+	if err != nil {
+		h.logger.Error("Failed to get fees summary", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get fees")
+		return
+	}
+
+	h.respondSuccess(w, "", summary)
+}
+
+// HandleSyncFees догружает комиссии из истории ордеров по всем аккаунтам
+func (h *Handler) HandleSyncFees(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	ctx, cancel := context.WithTimeout(r.Context(), historySyncTimeout)
+	defer cancel()
+
+	added, err := h.fees.Backfill(ctx, userID)
+	if err != nil {
+		// Частичная ошибка: часть аккаунтов могла синхронизироваться
+		h.logger.Warn("Fees backfill finished with errors", "error", err)
+	}
+
+	h.respondSuccess(w, "Fees synced", map[string]any{
+		"added":  added,
+		"errors": errorString(err),
+	})
+}
@@ -4,9 +4,19 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"net/netip"
 
 	"tg_mexc/internal/api/auth"
 	"tg_mexc/internal/api/copytrading"
+	"tg_mexc/internal/equity"
+	"tg_mexc/internal/exposure"
+	"tg_mexc/internal/features"
+	"tg_mexc/internal/fees"
+	"tg_mexc/internal/health"
+	"tg_mexc/internal/mailer"
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/pnl"
+	"tg_mexc/internal/reports"
 	"tg_mexc/internal/storage"
 )
 
@@ -15,7 +25,23 @@ type Handler struct {
 	storage        *storage.WebStorage
 	authService    *auth.Service
 	copyTradingSvc copytrading.CopyTradingService
+	features       *features.Service
+	pnl            *pnl.Service
+	equity         *equity.Service
+	fees           *fees.Service
+	exposure       *exposure.Service
+	reports        *reports.Service
+	health         *health.Service
+	loginGuard     *auth.Guard
+	telegramLogin  *auth.TelegramVerifier
+	mailer         mailer.Mailer
+	oidc           *auth.OIDCProvider // nil если SSO не настроен
+	oidcLinkByName bool
+	registration   bool // Открытая регистрация
+	trustedProxies []netip.Prefix
 	apiURL         string
+	telegramBot    string // Username бота для Telegram Login Widget
+	clients        *mexc.ClientPool
 	logger         *slog.Logger
 }
 
@@ -23,18 +49,65 @@ func New(
 	storage *storage.WebStorage,
 	authService *auth.Service,
 	copyTradingSvc copytrading.CopyTradingService,
+	featureSvc *features.Service,
+	pnlSvc *pnl.Service,
+	equitySvc *equity.Service,
+	feesSvc *fees.Service,
+	exposureSvc *exposure.Service,
+	reportsSvc *reports.Service,
+	healthSvc *health.Service,
+	loginGuard *auth.Guard,
+	telegramLogin *auth.TelegramVerifier,
+	mailer mailer.Mailer,
 	apiURL string,
+	telegramBot string,
 	logger *slog.Logger,
 ) *Handler {
 	return &Handler{
 		storage:        storage,
 		authService:    authService,
 		copyTradingSvc: copyTradingSvc,
+		features:       featureSvc,
+		pnl:            pnlSvc,
+		equity:         equitySvc,
+		fees:           feesSvc,
+		exposure:       exposureSvc,
+		reports:        reportsSvc,
+		health:         healthSvc,
+		loginGuard:     loginGuard,
+		telegramLogin:  telegramLogin,
+		mailer:         mailer,
+		registration:   true,
 		apiURL:         apiURL,
+		telegramBot:    telegramBot,
+		clients:        mexc.NewClientPool(logger),
 		logger:         logger,
 	}
 }
 
+// SetClientPool подключает общий с engine пул MEXC клиентов
+func (h *Handler) SetClientPool(pool *mexc.ClientPool) {
+	h.clients = pool
+}
+
+// SetRegistrationEnabled включает/выключает открытую регистрацию
+func (h *Handler) SetRegistrationEnabled(enabled bool) {
+	h.registration = enabled
+}
+
+// SetTrustedProxies задает reverse proxy, от которых принимается IP клиента из X-Forwarded-For и X-Real-IP.
+// Без них IP клиента - адрес соединения
+func (h *Handler) SetTrustedProxies(prefixes []netip.Prefix) {
+	h.trustedProxies = prefixes
+}
+
+// SetOIDC включает вход через OpenID Connect.
+// linkByUsername разрешает привязку SSO к существующему пользователю с тем же username
+func (h *Handler) SetOIDC(provider *auth.OIDCProvider, linkByUsername bool) {
+	h.oidc = provider
+	h.oidcLinkByName = linkByUsername
+}
+
 // Helper функции для JSON ответов
 
 type ErrorResponse struct {
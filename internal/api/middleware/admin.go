@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// AdminChecker проверяет права администратора
+type AdminChecker interface {
+	IsUserAdmin(userID int) (bool, error)
+}
+
+// AdminMiddleware пропускает только администраторов (использовать после AuthMiddleware)
+func AdminMiddleware(checker AdminChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserID(r.Context())
+			if !ok {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			isAdmin, err := checker.IsUserAdmin(userID)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+
+			if !isAdmin {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -27,14 +27,12 @@ func AuthMiddleware(authService *auth.Service) func(http.Handler) http.Handler {
 			}
 
 			// Извлекаем токен (формат: "Bearer <token>")
-			parts := strings.Split(authHeader, " ")
-			if len(parts) != 2 || parts[0] != "Bearer" {
+			scheme, tokenString, ok := strings.Cut(authHeader, " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") || tokenString == "" || strings.Contains(tokenString, " ") {
 				http.Error(w, "Invalid authorization header", http.StatusUnauthorized)
 				return
 			}
 
-			tokenString := parts[1]
-
 			// Валидируем токен
 			claims, err := authService.ValidateToken(tokenString)
 			if err != nil {
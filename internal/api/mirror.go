@@ -5,7 +5,6 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
-	"time"
 
 	"tg_mexc/internal/api/middleware"
 )
@@ -18,7 +17,7 @@ func (h *Handler) HandleMirrorAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, _, ok := h.copyTradingSvc.ValidateMirrorToken(token)
+	userID, _, ok := h.copyTradingSvc.ValidateMirrorToken(r.Context(), token)
 	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
@@ -40,11 +39,9 @@ func (h *Handler) HandleMirrorAPI(w http.ResponseWriter, r *http.Request) {
 	)
 
 	// Обрабатываем в горутине
+	// Таймауты fan-out задает engine по типу операции и числу slave аккаунтов
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		if err := h.copyTradingSvc.ProcessMirrorRequest(ctx, token, path, body); err != nil {
+		if err := h.copyTradingSvc.ProcessMirrorRequest(context.Background(), token, path, body); err != nil {
 			h.logger.Error("Mirror request failed",
 				slog.String("path", path),
 				slog.Int("user_id", userID),
@@ -61,7 +58,7 @@ func (h *Handler) HandleGetMirrorScript(w http.ResponseWriter, r *http.Request)
 	userID, _ := middleware.GetUserID(r.Context())
 	username, _ := middleware.GetUsername(r.Context())
 
-	script := h.copyTradingSvc.GetMirrorScript(userID, username)
+	script := h.copyTradingSvc.GetMirrorScript(r.Context(), userID, username)
 
 	h.respondSuccess(w, "", map[string]string{
 		"script":     script,
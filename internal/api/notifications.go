@@ -0,0 +1,53 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"tg_mexc/internal/api/middleware"
+	"tg_mexc/internal/models"
+	"tg_mexc/internal/notifier"
+)
+
+// HandleGetNotificationSettings возвращает каналы уведомлений о сделках (без настроек - только Telegram)
+func (h *Handler) HandleGetNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	settings, err := notifier.Settings(h.storage, userID)
+	if err != nil {
+		h.logger.Error("Failed to get notification settings", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get notification settings")
+		return
+	}
+
+	h.respondSuccess(w, "", settings)
+}
+
+// HandleSetNotificationSettings сохраняет каналы уведомлений о сделках и webhook URL Discord/Slack
+// (webhooks используются и алертами с каналами discord/slack)
+func (h *Handler) HandleSetNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	var settings models.NotificationSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	settings.UserID = userID
+	if settings.Channels == nil {
+		settings.Channels = []string{}
+	}
+
+	if err := notifier.ValidateSettings(settings); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.storage.SetNotificationSettings(settings); err != nil {
+		h.logger.Error("Failed to save notification settings", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update notification settings")
+		return
+	}
+
+	h.respondSuccess(w, "Notification settings updated", nil)
+}
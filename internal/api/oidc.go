@@ -0,0 +1,157 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"tg_mexc/internal/models"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+// HandleOIDCLogin перенаправляет пользователя на страницу входа OIDC провайдера
+func (h *Handler) HandleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		h.respondError(w, http.StatusNotFound, "OIDC login is not configured")
+		return
+	}
+
+	state := oauth2.GenerateVerifier()
+	nonce := oauth2.GenerateVerifier()
+	codeVerifier := oauth2.GenerateVerifier()
+
+	// state, nonce и PKCE verifier живут в короткоживущей HttpOnly cookie до callback
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    strings.Join([]string{state, nonce, codeVerifier}, "."),
+		Path:     "/api/auth/oidc",
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.apiURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, h.oidc.AuthCodeURL(state, nonce, codeVerifier), http.StatusFound)
+}
+
+// HandleOIDCCallback завершает вход: проверяет ID token, находит/создает локального
+// пользователя и передает токены frontend'у через URL fragment
+func (h *Handler) HandleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if h.oidc == nil {
+		h.respondError(w, http.StatusNotFound, "OIDC login is not configured")
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		h.redirectOIDCError(w, r, "Login session expired, try again")
+		return
+	}
+
+	// Cookie одноразовая
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/auth/oidc", MaxAge: -1})
+
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 3 || r.URL.Query().Get("state") != parts[0] {
+		h.redirectOIDCError(w, r, "Invalid login state")
+		return
+	}
+
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		h.redirectOIDCError(w, r, "SSO error: "+errParam)
+		return
+	}
+
+	identity, err := h.oidc.Exchange(r.Context(), r.URL.Query().Get("code"), parts[1], parts[2])
+	if err != nil {
+		h.logger.Warn("OIDC login rejected", slog.Any("error", err))
+		h.redirectOIDCError(w, r, "SSO login failed")
+
+		return
+	}
+
+	user, err := h.resolveOIDCUser(r, identity.Issuer, identity.Subject, identity.Username)
+	if err != nil {
+		h.logger.Error("Failed to resolve OIDC user",
+			slog.String("issuer", identity.Issuer),
+			slog.String("subject", identity.Subject),
+			slog.Any("error", err))
+		h.redirectOIDCError(w, r, err.Error())
+
+		return
+	}
+
+	resp, err := h.issueTokens(user, time.Now())
+	if err != nil {
+		h.logger.Error("Failed to issue tokens", "error", err)
+		h.redirectOIDCError(w, r, "Internal server error")
+
+		return
+	}
+
+	fragment := url.Values{
+		"token":         {resp.Token},
+		"refresh_token": {resp.RefreshToken},
+		"expires_in":    {strconv.Itoa(resp.ExpiresIn)},
+		"username":      {user.Username},
+	}
+	http.Redirect(w, r, "/#"+fragment.Encode(), http.StatusFound)
+}
+
+// resolveOIDCUser находит пользователя по (issuer, subject) или создает нового (только при открытой регистрации).
+// Существующий локальный пользователь с тем же username привязывается только при OIDC_LINK_BY_USERNAME
+func (h *Handler) resolveOIDCUser(r *http.Request, issuer, subject, username string) (*models.User, error) {
+	userID, err := h.storage.GetUserIDByIdentity(issuer, subject)
+	if err == nil {
+		return h.storage.GetUserByID(userID)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	user, err := h.storage.GetUserByUsername(username)
+	switch {
+	case err == nil && !h.oidcLinkByName:
+		return nil, fmt.Errorf("username %s is already taken", username)
+	case errors.Is(err, sql.ErrNoRows):
+		if !h.registration {
+			return nil, errors.New("registration is disabled")
+		}
+		user, err = h.storage.CreateUser(username, "")
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+
+	if err := h.storage.LinkIdentity(user.ID, issuer, subject); err != nil {
+		return nil, err
+	}
+
+	h.storage.AddLog(r.Context(), models.ActivityLog{
+		UserID:  &user.ID,
+		Level:   "info",
+		Action:  "oidc_linked",
+		Message: fmt.Sprintf("SSO identity linked (issuer: %s)", issuer),
+	})
+
+	return user, nil
+}
+
+// redirectOIDCError возвращает пользователя на страницу входа с ошибкой
+func (h *Handler) redirectOIDCError(w http.ResponseWriter, r *http.Request, message string) {
+	http.Redirect(w, r, "/#"+url.Values{"error": {message}}.Encode(), http.StatusFound)
+}
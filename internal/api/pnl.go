@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"tg_mexc/internal/api/middleware"
+)
+
+const (
+	defaultPnLDays = 30
+	maxPnLDays     = 365
+
+	historySyncTimeout = 60 * time.Second
+)
+
+// HandleGetPnL возвращает агрегированный PnL за последние N дней (?days=, по умолчанию 30)
+func (h *Handler) HandleGetPnL(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	summary, err := h.pnl.Summary(userID, parseDays(r, defaultPnLDays, maxPnLDays))
+	if err != nil {
+		h.logger.Error("Failed to get pnl summary", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get PnL")
+		return
+	}
+
+	h.respondSuccess(w, "", summary)
+}
+
+// HandleGetPnLDaily возвращает дневные записи PnL по аккаунтам и символам
+func (h *Handler) HandleGetPnLDaily(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	records, err := h.pnl.Daily(userID, parseDays(r, defaultPnLDays, maxPnLDays))
+	if err != nil {
+		h.logger.Error("Failed to get pnl records", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get PnL")
+		return
+	}
+
+	h.respondSuccess(w, "", records)
+}
+
+// HandleSyncPnL догружает закрытые позиции из истории биржи по всем аккаунтам
+func (h *Handler) HandleSyncPnL(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	ctx, cancel := context.WithTimeout(r.Context(), historySyncTimeout)
+	defer cancel()
+
+	added, err := h.pnl.Backfill(ctx, userID)
+	if err != nil {
+		// Частичная ошибка: часть аккаунтов могла синхронизироваться
+		h.logger.Warn("PnL backfill finished with errors", "error", err)
+	}
+
+	h.respondSuccess(w, "PnL synced", map[string]any{
+		"added":  added,
+		"errors": errorString(err),
+	})
+}
+
+// parseDays читает период в днях из ?days= (def если не задан или вне (0, maxDays])
+func parseDays(r *http.Request, def, maxDays int) int {
+	days := def
+	if d := r.URL.Query().Get("days"); d != "" {
+		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 && parsed <= maxDays {
+			days = parsed
+		}
+	}
+
+	return days
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
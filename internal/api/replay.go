@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"tg_mexc/internal/api/middleware"
+	"tg_mexc/internal/mexc/copytrading/replay"
+	wscopytrading "tg_mexc/internal/mexc/copytrading/websocket"
+	"tg_mexc/internal/mexc/websocket"
+	"tg_mexc/internal/models"
+)
+
+const (
+	defaultReplayDays = 1
+	maxReplayDays     = 30
+	maxReplayEvents   = 500
+)
+
+// HandleReplay прогоняет записанные события master аккаунта за последние N дней через Engine в DRY_RUN (?days=1).
+// ?source=frames берет вместо журнала событий сырые сообщения WebSocket мастера (WS_RECORD_DB).
+// Сделки не исполняются и не сохраняются - возвращается, что сделал бы Engine с текущими настройками.
+func (h *Handler) HandleReplay(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	days := parseDays(r, defaultReplayDays, maxReplayDays)
+	since := time.Now().AddDate(0, 0, -days)
+
+	accounts, err := h.storage.GetAccounts(userID)
+	if err != nil {
+		h.logger.Error("Failed to get accounts", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get accounts")
+		return
+	}
+
+	var events []models.MasterEvent
+	switch r.URL.Query().Get("source") {
+	case "", "events":
+		events, err = h.storage.GetMasterEvents(userID, since, maxReplayEvents)
+	case "frames":
+		events, err = h.masterFrameEvents(accounts, since)
+	default:
+		h.respondError(w, http.StatusBadRequest, "source must be events or frames")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to get master events", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get recorded events")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), historySyncTimeout)
+	defer cancel()
+
+	report, err := replay.Run(ctx, userID, accounts, events, h.logger)
+	if err != nil {
+		h.logger.Error("Replay failed", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Replay failed: "+err.Error())
+		return
+	}
+
+	h.respondSuccess(w, "", report)
+}
+
+// masterFrameEvents собирает события для replay из сырых сообщений WebSocket master аккаунта
+func (h *Handler) masterFrameEvents(accounts []models.Account, since time.Time) ([]models.MasterEvent, error) {
+	for _, acc := range accounts {
+		if !acc.IsMaster {
+			continue
+		}
+
+		frames, err := h.storage.GetWSFrames(acc.ID, since, maxReplayEvents)
+		if err != nil {
+			return nil, err
+		}
+
+		return wscopytrading.EventsFromFrames(frames, websocket.StopOrderMatchWindow()), nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"tg_mexc/internal/api/middleware"
+	"tg_mexc/internal/models"
+	"tg_mexc/internal/reports"
+)
+
+// HandleGetReportSettings возвращает подписку пользователя на отчеты (period = "" - отчеты отключены)
+func (h *Handler) HandleGetReportSettings(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	settings, err := h.storage.GetReportSettings(userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		h.logger.Error("Failed to get report settings", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get report settings")
+		return
+	}
+	if settings.Channels == nil {
+		settings.Channels = []string{}
+	}
+
+	h.respondSuccess(w, "", settings)
+}
+
+// HandleSetReportSettings сохраняет подписку на отчеты; пустой period отключает отчеты
+func (h *Handler) HandleSetReportSettings(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	var settings models.ReportSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	settings.UserID = userID
+
+	if settings.Period == "" {
+		if err := h.storage.DeleteReportSettings(userID); err != nil {
+			h.logger.Error("Failed to delete report settings", "error", err)
+			h.respondError(w, http.StatusInternalServerError, "Failed to update report settings")
+			return
+		}
+
+		h.respondSuccess(w, "Reports disabled", nil)
+		return
+	}
+
+	if err := reports.ValidateSettings(settings); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.storage.SetReportSettings(settings); err != nil {
+		h.logger.Error("Failed to save report settings", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update report settings")
+		return
+	}
+
+	h.respondSuccess(w, "Report settings updated", nil)
+}
+
+// HandlePreviewReport рендерит HTML отчет за последний полный день или неделю (?period=daily|weekly)
+func (h *Handler) HandlePreviewReport(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = reports.PeriodDaily
+	}
+	if period != reports.PeriodDaily && period != reports.PeriodWeekly {
+		h.respondError(w, http.StatusBadRequest, "Invalid period (daily or weekly)")
+		return
+	}
+
+	report, err := h.reports.Build(userID, period)
+	if err != nil {
+		h.logger.Error("Failed to build report", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to build report")
+		return
+	}
+
+	html, err := reports.Render(report)
+	if err != nil {
+		h.logger.Error("Failed to render report", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to build report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(html)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 
 	middleware2 "tg_mexc/internal/api/middleware"
 	"tg_mexc/internal/api/web"
@@ -19,6 +20,14 @@ func (h *Handler) SetupRouter() *mux.Router {
 	// Публичные маршруты (не требуют аутентификации)
 	r.HandleFunc("/api/auth/login", h.HandleLogin).Methods("POST", "OPTIONS")
 	r.HandleFunc("/api/auth/register", h.HandleRegister).Methods("POST", "OPTIONS")
+	if h.telegramBot != "" {
+		r.HandleFunc("/api/auth/telegram", h.HandleTelegramLogin).Methods("POST", "OPTIONS")
+	}
+	r.HandleFunc("/api/auth/verify-email", h.HandleVerifyEmail).Methods("GET")
+	r.HandleFunc("/api/auth/password-reset", h.HandlePasswordReset).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/auth/password-reset/confirm", h.HandlePasswordResetConfirm).Methods("POST", "OPTIONS")
+	r.HandleFunc("/api/auth/oidc/login", h.HandleOIDCLogin).Methods("GET")
+	r.HandleFunc("/api/auth/oidc/callback", h.HandleOIDCCallback).Methods("GET")
 	r.HandleFunc("/api/auth/refresh", h.HandleRefresh).Methods("POST", "OPTIONS")
 	r.HandleFunc("/api/auth/logout", h.HandleLogout).Methods("POST", "OPTIONS")
 	r.HandleFunc("/health", h.HandleHealth).Methods("GET")
@@ -28,19 +37,30 @@ func (h *Handler) SetupRouter() *mux.Router {
 	api := r.PathPrefix("/api").Subrouter()
 	api.Use(middleware2.AuthMiddleware(h.authService))
 
+	// Profile
+	api.HandleFunc("/me", h.HandleGetProfile).Methods("GET")
+	api.HandleFunc("/me/email", h.HandleSetEmail).Methods("PUT")
+	api.HandleFunc("/me/email/resend", h.HandleResendVerification).Methods("POST")
+
 	// Accounts
 	api.HandleFunc("/accounts", h.HandleGetAccounts).Methods("GET")
 	api.HandleFunc("/accounts/details", h.HandleGetAccountsWithDetails).Methods("GET")
+	api.HandleFunc("/accounts/health", h.HandleGetAccountsHealth).Methods("GET")
 	api.HandleFunc("/accounts", h.HandleAddAccount).Methods("POST")
 	api.HandleFunc("/accounts/{id:[0-9]+}", h.HandleDeleteAccount).Methods("DELETE")
 	api.HandleFunc("/accounts/{id:[0-9]+}/master", h.HandleSetMaster).Methods("PUT")
 	api.HandleFunc("/accounts/{id:[0-9]+}/disabled", h.HandleToggleDisabled).Methods("PUT")
+	api.HandleFunc("/accounts/{id:[0-9]+}/multiplier", h.HandleSetCopyMultiplier).Methods("PUT")
+	api.HandleFunc("/accounts/{id:[0-9]+}/caps", h.HandleSetCopyCaps).Methods("PUT")
 	api.HandleFunc("/accounts/script", h.HandleGetScript).Methods("GET")
 
 	// Copy Trading - единый API
 	api.HandleFunc("/copy-trading/mode", h.HandleSetMode).Methods("POST")
 	api.HandleFunc("/copy-trading/status", h.HandleGetStatus).Methods("GET")
+	api.HandleFunc("/copy-trading/symbol-filter", h.HandleGetSymbolFilter).Methods("GET")
+	api.HandleFunc("/copy-trading/symbol-filter", h.HandleSetSymbolFilter).Methods("PUT")
 	api.HandleFunc("/copy-trading/script", h.HandleGetMirrorScript).Methods("GET")
+	api.HandleFunc("/copy-trading/replay", h.HandleReplay).Methods("POST")
 
 	// Trades History
 	api.HandleFunc("/trades", h.HandleGetTrades).Methods("GET")
@@ -49,9 +69,58 @@ func (h *Handler) SetupRouter() *mux.Router {
 	// Account trades history
 	api.HandleFunc("/accounts/{id:[0-9]+}/trades", h.HandleGetAccountTrades).Methods("GET")
 
+	// PnL
+	api.HandleFunc("/pnl", h.HandleGetPnL).Methods("GET")
+	api.HandleFunc("/pnl/daily", h.HandleGetPnLDaily).Methods("GET")
+	api.HandleFunc("/pnl/sync", h.HandleSyncPnL).Methods("POST")
+
+	// Fees
+	api.HandleFunc("/fees", h.HandleGetFees).Methods("GET")
+	api.HandleFunc("/fees/sync", h.HandleSyncFees).Methods("POST")
+
+	// Analytics
+	api.HandleFunc("/analytics/latency", h.HandleGetLatency).Methods("GET")
+	api.HandleFunc("/analytics/slippage", h.HandleGetSlippage).Methods("GET")
+	api.HandleFunc("/analytics/exposure", h.HandleGetExposure).Methods("GET")
+
+	// Alerts
+	api.HandleFunc("/alerts/rules", h.HandleGetAlertRules).Methods("GET")
+	api.HandleFunc("/alerts/rules", h.HandleCreateAlertRule).Methods("POST")
+	api.HandleFunc("/alerts/rules/{id:[0-9]+}", h.HandleUpdateAlertRule).Methods("PUT")
+	api.HandleFunc("/alerts/rules/{id:[0-9]+}", h.HandleDeleteAlertRule).Methods("DELETE")
+	api.HandleFunc("/alerts/events", h.HandleGetAlertEvents).Methods("GET")
+
+	// Reports
+	api.HandleFunc("/reports/settings", h.HandleGetReportSettings).Methods("GET")
+	api.HandleFunc("/reports/settings", h.HandleSetReportSettings).Methods("PUT")
+	api.HandleFunc("/reports/preview", h.HandlePreviewReport).Methods("GET")
+
+	// Notifications (copied-trade channels: Telegram, Discord, Slack)
+	api.HandleFunc("/notifications/settings", h.HandleGetNotificationSettings).Methods("GET")
+	api.HandleFunc("/notifications/settings", h.HandleSetNotificationSettings).Methods("PUT")
+
+	// Equity curve (снимки баланса)
+	api.HandleFunc("/accounts/{id:[0-9]+}/equity", h.HandleGetAccountEquity).Methods("GET")
+	api.HandleFunc("/equity", h.HandleGetSlavesEquity).Methods("GET")
+
 	// Activity Logs
 	api.HandleFunc("/logs", h.HandleGetLogs).Methods("GET")
 
+	// Feature flags
+	api.HandleFunc("/features", h.HandleGetFeatures).Methods("GET")
+
+	// Admin
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware2.AdminMiddleware(h.storage))
+	admin.HandleFunc("/lockouts", h.HandleGetLockouts).Methods("GET")
+	admin.HandleFunc("/lockouts/unlock", h.HandleUnlock).Methods("POST")
+	admin.HandleFunc("/benchmark", h.HandleBenchmark).Methods("POST")
+	admin.HandleFunc("/transport", h.HandleGetTransportStats).Methods("GET")
+	admin.HandleFunc("/websocket", h.HandleGetWebSocketStats).Methods("GET")
+	admin.HandleFunc("/users/{id:[0-9]+}/features", h.HandleGetUserFeatures).Methods("GET")
+	admin.HandleFunc("/users/{id:[0-9]+}/features/{flag}", h.HandleSetFeature).Methods("PUT")
+	admin.HandleFunc("/users/{id:[0-9]+}/features/{flag}", h.HandleResetFeature).Methods("DELETE")
+
 	// Mirror API endpoints - перехват MEXC API запросов
 	r.PathPrefix("/api/platform/futures/").HandlerFunc(h.HandleMirrorAPI).Methods("POST", "OPTIONS")
 
@@ -74,8 +143,16 @@ func (h *Handler) HandleConfigJS(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/javascript")
 	w.Header().Set("Cache-Control", "no-cache")
 
+	oidcEnabled := "false"
+	if h.oidc != nil {
+		oidcEnabled = "true"
+	}
+
 	js := `window.APP_CONFIG = {
-    API_URL: "` + h.apiURL + `"
+    API_URL: "` + h.apiURL + `",
+    TELEGRAM_BOT_USERNAME: "` + h.telegramBot + `",
+    OIDC_ENABLED: ` + oidcEnabled + `,
+    REGISTRATION_ENABLED: ` + strconv.FormatBool(h.registration) + `
 };`
 
 	w.Write([]byte(js))
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"tg_mexc/internal/api/middleware"
+	corecopytrade "tg_mexc/internal/mexc/copytrading"
+	"tg_mexc/internal/models"
+)
+
+// HandleGetSymbolFilter возвращает фильтр символов копирования (без настроек - "off")
+func (h *Handler) HandleGetSymbolFilter(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	filter, err := corecopytrade.SymbolFilter(h.storage, userID)
+	if err != nil {
+		h.logger.Error("Failed to get symbol filter", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to get symbol filter")
+		return
+	}
+
+	h.respondSuccess(w, "", filter)
+}
+
+// HandleSetSymbolFilter сохраняет фильтр символов копирования: whitelist - копируются только входы
+// по символам списка, blacklist - входы по символам списка пропускаются, off - все символы.
+// Действует со следующего входа мастера, в том числе в запущенной сессии
+func (h *Handler) HandleSetSymbolFilter(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	var filter models.SymbolFilter
+	if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+		h.respondError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	filter.UserID = userID
+
+	filter, err := corecopytrade.NormalizeSymbolFilter(filter)
+	if err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.storage.SetSymbolFilter(filter); err != nil {
+		h.logger.Error("Failed to save symbol filter", "error", err)
+		h.respondError(w, http.StatusInternalServerError, "Failed to update symbol filter")
+		return
+	}
+
+	h.respondSuccess(w, "Symbol filter updated", filter)
+}
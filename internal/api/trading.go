@@ -2,12 +2,16 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"tg_mexc/internal/api/copytrading"
 	"tg_mexc/internal/api/middleware"
+	corecopytrade "tg_mexc/internal/mexc/copytrading"
+	"tg_mexc/internal/mexc/websocket"
 
 	"github.com/gorilla/mux"
 )
@@ -16,8 +20,19 @@ import (
 type SetModeRequest struct {
 	Mode       copytrading.Mode `json:"mode"` // "off", "websocket", "mirror"
 	IgnoreFees bool             `json:"ignore_fees,omitempty"`
+	// Окно матчинга order и stop order событий мастера для websocket режима, мс (0 - по умолчанию)
+	StopMatchWindowMs int `json:"stop_match_window_ms,omitempty"`
+	// Дополнительные master аккаунты websocket режима: у каждого свое соединение, сделки копируются
+	// на остальные slave аккаунты
+	ExtraMasterAccountIDs []int `json:"extra_master_account_ids,omitempty"`
+	// Задержка копирования websocket режима перед отправкой slave и случайная добавка к ней, мс
+	CopyDelayMs  int `json:"copy_delay_ms,omitempty"`
+	CopyJitterMs int `json:"copy_jitter_ms,omitempty"`
 }
 
+// maxStopMatchWindowMs - предел окна матчинга: order событие ждет stop order до отправки slave
+const maxStopMatchWindowMs = 10000
+
 // HandleSetMode устанавливает режим copy trading
 func (h *Handler) HandleSetMode(w http.ResponseWriter, r *http.Request) {
 	userID, _ := middleware.GetUserID(r.Context())
@@ -38,11 +53,37 @@ func (h *Handler) HandleSetMode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.StopMatchWindowMs < 0 || req.StopMatchWindowMs > maxStopMatchWindowMs {
+		h.respondError(w, http.StatusBadRequest, "stop_match_window_ms must be between 0 and 10000")
+		return
+	}
+
+	copyDelay := corecopytrade.CopyDelay{
+		Delay:  time.Duration(req.CopyDelayMs) * time.Millisecond,
+		Jitter: time.Duration(req.CopyJitterMs) * time.Millisecond,
+	}
+	if err := copyDelay.Validate(); err != nil {
+		h.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	opts := copytrading.ModeOptions{
-		IgnoreFees: req.IgnoreFees,
+		IgnoreFees:      req.IgnoreFees,
+		StopMatchWindow: time.Duration(req.StopMatchWindowMs) * time.Millisecond,
+		ExtraMasterIDs:  req.ExtraMasterAccountIDs,
+		CopyDelay:       copyDelay,
 	}
 
 	if err := h.copyTradingSvc.SetMode(r.Context(), userID, username, req.Mode, opts); err != nil {
+		// Отказ в авторизации мастера: пользователю нужно обновить токен аккаунта
+		var loginErr *websocket.LoginError
+		if errors.As(err, &loginErr) {
+			h.respondError(w, http.StatusConflict,
+				"MEXC rejected the master account token ("+loginErr.Message+"). "+
+					"Refresh the account with fresh browser data and start copy trading again")
+			return
+		}
+
 		h.respondError(w, http.StatusConflict, err.Error())
 		return
 	}
@@ -1,5 +1,8 @@
 // API Configuration (loaded from /config.js)
 const API_URL = window.APP_CONFIG?.API_URL || '';
+const TELEGRAM_BOT_USERNAME = window.APP_CONFIG?.TELEGRAM_BOT_USERNAME || '';
+const OIDC_ENABLED = window.APP_CONFIG?.OIDC_ENABLED || false;
+const REGISTRATION_ENABLED = window.APP_CONFIG?.REGISTRATION_ENABLED ?? true;
 
 // State
 let token = localStorage.getItem('token');
@@ -8,6 +11,7 @@ let username = localStorage.getItem('username');
 let currentPage = 'accounts';
 let balancesInterval = null;
 let feedInterval = null;
+let tokenRefreshTimer = null;
 let isRefreshing = false;
 let refreshQueue = [];
 let selectedAccountIds = new Set();
@@ -48,6 +52,7 @@ async function apiFetch(url, options = {}) {
                 refreshToken = data.data.refresh_token;
                 localStorage.setItem('token', token);
                 localStorage.setItem('refreshToken', refreshToken);
+                scheduleTokenRefresh();
 
                 // Повторяем оригинальный запрос с новым токеном
                 options.headers['Authorization'] = `Bearer ${token}`;
@@ -80,6 +85,52 @@ async function apiFetch(url, options = {}) {
     return response;
 }
 
+// Silent refresh: обновляем access token за минуту до истечения (exp берем из JWT)
+function scheduleTokenRefresh() {
+    clearTimeout(tokenRefreshTimer);
+    if (!token || !refreshToken) return;
+
+    let exp;
+    try {
+        const payload = JSON.parse(atob(token.split('.')[1].replace(/-/g, '+').replace(/_/g, '/')));
+        exp = payload.exp * 1000;
+    } catch (e) {
+        return;
+    }
+
+    const delay = Math.max(exp - Date.now() - 60000, 5000);
+    tokenRefreshTimer = setTimeout(silentRefresh, delay);
+}
+
+async function silentRefresh() {
+    if (!refreshToken || isRefreshing) return;
+
+    isRefreshing = true;
+    try {
+        const response = await fetch(`${API_URL}/api/auth/refresh`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ refresh_token: refreshToken })
+        });
+
+        if (response.ok) {
+            const data = await response.json();
+            token = data.data.token;
+            refreshToken = data.data.refresh_token;
+            localStorage.setItem('token', token);
+            localStorage.setItem('refreshToken', refreshToken);
+            scheduleTokenRefresh();
+        } else if (response.status === 401) {
+            // Сессия истекла (refresh token просрочен или достигнут абсолютный лимит)
+            handleLogout();
+        }
+    } catch (error) {
+        // Сеть недоступна - попробуем при следующем запросе через apiFetch
+    } finally {
+        isRefreshing = false;
+    }
+}
+
 // DOM Elements
 const loginContainer = document.getElementById('login-container');
 const appContainer = document.getElementById('app-container');
@@ -90,12 +141,20 @@ const logoutBtn = document.getElementById('logout-btn');
 
 // Initialize
 document.addEventListener('DOMContentLoaded', () => {
+    consumeOIDCRedirect();
+
     if (token) {
         showApp();
     } else {
         showLogin();
     }
 
+    if (!REGISTRATION_ENABLED) {
+        document.getElementById('show-register')?.parentElement.classList.add('hidden');
+    }
+
+    setupTelegramLogin();
+    setupOIDCLogin();
     setupEventListeners();
 });
 
@@ -104,6 +163,8 @@ function setupEventListeners() {
     // Login
     loginForm.addEventListener('submit', handleLogin);
     document.getElementById('show-register')?.addEventListener('click', handleRegister);
+    document.getElementById('forgot-password')?.addEventListener('click', handleForgotPassword);
+    document.getElementById('email-btn')?.addEventListener('click', handleSetEmail);
     logoutBtn.addEventListener('click', handleLogout);
 
     // Navigation
@@ -169,6 +230,92 @@ async function handleLogin(e) {
     }
 }
 
+// Telegram Login Widget - показываем только если задан username бота
+function setupTelegramLogin() {
+    if (!TELEGRAM_BOT_USERNAME) return;
+
+    const container = document.getElementById('telegram-login');
+    const script = document.createElement('script');
+    script.async = true;
+    script.src = 'https://telegram.org/js/telegram-widget.js?22';
+    script.setAttribute('data-telegram-login', TELEGRAM_BOT_USERNAME);
+    script.setAttribute('data-size', 'large');
+    script.setAttribute('data-request-access', 'write');
+    script.setAttribute('data-onauth', 'onTelegramAuth(user)');
+    container.appendChild(script);
+    container.classList.remove('hidden');
+}
+
+// Вызывается виджетом после авторизации в Telegram
+async function onTelegramAuth(user) {
+    try {
+        const response = await fetch(`${API_URL}/api/auth/telegram`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(user)
+        });
+
+        const data = await response.json();
+
+        if (response.ok) {
+            token = data.data.token;
+            refreshToken = data.data.refresh_token;
+            username = data.data.username;
+            localStorage.setItem('token', token);
+            localStorage.setItem('refreshToken', refreshToken);
+            localStorage.setItem('username', username);
+            showApp();
+        } else {
+            showError(data.error || 'Telegram login failed');
+        }
+    } catch (error) {
+        showError('Connection error: ' + error.message);
+    }
+}
+
+// SSO - кнопка показывается, если на сервере настроен OIDC
+function setupOIDCLogin() {
+    if (!OIDC_ENABLED) return;
+
+    const btn = document.getElementById('oidc-login-btn');
+    btn.classList.remove('hidden');
+    btn.addEventListener('click', () => {
+        window.location.href = `${API_URL}/api/auth/oidc/login`;
+    });
+}
+
+// После OIDC callback сервер передает токены (или ошибку) через URL fragment
+function consumeOIDCRedirect() {
+    if (!window.location.hash) return;
+
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    if (params.has('reset_token')) {
+        history.replaceState(null, '', window.location.pathname);
+        confirmPasswordReset(params.get('reset_token'));
+        return;
+    }
+    if (params.has('email_verified')) {
+        history.replaceState(null, '', window.location.pathname);
+        alert('Email подтвержден');
+        return;
+    }
+    if (!params.has('token') && !params.has('error')) return;
+
+    history.replaceState(null, '', window.location.pathname);
+
+    if (params.has('error')) {
+        showError(params.get('error'));
+        return;
+    }
+
+    token = params.get('token');
+    refreshToken = params.get('refresh_token');
+    username = params.get('username');
+    localStorage.setItem('token', token);
+    localStorage.setItem('refreshToken', refreshToken);
+    localStorage.setItem('username', username);
+}
+
 async function handleRegister(e) {
     e.preventDefault();
     const usernameInput = prompt('Введите username:');
@@ -201,7 +348,81 @@ async function handleRegister(e) {
     }
 }
 
+// Сброс пароля - ссылка приходит на подтвержденный email
+async function handleForgotPassword(e) {
+    e.preventDefault();
+    const email = prompt('Введите email, указанный в профиле:');
+    if (!email) return;
+
+    try {
+        const response = await fetch(`${API_URL}/api/auth/password-reset`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ email })
+        });
+
+        const data = await response.json();
+        alert(data.message || data.error);
+    } catch (error) {
+        alert('Connection error: ' + error.message);
+    }
+}
+
+async function confirmPasswordReset(resetToken) {
+    const password = prompt('Введите новый пароль (минимум 6 символов):');
+    if (!password) return;
+
+    try {
+        const response = await fetch(`${API_URL}/api/auth/password-reset/confirm`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ token: resetToken, password })
+        });
+
+        const data = await response.json();
+        if (response.ok) {
+            alert('Пароль изменен. Войдите с новым паролем.');
+        } else {
+            alert('Ошибка: ' + (data.error || 'Unknown error'));
+        }
+    } catch (error) {
+        alert('Connection error: ' + error.message);
+    }
+}
+
+// Email для сброса пароля и критических уведомлений (если Telegram не привязан)
+async function handleSetEmail() {
+    try {
+        const profileResponse = await apiFetch(`${API_URL}/api/me`);
+        const profile = (await profileResponse.json()).data || {};
+
+        const status = profile.email
+            ? `Текущий email: ${profile.email} (${profile.email_verified ? 'подтвержден' : 'не подтвержден'})\n\n`
+            : '';
+        const email = prompt(status + 'Новый email:', profile.email || '');
+        if (!email || email === profile.email && profile.email_verified) return;
+
+        const response = email === profile.email
+            ? await apiFetch(`${API_URL}/api/me/email/resend`, { method: 'POST' })
+            : await apiFetch(`${API_URL}/api/me/email`, {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ email })
+            });
+
+        const data = await response.json();
+        if (response.ok) {
+            alert('Письмо для подтверждения отправлено на ' + email);
+        } else {
+            alert('Ошибка: ' + (data.error || 'Unknown error'));
+        }
+    } catch (error) {
+        alert('Connection error: ' + error.message);
+    }
+}
+
 function handleLogout() {
+    clearTimeout(tokenRefreshTimer);
     stopBalancesAutoRefresh();
     stopFeedAutoRefresh();
     // Инвалидируем refresh token на сервере
@@ -231,6 +452,7 @@ function showApp() {
     loginContainer.classList.add('hidden');
     appContainer.classList.remove('hidden');
     usernameDisplay.textContent = username;
+    scheduleTokenRefresh();
     loadAccounts();
     startBalancesAutoRefresh();
     startFeedAutoRefresh();
@@ -322,7 +544,7 @@ function renderAccounts(accounts, withDetails = false) {
     }
 
     container.innerHTML = accounts.map(acc => `
-        <div class="account-card ${acc.is_master ? 'master' : ''} ${acc.disabled ? 'disabled' : ''} ${selectedAccountIds.has(acc.id) ? 'selected' : ''}"
+        <div class="account-card ${acc.is_master ? 'master' : ''} ${acc.disabled ? 'disabled' : ''} ${acc.health?.weakest ? 'weakest' : ''} ${selectedAccountIds.has(acc.id) ? 'selected' : ''}"
              onclick="toggleAccountSelection(${acc.id})" data-account-id="${acc.id}">
             <div class="account-header">
                 <div class="account-name">${acc.name}</div>
@@ -330,13 +552,18 @@ function renderAccounts(accounts, withDetails = false) {
                     ${acc.is_master ? '<span class="account-badge badge-master">Master</span>' : ''}
                     ${acc.disabled ? '<span class="account-badge badge-disabled">Disabled</span>' : '<span class="account-badge badge-enabled">Active</span>'}
                     ${withDetails && (acc.maker_fee > 0 || acc.taker_fee > 0) ? '<span class="account-badge badge-fee">Fee</span>' : ''}
+                    ${acc.health ? `<span class="account-badge badge-health-${acc.health.status}" title="${(acc.health.issues || []).join(', ')}">Health ${acc.health.score}</span>` : ''}
+                    ${acc.health?.weakest ? '<span class="account-badge badge-weakest">Weakest</span>' : ''}
+                    ${withDetails && acc.status?.issues?.length ? `<span class="account-badge badge-disabled" title="${acc.status.issues.join(', ')}">Restricted</span>` : ''}
                 </div>
             </div>
             <div class="account-info">
+                ${acc.health?.issues?.length ? `<div class="account-health-issues">${acc.health.issues.join(', ')}</div>` : ''}
                 ${withDetails ? `
                     <div><strong>Balance:</strong> ${acc.balance?.toFixed(2) || '—'} USDT</div>
                     <div><strong>Maker Fee:</strong> ${((acc.maker_fee || 0) * 100).toFixed(4)}%</div>
                     <div><strong>Taker Fee:</strong> ${((acc.taker_fee || 0) * 100).toFixed(4)}%</div>
+                    <div><strong>Status:</strong> ${acc.status ? (acc.status.issues?.length ? acc.status.issues.join(', ') : `OK, KYC ${acc.status.kyc_level}`) : (acc.status_error || '—')}</div>
                 ` : ''}
                 <div><strong>Token:</strong> ${acc.token}...</div>
             </div>
@@ -793,6 +1020,7 @@ function renderAccountHistory(trades, isMaster) {
                         <th>Сторона</th>
                         <th>Статус</th>
                         <th>Latency</th>
+                        <th>Исполнение</th>
                         <th>Ошибка</th>
                     </tr>
                 </thead>
@@ -808,6 +1036,7 @@ function renderAccountHistory(trades, isMaster) {
                                 <td>${sideText}</td>
                                 <td><span class="status-badge ${detail.status || t.status}">${detail.status || t.status}</span></td>
                                 <td>${detail.latency_ms ? detail.latency_ms + 'ms' : '-'}</td>
+                                <td>${detail.fill_price ? detail.fill_price + (detail.fee ? ` (fee ${detail.fee.toFixed(4)})` : '') : '-'}</td>
                                 <td>${detail.error || '-'}</td>
                             </tr>
                         `;
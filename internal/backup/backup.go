@@ -0,0 +1,89 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// MaxDocumentSize - лимит размера документа, который бот может отправить в Telegram
+	MaxDocumentSize = 50 << 20
+
+	// pbkdf2Iterations - число итераций PBKDF2 при шифровании (передается openssl через -iter)
+	pbkdf2Iterations = 100000
+)
+
+// DecryptCommand - команда расшифровки зашифрованного снимка
+const DecryptCommand = "openssl enc -d -aes-256-cbc -pbkdf2 -iter 100000 -in <file>.db.enc -out <file>.db"
+
+// Storage - база, поддерживающая согласованный снимок в файл
+type Storage interface {
+	Backup(ctx context.Context, path string) error
+}
+
+// Snapshot возвращает согласованный снимок базы
+func Snapshot(ctx context.Context, storage Storage) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "mex-backup-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "backup.db")
+	if err := storage.Backup(ctx, path); err != nil {
+		return nil, err
+	}
+
+	return os.ReadFile(path)
+}
+
+// FileName возвращает имя файла снимка на момент now
+func FileName(now time.Time, encrypted bool) string {
+	name := "mex-backup-" + now.UTC().Format("20060102-150405") + ".db"
+	if encrypted {
+		name += ".enc"
+	}
+
+	return name
+}
+
+// Encrypt шифрует снимок паролем в формате openssl enc -aes-256-cbc -pbkdf2
+// ("Salted__" + соль + шифротекст), расшифровка - DecryptCommand
+func Encrypt(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	// Ключ и IV - первые 32 и следующие 16 байт PBKDF2-HMAC-SHA256
+	keyIV, err := pbkdf2.Key(sha256.New, passphrase, salt, pbkdf2Iterations, 32+aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(keyIV[:32])
+	if err != nil {
+		return nil, err
+	}
+
+	// PKCS#7 padding
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	plain := append(bytes.Clone(data), bytes.Repeat([]byte{byte(padding)}, padding)...)
+
+	out := make([]byte, 0, 16+len(plain))
+	out = append(out, "Salted__"...)
+	out = append(out, salt...)
+	out = out[:16+len(plain)]
+	cipher.NewCBCEncrypter(block, keyIV[32:]).CryptBlocks(out[16:], plain)
+
+	return out, nil
+}
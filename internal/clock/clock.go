@@ -0,0 +1,147 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock - абстракция над временем, позволяющая подменять его в тестах
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer - таймер, созданный через Clock.AfterFunc
+type Timer interface {
+	Stop() bool
+}
+
+// Real - системные часы
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Fake - управляемые вручную часы для детерминированных тестов.
+// Время двигается только через Advance; Sleep/After/AfterFunc срабатывают при достижении дедлайна.
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	fn       func()
+	ch       chan time.Time
+	stopped  bool
+	fired    bool
+	clock    *Fake
+}
+
+// NewFake создает фейковые часы, начинающиеся с now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+// Sleep блокируется до тех пор, пока часы не будут продвинуты на d
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	f.addWaiter(d, nil, ch)
+	return ch
+}
+
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	return f.addWaiter(d, fn, nil)
+}
+
+// Advance сдвигает время и запускает все истекшие таймеры по порядку дедлайнов
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	now := f.now
+
+	var due, pending []*fakeWaiter
+	for _, w := range f.waiters {
+		if !w.deadline.After(now) {
+			due = append(due, w)
+		} else {
+			pending = append(pending, w)
+		}
+	}
+	f.waiters = pending
+	f.mu.Unlock()
+
+	sort.Slice(due, func(i, j int) bool { return due[i].deadline.Before(due[j].deadline) })
+
+	for _, w := range due {
+		f.mu.Lock()
+		if w.stopped {
+			f.mu.Unlock()
+			continue
+		}
+		w.fired = true
+		f.mu.Unlock()
+
+		if w.ch != nil {
+			w.ch <- now
+		}
+		if w.fn != nil {
+			w.fn()
+		}
+	}
+}
+
+func (f *Fake) addWaiter(d time.Duration, fn func(), ch chan time.Time) *fakeWaiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w := &fakeWaiter{
+		deadline: f.now.Add(d),
+		fn:       fn,
+		ch:       ch,
+		clock:    f,
+	}
+	f.waiters = append(f.waiters, w)
+
+	return w
+}
+
+// Stop отменяет таймер. Возвращает false если таймер уже сработал или остановлен
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	if w.stopped || w.fired {
+		return false
+	}
+	w.stopped = true
+
+	return true
+}
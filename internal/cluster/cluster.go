@@ -0,0 +1,141 @@
+package cluster
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// LockTTL - время жизни блокировки без продления: блокировки упавшего инстанса освобождаются сами
+	LockTTL = 30 * time.Second
+	// refreshInterval - период продления удерживаемых блокировок
+	refreshInterval = LockTTL / 3
+)
+
+// Виды сообщений между инстансами
+const (
+	// KindStopSession - остановить сессию пользователя на инстансе-владельце
+	KindStopSession = "stop_session"
+	// KindMirrorRequest - перехваченный mirror запрос для сессии на инстансе-владельце
+	KindMirrorRequest = "mirror_request"
+	// KindSessionLost - блокировка сессии текущего инстанса потеряна (перехвачена или не продлена до LockTTL):
+	// локальная сессия останавливается, иначе сделки копировали бы два инстанса. Не передается между инстансами
+	KindSessionLost = "session_lost"
+)
+
+// ErrLockHeld - блокировка удерживается другим инстансом
+var ErrLockHeld = errors.New("lock is held by another instance")
+
+// Message - сообщение инстансу-владельцу сессии (pub/sub)
+type Message struct {
+	Kind    string    `json:"kind"`
+	UserID  int       `json:"user_id"`
+	Path    string    `json:"path,omitempty"`
+	Body    []byte    `json:"body,omitempty"`
+	EventAt time.Time `json:"event_at,omitzero"` // Момент перехвата mirror запроса (точка отсчета latency)
+}
+
+// MirrorToken - владелец токена mirror режима
+type MirrorToken struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// Registry - общее между инстансами web-app состояние: блокировки сессий, токены mirror режима
+// и доставка сообщений инстансу-владельцу сессии. Local - один инстанс (состояние в памяти),
+// Redis - горизонтальное масштабирование
+type Registry interface {
+	// Instance возвращает ID текущего инстанса
+	Instance() string
+
+	// Lock захватывает блокировку key со значением value на LockTTL и продлевает ее, пока не вызван Unlock.
+	// Повторный захват тем же инстансом с тем же value продлевает блокировку.
+	// ErrLockHeld - блокировку держит другой инстанс (или этот с другим value)
+	Lock(ctx context.Context, key, value string) error
+	// Unlock освобождает блокировку, если ее держит текущий инстанс
+	Unlock(ctx context.Context, key string) error
+	// Holder возвращает value блокировки (false - блокировка свободна)
+	Holder(ctx context.Context, key string) (string, bool, error)
+
+	// MirrorToken возвращает токен mirror режима пользователя, создавая его при отсутствии
+	MirrorToken(ctx context.Context, userID int, username string) (string, error)
+	// LookupMirrorToken возвращает владельца токена (false - неизвестный токен)
+	LookupMirrorToken(ctx context.Context, token string) (MirrorToken, bool, error)
+
+	// Publish отправляет сообщение инстансу instance
+	Publish(ctx context.Context, instance string, msg Message) error
+	// Subscribe возвращает сообщения для текущего инстанса до отмены ctx, включая KindSessionLost
+	// о потерянных блокировках сессий
+	Subscribe(ctx context.Context) (<-chan Message, error)
+
+	// Run продлевает удерживаемые блокировки до отмены ctx. О потерянной блокировке сессии
+	// сообщает через Subscribe (KindSessionLost)
+	Run(ctx context.Context)
+	Close() error
+}
+
+// NewInstanceID возвращает ID инстанса: hostname со случайным суффиксом
+// (перезапущенный инстанс не подхватывает блокировки предыдущего процесса)
+func NewInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "instance"
+	}
+
+	return fmt.Sprintf("%s-%s", host, randomHex(4))
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RunLeader выполняет run, пока текущий инстанс удерживает блокировку лидера name:
+// фоновые задачи (алерты, отчеты, снимки баланса) выполняются одним инстансом.
+// Если лидерство потеряно, ctx run отменяется, и инстанс снова ждет блокировку
+func RunLeader(ctx context.Context, registry Registry, name string, run func(ctx context.Context)) {
+	key := "leader:" + name
+
+	for {
+		if err := registry.Lock(ctx, key, registry.Instance()); err == nil {
+			leadCtx, cancel := context.WithCancel(ctx)
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				run(leadCtx)
+			}()
+
+			holdLeadership(leadCtx, registry, key)
+			cancel()
+			<-done
+			_ = registry.Unlock(context.WithoutCancel(ctx), key)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(refreshInterval):
+		}
+	}
+}
+
+// holdLeadership продлевает блокировку лидера до отмены ctx или ее потери
+func holdLeadership(ctx context.Context, registry Registry, key string) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(refreshInterval):
+		}
+
+		if err := registry.Lock(ctx, key, registry.Instance()); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+)
+
+// Local - реестр одного инстанса: состояние в памяти процесса (REDIS_URL не задан)
+type Local struct {
+	instance string
+
+	mu       sync.Mutex
+	locks    map[string]string // key -> value
+	tokens   map[string]MirrorToken
+	byUser   map[int]string // userID -> token
+	messages chan Message
+}
+
+// NewLocal создает реестр одного инстанса
+func NewLocal() *Local {
+	return &Local{
+		instance: NewInstanceID(),
+		locks:    make(map[string]string),
+		tokens:   make(map[string]MirrorToken),
+		byUser:   make(map[int]string),
+		messages: make(chan Message, 100),
+	}
+}
+
+func (l *Local) Instance() string {
+	return l.instance
+}
+
+func (l *Local) Lock(_ context.Context, key, value string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if held, ok := l.locks[key]; ok && held != value {
+		return ErrLockHeld
+	}
+	l.locks[key] = value
+
+	return nil
+}
+
+func (l *Local) Unlock(_ context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.locks, key)
+
+	return nil
+}
+
+func (l *Local) Holder(_ context.Context, key string) (string, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	value, ok := l.locks[key]
+
+	return value, ok, nil
+}
+
+func (l *Local) MirrorToken(_ context.Context, userID int, username string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if token, ok := l.byUser[userID]; ok {
+		return token, nil
+	}
+
+	token := randomHex(16)
+	l.tokens[token] = MirrorToken{UserID: userID, Username: username}
+	l.byUser[userID] = token
+
+	return token, nil
+}
+
+func (l *Local) LookupMirrorToken(_ context.Context, token string) (MirrorToken, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	mt, ok := l.tokens[token]
+
+	return mt, ok, nil
+}
+
+func (l *Local) Publish(ctx context.Context, _ string, msg Message) error {
+	select {
+	case l.messages <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *Local) Subscribe(_ context.Context) (<-chan Message, error) {
+	return l.messages, nil
+}
+
+func (l *Local) Run(context.Context) {}
+
+func (l *Local) Close() error {
+	return nil
+}
@@ -0,0 +1,328 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// keyPrefix - префикс ключей и каналов в Redis
+const keyPrefix = "mex:"
+
+// lockScript захватывает блокировку или продлевает ее, если значение совпадает
+var lockScript = redis.NewScript(`
+local held = redis.call('GET', KEYS[1])
+if not held then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+if held == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// refreshScript продлевает блокировку, только если значение совпадает (не захватывает свободную)
+var refreshScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// unlockScript удаляет блокировку, только если значение совпадает
+var unlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Redis - реестр, общий для всех инстансов web-app
+type Redis struct {
+	client   *redis.Client
+	instance string
+	logger   *slog.Logger
+
+	mu        sync.Mutex
+	held      map[string]string    // Удерживаемые этим инстансом блокировки: key -> value
+	refreshed map[string]time.Time // Последнее успешное продление блокировки: key -> время
+	lost      chan Message         // KindSessionLost для Subscribe
+}
+
+// NewRedis подключается к Redis по URL (redis://[:password@]host:port/db)
+func NewRedis(ctx context.Context, url, instance string, logger *slog.Logger) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	if instance == "" {
+		instance = NewInstanceID()
+	}
+
+	return &Redis{
+		client:    client,
+		instance:  instance,
+		logger:    logger,
+		held:      make(map[string]string),
+		refreshed: make(map[string]time.Time),
+		lost:      make(chan Message, 100),
+	}, nil
+}
+
+func (r *Redis) Instance() string {
+	return r.instance
+}
+
+func (r *Redis) Lock(ctx context.Context, key, value string) error {
+	ok, err := lockScript.Run(ctx, r.client, []string{keyPrefix + key}, value, LockTTL.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("failed to acquire lock %s: %w", key, err)
+	}
+	if ok == 0 {
+		return ErrLockHeld
+	}
+
+	r.mu.Lock()
+	r.held[key] = value
+	r.refreshed[key] = time.Now()
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *Redis) Unlock(ctx context.Context, key string) error {
+	r.mu.Lock()
+	value, ok := r.held[key]
+	delete(r.held, key)
+	delete(r.refreshed, key)
+	r.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	if err := unlockScript.Run(ctx, r.client, []string{keyPrefix + key}, value).Err(); err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (r *Redis) Holder(ctx context.Context, key string) (string, bool, error) {
+	value, err := r.client.Get(ctx, keyPrefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return value, true, nil
+}
+
+func (r *Redis) MirrorToken(ctx context.Context, userID int, username string) (string, error) {
+	userKey := keyPrefix + "mirror:user:" + strconv.Itoa(userID)
+
+	token, err := r.client.Get(ctx, userKey).Result()
+	if err == nil {
+		return token, nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return "", err
+	}
+
+	payload, err := json.Marshal(MirrorToken{UserID: userID, Username: username})
+	if err != nil {
+		return "", err
+	}
+
+	// Токен пишется до привязки к пользователю: LookupMirrorToken не увидит привязку без токена
+	token = randomHex(16)
+	if err := r.client.Set(ctx, keyPrefix+"mirror:token:"+token, payload, 0).Err(); err != nil {
+		return "", err
+	}
+
+	created, err := r.client.SetNX(ctx, userKey, token, 0).Result()
+	if err != nil {
+		return "", err
+	}
+	if created {
+		return token, nil
+	}
+
+	// Другой инстанс создал токен одновременно - используем его
+	r.client.Del(ctx, keyPrefix+"mirror:token:"+token)
+
+	return r.client.Get(ctx, userKey).Result()
+}
+
+func (r *Redis) LookupMirrorToken(ctx context.Context, token string) (MirrorToken, bool, error) {
+	payload, err := r.client.Get(ctx, keyPrefix+"mirror:token:"+token).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return MirrorToken{}, false, nil
+	}
+	if err != nil {
+		return MirrorToken{}, false, err
+	}
+
+	var mt MirrorToken
+	if err := json.Unmarshal(payload, &mt); err != nil {
+		return MirrorToken{}, false, fmt.Errorf("invalid mirror token payload: %w", err)
+	}
+
+	return mt, true, nil
+}
+
+func (r *Redis) Publish(ctx context.Context, instance string, msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return r.client.Publish(ctx, channel(instance), payload).Err()
+}
+
+func (r *Redis) Subscribe(ctx context.Context) (<-chan Message, error) {
+	pubsub := r.client.Subscribe(ctx, channel(r.instance))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	// go-redis переподключает подписку сам; канал закрывается при отмене ctx
+	messages := make(chan Message, 100)
+	go func() {
+		defer close(messages)
+		defer pubsub.Close()
+
+		incoming := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-r.lost:
+				select {
+				case messages <- msg:
+				case <-ctx.Done():
+					return
+				}
+			case raw, ok := <-incoming:
+				if !ok {
+					return
+				}
+
+				var msg Message
+				if err := json.Unmarshal([]byte(raw.Payload), &msg); err != nil {
+					r.logger.Warn("Invalid cluster message", slog.Any("error", err))
+					continue
+				}
+
+				select {
+				case messages <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return messages, nil
+}
+
+// Run продлевает удерживаемые блокировки каждые refreshInterval. Блокировку, перехваченную другим
+// инстансом или не продленную до истечения LockTTL (Redis недоступен), инстанс отпускает: для блокировки
+// сессии Subscribe получает KindSessionLost, и локальная сессия останавливается
+func (r *Redis) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(refreshInterval):
+		}
+
+		r.mu.Lock()
+		held := make(map[string]string, len(r.held))
+		for key, value := range r.held {
+			held[key] = value
+		}
+		r.mu.Unlock()
+
+		for key, value := range held {
+			ok, err := refreshScript.Run(ctx, r.client, []string{keyPrefix + key}, value, LockTTL.Milliseconds()).Int()
+			if err != nil {
+				r.logger.Warn("Failed to refresh lock", slog.String("key", key), slog.Any("error", err))
+
+				// До следующей попытки блокировка истечет, и ее сможет захватить другой инстанс
+				r.mu.Lock()
+				expiring := r.held[key] == value && time.Since(r.refreshed[key]) >= LockTTL-refreshInterval
+				r.mu.Unlock()
+				if expiring {
+					r.release(key, value, "Lock expired without refresh")
+				}
+				continue
+			}
+			if ok == 1 {
+				r.mu.Lock()
+				if r.held[key] == value {
+					r.refreshed[key] = time.Now()
+				}
+				r.mu.Unlock()
+				continue
+			}
+
+			r.release(key, value, "Lock lost to another instance")
+		}
+	}
+}
+
+// release забывает потерянную блокировку key и сообщает о потере блокировки сессии через Subscribe
+func (r *Redis) release(key, value, reason string) {
+	r.mu.Lock()
+	lost := r.held[key] == value
+	if lost {
+		delete(r.held, key)
+		delete(r.refreshed, key)
+	}
+	r.mu.Unlock()
+
+	if !lost {
+		return
+	}
+
+	r.logger.Error(reason, slog.String("key", key))
+
+	userID, ok := sessionUserID(key)
+	if !ok {
+		return
+	}
+
+	select {
+	case r.lost <- Message{Kind: KindSessionLost, UserID: userID}:
+	default:
+		r.logger.Error("Session lost queue is full", slog.Int("user_id", userID))
+	}
+}
+
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+func channel(instance string) string {
+	return keyPrefix + "instance:" + instance
+}
@@ -0,0 +1,92 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SessionOwner - инстанс, на котором работает сессия copy trading пользователя.
+// Хранится в блокировке session:<userID> как <instance>|<mode>|<started unix ms>
+type SessionOwner struct {
+	Instance  string
+	Mode      string
+	StartedAt time.Time
+}
+
+func sessionKey(userID int) string {
+	return "session:" + strconv.Itoa(userID)
+}
+
+// sessionUserID возвращает пользователя блокировки сессии (false - key не блокировка сессии)
+func sessionUserID(key string) (int, bool) {
+	id, ok := strings.CutPrefix(key, "session:")
+	if !ok {
+		return 0, false
+	}
+
+	userID, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, false
+	}
+
+	return userID, true
+}
+
+// LockSession закрепляет сессию пользователя в режиме mode за текущим инстансом
+// (ErrLockHeld - сессия работает на другом инстансе или в другом режиме)
+func LockSession(ctx context.Context, registry Registry, userID int, mode string, startedAt time.Time) error {
+	value := fmt.Sprintf("%s|%s|%d", registry.Instance(), mode, startedAt.UnixMilli())
+	return registry.Lock(ctx, sessionKey(userID), value)
+}
+
+// UnlockSession освобождает сессию пользователя, если она закреплена за текущим инстансом
+func UnlockSession(ctx context.Context, registry Registry, userID int) error {
+	return registry.Unlock(ctx, sessionKey(userID))
+}
+
+// LookupSession возвращает владельца сессии пользователя (false - сессии нет ни на одном инстансе)
+func LookupSession(ctx context.Context, registry Registry, userID int) (SessionOwner, bool, error) {
+	value, ok, err := registry.Holder(ctx, sessionKey(userID))
+	if err != nil || !ok {
+		return SessionOwner{}, false, err
+	}
+
+	parts := strings.Split(value, "|")
+	if len(parts) != 3 {
+		return SessionOwner{}, false, fmt.Errorf("invalid session lock value %q", value)
+	}
+
+	startedMs, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return SessionOwner{}, false, fmt.Errorf("invalid session lock value %q", value)
+	}
+
+	return SessionOwner{
+		Instance:  parts[0],
+		Mode:      parts[1],
+		StartedAt: time.UnixMilli(startedMs),
+	}, true, nil
+}
+
+// Sessions - активные сессии всех инстансов web-app (для алертов, которые считает лидер)
+type Sessions struct {
+	registry Registry
+}
+
+// NewSessions создает источник активных сессий по реестру
+func NewSessions(registry Registry) *Sessions {
+	return &Sessions{registry: registry}
+}
+
+// ActiveSince возвращает время запуска сессии пользователя на любом инстансе
+func (s *Sessions) ActiveSince(userID int) (time.Time, bool) {
+	owner, ok, err := LookupSession(context.Background(), s.registry, userID)
+	if err != nil || !ok {
+		return time.Time{}, false
+	}
+
+	return owner.StartedAt, true
+}
@@ -1,22 +1,198 @@
 package config
 
 import (
+	"cmp"
+	"fmt"
 	"log/slog"
+	"math"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Config содержит конфигурацию приложения
 type Config struct {
 	TelegramToken string
+	TelegramBot   string // Username бота для Telegram Login Widget (без @)
 	DBPath        string
 	DryRun        bool // Режим тестирования - только логирование, без реальных сделок
 	JWTSecret     string
-	APIURL        string
+	JWTIssuer     string
+	JWTAudience   string
+	JWTAccessTTL  time.Duration
+	JWTClockSkew  time.Duration
+
+	RefreshTokenTTL    time.Duration // Скользящее время жизни refresh token
+	SessionMaxLifetime time.Duration // Абсолютное время жизни сессии
+	APIURL             string
 
 	// Webhook configuration
 	WebhookURL  string // URL для webhook (e.g., https://tg.example.com/webhook)
 	WebhookPath string // Path для webhook endpoint (e.g., /webhook)
 	Address     string // Address для HTTP сервера (e.g., 0.0.0.0:8080)
+
+	// FeatureFlags - флаги, включенные глобально (e.g., proportional_sizing)
+	FeatureFlags []string
+
+	// Защита входа от перебора паролей
+	LoginMaxFailures     int           // Неудачных попыток на username до блокировки
+	LoginMaxIPFailures   int           // Неудачных попыток с одного IP до блокировки
+	LoginFailureWindow   time.Duration // Окно подсчета неудачных попыток
+	LoginLockoutDuration time.Duration // Длительность блокировки
+
+	// Максимальный возраст auth_date у Telegram Login Widget
+	TelegramLoginMaxAge time.Duration
+
+	// OpenID Connect SSO (включается, если задан OIDCIssuerURL)
+	OIDCIssuerURL      string
+	OIDCClientID       string
+	OIDCClientSecret   string
+	OIDCRedirectURL    string
+	OIDCScopes         []string
+	OIDCUsernameClaim  string
+	OIDCLinkByUsername bool
+
+	// Первый администратор (создается при старте, если администраторов нет)
+	AdminUsername string
+	AdminPassword string
+
+	// RegistrationEnabled - открытая регистрация через /api/auth/register
+	RegistrationEnabled bool
+
+	// TrustedProxies - reverse proxy, которым разрешено передавать IP клиента (X-Forwarded-For, X-Real-IP)
+	TrustedProxies []netip.Prefix
+
+	// SMTP для email (подтверждение, сброс пароля, критические уведомления)
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Снимки баланса для кривой equity (0 отключает)
+	EquitySnapshotInterval  time.Duration
+	EquitySnapshotRetention time.Duration
+
+	// Период синхронизации funding платежей (0 отключает)
+	FundingSyncInterval time.Duration
+
+	// Период проверки правил алертов (0 отключает)
+	AlertEvalInterval time.Duration
+
+	// Период проверки сессий (uc_token) аккаунтов (0 отключает)
+	SessionCheckInterval time.Duration
+
+	// Период проверки прокси из пулов аккаунтов (0 отключает)
+	ProxyHealthInterval time.Duration
+
+	// Интервал синхронизации времени сервера MEXC для подписей (0 отключает)
+	MexcTimeSyncInterval time.Duration
+
+	// Час рассылки отчетов по подпискам (UTC, -1 отключает)
+	ReportHour int
+
+	// Таймауты операций copy trading по типу; бюджет fan-out = таймаут + CopyTimeoutPerSlave на каждый slave
+	CopyTimeoutOpen      time.Duration
+	CopyTimeoutClose     time.Duration
+	CopyTimeoutStopOrder time.Duration
+	CopyTimeoutLeverage  time.Duration
+	CopyTimeoutPerSlave  time.Duration
+
+	// Допустимое проскальзывание market входа по стакану для суммарного объёма slave, % (0 - стакан не проверяется)
+	CopyMaxSlippage float64
+
+	// Объём входа slave: fixed - объём мастера, proportional - объём мастера × equity slave / equity мастера
+	CopySizingMode string
+
+	// Дневной реализованный убыток slave аккаунта (UTC), USDT (0 - без ограничения) и действие при его превышении:
+	// block - входы на slave не копируются до конца дня, halt - сессия copy trading пользователя останавливается
+	CopyDailyLossLimit  float64
+	CopyDailyLossAction string
+
+	// Очередь повторов копий slave с временной ошибкой (rate limit, таймаут до отправки ордера):
+	// повторов на копию (0 - без повторов), пауза перед первым (дальше вдвое больше) и предельный возраст копии
+	CopyRetryMaxAttempts int
+	CopyRetryBaseDelay   time.Duration
+	CopyRetryMaxAge      time.Duration
+
+	// Защита копируемых market ордеров: priceProtect, marketCeiling и граница цены от цены мастера, % (0 - без границы)
+	CopyPriceProtect  bool
+	CopyMarketCeiling bool
+	CopySlippageLimit float64
+
+	// Подтверждение исполнения slave по fill'ам их WebSocket соединений (цена и комиссия в trade_details)
+	CopySlaveFillWS bool
+
+	// Таймаут команды Telegram бота
+	BotCommandTimeout time.Duration
+
+	// BackupAllowPlaintext - /backup без пароля отправляет снимок базы незашифрованным
+	BackupAllowPlaintext bool
+
+	// Лимит запросов к MEXC на аккаунт (token bucket): запросов в секунду и размер пачки (0 отключает)
+	MexcRateLimitRPS   float64
+	MexcRateLimitBurst int
+
+	// Соединения MEXC клиентов: keep-alive соединений на хост, HTTP/2, кэш TLS сессий (0 отключает resumption)
+	MexcMaxIdleConnsPerHost int
+	MexcHTTP2               bool
+	MexcTLSSessionCache     int
+
+	// Время жизни кэша комиссий аккаунта в MEXC клиенте (0 отключает кэш)
+	MexcFeeRateCacheTTL time.Duration
+
+	// Окно ожидания pong WebSocket: без ответа дольше соединение переподключается (0 отключает watchdog)
+	MexcWSPongTimeout time.Duration
+
+	// Сжатие WebSocket: permessage-deflate и gzip данных MEXC (меньше трафика при многих соединениях)
+	MexcWSCompression bool
+
+	// Фильтр приватных каналов WebSocket после login: биржа присылает только каналы с обработчиками
+	MexcWSPersonalFilter bool
+
+	// Очередь событий WebSocket клиента перед обработчиками: размер и политика переполнения
+	// (block, drop-oldest, coalesce)
+	MexcWSQueueSize   int
+	MexcWSQueuePolicy string
+
+	// Окно матчинга order и stop order событий мастера (0 - без матчинга)
+	CopyStopMatchWindow time.Duration
+
+	// Алерт о падении equity master аккаунта по событиям счета WebSocket: % от максимума за окно (0 отключает)
+	MasterBalanceDropPct    float64
+	MasterBalanceDropWindow time.Duration
+
+	// Запись сырых push.personal.* сообщений WebSocket: в файл JSON Lines (путь) и / или в таблицу ws_frames
+	WSRecordFile string
+	WSRecordDB   bool
+
+	// Адреса MEXC (пусто - mexc.com). Sandbox направляет клиентов на локальный mock сервер (cmd/mexc-sandbox)
+	MexcBaseURL     string
+	MexcWSURL       string
+	MexcSandbox     bool
+	MexcSandboxAddr string
+
+	// Горизонтальное масштабирование web-app: общий реестр сессий в Redis (пусто - один инстанс)
+	RedisURL   string
+	InstanceID string // Пусто - hostname со случайным суффиксом
+
+	// Сверка позиций slave с master: период (0 отключает) и сколько расхождение держится до уведомления,
+	// исправление без подтверждения и допустимое отклонение объёма slave, % (0 - объём не сверяется)
+	ReconcileInterval      time.Duration
+	ReconcileGrace         time.Duration
+	ReconcileAutoFix       bool
+	ReconcileSizeTolerance float64
+
+	// Лимиты концентрации суммарной экспозиции по символу и направлению (0 отключает)
+	ExposureMaxNotional float64 // USDT
+	ExposureMaxShare    float64 // % от общей экспозиции
+
+	// Параметры Argon2id для хеширования паролей
+	Argon2Memory      int // Память в KiB
+	Argon2Iterations  int
+	Argon2Parallelism int
 }
 
 // Load загружает конфигурацию из переменных окружения
@@ -37,6 +213,20 @@ func Load(logger *slog.Logger) *Config {
 		logger.Info("🔍 DRY_RUN enabled - only logging, no real trades")
 	}
 
+	// Sandbox: адреса MEXC по умолчанию - локальный mock сервер
+	mexcSandbox := os.Getenv("MEXC_SANDBOX") == "true"
+	mexcSandboxAddr := cmp.Or(os.Getenv("MEXC_SANDBOX_ADDR"), "127.0.0.1:8090")
+	mexcBaseURL := os.Getenv("MEXC_BASE_URL")
+	mexcWSURL := os.Getenv("MEXC_WS_URL")
+	if mexcSandbox {
+		mexcBaseURL = cmp.Or(mexcBaseURL, "http://"+mexcSandboxAddr)
+		mexcWSURL = cmp.Or(mexcWSURL, "ws://"+mexcSandboxAddr+"/edge")
+
+		logger.Warn("🧪 MEXC sandbox mode - requests go to the mock server",
+			slog.String("base_url", mexcBaseURL),
+			slog.String("ws_url", mexcWSURL))
+	}
+
 	// Webhook configuration
 	webhookURL := os.Getenv("WEBHOOK_URL")
 	webhookPath := os.Getenv("WEBHOOK_PATH")
@@ -51,6 +241,21 @@ func Load(logger *slog.Logger) *Config {
 		logger.Warn("⚠️  JWT_SECRET not set, using default (insecure!)")
 	}
 
+	jwtIssuer := os.Getenv("JWT_ISSUER")
+	if jwtIssuer == "" {
+		jwtIssuer = "tg_mexc"
+	}
+
+	jwtAudience := os.Getenv("JWT_AUDIENCE")
+	if jwtAudience == "" {
+		jwtAudience = "tg_mexc-web"
+	}
+
+	jwtAccessTTL := getEnvDuration(logger, "JWT_ACCESS_TTL", 15*time.Minute)
+	jwtClockSkew := getEnvDuration(logger, "JWT_CLOCK_SKEW", 30*time.Second)
+	refreshTokenTTL := getEnvDuration(logger, "REFRESH_TOKEN_TTL", 7*24*time.Hour)
+	sessionMaxLifetime := getEnvDuration(logger, "SESSION_MAX_LIFETIME", 30*24*time.Hour)
+
 	// API URL для frontend и mirror скрипта
 	apiURL := os.Getenv("API_URL")
 	if apiURL == "" {
@@ -67,6 +272,60 @@ func Load(logger *slog.Logger) *Config {
 		dbPath = "./mexc.db"
 	}
 
+	featureFlags := splitList(os.Getenv("FEATURE_FLAGS"))
+
+	loginMaxFailures := getEnvInt(logger, "LOGIN_MAX_FAILURES", 5)
+	loginMaxIPFailures := getEnvInt(logger, "LOGIN_MAX_IP_FAILURES", 20)
+	loginFailureWindow := getEnvDuration(logger, "LOGIN_FAILURE_WINDOW", 15*time.Minute)
+	loginLockoutDuration := getEnvDuration(logger, "LOGIN_LOCKOUT_DURATION", 15*time.Minute)
+
+	telegramBot := strings.TrimPrefix(os.Getenv("TELEGRAM_BOT_USERNAME"), "@")
+	telegramLoginMaxAge := getEnvDuration(logger, "TELEGRAM_LOGIN_MAX_AGE", 24*time.Hour)
+
+	oidcRedirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if oidcRedirectURL == "" {
+		oidcRedirectURL = strings.TrimSuffix(apiURL, "/") + "/api/auth/oidc/callback"
+	}
+
+	oidcScopes := splitList(os.Getenv("OIDC_SCOPES"))
+	if len(oidcScopes) == 0 {
+		oidcScopes = []string{"profile", "email"}
+	}
+
+	oidcUsernameClaim := os.Getenv("OIDC_USERNAME_CLAIM")
+	if oidcUsernameClaim == "" {
+		oidcUsernameClaim = "preferred_username"
+	}
+
+	adminPassword := os.Getenv("ADMIN_PASSWORD")
+	if passwordFile := os.Getenv("ADMIN_PASSWORD_FILE"); passwordFile != "" {
+		data, err := os.ReadFile(passwordFile)
+		if err != nil {
+			logger.Error("❌ Failed to read ADMIN_PASSWORD_FILE", slog.Any("error", err))
+			os.Exit(1)
+		}
+		adminPassword = strings.TrimRight(string(data), "\r\n")
+	}
+
+	registrationEnabled := os.Getenv("REGISTRATION_ENABLED") != "false"
+	if !registrationEnabled {
+		logger.Info("🔒 Open registration disabled")
+	}
+
+	trustedProxies, err := parsePrefixes(splitList(os.Getenv("TRUSTED_PROXIES")))
+	if err != nil {
+		logger.Error("❌ Invalid TRUSTED_PROXIES", slog.Any("error", err))
+		os.Exit(1)
+	}
+
+	argon2Memory := getEnvInt(logger, "ARGON2_MEMORY_KIB", 64*1024)
+	argon2Iterations := getEnvInt(logger, "ARGON2_ITERATIONS", 3)
+	argon2Parallelism := getEnvInt(logger, "ARGON2_PARALLELISM", 2)
+	if err := validateArgon2(argon2Memory, argon2Iterations, argon2Parallelism); err != nil {
+		logger.Error("❌ Invalid Argon2 params", slog.Any("error", err))
+		os.Exit(1)
+	}
+
 	if webhookURL != "" {
 		logger.Info("🔗 Webhook mode enabled", slog.String("url", webhookURL))
 	} else {
@@ -75,12 +334,230 @@ func Load(logger *slog.Logger) *Config {
 
 	return &Config{
 		TelegramToken: token,
+		TelegramBot:   telegramBot,
 		DBPath:        dbPath,
 		JWTSecret:     jwtSecret,
-		APIURL:        apiURL,
-		DryRun:        dryRun,
-		WebhookURL:    webhookURL,
-		WebhookPath:   webhookPath,
-		Address:       address,
+		JWTIssuer:     jwtIssuer,
+		JWTAudience:   jwtAudience,
+		JWTAccessTTL:  jwtAccessTTL,
+		JWTClockSkew:  jwtClockSkew,
+
+		RefreshTokenTTL:    refreshTokenTTL,
+		SessionMaxLifetime: sessionMaxLifetime,
+		APIURL:             apiURL,
+		DryRun:             dryRun,
+		WebhookURL:         webhookURL,
+		WebhookPath:        webhookPath,
+		Address:            address,
+		FeatureFlags:       featureFlags,
+
+		LoginMaxFailures:     loginMaxFailures,
+		LoginMaxIPFailures:   loginMaxIPFailures,
+		LoginFailureWindow:   loginFailureWindow,
+		LoginLockoutDuration: loginLockoutDuration,
+
+		TelegramLoginMaxAge: telegramLoginMaxAge,
+
+		OIDCIssuerURL:      os.Getenv("OIDC_ISSUER_URL"),
+		OIDCClientID:       os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret:   os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:    oidcRedirectURL,
+		OIDCScopes:         oidcScopes,
+		OIDCUsernameClaim:  oidcUsernameClaim,
+		OIDCLinkByUsername: os.Getenv("OIDC_LINK_BY_USERNAME") == "true",
+
+		AdminUsername:       os.Getenv("ADMIN_USERNAME"),
+		AdminPassword:       adminPassword,
+		RegistrationEnabled: registrationEnabled,
+		TrustedProxies:      trustedProxies,
+
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPPort:     getEnvInt(logger, "SMTP_PORT", 587),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:     os.Getenv("SMTP_FROM"),
+
+		EquitySnapshotInterval:  getEnvDuration(logger, "EQUITY_SNAPSHOT_INTERVAL", time.Hour),
+		EquitySnapshotRetention: getEnvDuration(logger, "EQUITY_SNAPSHOT_RETENTION", 365*24*time.Hour),
+
+		FundingSyncInterval: getEnvDuration(logger, "FUNDING_SYNC_INTERVAL", time.Hour),
+
+		AlertEvalInterval: getEnvDuration(logger, "ALERT_EVAL_INTERVAL", time.Minute),
+
+		SessionCheckInterval: getEnvDuration(logger, "SESSION_CHECK_INTERVAL", 30*time.Minute),
+
+		ProxyHealthInterval: getEnvDuration(logger, "PROXY_HEALTH_INTERVAL", 5*time.Minute),
+
+		MexcTimeSyncInterval: getEnvDuration(logger, "MEXC_TIME_SYNC_INTERVAL", 5*time.Minute),
+
+		ReportHour: getEnvInt(logger, "REPORT_HOUR", 8),
+
+		CopyTimeoutOpen:      getEnvDuration(logger, "COPY_TIMEOUT_OPEN", 10*time.Second),
+		CopyTimeoutClose:     getEnvDuration(logger, "COPY_TIMEOUT_CLOSE", 10*time.Second),
+		CopyTimeoutStopOrder: getEnvDuration(logger, "COPY_TIMEOUT_STOP_ORDER", 10*time.Second),
+		CopyTimeoutLeverage:  getEnvDuration(logger, "COPY_TIMEOUT_LEVERAGE", 10*time.Second),
+		CopyTimeoutPerSlave:  getEnvDuration(logger, "COPY_TIMEOUT_PER_SLAVE", 500*time.Millisecond),
+
+		CopyMaxSlippage: getEnvFloat(logger, "COPY_MAX_SLIPPAGE", 0),
+		CopySizingMode:  cmp.Or(os.Getenv("COPY_SIZING_MODE"), "fixed"),
+
+		CopyDailyLossLimit:  getEnvFloat(logger, "COPY_DAILY_LOSS_LIMIT", 0),
+		CopyDailyLossAction: cmp.Or(os.Getenv("COPY_DAILY_LOSS_ACTION"), "block"),
+
+		CopyRetryMaxAttempts: getEnvInt(logger, "COPY_RETRY_MAX_ATTEMPTS", 3),
+		CopyRetryBaseDelay:   getEnvDuration(logger, "COPY_RETRY_BASE_DELAY", time.Second),
+		CopyRetryMaxAge:      getEnvDuration(logger, "COPY_RETRY_MAX_AGE", 30*time.Second),
+
+		CopyPriceProtect:  os.Getenv("COPY_PRICE_PROTECT") == "true",
+		CopyMarketCeiling: os.Getenv("COPY_MARKET_CEILING") == "true",
+		CopySlippageLimit: getEnvFloat(logger, "COPY_SLIPPAGE_LIMIT", 0),
+		CopySlaveFillWS:   os.Getenv("COPY_SLAVE_FILL_WS") == "true",
+
+		BotCommandTimeout:    getEnvDuration(logger, "BOT_COMMAND_TIMEOUT", 15*time.Second),
+		BackupAllowPlaintext: os.Getenv("BACKUP_ALLOW_PLAINTEXT") == "true",
+
+		MexcRateLimitRPS:   getEnvFloat(logger, "MEXC_RATE_LIMIT_RPS", 10),
+		MexcRateLimitBurst: getEnvInt(logger, "MEXC_RATE_LIMIT_BURST", 20),
+
+		MexcMaxIdleConnsPerHost: getEnvInt(logger, "MEXC_MAX_IDLE_CONNS_PER_HOST", 10),
+		MexcHTTP2:               os.Getenv("MEXC_HTTP2") != "false",
+		MexcTLSSessionCache:     getEnvInt(logger, "MEXC_TLS_SESSION_CACHE", 64),
+
+		MexcFeeRateCacheTTL: getEnvDuration(logger, "MEXC_FEE_RATE_CACHE_TTL", 5*time.Minute),
+
+		MexcWSPongTimeout:    getEnvDuration(logger, "MEXC_WS_PONG_TIMEOUT", 45*time.Second),
+		MexcWSCompression:    os.Getenv("MEXC_WS_COMPRESSION") == "true",
+		MexcWSPersonalFilter: os.Getenv("MEXC_WS_PERSONAL_FILTER") != "false",
+		MexcWSQueueSize:      getEnvInt(logger, "MEXC_WS_QUEUE_SIZE", 1024),
+		MexcWSQueuePolicy:    cmp.Or(os.Getenv("MEXC_WS_QUEUE_POLICY"), "block"),
+
+		CopyStopMatchWindow: getEnvDuration(logger, "COPY_STOP_MATCH_WINDOW", time.Second),
+
+		MasterBalanceDropPct:    getEnvFloat(logger, "MASTER_BALANCE_DROP_PCT", 20),
+		MasterBalanceDropWindow: getEnvDuration(logger, "MASTER_BALANCE_DROP_WINDOW", time.Hour),
+
+		WSRecordFile: os.Getenv("WS_RECORD_FILE"),
+		WSRecordDB:   os.Getenv("WS_RECORD_DB") == "true",
+
+		MexcBaseURL:     mexcBaseURL,
+		MexcWSURL:       mexcWSURL,
+		MexcSandbox:     mexcSandbox,
+		MexcSandboxAddr: mexcSandboxAddr,
+
+		RedisURL:   os.Getenv("REDIS_URL"),
+		InstanceID: os.Getenv("INSTANCE_ID"),
+
+		ReconcileInterval:      getEnvDuration(logger, "RECONCILE_INTERVAL", time.Minute),
+		ReconcileGrace:         getEnvDuration(logger, "RECONCILE_GRACE", 2*time.Minute),
+		ReconcileAutoFix:       os.Getenv("RECONCILE_AUTO_FIX") == "true",
+		ReconcileSizeTolerance: getEnvFloat(logger, "RECONCILE_SIZE_TOLERANCE", 10),
+
+		ExposureMaxNotional: getEnvFloat(logger, "EXPOSURE_MAX_NOTIONAL", 0),
+		ExposureMaxShare:    getEnvFloat(logger, "EXPOSURE_MAX_SHARE", 50),
+
+		Argon2Memory:      argon2Memory,
+		Argon2Iterations:  argon2Iterations,
+		Argon2Parallelism: argon2Parallelism,
 	}
 }
+
+// splitList разбирает список через запятую, пропуская пустые элементы
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// validateArgon2 проверяет параметры Argon2id: при недопустимых значениях argon2.IDKey паникует (iterations
+// или parallelism 0) или молча считает хеш с другими параметрами (переполнение uint32/uint8, memory < 8*parallelism)
+func validateArgon2(memory, iterations, parallelism int) error {
+	if iterations < 1 || iterations > math.MaxUint32 {
+		return fmt.Errorf("ARGON2_ITERATIONS must be between 1 and %d, got %d", uint32(math.MaxUint32), iterations)
+	}
+	if parallelism < 1 || parallelism > math.MaxUint8 {
+		return fmt.Errorf("ARGON2_PARALLELISM must be between 1 and %d, got %d", math.MaxUint8, parallelism)
+	}
+	if memory < 8*parallelism || memory > math.MaxUint32 {
+		return fmt.Errorf("ARGON2_MEMORY_KIB must be between 8 × ARGON2_PARALLELISM (%d) and %d, got %d",
+			8*parallelism, uint32(math.MaxUint32), memory)
+	}
+
+	return nil
+}
+
+// parsePrefixes разбирает список подсетей (CIDR) и адресов (подсеть из одного адреса)
+func parsePrefixes(items []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(items))
+	for _, item := range items {
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, err
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// getEnvInt читает целое число из переменной окружения (def если не задано или невалидно)
+func getEnvInt(logger *slog.Logger, key string, def int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		logger.Warn("⚠️  Invalid integer env, using default", slog.String("key", key), slog.Int("default", def))
+		return def
+	}
+
+	return value
+}
+
+// getEnvFloat читает число с плавающей точкой из переменной окружения (def если не задано или невалидно)
+func getEnvFloat(logger *slog.Logger, key string, def float64) float64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		logger.Warn("⚠️  Invalid number env, using default", slog.String("key", key), slog.Float64("default", def))
+		return def
+	}
+
+	return value
+}
+
+// getEnvDuration читает длительность (e.g., 15m, 1h) из переменной окружения
+func getEnvDuration(logger *slog.Logger, key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+
+	value, err := time.ParseDuration(raw)
+	if err != nil {
+		logger.Warn("⚠️  Invalid duration env, using default", slog.String("key", key), slog.Duration("default", def))
+		return def
+	}
+
+	return value
+}
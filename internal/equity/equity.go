@@ -0,0 +1,198 @@
+package equity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"tg_mexc/internal/clock"
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+)
+
+const (
+	// DefaultRange - период кривой по умолчанию
+	DefaultRange = 30 * 24 * time.Hour
+	// MaxRange - максимальный запрашиваемый период
+	MaxRange = 365 * 24 * time.Hour
+
+	snapshotTimeout = 30 * time.Second
+)
+
+// ErrInvalidRange возвращается для нераспознанного периода
+var ErrInvalidRange = errors.New("invalid range")
+
+// Storage - хранилище снимков баланса
+type Storage interface {
+	GetUserIDsWithAccounts() ([]int, error)
+	GetAccounts(userID int) ([]models.Account, error)
+	AddBalanceSnapshot(userID int, snapshot models.BalanceSnapshot) error
+	DeleteBalanceSnapshotsBefore(before time.Time) error
+	GetEquityCurve(userID int, accountID int, since time.Time) ([]models.EquityPoint, error)
+	GetSlavesEquityCurve(userID int, since time.Time) ([]models.EquityPoint, error)
+}
+
+// Service периодически снимает баланс всех аккаунтов и строит кривые equity
+type Service struct {
+	storage   Storage
+	interval  time.Duration
+	retention time.Duration
+	logger    *slog.Logger
+	clock     clock.Clock
+}
+
+// New создает сервис equity.
+// interval - период снимков (0 отключает фоновую задачу), retention - сколько хранить снимки
+func New(storage Storage, interval, retention time.Duration, logger *slog.Logger) *Service {
+	return &Service{
+		storage:   storage,
+		interval:  interval,
+		retention: retention,
+		logger:    logger,
+		clock:     clock.Real,
+	}
+}
+
+// SetClock подменяет источник времени
+func (s *Service) SetClock(clk clock.Clock) {
+	s.clock = clk
+}
+
+// Run запускает снимки баланса каждые interval до отмены ctx
+func (s *Service) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		s.logger.Info("Equity snapshots disabled")
+		return
+	}
+
+	for {
+		s.SnapshotAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.interval):
+		}
+	}
+}
+
+// SnapshotAll снимает баланс всех аккаунтов всех пользователей и удаляет устаревшие снимки
+func (s *Service) SnapshotAll(ctx context.Context) {
+	userIDs, err := s.storage.GetUserIDsWithAccounts()
+	if err != nil {
+		s.logger.Error("Failed to list users for equity snapshot", slog.Any("error", err))
+		return
+	}
+
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := s.snapshotUser(ctx, userID); err != nil {
+			s.logger.Warn("Equity snapshot finished with errors",
+				slog.Int("user_id", userID),
+				slog.Any("error", err))
+		}
+	}
+
+	if s.retention > 0 {
+		if err := s.storage.DeleteBalanceSnapshotsBefore(s.clock.Now().Add(-s.retention)); err != nil {
+			s.logger.Error("Failed to delete old equity snapshots", slog.Any("error", err))
+		}
+	}
+}
+
+func (s *Service) snapshotUser(ctx context.Context, userID int) error {
+	accounts, err := s.storage.GetAccounts(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	// Один момент времени на прогон - по нему суммируется общая кривая slave аккаунтов
+	takenAt := s.clock.Now().UTC().Truncate(time.Second)
+
+	var errs []error
+	for _, acc := range accounts {
+		snapshot, err := s.snapshotAccount(ctx, acc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", acc.Name, err))
+			continue
+		}
+
+		snapshot.TakenAt = takenAt
+		if err := s.storage.AddBalanceSnapshot(userID, snapshot); err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to save snapshot: %w", acc.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Service) snapshotAccount(ctx context.Context, acc models.Account) (models.BalanceSnapshot, error) {
+	client, err := mexc.NewClient(acc, s.logger)
+	if err != nil {
+		return models.BalanceSnapshot{}, err
+	}
+	client.SetClock(s.clock)
+
+	ctx, cancel := context.WithTimeout(ctx, snapshotTimeout)
+	defer cancel()
+
+	balances, err := client.GetBalance(ctx)
+	if err != nil {
+		return models.BalanceSnapshot{}, err
+	}
+
+	snapshot := models.BalanceSnapshot{AccountID: acc.ID}
+	for _, bal := range balances {
+		if bal.Currency == "USDT" {
+			snapshot.Equity = bal.Equity
+			snapshot.Available = bal.AvailableBalance
+		}
+	}
+
+	return snapshot, nil
+}
+
+// AccountCurve возвращает кривую equity аккаунта за период
+func (s *Service) AccountCurve(userID int, accountID int, period time.Duration) ([]models.EquityPoint, error) {
+	return s.storage.GetEquityCurve(userID, accountID, s.clock.Now().Add(-period))
+}
+
+// SlavesCurve возвращает суммарную кривую equity всех slave аккаунтов за период
+func (s *Service) SlavesCurve(userID int, period time.Duration) ([]models.EquityPoint, error) {
+	return s.storage.GetSlavesEquityCurve(userID, s.clock.Now().Add(-period))
+}
+
+// ParseRange разбирает период вида "30d", "12h" или любую длительность Go (пустая строка - DefaultRange)
+func ParseRange(raw string) (time.Duration, error) {
+	if raw == "" {
+		return DefaultRange, nil
+	}
+
+	var period time.Duration
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, ErrInvalidRange
+		}
+		period = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return 0, ErrInvalidRange
+		}
+		period = d
+	}
+
+	if period <= 0 || period > MaxRange {
+		return 0, ErrInvalidRange
+	}
+
+	return period, nil
+}
@@ -0,0 +1,248 @@
+package exposure
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+)
+
+const (
+	// DirectionLong / DirectionShort - направление позиции
+	DirectionLong  = "long"
+	DirectionShort = "short"
+
+	positionsTimeout = 15 * time.Second
+)
+
+// Storage - источник аккаунтов пользователя
+type Storage interface {
+	GetAccounts(userID int) ([]models.Account, error)
+}
+
+// Limits - лимиты концентрации по символу и направлению (0 отключает лимит)
+type Limits struct {
+	MaxNotional float64 `json:"max_notional"` // USDT
+	MaxShare    float64 `json:"max_share"`    // % от общей экспозиции
+}
+
+// AccountExposure - позиция аккаунта в группе символ/направление
+type AccountExposure struct {
+	AccountID   int     `json:"account_id"`
+	AccountName string  `json:"account_name"`
+	IsMaster    bool    `json:"is_master"`
+	Volume      float64 `json:"volume"`
+	Notional    float64 `json:"notional"`
+}
+
+// SymbolExposure - суммарная экспозиция по символу и направлению
+type SymbolExposure struct {
+	Symbol         string            `json:"symbol"`
+	Direction      string            `json:"direction"`
+	Notional       float64           `json:"notional"`
+	SlavesNotional float64           `json:"slaves_notional"`
+	MasterNotional float64           `json:"master_notional"`
+	Share          float64           `json:"share"` // % от общей экспозиции
+	Concentrated   bool              `json:"concentrated"`
+	Reasons        []string          `json:"reasons,omitempty"`
+	Accounts       []AccountExposure `json:"accounts"`
+}
+
+// Report - экспозиция пользователя по всем аккаунтам
+type Report struct {
+	TotalNotional float64          `json:"total_notional"`
+	Limits        Limits           `json:"limits"`
+	Symbols       []SymbolExposure `json:"symbols"`
+	Errors        []string         `json:"errors,omitempty"` // аккаунты, позиции которых не удалось получить
+}
+
+// Service считает открытую экспозицию по символам на всех аккаунтах пользователя
+type Service struct {
+	storage Storage
+	limits  Limits
+	logger  *slog.Logger
+
+	mu            sync.Mutex
+	contractSizes map[string]float64
+}
+
+// New создает сервис экспозиции
+func New(storage Storage, limits Limits, logger *slog.Logger) *Service {
+	return &Service{
+		storage:       storage,
+		limits:        limits,
+		logger:        logger,
+		contractSizes: make(map[string]float64),
+	}
+}
+
+// accountPositions - открытые позиции аккаунта
+type accountPositions struct {
+	account   models.Account
+	client    *mexc.Client
+	positions []models.Position
+	err       error
+}
+
+// Exposure собирает открытые позиции master и всех slave аккаунтов (включая отключенные) и суммирует
+// notional по цене входа в разрезе символ/направление.
+// Группы отсортированы по notional (крупнейшие первыми).
+func (s *Service) Exposure(ctx context.Context, userID int) (Report, error) {
+	accounts, err := s.storage.GetAccounts(userID)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	results := make([]accountPositions, len(accounts))
+
+	var wg sync.WaitGroup
+	for i, acc := range accounts {
+		wg.Add(1)
+		go func(i int, acc models.Account) {
+			defer wg.Done()
+			results[i] = s.fetchPositions(ctx, acc)
+		}(i, acc)
+	}
+	wg.Wait()
+
+	report := Report{
+		Limits:  s.limits,
+		Symbols: []SymbolExposure{},
+	}
+
+	groups := make(map[string]*SymbolExposure)
+	failedSymbols := make(map[string]bool)
+	for _, res := range results {
+		if res.err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", res.account.Name, res.err))
+			continue
+		}
+
+		for _, pos := range res.positions {
+			if pos.HoldVol == 0 {
+				continue
+			}
+
+			if failedSymbols[pos.Symbol] {
+				continue
+			}
+
+			contractSize, err := s.contractSize(ctx, res.client, pos.Symbol)
+			if err != nil {
+				failedSymbols[pos.Symbol] = true
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", pos.Symbol, err))
+				continue
+			}
+
+			direction := DirectionLong
+			if pos.PositionType == 2 {
+				direction = DirectionShort
+			}
+
+			key := pos.Symbol + "/" + direction
+			group, ok := groups[key]
+			if !ok {
+				group = &SymbolExposure{Symbol: pos.Symbol, Direction: direction}
+				groups[key] = group
+			}
+
+			notional := pos.HoldVol * contractSize * pos.HoldAvgPrice
+			group.Notional += notional
+			if res.account.IsMaster {
+				group.MasterNotional += notional
+			} else {
+				group.SlavesNotional += notional
+			}
+			group.Accounts = append(group.Accounts, AccountExposure{
+				AccountID:   res.account.ID,
+				AccountName: res.account.Name,
+				IsMaster:    res.account.IsMaster,
+				Volume:      pos.HoldVol,
+				Notional:    notional,
+			})
+
+			report.TotalNotional += notional
+		}
+	}
+
+	for _, group := range groups {
+		if report.TotalNotional > 0 {
+			group.Share = group.Notional / report.TotalNotional * 100
+		}
+		s.checkLimits(group)
+		report.Symbols = append(report.Symbols, *group)
+	}
+
+	slices.SortFunc(report.Symbols, func(a, b SymbolExposure) int {
+		if c := cmp.Compare(b.Notional, a.Notional); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Symbol+a.Direction, b.Symbol+b.Direction)
+	})
+
+	return report, nil
+}
+
+// checkLimits отмечает группу, превышающую лимиты концентрации
+func (s *Service) checkLimits(group *SymbolExposure) {
+	if s.limits.MaxNotional > 0 && group.Notional > s.limits.MaxNotional {
+		group.Reasons = append(group.Reasons,
+			fmt.Sprintf("notional %.2f > %.2f USDT", group.Notional, s.limits.MaxNotional))
+	}
+
+	if s.limits.MaxShare > 0 && group.Share > s.limits.MaxShare {
+		group.Reasons = append(group.Reasons,
+			fmt.Sprintf("share %.1f%% > %.1f%%", group.Share, s.limits.MaxShare))
+	}
+
+	group.Concentrated = len(group.Reasons) > 0
+}
+
+func (s *Service) fetchPositions(ctx context.Context, acc models.Account) accountPositions {
+	res := accountPositions{account: acc}
+
+	res.client, res.err = mexc.NewClient(acc, s.logger)
+	if res.err != nil {
+		return res
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, positionsTimeout)
+	defer cancel()
+
+	res.positions, res.err = res.client.GetPositions(ctx, "")
+
+	return res
+}
+
+// contractSize возвращает размер контракта символа (кэшируется на время жизни сервиса)
+func (s *Service) contractSize(ctx context.Context, client *mexc.Client, symbol string) (float64, error) {
+	s.mu.Lock()
+	size, ok := s.contractSizes[symbol]
+	s.mu.Unlock()
+	if ok {
+		return size, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, positionsTimeout)
+	defer cancel()
+
+	detail, err := client.GetContractDetail(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+	if detail.ContractSize <= 0 {
+		return 0, fmt.Errorf("invalid contract size %v", detail.ContractSize)
+	}
+
+	s.mu.Lock()
+	s.contractSizes[symbol] = detail.ContractSize
+	s.mu.Unlock()
+
+	return detail.ContractSize, nil
+}
@@ -0,0 +1,121 @@
+package features
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Flag - идентификатор feature flag
+type Flag string
+
+const (
+	// ProportionalSizing - масштабирование объёма slave по отношению equity к мастеру
+	ProportionalSizing Flag = "proportional_sizing"
+)
+
+// All - список всех известных флагов
+var All = []Flag{
+	ProportionalSizing,
+}
+
+// IsKnown проверяет, что флаг зарегистрирован
+func IsKnown(flag Flag) bool {
+	return slices.Contains(All, flag)
+}
+
+// OverrideStorage - хранилище per-user переопределений флагов
+type OverrideStorage interface {
+	GetFeatureOverrides(userID int) (map[string]bool, error)
+	SetFeatureOverride(userID int, flag string, enabled bool) error
+	DeleteFeatureOverride(userID int, flag string) error
+}
+
+// Service вычисляет состояние флагов: глобальные значения из конфига + переопределения пользователя
+type Service struct {
+	defaults map[Flag]bool
+	storage  OverrideStorage
+	logger   *slog.Logger
+}
+
+// NewService создает сервис feature flags.
+// enabled - список флагов, включенных глобально (из конфига)
+func NewService(enabled []string, storage OverrideStorage, logger *slog.Logger) *Service {
+	defaults := make(map[Flag]bool, len(All))
+	for _, flag := range All {
+		defaults[flag] = false
+	}
+
+	for _, name := range enabled {
+		flag := Flag(strings.TrimSpace(name))
+		if !IsKnown(flag) {
+			logger.Warn("Unknown feature flag in config", slog.String("flag", name))
+			continue
+		}
+
+		defaults[flag] = true
+	}
+
+	return &Service{
+		defaults: defaults,
+		storage:  storage,
+		logger:   logger,
+	}
+}
+
+// IsEnabled возвращает состояние флага для пользователя
+func (s *Service) IsEnabled(userID int, flag Flag) bool {
+	if s == nil {
+		return false
+	}
+
+	overrides, err := s.storage.GetFeatureOverrides(userID)
+	if err != nil {
+		s.logger.Warn("Failed to get feature overrides",
+			slog.Int("user_id", userID),
+			slog.Any("error", err))
+
+		return s.defaults[flag]
+	}
+
+	if enabled, ok := overrides[string(flag)]; ok {
+		return enabled
+	}
+
+	return s.defaults[flag]
+}
+
+// Effective возвращает состояние всех флагов для пользователя
+func (s *Service) Effective(userID int) map[Flag]bool {
+	result := make(map[Flag]bool, len(s.defaults))
+	for flag, enabled := range s.defaults {
+		result[flag] = enabled
+	}
+
+	overrides, err := s.storage.GetFeatureOverrides(userID)
+	if err != nil {
+		s.logger.Warn("Failed to get feature overrides",
+			slog.Int("user_id", userID),
+			slog.Any("error", err))
+
+		return result
+	}
+
+	for name, enabled := range overrides {
+		if IsKnown(Flag(name)) {
+			result[Flag(name)] = enabled
+		}
+	}
+
+	return result
+}
+
+// SetOverride включает/выключает флаг для конкретного пользователя
+func (s *Service) SetOverride(userID int, flag Flag, enabled bool) error {
+	return s.storage.SetFeatureOverride(userID, string(flag), enabled)
+}
+
+// ResetOverride удаляет переопределение пользователя (возврат к глобальному значению)
+func (s *Service) ResetOverride(userID int, flag Flag) error {
+	return s.storage.DeleteFeatureOverride(userID, string(flag))
+}
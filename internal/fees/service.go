@@ -0,0 +1,249 @@
+package fees
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tg_mexc/internal/clock"
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+)
+
+const (
+	// SourceDeal - fill'ы master аккаунта из WebSocket
+	SourceDeal = "deal"
+	// SourceOrder - исполненные ордера из истории (backfill, основной источник для slave)
+	SourceOrder = "order"
+
+	backfillPageSize = 100
+	backfillMaxPages = 10
+)
+
+// Storage - хранилище комиссий
+type Storage interface {
+	AddFeeEntry(userID int, entry models.FeeEntry) (bool, error)
+	GetFirstFeeEntryTime(accountID int, source string) (time.Time, error)
+	GetFeeRecords(userID int, fromDay, toDay string) ([]models.FeeRecord, error)
+	GetAccounts(userID int) ([]models.Account, error)
+}
+
+// AccountFees - комиссии аккаунта за период
+type AccountFees struct {
+	AccountID   int     `json:"account_id"`
+	AccountName string  `json:"account_name"`
+	IsMaster    bool    `json:"is_master"`
+	Disabled    bool    `json:"disabled"`
+	TakerFees   float64 `json:"taker_fees"`
+	MakerFees   float64 `json:"maker_fees"`
+	TotalFees   float64 `json:"total_fees"`
+	Fills       int     `json:"fills"`
+	// Charging - активный slave, который платит комиссию (ожидается zero-fee)
+	Charging bool `json:"charging"`
+}
+
+// Summary - комиссии пользователя за период
+type Summary struct {
+	From      string        `json:"from"`
+	To        string        `json:"to"`
+	TotalFees float64       `json:"total_fees"`
+	Accounts  []AccountFees `json:"accounts"`
+}
+
+// Service учитывает уплаченные комиссии по аккаунтам
+type Service struct {
+	storage Storage
+	logger  *slog.Logger
+	clock   clock.Clock
+}
+
+// New создает сервис учета комиссий
+func New(storage Storage, logger *slog.Logger) *Service {
+	return &Service{
+		storage: storage,
+		logger:  logger,
+		clock:   clock.Real,
+	}
+}
+
+// SetClock подменяет источник времени
+func (s *Service) SetClock(clk clock.Clock) {
+	s.clock = clk
+}
+
+// RecordDeal учитывает комиссию fill'а из WebSocket (повторная запись игнорируется)
+func (s *Service) RecordDeal(userID int, deal models.Deal) error {
+	if deal.Fee == 0 {
+		return nil
+	}
+
+	entry := models.FeeEntry{
+		AccountID:  deal.AccountID,
+		Source:     SourceDeal,
+		Ref:        deal.ID,
+		Symbol:     deal.Symbol,
+		OccurredAt: deal.At,
+	}
+	if deal.Taker {
+		entry.TakerFee = deal.Fee
+	} else {
+		entry.MakerFee = deal.Fee
+	}
+
+	if entry.OccurredAt.IsZero() {
+		entry.OccurredAt = s.clock.Now()
+	}
+
+	if _, err := s.storage.AddFeeEntry(userID, entry); err != nil {
+		return fmt.Errorf("failed to add fee entry: %w", err)
+	}
+
+	return nil
+}
+
+// Backfill догружает комиссии из истории ордеров по всем аккаунтам пользователя.
+// Ордера, исполненные после первого fill'а из WebSocket, пропускаются - они уже учтены по deal событиям.
+// Возвращает количество новых записей.
+func (s *Service) Backfill(ctx context.Context, userID int) (int, error) {
+	accounts, err := s.storage.GetAccounts(userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	var (
+		added int
+		errs  []error
+	)
+	for _, acc := range accounts {
+		n, err := s.backfillAccount(ctx, userID, acc)
+		added += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", acc.Name, err))
+		}
+	}
+
+	return added, errors.Join(errs...)
+}
+
+func (s *Service) backfillAccount(ctx context.Context, userID int, acc models.Account) (int, error) {
+	dealsSince, err := s.storage.GetFirstFeeEntryTime(acc.ID, SourceDeal)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get first deal time: %w", err)
+	}
+
+	client, err := mexc.NewClient(acc, s.logger)
+	if err != nil {
+		return 0, err
+	}
+	client.SetClock(s.clock)
+
+	// История отдается от новых к старым: страница без новых записей означает, что дальше все уже учтено
+	added := 0
+	fetch := func(ctx context.Context, pageNum, pageSize int) ([]models.OpenOrder, error) {
+		return client.GetHistoryOrders(ctx, "", pageNum, pageSize)
+	}
+	err = mexc.Paginate(ctx, fetch, backfillPageSize, backfillMaxPages, func(orders []models.OpenOrder) error {
+		pageAdded := 0
+		for _, order := range orders {
+			if order.DealVol == 0 || (order.TakerFee == 0 && order.MakerFee == 0) {
+				continue
+			}
+
+			entry := models.FeeEntry{
+				AccountID:  acc.ID,
+				Source:     SourceOrder,
+				Ref:        order.OrderID,
+				Symbol:     order.Symbol,
+				TakerFee:   order.TakerFee,
+				MakerFee:   order.MakerFee,
+				OccurredAt: time.UnixMilli(order.UpdateTime).UTC(),
+			}
+			if !dealsSince.IsZero() && !entry.OccurredAt.Before(dealsSince) {
+				pageAdded++ // учтено по deal событиям, продолжаем листать
+				continue
+			}
+
+			ok, err := s.storage.AddFeeEntry(userID, entry)
+			if err != nil {
+				return fmt.Errorf("failed to add fee entry: %w", err)
+			}
+			if ok {
+				added++
+				pageAdded++
+			}
+		}
+
+		if pageAdded == 0 {
+			return mexc.ErrStopPaging
+		}
+		return nil
+	})
+
+	return added, err
+}
+
+// Summary возвращает комиссии по всем аккаунтам за последние days дней (включая сегодня, UTC)
+func (s *Service) Summary(userID int, days int) (Summary, error) {
+	if days < 1 {
+		days = 1
+	}
+
+	to := s.clock.Now().UTC()
+	from := to.AddDate(0, 0, -(days - 1))
+
+	return s.SummaryBetween(userID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+}
+
+// SummaryBetween возвращает комиссии по всем аккаунтам за дни from..to включительно (YYYY-MM-DD, UTC)
+func (s *Service) SummaryBetween(userID int, from, to string) (Summary, error) {
+	summary := Summary{
+		From:     from,
+		To:       to,
+		Accounts: []AccountFees{},
+	}
+
+	accounts, err := s.storage.GetAccounts(userID)
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	records, err := s.storage.GetFeeRecords(userID, summary.From, summary.To)
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed to get fee records: %w", err)
+	}
+
+	byAccount := make(map[int]*AccountFees, len(accounts))
+	for _, acc := range accounts {
+		summary.Accounts = append(summary.Accounts, AccountFees{
+			AccountID:   acc.ID,
+			AccountName: acc.Name,
+			IsMaster:    acc.IsMaster,
+			Disabled:    acc.Disabled,
+		})
+	}
+	for i := range summary.Accounts {
+		byAccount[summary.Accounts[i].AccountID] = &summary.Accounts[i]
+	}
+
+	for _, record := range records {
+		acc, ok := byAccount[record.AccountID]
+		if !ok {
+			continue
+		}
+
+		acc.TakerFees += record.TakerFees
+		acc.MakerFees += record.MakerFees
+		acc.TotalFees += record.TakerFees + record.MakerFees
+		acc.Fills += record.Fills
+		summary.TotalFees += record.TakerFees + record.MakerFees
+	}
+
+	for i := range summary.Accounts {
+		acc := &summary.Accounts[i]
+		acc.Charging = !acc.IsMaster && !acc.Disabled && acc.TotalFees > 0
+	}
+
+	return summary, nil
+}
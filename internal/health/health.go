@@ -0,0 +1,315 @@
+package health
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"tg_mexc/internal/analytics"
+	"tg_mexc/internal/clock"
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+)
+
+// Window - скользящее окно, по которому считается оценка
+const Window = 24 * time.Hour
+
+// Статусы аккаунта по итоговой оценке
+const (
+	StatusHealthy  = "healthy"  // >= 80
+	StatusDegraded = "degraded" // >= 50
+	StatusCritical = "critical" // < 50
+)
+
+// Пороги задержки отправка → подтверждение биржи (p50): лучше goodLatency - 100 баллов, хуже badLatency - 0
+const (
+	goodLatencyMs = 300.0
+	badLatencyMs  = 2000.0
+	// proxyErrorPenalty - минус баллов за каждую сетевую/прокси ошибку в окне
+	proxyErrorPenalty = 20
+)
+
+// Storage - данные аккаунтов для оценки
+type Storage interface {
+	GetAccounts(userID int) ([]models.Account, error)
+	GetExecutionSamples(userID int, since time.Time) ([]models.ExecutionSample, error)
+	GetLatestBalanceSnapshots(userID int) ([]models.BalanceSnapshot, error)
+}
+
+// Components - составляющие оценки 0..100 (nil - нет данных за окно)
+type Components struct {
+	Auth     *int `json:"auth"`     // Последнее исполнение не отклонено из-за истекшей авторизации
+	Failures *int `json:"failures"` // Доля успешных исполнений
+	Latency  *int `json:"latency"`  // p50 задержки отправка → подтверждение
+	Proxy    *int `json:"proxy"`    // Сетевые/прокси ошибки
+	Margin   *int `json:"margin"`   // Свободная маржа: available / equity по последнему снимку баланса
+}
+
+// Score - оценка здоровья аккаунта
+type Score struct {
+	AccountID   int        `json:"account_id"`
+	AccountName string     `json:"account_name"`
+	IsMaster    bool       `json:"is_master"`
+	Score       int        `json:"score"`
+	Status      string     `json:"status"`
+	Weakest     bool       `json:"weakest"` // Самый слабый включенный slave пользователя
+	Components  Components `json:"components"`
+	Issues      []string   `json:"issues,omitempty"`
+	Executions  int        `json:"executions"`
+	FailureRate float64    `json:"failure_rate"` // %
+	LatencyP50  float64    `json:"latency_p50_ms"`
+}
+
+// Service считает оценку здоровья аккаунтов по исполнениям за Window и последним снимкам баланса
+type Service struct {
+	storage Storage
+	logger  *slog.Logger
+	clock   clock.Clock
+}
+
+// New создает сервис оценки здоровья аккаунтов
+func New(storage Storage, logger *slog.Logger) *Service {
+	return &Service{
+		storage: storage,
+		logger:  logger,
+		clock:   clock.Real,
+	}
+}
+
+// SetClock подменяет источник времени
+func (s *Service) SetClock(clk clock.Clock) {
+	s.clock = clk
+}
+
+// Scores возвращает оценки всех аккаунтов пользователя по ID аккаунта
+func (s *Service) Scores(userID int) (map[int]Score, error) {
+	accounts, err := s.storage.GetAccounts(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	samples, err := s.storage.GetExecutionSamples(userID, s.clock.Now().Add(-Window))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get execution samples: %w", err)
+	}
+
+	snapshots, err := s.storage.GetLatestBalanceSnapshots(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance snapshots: %w", err)
+	}
+
+	byAccount := make(map[int][]models.ExecutionSample)
+	for _, sample := range samples {
+		byAccount[sample.AccountID] = append(byAccount[sample.AccountID], sample)
+	}
+
+	balances := make(map[int]models.BalanceSnapshot, len(snapshots))
+	for _, snapshot := range snapshots {
+		balances[snapshot.AccountID] = snapshot
+	}
+
+	scores := make(map[int]Score, len(accounts))
+	weakest, slaves := -1, 0
+	for _, acc := range accounts {
+		balance, hasBalance := balances[acc.ID]
+		score := score(acc, byAccount[acc.ID], balance, hasBalance)
+		scores[acc.ID] = score
+
+		if acc.IsMaster || acc.Disabled {
+			continue
+		}
+		slaves++
+		if weakest < 0 || score.Score < scores[weakest].Score {
+			weakest = acc.ID
+		}
+	}
+
+	// Самый слабый отмечается, только если есть с кем сравнивать и он не идеален
+	if slaves > 1 && scores[weakest].Score < 100 {
+		score := scores[weakest]
+		score.Weakest = true
+		scores[weakest] = score
+	}
+
+	return scores, nil
+}
+
+// List возвращает оценки аккаунтов пользователя от самого слабого к самому здоровому
+func (s *Service) List(userID int) ([]Score, error) {
+	scores, err := s.Scores(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Score, 0, len(scores))
+	for _, score := range scores {
+		list = append(list, score)
+	}
+	slices.SortFunc(list, func(a, b Score) int {
+		return cmp.Or(cmp.Compare(a.Score, b.Score), cmp.Compare(a.AccountID, b.AccountID))
+	})
+
+	return list, nil
+}
+
+func score(acc models.Account, samples []models.ExecutionSample, balance models.BalanceSnapshot, hasBalance bool) Score {
+	result := Score{
+		AccountID:   acc.ID,
+		AccountName: acc.Name,
+		IsMaster:    acc.IsMaster,
+		Executions:  len(samples),
+	}
+
+	var failed, authFailed, networkFailed int
+	var latencies []float64
+	lastAuthFailed := false
+	for _, sample := range samples {
+		if sample.Status != "failed" {
+			lastAuthFailed = false
+			if sample.AckedAt != nil {
+				latencies = append(latencies, float64(sample.AckedAt.Sub(sample.DispatchedAt).Microseconds())/1000)
+			}
+			continue
+		}
+
+		failed++
+		err := errors.New(sample.Error)
+		lastAuthFailed = mexc.IsAuthError(err)
+		switch {
+		case lastAuthFailed:
+			authFailed++
+		case isNetworkError(sample.Error):
+			networkFailed++
+		}
+	}
+
+	c := &result.Components
+	if len(samples) > 0 {
+		switch {
+		case lastAuthFailed:
+			c.Auth = ptr(0)
+			result.Issues = append(result.Issues, "authorization expired")
+		case authFailed > 0:
+			c.Auth = ptr(50)
+			result.Issues = append(result.Issues, fmt.Sprintf("%d auth errors", authFailed))
+		default:
+			c.Auth = ptr(100)
+		}
+
+		result.FailureRate = float64(failed) / float64(len(samples)) * 100
+		c.Failures = ptr(int(math.Round(100 - result.FailureRate)))
+		if failed > 0 {
+			result.Issues = append(result.Issues, fmt.Sprintf("%.0f%% failed executions", result.FailureRate))
+		}
+
+		c.Proxy = ptr(max(0, 100-proxyErrorPenalty*networkFailed))
+		if networkFailed > 0 {
+			result.Issues = append(result.Issues, fmt.Sprintf("%d network/proxy errors", networkFailed))
+		}
+	}
+
+	if len(latencies) > 0 {
+		result.LatencyP50 = analytics.NewPercentiles(latencies).P50
+		c.Latency = ptr(latencyScore(result.LatencyP50))
+		if *c.Latency < 50 {
+			result.Issues = append(result.Issues, fmt.Sprintf("slow execution (p50 %.0f ms)", result.LatencyP50))
+		}
+	}
+
+	if hasBalance && balance.Equity > 0 {
+		headroom := balance.Available / balance.Equity * 100
+		c.Margin = ptr(int(math.Round(min(100, max(0, headroom)))))
+		if *c.Margin < 20 {
+			result.Issues = append(result.Issues, fmt.Sprintf("low margin headroom (%d%%)", *c.Margin))
+		}
+	}
+
+	result.Score = weighted(*c)
+	result.Status = status(result.Score)
+
+	return result
+}
+
+// weighted считает взвешенную оценку по известным составляющим (нет данных ни по одной - 100)
+func weighted(c Components) int {
+	parts := []struct {
+		value  *int
+		weight float64
+	}{
+		{c.Auth, 0.30},
+		{c.Failures, 0.25},
+		{c.Latency, 0.15},
+		{c.Proxy, 0.15},
+		{c.Margin, 0.15},
+	}
+
+	var sum, total float64
+	for _, part := range parts {
+		if part.value == nil {
+			continue
+		}
+		sum += part.weight * float64(*part.value)
+		total += part.weight
+	}
+
+	if total == 0 {
+		return 100
+	}
+
+	return int(math.Round(sum / total))
+}
+
+func latencyScore(p50 float64) int {
+	switch {
+	case p50 <= goodLatencyMs:
+		return 100
+	case p50 >= badLatencyMs:
+		return 0
+	}
+
+	return int(math.Round(100 * (badLatencyMs - p50) / (badLatencyMs - goodLatencyMs)))
+}
+
+func status(score int) string {
+	switch {
+	case score >= 80:
+		return StatusHealthy
+	case score >= 50:
+		return StatusDegraded
+	}
+
+	return StatusCritical
+}
+
+// networkErrors - признаки ошибок соединения с биржей или прокси в тексте ошибки исполнения
+var networkErrors = []string{
+	"proxyconnect",
+	"dial tcp",
+	"i/o timeout",
+	"connection refused",
+	"connection reset",
+	"no such host",
+	"tls handshake",
+	"deadline exceeded",
+	"eof",
+}
+
+func isNetworkError(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, marker := range networkErrors {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ptr(v int) *int {
+	return &v
+}
@@ -0,0 +1,54 @@
+package httpmiddleware
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// ErrBotChallenge means a JSON API answered with an HTML page: an anti-bot challenge
+// (Cloudflare, Akamai) or a block page of the CDN in front of the API
+var ErrBotChallenge = errors.New("anti-bot challenge: HTML response instead of JSON")
+
+// sniffLen is how many leading bytes of a body are inspected when Content-Type is not conclusive
+const sniffLen = 512
+
+// BotChallenge is a middleware for JSON APIs that turns HTML responses into ErrBotChallenge
+// instead of passing them on to json.Unmarshal, which would silently produce zero values.
+// A response is HTML when its Content-Type says so or, for a missing or non-JSON type,
+// when the body starts with '<'. The response body is closed, the error carries the status code.
+func BotChallenge(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if err != nil || req.Method == http.MethodHead {
+			return resp, err
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/json":
+			return resp, nil
+		case "text/html", "application/xhtml+xml":
+			resp.Body.Close()
+			return nil, fmt.Errorf("%w (HTTP %d)", ErrBotChallenge, resp.StatusCode)
+		}
+
+		body := bufio.NewReaderSize(resp.Body, sniffLen)
+		head, _ := body.Peek(sniffLen)
+		if bytes.HasPrefix(bytes.TrimLeft(head, " \t\r\n"), []byte("<")) {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%w (HTTP %d)", ErrBotChallenge, resp.StatusCode)
+		}
+
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{body, resp.Body}
+
+		return resp, nil
+	})
+}
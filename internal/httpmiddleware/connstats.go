@@ -0,0 +1,115 @@
+package httpmiddleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+// ConnStats counts how requests obtained their connections. A low reuse ratio or
+// many full TLS handshakes on the fan-out path mean MaxIdleConnsPerHost or
+// IdleConnTimeout is too small for the traffic (see TransportConfig).
+type ConnStats struct {
+	requests      atomic.Int64
+	reused        atomic.Int64
+	idleReused    atomic.Int64
+	dials         atomic.Int64
+	dialNanos     atomic.Int64
+	tlsHandshakes atomic.Int64
+	tlsResumed    atomic.Int64
+	tlsNanos      atomic.Int64
+}
+
+// ConnStatsSnapshot is a point-in-time copy of ConnStats
+type ConnStatsSnapshot struct {
+	Requests      int64   `json:"requests"`
+	Reused        int64   `json:"reused"`         // Requests sent over an already open connection
+	IdleReused    int64   `json:"idle_reused"`    // Of them, taken from the idle pool
+	ReuseRatio    float64 `json:"reuse_ratio"`    // Reused / Requests
+	Dials         int64   `json:"dials"`          // New TCP connections
+	AvgDialMs     float64 `json:"avg_dial_ms"`    // Average TCP connect time
+	TLSHandshakes int64   `json:"tls_handshakes"` // Completed TLS handshakes
+	TLSResumed    int64   `json:"tls_resumed"`    // Of them, resumed from the session cache
+	AvgTLSMs      float64 `json:"avg_tls_ms"`     // Average TLS handshake time
+}
+
+// Snapshot returns the current counters
+func (s *ConnStats) Snapshot() ConnStatsSnapshot {
+	snapshot := ConnStatsSnapshot{
+		Requests:      s.requests.Load(),
+		Reused:        s.reused.Load(),
+		IdleReused:    s.idleReused.Load(),
+		Dials:         s.dials.Load(),
+		TLSHandshakes: s.tlsHandshakes.Load(),
+		TLSResumed:    s.tlsResumed.Load(),
+	}
+
+	if snapshot.Requests > 0 {
+		snapshot.ReuseRatio = float64(snapshot.Reused) / float64(snapshot.Requests)
+	}
+	if snapshot.Dials > 0 {
+		snapshot.AvgDialMs = float64(s.dialNanos.Load()) / float64(snapshot.Dials) / float64(time.Millisecond)
+	}
+	if snapshot.TLSHandshakes > 0 {
+		snapshot.AvgTLSMs = float64(s.tlsNanos.Load()) / float64(snapshot.TLSHandshakes) / float64(time.Millisecond)
+	}
+
+	return snapshot
+}
+
+// ConnMetrics creates a middleware that records connection reuse, dials and TLS handshakes
+// of every request (every retry attempt when placed under Retry) into stats.
+// A nil stats disables the middleware.
+func ConnMetrics(stats *ConnStats) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if stats == nil {
+			return next
+		}
+
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			// Dial and TLS callbacks run on the dialing goroutine, possibly in parallel (Happy Eyeballs)
+			var dialStart, tlsStart atomic.Int64
+
+			trace := &httptrace.ClientTrace{
+				GotConn: func(info httptrace.GotConnInfo) {
+					stats.requests.Add(1)
+					if info.Reused {
+						stats.reused.Add(1)
+					}
+					if info.WasIdle {
+						stats.idleReused.Add(1)
+					}
+				},
+				ConnectStart: func(_, _ string) {
+					dialStart.Store(time.Now().UnixNano())
+				},
+				ConnectDone: func(_, _ string, err error) {
+					if start := dialStart.Load(); err == nil && start != 0 {
+						stats.dials.Add(1)
+						stats.dialNanos.Add(time.Now().UnixNano() - start)
+					}
+				},
+				TLSHandshakeStart: func() {
+					tlsStart.Store(time.Now().UnixNano())
+				},
+				TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+					start := tlsStart.Load()
+					if err != nil || start == 0 {
+						return
+					}
+					stats.tlsHandshakes.Add(1)
+					stats.tlsNanos.Add(time.Now().UnixNano() - start)
+					if state.DidResume {
+						stats.tlsResumed.Add(1)
+					}
+				},
+			}
+
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+			return next.RoundTrip(req)
+		})
+	}
+}
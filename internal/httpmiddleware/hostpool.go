@@ -0,0 +1,226 @@
+package httpmiddleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+type hostState struct {
+	url       *url.URL
+	healthy   bool
+	lastError error
+	failedAt  time.Time
+}
+
+// HostStatus is a snapshot of a pool host for health reporting
+type HostStatus struct {
+	URL       string
+	Healthy   bool
+	Current   bool
+	LastError string
+	FailedAt  time.Time
+}
+
+// HostPool is a list of interchangeable base addresses of one API (mirrors).
+// Requests are built for the first (primary) address and sent to the current one;
+// the pool rotates to the next healthy host when the current one fails
+type HostPool struct {
+	mu      sync.Mutex
+	hosts   []*hostState
+	current int
+}
+
+// NewHostPool creates a pool with all hosts considered healthy; the first host is the primary one
+func NewHostPool(hosts []*url.URL) *HostPool {
+	pool := &HostPool{}
+	for _, host := range hosts {
+		pool.hosts = append(pool.hosts, &hostState{url: host, healthy: true})
+	}
+
+	return pool
+}
+
+// Primary returns the address requests are built for (nil for an empty pool)
+func (p *HostPool) Primary() *url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.hosts) == 0 {
+		return nil
+	}
+
+	return p.hosts[0].url
+}
+
+// Current returns the host in use (nil for an empty pool)
+func (p *HostPool) Current() *url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.hosts) == 0 {
+		return nil
+	}
+
+	return p.hosts[p.current].url
+}
+
+// MarkFailed marks host unhealthy and, if it is the current one, switches to the next healthy host.
+// When every host is unhealthy the pool keeps rotating so a recovered host is found again.
+func (p *HostPool) MarkFailed(host *url.URL, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.index(host)
+	if i < 0 {
+		return
+	}
+
+	p.hosts[i].healthy = false
+	p.hosts[i].lastError = err
+	p.hosts[i].failedAt = time.Now()
+
+	if i == p.current {
+		p.rotate()
+	}
+}
+
+// MarkHealthy marks host healthy after a successful response
+func (p *HostPool) MarkHealthy(host *url.URL) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if i := p.index(host); i >= 0 {
+		p.hosts[i].healthy = true
+	}
+}
+
+// Status returns a snapshot of all pool hosts
+func (p *HostPool) Status() []HostStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]HostStatus, 0, len(p.hosts))
+	for i, state := range p.hosts {
+		status := HostStatus{
+			URL:      state.url.String(),
+			Healthy:  state.healthy,
+			Current:  i == p.current,
+			FailedAt: state.failedAt,
+		}
+		if state.lastError != nil {
+			status.LastError = state.lastError.Error()
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// rotate switches to the next healthy host, or just the next one if none is healthy
+func (p *HostPool) rotate() {
+	n := len(p.hosts)
+	for step := 1; step <= n; step++ {
+		next := (p.current + step) % n
+		if p.hosts[next].healthy {
+			p.current = next
+			return
+		}
+	}
+
+	p.current = (p.current + 1) % n
+}
+
+func (p *HostPool) index(host *url.URL) int {
+	if host == nil {
+		return -1
+	}
+
+	for i, state := range p.hosts {
+		if state.url.String() == host.String() {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// isHostError reports whether err means the host itself is unreachable from here:
+// name resolution failed or the TLS handshake did not complete (regional blocks, DNS poisoning)
+func isHostError(err error) bool {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var hostnameErr x509.HostnameError
+	var authorityErr x509.UnknownAuthorityError
+
+	return errors.As(err, &dnsErr) ||
+		errors.As(err, &certErr) ||
+		errors.As(err, &recordErr) ||
+		errors.As(err, &alertErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &authorityErr)
+}
+
+// HostFailover creates a middleware that sends requests addressed to the pool primary host
+// to the current host instead (scheme, host and the Origin / Referer headers are rewritten).
+// A DNS / TLS error or a 5xx response rotates the pool, so the next attempt (see Retry)
+// goes to another mirror. Requests to other hosts pass through. A nil pool disables failover.
+func HostFailover(pool *HostPool) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if pool == nil {
+			return next
+		}
+
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			primary, host := pool.Primary(), pool.Current()
+			if primary == nil || req.URL.Host != primary.Host {
+				return next.RoundTrip(req)
+			}
+
+			if host.Host != primary.Host {
+				req = rewriteHost(req, primary, host)
+			}
+
+			resp, err := next.RoundTrip(req)
+			switch {
+			case err != nil:
+				if req.Context().Err() == nil && isHostError(err) {
+					pool.MarkFailed(host, err)
+				}
+			case resp.StatusCode >= http.StatusInternalServerError:
+				pool.MarkFailed(host, fmt.Errorf("HTTP %d", resp.StatusCode))
+			default:
+				pool.MarkHealthy(host)
+			}
+
+			return resp, err
+		})
+	}
+}
+
+// rewriteHost returns a copy of req addressed to host instead of primary
+func rewriteHost(req *http.Request, primary, host *url.URL) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.URL.Scheme = host.Scheme
+	clone.URL.Host = host.Host
+	clone.Host = ""
+
+	primaryOrigin := primary.Scheme + "://" + primary.Host
+	hostOrigin := host.Scheme + "://" + host.Host
+	for _, header := range []string{"Origin", "Referer"} {
+		if value := clone.Header.Get(header); strings.HasPrefix(value, primaryOrigin) {
+			clone.Header.Set(header, hostOrigin+strings.TrimPrefix(value, primaryOrigin))
+		}
+	}
+
+	return clone
+}
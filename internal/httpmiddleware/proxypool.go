@@ -0,0 +1,251 @@
+package httpmiddleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxySchemes are the proxy URL schemes supported by http.Transport.
+// socks5h resolves host names on the proxy side; user:password@ enables proxy authentication.
+var ProxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// ParseProxies parses a proxy list separated by commas, spaces or new lines.
+// A proxy without a scheme (host:port) is an HTTP proxy.
+func ParseProxies(list string) ([]*url.URL, error) {
+	fields := strings.FieldsFunc(list, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	})
+
+	proxies := make([]*url.URL, 0, len(fields))
+	for _, field := range fields {
+		if !strings.Contains(field, "://") {
+			field = "http://" + field
+		}
+
+		proxyURL, err := url.Parse(field)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q", field)
+		}
+		if !slices.Contains(ProxySchemes, proxyURL.Scheme) {
+			return nil, fmt.Errorf("invalid proxy %q: supported schemes are %s",
+				proxyURL.Redacted(), strings.Join(ProxySchemes, ", "))
+		}
+		proxies = append(proxies, proxyURL)
+	}
+
+	return proxies, nil
+}
+
+type proxyState struct {
+	url       *url.URL
+	healthy   bool
+	lastError error
+	checkedAt time.Time
+}
+
+// ProxyStatus is a snapshot of a pool proxy for health reporting
+type ProxyStatus struct {
+	URL       string
+	Healthy   bool
+	Current   bool
+	LastError string
+	CheckedAt time.Time
+}
+
+// ProxyPool selects one proxy of a pool for outgoing connections and rotates
+// to the next healthy one when the current proxy fails
+type ProxyPool struct {
+	mu      sync.Mutex
+	proxies []*proxyState
+	current int
+}
+
+// NewProxyPool creates a pool with all proxies considered healthy
+func NewProxyPool(proxies []*url.URL) *ProxyPool {
+	pool := &ProxyPool{}
+	for _, proxyURL := range proxies {
+		pool.proxies = append(pool.proxies, &proxyState{url: proxyURL, healthy: true})
+	}
+
+	return pool
+}
+
+// Proxy is an http.Transport.Proxy function returning the current proxy
+func (p *ProxyPool) Proxy(_ *http.Request) (*url.URL, error) {
+	return p.Current(), nil
+}
+
+// Current returns the proxy in use (nil for an empty pool)
+func (p *ProxyPool) Current() *url.URL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.proxies) == 0 {
+		return nil
+	}
+
+	return p.proxies[p.current].url
+}
+
+// MarkFailed marks proxy unhealthy and, if it is the current one, switches to the next healthy proxy.
+// When every proxy is unhealthy the pool keeps rotating so a recovered proxy is found again.
+func (p *ProxyPool) MarkFailed(proxy *url.URL, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.index(proxy)
+	if i < 0 {
+		return
+	}
+
+	p.proxies[i].healthy = false
+	p.proxies[i].lastError = err
+
+	if i == p.current {
+		p.rotate()
+	}
+}
+
+// Check probes every proxy of the pool with a request to target and updates their health.
+// If the current proxy is unhealthy the pool switches to a healthy one.
+func (p *ProxyPool) Check(ctx context.Context, target string, timeout time.Duration) {
+	p.mu.Lock()
+	proxies := make([]*url.URL, len(p.proxies))
+	for i, state := range p.proxies {
+		proxies[i] = state.url
+	}
+	p.mu.Unlock()
+
+	results := make([]error, len(proxies))
+	var wg sync.WaitGroup
+	for i, proxyURL := range proxies {
+		wg.Go(func() {
+			results[i] = probeProxy(ctx, proxyURL, target, timeout)
+		})
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for i, proxyURL := range proxies {
+		j := p.index(proxyURL)
+		if j < 0 {
+			continue
+		}
+		p.proxies[j].healthy = results[i] == nil
+		p.proxies[j].lastError = results[i]
+		p.proxies[j].checkedAt = now
+	}
+
+	if len(p.proxies) > 0 && !p.proxies[p.current].healthy {
+		p.rotate()
+	}
+}
+
+// Status returns a snapshot of all pool proxies
+func (p *ProxyPool) Status() []ProxyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]ProxyStatus, 0, len(p.proxies))
+	for i, state := range p.proxies {
+		status := ProxyStatus{
+			URL:       state.url.Redacted(),
+			Healthy:   state.healthy,
+			Current:   i == p.current,
+			CheckedAt: state.checkedAt,
+		}
+		if state.lastError != nil {
+			status.LastError = state.lastError.Error()
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// rotate switches to the next healthy proxy, or just the next one if none is healthy
+func (p *ProxyPool) rotate() {
+	n := len(p.proxies)
+	for step := 1; step <= n; step++ {
+		next := (p.current + step) % n
+		if p.proxies[next].healthy {
+			p.current = next
+			return
+		}
+	}
+
+	p.current = (p.current + 1) % n
+}
+
+func (p *ProxyPool) index(proxy *url.URL) int {
+	if proxy == nil {
+		return -1
+	}
+
+	for i, state := range p.proxies {
+		if state.url.String() == proxy.String() {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// probeProxy sends a HEAD request to target through proxy; any HTTP response means the proxy works
+func probeProxy(ctx context.Context, proxy *url.URL, target string, timeout time.Duration) error {
+	transport := DefaultTransport()
+	transport.Proxy = http.ProxyURL(proxy)
+	defer transport.CloseIdleConnections()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, http.NoBody)
+	if err != nil {
+		return err
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		return errors.New(resp.Status)
+	}
+
+	return nil
+}
+
+// ProxyFailover creates a middleware that rotates the pool when a request fails
+// with a connection error, so the next attempt (see Retry) goes through another proxy.
+// A nil pool disables failover.
+func ProxyFailover(pool *ProxyPool) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if pool == nil {
+			return next
+		}
+
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			proxy := pool.Current()
+
+			resp, err := next.RoundTrip(req)
+			if err != nil && req.Context().Err() == nil {
+				pool.MarkFailed(proxy, err)
+			}
+
+			return resp, err
+		})
+	}
+}
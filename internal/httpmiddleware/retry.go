@@ -0,0 +1,154 @@
+package httpmiddleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// RetryConfig controls the retry middleware
+type RetryConfig struct {
+	MaxAttempts int           // Total attempts including the first one (<= 1 disables retries)
+	BaseDelay   time.Duration // Backoff before the second attempt, doubled for each next one
+	MaxDelay    time.Duration // Backoff cap
+
+	// TransientCodes are API error codes in a JSON body ({"code": N}) that are worth retrying
+	// (the exchange answers 200 OK with an error code when it is busy or throttling)
+	TransientCodes []int
+}
+
+// DefaultRetryConfig returns the retry settings for MEXC API calls:
+// 500 internal error, 501 system busy, 510 request frequency too high
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:    3,
+		BaseDelay:      200 * time.Millisecond,
+		MaxDelay:       2 * time.Second,
+		TransientCodes: []int{500, 501, 510},
+	}
+}
+
+type idempotentKey struct{}
+
+// Idempotent marks a non-GET request as safe to repeat (e.g. order cancellation),
+// so the retry middleware treats it like a GET
+func Idempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(req *http.Request) bool {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return true
+	}
+
+	marked, _ := req.Context().Value(idempotentKey{}).(bool)
+	return marked
+}
+
+// Retry creates a middleware that retries failed requests with exponential backoff and full jitter.
+// Idempotent requests are retried on network errors, 429/5xx and transient API codes.
+// Other requests (order placement) are retried only when the connection could not be
+// established, i.e. the request surely never reached the server.
+// Requests with a body need GetBody (see RequestGetBodySetter).
+func Retry(cfg RetryConfig) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		if cfg.MaxAttempts <= 1 {
+			return next
+		}
+
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			idempotent := isIdempotent(req)
+
+			for attempt := 1; ; attempt++ {
+				resp, err := next.RoundTrip(req)
+
+				retry := false
+				switch {
+				case err != nil:
+					retry = idempotent || isDialError(err)
+				case idempotent:
+					retry = isTransientStatus(resp.StatusCode) || hasTransientCode(resp, cfg.TransientCodes)
+				}
+
+				if !retry || attempt >= cfg.MaxAttempts || req.Context().Err() != nil {
+					return resp, err
+				}
+
+				if resp != nil {
+					io.Copy(io.Discard, resp.Body)
+					resp.Body.Close()
+				}
+
+				if req.GetBody != nil {
+					body, bodyErr := req.GetBody()
+					if bodyErr != nil {
+						return nil, bodyErr
+					}
+					req.Body = body
+				}
+
+				timer := time.NewTimer(backoff(cfg, attempt))
+				select {
+				case <-timer.C:
+				case <-req.Context().Done():
+					timer.Stop()
+					return nil, req.Context().Err()
+				}
+			}
+		})
+	}
+}
+
+// backoff returns a random delay in [0, min(MaxDelay, BaseDelay * 2^(attempt-1))]
+func backoff(cfg RetryConfig, attempt int) time.Duration {
+	delay := cfg.BaseDelay << (attempt - 1)
+	if cfg.MaxDelay > 0 && (delay > cfg.MaxDelay || delay <= 0) {
+		delay = cfg.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	return rand.N(delay + 1)
+}
+
+// isDialError reports whether the connection failed before the request was sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func isTransientStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// hasTransientCode checks the API error code in the JSON body and restores the body for the caller
+func hasTransientCode(resp *http.Response, codes []int) bool {
+	if len(codes) == 0 || resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	var envelope struct {
+		Success bool `json:"success"`
+		Code    int  `json:"code"`
+	}
+	if json.Unmarshal(body, &envelope) != nil || envelope.Success {
+		return false
+	}
+
+	return slices.Contains(codes, envelope.Code)
+}
@@ -2,27 +2,60 @@ package httpmiddleware
 
 import (
 	"bytes"
+	"crypto/tls"
 	"io"
 	"net"
 	"net/http"
 	"time"
 )
 
+// TransportConfig tunes connection reuse of an http.Transport
+type TransportConfig struct {
+	MaxIdleConnsPerHost int  // Idle keep-alive connections kept per host (<= 0 - Go default of 2)
+	DisableHTTP2        bool // Use HTTP/1.1 only: a connection per in-flight request instead of one multiplexed connection
+	TLSSessionCacheSize int  // Cached TLS sessions for resumption (abbreviated handshake on reconnect), 0 disables
+}
+
+// DefaultTransportConfig returns the transport settings for external API calls
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConnsPerHost: 10,
+		TLSSessionCacheSize: 64,
+	}
+}
+
 // DefaultTransport returns a configured http.Transport suitable for external API calls.
 func DefaultTransport() *http.Transport {
-	return &http.Transport{
+	return NewTransport(DefaultTransportConfig())
+}
+
+// NewTransport returns an http.Transport for external API calls tuned by cfg.
+func NewTransport(cfg TransportConfig) *http.Transport {
+	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
 			Timeout:   30 * time.Second,
 			KeepAlive: 30 * time.Second,
 		}).DialContext,
-		ForceAttemptHTTP2:     true,
+		ForceAttemptHTTP2:     !cfg.DisableHTTP2,
 		MaxIdleConns:          100,
-		MaxIdleConnsPerHost:   10,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
 		IdleConnTimeout:       90 * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
 	}
+
+	if cfg.TLSSessionCacheSize > 0 {
+		transport.TLSClientConfig = &tls.Config{
+			ClientSessionCache: tls.NewLRUClientSessionCache(cfg.TLSSessionCacheSize),
+		}
+	}
+	if cfg.DisableHTTP2 {
+		// A non-nil empty map turns off the HTTP/2 upgrade even with a custom TLS config
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return transport
 }
 
 // Middleware is a function that wraps an http.RoundTripper.
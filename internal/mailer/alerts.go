@@ -0,0 +1,268 @@
+package mailer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ContactStorage - доступ к контактам пользователя
+type ContactStorage interface {
+	GetUserContact(userID int) (email string, emailVerified bool, telegramLinked bool, err error)
+	GetTelegramChatID(userID int) (int64, error)
+}
+
+// TelegramSender отправляет сообщения в Telegram
+type TelegramSender interface {
+	SendMessage(chatID int64, text string) error
+}
+
+// ModeStorage - сохраненный режим торговли приложений и список пользователей для уведомления
+type ModeStorage interface {
+	GetRuntimeState(key string) (string, error)
+	SetRuntimeState(key, value string) error
+	GetUserIDsWithAccounts() ([]int, error)
+}
+
+// Alert - критическое уведомление: текст для Telegram и письмо для email
+type Alert struct {
+	// Text - сообщение в Telegram. Пустой - Telegram уведомление отправляет вызывающий код,
+	// письмо уходит только пользователям без привязанного Telegram
+	Text    string
+	Subject string
+	Body    string
+}
+
+// Alerter доставляет критические уведомления: в Telegram, а если Telegram не привязан
+// или доставка в него не удалась - по email (подтвержденный адрес)
+type Alerter struct {
+	storage  ContactStorage
+	mailer   Mailer
+	telegram TelegramSender // nil - Telegram недоступен, только email
+	logger   *slog.Logger
+}
+
+// NewAlerter создает новый Alerter
+func NewAlerter(storage ContactStorage, mailer Mailer, logger *slog.Logger) *Alerter {
+	return &Alerter{
+		storage: storage,
+		mailer:  mailer,
+		logger:  logger,
+	}
+}
+
+// SetTelegram включает доставку критических уведомлений в Telegram (email остается запасным каналом)
+func (a *Alerter) SetTelegram(sender TelegramSender) {
+	a.telegram = sender
+}
+
+// Critical отправляет критическое уведомление в Telegram, при неудаче - по email
+func (a *Alerter) Critical(ctx context.Context, userID int, alert Alert) {
+	if a == nil {
+		return
+	}
+
+	email, verified, telegramLinked, err := a.storage.GetUserContact(userID)
+	if err != nil {
+		a.logger.Error("Failed to get user contact", slog.Int("user_id", userID), slog.Any("error", err))
+		return
+	}
+
+	if telegramLinked {
+		if alert.Text == "" {
+			return
+		}
+
+		err := a.sendTelegram(userID, alert.Text)
+		if err == nil {
+			return
+		}
+
+		a.logger.Warn("Failed to deliver critical alert to Telegram, falling back to email",
+			slog.Int("user_id", userID), slog.Any("error", err))
+	}
+
+	if email == "" || !verified {
+		return
+	}
+
+	if err := a.mailer.Send(ctx, email, alert.Subject, alert.Body); err != nil {
+		a.logger.Error("Failed to send alert email", slog.Int("user_id", userID), slog.Any("error", err))
+	}
+}
+
+func (a *Alerter) sendTelegram(userID int, text string) error {
+	if a.telegram == nil {
+		return fmt.Errorf("telegram is not configured")
+	}
+
+	chatID, err := a.storage.GetTelegramChatID(userID)
+	if err != nil {
+		return err
+	}
+
+	return a.telegram.SendMessage(chatID, text)
+}
+
+// AccountAuthExpired - токен MEXC аккаунта истек, нужно заново выполнить браузерный скрипт
+func (a *Alerter) AccountAuthExpired(ctx context.Context, userID int, accountName string) {
+	a.Critical(ctx, userID, Alert{
+		Text: fmt.Sprintf("🚨 Авторизация аккаунта %s истекла\n\n"+
+			"Copy trading для аккаунта не работает. Добавь аккаунт заново со свежими данными браузера.", accountName),
+		Subject: fmt.Sprintf("MEXC account %s: authorization expired", accountName),
+		Body: fmt.Sprintf("Authorization of MEXC account %q has expired.\n\n"+
+			"Copy trading for this account will fail until you re-add it with fresh browser data.", accountName),
+	})
+}
+
+// AccountBotChallenge - биржа отдала аккаунту anti-bot challenge, копирование на него приостановлено до until
+func (a *Alerter) AccountBotChallenge(ctx context.Context, userID int, accountName string, until time.Time) {
+	a.Critical(ctx, userID, Alert{
+		Text: fmt.Sprintf("🚨 MEXC вернула anti-bot проверку аккаунту %s\n\n"+
+			"Копирование на аккаунт приостановлено до %s. Если повторится - смени прокси аккаунта "+
+			"или пройди проверку в браузере и добавь аккаунт заново.", accountName, until.Format(time.TimeOnly)),
+		Subject: fmt.Sprintf("MEXC account %s: anti-bot challenge", accountName),
+		Body: fmt.Sprintf("MEXC answered account %q with an anti-bot challenge page instead of API data.\n\n"+
+			"Copy trading for this account is paused until %s. If it happens again, change the account proxy "+
+			"or pass the check in a browser and re-add the account.", accountName, until.Format(time.TimeOnly)),
+	})
+}
+
+// MasterBalanceDrop - equity master аккаунта резко упал (события счета WebSocket)
+func (a *Alerter) MasterBalanceDrop(ctx context.Context, userID int, accountName string, from, to float64) {
+	dropPct := (from - to) / from * 100
+
+	a.Critical(ctx, userID, Alert{
+		Text: fmt.Sprintf("⚠️ Баланс master аккаунта %s упал на %.1f%%\n\n"+
+			"Equity: %.2f → %.2f USDT. Проверь позиции мастера и slave аккаунтов.", accountName, dropPct, from, to),
+		Subject: fmt.Sprintf("MEXC master account %s: balance dropped %.1f%%", accountName, dropPct),
+		Body: fmt.Sprintf("Equity of master account %q dropped from %.2f to %.2f USDT (%.1f%%).\n\n"+
+			"Check the positions of the master and slave accounts.", accountName, from, to, dropPct),
+	})
+}
+
+// LiquidationRisk - позиция master или slave аккаунта приближается к ликвидации
+func (a *Alerter) LiquidationRisk(ctx context.Context, userID int, accountName string, master bool, symbol string, liquidatePrice float64) {
+	role := "slave"
+	if master {
+		role = "master"
+	}
+
+	a.Critical(ctx, userID, Alert{
+		Text: fmt.Sprintf("🚨 Позиция %s на %s аккаунте %s близка к ликвидации\n\n"+
+			"Цена ликвидации: %g. Пополни маржу или сократи позицию.", symbol, role, accountName, liquidatePrice),
+		Subject: fmt.Sprintf("MEXC %s account %s: %s close to liquidation", role, accountName, symbol),
+		Body: fmt.Sprintf("Position %s of %s account %q is close to liquidation (liquidation price %g).\n\n"+
+			"Add margin or reduce the position.", symbol, role, accountName, liquidatePrice),
+	})
+}
+
+// DailyLossLimit - дневной реализованный убыток slave аккаунта превысил предел: входы на аккаунт не копируются
+// до конца дня (UTC) или сессия copy trading остановлена (halted)
+func (a *Alerter) DailyLossLimit(ctx context.Context, userID int, accountName string, pnl, limit float64, halted bool) {
+	action := "Входы мастера на аккаунт не копируются до конца дня (UTC), закрытия копируются."
+	actionEn := "Master entries are not copied to this account until the end of the day (UTC); closes are still copied."
+	if halted {
+		action = "Copy trading остановлен, запусти его заново, когда будешь готов."
+		actionEn = "Your copy trading session was stopped. Start it again when you are ready."
+	}
+
+	a.Critical(ctx, userID, Alert{
+		Text: fmt.Sprintf("🛑 Дневной убыток slave аккаунта %s: %.2f USDT (предел %.2f USDT)\n\n%s",
+			accountName, pnl, limit, action),
+		Subject: fmt.Sprintf("MEXC account %s: daily loss limit reached", accountName),
+		Body: fmt.Sprintf("Realized PnL of slave account %q today is %.2f USDT, the daily loss limit is %.2f USDT.\n\n%s",
+			accountName, pnl, limit, actionEn),
+	})
+}
+
+// SlaveAutoDisabled - slave аккаунт автоматически отключен (Telegram уведомление отправляет бот)
+func (a *Alerter) SlaveAutoDisabled(ctx context.Context, userID int, accountName, reason string) {
+	a.Critical(ctx, userID, Alert{
+		Subject: fmt.Sprintf("MEXC account %s was disabled", accountName),
+		Body:    fmt.Sprintf("Account %q was automatically excluded from copy trading.\n\nReason: %s", accountName, reason),
+	})
+}
+
+// SessionStopped - сессия copy trading остановлена автоматически: WebSocket соединение master аккаунта разорвано
+func (a *Alerter) SessionStopped(ctx context.Context, userID int, masterName string) {
+	a.Critical(ctx, userID, Alert{
+		Text: fmt.Sprintf("🚨 Copy trading остановлен\n\nWebSocket соединение master аккаунта %s разорвано. "+
+			"Сделки не копируются, запусти copy trading заново.", masterName),
+		Subject: "Copy trading stopped",
+		Body: fmt.Sprintf("Your copy trading session was stopped automatically: "+
+			"the WebSocket connection of master account %q was lost.\n\n"+
+			"Master trades are not copied until you start copy trading again.", masterName),
+	})
+}
+
+// SessionReconnecting - WebSocket соединение master аккаунта разорвано, сессия переподключается
+func (a *Alerter) SessionReconnecting(ctx context.Context, userID int, masterName string) {
+	a.Critical(ctx, userID, Alert{
+		Text: fmt.Sprintf("⚠️ WebSocket соединение master аккаунта %s разорвано\n\n"+
+			"Переподключаюсь. Пока соединения нет, сделки мастера не копируются.", masterName),
+		Subject: "Copy trading connection lost, reconnecting",
+		Body: fmt.Sprintf("The WebSocket connection of master account %q was lost and is being re-established.\n\n"+
+			"Master trades are not copied until the connection is back.", masterName),
+	})
+}
+
+// SessionReconnected - WebSocket соединение master аккаунта восстановлено
+func (a *Alerter) SessionReconnected(ctx context.Context, userID int, masterName string) {
+	a.Critical(ctx, userID, Alert{
+		Text:    fmt.Sprintf("✅ WebSocket соединение master аккаунта %s восстановлено, copy trading продолжается", masterName),
+		Subject: "Copy trading connection restored",
+		Body:    fmt.Sprintf("The WebSocket connection of master account %q is restored, copy trading continues.", masterName),
+	})
+}
+
+// LiveTradingEnabled - приложение app перезапущено без DRY_RUN: сделки снова открываются на бирже
+func (a *Alerter) LiveTradingEnabled(ctx context.Context, userID int, app string) {
+	a.Critical(ctx, userID, Alert{
+		Text:    fmt.Sprintf("🚨 %s переключен из DRY RUN в боевой режим\n\nСделки master аккаунта копируются на slave аккаунты реальными ордерами.", app),
+		Subject: fmt.Sprintf("%s switched from dry run to live trading", app),
+		Body: fmt.Sprintf("%s was restarted with DRY_RUN disabled.\n\n"+
+			"Master trades are now copied to slave accounts with real orders.", app),
+	})
+}
+
+// CheckTradingMode сохраняет режим DRY_RUN приложения app и при переключении dry-run → live
+// уведомляет всех пользователей с аккаунтами
+func (a *Alerter) CheckTradingMode(ctx context.Context, storage ModeStorage, app string, dryRun bool) {
+	if a == nil {
+		return
+	}
+
+	key := "dry_run:" + app
+	current := fmt.Sprint(dryRun)
+
+	previous, err := storage.GetRuntimeState(key)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		a.logger.Error("Failed to get trading mode", slog.String("app", app), slog.Any("error", err))
+		return
+	}
+
+	if err := storage.SetRuntimeState(key, current); err != nil {
+		a.logger.Error("Failed to save trading mode", slog.String("app", app), slog.Any("error", err))
+		return
+	}
+
+	if previous != "true" || dryRun {
+		return
+	}
+
+	a.logger.Warn("⚠️ Switched from dry run to live trading", slog.String("app", app))
+
+	userIDs, err := storage.GetUserIDsWithAccounts()
+	if err != nil {
+		a.logger.Error("Failed to get users for trading mode alert", slog.Any("error", err))
+		return
+	}
+
+	for _, userID := range userIDs {
+		a.LiveTradingEnabled(ctx, userID, app)
+	}
+}
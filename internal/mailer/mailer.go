@@ -0,0 +1,129 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mailer отправляет email
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+	SendHTML(ctx context.Context, to, subject, html string) error
+	Enabled() bool
+}
+
+// Config - настройки SMTP
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// New создает SMTP mailer; если SMTP не настроен - mailer, который только логирует письма
+func New(cfg Config, logger *slog.Logger) Mailer {
+	if cfg.Host == "" || cfg.From == "" {
+		return &logMailer{logger: logger}
+	}
+
+	return &smtpMailer{cfg: cfg, logger: logger}
+}
+
+// smtpMailer отправляет письма через SMTP (STARTTLS, если сервер поддерживает)
+type smtpMailer struct {
+	cfg    Config
+	logger *slog.Logger
+}
+
+func (m *smtpMailer) Enabled() bool {
+	return true
+}
+
+func (m *smtpMailer) Send(ctx context.Context, to, subject, body string) error {
+	return m.send(ctx, to, subject, buildMessage(m.cfg.From, to, subject, "text/plain", body))
+}
+
+func (m *smtpMailer) SendHTML(ctx context.Context, to, subject, html string) error {
+	return m.send(ctx, to, subject, buildMessage(m.cfg.From, to, subject, "text/html", html))
+}
+
+func (m *smtpMailer) send(ctx context.Context, to, subject string, msg []byte) error {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	// net/smtp не поддерживает context - отправляем в горутине и ждем ctx
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(addr, auth, m.cfg.From, []string{to}, msg)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+
+		m.logger.Info("📧 Email sent", slog.String("to", to), slog.String("subject", subject))
+
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// logMailer используется без SMTP - в лог пишутся только получатель и тема письма: тело содержит
+// одноразовые ссылки (сброс пароля, подтверждение email), которые нельзя раскрывать через логи
+type logMailer struct {
+	logger *slog.Logger
+}
+
+func (m *logMailer) Enabled() bool {
+	return false
+}
+
+func (m *logMailer) Send(_ context.Context, to, subject, body string) error {
+	m.logger.Warn("📧 SMTP not configured, email not sent",
+		slog.String("to", to),
+		slog.String("subject", subject),
+		slog.Int("body_bytes", len(body)))
+
+	return nil
+}
+
+func (m *logMailer) SendHTML(_ context.Context, to, subject, html string) error {
+	m.logger.Warn("📧 SMTP not configured, email not sent",
+		slog.String("to", to),
+		slog.String("subject", subject),
+		slog.Int("html_bytes", len(html)))
+
+	return nil
+}
+
+func buildMessage(from, to, subject, contentType, body string) []byte {
+	// Заголовки не должны содержать переводов строк (header injection)
+	header := strings.NewReplacer("\r", " ", "\n", " ")
+	from, to, subject = header.Replace(from), header.Replace(to), header.Replace(subject)
+
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + to + "\r\n")
+	b.WriteString("Subject: " + subject + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: " + contentType + "; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return []byte(b.String())
+}
@@ -0,0 +1,114 @@
+package mexc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"tg_mexc/internal/models"
+)
+
+// AccountStatusCacheTTL - время жизни закэшированного состояния аккаунта (GetAccountStatusCached)
+const AccountStatusCacheTTL = 5 * time.Minute
+
+// GetAccountStatus запрашивает состояние аккаунта: профиль пользователя (KYC, заморозка)
+// и доступ к фьючерсному счету. Ошибка API фьючерсного счета (кроме авторизации) - не ошибка метода,
+// а FuturesEnabled = false с причиной в Issues. Истекшая авторизация и сетевые ошибки возвращаются как есть
+func (c *Client) GetAccountStatus(ctx context.Context) (*models.AccountStatus, error) {
+	timestamp := c.timestamp()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", c.baseURL+userInfoEndpoint, http.NoBody)
+	c.setGetHeaders(req, timestamp)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("GetAccountStatus failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
+
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, newAPIError("GetAccountStatus", 401, resp.Status)
+	}
+
+	// Ответы ucenter: {"code": 0, "data": {...}}; код 0 или 200 - успех
+	if apiErr := apiErrorFromBody("GetAccountStatus", body); apiErr.Code != 0 && apiErr.Code != http.StatusOK {
+		c.logger.Error("GetAccountStatus API error",
+			slog.String("account", c.account.Name),
+			slog.String("response", string(body)))
+
+		return nil, apiErr
+	}
+
+	var info struct {
+		Data struct {
+			KYCLevel int  `json:"kycLevel"`
+			Frozen   bool `json:"frozen"`
+		} `json:"data"`
+	}
+	json.Unmarshal(body, &info)
+
+	status := &models.AccountStatus{
+		KYCLevel:       info.Data.KYCLevel,
+		Restricted:     info.Data.Frozen,
+		FuturesEnabled: true,
+		CheckedAt:      c.clock.Now(),
+	}
+
+	if _, err := c.GetBalance(ctx); err != nil {
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || IsAuthError(err) {
+			return nil, err
+		}
+
+		status.FuturesEnabled = false
+		status.Issues = append(status.Issues, "фьючерсы недоступны: "+err.Error())
+	}
+	if status.Restricted {
+		status.Issues = append(status.Issues, "аккаунт ограничен биржей")
+	}
+	if status.KYCLevel == 0 {
+		status.Issues = append(status.Issues, "KYC не пройден")
+	}
+
+	return status, nil
+}
+
+// GetAccountStatusCached возвращает состояние аккаунта из кэша клиента, после AccountStatusCacheTTL -
+// запросом к бирже. Состояние выводится в списке аккаунтов, который web интерфейс обновляет каждые 5 секунд
+func (c *Client) GetAccountStatusCached(ctx context.Context) (*models.AccountStatus, error) {
+	c.statusMu.Lock()
+	cached := c.status
+	c.statusMu.Unlock()
+
+	if cached != nil && c.clock.Now().Sub(cached.CheckedAt) < AccountStatusCacheTTL {
+		status := *cached
+		return &status, nil
+	}
+
+	return c.RefreshAccountStatus(ctx)
+}
+
+// RefreshAccountStatus запрашивает состояние аккаунта у биржи в обход кэша и обновляет кэш
+func (c *Client) RefreshAccountStatus(ctx context.Context) (*models.AccountStatus, error) {
+	status, err := c.GetAccountStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.statusMu.Lock()
+	c.status = status
+	c.statusMu.Unlock()
+
+	result := *status
+	return &result, nil
+}
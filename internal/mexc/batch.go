@@ -0,0 +1,35 @@
+package mexc
+
+import (
+	"context"
+	"sync"
+
+	"tg_mexc/internal/models"
+)
+
+// OrderResult - результат одного ордера PlaceOrders
+type OrderResult struct {
+	OrderID string
+	Err     error
+}
+
+// PlaceOrders размещает пачку ордеров на открытие позиций (MarketOrder, LimitOrder) одним вызовом.
+// Batch endpoint MEXC (order/submit_batch) доступен только с API ключом, поэтому ордера web сессии
+// отправляются конвейером: все сразу, не дожидаясь ответа на предыдущий. Темп запросов аккаунта
+// по-прежнему ограничивает rate limiter клиента. Результаты - в порядке orders
+func (c *Client) PlaceOrders(ctx context.Context, orders []models.OpenPositionRequest) []OrderResult {
+	results := make([]OrderResult, len(orders))
+	if len(orders) == 0 {
+		return results
+	}
+
+	var wg sync.WaitGroup
+	for i, order := range orders {
+		wg.Go(func() {
+			results[i].OrderID, results[i].Err = c.placeOrder(ctx, order)
+		})
+	}
+	wg.Wait()
+
+	return results
+}
@@ -6,15 +6,19 @@ import (
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"tg_mexc/internal/clock"
 	"tg_mexc/internal/httpmiddleware"
 	"tg_mexc/internal/models"
 
@@ -22,8 +26,6 @@ import (
 )
 
 const (
-	baseURL = "https://www.mexc.com"
-
 	// API endpoints
 	orderCreateEndpoint        = "/api/platform/futures/api/v1/private/order/create"
 	positionsEndpoint          = "/api/platform/futures/api/v1/private/position/open_positions"
@@ -36,14 +38,74 @@ const (
 	openOrdersEndpoint         = "/api/platform/futures/api/v1/private/order/list/open_orders"
 	tieredFeeRateEndpoint      = "/api/platform/futures/api/v1/private/account/tiered_fee_rate/v2"
 	changeLeverageEndpoint     = "/api/platform/futures/api/v1/private/position/change_leverage"
+	historyPositionsEndpoint   = "/api/platform/futures/api/v1/private/position/list/history_positions"
+	historyOrdersEndpoint      = "/api/platform/futures/api/v1/private/order/list/history_orders"
+	orderGetEndpoint           = "/api/platform/futures/api/v1/private/order/get/"
+	contractDetailEndpoint     = "/api/platform/futures/api/v1/contract/detail"
+	fundingRecordsEndpoint     = "/api/platform/futures/api/v1/private/position/funding_records"
+	trackOrderPlaceEndpoint    = "/api/platform/futures/api/v1/private/trackorder/place"
+	trackOrderChangeEndpoint   = "/api/platform/futures/api/v1/private/trackorder/change_order"
+	trackOrderCancelEndpoint   = "/api/platform/futures/api/v1/private/trackorder/cancel"
+	trackOrderListEndpoint     = "/api/platform/futures/api/v1/private/trackorder/list/orders"
+	orderCancelEndpoint        = "/api/platform/futures/api/v1/private/order/cancel"
+	orderCancelAllEndpoint     = "/api/platform/futures/api/v1/private/order/cancel_all"
+	fundingRateEndpoint        = "/api/platform/futures/api/v1/contract/funding_rate/"
+	fundingRateHistoryEndpoint = "/api/platform/futures/api/v1/contract/funding_rate/history"
+	tickerEndpoint             = "/api/platform/futures/api/v1/contract/ticker"
+	depthEndpoint              = "/api/platform/futures/api/v1/contract/depth/"
+	klineEndpoint              = "/api/platform/futures/api/v1/contract/kline/"
+)
+
+// Типы ордеров MEXC (поле type при создании ордера и orderType в событиях)
+const (
+	OrderTypeLimit    = 1
+	OrderTypePostOnly = 2
+	OrderTypeIOC      = 3 // Immediate or cancel: неисполненный остаток отменяется
+	OrderTypeFOK      = 4 // Fill or kill: исполняется целиком или отменяется
+	OrderTypeMarket   = 5
 )
 
+// Режимы маржи MEXC (поле openType ордеров и позиций)
+const (
+	OpenTypeIsolated = 1
+	OpenTypeCross    = 2
+)
+
+// MarginOpenType возвращает режим маржи для ордера: неизвестный режим (0) - isolated
+func MarginOpenType(openType int) int {
+	if openType == OpenTypeCross {
+		return OpenTypeCross
+	}
+	return OpenTypeIsolated
+}
+
 // Client - клиент для работы с MEXC API
 type Client struct {
 	account    models.Account
 	httpClient *http.Client
 	logger     *slog.Logger
 	baseURL    string
+	clock      clock.Clock
+	limiter    *httpmiddleware.RateLimiter // Общий для всех клиентов аккаунта, nil - без лимита
+	proxies    *httpmiddleware.ProxyPool   // Общий для всех клиентов аккаунта, nil - без прокси
+	hosts      *httpmiddleware.HostPool    // Основной адрес REST API и зеркала, nil - без зеркал
+	base       http.RoundTripper           // Сетевой transport под middleware
+	retry      httpmiddleware.RetryConfig
+
+	modeMu sync.Mutex
+	mode   positionModeEntry // Режим позиций аккаунта, zero - еще не запрошен (positionMode)
+
+	leverageMu sync.Mutex
+	leverages  map[string]leverageEntry // symbol -> leverage (cachedLeverage)
+
+	feeRateMu sync.Mutex
+	feeRates  map[string]feeRateEntry // symbol -> комиссии (GetTieredFeeRateCached)
+
+	statusMu sync.Mutex
+	status   *models.AccountStatus // Состояние аккаунта (GetAccountStatusCached), nil - еще не запрошено
+
+	equityMu sync.Mutex
+	equity   equityEntry // Equity фьючерсного счета (GetEquityCached), zero - еще не запрошен
 }
 
 // NewClient создает новый MEXC клиент для аккаунта
@@ -51,40 +113,39 @@ func NewClient(account models.Account, logger *slog.Logger) (*Client, error) {
 	jar, _ := cookiejar.New(nil)
 
 	// Базовый transport
-	baseTransport := httpmiddleware.DefaultTransport()
-
-	// Если есть прокси - настраиваем
-	if account.Proxy != "" {
-		proxyURL, err := url.Parse(account.Proxy)
-		if err != nil {
-			logger.Error("Invalid proxy",
-				slog.String("account", account.Name),
-				slog.Any("error", err))
-		} else {
-			baseTransport.Proxy = http.ProxyURL(proxyURL)
-
-			logger.Info("Using proxy",
-				slog.String("account", account.Name),
-				slog.String("proxy", account.Proxy))
-		}
-	}
+	baseTransport := httpmiddleware.NewTransport(transportConfig())
+
+	// Если есть прокси - настраиваем пул (один прокси или несколько с ротацией)
+	proxies, err := accountProxyPool(account)
+	if err != nil {
+		logger.Error("Invalid proxy",
+			slog.String("account", account.Name),
+			slog.Any("error", err))
+	} else if proxies != nil {
+		baseTransport.Proxy = proxies.Proxy
 
-	httpClient := &http.Client{
-		Jar:     jar,
-		Timeout: 30 * time.Second,
-		Transport: httpmiddleware.Wrap(
-			baseTransport,
-			httpmiddleware.RequestGetBodySetter,
-			httpmiddleware.Logger(logger, -1),
-		),
+		logger.Info("Using proxy",
+			slog.String("account", account.Name),
+			slog.String("proxy", proxies.Current().Redacted()))
 	}
 
+	baseURLs := BaseURLs()
+
 	client := &Client{
 		account:    account,
-		httpClient: httpClient,
+		httpClient: &http.Client{Jar: jar, Timeout: 30 * time.Second},
 		logger:     logger,
-		baseURL:    baseURL,
-	}
+		baseURL:    baseURLs[0],
+		clock:      clock.Real,
+		limiter:    accountLimiter(account),
+		proxies:    proxies,
+		hosts:      newHostPool(baseURLs, logger),
+		base:       baseTransport,
+		retry:      httpmiddleware.DefaultRetryConfig(),
+		leverages:  make(map[string]leverageEntry),
+		feeRates:   make(map[string]feeRateEntry),
+	}
+	client.httpClient.Transport = client.wrapTransport()
 
 	// Устанавливаем cookies
 	client.setCookies()
@@ -92,6 +153,44 @@ func NewClient(account models.Account, logger *slog.Logger) (*Client, error) {
 	return client, nil
 }
 
+// SetClock подменяет источник времени (для подписей и timestamp'ов)
+func (c *Client) SetClock(clk clock.Clock) {
+	c.clock = clk
+}
+
+// SetBaseTransport подменяет сетевой transport под middleware клиента
+// (benchmark: запросы подписываются и проходят middleware, но не уходят в сеть)
+func (c *Client) SetBaseTransport(base http.RoundTripper) {
+	c.base = base
+	c.httpClient.Transport = c.wrapTransport()
+}
+
+// SetRetryConfig задает повторы запросов клиента (MaxAttempts <= 1 отключает)
+func (c *Client) SetRetryConfig(cfg httpmiddleware.RetryConfig) {
+	c.retry = cfg
+	c.httpClient.Transport = c.wrapTransport()
+}
+
+// wrapTransport оборачивает сетевой transport в middleware клиента.
+// Каждый повтор проходит лимит аккаунта; ожидание лимита не входит в длительность запроса в логе.
+// Ошибка соединения переключает пул прокси, поэтому повтор идет через следующий прокси.
+// DNS / TLS ошибка или 5xx переключает адрес REST API на следующее зеркало (если заданы).
+// HTML вместо JSON (anti-bot challenge) возвращается как ErrBotChallenge и тоже переключает прокси.
+// Соединение каждой попытки учитывается в статистике переиспользования (TransportStats)
+func (c *Client) wrapTransport() http.RoundTripper {
+	return httpmiddleware.Wrap(
+		c.base,
+		httpmiddleware.RequestGetBodySetter,
+		httpmiddleware.Retry(c.retry),
+		httpmiddleware.RateLimit(c.limiter),
+		httpmiddleware.HostFailover(c.hosts),
+		httpmiddleware.ProxyFailover(c.proxies),
+		httpmiddleware.BotChallenge,
+		httpmiddleware.Logger(c.logger, -1),
+		httpmiddleware.ConnMetrics(&connStats),
+	)
+}
+
 // setCookies устанавливает cookies из аккаунта
 func (c *Client) setCookies() {
 	u, _ := url.Parse(c.baseURL)
@@ -104,7 +203,7 @@ func (c *Client) setCookies() {
 		httpCookies = append(httpCookies, &http.Cookie{
 			Name:   key,
 			Value:  sanitizedValue,
-			Domain: ".mexc.com",
+			Domain: cookieDomain(u),
 			Path:   "/",
 		})
 	}
@@ -184,6 +283,40 @@ func (c *Client) setHeaders(req *http.Request, timestamp int64, signature string
 	req.Header.Set("sentry-trace", sentryTrace)
 }
 
+// signedGetEndpoints - приватные GET endpoints, которые без x-mxc-sign периодически отклоняются.
+// Подпись GET запроса считается по query строке так же, как по телу POST запроса
+var signedGetEndpoints = []string{
+	positionsEndpoint,
+	stopLossOpenOrdersEndpoint,
+	openOrdersEndpoint,
+	historyOrdersEndpoint,
+	historyPositionsEndpoint,
+	orderGetEndpoint,
+	orderExternalEndpoint,
+	trackOrderListEndpoint,
+}
+
+// setGetHeaders устанавливает заголовки GET запроса; endpoints из signedGetEndpoints подписываются
+func (c *Client) setGetHeaders(req *http.Request, timestamp int64) {
+	signature := ""
+	if isSignedGet(req.URL.Path) {
+		signature = c.generateSignature(timestamp, []byte(req.URL.RawQuery))
+	}
+
+	c.setHeaders(req, timestamp, signature)
+}
+
+// isSignedGet сообщает, что GET запрос по path нужно подписать (orderGetEndpoint - префикс с ID ордера)
+func isSignedGet(path string) bool {
+	for _, endpoint := range signedGetEndpoints {
+		if path == endpoint || (strings.HasSuffix(endpoint, "/") && strings.HasPrefix(path, endpoint)) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func generateUUID() string {
 	return uuid.New().String()
 }
@@ -193,26 +326,109 @@ func generateShortID() string {
 }
 
 // PlaceOrder размещает ордер (открывает позицию)
-// stopLossPrice - опциональный параметр для установки stop loss при создании ордера (передать 0 если не нужен)
-func (c *Client) PlaceOrder(ctx context.Context, symbol string, side int, vol int, leverage int, stopLossPrice ...float64) (string, error) {
-	timestamp := time.Now().UnixMilli()
+// openType - режим маржи (OpenTypeIsolated, OpenTypeCross; 0 - isolated)
+// stopLossPrice - опциональный параметр для установки stop loss при создании ордера (передать 0 если не нужен),
+// отправляется с точностью символа (FormatPrice)
+func (c *Client) PlaceOrder(ctx context.Context, symbol string, side int, vol int, leverage int, openType int, stopLossPrice ...float64) (string, error) {
+	orderReq := MarketOrder(symbol, side, vol, leverage, openType, 0)
+	c.setStopLoss(ctx, &orderReq, stopLoss(stopLossPrice))
+
+	return c.placeOrder(ctx, orderReq)
+}
+
+// PlaceLimitOrder размещает лимитный ордер на открытие позиции по цене price
+// openType и stopLossPrice - как в PlaceOrder
+func (c *Client) PlaceLimitOrder(ctx context.Context, symbol string, side int, vol int, leverage int, openType int, price float64, stopLossPrice ...float64) (string, error) {
+	if price <= 0 {
+		return "", fmt.Errorf("invalid limit price: %v", price)
+	}
+
+	orderReq := LimitOrder(symbol, side, vol, leverage, openType, price, 0)
+	orderReq.Price = c.FormatPrice(ctx, symbol, price)
+	c.setStopLoss(ctx, &orderReq, stopLoss(stopLossPrice))
+
+	return c.placeOrder(ctx, orderReq)
+}
 
+// MarketOrder собирает market ордер на открытие позиции (для PlaceOrders).
+// stopLossPrice уже округлена по шагу контракта (ContractDetail.RoundPrice), 0 - без stop loss
+func MarketOrder(symbol string, side int, vol int, leverage int, openType int, stopLossPrice float64) models.OpenPositionRequest {
 	orderReq := models.OpenPositionRequest{
 		Symbol:        symbol,
 		Side:          side,
-		OpenType:      1,   // 1: isolated
-		Type:          "5", // "5": market order (СТРОКА!)
+		OpenType:      MarginOpenType(openType),
+		Type:          strconv.Itoa(OrderTypeMarket), // СТРОКА!
 		Vol:           vol,
 		Leverage:      leverage,
 		MarketCeiling: false,
 		PriceProtect:  "0",
+		ExternalOid:   NewExternalOid(),
 	}
+	setStopLoss(&orderReq, stopLossPrice)
 
-	// Добавляем stop loss если указан
-	if len(stopLossPrice) > 0 && stopLossPrice[0] > 0 {
-		orderReq.StopLossPrice = fmt.Sprintf("%.1f", stopLossPrice[0])
+	return orderReq
+}
+
+// LimitOrder собирает limit ордер на открытие позиции по цене price (для PlaceOrders)
+func LimitOrder(symbol string, side int, vol int, leverage int, openType int, price float64, stopLossPrice float64) models.OpenPositionRequest {
+	orderReq := models.OpenPositionRequest{
+		Symbol:       symbol,
+		Side:         side,
+		OpenType:     MarginOpenType(openType),
+		Type:         strconv.Itoa(OrderTypeLimit), // СТРОКА!
+		Price:        strconv.FormatFloat(price, 'f', -1, 64),
+		Vol:          vol,
+		Leverage:     leverage,
+		PriceProtect: "0",
+		ExternalOid:  NewExternalOid(),
+	}
+	setStopLoss(&orderReq, stopLossPrice)
+
+	return orderReq
+}
+
+// setStopLoss добавляет stop loss к ордеру, если он указан
+func setStopLoss(orderReq *models.OpenPositionRequest, stopLossPrice float64) {
+	if stopLossPrice > 0 {
+		orderReq.StopLossPrice = strconv.FormatFloat(stopLossPrice, 'f', -1, 64)
 		orderReq.LossTrend = "1" // "1": latest price (СТРОКА!)
 	}
+}
+
+// setStopLoss добавляет stop loss к ордеру с точностью символа
+func (c *Client) setStopLoss(ctx context.Context, orderReq *models.OpenPositionRequest, stopLossPrice float64) {
+	setStopLoss(orderReq, stopLossPrice)
+	if orderReq.StopLossPrice != "" {
+		orderReq.StopLossPrice = c.FormatPrice(ctx, orderReq.Symbol, stopLossPrice)
+	}
+}
+
+// stopLoss возвращает опциональный stop loss (0 если не передан)
+func stopLoss(stopLossPrice []float64) float64 {
+	if len(stopLossPrice) > 0 {
+		return stopLossPrice[0]
+	}
+	return 0
+}
+
+// placeOrder размещает ордер. Если ответ потерян (ошибка транспорта, а не отказ биржи),
+// ордер с externalOid проверяется на бирже (recoverOrder), чтобы не открыть позицию дважды
+func (c *Client) placeOrder(ctx context.Context, orderReq models.OpenPositionRequest) (string, error) {
+	orderReq.Side, orderReq.ReduceOnly = c.orderSide(ctx, orderReq.Side)
+
+	orderID, err := c.sendOrder(ctx, orderReq)
+	var apiErr *APIError
+	if err == nil || orderReq.ExternalOid == "" || errors.As(err, &apiErr) {
+		c.invalidatePositionMode(err)
+		return orderID, err
+	}
+
+	return c.recoverOrder(ctx, orderReq, err)
+}
+
+// sendOrder отправляет ордер на биржу
+func (c *Client) sendOrder(ctx context.Context, orderReq models.OpenPositionRequest) (string, error) {
+	timestamp := c.timestamp()
 
 	body, _ := json.Marshal(orderReq)
 	signature := c.generateSignature(timestamp, body)
@@ -243,11 +459,12 @@ func (c *Client) PlaceOrder(ctx context.Context, symbol string, side int, vol in
 			slog.Int("code", orderResp.Code),
 			slog.String("message", orderResp.Message))
 
-		return "", fmt.Errorf("order failed: %s", orderResp.Message)
+		return "", newAPIError("order", orderResp.Code, orderResp.Message)
 	}
 
 	c.logger.Info("✅ PlaceOrder success",
 		slog.String("account", c.account.Name),
+		slog.String("type", orderReq.Type),
 		slog.String("orderId", orderResp.Data.OrderID))
 
 	return orderResp.Data.OrderID, nil
@@ -255,7 +472,7 @@ func (c *Client) PlaceOrder(ctx context.Context, symbol string, side int, vol in
 
 // GetPositions получает позиции
 func (c *Client) GetPositions(ctx context.Context, symbol string) ([]models.Position, error) {
-	timestamp := time.Now().UnixMilli()
+	timestamp := c.timestamp()
 
 	apiURL := c.baseURL + positionsEndpoint
 	if symbol != "" {
@@ -263,7 +480,7 @@ func (c *Client) GetPositions(ctx context.Context, symbol string) ([]models.Posi
 	}
 
 	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
-	c.setHeaders(req, timestamp, "")
+	c.setGetHeaders(req, timestamp)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -289,7 +506,7 @@ func (c *Client) GetPositions(ctx context.Context, symbol string) ([]models.Posi
 			slog.String("account", c.account.Name),
 			slog.String("response", string(body)))
 
-		return nil, fmt.Errorf("API error: %s", string(body))
+		return nil, apiErrorFromBody("GetPositions", body)
 	}
 
 	return result.Data, nil
@@ -297,12 +514,12 @@ func (c *Client) GetPositions(ctx context.Context, symbol string) ([]models.Posi
 
 // GetBalance получает баланс
 func (c *Client) GetBalance(ctx context.Context) ([]models.Balance, error) {
-	timestamp := time.Now().UnixMilli()
+	timestamp := c.timestamp()
 
 	apiURL := c.baseURL + accountAssetsEndpoint
 
 	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
-	c.setHeaders(req, timestamp, "")
+	c.setGetHeaders(req, timestamp)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -328,7 +545,7 @@ func (c *Client) GetBalance(ctx context.Context) ([]models.Balance, error) {
 			slog.String("account", c.account.Name),
 			slog.String("response", string(body)))
 
-		return nil, fmt.Errorf("API error: %s", string(body))
+		return nil, apiErrorFromBody("GetBalance", body)
 	}
 
 	return result.Data, nil
@@ -336,12 +553,12 @@ func (c *Client) GetBalance(ctx context.Context) ([]models.Balance, error) {
 
 // GetLeverage получает текущий leverage для символа
 func (c *Client) GetLeverage(ctx context.Context, symbol string) ([]models.LeverageInfo, error) {
-	timestamp := time.Now().UnixMilli()
+	timestamp := c.timestamp()
 
 	apiURL := c.baseURL + leverageEndpoint + "?symbol=" + symbol
 
 	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
-	c.setHeaders(req, timestamp, "")
+	c.setGetHeaders(req, timestamp)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -367,7 +584,7 @@ func (c *Client) GetLeverage(ctx context.Context, symbol string) ([]models.Lever
 			slog.String("account", c.account.Name),
 			slog.String("response", string(body)))
 
-		return nil, fmt.Errorf("API error: %s", string(body))
+		return nil, apiErrorFromBody("GetLeverage", body)
 	}
 
 	return result.Data, nil
@@ -375,11 +592,22 @@ func (c *Client) GetLeverage(ctx context.Context, symbol string) ([]models.Lever
 
 // GetLeverageForSide получает leverage для конкретной стороны (long/short)
 func (c *Client) GetLeverageForSide(ctx context.Context, symbol string, side int) (int, error) {
-	leverages, err := c.GetLeverage(ctx, symbol)
+	info, err := c.GetLeverageInfoForSide(ctx, symbol, side)
 	if err != nil {
 		return 0, err
 	}
 
+	return info.Leverage, nil
+}
+
+// GetLeverageInfoForSide получает leverage и ступень риск-лимита (Level, MaxVol) для стороны ордера.
+// Значение берется из кэша клиента (LeverageCacheTTL): запрос вызывается на каждом копируемом открытии
+func (c *Client) GetLeverageInfoForSide(ctx context.Context, symbol string, side int) (models.LeverageInfo, error) {
+	leverages, err := c.cachedLeverage(ctx, symbol)
+	if err != nil {
+		return models.LeverageInfo{}, err
+	}
+
 	// Определяем нужный positionType на основе side
 	// side 1 = open long -> positionType 1
 	// side 3 = open short -> positionType 2
@@ -397,15 +625,20 @@ func (c *Client) GetLeverageForSide(ctx context.Context, symbol string, side int
 				slog.Int("positionType", positionType),
 				slog.Int("leverage", lev.Leverage))
 
-			return lev.Leverage, nil
+			return lev, nil
 		}
 	}
 
-	return 0, fmt.Errorf("leverage not found for positionType %d", positionType)
+	return models.LeverageInfo{}, fmt.Errorf("leverage not found for positionType %d", positionType)
 }
 
 // ClosePosition закрывает позицию
 func (c *Client) ClosePosition(ctx context.Context, symbol string) error {
+	return c.ClosePositionType(ctx, symbol, 0)
+}
+
+// ClosePositionType закрывает позицию одного направления (1 = long, 2 = short, 0 = обе)
+func (c *Client) ClosePositionType(ctx context.Context, symbol string, positionType int) error {
 	c.logger.Info("Closing position",
 		slog.String("account", c.account.Name),
 		slog.String("symbol", symbol))
@@ -424,84 +657,120 @@ func (c *Client) ClosePosition(ctx context.Context, symbol string) error {
 	}
 
 	for _, pos := range positions {
-		if pos.Symbol == symbol && pos.HoldVol > 0 {
-			closeSide := 4 // close long
-			posTypeText := "LONG"
-			if pos.PositionType == 2 {
-				closeSide = 2 // close short
-				posTypeText = "SHORT"
+		if pos.Symbol == symbol && pos.HoldVol > 0 && (positionType == 0 || pos.PositionType == positionType) {
+			if err := c.closePositionVol(ctx, pos, int(pos.HoldVol)); err != nil {
+				return err
 			}
+		}
+	}
 
-			c.logger.Info("Closing position",
-				slog.String("account", c.account.Name),
-				slog.String("symbol", symbol),
-				slog.String("type", posTypeText),
-				slog.Float64("vol", pos.HoldVol))
-
-			// Закрываем позицию с указанием positionId
-			timestamp := time.Now().UnixMilli()
-
-			orderReq := models.ClosePositionRequest{
-				Symbol:       symbol,
-				OpenType:     1, // 1: isolated
-				PositionID:   pos.PositionID,
-				Leverage:     pos.Leverage,
-				Type:         5, // 5: market order (ЧИСЛО!)
-				Vol:          int(pos.HoldVol),
-				Side:         closeSide,
-				PriceProtect: "0",
-			}
+	return nil
+}
+
+// ClosePositionPartial закрывает vol контрактов позиции positionID (vol >= HoldVol - закрытие полностью)
+func (c *Client) ClosePositionPartial(ctx context.Context, symbol string, vol int, positionID int64) error {
+	positions, err := c.GetPositions(ctx, symbol)
+	if err != nil {
+		return err
+	}
 
-			body, _ := json.Marshal(orderReq)
-			signature := c.generateSignature(timestamp, body)
+	for _, pos := range positions {
+		if pos.PositionID == positionID && pos.HoldVol > 0 {
+			return c.closePositionVol(ctx, pos, min(vol, int(pos.HoldVol)))
+		}
+	}
 
-			apiURL := c.baseURL + orderCreateEndpoint
+	return fmt.Errorf("position %d not found", positionID)
+}
 
-			req, _ := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(body))
-			c.setHeaders(req, timestamp, signature)
+// closePositionVol закрывает vol контрактов позиции market ордером.
+// Ордер всегда reduceOnly: если позиция уже закрыта (stop loss, ликвидация) или режим позиций
+// определен неверно, биржа отклонит ордер, а не откроет позицию в обратную сторону
+func (c *Client) closePositionVol(ctx context.Context, pos models.Position, vol int) error {
+	closeSide := SideCloseLong
+	posTypeText := "LONG"
+	if pos.PositionType == 2 {
+		closeSide = SideCloseShort
+		posTypeText = "SHORT"
+	}
+	closeSide, _ = c.orderSide(ctx, closeSide)
 
-			resp, err := c.httpClient.Do(req)
-			if err != nil {
-				c.logger.Error("ClosePosition failed",
-					slog.String("account", c.account.Name),
-					slog.Any("error", err))
+	c.logger.Info("Closing position",
+		slog.String("account", c.account.Name),
+		slog.String("symbol", pos.Symbol),
+		slog.String("type", posTypeText),
+		slog.Int("vol", vol),
+		slog.Float64("holdVol", pos.HoldVol))
+
+	// Закрываем позицию с указанием positionId
+	timestamp := c.timestamp()
+
+	orderReq := models.ClosePositionRequest{
+		Symbol:       pos.Symbol,
+		OpenType:     MarginOpenType(pos.OpenType), // режим маржи закрываемой позиции
+		PositionID:   pos.PositionID,
+		Leverage:     pos.Leverage,
+		Type:         5, // 5: market order (ЧИСЛО!)
+		Vol:          vol,
+		Side:         closeSide,
+		ReduceOnly:   true, // Закрытие только уменьшает позицию: без позиции встречная не откроется
+		PriceProtect: "0",
+	}
 
-				return err
-			}
-			defer resp.Body.Close()
+	body, _ := json.Marshal(orderReq)
+	signature := c.generateSignature(timestamp, body)
 
-			respBody, _ := io.ReadAll(resp.Body)
+	apiURL := c.baseURL + orderCreateEndpoint
 
-			var orderResp models.OrderResponse
-			json.Unmarshal(respBody, &orderResp)
+	req, _ := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(body))
+	c.setHeaders(req, timestamp, signature)
 
-			if !orderResp.Success {
-				c.logger.Error("ClosePosition API error",
-					slog.String("account", c.account.Name),
-					slog.Int("code", orderResp.Code),
-					slog.String("message", orderResp.Message))
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("ClosePosition failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
 
-				return fmt.Errorf("close position failed: %s", orderResp.Message)
-			}
+		return err
+	}
+	defer resp.Body.Close()
 
-			c.logger.Info("✅ ClosePosition success",
-				slog.String("account", c.account.Name),
-				slog.String("orderId", orderResp.Data.OrderID))
-		}
+	respBody, _ := io.ReadAll(resp.Body)
+
+	var orderResp models.OrderResponse
+	json.Unmarshal(respBody, &orderResp)
+
+	if !orderResp.Success {
+		c.logger.Error("ClosePosition API error",
+			slog.String("account", c.account.Name),
+			slog.Int("code", orderResp.Code),
+			slog.String("message", orderResp.Message))
+
+		apiErr := newAPIError("close position", orderResp.Code, orderResp.Message)
+		c.invalidatePositionMode(apiErr)
+
+		return apiErr
 	}
 
+	c.logger.Info("✅ ClosePosition success",
+		slog.String("account", c.account.Name),
+		slog.String("orderId", orderResp.Data.OrderID))
+
 	return nil
 }
 
-// PlacePlanOrder устанавливает Stop Loss и Take Profit для позиции
+// PlacePlanOrder устанавливает Stop Loss и Take Profit для позиции (0 - не устанавливается).
+// Цены отправляются с точностью символа (FormatPrice)
 func (c *Client) PlacePlanOrder(ctx context.Context, symbol string, stopLossPrice, takeProfitPrice float64) error {
-	timestamp := time.Now().UnixMilli()
-
-	stopLossReq := models.StopLossRequest{
-		Symbol:          symbol,
-		StopLossPrice:   stopLossPrice,
-		TakeProfitPrice: takeProfitPrice,
+	stopLossReq := models.StopLossRequest{Symbol: symbol}
+	if stopLossPrice > 0 {
+		stopLossReq.StopLossPrice = json.Number(c.FormatPrice(ctx, symbol, stopLossPrice))
 	}
+	if takeProfitPrice > 0 {
+		stopLossReq.TakeProfitPrice = json.Number(c.FormatPrice(ctx, symbol, takeProfitPrice))
+	}
+
+	timestamp := c.timestamp()
 
 	body, _ := json.Marshal(stopLossReq)
 	signature := c.generateSignature(timestamp, body)
@@ -536,7 +805,7 @@ func (c *Client) PlacePlanOrder(ctx context.Context, symbol string, stopLossPric
 			slog.Int("code", result.Code),
 			slog.String("message", result.Message))
 
-		return fmt.Errorf("set SL/TP failed: %s", result.Message)
+		return newAPIError("set SL/TP", result.Code, result.Message)
 	}
 
 	c.logger.Info("✅ SetStopLoss success",
@@ -550,7 +819,7 @@ func (c *Client) PlacePlanOrder(ctx context.Context, symbol string, stopLossPric
 
 // GetOpenStopOrders получает список открытых стоп-ордеров
 func (c *Client) GetOpenStopOrders(ctx context.Context, symbol string) ([]models.StopOrder, error) {
-	timestamp := time.Now().UnixMilli()
+	timestamp := c.timestamp()
 
 	apiURL := c.baseURL + stopLossOpenOrdersEndpoint
 	if symbol != "" {
@@ -558,7 +827,7 @@ func (c *Client) GetOpenStopOrders(ctx context.Context, symbol string) ([]models
 	}
 
 	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
-	c.setHeaders(req, timestamp, "")
+	c.setGetHeaders(req, timestamp)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -584,7 +853,7 @@ func (c *Client) GetOpenStopOrders(ctx context.Context, symbol string) ([]models
 			slog.String("account", c.account.Name),
 			slog.String("response", string(body)))
 
-		return nil, fmt.Errorf("API error: %s", string(body))
+		return nil, apiErrorFromBody("GetOpenStopOrders", body)
 	}
 
 	return result.Data, nil
@@ -592,7 +861,7 @@ func (c *Client) GetOpenStopOrders(ctx context.Context, symbol string) ([]models
 
 // CancelStopOrder отменяет стоп-ордер по ID
 func (c *Client) CancelStopOrder(ctx context.Context, stopPlanOrderID int64) error {
-	timestamp := time.Now().UnixMilli()
+	timestamp := c.timestamp()
 
 	cancelItems := []models.StopOrderCancelItem{
 		{StopPlanOrderID: stopPlanOrderID},
@@ -603,7 +872,8 @@ func (c *Client) CancelStopOrder(ctx context.Context, stopPlanOrderID int64) err
 
 	apiURL := c.baseURL + stopLossCancelEndpoint
 
-	req, _ := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(body))
+	// Повтор отмены безопасен
+	req, _ := http.NewRequestWithContext(httpmiddleware.Idempotent(ctx), "POST", apiURL, bytes.NewBuffer(body))
 	c.setHeaders(req, timestamp, signature)
 
 	resp, err := c.httpClient.Do(req)
@@ -631,7 +901,7 @@ func (c *Client) CancelStopOrder(ctx context.Context, stopPlanOrderID int64) err
 			slog.Int("code", result.Code),
 			slog.String("message", result.Message))
 
-		return fmt.Errorf("cancel SL/TP failed: %s", result.Message)
+		return newAPIError("cancel SL/TP", result.Code, result.Message)
 	}
 
 	c.logger.Info("✅ CancelStopLoss success",
@@ -681,7 +951,7 @@ func (c *Client) CancelAllStopLossBySymbol(ctx context.Context, symbol string) e
 
 // ChangePlanPrice изменяет цену stop loss для существующего ордера
 func (c *Client) ChangePlanPrice(ctx context.Context, req1 models.ChangePlanPriceRequest) error {
-	timestamp := time.Now().UnixMilli()
+	timestamp := c.timestamp()
 
 	body, _ := json.Marshal(req1)
 	signature := c.generateSignature(timestamp, body)
@@ -716,7 +986,7 @@ func (c *Client) ChangePlanPrice(ctx context.Context, req1 models.ChangePlanPric
 			slog.Int("code", result.Code),
 			slog.String("message", result.Message))
 
-		return fmt.Errorf("change SL/TP failed: %s", result.Message)
+		return newAPIError("change SL/TP", result.Code, result.Message)
 	}
 
 	c.logger.Info("✅ ChangeStopLoss success",
@@ -725,73 +995,73 @@ func (c *Client) ChangePlanPrice(ctx context.Context, req1 models.ChangePlanPric
 	return nil
 }
 
-// GetOpenOrders получает список открытых ордеров
-func (c *Client) GetOpenOrders(ctx context.Context, pageNum, pageSize int) ([]models.OpenOrder, error) {
-	timestamp := time.Now().UnixMilli()
-
-	// Default values
-	if pageNum < 1 {
-		pageNum = 1
+// PlaceTrailingStop создает trailing stop ордер, возвращает его ID
+func (c *Client) PlaceTrailingStop(ctx context.Context, trailingReq models.TrailingStopRequest) (int64, error) {
+	var trackOrderID int64
+	if err := c.postSigned(ctx, "PlaceTrailingStop", trackOrderPlaceEndpoint, trailingReq, &trackOrderID); err != nil {
+		return 0, err
 	}
 
-	if pageSize < 1 {
-		pageSize = 20
-	}
+	c.logger.Info("✅ PlaceTrailingStop success",
+		slog.String("account", c.account.Name),
+		slog.String("symbol", trailingReq.Symbol),
+		slog.Float64("backValue", trailingReq.BackValue),
+		slog.Int64("trackOrderId", trackOrderID))
 
-	if pageSize > 100 {
-		pageSize = 100
-	}
+	return trackOrderID, nil
+}
 
-	apiURL := fmt.Sprintf("%s%s?page_num=%d&page_size=%d", c.baseURL, openOrdersEndpoint, pageNum, pageSize)
+// ChangeTrailingStop изменяет параметры trailing stop ордера (цена активации, callback, объем)
+func (c *Client) ChangeTrailingStop(ctx context.Context, changeReq models.ChangeTrailingStopRequest) error {
+	if err := c.postSigned(ctx, "ChangeTrailingStop", trackOrderChangeEndpoint, changeReq, nil); err != nil {
+		return err
+	}
 
-	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
-	c.setHeaders(req, timestamp, "")
+	c.logger.Info("✅ ChangeTrailingStop success",
+		slog.String("account", c.account.Name),
+		slog.Int64("trackOrderId", changeReq.TrackOrderID),
+		slog.Float64("backValue", changeReq.BackValue))
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		c.logger.Error("GetOpenOrders failed",
-			slog.String("account", c.account.Name),
-			slog.Any("error", err))
+	return nil
+}
 
-		return nil, err
+// CancelTrailingStop отменяет trailing stop ордер по ID
+func (c *Client) CancelTrailingStop(ctx context.Context, symbol string, trackOrderID int64) error {
+	cancelItems := []models.TrailingStopCancelItem{
+		{Symbol: symbol, TrackOrderID: trackOrderID},
 	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
 
-	var result struct {
-		Success bool               `json:"success"`
-		Data    []models.OpenOrder `json:"data"`
+	if err := c.postSigned(httpmiddleware.Idempotent(ctx), "CancelTrailingStop", trackOrderCancelEndpoint, cancelItems, nil); err != nil {
+		return err
 	}
 
-	json.Unmarshal(body, &result)
-
-	if !result.Success {
-		c.logger.Error("GetOpenOrders API error",
-			slog.String("account", c.account.Name),
-			slog.String("response", string(body)))
-
-		return nil, fmt.Errorf("API error: %s", string(body))
-	}
+	c.logger.Info("✅ CancelTrailingStop success",
+		slog.String("account", c.account.Name),
+		slog.Int64("trackOrderId", trackOrderID))
 
-	return result.Data, nil
+	return nil
 }
 
-// GetTieredFeeRate получает информацию о комиссионных ставках
-func (c *Client) GetTieredFeeRate(ctx context.Context, symbol string) (*models.TieredFeeRateResponse, error) {
-	timestamp := time.Now().UnixMilli()
+// GetOpenTrailingStops получает список не сработавших trailing stop ордеров
+func (c *Client) GetOpenTrailingStops(ctx context.Context, symbol string) ([]models.TrailingStop, error) {
+	timestamp := c.timestamp()
 
-	apiURL := c.baseURL + tieredFeeRateEndpoint
+	params := url.Values{}
+	params.Set("states", "0") // 0: не сработавшие
+	params.Set("page_num", "1")
+	params.Set("page_size", "100")
 	if symbol != "" {
-		apiURL += "?symbol=" + symbol
+		params.Set("symbol", symbol)
 	}
 
+	apiURL := c.baseURL + trackOrderListEndpoint + "?" + params.Encode()
+
 	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
-	c.setHeaders(req, timestamp, "")
+	c.setGetHeaders(req, timestamp)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		c.logger.Error("GetTieredFeeRate failed",
+		c.logger.Error("GetOpenTrailingStops failed",
 			slog.String("account", c.account.Name),
 			slog.Any("error", err))
 
@@ -802,109 +1072,740 @@ func (c *Client) GetTieredFeeRate(ctx context.Context, symbol string) (*models.T
 	body, _ := io.ReadAll(resp.Body)
 
 	var result struct {
-		Success bool                         `json:"success"`
-		Data    models.TieredFeeRateResponse `json:"data"`
+		Success bool                  `json:"success"`
+		Data    []models.TrailingStop `json:"data"`
 	}
 
 	json.Unmarshal(body, &result)
 
 	if !result.Success {
-		c.logger.Error("GetTieredFeeRate API error",
+		c.logger.Error("GetOpenTrailingStops API error",
 			slog.String("account", c.account.Name),
 			slog.String("response", string(body)))
 
-		return nil, fmt.Errorf("API error: %s", string(body))
-	}
-
-	return &result.Data, nil
-}
-
-// cleanRawRequest удаляет технические поля подписи из raw запроса
-func cleanRawRequest(reqBody []byte) ([]byte, error) {
-	var rawReq map[string]any
-	if err := json.Unmarshal(reqBody, &rawReq); err != nil {
-		return nil, err
+		return nil, apiErrorFromBody("GetOpenTrailingStops", body)
 	}
 
-	// Удаляем поля с подписью master аккаунта
-	delete(rawReq, "p0")
-	delete(rawReq, "k0")
-	delete(rawReq, "chash")
-	delete(rawReq, "mtoken")
-	delete(rawReq, "ts")
-	delete(rawReq, "mhash")
-
-	return json.Marshal(rawReq)
+	return result.Data, nil
 }
 
-// PlaceOrderRaw выполняет запрос на создание ордера с raw данными из browser mirror
-func (c *Client) PlaceOrderRaw(ctx context.Context, reqBody []byte) (string, error) {
-	timestamp := time.Now().UnixMilli()
-
-	body, err := cleanRawRequest(reqBody)
-	if err != nil {
-		return "", err
-	}
+// postSigned отправляет подписанный POST запрос; data - куда разобрать поле data ответа (nil - не нужно)
+func (c *Client) postSigned(ctx context.Context, name, endpoint string, payload any, data any) error {
+	timestamp := c.timestamp()
 
+	body, _ := json.Marshal(payload)
 	signature := c.generateSignature(timestamp, body)
 
-	apiURL := c.baseURL + orderCreateEndpoint
+	apiURL := c.baseURL + endpoint
 
 	req, _ := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(body))
 	c.setHeaders(req, timestamp, signature)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		c.logger.Error("PlaceOrderRaw failed",
+		c.logger.Error(name+" failed",
 			slog.String("account", c.account.Name),
 			slog.Any("error", err))
 
-		return "", err
+		return err
 	}
 	defer resp.Body.Close()
 
 	respBody, _ := io.ReadAll(resp.Body)
 
-	var orderResp models.OrderResponse
-	json.Unmarshal(respBody, &orderResp)
+	var result struct {
+		Success bool            `json:"success"`
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	json.Unmarshal(respBody, &result)
 
-	if !orderResp.Success {
-		c.logger.Error("PlaceOrderRaw API error",
+	if !result.Success {
+		c.logger.Error(name+" API error",
 			slog.String("account", c.account.Name),
-			slog.Int("code", orderResp.Code),
-			slog.String("message", orderResp.Message))
+			slog.Int("code", result.Code),
+			slog.String("message", result.Message))
 
-		return "", fmt.Errorf("order failed: %s", orderResp.Message)
+		return newAPIError(name, result.Code, result.Message)
 	}
 
-	c.logger.Info("✅ PlaceOrderRaw success",
-		slog.String("account", c.account.Name),
-		slog.String("orderId", orderResp.Data.OrderID))
+	if data != nil && len(result.Data) > 0 {
+		if err := json.Unmarshal(result.Data, data); err != nil {
+			return fmt.Errorf("failed to decode %s response: %w", name, err)
+		}
+	}
 
-	return orderResp.Data.OrderID, nil
+	return nil
 }
 
-// SetStopLossRaw устанавливает SL/TP с raw данными из browser mirror
-func (c *Client) SetStopLossRaw(ctx context.Context, reqBody []byte) error {
-	timestamp := time.Now().UnixMilli()
-
-	body, err := cleanRawRequest(reqBody)
-	if err != nil {
+// CancelOrder отменяет открытый (не stop) ордер по ID
+func (c *Client) CancelOrder(ctx context.Context, orderID string) error {
+	// Ответ - результат по каждому ордеру: errorCode != 0 - ордер не отменен
+	var results []struct {
+		OrderID   string `json:"orderId"`
+		ErrorCode int    `json:"errorCode"`
+		ErrorMsg  string `json:"errorMsg"`
+	}
+	if err := c.postSigned(httpmiddleware.Idempotent(ctx), "CancelOrder", orderCancelEndpoint, []string{orderID}, &results); err != nil {
 		return err
 	}
 
-	signature := c.generateSignature(timestamp, body)
+	for _, r := range results {
+		if r.ErrorCode != 0 {
+			c.logger.Error("CancelOrder API error",
+				slog.String("account", c.account.Name),
+				slog.String("orderId", r.OrderID),
+				slog.Int("code", r.ErrorCode),
+				slog.String("message", r.ErrorMsg))
 
-	apiURL := c.baseURL + stopLossEndpoint
+			return newAPIError("cancel order", r.ErrorCode, r.ErrorMsg)
+		}
+	}
 
-	req, _ := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(body))
-	c.setHeaders(req, timestamp, signature)
+	c.logger.Info("✅ CancelOrder success",
+		slog.String("account", c.account.Name),
+		slog.String("orderId", orderID))
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		c.logger.Error("SetStopLossRaw failed",
-			slog.String("account", c.account.Name),
-			slog.Any("error", err))
+	return nil
+}
+
+// CancelAllOrders отменяет все открытые (не stop) ордера символа (symbol пустой - всех символов)
+func (c *Client) CancelAllOrders(ctx context.Context, symbol string) error {
+	payload := map[string]string{}
+	if symbol != "" {
+		payload["symbol"] = symbol
+	}
+
+	if err := c.postSigned(httpmiddleware.Idempotent(ctx), "CancelAllOrders", orderCancelAllEndpoint, payload, nil); err != nil {
+		return err
+	}
+
+	c.logger.Info("✅ CancelAllOrders success",
+		slog.String("account", c.account.Name),
+		slog.String("symbol", symbol))
+
+	return nil
+}
+
+// GetOpenOrders получает список открытых ордеров
+func (c *Client) GetOpenOrders(ctx context.Context, pageNum, pageSize int) ([]models.OpenOrder, error) {
+	timestamp := c.timestamp()
+
+	// Default values
+	if pageNum < 1 {
+		pageNum = 1
+	}
+
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	apiURL := fmt.Sprintf("%s%s?page_num=%d&page_size=%d", c.baseURL, openOrdersEndpoint, pageNum, pageSize)
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
+	c.setGetHeaders(req, timestamp)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("GetOpenOrders failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
+
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	var result struct {
+		Success bool               `json:"success"`
+		Data    []models.OpenOrder `json:"data"`
+	}
+
+	json.Unmarshal(body, &result)
+
+	if !result.Success {
+		c.logger.Error("GetOpenOrders API error",
+			slog.String("account", c.account.Name),
+			slog.String("response", string(body)))
+
+		return nil, apiErrorFromBody("GetOpenOrders", body)
+	}
+
+	return result.Data, nil
+}
+
+// PositionHistoryPageSize - размер страницы GetPositionHistory (максимум API)
+const PositionHistoryPageSize = 100
+
+// GetPositionHistory возвращает страницу page (с 1) закрытых позиций с реализованным PnL,
+// от новых к старым. Страница короче PositionHistoryPageSize - последняя
+func (c *Client) GetPositionHistory(ctx context.Context, symbol string, page int) ([]models.HistoryPosition, error) {
+	return c.GetHistoryPositions(ctx, symbol, page, PositionHistoryPageSize)
+}
+
+// GetHistoryPositions получает историю закрытых позиций (symbol может быть пустым)
+func (c *Client) GetHistoryPositions(ctx context.Context, symbol string, pageNum, pageSize int) ([]models.HistoryPosition, error) {
+	timestamp := c.timestamp()
+
+	// Default values
+	if pageNum < 1 {
+		pageNum = 1
+	}
+
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	apiURL := fmt.Sprintf("%s%s?page_num=%d&page_size=%d", c.baseURL, historyPositionsEndpoint, pageNum, pageSize)
+	if symbol != "" {
+		apiURL += "&symbol=" + symbol
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
+	c.setGetHeaders(req, timestamp)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("GetHistoryPositions failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
+
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	var result struct {
+		Success bool                     `json:"success"`
+		Data    []models.HistoryPosition `json:"data"`
+	}
+
+	json.Unmarshal(body, &result)
+
+	if !result.Success {
+		c.logger.Error("GetHistoryPositions API error",
+			slog.String("account", c.account.Name),
+			slog.String("response", string(body)))
+
+		return nil, apiErrorFromBody("GetHistoryPositions", body)
+	}
+
+	return result.Data, nil
+}
+
+// GetFundingRecords получает историю funding платежей (от новых к старым)
+func (c *Client) GetFundingRecords(ctx context.Context, symbol string, pageNum, pageSize int) ([]models.FundingRecord, error) {
+	timestamp := c.timestamp()
+
+	// Default values
+	if pageNum < 1 {
+		pageNum = 1
+	}
+
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	apiURL := fmt.Sprintf("%s%s?page_num=%d&page_size=%d", c.baseURL, fundingRecordsEndpoint, pageNum, pageSize)
+	if symbol != "" {
+		apiURL += "&symbol=" + symbol
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
+	c.setGetHeaders(req, timestamp)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("GetFundingRecords failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
+
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	// Ответ постраничный: записи лежат в data.resultList
+	var result struct {
+		Success bool `json:"success"`
+		Data    struct {
+			ResultList []models.FundingRecord `json:"resultList"`
+		} `json:"data"`
+	}
+
+	json.Unmarshal(body, &result)
+
+	if !result.Success {
+		c.logger.Error("GetFundingRecords API error",
+			slog.String("account", c.account.Name),
+			slog.String("response", string(body)))
+
+		return nil, apiErrorFromBody("GetFundingRecords", body)
+	}
+
+	return result.Data.ResultList, nil
+}
+
+// GetOrder получает ордер по ID (включая среднюю цену исполнения)
+func (c *Client) GetOrder(ctx context.Context, orderID string) (*models.OpenOrder, error) {
+	timestamp := c.timestamp()
+
+	apiURL := c.baseURL + orderGetEndpoint + url.PathEscape(orderID)
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
+	c.setGetHeaders(req, timestamp)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("GetOrder failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
+
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	var result struct {
+		Success bool             `json:"success"`
+		Data    models.OpenOrder `json:"data"`
+	}
+
+	json.Unmarshal(body, &result)
+
+	if !result.Success {
+		c.logger.Error("GetOrder API error",
+			slog.String("account", c.account.Name),
+			slog.String("response", string(body)))
+
+		return nil, apiErrorFromBody("GetOrder", body)
+	}
+
+	return &result.Data, nil
+}
+
+// GetHistoryOrders получает историю ордеров (symbol может быть пустым).
+// Формат ответа совпадает с открытыми ордерами, включая takerFee/makerFee
+func (c *Client) GetHistoryOrders(ctx context.Context, symbol string, pageNum, pageSize int) ([]models.OpenOrder, error) {
+	timestamp := c.timestamp()
+
+	// Default values
+	if pageNum < 1 {
+		pageNum = 1
+	}
+
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	apiURL := fmt.Sprintf("%s%s?page_num=%d&page_size=%d", c.baseURL, historyOrdersEndpoint, pageNum, pageSize)
+	if symbol != "" {
+		apiURL += "&symbol=" + symbol
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
+	c.setGetHeaders(req, timestamp)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("GetHistoryOrders failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
+
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	var result struct {
+		Success bool               `json:"success"`
+		Data    []models.OpenOrder `json:"data"`
+	}
+
+	json.Unmarshal(body, &result)
+
+	if !result.Success {
+		c.logger.Error("GetHistoryOrders API error",
+			slog.String("account", c.account.Name),
+			slog.String("response", string(body)))
+
+		return nil, apiErrorFromBody("GetHistoryOrders", body)
+	}
+
+	return result.Data, nil
+}
+
+// GetOrderHistory получает исполненные ордера из истории (symbol может быть пустым), новые первыми
+func (c *Client) GetOrderHistory(ctx context.Context, symbol string, pageNum, pageSize int) ([]models.FilledOrder, error) {
+	timestamp := c.timestamp()
+
+	// Default values
+	if pageNum < 1 {
+		pageNum = 1
+	}
+
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	// states=3 - только исполненные ордера
+	apiURL := fmt.Sprintf("%s%s?states=3&page_num=%d&page_size=%d", c.baseURL, historyOrdersEndpoint, pageNum, pageSize)
+	if symbol != "" {
+		apiURL += "&symbol=" + symbol
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
+	c.setGetHeaders(req, timestamp)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("GetOrderHistory failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
+
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	var result struct {
+		Success bool                 `json:"success"`
+		Data    []models.FilledOrder `json:"data"`
+	}
+
+	json.Unmarshal(body, &result)
+
+	if !result.Success {
+		c.logger.Error("GetOrderHistory API error",
+			slog.String("account", c.account.Name),
+			slog.String("response", string(body)))
+
+		return nil, apiErrorFromBody("GetOrderHistory", body)
+	}
+
+	return result.Data, nil
+}
+
+// GetTieredFeeRate получает информацию о комиссионных ставках
+func (c *Client) GetTieredFeeRate(ctx context.Context, symbol string) (*models.TieredFeeRateResponse, error) {
+	timestamp := c.timestamp()
+
+	apiURL := c.baseURL + tieredFeeRateEndpoint
+	if symbol != "" {
+		apiURL += "?symbol=" + symbol
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
+	c.setGetHeaders(req, timestamp)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("GetTieredFeeRate failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
+
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	var result struct {
+		Success bool                         `json:"success"`
+		Data    models.TieredFeeRateResponse `json:"data"`
+	}
+
+	json.Unmarshal(body, &result)
+
+	if !result.Success {
+		c.logger.Error("GetTieredFeeRate API error",
+			slog.String("account", c.account.Name),
+			slog.String("response", string(body)))
+
+		return nil, apiErrorFromBody("GetTieredFeeRate", body)
+	}
+
+	return &result.Data, nil
+}
+
+// GetContractDetail получает параметры контракта (размер контракта для расчета notional)
+func (c *Client) GetContractDetail(ctx context.Context, symbol string) (*models.ContractDetail, error) {
+	timestamp := c.timestamp()
+
+	apiURL := c.baseURL + contractDetailEndpoint + "?symbol=" + symbol
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
+	c.setGetHeaders(req, timestamp)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("GetContractDetail failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
+
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	var result struct {
+		Success bool                  `json:"success"`
+		Data    models.ContractDetail `json:"data"`
+	}
+
+	json.Unmarshal(body, &result)
+
+	if !result.Success {
+		c.logger.Error("GetContractDetail API error",
+			slog.String("account", c.account.Name),
+			slog.String("response", string(body)))
+
+		return nil, apiErrorFromBody("GetContractDetail", body)
+	}
+
+	return &result.Data, nil
+}
+
+// GetFundingRate получает прогнозную ставку финансирования символа и ставку последнего расчета
+func (c *Client) GetFundingRate(ctx context.Context, symbol string) (*models.FundingRate, error) {
+	var rate models.FundingRate
+	if err := c.getPublic(ctx, "GetFundingRate", c.baseURL+fundingRateEndpoint+url.PathEscape(symbol), &rate); err != nil {
+		return nil, err
+	}
+
+	var history struct {
+		ResultList []struct {
+			FundingRate float64 `json:"fundingRate"`
+			SettleTime  int64   `json:"settleTime"`
+		} `json:"resultList"`
+	}
+
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("page_num", "1")
+	params.Set("page_size", "1")
+
+	if err := c.getPublic(ctx, "GetFundingRateHistory", c.baseURL+fundingRateHistoryEndpoint+"?"+params.Encode(), &history); err != nil {
+		return nil, err
+	}
+
+	if len(history.ResultList) > 0 {
+		rate.LastFundingRate = history.ResultList[0].FundingRate
+		rate.LastSettleTime = history.ResultList[0].SettleTime
+	}
+
+	return &rate, nil
+}
+
+// GetTicker получает последнюю цену, mark price и объёмы символа
+func (c *Client) GetTicker(ctx context.Context, symbol string) (*models.Ticker, error) {
+	var ticker models.Ticker
+	if err := c.getPublic(ctx, "GetTicker", c.baseURL+tickerEndpoint+"?symbol="+url.QueryEscape(symbol), &ticker); err != nil {
+		return nil, err
+	}
+
+	return &ticker, nil
+}
+
+// GetKlines получает свечи символа за [start, end] (interval: Min1, Min5, Min15, Min30, Min60, Hour4, Hour8, Day1, Week1, Month1).
+// Нулевые start/end - последние свечи
+func (c *Client) GetKlines(ctx context.Context, symbol, interval string, start, end time.Time) ([]models.Kline, error) {
+	params := url.Values{}
+	params.Set("interval", interval)
+	if !start.IsZero() {
+		params.Set("start", strconv.FormatInt(start.Unix(), 10))
+	}
+	if !end.IsZero() {
+		params.Set("end", strconv.FormatInt(end.Unix(), 10))
+	}
+
+	// Свечи приходят колонками
+	var data struct {
+		Time  []int64   `json:"time"`
+		Open  []float64 `json:"open"`
+		Close []float64 `json:"close"`
+		High  []float64 `json:"high"`
+		Low   []float64 `json:"low"`
+		Vol   []float64 `json:"vol"`
+	}
+
+	apiURL := c.baseURL + klineEndpoint + url.PathEscape(symbol) + "?" + params.Encode()
+	if err := c.getPublic(ctx, "GetKlines", apiURL, &data); err != nil {
+		return nil, err
+	}
+
+	n := min(len(data.Time), len(data.Open), len(data.Close), len(data.High), len(data.Low), len(data.Vol))
+
+	klines := make([]models.Kline, 0, n)
+	for i := range n {
+		klines = append(klines, models.Kline{
+			Time:   data.Time[i],
+			Open:   data.Open[i],
+			Close:  data.Close[i],
+			High:   data.High[i],
+			Low:    data.Low[i],
+			Volume: data.Vol[i],
+		})
+	}
+
+	return klines, nil
+}
+
+// getPublic выполняет GET запрос и разбирает поле data ответа в data
+func (c *Client) getPublic(ctx context.Context, name, apiURL string, data any) error {
+	timestamp := c.timestamp()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", apiURL, http.NoBody)
+	c.setGetHeaders(req, timestamp)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error(name+" failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
+
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	var result struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+	}
+
+	json.Unmarshal(body, &result)
+
+	if !result.Success {
+		c.logger.Error(name+" API error",
+			slog.String("account", c.account.Name),
+			slog.String("response", string(body)))
+
+		return apiErrorFromBody(name, body)
+	}
+
+	if err := json.Unmarshal(result.Data, data); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", name, err)
+	}
+
+	return nil
+}
+
+// cleanRawRequest удаляет технические поля подписи из raw запроса
+func cleanRawRequest(reqBody []byte) ([]byte, error) {
+	var rawReq map[string]any
+	if err := json.Unmarshal(reqBody, &rawReq); err != nil {
+		return nil, err
+	}
+
+	// Удаляем поля с подписью master аккаунта
+	delete(rawReq, "p0")
+	delete(rawReq, "k0")
+	delete(rawReq, "chash")
+	delete(rawReq, "mtoken")
+	delete(rawReq, "ts")
+	delete(rawReq, "mhash")
+
+	return json.Marshal(rawReq)
+}
+
+// PlaceOrderRaw выполняет запрос на создание ордера с raw данными из browser mirror
+func (c *Client) PlaceOrderRaw(ctx context.Context, reqBody []byte) (string, error) {
+	timestamp := c.timestamp()
+
+	body, err := cleanRawRequest(reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	signature := c.generateSignature(timestamp, body)
+
+	apiURL := c.baseURL + orderCreateEndpoint
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(body))
+	c.setHeaders(req, timestamp, signature)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("PlaceOrderRaw failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
+
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+
+	var orderResp models.OrderResponse
+	json.Unmarshal(respBody, &orderResp)
+
+	if !orderResp.Success {
+		c.logger.Error("PlaceOrderRaw API error",
+			slog.String("account", c.account.Name),
+			slog.Int("code", orderResp.Code),
+			slog.String("message", orderResp.Message))
+
+		return "", newAPIError("order", orderResp.Code, orderResp.Message)
+	}
+
+	c.logger.Info("✅ PlaceOrderRaw success",
+		slog.String("account", c.account.Name),
+		slog.String("orderId", orderResp.Data.OrderID))
+
+	return orderResp.Data.OrderID, nil
+}
+
+// SetStopLossRaw устанавливает SL/TP с raw данными из browser mirror
+func (c *Client) SetStopLossRaw(ctx context.Context, reqBody []byte) error {
+	timestamp := c.timestamp()
+
+	body, err := cleanRawRequest(reqBody)
+	if err != nil {
+		return err
+	}
+
+	signature := c.generateSignature(timestamp, body)
+
+	apiURL := c.baseURL + stopLossEndpoint
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(body))
+	c.setHeaders(req, timestamp, signature)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("SetStopLossRaw failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
 
 		return err
 	}
@@ -925,7 +1826,7 @@ func (c *Client) SetStopLossRaw(ctx context.Context, reqBody []byte) error {
 			slog.Int("code", result.Code),
 			slog.String("message", result.Message))
 
-		return fmt.Errorf("set SL/TP failed: %s", result.Message)
+		return newAPIError("set SL/TP", result.Code, result.Message)
 	}
 
 	c.logger.Info("✅ SetStopLossRaw success",
@@ -936,7 +1837,7 @@ func (c *Client) SetStopLossRaw(ctx context.Context, reqBody []byte) error {
 
 // ChangeStopLossRaw изменяет цену stop loss с raw данными из browser mirror
 func (c *Client) ChangeStopLossRaw(ctx context.Context, reqBody []byte) error {
-	timestamp := time.Now().UnixMilli()
+	timestamp := c.timestamp()
 
 	body, err := cleanRawRequest(reqBody)
 	if err != nil {
@@ -975,7 +1876,7 @@ func (c *Client) ChangeStopLossRaw(ctx context.Context, reqBody []byte) error {
 			slog.Int("code", result.Code),
 			slog.String("message", result.Message))
 
-		return fmt.Errorf("change SL/TP failed: %s", result.Message)
+		return newAPIError("change SL/TP", result.Code, result.Message)
 	}
 
 	c.logger.Info("✅ ChangeStopLossRaw success",
@@ -986,7 +1887,7 @@ func (c *Client) ChangeStopLossRaw(ctx context.Context, reqBody []byte) error {
 
 // CancelStopLossRaw отменяет stop order с raw данными из browser mirror
 func (c *Client) CancelStopLossRaw(ctx context.Context, reqBody []byte) error {
-	timestamp := time.Now().UnixMilli()
+	timestamp := c.timestamp()
 
 	body, err := cleanRawRequest(reqBody)
 	if err != nil {
@@ -1025,7 +1926,7 @@ func (c *Client) CancelStopLossRaw(ctx context.Context, reqBody []byte) error {
 			slog.Int("code", result.Code),
 			slog.String("message", result.Message))
 
-		return fmt.Errorf("cancel stop order failed: %s", result.Message)
+		return newAPIError("cancel stop order", result.Code, result.Message)
 	}
 
 	c.logger.Info("✅ CancelStopLossRaw success",
@@ -1036,7 +1937,7 @@ func (c *Client) CancelStopLossRaw(ctx context.Context, reqBody []byte) error {
 
 // ChangeLeverageRaw изменяет leverage с raw данными из browser mirror
 func (c *Client) ChangeLeverageRaw(ctx context.Context, reqBody []byte) error {
-	timestamp := time.Now().UnixMilli()
+	timestamp := c.timestamp()
 
 	body, err := cleanRawRequest(reqBody)
 	if err != nil {
@@ -1075,9 +1976,12 @@ func (c *Client) ChangeLeverageRaw(ctx context.Context, reqBody []byte) error {
 			slog.Int("code", result.Code),
 			slog.String("message", result.Message))
 
-		return fmt.Errorf("change leverage failed: %s", result.Message)
+		return newAPIError("change leverage", result.Code, result.Message)
 	}
 
+	// Символ в raw запросе не разбирается - сбрасываем leverage всех символов
+	c.InvalidateLeverage("")
+
 	c.logger.Info("✅ ChangeLeverageRaw success",
 		slog.String("account", c.account.Name))
 
@@ -1094,7 +1998,7 @@ type ChangeLeverageRequest struct {
 
 // ChangeLeverage изменяет leverage для символа
 func (c *Client) ChangeLeverage(ctx context.Context, req ChangeLeverageRequest) error {
-	timestamp := time.Now().UnixMilli()
+	timestamp := c.timestamp()
 
 	body, _ := json.Marshal(req)
 	signature := c.generateSignature(timestamp, body)
@@ -1129,9 +2033,11 @@ func (c *Client) ChangeLeverage(ctx context.Context, req ChangeLeverageRequest)
 			slog.Int("code", result.Code),
 			slog.String("message", result.Message))
 
-		return fmt.Errorf("change leverage failed: %s", result.Message)
+		return newAPIError("change leverage", result.Code, result.Message)
 	}
 
+	c.InvalidateLeverage(req.Symbol)
+
 	c.logger.Info("✅ ChangeLeverage success",
 		slog.String("account", c.account.Name),
 		slog.String("symbol", req.Symbol),
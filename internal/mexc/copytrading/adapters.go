@@ -5,6 +5,18 @@ func IsOpenOrder(side int) bool {
 	return side == 1 || side == 3
 }
 
+// ClosePositionType возвращает тип позиции, которую закрывает side (1 = long, 2 = short, 0 = неизвестно)
+func ClosePositionType(side int) int {
+	switch side {
+	case 4:
+		return 1
+	case 2:
+		return 2
+	default:
+		return 0
+	}
+}
+
 // GetSideText возвращает текстовое описание side
 func GetSideText(side int) string {
 	switch side {
@@ -0,0 +1,116 @@
+package copytrading
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// MarginUsage - состояние фьючерсного счета master аккаунта по последнему событию счета (WebSocket)
+type MarginUsage struct {
+	AccountID        int       `json:"account_id"`
+	AccountName      string    `json:"account_name"`
+	Currency         string    `json:"currency"`
+	Equity           float64   `json:"equity"`
+	AvailableBalance float64   `json:"available_balance"`
+	PositionMargin   float64   `json:"position_margin"`
+	FrozenBalance    float64   `json:"frozen_balance"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// Ratio возвращает долю equity, занятую маржой позиций и ордеров (0..1, 0 - equity неизвестен)
+func (u MarginUsage) Ratio() float64 {
+	if u.Equity <= 0 {
+		return 0
+	}
+	return (u.PositionMargin + u.FrozenBalance) / u.Equity
+}
+
+// masterAsset - счет master аккаунта и опорное значение equity для алерта о падении баланса
+type masterAsset struct {
+	usage  MarginUsage
+	peak   float64   // Максимум equity в окне balanceDropWindow
+	peakAt time.Time // Когда достигнут peak
+}
+
+// SetBalanceDropAlert включает алерт о падении equity master аккаунта на pct% от максимума
+// за window (pct <= 0 отключает, window <= 0 - максимум с начала событий). Максимум после алерта
+// сбрасывается: следующий алерт - после нового падения на pct% от текущего значения
+func (e *Engine) SetBalanceDropAlert(pct float64, window time.Duration) {
+	e.balanceDropPct = pct
+	e.balanceDropWindow = window
+}
+
+// MasterMargin возвращает последнее известное состояние счета master аккаунта (false - событий не было)
+func (e *Engine) MasterMargin(accountID int) (MarginUsage, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	asset, ok := e.assets[accountID]
+	if !ok {
+		return MarginUsage{}, false
+	}
+
+	return asset.usage, true
+}
+
+// trackMasterAsset сохраняет состояние счета master аккаунта и уведомляет пользователя,
+// если equity упал на balanceDropPct% от максимума за balanceDropWindow
+func (e *Engine) trackMasterAsset(userID int, usage MarginUsage) {
+	now := usage.UpdatedAt
+
+	e.mu.Lock()
+	asset, ok := e.assets[usage.AccountID]
+	if !ok {
+		asset = &masterAsset{}
+		e.assets[usage.AccountID] = asset
+	}
+	asset.usage = usage
+
+	if usage.Equity >= asset.peak || (e.balanceDropWindow > 0 && now.Sub(asset.peakAt) > e.balanceDropWindow) {
+		asset.peak = usage.Equity
+		asset.peakAt = now
+	}
+
+	peak := asset.peak
+	dropped := e.balanceDropPct > 0 && peak > 0 && usage.Equity <= peak*(1-e.balanceDropPct/100)
+	if dropped {
+		asset.peak = usage.Equity
+		asset.peakAt = now
+	}
+	e.mu.Unlock()
+
+	if !dropped {
+		return
+	}
+
+	e.logger.Warn("Master balance dropped",
+		slog.Int("user_id", userID),
+		slog.String("master", usage.AccountName),
+		slog.Float64("from", peak),
+		slog.Float64("to", usage.Equity))
+
+	if e.alerter != nil {
+		go e.alerter.MasterBalanceDrop(context.Background(), userID, usage.AccountName, peak, usage.Equity)
+	}
+}
+
+// UpdateMasterAsset передает engine состояние счета master аккаунта сессии (событие счета WebSocket)
+func (s *Session) UpdateMasterAsset(usage MarginUsage) {
+	s.engine.trackMasterAsset(s.userID, usage)
+}
+
+// MasterMargin возвращает последнее состояние счета master аккаунта сессии
+func (s *Session) MasterMargin() (MarginUsage, bool) {
+	master, err := s.GetMasterAccount()
+	if err != nil {
+		return MarginUsage{}, false
+	}
+
+	return s.engine.MasterMargin(master.ID)
+}
+
+// MasterMargin возвращает последнее состояние счета master аккаунта accountID
+func (m *Manager) MasterMargin(accountID int) (MarginUsage, bool) {
+	return m.engine.MasterMargin(accountID)
+}
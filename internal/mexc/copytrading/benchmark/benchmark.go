@@ -0,0 +1,217 @@
+package benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tg_mexc/internal/analytics"
+	copytrading "tg_mexc/internal/mexc/copytrading"
+	wscopytrading "tg_mexc/internal/mexc/copytrading/websocket"
+	"tg_mexc/internal/mexc/websocket"
+	"tg_mexc/internal/models"
+	"tg_mexc/internal/storage"
+)
+
+// Параметры прогона по умолчанию и лимиты
+const (
+	DefaultEvents = 100
+	DefaultSlaves = 5
+	MaxEvents     = 5000
+	MaxSlaves     = 100
+)
+
+const (
+	// sessionName - имя сессии benchmark в Manager
+	sessionName = "benchmark"
+	userID      = 1
+	symbol      = "BTC_USDT"
+	price       = 60000.0
+)
+
+// Options - параметры прогона
+type Options struct {
+	Events int // Число синтетических ордеров мастера (открытие и закрытие по очереди)
+	Slaves int // Число синтетических slave аккаунтов
+}
+
+// Stages - распределение задержек по этапам конвейера копирования
+type Stages struct {
+	EventToDispatch analytics.Percentiles `json:"event_to_dispatch"` // Разбор WS события → engine начал обработку slave
+	DispatchToSend  analytics.Percentiles `json:"dispatch_to_send"`  // Клиент, leverage, подпись → ордер готов к отправке в сеть
+	EndToEnd        analytics.Percentiles `json:"end_to_end"`        // Событие у мастера → ордер готов к отправке в сеть
+}
+
+// Report - результат прогона
+type Report struct {
+	Events     int      `json:"events"`
+	Slaves     int      `json:"slaves"`
+	Executions int      `json:"executions"`
+	Failed     int      `json:"failed"`
+	DurationMs float64  `json:"duration_ms"`
+	EventsPerS float64  `json:"events_per_second"`
+	Overall    Stages   `json:"overall"`
+	Open       Stages   `json:"open"`
+	Close      Stages   `json:"close"`
+	Errors     []string `json:"errors,omitempty"`
+}
+
+// samples накапливает задержки этапов в миллисекундах
+type samples struct {
+	eventToDispatch []float64
+	dispatchToSend  []float64
+	endToEnd        []float64
+}
+
+func (s *samples) add(eventAt, dispatchedAt, sentAt time.Time) {
+	s.eventToDispatch = append(s.eventToDispatch, ms(dispatchedAt.Sub(eventAt)))
+	s.dispatchToSend = append(s.dispatchToSend, ms(sentAt.Sub(dispatchedAt)))
+	s.endToEnd = append(s.endToEnd, ms(sentAt.Sub(eventAt)))
+}
+
+func (s *samples) stages() Stages {
+	return Stages{
+		EventToDispatch: analytics.NewPercentiles(s.eventToDispatch),
+		DispatchToSend:  analytics.NewPercentiles(s.dispatchToSend),
+		EndToEnd:        analytics.NewPercentiles(s.endToEnd),
+	}
+}
+
+// Run прогоняет синтетические события master аккаунта через полный конвейер копирования:
+// разбор WS события, Engine, создание клиентов, запрос leverage/позиций, подпись ордера.
+// Сеть заменена stub transport'ом, который фиксирует момент готовности ордера к отправке
+// и сразу отвечает успехом; сделки пишутся в хранилище в памяти.
+func Run(ctx context.Context, opts Options, logger *slog.Logger) (Report, error) {
+	accounts := syntheticAccounts(opts.Slaves)
+	mem := storage.NewMemory(accounts)
+
+	transport := newStubTransport()
+	engine := copytrading.NewEngine(mem, mem, mem, mem, logger, false)
+	engine.SetTransport(transport)
+	manager := copytrading.NewManager(engine, false, logger)
+
+	session, err := manager.CreateOrGetActiveSession(userID, sessionName)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to create benchmark session: %w", err)
+	}
+	defer manager.StopSession(userID, sessionName)
+
+	svc := wscopytrading.NewService(session, logger)
+
+	uids := make(map[int]string, len(accounts))
+	for _, acc := range accounts {
+		uids[acc.ID] = acc.UserID
+	}
+
+	report := Report{Events: opts.Events, Slaves: opts.Slaves}
+	var overall, open, closing samples
+
+	started := time.Now()
+	for i := range opts.Events {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		isOpen := i%2 == 0
+		event, err := syntheticEvent(i, isOpen)
+		if err != nil {
+			return report, err
+		}
+
+		probe := newProbe()
+		tradesBefore := len(mem.Trades())
+
+		event.ReceivedAt = time.Now()
+		if err := svc.Replay(withProbe(ctx, probe), event); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("event %d: %v", i, err))
+			continue
+		}
+
+		for _, trade := range mem.Trades()[tradesBefore:] {
+			for _, detail := range trade.Details {
+				report.Executions++
+
+				sentAt, sent := probe.sentAt(uids[detail.AccountID])
+				if detail.Status != "success" || !sent || detail.DispatchedAt == nil {
+					report.Failed++
+					if detail.Error != "" {
+						report.Errors = append(report.Errors, fmt.Sprintf("event %d, %s: %s", i, detail.AccountName, detail.Error))
+					}
+					continue
+				}
+
+				overall.add(event.ReceivedAt, *detail.DispatchedAt, sentAt)
+				if isOpen {
+					open.add(event.ReceivedAt, *detail.DispatchedAt, sentAt)
+				} else {
+					closing.add(event.ReceivedAt, *detail.DispatchedAt, sentAt)
+				}
+			}
+		}
+	}
+
+	elapsed := time.Since(started)
+	report.DurationMs = ms(elapsed)
+	if elapsed > 0 {
+		report.EventsPerS = float64(opts.Events) / elapsed.Seconds()
+	}
+	report.Overall = overall.stages()
+	report.Open = open.stages()
+	report.Close = closing.stages()
+
+	return report, nil
+}
+
+// syntheticAccounts создает master и slaves аккаунты с фиктивной авторизацией
+func syntheticAccounts(slaves int) []models.Account {
+	accounts := make([]models.Account, 0, slaves+1)
+	for i := range slaves + 1 {
+		accounts = append(accounts, models.Account{
+			ID:       i + 1,
+			Name:     fmt.Sprintf("bench-%d", i),
+			Token:    fmt.Sprintf("WEB%064d", i),
+			UserID:   fmt.Sprintf("bench-uid-%d", i),
+			DeviceID: fmt.Sprintf("bench-device-%d", i),
+			Cookies:  map[string]string{"u_id": fmt.Sprintf("bench-uid-%d", i)},
+			IsMaster: i == 0,
+		})
+	}
+
+	return accounts
+}
+
+// syntheticEvent создает WS событие ордера мастера: открытие long или его закрытие
+func syntheticEvent(i int, isOpen bool) (models.MasterEvent, error) {
+	side := 4 // close long
+	if isOpen {
+		side = 1 // open long
+	}
+
+	payload, err := json.Marshal(websocket.OrderEvent{
+		OrderID:      fmt.Sprintf("bench-%d", i),
+		Symbol:       symbol,
+		Price:        price,
+		Vol:          1,
+		Leverage:     20,
+		Side:         side,
+		State:        3,
+		DealVol:      1,
+		DealAvgPrice: price,
+	})
+	if err != nil {
+		return models.MasterEvent{}, fmt.Errorf("failed to encode synthetic event: %w", err)
+	}
+
+	return models.MasterEvent{
+		ID:        i + 1,
+		AccountID: 1,
+		Kind:      wscopytrading.EventOrder,
+		Payload:   string(payload),
+	}, nil
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,88 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type probeKey struct{}
+
+// probe фиксирует момент, когда подписанный ордер slave аккаунта дошел до отправки в сеть
+type probe struct {
+	mu   sync.Mutex
+	sent map[string]time.Time // trochilus-uid -> время первого запроса order/create
+}
+
+func newProbe() *probe {
+	return &probe{sent: make(map[string]time.Time)}
+}
+
+func withProbe(ctx context.Context, p *probe) context.Context {
+	return context.WithValue(ctx, probeKey{}, p)
+}
+
+func (p *probe) markSent(uid string, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.sent[uid]; !ok {
+		p.sent[uid] = at
+	}
+}
+
+func (p *probe) sentAt(uid string) (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	at, ok := p.sent[uid]
+	return at, ok
+}
+
+// stubTransport отвечает на запросы MEXC клиента без сети: leverage, позиции и ордера всегда успешны
+type stubTransport struct {
+	orders atomic.Int64
+}
+
+func newStubTransport() *stubTransport {
+	return &stubTransport{}
+}
+
+func (t *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	now := time.Now()
+	path := req.URL.Path
+
+	var body string
+	switch {
+	case strings.HasSuffix(path, "/private/order/create"):
+		if p, ok := req.Context().Value(probeKey{}).(*probe); ok {
+			p.markSent(req.Header.Get("trochilus-uid"), now)
+		}
+		body = fmt.Sprintf(`{"success":true,"code":0,"data":{"orderId":"%d","ts":%d}}`, t.orders.Add(1), now.UnixMilli())
+	case strings.HasSuffix(path, "/private/position/leverage"):
+		body = `{"success":true,"data":[{"positionType":1,"openType":1,"leverage":20},{"positionType":2,"openType":1,"leverage":20}]}`
+	case strings.HasSuffix(path, "/private/position/open_positions"):
+		body = fmt.Sprintf(`{"success":true,"data":[{"positionId":1,"symbol":%q,"positionType":1,"holdVol":1,"holdAvgPrice":%v,"leverage":20}]}`,
+			req.URL.Query().Get("symbol"), price)
+	case strings.Contains(path, "/private/order/get/"):
+		body = fmt.Sprintf(`{"success":true,"data":{"dealAvgPrice":%v,"state":3}}`, price)
+	default:
+		body = `{"success":true,"data":{}}`
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
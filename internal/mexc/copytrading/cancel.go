@@ -0,0 +1,145 @@
+package copytrading
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+)
+
+// CancelOrders отменяет открытые ордера на всех slave аккаунтах
+func (e *Engine) CancelOrders(ctx context.Context, userID int, req CancelOrderRequest) (ExecutionResult, error) {
+	// Ордер мастера еще не отменен (mirror): символ, сторона и цена - из него
+	if req.Symbol == "" && req.MasterOrderID != "" {
+		masterAccount, err := e.masterAccount(ctx, userID)
+		if err != nil {
+			return ExecutionResult{}, fmt.Errorf("failed to get master account: %w", err)
+		}
+
+		masterClient, err := e.newClient(masterAccount)
+		if err != nil {
+			return ExecutionResult{}, fmt.Errorf("failed to create master client: %w", err)
+		}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, e.timeouts.Open)
+		masterOrder, err := masterClient.GetOrder(lookupCtx, req.MasterOrderID)
+		cancel()
+		if err != nil {
+			return ExecutionResult{}, fmt.Errorf("failed to get master order: %w", err)
+		}
+
+		req.Symbol, req.Side, req.Price = masterOrder.Symbol, masterOrder.Side, masterOrder.Price
+	}
+
+	result, err := e.execute(ctx, OpOpen, userID, func(ctx context.Context, acc models.Account) AccountResult {
+		return e.processCancelOrders(ctx, acc, req)
+	})
+	if err != nil && !IsPartial(err) {
+		return ExecutionResult{}, err
+	}
+
+	record := models.Trade{
+		UserID: userID,
+		Symbol: req.Symbol,
+		Side:   req.Side,
+		Action: "cancel_order",
+	}
+	if err := e.saveTrade(ctx, record, result); err != nil {
+		return ExecutionResult{}, fmt.Errorf("failed to save trade: %w", err)
+	}
+
+	return result, err
+}
+
+// processCancelOrders обрабатывает отмену ордеров для одного аккаунта
+func (e *Engine) processCancelOrders(ctx context.Context, acc models.Account, req CancelOrderRequest) AccountResult {
+	result := AccountResult{
+		AccountID:   acc.ID,
+		AccountName: acc.Name,
+		Success:     false,
+	}
+
+	client, err := e.newClient(acc)
+	if err != nil {
+		e.logger.Error("Failed to create client",
+			slog.String("slave", acc.Name),
+			slog.Any("error", err))
+		result.setError(err)
+		return result
+	}
+
+	// Без стороны - все ордера символа одним запросом
+	if req.Side == 0 {
+		if e.dryRun {
+			e.logger.Info("DRY_RUN - Would cancel all orders",
+				slog.String("slave", acc.Name),
+				slog.String("symbol", req.Symbol))
+			result.Success = true
+			return result
+		}
+
+		if err := client.CancelAllOrders(ctx, req.Symbol); err != nil {
+			e.logger.Error("Failed to cancel orders",
+				slog.String("slave", acc.Name),
+				slog.String("symbol", req.Symbol),
+				slog.Any("error", err))
+			result.setError(err)
+			return result
+		}
+
+		result.Success = true
+		return result
+	}
+
+	slaveOrders, err := slaveOpenOrders(ctx, client, req)
+	if err != nil {
+		e.logger.Error("Failed to get slave open orders",
+			slog.String("slave", acc.Name),
+			slog.Any("error", err))
+		result.setError(err)
+		return result
+	}
+
+	if e.dryRun {
+		e.logger.Info("DRY_RUN - Would cancel orders",
+			slog.String("slave", acc.Name),
+			slog.String("symbol", req.Symbol),
+			slog.Int("count", len(slaveOrders)))
+		result.Success = true
+		return result
+	}
+
+	for _, order := range slaveOrders {
+		if err := client.CancelOrder(ctx, order.OrderID); err != nil {
+			e.logger.Error("Failed to cancel order",
+				slog.String("slave", acc.Name),
+				slog.String("symbol", req.Symbol),
+				slog.Any("error", err))
+			result.setError(err)
+			return result
+		}
+	}
+
+	result.Success = true
+
+	return result
+}
+
+// slaveOpenOrders возвращает открытые ордера slave, совпадающие с ордером мастера по символу, стороне и цене
+func slaveOpenOrders(ctx context.Context, client *mexc.Client, req CancelOrderRequest) ([]models.OpenOrder, error) {
+	orders, err := client.GetAllOpenOrders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := orders[:0]
+	for _, order := range orders {
+		if order.Symbol == req.Symbol && order.Side == req.Side && (req.Price == 0 || order.Price == req.Price) {
+			matched = append(matched, order)
+		}
+	}
+
+	return matched, nil
+}
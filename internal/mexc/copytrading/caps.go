@@ -0,0 +1,166 @@
+package copytrading
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+)
+
+// ErrCapReached - ограничения slave аккаунта (models.CopyCaps) не оставляют места для входа
+var ErrCapReached = errors.New("slave cap reached, entry not copied")
+
+// ErrPositionLimit - у slave аккаунта открыто models.CopyCaps.MaxPositions символов, вход по новому
+// символу не копируется (вход в уже открытый символ копируется)
+var ErrPositionLimit = errors.New("slave max open positions reached, entry not copied")
+
+// applyCaps уменьшает объём входа до ограничений slave аккаунта (models.CopyCaps). Для ограничений
+// в USDT запрашиваются позиции аккаунта: notional - объём × размер контракта × цена (входа позиции,
+// для нового входа - цена мастера или рыночная). Возвращает объём и признак уменьшения
+func (e *Engine) applyCaps(ctx context.Context, client *mexc.Client, acc models.Account, req OpenPositionRequest, detail models.ContractDetail) (float64, bool, error) {
+	caps := acc.Caps
+	volume := req.Volume
+	capped := false
+
+	if caps.MaxOrderVol > 0 && volume > caps.MaxOrderVol {
+		volume = caps.MaxOrderVol
+		capped = true
+	}
+
+	if !caps.Notional() {
+		return volume, capped, nil
+	}
+
+	if detail.ContractSize <= 0 {
+		return 0, false, fmt.Errorf("notional cap: unknown contract size of %s", req.Symbol)
+	}
+
+	price, err := e.entryPrice(ctx, client, req)
+	if err != nil {
+		return 0, false, fmt.Errorf("notional cap: %w", err)
+	}
+
+	symbolNotional, exposure, err := e.accountNotional(ctx, client, req.Symbol)
+	if err != nil {
+		return 0, false, fmt.Errorf("notional cap: %w", err)
+	}
+
+	contractNotional := detail.ContractSize * price
+	limit := func(maxNotional, current float64) {
+		if maxNotional <= 0 {
+			return
+		}
+		room := max((maxNotional-current)/contractNotional, 0)
+		if volume > room {
+			volume = room
+			capped = true
+		}
+	}
+	limit(caps.MaxSymbolNotional, symbolNotional)
+	limit(caps.MaxExposure, exposure)
+
+	return volume, capped, nil
+}
+
+// entryPrice - цена входа для оценки notional: limit цена, исполнение мастера, рыночная цена события
+// или последняя цена тикера
+func (e *Engine) entryPrice(ctx context.Context, client *mexc.Client, req OpenPositionRequest) (float64, error) {
+	for _, price := range []float64{req.LimitPrice, req.MasterPrice, req.MarketPrice} {
+		if price > 0 {
+			return price, nil
+		}
+	}
+
+	ticker, err := client.GetTicker(ctx, req.Symbol)
+	if err != nil {
+		return 0, err
+	}
+	if ticker.LastPrice <= 0 {
+		return 0, fmt.Errorf("no price of %s", req.Symbol)
+	}
+
+	return ticker.LastPrice, nil
+}
+
+// accountNotional возвращает notional открытых позиций аккаунта по символу и по всем символам (USDT)
+func (e *Engine) accountNotional(ctx context.Context, client *mexc.Client, symbol string) (symbolNotional, exposure float64, err error) {
+	positions, err := client.GetPositions(ctx, "")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for _, pos := range positions {
+		if pos.HoldVol <= 0 {
+			continue
+		}
+
+		detail, err := e.contractDetail(ctx, client, pos.Symbol)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		notional := pos.HoldVol * detail.ContractSize * pos.HoldAvgPrice
+		exposure += notional
+		if pos.Symbol == symbol {
+			symbolNotional += notional
+		}
+	}
+
+	return symbolNotional, exposure, nil
+}
+
+// logCapped записывает уменьшение входа ограничениями slave аккаунта
+func (e *Engine) logCapped(acc models.Account, req OpenPositionRequest, volume float64) {
+	e.logger.Info("Entry volume capped by slave caps",
+		slog.String("slave", acc.Name),
+		slog.String("symbol", req.Symbol),
+		slog.Float64("volume", req.Volume),
+		slog.Float64("capped_volume", volume),
+		slog.Float64("max_order_vol", acc.Caps.MaxOrderVol),
+		slog.Float64("max_symbol_notional", acc.Caps.MaxSymbolNotional),
+		slog.Float64("max_exposure", acc.Caps.MaxExposure))
+}
+
+// openSymbols возвращает символы открытых позиций аккаунта для models.CopyCaps.MaxPositions
+// (nil - предел не задан)
+func (e *Engine) openSymbols(ctx context.Context, client *mexc.Client, acc models.Account) (map[string]struct{}, error) {
+	if acc.Caps.MaxPositions <= 0 {
+		return nil, nil
+	}
+
+	positions, err := client.GetPositions(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("max positions cap: %w", err)
+	}
+
+	symbols := make(map[string]struct{}, len(positions))
+	for _, pos := range positions {
+		if pos.HoldVol > 0 {
+			symbols[pos.Symbol] = struct{}{}
+		}
+	}
+
+	return symbols, nil
+}
+
+// checkPositionLimit проверяет вход по пределу символов с открытой позицией. symbols - результат
+// openSymbols, дополняется символами входов пачки, отправленных до этого
+func (e *Engine) checkPositionLimit(acc models.Account, req OpenPositionRequest, symbols map[string]struct{}) error {
+	if symbols == nil {
+		return nil
+	}
+	if _, ok := symbols[req.Symbol]; ok || len(symbols) < acc.Caps.MaxPositions {
+		return nil
+	}
+
+	e.logger.Info("Entry skipped, slave max open positions reached",
+		slog.String("slave", acc.Name),
+		slog.String("symbol", req.Symbol),
+		slog.Int("positions", len(symbols)),
+		slog.Int("max_positions", acc.Caps.MaxPositions))
+
+	return ErrPositionLimit
+}
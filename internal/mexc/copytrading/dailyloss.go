@@ -0,0 +1,235 @@
+package copytrading
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/mexc/websocket"
+	"tg_mexc/internal/models"
+)
+
+// DailyLossAction - что делает engine, когда дневной убыток slave аккаунта превысил предел
+type DailyLossAction string
+
+const (
+	// DailyLossBlock - входы мастера на slave аккаунт не копируются до конца дня (UTC), закрытия копируются
+	DailyLossBlock DailyLossAction = "block"
+	// DailyLossHalt - сессия copy trading пользователя останавливается (SetHaltHandler)
+	DailyLossHalt DailyLossAction = "halt"
+)
+
+// DailyLossRefresh - как часто перечитывается история закрытых позиций slave для дневного PnL
+const DailyLossRefresh = time.Minute
+
+// dailyLossMaxPages - сколько страниц истории позиций читается за день
+const dailyLossMaxPages = 5
+
+// ErrDailyLossLimit - дневной реализованный убыток slave аккаунта превысил предел, вход не копируется
+var ErrDailyLossLimit = errors.New("daily loss limit reached, entry not copied")
+
+// ParseDailyLossAction разбирает действие при превышении дневного убытка (пусто - DailyLossBlock)
+func ParseDailyLossAction(raw string) (DailyLossAction, error) {
+	switch action := DailyLossAction(raw); action {
+	case "":
+		return DailyLossBlock, nil
+	case DailyLossBlock, DailyLossHalt:
+		return action, nil
+	}
+
+	return "", fmt.Errorf("unknown daily loss action %q", raw)
+}
+
+// dayLoss - реализованный PnL slave аккаунта за день (UTC)
+type dayLoss struct {
+	day       string    // YYYY-MM-DD
+	history   float64   // Закрытые за день позиции из истории биржи (с комиссиями и funding)
+	historyAt time.Time // Последнее чтение истории, zero - еще не читалась
+	deals     float64   // Прибыль fill'ов за день по WebSocket за вычетом комиссий
+	breached  bool      // Предел превышен: входы заблокированы до конца дня
+}
+
+// pnl возвращает реализованный PnL дня. Источники пересекаются (fill'ы закрытых позиций есть в обоих,
+// частичные закрытия - только в fill'ах, fill'ы до подключения WebSocket - только в истории),
+// поэтому берется худшая оценка
+func (l *dayLoss) pnl() float64 {
+	return min(l.history, l.deals)
+}
+
+// SetDailyLossLimit включает ограничение дневного реализованного убытка slave аккаунтов: limit - USDT
+// (0 отключает), action - что делать при превышении. Пользователь получает уведомление
+func (e *Engine) SetDailyLossLimit(limit float64, action DailyLossAction) {
+	e.dailyLossLimit = limit
+	e.dailyLossAction = action
+}
+
+// SetHaltHandler задает остановку сессии copy trading пользователя для DailyLossHalt (останавливает
+// WebSocket режим или mirror). Без обработчика DailyLossHalt действует как DailyLossBlock
+func (e *Engine) SetHaltHandler(handler func(userID int)) {
+	e.onHalt = handler
+}
+
+// dayStart возвращает начало дня (UTC) момента t и его ключ
+func dayStart(t time.Time) (time.Time, string) {
+	start := t.UTC().Truncate(24 * time.Hour)
+	return start, start.Format(time.DateOnly)
+}
+
+// dayLossLocked возвращает PnL дня аккаунта, сбрасывая его в начале нового дня (e.mu захвачен)
+func (e *Engine) dayLossLocked(accountID int, day string) *dayLoss {
+	loss, ok := e.dailyLoss[accountID]
+	if !ok || loss.day != day {
+		loss = &dayLoss{day: day}
+		e.dailyLoss[accountID] = loss
+	}
+
+	return loss
+}
+
+// checkDailyLoss проверяет дневной убыток slave аккаунта перед входом: история закрытых позиций
+// перечитывается не чаще DailyLossRefresh. Ошибка истории не блокирует вход (оценка - по fill'ам)
+func (e *Engine) checkDailyLoss(ctx context.Context, client *mexc.Client, userID int, acc models.Account) error {
+	if e.dailyLossLimit <= 0 {
+		return nil
+	}
+
+	now := e.clock.Now()
+	start, day := dayStart(now)
+
+	e.mu.Lock()
+	loss := e.dayLossLocked(acc.ID, day)
+	breached := loss.breached
+	stale := now.Sub(loss.historyAt) >= DailyLossRefresh
+	e.mu.Unlock()
+
+	if breached {
+		return ErrDailyLossLimit
+	}
+
+	if stale {
+		history, err := e.realizedSince(ctx, client, start)
+		if err != nil {
+			e.logger.Warn("Failed to get slave position history for daily loss",
+				slog.String("slave", acc.Name),
+				slog.Any("error", err))
+		} else {
+			e.mu.Lock()
+			loss = e.dayLossLocked(acc.ID, day)
+			loss.history = history
+			loss.historyAt = now
+			e.mu.Unlock()
+		}
+	}
+
+	if e.updateDailyLoss(userID, acc, day, nil) {
+		return ErrDailyLossLimit
+	}
+
+	return nil
+}
+
+// realizedSince суммирует реализованный PnL позиций аккаунта, закрытых начиная с since
+func (e *Engine) realizedSince(ctx context.Context, client *mexc.Client, since time.Time) (float64, error) {
+	realized := 0.0
+	fetch := func(ctx context.Context, pageNum, pageSize int) ([]models.HistoryPosition, error) {
+		return client.GetHistoryPositions(ctx, "", pageNum, pageSize)
+	}
+
+	// История отдается от новых к старым: позиция, закрытая до since, означает конец дня
+	err := mexc.Paginate(ctx, fetch, mexc.PositionHistoryPageSize, dailyLossMaxPages, func(positions []models.HistoryPosition) error {
+		for _, pos := range positions {
+			if time.UnixMilli(pos.UpdateTime).Before(since) {
+				return mexc.ErrStopPaging
+			}
+			realized += pos.Realised
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return realized, nil
+}
+
+// recordSlaveDeal учитывает fill slave аккаунта из его WebSocket соединения (FillWatcher): превышение
+// предела срабатывает сразу, не дожидаясь следующего входа
+func (e *Engine) recordSlaveDeal(userID int, acc models.Account, deal websocket.DealEvent) {
+	if e.dailyLossLimit <= 0 {
+		return
+	}
+
+	at := e.clock.Now()
+	if deal.Timestamp > 0 {
+		at = time.UnixMilli(deal.Timestamp)
+	}
+	_, day := dayStart(at)
+
+	e.updateDailyLoss(userID, acc, day, func(loss *dayLoss) {
+		loss.deals += deal.Profit - deal.Fee
+	})
+}
+
+// updateDailyLoss применяет update к PnL дня аккаунта и сравнивает его с пределом. Первое превышение
+// за день блокирует входы, записывается в журнал и уведомляет пользователя. true - предел превышен
+func (e *Engine) updateDailyLoss(userID int, acc models.Account, day string, update func(loss *dayLoss)) bool {
+	_, today := dayStart(e.clock.Now())
+
+	e.mu.Lock()
+	if day != today {
+		e.mu.Unlock()
+		return false
+	}
+
+	loss := e.dayLossLocked(acc.ID, day)
+	if update != nil {
+		update(loss)
+	}
+	pnl := loss.pnl()
+	if loss.breached || pnl > -e.dailyLossLimit {
+		breached := loss.breached
+		e.mu.Unlock()
+		return breached
+	}
+	loss.breached = true
+	e.mu.Unlock()
+
+	halt := e.dailyLossAction == DailyLossHalt && e.onHalt != nil
+
+	e.logger.Warn("Slave daily loss limit reached",
+		slog.Int("user_id", userID),
+		slog.String("slave", acc.Name),
+		slog.Float64("pnl", pnl),
+		slog.Float64("limit", e.dailyLossLimit),
+		slog.Bool("halt", halt))
+
+	message := fmt.Sprintf("Daily loss of slave %s reached %.2f USDT (limit %.2f USDT): entries are not copied until the end of the day (UTC)",
+		acc.Name, pnl, e.dailyLossLimit)
+	if halt {
+		message = fmt.Sprintf("Daily loss of slave %s reached %.2f USDT (limit %.2f USDT): copy trading session stopped",
+			acc.Name, pnl, e.dailyLossLimit)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	e.logStorage.AddLog(ctx, models.ActivityLog{
+		UserID:  &userID,
+		Level:   "warn",
+		Action:  "daily_loss_limit",
+		Message: message,
+	})
+
+	if e.alerter != nil {
+		go e.alerter.DailyLossLimit(context.Background(), userID, acc.Name, pnl, e.dailyLossLimit, halt)
+	}
+
+	// Остановка закрывает и WebSocket соединения slave (в том числе то, из которого пришел fill)
+	if halt {
+		go e.onHalt(userID)
+	}
+
+	return true
+}
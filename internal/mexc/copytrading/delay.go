@@ -0,0 +1,89 @@
+package copytrading
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// MaxCopyDelay - наибольшая задержка копирования сессии вместе с разбросом
+const MaxCopyDelay = time.Minute
+
+// CopyDelay - задержка копирования сессии: перед fan-out на slave аккаунты engine ждет
+// Delay + случайную добавку из [0, Jitter), чтобы сделки slave не совпадали с мастером по времени
+type CopyDelay struct {
+	Delay  time.Duration `json:"delay"`
+	Jitter time.Duration `json:"jitter"`
+}
+
+// Validate проверяет задержку: неотрицательные значения, в сумме не больше MaxCopyDelay
+func (d CopyDelay) Validate() error {
+	if d.Delay < 0 || d.Jitter < 0 {
+		return fmt.Errorf("copy delay and jitter must be non-negative")
+	}
+	if d.Delay+d.Jitter > MaxCopyDelay {
+		return fmt.Errorf("copy delay with jitter must not exceed %s", MaxCopyDelay)
+	}
+	return nil
+}
+
+// IsZero сообщает, что задержки нет
+func (d CopyDelay) IsZero() bool {
+	return d.Delay <= 0 && d.Jitter <= 0
+}
+
+// next возвращает задержку очередной операции
+func (d CopyDelay) next() time.Duration {
+	delay := max(d.Delay, 0)
+	if d.Jitter > 0 {
+		delay += rand.N(d.Jitter)
+	}
+	return delay
+}
+
+// SetCopyDelay задает задержку копирования сессии (zero - без задержки)
+func (s *Session) SetCopyDelay(delay CopyDelay) {
+	s.engine.setCopyDelay(s.userID, delay)
+}
+
+// setCopyDelay задает задержку копирования сделок пользователя
+func (e *Engine) setCopyDelay(userID int, delay CopyDelay) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if delay.IsZero() {
+		delete(e.delays, userID)
+		return
+	}
+	e.delays[userID] = delay
+}
+
+// waitCopyDelay выдерживает задержку копирования пользователя перед fan-out. События мастера
+// обрабатываются по порядку, поэтому следующие операции ждут своей очереди и порядок сохраняется
+func (e *Engine) waitCopyDelay(ctx context.Context, op Operation, userID int) error {
+	e.mu.RLock()
+	delay, ok := e.delays[userID]
+	e.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	wait := delay.next()
+	if wait <= 0 {
+		return nil
+	}
+
+	e.logger.Debug("Copy delayed",
+		slog.Int("user_id", userID),
+		slog.String("op", string(op)),
+		slog.Duration("delay", wait))
+
+	select {
+	case <-e.clock.After(wait):
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("copy delay: %w", ctx.Err())
+	}
+}
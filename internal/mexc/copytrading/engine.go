@@ -5,30 +5,33 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"net/http"
 	"slices"
 	"strconv"
 	"sync"
 	"time"
 
+	"tg_mexc/internal/clock"
 	"tg_mexc/internal/mexc"
-	models2 "tg_mexc/internal/models"
+	"tg_mexc/internal/models"
 
 	"golang.org/x/sync/errgroup"
 )
 
 type TradeStorage interface {
-	CreateTrade(ctx context.Context, trade models2.Trade) (int, error)
-	AddTradeDetail(ctx context.Context, detail models2.TradeDetail) error
+	CreateTrade(ctx context.Context, trade models.Trade) (int, error)
+	AddTradeDetail(ctx context.Context, detail models.TradeDetail) error
 	UpdateTradeStatus(ctx context.Context, tradeID int, status string, errorMsg string) error
 }
 
 type LogStorage interface {
-	AddLog(ctx context.Context, log models2.ActivityLog) error
+	AddLog(ctx context.Context, log models.ActivityLog) error
 }
 
 type UserStorage interface {
-	GetMasterAccount(userID int) (models2.Account, error)
-	GetSlaveAccounts(userID int, includeInactive bool) ([]models2.Account, error)
+	GetMasterAccount(userID int) (models.Account, error)
+	GetSlaveAccounts(userID int, includeInactive bool) ([]models.Account, error)
 }
 
 type StopOrderCache interface {
@@ -37,14 +40,79 @@ type StopOrderCache interface {
 	SaveStopOrders(userID int, orders map[string]string) error
 }
 
+// DealRecorder - получатель fill'ов master аккаунта (учет PnL, комиссий)
+type DealRecorder interface {
+	RecordDeal(userID int, deal models.Deal) error
+}
+
+// TradeNotifier - получатель результатов копирования (уведомления пользователю).
+// Вызывается синхронно после сохранения сделки: реализация не должна блокировать fan-out
+type TradeNotifier interface {
+	NotifyTrade(trade models.Trade, result ExecutionResult)
+}
+
+// EventStorage - журнал событий master аккаунта (для offline replay)
+type EventStorage interface {
+	AddMasterEvent(userID int, event models.MasterEvent) error
+}
+
+// AccountAlerter - критические уведомления пользователю о проблемах аккаунтов
+type AccountAlerter interface {
+	AccountBotChallenge(ctx context.Context, userID int, accountName string, until time.Time)
+	MasterBalanceDrop(ctx context.Context, userID int, accountName string, from, to float64)
+	LiquidationRisk(ctx context.Context, userID int, accountName string, master bool, symbol string, liquidatePrice float64)
+	DailyLossLimit(ctx context.Context, userID int, accountName string, pnl, limit float64, halted bool)
+}
+
+// fillWaitTimeout - сколько engine ждет подтверждения исполнения market ордера slave
+const fillWaitTimeout = 3 * time.Second
+
+// depthLevels - сколько уровней стакана запрашивается для оценки проскальзывания
+const depthLevels = 100
+
+// BotChallengePause - на сколько slave аккаунт исключается из копирования после anti-bot challenge
+const BotChallengePause = 15 * time.Minute
+
 // Engine - core механизм копирования
 type Engine struct {
 	logStorage     LogStorage
 	tradeStorage   TradeStorage
 	userStorage    UserStorage
 	stopOrderCache StopOrderCache
+	dealRecorders  []DealRecorder
+	tradeNotifiers []TradeNotifier
+	eventStorage   EventStorage
+	alerter        AccountAlerter
 	logger         *slog.Logger
 	dryRun         bool
+	clock          clock.Clock
+	transport      http.RoundTripper // nil - сетевой transport клиента по умолчанию
+	clients        *mexc.ClientPool
+	timeouts       Timeouts
+	maxSlippagePct float64 // 0 - стакан перед копированием не проверяется
+	sizing         SizingMode
+	features       FeatureFlags        // nil - per-user feature flags не учитываются
+	symbolFilters  SymbolFilterStorage // nil - входы копируются по всем символам
+	protection     mexc.OrderProtection
+	fills          *FillWatcher // nil - исполнение slave не отслеживается по WebSocket
+
+	balanceDropPct    float64       // Падение equity мастера для алерта, % (0 - без алерта)
+	balanceDropWindow time.Duration // Окно, в котором считается максимум equity
+
+	dailyLossLimit  float64 // Дневной реализованный убыток slave, USDT (0 - без ограничения)
+	dailyLossAction DailyLossAction
+	onHalt          func(userID int) // Остановка сессии пользователя (DailyLossHalt)
+
+	retries  RetryStorage // nil - копии с временной ошибкой не повторяются (retry.go)
+	retryCfg RetryConfig
+
+	mu              sync.RWMutex
+	includeDisabled map[int]bool          // userID -> копировать и на отключенные (из-за комиссии) slave аккаунты
+	paused          map[int]time.Time     // accountID -> до какого момента аккаунт пропускается (anti-bot challenge)
+	assets          map[int]*masterAsset  // accountID мастера -> счет по событиям WebSocket (assets.go)
+	riskAlerts      map[riskKey]time.Time // Позиция -> последнее предупреждение о риске ликвидации
+	dailyLoss       map[int]*dayLoss      // accountID slave -> реализованный PnL дня (dailyloss.go)
+	delays          map[int]CopyDelay     // userID -> задержка копирования сессии (delay.go)
 }
 
 func NewEngine(
@@ -56,40 +124,258 @@ func NewEngine(
 	dryRun bool,
 ) *Engine {
 	return &Engine{
-		logStorage:     logStorage,
-		tradeStorage:   tradeStorage,
-		userStorage:    userStorage,
-		stopOrderCache: stopOrderCache,
-		logger:         logger,
-		dryRun:         dryRun,
+		logStorage:      logStorage,
+		tradeStorage:    tradeStorage,
+		userStorage:     userStorage,
+		stopOrderCache:  stopOrderCache,
+		logger:          logger,
+		dryRun:          dryRun,
+		clock:           clock.Real,
+		timeouts:        DefaultTimeouts(),
+		sizing:          SizingFixed,
+		dailyLossAction: DailyLossBlock,
+		clients:         mexc.NewClientPool(logger),
+		includeDisabled: make(map[int]bool),
+		paused:          make(map[int]time.Time),
+		assets:          make(map[int]*masterAsset),
+		riskAlerts:      make(map[riskKey]time.Time),
+		dailyLoss:       make(map[int]*dayLoss),
+		delays:          make(map[int]CopyDelay),
+	}
+}
+
+// SetClock подменяет источник времени (для измерения latency и таймингов)
+func (e *Engine) SetClock(clk clock.Clock) {
+	e.clock = clk
+	e.clients.SetClock(clk)
+}
+
+// SetTransport подменяет сетевой transport MEXC клиентов slave аккаунтов (benchmark без отправки в сеть)
+func (e *Engine) SetTransport(transport http.RoundTripper) {
+	e.transport = transport
+}
+
+// SetClientPool подключает общий пул MEXC клиентов (handlers и engine используют одни соединения)
+func (e *Engine) SetClientPool(pool *mexc.ClientPool) {
+	e.clients = pool
+}
+
+// SetTimeouts задает таймауты операций и их бюджет на fan-out по slave аккаунтам
+func (e *Engine) SetTimeouts(timeouts Timeouts) {
+	e.timeouts = timeouts
+}
+
+// Timeouts возвращает таймауты операций engine
+func (e *Engine) Timeouts() Timeouts {
+	return e.timeouts
+}
+
+// AddDealRecorder подключает получателя fill'ов master аккаунта
+func (e *Engine) AddDealRecorder(recorder DealRecorder) {
+	e.dealRecorders = append(e.dealRecorders, recorder)
+}
+
+// AddTradeNotifier подключает получателя результатов копирования
+func (e *Engine) AddTradeNotifier(notifier TradeNotifier) {
+	e.tradeNotifiers = append(e.tradeNotifiers, notifier)
+}
+
+// SetEventStorage включает запись событий master аккаунта для последующего replay
+func (e *Engine) SetEventStorage(storage EventStorage) {
+	e.eventStorage = storage
+}
+
+// SetMaxSlippage включает проверку стакана перед копированием открытия: если стакан не покрывает
+// суммарный объём slave аккаунтов или оценка проскальзывания больше maxSlippagePct (%), ордер не отправляется.
+// 0 отключает проверку (лишний запрос на пути копирования)
+func (e *Engine) SetMaxSlippage(maxSlippagePct float64) {
+	e.maxSlippagePct = maxSlippagePct
+}
+
+// SetOrderProtection задает защиту копируемых market ордеров: priceProtect, marketCeiling и граница
+// проскальзывания от цены мастера, за которой биржа отклоняет ордер (FOK limit)
+func (e *Engine) SetOrderProtection(protection mexc.OrderProtection) {
+	e.protection = protection
+}
+
+// SetFillWatcher включает подтверждение исполнения через WebSocket соединения slave аккаунтов:
+// цена и комиссия fill'ов записываются в trade_details, предупреждения о риске ликвидации slave
+// уходят пользователю, прибыль fill'ов учитывается в дневном убытке. Не действует в dry run
+// и с подмененным transport
+func (e *Engine) SetFillWatcher(watcher *FillWatcher) {
+	e.fills = watcher
+	watcher.SetRiskHandler(e.reportLiquidationRisk)
+	watcher.SetDealHandler(e.recordSlaveDeal)
+}
+
+// watchFills открывает WebSocket соединение slave аккаунта для учета fill'ов, если оно включено
+func (e *Engine) watchFills(userID int, acc models.Account) {
+	if e.fills == nil || e.dryRun || e.transport != nil {
+		return
+	}
+
+	e.fills.Watch(userID, acc)
+}
+
+// startFillWatch заранее открывает соединения slave аккаунтов пользователя (старт сессии):
+// fill'ы первой скопированной сделки не ждут подключения
+func (e *Engine) startFillWatch(userID int) {
+	if e.fills == nil {
+		return
+	}
+
+	slaves, err := e.Slaves(userID)
+	if err != nil {
+		e.logger.Warn("Failed to get slave accounts for fill watch", slog.Any("error", err))
+		return
+	}
+
+	for _, acc := range slaves {
+		e.watchFills(userID, acc)
+	}
+}
+
+// stopFillWatch закрывает соединения slave аккаунтов пользователя (остановка сессии)
+func (e *Engine) stopFillWatch(userID int) {
+	if e.fills == nil {
+		return
+	}
+
+	slaves, err := e.userStorage.GetSlaveAccounts(userID, true)
+	if err != nil {
+		e.logger.Warn("Failed to get slave accounts for fill watch", slog.Any("error", err))
+		return
+	}
+
+	for _, acc := range slaves {
+		e.fills.Unwatch(acc.ID)
+	}
+}
+
+// SetAlerter подключает критические уведомления об аккаунтах (приостановка slave из-за anti-bot
+// challenge, падение баланса мастера, риск ликвидации)
+func (e *Engine) SetAlerter(alerter AccountAlerter) {
+	e.alerter = alerter
+}
+
+// pausedUntil возвращает момент, до которого аккаунт исключен из копирования (zero - не приостановлен)
+func (e *Engine) pausedUntil(accountID int) time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	until := e.paused[accountID]
+	if !until.After(e.clock.Now()) {
+		return time.Time{}
+	}
+
+	return until
+}
+
+// pauseAccount исключает аккаунт из копирования на BotChallengePause: пока биржа отдает HTML challenge,
+// запросы аккаунта бесполезны и только продлевают блокировку IP. Пользователь получает уведомление
+func (e *Engine) pauseAccount(userID int, accResult AccountResult) {
+	until := e.clock.Now().Add(BotChallengePause)
+
+	e.mu.Lock()
+	e.paused[accResult.AccountID] = until
+	e.mu.Unlock()
+
+	e.logger.Warn("Slave hit anti-bot challenge, account paused",
+		slog.String("slave", accResult.AccountName),
+		slog.Time("until", until),
+		slog.String("error", accResult.Error))
+
+	if e.alerter != nil {
+		go e.alerter.AccountBotChallenge(context.Background(), userID, accResult.AccountName, until)
+	}
+}
+
+// newClient возвращает MEXC клиент аккаунта из пула engine.
+// С подмененным transport (benchmark) клиент создается заново и в пул не попадает
+func (e *Engine) newClient(acc models.Account) (*mexc.Client, error) {
+	if e.transport == nil {
+		return e.clients.Get(acc)
 	}
+
+	client, err := mexc.NewClient(acc, e.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	client.SetClock(e.clock)
+	client.SetBaseTransport(e.transport)
+
+	return client, nil
+}
+
+// contractDetail возвращает параметры контракта символа (кэш MEXC клиента, общий для всех аккаунтов)
+func (e *Engine) contractDetail(ctx context.Context, client *mexc.Client, symbol string) (models.ContractDetail, error) {
+	return client.ContractDetail(ctx, symbol)
 }
 
 // saveTrade сохраняет результаты сделки в storage (если есть)
-func (e *Engine) saveTrade(ctx context.Context, record models2.Trade, result ExecutionResult) error {
+func (e *Engine) saveTrade(ctx context.Context, record models.Trade, result ExecutionResult) error {
+	_, err := e.storeTrade(ctx, record, result)
+	return err
+}
+
+// storeTrade - saveTrade, возвращающий ID записи сделки (для очереди повторов)
+func (e *Engine) storeTrade(ctx context.Context, record models.Trade, result ExecutionResult) (int, error) {
+	// Результат сохраняется и после отмены ctx вызывающего (частичный fan-out)
+	ctx = context.WithoutCancel(ctx)
+
+	for _, notifier := range e.tradeNotifiers {
+		notifier.NotifyTrade(record, result)
+	}
+
 	tradeID, err := e.tradeStorage.CreateTrade(ctx, record)
 	if err != nil {
-		return fmt.Errorf("failed to create trade record: %w", err)
+		return 0, fmt.Errorf("failed to create trade record: %w", err)
+	}
+
+	var masterEventAt *time.Time
+	if t, ok := EventTime(ctx); ok {
+		masterEventAt = &t
 	}
 
 	for _, r := range result.Results {
 		status := "success"
-		if !r.Success {
+		switch {
+		case r.Skipped:
+			status = "skipped"
+		case !r.Success:
 			status = "failed"
 		}
 
-		err = errors.Join(e.tradeStorage.AddTradeDetail(ctx, models2.TradeDetail{
+		detail := models.TradeDetail{
 			TradeID:   tradeID,
 			AccountID: r.AccountID,
 			Status:    status,
 			Error:     r.Error,
 			OrderID:   r.OrderID,
 			LatencyMs: int(r.LatencyMs),
-		}))
+			FillPrice: r.FillPrice,
+			Capped:    r.Capped,
+
+			MasterEventAt: masterEventAt,
+			DispatchedAt:  timePtr(r.DispatchedAt),
+			AckedAt:       timePtr(r.AckedAt),
+		}
+
+		// Fill'ы, пришедшие по WebSocket slave до сохранения сделки; более поздние FillWatcher допишет сам
+		if e.fills != nil && r.OrderID != "" {
+			if fill, ok := e.fills.Fill(r.AccountID, r.OrderID); ok {
+				detail.FillPrice = fill.AvgPrice
+				detail.FilledVol = fill.Vol
+				detail.Fee = fill.Fee
+			}
+		}
+
+		err = errors.Join(e.tradeStorage.AddTradeDetail(ctx, detail))
 	}
 
 	if err != nil {
-		return fmt.Errorf("failed to save trade details: %w", err)
+		return tradeID, fmt.Errorf("failed to save trade details: %w", err)
 	}
 
 	status := "completed"
@@ -102,12 +388,12 @@ func (e *Engine) saveTrade(ctx context.Context, record models2.Trade, result Exe
 		e.logger.Error("Failed to update trade status", slog.Any("error", err))
 	}
 
-	return e.saveLog(ctx, "info", record, result)
+	return tradeID, e.saveLog(ctx, "info", record, result)
 }
 
 // saveLog сохраняет только лог активности (для операций без trade записи)
-func (e *Engine) saveLog(ctx context.Context, level string, record models2.Trade, result ExecutionResult) error {
-	logRecord := models2.ActivityLog{
+func (e *Engine) saveLog(ctx context.Context, level string, record models.Trade, result ExecutionResult) error {
+	logRecord := models.ActivityLog{
 		UserID:    &record.UserID,
 		Level:     level,
 		Action:    record.Action,
@@ -122,12 +408,30 @@ func (e *Engine) saveLog(ctx context.Context, level string, record models2.Trade
 	return nil
 }
 
-func (e *Engine) getSlaves(userID int) ([]models2.Account, error) {
+// setIncludeDisabled задает, копировать ли сделки пользователя и на отключенные slave аккаунты (ignore fees)
+func (e *Engine) setIncludeDisabled(userID int, include bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if include {
+		e.includeDisabled[userID] = true
+	} else {
+		delete(e.includeDisabled, userID)
+	}
+}
+
+// Slaves возвращает slave аккаунты, на которые engine копирует сделки пользователя.
+// Единственная точка выбора slave аккаунтов: адаптеры (Telegram, web) используют ее для статуса
+func (e *Engine) Slaves(userID int) ([]models.Account, error) {
 	if _, err := e.userStorage.GetMasterAccount(userID); err != nil {
 		return nil, fmt.Errorf("failed to get master account: %w", err)
 	}
 
-	slaveAccounts, err := e.userStorage.GetSlaveAccounts(userID, false)
+	e.mu.RLock()
+	includeDisabled := e.includeDisabled[userID]
+	e.mu.RUnlock()
+
+	slaveAccounts, err := e.userStorage.GetSlaveAccounts(userID, includeDisabled)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get slave accounts: %w", err)
 	}
@@ -135,174 +439,692 @@ func (e *Engine) getSlaves(userID int) ([]models2.Account, error) {
 	return slaveAccounts, nil
 }
 
-func (e *Engine) execute(userID int, fn func(acc models2.Account) AccountResult) (ExecutionResult, error) {
-	slaveAccounts, err := e.getSlaves(userID)
+// execute выполняет fn параллельно на всех slave аккаунтах пользователя: ctx каждого аккаунта
+// ограничен бюджетом операции op на весь fan-out.
+// После отмены ctx новые аккаунты не запускаются (Skipped), а ответы аккаунтов, не успевших ответить,
+// больше не ждутся: их исход неизвестен, расхождение позиций поймает reconcile. В этом случае
+// возвращается частичный результат вместе с *PartialResultError. Задержка копирования сессии
+// (SetCopyDelay) выдерживается до выбора slave аккаунтов и в бюджет не входит
+func (e *Engine) execute(ctx context.Context, op Operation, userID int, fn func(ctx context.Context, acc models.Account) AccountResult) (ExecutionResult, error) {
+	if err := e.waitCopyDelay(ctx, op, userID); err != nil {
+		return ExecutionResult{}, err
+	}
+
+	slaveAccounts, err := e.slaves(ctx, userID)
 	if err != nil {
 		return ExecutionResult{}, fmt.Errorf("failed to get slave accounts: %w", err)
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, e.timeouts.Budget(op, len(slaveAccounts)))
+	defer cancel()
+
 	result := ExecutionResult{
 		TotalCount: len(slaveAccounts),
 		Results:    make([]AccountResult, 0, len(slaveAccounts)),
 	}
 
-	var wg sync.WaitGroup
-	var mu sync.Mutex
+	add := func(accResult AccountResult) {
+		if !accResult.Success && !accResult.Skipped && accResult.ErrorKind == mexc.ErrorKindAuth {
+			e.logger.Warn("Slave authorization expired, account needs a new token",
+				slog.String("slave", accResult.AccountName),
+				slog.String("error", accResult.Error))
+		}
+		if !accResult.Success && !accResult.Skipped && accResult.ErrorKind == mexc.ErrorKindBotChallenge {
+			e.pauseAccount(userID, accResult)
+		}
+		result.add(accResult)
+	}
+
+	// Буфер на все аккаунты: горутины, ответившие после отмены, не блокируются
+	results := make(chan AccountResult, len(slaveAccounts))
+	pending := make(map[int]models.Account, len(slaveAccounts))
 
 	for _, slaveAcc := range slaveAccounts {
-		wg.Add(1)
-		go func(acc models2.Account) {
-			defer wg.Done()
+		if err := ctx.Err(); err != nil {
+			add(AccountResult{
+				AccountID:   slaveAcc.ID,
+				AccountName: slaveAcc.Name,
+				Skipped:     true,
+				Error:       fmt.Sprintf("skipped: %v", err),
+				Retryable:   true, // Ордер не отправлялся
+			})
+			continue
+		}
+		if until := e.pausedUntil(slaveAcc.ID); !until.IsZero() {
+			add(AccountResult{
+				AccountID:   slaveAcc.ID,
+				AccountName: slaveAcc.Name,
+				Skipped:     true,
+				Error:       fmt.Sprintf("paused until %s: anti-bot challenge", until.Format(time.TimeOnly)),
+				ErrorKind:   mexc.ErrorKindBotChallenge,
+			})
+			continue
+		}
 
-			startTime := time.Now()
-			accResult := fn(acc)
-			accResult.LatencyMs = time.Since(startTime).Milliseconds()
+		e.watchFills(userID, slaveAcc)
 
-			mu.Lock()
-			if accResult.Success {
-				result.SuccessCount++
-			} else {
-				result.FailedCount++
+		pending[slaveAcc.ID] = slaveAcc
+		go func(acc models.Account) {
+			startTime := e.clock.Now()
+			accResult := fn(ctx, acc)
+			accResult.AccountID = acc.ID // ключ pending
+			accResult.DispatchedAt = startTime
+			if accResult.AckedAt.IsZero() {
+				accResult.AckedAt = e.clock.Now()
 			}
-			result.Results = append(result.Results, accResult)
-			mu.Unlock()
+			accResult.LatencyMs = accResult.AckedAt.Sub(startTime).Milliseconds()
+
+			results <- accResult
 		}(slaveAcc)
 	}
 
-	wg.Wait()
+collect:
+	for len(pending) > 0 {
+		select {
+		case accResult := <-results:
+			delete(pending, accResult.AccountID)
+			add(accResult)
+		case <-ctx.Done():
+			break collect
+		}
+	}
+
+	for _, acc := range pending {
+		add(AccountResult{
+			AccountID:   acc.ID,
+			AccountName: acc.Name,
+			Error:       fmt.Sprintf("no response before cancel: %v", ctx.Err()),
+		})
+	}
+
+	if len(pending) > 0 || result.SkippedCount > 0 {
+		return result, &PartialResultError{Result: result, Cause: context.Cause(ctx)}
+	}
 
 	return result, nil
 }
 
-// OpenPosition открывает позицию на всех slave аккаунтах
+// OpenPosition открывает позицию на всех slave аккаунтах. Вход по символу, исключенному фильтром
+// символов пользователя, не копируется: пустой результат без записи сделки
 func (e *Engine) OpenPosition(ctx context.Context, userID int, req OpenPositionRequest) (ExecutionResult, error) {
-	result, err := e.execute(userID, func(acc models2.Account) AccountResult {
-		return e.processOpenPosition(ctx, acc, req)
+	if !e.allowedEntries(userID, []OpenPositionRequest{req})[0] {
+		return ExecutionResult{}, nil
+	}
+
+	blocked := e.checkDepth(ctx, userID, []OpenPositionRequest{req})
+	masterEquity := e.masterEquity(ctx, userID)
+
+	result, err := e.execute(ctx, OpOpen, userID, func(ctx context.Context, acc models.Account) AccountResult {
+		return e.processOpenPositions(ctx, userID, acc, []OpenPositionRequest{req}, blocked, masterEquity)[0]
 	})
-	if err != nil {
+	if err != nil && !IsPartial(err) {
 		return ExecutionResult{}, err
 	}
 
-	record := models2.Trade{
-		UserID:   userID,
-		Symbol:   req.Symbol,
-		Side:     req.Side,
-		Volume:   int(req.Volume),
-		Leverage: req.Leverage,
-		Action:   "open_position",
+	tradeID, saveErr := e.storeTrade(ctx, openTradeRecord(userID, req), result)
+	if saveErr != nil {
+		return ExecutionResult{}, fmt.Errorf("failed to save trade: %w", saveErr)
 	}
-	if err := e.saveTrade(ctx, record, result); err != nil {
-		return ExecutionResult{}, fmt.Errorf("failed to save trade: %w", err)
+	e.enqueueRetries(ctx, userID, tradeID, OpOpen, req.Symbol, req, 0, result)
+
+	return result, err
+}
+
+// OpenPositions открывает пачку входов мастера на всех slave аккаунтах: ордера одного slave
+// отправляются одним вызовом Client.PlaceOrders, а не последовательными запросами.
+// Результат и запись сделки - на каждый запрос, в порядке reqs; входы, исключенные фильтром символов,
+// не копируются (пустой результат)
+func (e *Engine) OpenPositions(ctx context.Context, userID int, reqs []OpenPositionRequest) ([]ExecutionResult, error) {
+	if len(reqs) == 0 {
+		return nil, nil
 	}
 
-	return result, nil
+	allowed := e.allowedEntries(userID, reqs)
+	if !slices.Contains(allowed, false) {
+		return e.openPositions(ctx, userID, reqs)
+	}
+
+	copied := make([]OpenPositionRequest, 0, len(reqs))
+	for i, req := range reqs {
+		if allowed[i] {
+			copied = append(copied, req)
+		}
+	}
+
+	results := make([]ExecutionResult, len(reqs))
+	if len(copied) == 0 {
+		return results, nil
+	}
+
+	copiedResults, err := e.openPositions(ctx, userID, copied)
+	if copiedResults == nil {
+		return nil, err
+	}
+
+	j := 0
+	for i := range reqs {
+		if allowed[i] {
+			results[i] = copiedResults[j]
+			j++
+		}
+	}
+
+	return results, err
 }
 
-// processOpenPosition обрабатывает открытие позиции для одного аккаунта
-func (e *Engine) processOpenPosition(ctx context.Context, acc models2.Account, req OpenPositionRequest) AccountResult {
-	result := AccountResult{
+// openPositions - OpenPositions для входов, прошедших фильтр символов
+func (e *Engine) openPositions(ctx context.Context, userID int, reqs []OpenPositionRequest) ([]ExecutionResult, error) {
+	blocked := e.checkDepth(ctx, userID, reqs)
+	masterEquity := e.masterEquity(ctx, userID)
+
+	var mu sync.Mutex
+	byAccount := make(map[int][]AccountResult)
+
+	total, err := e.execute(ctx, OpOpen, userID, func(ctx context.Context, acc models.Account) AccountResult {
+		accResults := e.processOpenPositions(ctx, userID, acc, reqs, blocked, masterEquity)
+
+		mu.Lock()
+		byAccount[acc.ID] = accResults
+		mu.Unlock()
+
+		return summarizeAccount(acc, accResults)
+	})
+	if err != nil && !IsPartial(err) {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	results := make([]ExecutionResult, len(reqs))
+	for i, req := range reqs {
+		results[i] = ExecutionResult{
+			TotalCount: total.TotalCount,
+			Results:    make([]AccountResult, 0, len(total.Results)),
+		}
+		for _, accResult := range total.Results {
+			// Аккаунт не запускался или не успел ответить - его исход одинаков для всей пачки
+			if accResults, ok := byAccount[accResult.AccountID]; ok {
+				dispatchedAt := accResult.DispatchedAt
+				accResult = accResults[i]
+				accResult.DispatchedAt = dispatchedAt
+				if !accResult.AckedAt.IsZero() {
+					accResult.LatencyMs = accResult.AckedAt.Sub(dispatchedAt).Milliseconds()
+				}
+			}
+			results[i].add(accResult)
+		}
+
+		tradeID, saveErr := e.storeTrade(ctx, openTradeRecord(userID, req), results[i])
+		if saveErr != nil {
+			return nil, fmt.Errorf("failed to save trade: %w", saveErr)
+		}
+		e.enqueueRetries(ctx, userID, tradeID, OpOpen, req.Symbol, req, 0, results[i])
+	}
+
+	return results, err
+}
+
+// openTradeRecord - запись сделки открытия позиции
+func openTradeRecord(userID int, req OpenPositionRequest) models.Trade {
+	return models.Trade{
+		UserID:      userID,
+		Symbol:      req.Symbol,
+		Side:        req.Side,
+		Volume:      int(req.Volume),
+		Leverage:    req.Leverage,
+		MasterPrice: req.MasterPrice,
+		Action:      "open_position",
+	}
+}
+
+// summarizeAccount сводит результаты пачки одного аккаунта для execute: успех - если успешен хотя бы один ордер
+func summarizeAccount(acc models.Account, accResults []AccountResult) AccountResult {
+	summary := AccountResult{
 		AccountID:   acc.ID,
 		AccountName: acc.Name,
-		Success:     false,
 	}
 
-	client, err := mexc.NewClient(acc, e.logger)
+	for _, accResult := range accResults {
+		if accResult.Success {
+			summary.Success = true
+			summary.AckedAt = accResult.AckedAt
+			return summary
+		}
+	}
+
+	if len(accResults) > 0 {
+		summary.Error = accResults[0].Error
+		summary.ErrorKind = accResults[0].ErrorKind
+	}
+
+	return summary
+}
+
+// checkDepth оценивает по стакану исполнение market входов мастера суммарным объёмом всех slave аккаунтов.
+// Результат - в порядке reqs: ErrThinOrderBook - вход не копируется, nil - копируется.
+// Ошибка получения стакана не блокирует копирование
+func (e *Engine) checkDepth(ctx context.Context, userID int, reqs []OpenPositionRequest) []error {
+	blocked := make([]error, len(reqs))
+	if e.maxSlippagePct <= 0 {
+		return blocked
+	}
+
+	master, err := e.masterAccount(ctx, userID)
+	if err != nil {
+		return blocked
+	}
+	slaves, err := e.slaves(ctx, userID)
+	if err != nil || len(slaves) == 0 {
+		return blocked
+	}
+	client, err := e.newClient(master)
+	if err != nil {
+		return blocked
+	}
+
+	// Суммарный объём slave аккаунтов - объём мастера с множителями аккаунтов
+	var multiplier float64
+	for _, acc := range slaves {
+		multiplier += acc.VolumeMultiplier()
+	}
+
+	depths := make(map[string]*models.Depth)
+	for i, req := range reqs {
+		// Limit ордер исполняется по своей цене, проскальзывания нет
+		if req.LimitPrice > 0 {
+			continue
+		}
+
+		depth, ok := depths[req.Symbol]
+		if !ok {
+			depth, err = client.GetDepth(ctx, req.Symbol, depthLevels)
+			if err != nil {
+				e.logger.Warn("Failed to get order book, copying without slippage check",
+					slog.String("symbol", req.Symbol),
+					slog.Any("error", err))
+			}
+			depths[req.Symbol] = depth
+		}
+		if depth == nil {
+			continue
+		}
+
+		estimate := mexc.EstimateSlippage(*depth, req.Side, req.Volume*multiplier)
+		blocked[i] = mexc.CheckSlippage(estimate, e.maxSlippagePct)
+
+		e.logger.Info("Order book slippage estimate",
+			slog.String("symbol", req.Symbol),
+			slog.Int("side", req.Side),
+			slog.Float64("volume", estimate.Vol),
+			slog.Float64("covered", estimate.FilledVol),
+			slog.Float64("slippage_pct", estimate.SlippagePct),
+			slog.Bool("skip", blocked[i] != nil))
+	}
+
+	return blocked
+}
+
+// processOpenPositions обрабатывает пачку открытий позиций для одного аккаунта.
+// blocked - результат checkDepth: такие входы не отправляются, masterEquity - для SizingProportional.
+// Аккаунт, превысивший дневной убыток, входы не получает; вход по новому символу сверх
+// models.CopyCaps.MaxPositions пропускается. Результаты - в порядке reqs
+func (e *Engine) processOpenPositions(ctx context.Context, userID int, acc models.Account, reqs []OpenPositionRequest, blocked []error, masterEquity float64) []AccountResult {
+	results := make([]AccountResult, len(reqs))
+	for i := range results {
+		results[i] = AccountResult{
+			AccountID:   acc.ID,
+			AccountName: acc.Name,
+			Success:     false,
+		}
+	}
+
+	client, err := e.newClient(acc)
 	if err != nil {
 		e.logger.Error("Failed to create client",
 			slog.String("slave", acc.Name),
 			slog.Any("error", err))
-		result.Error = err.Error()
-		return result
+		for i := range results {
+			results[i].setError(err)
+		}
+		return results
+	}
+
+	if err := e.checkDailyLoss(ctx, client, userID, acc); err != nil {
+		for i := range results {
+			results[i].setError(err)
+		}
+		return results
+	}
+
+	symbols, err := e.openSymbols(ctx, client, acc)
+	if err != nil {
+		e.logger.Warn("Failed to get slave positions, entries not copied",
+			slog.String("slave", acc.Name),
+			slog.Any("error", err))
+		for i := range results {
+			results[i].setError(err)
+		}
+		return results
+	}
+
+	orders := make([]models.OpenPositionRequest, 0, len(reqs))
+	indexes := make([]int, 0, len(reqs))
+	for i, req := range reqs {
+		if blocked[i] != nil {
+			results[i].setError(blocked[i])
+			continue
+		}
+
+		if err := e.checkPositionLimit(acc, req, symbols); err != nil {
+			results[i].setError(err)
+			continue
+		}
+
+		order, ok := e.openOrder(ctx, client, userID, acc, req, masterEquity, &results[i])
+		if !ok {
+			continue
+		}
+		if symbols != nil {
+			symbols[req.Symbol] = struct{}{}
+		}
+
+		if e.dryRun {
+			e.logger.Info("DRY_RUN - Would place order",
+				slog.String("slave", acc.Name),
+				slog.String("symbol", order.Symbol),
+				slog.Int("side", order.Side),
+				slog.Int("volume", order.Vol),
+				slog.Int("leverage", order.Leverage),
+				slog.Int("openType", order.OpenType),
+				slog.String("limitPrice", order.Price),
+				slog.String("stopLoss", order.StopLossPrice))
+			results[i].Success = true
+			continue
+		}
+
+		orders = append(orders, order)
+		indexes = append(indexes, i)
+	}
+
+	if len(orders) == 0 {
+		return results
+	}
+
+	// Открываем позиции: limit ордера мастера копируются по той же цене
+	placed := client.PlaceOrders(ctx, orders)
+	ackedAt := e.clock.Now()
+
+	unconfirmed := make([]int, 0, len(placed))
+
+	for j, orderResult := range placed {
+		i := indexes[j]
+		result := &results[i]
+
+		if orderResult.Err != nil {
+			e.logger.Error("Failed to place order",
+				slog.String("slave", acc.Name),
+				slog.Any("error", orderResult.Err))
+			result.setError(orderResult.Err)
+			result.Retryable = orderRetryable(orderResult.Err)
+			// Ордер мог быть отклонен из-за плеча, измененного на сайте: следующий вход перечитает его
+			client.InvalidateLeverage(orders[j].Symbol)
+			continue
+		}
+
+		result.AckedAt = ackedAt
+
+		e.logger.Info("Order placed successfully",
+			slog.String("slave", acc.Name),
+			slog.String("order_id", orderResult.OrderID),
+			slog.Int("leverage", orders[j].Leverage))
+
+		result.Success = true
+		result.OrderID = orderResult.OrderID
+
+		// Limit ордер исполняется по своей цене и может ждать исполнения долго - подтверждается только market
+		if reqs[i].LimitPrice == 0 {
+			unconfirmed = append(unconfirmed, i)
+		}
+	}
+
+	// Ответ 200 на размещение не значит исполнение: ордера пачки подтверждаются по состоянию параллельно,
+	// чтобы ожидание одного не сдвигало остальные на fillWaitTimeout
+	var wg sync.WaitGroup
+	for _, i := range unconfirmed {
+		wg.Go(func() {
+			e.confirmFill(ctx, client, acc, &results[i])
+		})
+	}
+	wg.Wait()
+
+	return results
+}
+
+// confirmFill дожидается исполнения размещенного market ордера. Цена исполнения нужна для сравнения
+// с мастером (slippage); ордер, отмененный без исполнения, переводит result в ошибку
+func (e *Engine) confirmFill(ctx context.Context, client *mexc.Client, acc models.Account, result *AccountResult) {
+	order, err := client.WaitForFill(ctx, result.OrderID, fillWaitTimeout)
+	switch {
+	case errors.Is(err, mexc.ErrOrderNotFilled):
+		e.logger.Error("Order was not filled",
+			slog.String("slave", acc.Name),
+			slog.String("order_id", result.OrderID),
+			slog.Any("error", err))
+		result.Success = false
+		result.setError(err)
+	case err != nil:
+		// Ордер принят биржей, исход неизвестен - расхождение позиций поймает reconcile
+		e.logger.Warn("Failed to confirm order fill",
+			slog.String("slave", acc.Name),
+			slog.String("order_id", result.OrderID),
+			slog.Any("error", err))
+	default:
+		result.FillPrice = order.DealAvgPrice
+	}
+}
+
+// openOrder приводит вход мастера к объёму slave (sizeEntry), шагу контракта и плечу slave и собирает ордер.
+// false - ордер не отправляется, ошибка записана в result
+func (e *Engine) openOrder(ctx context.Context, client *mexc.Client, userID int, acc models.Account, req OpenPositionRequest, masterEquity float64, result *AccountResult) (models.OpenPositionRequest, bool) {
+	req, detail, capped, err := e.sizeEntry(ctx, client, userID, acc, req, masterEquity)
+	result.Capped = capped
+	if err != nil {
+		result.setError(err)
+		return models.OpenPositionRequest{}, false
 	}
 
-	// Получаем текущий leverage для этого аккаунта
-	currentLeverage, err := client.GetLeverageForSide(ctx, req.Symbol, req.Side)
+	// Получаем текущий leverage и ступень риск-лимита для этого аккаунта
+	leverage, err := client.GetLeverageInfoForSide(ctx, req.Symbol, req.Side)
 	if err != nil {
 		e.logger.Error("Failed to get leverage",
 			slog.String("slave", acc.Name),
 			slog.String("symbol", req.Symbol),
 			slog.Any("error", err))
-		result.Error = err.Error()
-		return result
+		result.setError(err)
+		return models.OpenPositionRequest{}, false
 	}
+	currentLeverage := leverage.Leverage
 
-	if e.dryRun {
-		e.logger.Info("DRY_RUN - Would place order",
+	// Ордер больше ступени риск-лимита биржа все равно отклонит. Текущая позиция slave не запрашивается
+	// (лишний запрос на пути копирования) - с ней лимит проверит биржа
+	if err := mexc.CheckRiskLimit(leverage, 0, req.Volume); err != nil {
+		e.logger.Warn("Order exceeds slave risk limit tier",
 			slog.String("slave", acc.Name),
 			slog.String("symbol", req.Symbol),
-			slog.Int("side", req.Side),
-			slog.Float64("volume", req.Volume),
-			slog.Int("leverage", currentLeverage),
-			slog.Float64("stopLoss", req.StopLossPrice))
-		result.Success = true
-		return result
+			slog.Any("error", err))
+		result.setError(err)
+		return models.OpenPositionRequest{}, false
 	}
 
-	// Открываем позицию
-	var orderID string
-	if req.StopLossPrice > 0 {
-		orderID, err = client.PlaceOrder(ctx, req.Symbol, req.Side, int(req.Volume), currentLeverage, req.StopLossPrice)
-	} else {
-		orderID, err = client.PlaceOrder(ctx, req.Symbol, req.Side, int(req.Volume), currentLeverage)
+	if req.LimitPrice > 0 {
+		return mexc.LimitOrder(req.Symbol, req.Side, int(req.Volume), currentLeverage, req.OpenType, req.LimitPrice, req.StopLossPrice), true
+	}
+
+	// Ориентир защиты - исполнение мастера, без него - рыночная цена в момент события
+	refPrice := req.MasterPrice
+	if refPrice <= 0 {
+		refPrice = req.MarketPrice
 	}
 
+	order := mexc.MarketOrder(req.Symbol, req.Side, int(req.Volume), currentLeverage, req.OpenType, req.StopLossPrice)
+	e.protection.Apply(&order, refPrice, detail)
+
+	return order, true
+}
+
+// sizeEntry приводит объём входа мастера к объёму slave: режим объёма пользователя (SizingMode), множитель
+// и ограничения аккаунта (CopyCaps), шаг контракта; цены - тоже по шагу. detail - параметры контракта (пустые,
+// если не получены), capped - объём уменьшен ограничениями
+func (e *Engine) sizeEntry(ctx context.Context, client *mexc.Client, userID int, acc models.Account, req OpenPositionRequest, masterEquity float64) (OpenPositionRequest, models.ContractDetail, bool, error) {
+	volume, err := e.scaleVolume(ctx, client, userID, acc, req.Volume, masterEquity)
 	if err != nil {
-		e.logger.Error("Failed to place order",
+		e.logger.Warn("Failed to scale volume, entry not copied",
 			slog.String("slave", acc.Name),
+			slog.String("symbol", req.Symbol),
 			slog.Any("error", err))
-		result.Error = err.Error()
-		return result
+		return req, models.ContractDetail{}, false, err
 	}
+	req.Volume = volume * acc.VolumeMultiplier()
 
-	e.logger.Info("Order placed successfully",
-		slog.String("slave", acc.Name),
-		slog.String("order_id", orderID),
-		slog.Int("leverage", currentLeverage))
+	// Объём и цены мастера - по шагу контракта
+	detail, detailErr := e.contractDetail(ctx, client, req.Symbol)
+	if detailErr != nil {
+		e.logger.Warn("Failed to get contract detail, sending master values as is",
+			slog.String("slave", acc.Name),
+			slog.String("symbol", req.Symbol),
+			slog.Any("error", detailErr))
+	}
 
-	result.Success = true
-	result.OrderID = orderID
+	// Ограничения slave аккаунта - до округления: округление вниз не выводит объём за них
+	capped, isCapped, err := e.applyCaps(ctx, client, acc, req, detail)
+	if err != nil {
+		e.logger.Warn("Failed to apply slave caps, entry not copied",
+			slog.String("slave", acc.Name),
+			slog.String("symbol", req.Symbol),
+			slog.Any("error", err))
+		return req, detail, false, err
+	}
+	if isCapped {
+		e.logCapped(acc, req, capped)
+		req.Volume = capped
+	}
 
-	return result
+	if detailErr == nil {
+		req.Volume = detail.RoundVolume(req.Volume)
+		req.LimitPrice = detail.RoundPrice(req.LimitPrice)
+		req.StopLossPrice = detail.RoundPrice(req.StopLossPrice)
+	}
+
+	switch {
+	case req.Volume <= 0 && isCapped:
+		return req, detail, true, ErrCapReached
+	case req.Volume <= 0:
+		return req, detail, false, fmt.Errorf("volume below contract minimum %v", detail.MinVol)
+	}
+
+	return req, detail, isCapped, nil
 }
 
 // ClosePosition закрывает позицию на всех slave аккаунтах
 func (e *Engine) ClosePosition(ctx context.Context, userID int, req ClosePositionRequest) (ExecutionResult, error) {
-	result, err := e.execute(userID, func(acc models2.Account) AccountResult {
-		return e.processClosePosition(ctx, acc, req)
+	// Частичное закрытие мастера копируется пропорционально: slave закрывают ту же долю своей позиции
+	ratio := 1.0
+	if req.Volume > 0 {
+		var err error
+		ratio, err = e.masterCloseRatio(ctx, userID, req)
+		if err != nil {
+			return ExecutionResult{}, err
+		}
+	}
+
+	result, err := e.execute(ctx, OpClose, userID, func(ctx context.Context, acc models.Account) AccountResult {
+		return e.processClosePosition(ctx, acc, req, ratio)
 	})
-	if err != nil {
+	if err != nil && !IsPartial(err) {
 		return ExecutionResult{}, err
 	}
 
-	record := models2.Trade{
+	record := models.Trade{
 		UserID: userID,
 		Symbol: req.Symbol,
+		Side:   req.Side,
+		Volume: int(req.Volume),
 		Action: "close_position",
 	}
-	if err := e.saveTrade(ctx, record, result); err != nil {
-		return ExecutionResult{}, fmt.Errorf("failed to save trade: %w", err)
+	tradeID, saveErr := e.storeTrade(ctx, record, result)
+	if saveErr != nil {
+		return ExecutionResult{}, fmt.Errorf("failed to save trade: %w", saveErr)
 	}
 
-	return result, nil
+	// Полное закрытие мастера отменяет еще не выполненные повторы входов по символу
+	if ratio >= 1 {
+		e.dropOpenRetries(ctx, userID, req.Symbol)
+	}
+	e.enqueueRetries(ctx, userID, tradeID, OpClose, req.Symbol, req, ratio, result)
+
+	return result, err
 }
 
-// processClosePosition обрабатывает закрытие позиции для одного аккаунта
-func (e *Engine) processClosePosition(ctx context.Context, acc models2.Account, req ClosePositionRequest) AccountResult {
+// masterCloseRatio возвращает долю позиции мастера, закрываемую req (1 - закрытие полностью)
+func (e *Engine) masterCloseRatio(ctx context.Context, userID int, req ClosePositionRequest) (float64, error) {
+	masterAccount, err := e.masterAccount(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get master account: %w", err)
+	}
+
+	masterClient, err := e.newClient(masterAccount)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create master client: %w", err)
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, e.timeouts.Close)
+	positions, err := masterClient.GetPositions(lookupCtx, req.Symbol)
+	cancel()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get master positions: %w", err)
+	}
+
+	positionType := ClosePositionType(req.Side)
+
+	var holdVol float64
+	for _, pos := range positions {
+		if pos.Symbol == req.Symbol && (positionType == 0 || pos.PositionType == positionType) {
+			holdVol += pos.HoldVol
+		}
+	}
+	if req.MasterExecuted {
+		holdVol += req.Volume
+	}
+
+	if holdVol <= req.Volume {
+		return 1, nil
+	}
+
+	return req.Volume / holdVol, nil
+}
+
+// processClosePosition обрабатывает закрытие позиции для одного аккаунта (ratio < 1 - частичное закрытие)
+func (e *Engine) processClosePosition(ctx context.Context, acc models.Account, req ClosePositionRequest, ratio float64) AccountResult {
 	result := AccountResult{
 		AccountID:   acc.ID,
 		AccountName: acc.Name,
 		Success:     false,
 	}
 
-	client, err := mexc.NewClient(acc, e.logger)
+	client, err := e.newClient(acc)
 	if err != nil {
 		e.logger.Error("Failed to create client",
 			slog.String("slave", acc.Name),
 			slog.Any("error", err))
-		result.Error = err.Error()
+		result.setError(err)
 		return result
 	}
 
+	if ratio < 1 {
+		return e.processClosePositionPartial(ctx, client, acc, req, ratio)
+	}
+
 	if e.dryRun {
 		e.logger.Info("DRY_RUN - Would close position",
 			slog.String("slave", acc.Name),
@@ -316,7 +1138,7 @@ func (e *Engine) processClosePosition(ctx context.Context, acc models2.Account,
 		e.logger.Error("Failed to close position",
 			slog.String("slave", acc.Name),
 			slog.Any("error", err))
-		result.Error = err.Error()
+		result.setError(err)
 		return result
 	}
 
@@ -329,16 +1151,76 @@ func (e *Engine) processClosePosition(ctx context.Context, acc models2.Account,
 	return result
 }
 
+// processClosePositionPartial закрывает долю ratio позиции slave того же направления, что у мастера
+func (e *Engine) processClosePositionPartial(ctx context.Context, client *mexc.Client, acc models.Account, req ClosePositionRequest, ratio float64) AccountResult {
+	result := AccountResult{
+		AccountID:   acc.ID,
+		AccountName: acc.Name,
+		Success:     false,
+	}
+
+	positions, err := client.GetPositions(ctx, req.Symbol)
+	if err != nil {
+		e.logger.Error("Failed to get slave positions",
+			slog.String("slave", acc.Name),
+			slog.Any("error", err))
+		result.setError(err)
+		return result
+	}
+
+	positionType := ClosePositionType(req.Side)
+
+	closed := false
+	for _, pos := range positions {
+		if pos.Symbol != req.Symbol || pos.HoldVol <= 0 || (positionType != 0 && pos.PositionType != positionType) {
+			continue
+		}
+
+		// Не меньше одного контракта, иначе мелкие позиции slave не закрываются никогда
+		vol := max(int(math.Round(pos.HoldVol*ratio)), 1)
+
+		if e.dryRun {
+			e.logger.Info("DRY_RUN - Would partially close position",
+				slog.String("slave", acc.Name),
+				slog.String("symbol", req.Symbol),
+				slog.Int("vol", vol),
+				slog.Float64("holdVol", pos.HoldVol))
+			continue
+		}
+
+		if err := client.ClosePositionPartial(ctx, req.Symbol, vol, pos.PositionID); err != nil {
+			e.logger.Error("Failed to partially close position",
+				slog.String("slave", acc.Name),
+				slog.Any("error", err))
+			result.setError(err)
+			// Повтор снова закрыл бы долю уже уменьшенной позиции, а ордер после таймаута мог исполниться
+			result.Retryable = !closed && orderRetryable(err)
+			return result
+		}
+		closed = true
+
+		e.logger.Info("Position partially closed",
+			slog.String("slave", acc.Name),
+			slog.String("symbol", req.Symbol),
+			slog.Int("vol", vol),
+			slog.Float64("holdVol", pos.HoldVol))
+	}
+
+	result.Success = true
+
+	return result
+}
+
 // PlacePlanOrder устанавливает SL/TP на всех slave аккаунтах
 func (e *Engine) PlacePlanOrder(ctx context.Context, userID int, req PlacePlanOrderRequest) (ExecutionResult, error) {
-	result, err := e.execute(userID, func(acc models2.Account) AccountResult {
+	result, err := e.execute(ctx, OpStopOrder, userID, func(ctx context.Context, acc models.Account) AccountResult {
 		return e.processPlacePlanOrder(ctx, acc, req)
 	})
-	if err != nil {
+	if err != nil && !IsPartial(err) {
 		return ExecutionResult{}, err
 	}
 
-	record := models2.Trade{
+	record := models.Trade{
 		UserID: userID,
 		Symbol: req.Symbol,
 		Action: "place_plan_order",
@@ -347,23 +1229,23 @@ func (e *Engine) PlacePlanOrder(ctx context.Context, userID int, req PlacePlanOr
 		return ExecutionResult{}, fmt.Errorf("failed to save trade: %w", err)
 	}
 
-	return result, nil
+	return result, err
 }
 
 // processPlacePlanOrder обрабатывает установку SL/TP для одного аккаунта
-func (e *Engine) processPlacePlanOrder(ctx context.Context, acc models2.Account, req PlacePlanOrderRequest) AccountResult {
+func (e *Engine) processPlacePlanOrder(ctx context.Context, acc models.Account, req PlacePlanOrderRequest) AccountResult {
 	result := AccountResult{
 		AccountID:   acc.ID,
 		AccountName: acc.Name,
 		Success:     false,
 	}
 
-	client, err := mexc.NewClient(acc, e.logger)
+	client, err := e.newClient(acc)
 	if err != nil {
 		e.logger.Error("Failed to create client",
 			slog.String("slave", acc.Name),
 			slog.Any("error", err))
-		result.Error = err.Error()
+		result.setError(err)
 		return result
 	}
 
@@ -377,12 +1259,17 @@ func (e *Engine) processPlacePlanOrder(ctx context.Context, acc models2.Account,
 		return result
 	}
 
+	if detail, err := e.contractDetail(ctx, client, req.Symbol); err == nil {
+		req.StopLossPrice = detail.RoundPrice(req.StopLossPrice)
+		req.TakeProfitPrice = detail.RoundPrice(req.TakeProfitPrice)
+	}
+
 	err = client.PlacePlanOrder(ctx, req.Symbol, req.StopLossPrice, req.TakeProfitPrice)
 	if err != nil {
 		e.logger.Error("Failed to set SL/TP",
 			slog.String("slave", acc.Name),
 			slog.Any("error", err))
-		result.Error = err.Error()
+		result.setError(err)
 		return result
 	}
 
@@ -415,17 +1302,19 @@ func (e *Engine) ChangePlanPrice(ctx context.Context, userID int, req ChangePlan
 
 	// 3. Fallback - API вызов к master account
 	if symbol == "" {
-		masterAccount, err := e.userStorage.GetMasterAccount(userID)
+		masterAccount, err := e.masterAccount(ctx, userID)
 		if err != nil {
 			return ExecutionResult{}, fmt.Errorf("failed to get master account: %w", err)
 		}
 
-		masterClient, err := mexc.NewClient(masterAccount, e.logger)
+		masterClient, err := e.newClient(masterAccount)
 		if err != nil {
 			return ExecutionResult{}, fmt.Errorf("failed to create master client: %w", err)
 		}
 
-		masterOrders, err := masterClient.GetOpenStopOrders(ctx, "")
+		lookupCtx, cancel := context.WithTimeout(ctx, e.timeouts.StopOrder)
+		masterOrders, err := masterClient.GetOpenStopOrders(lookupCtx, "")
+		cancel()
 		if err != nil {
 			return ExecutionResult{}, fmt.Errorf("failed to get master open orders: %w", err)
 		}
@@ -452,14 +1341,14 @@ func (e *Engine) ChangePlanPrice(ctx context.Context, userID int, req ChangePlan
 		return ExecutionResult{}, fmt.Errorf("stop order %d not found", req.StopPlanOrderID)
 	}
 
-	result, err := e.execute(userID, func(acc models2.Account) AccountResult {
+	result, err := e.execute(ctx, OpStopOrder, userID, func(ctx context.Context, acc models.Account) AccountResult {
 		return e.processChangePlanPrice(ctx, symbol, acc, req)
 	})
-	if err != nil {
+	if err != nil && !IsPartial(err) {
 		return ExecutionResult{}, err
 	}
 
-	record := models2.Trade{
+	record := models.Trade{
 		UserID: userID,
 		Symbol: symbol,
 		Action: "change_plan_price",
@@ -468,23 +1357,23 @@ func (e *Engine) ChangePlanPrice(ctx context.Context, userID int, req ChangePlan
 		return ExecutionResult{}, fmt.Errorf("failed to save trade: %w", err)
 	}
 
-	return result, nil
+	return result, err
 }
 
 // processChangePlanPrice обрабатывает обновление SL/TP для одного аккаунта
-func (e *Engine) processChangePlanPrice(ctx context.Context, symbol string, acc models2.Account, req ChangePlanPriceRequest) AccountResult {
+func (e *Engine) processChangePlanPrice(ctx context.Context, symbol string, acc models.Account, req ChangePlanPriceRequest) AccountResult {
 	result := AccountResult{
 		AccountID:   acc.ID,
 		AccountName: acc.Name,
 		Success:     false,
 	}
 
-	client, err := mexc.NewClient(acc, e.logger)
+	client, err := e.newClient(acc)
 	if err != nil {
 		e.logger.Error("Failed to create client",
 			slog.String("slave", acc.Name),
 			slog.Any("error", err))
-		result.Error = err.Error()
+		result.setError(err)
 		return result
 	}
 
@@ -494,7 +1383,7 @@ func (e *Engine) processChangePlanPrice(ctx context.Context, symbol string, acc
 		e.logger.Error("Failed to get slave open orders",
 			slog.String("slave", acc.Name),
 			slog.Any("error", err))
-		result.Error = err.Error()
+		result.setError(err)
 		return result
 	}
 
@@ -517,7 +1406,11 @@ func (e *Engine) processChangePlanPrice(ctx context.Context, symbol string, acc
 		return result
 	}
 
-	changeReq := models2.ChangePlanPriceRequest{
+	if detail, err := e.contractDetail(ctx, client, symbol); err == nil {
+		req.StopLossPrice = detail.RoundPrice(req.StopLossPrice)
+	}
+
+	changeReq := models.ChangePlanPriceRequest{
 		StopPlanOrderID:   slaveOrder.Id,
 		LossTrend:         req.LossTrend,
 		ProfitTrend:       req.ProfitTrend,
@@ -531,7 +1424,7 @@ func (e *Engine) processChangePlanPrice(ctx context.Context, symbol string, acc
 			slog.String("slave", acc.Name),
 			slog.String("symbol", symbol),
 			slog.Any("error", err))
-		result.Error = err.Error()
+		result.setError(err)
 		return result
 	}
 
@@ -546,14 +1439,14 @@ func (e *Engine) processChangePlanPrice(ctx context.Context, symbol string, acc
 
 // ChangeLeverage изменяет leverage на всех slave аккаунтах
 func (e *Engine) ChangeLeverage(ctx context.Context, userID int, req ChangeLeverageRequest) (ExecutionResult, error) {
-	result, err := e.execute(userID, func(acc models2.Account) AccountResult {
+	result, err := e.execute(ctx, OpLeverage, userID, func(ctx context.Context, acc models.Account) AccountResult {
 		return e.processChangeLeverage(ctx, acc, req)
 	})
-	if err != nil {
+	if err != nil && !IsPartial(err) {
 		return ExecutionResult{}, err
 	}
 
-	record := models2.Trade{
+	record := models.Trade{
 		UserID:   userID,
 		Symbol:   req.Symbol,
 		Leverage: req.Leverage,
@@ -563,23 +1456,23 @@ func (e *Engine) ChangeLeverage(ctx context.Context, userID int, req ChangeLever
 		return ExecutionResult{}, fmt.Errorf("failed to save trade: %w", err)
 	}
 
-	return result, nil
+	return result, err
 }
 
 // processChangeLeverage обрабатывает изменение leverage для одного аккаунта
-func (e *Engine) processChangeLeverage(ctx context.Context, acc models2.Account, req ChangeLeverageRequest) AccountResult {
+func (e *Engine) processChangeLeverage(ctx context.Context, acc models.Account, req ChangeLeverageRequest) AccountResult {
 	result := AccountResult{
 		AccountID:   acc.ID,
 		AccountName: acc.Name,
 		Success:     false,
 	}
 
-	client, err := mexc.NewClient(acc, e.logger)
+	client, err := e.newClient(acc)
 	if err != nil {
 		e.logger.Error("Failed to create client",
 			slog.String("slave", acc.Name),
 			slog.Any("error", err))
-		result.Error = err.Error()
+		result.setError(err)
 		return result
 	}
 
@@ -604,7 +1497,7 @@ func (e *Engine) processChangeLeverage(ctx context.Context, acc models2.Account,
 			slog.String("slave", acc.Name),
 			slog.String("symbol", req.Symbol),
 			slog.Any("error", err))
-		result.Error = err.Error()
+		result.setError(err)
 		return result
 	}
 
@@ -647,17 +1540,19 @@ func (e *Engine) CancelStopOrder(ctx context.Context, userID int, orderIDs []int
 
 	// 2. Если есть missing - идем по API
 	if len(missingOrderIDs) > 0 {
-		masterAccount, err := e.userStorage.GetMasterAccount(userID)
+		masterAccount, err := e.masterAccount(ctx, userID)
 		if err != nil {
 			return ExecutionResult{}, fmt.Errorf("failed to get master account: %w", err)
 		}
 
-		masterClient, err := mexc.NewClient(masterAccount, e.logger)
+		masterClient, err := e.newClient(masterAccount)
 		if err != nil {
 			return ExecutionResult{}, fmt.Errorf("failed to create master client: %w", err)
 		}
 
-		masterOrders, err := masterClient.GetOpenStopOrders(ctx, "")
+		lookupCtx, cancel := context.WithTimeout(ctx, e.timeouts.StopOrder)
+		masterOrders, err := masterClient.GetOpenStopOrders(lookupCtx, "")
+		cancel()
 		if err != nil {
 			return ExecutionResult{}, fmt.Errorf("failed to get master open orders: %w", err)
 		}
@@ -692,10 +1587,10 @@ func (e *Engine) CancelStopOrder(ctx context.Context, userID int, orderIDs []int
 	for _, sym := range symbols {
 		symbol := sym // capture для goroutine
 		errg.Go(func() error {
-			res, err := e.execute(userID, func(acc models2.Account) AccountResult {
-				return e.processCancelStopOrder(c, acc, CancelStopOrderRequest{Symbol: symbol})
+			res, err := e.execute(c, OpStopOrder, userID, func(ctx context.Context, acc models.Account) AccountResult {
+				return e.processCancelStopOrder(ctx, acc, CancelStopOrderRequest{Symbol: symbol})
 			})
-			if err != nil {
+			if err != nil && !IsPartial(err) {
 				return err
 			}
 
@@ -703,18 +1598,24 @@ func (e *Engine) CancelStopOrder(ctx context.Context, userID int, orderIDs []int
 			result.Results = append(result.Results, res.Results...)
 			result.SuccessCount += res.SuccessCount
 			result.FailedCount += res.FailedCount
+			result.SkippedCount += res.SkippedCount
 			result.TotalCount += res.TotalCount
 			mu.Unlock()
 
-			return nil
+			return err
 		})
 	}
 
-	if err := errg.Wait(); err != nil {
+	// Частичный результат по символу отменяет остальные символы, но исполненное сохраняется
+	err := errg.Wait()
+	var partial *PartialResultError
+	if errors.As(err, &partial) {
+		err = &PartialResultError{Result: result, Cause: partial.Cause}
+	} else if err != nil {
 		return ExecutionResult{}, fmt.Errorf("failed to cancel stop orders: %w", err)
 	}
 
-	record := models2.Trade{
+	record := models.Trade{
 		UserID: userID,
 		Action: "cancel_stop_order",
 	}
@@ -722,23 +1623,23 @@ func (e *Engine) CancelStopOrder(ctx context.Context, userID int, orderIDs []int
 		return ExecutionResult{}, fmt.Errorf("failed to save trade: %w", err)
 	}
 
-	return result, nil
+	return result, err
 }
 
 // processCancelStopOrder обрабатывает отмену стоп-ордера для одного аккаунта
-func (e *Engine) processCancelStopOrder(ctx context.Context, acc models2.Account, req CancelStopOrderRequest) AccountResult {
+func (e *Engine) processCancelStopOrder(ctx context.Context, acc models.Account, req CancelStopOrderRequest) AccountResult {
 	result := AccountResult{
 		AccountID:   acc.ID,
 		AccountName: acc.Name,
 		Success:     false,
 	}
 
-	client, err := mexc.NewClient(acc, e.logger)
+	client, err := e.newClient(acc)
 	if err != nil {
 		e.logger.Error("Failed to create client",
 			slog.String("slave", acc.Name),
 			slog.Any("error", err))
-		result.Error = err.Error()
+		result.setError(err)
 		return result
 	}
 
@@ -748,7 +1649,7 @@ func (e *Engine) processCancelStopOrder(ctx context.Context, acc models2.Account
 		e.logger.Error("Failed to get slave open orders",
 			slog.String("slave", acc.Name),
 			slog.Any("error", err))
-		result.Error = err.Error()
+		result.setError(err)
 		return result
 	}
 
@@ -775,7 +1676,7 @@ func (e *Engine) processCancelStopOrder(ctx context.Context, acc models2.Account
 			slog.String("slave", acc.Name),
 			slog.String("symbol", req.Symbol),
 			slog.Any("error", err))
-		result.Error = err.Error()
+		result.setError(err)
 		return result
 	}
 
@@ -790,14 +1691,14 @@ func (e *Engine) processCancelStopOrder(ctx context.Context, acc models2.Account
 
 // CancelStopOrderBySymbol отменяет стоп-ордера на всех slave аккаунтах по символу
 func (e *Engine) CancelStopOrderBySymbol(ctx context.Context, userID int, symbol string) (ExecutionResult, error) {
-	result, err := e.execute(userID, func(acc models2.Account) AccountResult {
+	result, err := e.execute(ctx, OpStopOrder, userID, func(ctx context.Context, acc models.Account) AccountResult {
 		return e.processCancelStopOrder(ctx, acc, CancelStopOrderRequest{Symbol: symbol})
 	})
-	if err != nil {
+	if err != nil && !IsPartial(err) {
 		return ExecutionResult{}, err
 	}
 
-	record := models2.Trade{
+	record := models.Trade{
 		UserID: userID,
 		Symbol: symbol,
 		Action: "cancel_stop_order",
@@ -806,5 +1707,5 @@ func (e *Engine) CancelStopOrderBySymbol(ctx context.Context, userID int, symbol
 		return ExecutionResult{}, fmt.Errorf("failed to save trade: %w", err)
 	}
 
-	return result, nil
+	return result, err
 }
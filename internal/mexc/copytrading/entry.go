@@ -0,0 +1,76 @@
+package copytrading
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+)
+
+// ErrSymbolFiltered - символ исключен фильтром символов пользователя, вход не копируется
+var ErrSymbolFiltered = errors.New("symbol excluded by symbol filter, entry not copied")
+
+// ErrAccountPaused - slave аккаунт приостановлен после anti-bot challenge, вход не копируется
+var ErrAccountPaused = errors.New("slave account paused, entry not copied")
+
+// CheckEntry проверяет, скопировал бы engine вход мастера в symbol на slave аккаунт: фильтр символов
+// пользователя, пауза аккаунта, дневной убыток и предел символов с позицией (models.CopyCaps.MaxPositions).
+// Ошибка - причина, по которой engine вход пропускает. Для сверки позиций (reconcile): недостающая
+// позиция, которую engine не открыл намеренно, не расхождение
+func (e *Engine) CheckEntry(ctx context.Context, userID int, acc models.Account, symbol string) error {
+	req := OpenPositionRequest{Symbol: symbol}
+	if !e.allowedEntries(userID, []OpenPositionRequest{req})[0] {
+		return ErrSymbolFiltered
+	}
+
+	if until := e.pausedUntil(acc.ID); !until.IsZero() {
+		return fmt.Errorf("%w until %s", ErrAccountPaused, until.Format(time.TimeOnly))
+	}
+
+	client, err := e.newClient(acc)
+	if err != nil {
+		return err
+	}
+
+	if err := e.checkDailyLoss(ctx, client, userID, acc); err != nil {
+		return err
+	}
+
+	symbols, err := e.openSymbols(ctx, client, acc)
+	if err != nil {
+		return err
+	}
+
+	return e.checkPositionLimit(acc, req, symbols)
+}
+
+// EntryVolume возвращает объём, который engine открыл бы на slave аккаунте для входа мастера объёмом masterVol
+// в symbol (side: 1=open long, 3=open short): режим объёма пользователя, множитель, ограничения аккаунта
+// и шаг контракта, как при копировании входа. ErrCapReached - ограничения не оставили объёма
+func (e *Engine) EntryVolume(ctx context.Context, userID int, acc models.Account, symbol string, side int, masterVol float64) (float64, error) {
+	client, err := e.newClient(acc)
+	if err != nil {
+		return 0, err
+	}
+
+	req := OpenPositionRequest{Symbol: symbol, Side: side, Volume: masterVol}
+	req, _, _, err = e.sizeEntry(ctx, client, userID, acc, req, e.masterEquity(ctx, userID))
+	if err != nil {
+		return 0, err
+	}
+
+	return req.Volume, nil
+}
+
+// Client возвращает MEXC клиент аккаунта из пула engine
+func (e *Engine) Client(acc models.Account) (*mexc.Client, error) {
+	return e.newClient(acc)
+}
+
+// DryRun сообщает, что ордера не отправляются (DRY_RUN)
+func (e *Engine) DryRun() bool {
+	return e.dryRun
+}
@@ -0,0 +1,206 @@
+package copytrading
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"tg_mexc/internal/mexc/websocket"
+	"tg_mexc/internal/models"
+)
+
+// fillRetention - сколько хранится сводка fill'ов ордера slave: fill'ы limit ордеров приходят
+// и после сохранения сделки
+const fillRetention = time.Hour
+
+// fillStorageTimeout - таймаут записи исполнения в детали сделки
+const fillStorageTimeout = 5 * time.Second
+
+// FillStorage - запись фактического исполнения ордеров slave в детали сделок (trade_details)
+type FillStorage interface {
+	UpdateTradeDetailFill(ctx context.Context, accountID int, orderID string, fill models.OrderFill) error
+}
+
+type fillKey struct {
+	accountID int
+	orderID   string
+}
+
+type orderFill struct {
+	fill      models.OrderFill
+	notional  float64
+	deals     map[string]struct{} // ID fill'ов: повтор после переподключения не учитывается дважды
+	updatedAt time.Time
+}
+
+// FillWatcher держит облегченные WebSocket соединения slave аккаунтов (только fill'ы) и сводит
+// их deal события по ордерам: цена исполнения и комиссия попадают в trade_details вместо одного ID ордера
+type FillWatcher struct {
+	storage FillStorage
+	logger  *slog.Logger
+	onRisk  func(userID int, risk LiquidationRisk)
+	onDeal  func(userID int, acc models.Account, deal websocket.DealEvent)
+
+	mu    sync.Mutex
+	conns map[int]*websocket.Client // accountID -> соединение
+	fills map[fillKey]*orderFill
+}
+
+// NewFillWatcher создает наблюдатель fill'ов slave аккаунтов
+func NewFillWatcher(storage FillStorage, logger *slog.Logger) *FillWatcher {
+	return &FillWatcher{
+		storage: storage,
+		logger:  logger,
+		conns:   make(map[int]*websocket.Client),
+		fills:   make(map[fillKey]*orderFill),
+	}
+}
+
+// SetRiskHandler задает обработчик предупреждений о риске ликвидации позиций slave аккаунтов
+// (Engine.SetFillWatcher). Вызывается до Watch
+func (w *FillWatcher) SetRiskHandler(handler func(userID int, risk LiquidationRisk)) {
+	w.onRisk = handler
+}
+
+// SetDealHandler задает получателя новых fill'ов slave аккаунтов (Engine.SetFillWatcher, дневной убыток).
+// Повтор fill'а после переподключения не передается. Вызывается до Watch
+func (w *FillWatcher) SetDealHandler(handler func(userID int, acc models.Account, deal websocket.DealEvent)) {
+	w.onDeal = handler
+}
+
+// Watch открывает соединение slave аккаунта пользователя userID в фоне, если его еще нет.
+// Повторный вызов ничего не делает
+func (w *FillWatcher) Watch(userID int, acc models.Account) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.conns[acc.ID]; ok {
+		return
+	}
+
+	client := websocket.New(acc, w.logger)
+	client.SetDealHandler(func(event any) {
+		if deal, ok := event.(websocket.DealEvent); ok && w.recordDeal(acc.ID, deal) && w.onDeal != nil {
+			w.onDeal(userID, acc, deal)
+		}
+	})
+	if w.onRisk != nil {
+		client.SetLiquidateRiskHandler(func(event any) {
+			if risk, ok := event.(websocket.LiquidateRiskEvent); ok {
+				w.onRisk(userID, LiquidationRiskFromEvent(acc, risk))
+			}
+		})
+	}
+	client.SetCloseHandler(func(err error) {
+		w.logger.Warn("Slave fill WebSocket closed",
+			slog.String("slave", acc.Name),
+			slog.Any("error", err))
+		w.forget(acc.ID, client)
+	})
+	w.conns[acc.ID] = client
+
+	go func() {
+		if err := client.Connect(context.Background()); err != nil {
+			w.logger.Warn("Failed to connect slave fill WebSocket",
+				slog.String("slave", acc.Name),
+				slog.Any("error", err))
+			w.forget(acc.ID, client)
+		}
+	}()
+}
+
+// Unwatch закрывает соединение slave аккаунта
+func (w *FillWatcher) Unwatch(accountID int) {
+	w.mu.Lock()
+	client, ok := w.conns[accountID]
+	delete(w.conns, accountID)
+	w.mu.Unlock()
+
+	if ok {
+		client.Disconnect()
+	}
+}
+
+// Close закрывает все соединения
+func (w *FillWatcher) Close() {
+	w.mu.Lock()
+	conns := w.conns
+	w.conns = make(map[int]*websocket.Client)
+	w.mu.Unlock()
+
+	for _, client := range conns {
+		client.Disconnect()
+	}
+}
+
+// Fill возвращает сводку уже полученных fill'ов ордера slave (false - fill'ов не было)
+func (w *FillWatcher) Fill(accountID int, orderID string) (models.OrderFill, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	fill, ok := w.fills[fillKey{accountID: accountID, orderID: orderID}]
+	if !ok {
+		return models.OrderFill{}, false
+	}
+
+	return fill.fill, true
+}
+
+// forget удаляет соединение, если оно не заменено новым (Watch после разрыва откроет его заново)
+func (w *FillWatcher) forget(accountID int, client *websocket.Client) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conns[accountID] == client {
+		delete(w.conns, accountID)
+	}
+}
+
+// recordDeal добавляет fill в сводку ордера и записывает ее в детали сделки. Если сделка еще
+// не сохранена, сводку возьмет Engine.saveTrade. false - fill пустой или уже учтен
+func (w *FillWatcher) recordDeal(accountID int, deal websocket.DealEvent) bool {
+	if deal.OrderID == "" || deal.Vol <= 0 {
+		return false
+	}
+
+	now := time.Now()
+	key := fillKey{accountID: accountID, orderID: deal.OrderID}
+
+	w.mu.Lock()
+	for k, fill := range w.fills {
+		if now.Sub(fill.updatedAt) > fillRetention {
+			delete(w.fills, k)
+		}
+	}
+
+	fill, ok := w.fills[key]
+	if !ok {
+		fill = &orderFill{deals: make(map[string]struct{})}
+		w.fills[key] = fill
+	}
+	if _, seen := fill.deals[deal.ID]; seen && deal.ID != "" {
+		w.mu.Unlock()
+		return false
+	}
+	fill.deals[deal.ID] = struct{}{}
+	fill.notional += deal.Vol * deal.Price
+	fill.fill.Vol += deal.Vol
+	fill.fill.Fee += deal.Fee
+	fill.fill.AvgPrice = fill.notional / fill.fill.Vol
+	fill.updatedAt = now
+	summary := fill.fill
+	w.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), fillStorageTimeout)
+	defer cancel()
+
+	if err := w.storage.UpdateTradeDetailFill(ctx, accountID, deal.OrderID, summary); err != nil {
+		w.logger.Warn("Failed to record slave fill",
+			slog.Int("account_id", accountID),
+			slog.String("order_id", deal.OrderID),
+			slog.Any("error", err))
+	}
+
+	return true
+}
@@ -0,0 +1,27 @@
+package copytrading
+
+import (
+	"context"
+	"time"
+)
+
+type eventTimeKey struct{}
+
+// WithEventTime сохраняет в контексте момент события у мастера.
+// Engine записывает его в trade_details для end-to-end latency аналитики.
+func WithEventTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, eventTimeKey{}, t)
+}
+
+// EventTime возвращает момент события у мастера, если он был передан через WithEventTime
+func EventTime(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(eventTimeKey{}).(time.Time)
+	return t, ok && !t.IsZero()
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,104 @@
+package copytrading
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tg_mexc/internal/mexc/websocket"
+	"tg_mexc/internal/models"
+)
+
+// liquidationRiskCooldown - не чаще одного предупреждения о риске ликвидации на позицию аккаунта:
+// биржа повторяет событие, пока позиция остается у границы
+const liquidationRiskCooldown = 15 * time.Minute
+
+// LiquidationRisk - предупреждение биржи о приближении позиции аккаунта к ликвидации
+// (push.personal.liquidate.risk соединения мастера или slave)
+type LiquidationRisk struct {
+	AccountID      int
+	AccountName    string
+	Master         bool
+	Symbol         string
+	PositionID     int64
+	PositionType   int // 1 long, 2 short
+	LiquidatePrice float64
+	MarginRatio    float64 // Уровень маржи позиции, % (0 - биржа не передала)
+	At             time.Time
+}
+
+// LiquidationRiskFromEvent конвертирует websocket.LiquidateRiskEvent аккаунта acc в LiquidationRisk
+func LiquidationRiskFromEvent(acc models.Account, event websocket.LiquidateRiskEvent) LiquidationRisk {
+	return LiquidationRisk{
+		AccountID:      acc.ID,
+		AccountName:    acc.Name,
+		Symbol:         event.Symbol,
+		PositionID:     event.PositionID,
+		PositionType:   event.PositionType,
+		LiquidatePrice: event.LiquidatePrice,
+		MarginRatio:    event.MarginRatio,
+		At:             time.Now(),
+	}
+}
+
+// riskKey - позиция аккаунта для cooldown предупреждений
+type riskKey struct {
+	accountID  int
+	positionID int64
+}
+
+// reportLiquidationRisk записывает предупреждение о риске ликвидации в журнал активности пользователя
+// и отправляет критическое уведомление (не чаще liquidationRiskCooldown на позицию)
+func (e *Engine) reportLiquidationRisk(userID int, risk LiquidationRisk) {
+	key := riskKey{accountID: risk.AccountID, positionID: risk.PositionID}
+
+	e.mu.Lock()
+	for k, at := range e.riskAlerts {
+		if risk.At.Sub(at) > liquidationRiskCooldown {
+			delete(e.riskAlerts, k)
+		}
+	}
+	if _, recent := e.riskAlerts[key]; recent {
+		e.mu.Unlock()
+		return
+	}
+	e.riskAlerts[key] = risk.At
+	e.mu.Unlock()
+
+	role := "slave"
+	if risk.Master {
+		role = "master"
+	}
+
+	e.logger.Warn("Position close to liquidation",
+		slog.Int("user_id", userID),
+		slog.String("role", role),
+		slog.String("account", risk.AccountName),
+		slog.String("symbol", risk.Symbol),
+		slog.Float64("liquidate_price", risk.LiquidatePrice),
+		slog.Float64("margin_ratio", risk.MarginRatio))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := e.logStorage.AddLog(ctx, models.ActivityLog{
+		UserID: &userID,
+		Level:  "warn",
+		Action: "liquidation_risk",
+		Message: fmt.Sprintf("Position %s of %s account %s is close to liquidation (liquidation price %g)",
+			risk.Symbol, role, risk.AccountName, risk.LiquidatePrice),
+	})
+	if err != nil {
+		e.logger.Warn("Failed to log liquidation risk", slog.Any("error", err))
+	}
+
+	if e.alerter != nil {
+		go e.alerter.LiquidationRisk(context.Background(), userID, risk.AccountName, risk.Master, risk.Symbol, risk.LiquidatePrice)
+	}
+}
+
+// ReportLiquidationRisk передает engine предупреждение о риске ликвидации позиции master аккаунта сессии
+func (s *Session) ReportLiquidationRisk(risk LiquidationRisk) {
+	risk.Master = true
+	s.engine.reportLiquidationRisk(s.userID, risk)
+}
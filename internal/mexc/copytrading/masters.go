@@ -0,0 +1,122 @@
+package copytrading
+
+import (
+	"context"
+	"fmt"
+
+	"tg_mexc/internal/models"
+)
+
+// masterSessionKey - ключ контекста сессии, от имени которой выполняется операция
+type masterSessionKey struct{}
+
+// withMasterSession привязывает операцию engine к сессии: master аккаунт сессии мастера и исключение
+// мастеров пользователя из slave аккаунтов
+func withMasterSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, masterSessionKey{}, session)
+}
+
+// masterSessionFrom возвращает сессию операции (nil - операция без сессии: mirror, бот)
+func masterSessionFrom(ctx context.Context) *Session {
+	session, _ := ctx.Value(masterSessionKey{}).(*Session)
+	return session
+}
+
+// masterAccount возвращает master аккаунт операции: мастер сессии мастера или master аккаунт пользователя
+func (e *Engine) masterAccount(ctx context.Context, userID int) (models.Account, error) {
+	if session := masterSessionFrom(ctx); session != nil && session.master != nil {
+		return *session.master, nil
+	}
+
+	return e.userStorage.GetMasterAccount(userID)
+}
+
+// slaves возвращает slave аккаунты операции. Аккаунты, которые сами копируются как мастера
+// (Session.ForMaster), не получают сделки других мастеров
+func (e *Engine) slaves(ctx context.Context, userID int) ([]models.Account, error) {
+	slaveAccounts, err := e.Slaves(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	session := masterSessionFrom(ctx)
+	if session == nil {
+		return slaveAccounts, nil
+	}
+
+	masters := session.root.masterIDs()
+	if len(masters) == 0 {
+		return slaveAccounts, nil
+	}
+
+	filtered := make([]models.Account, 0, len(slaveAccounts))
+	for _, acc := range slaveAccounts {
+		if _, isMaster := masters[acc.ID]; !isMaster {
+			filtered = append(filtered, acc)
+		}
+	}
+
+	return filtered, nil
+}
+
+// ForMaster возвращает сессию мастера: операции копируют сделки master аккаунта master на slave
+// аккаунты пользователя (кроме других мастеров). Сессия мастера активна, пока активна сессия
+// пользователя; повторный вызов для того же аккаунта возвращает ту же сессию, для master аккаунта
+// пользователя - саму сессию пользователя
+func (s *Session) ForMaster(master models.Account) (*Session, error) {
+	if s.root != s {
+		return nil, fmt.Errorf("session is already bound to master %s", s.master.Name)
+	}
+	if err := s.ensureActive(); err != nil {
+		return nil, err
+	}
+
+	if primary, err := s.engine.userStorage.GetMasterAccount(s.userID); err == nil && primary.ID == master.ID {
+		return s, nil
+	}
+
+	s.mastersMu.Lock()
+	defer s.mastersMu.Unlock()
+
+	if session, ok := s.masters[master.ID]; ok {
+		return session, nil
+	}
+
+	session := &Session{
+		userID:    s.userID,
+		engine:    s.engine,
+		name:      s.name,
+		startedAt: s.engine.clock.Now(),
+		master:    &master,
+		root:      s,
+	}
+	s.masters[master.ID] = session
+
+	return session, nil
+}
+
+// ReleaseMaster отвязывает сессию мастера: аккаунт снова получает сделки как slave
+func (s *Session) ReleaseMaster(masterID int) {
+	s.mastersMu.Lock()
+	defer s.mastersMu.Unlock()
+
+	delete(s.masters, masterID)
+}
+
+// masterIDs возвращает аккаунты сессий мастеров
+func (s *Session) masterIDs() map[int]struct{} {
+	s.mastersMu.Lock()
+	defer s.mastersMu.Unlock()
+
+	ids := make(map[int]struct{}, len(s.masters))
+	for id := range s.masters {
+		ids[id] = struct{}{}
+	}
+
+	return ids
+}
+
+// context привязывает операции к сессии (masterAccount, slaves)
+func (s *Session) context(ctx context.Context) context.Context {
+	return withMasterSession(ctx, s)
+}
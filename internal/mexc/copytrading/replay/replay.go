@@ -0,0 +1,107 @@
+package replay
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	copytrading "tg_mexc/internal/mexc/copytrading"
+	wscopytrading "tg_mexc/internal/mexc/copytrading/websocket"
+	"tg_mexc/internal/models"
+	"tg_mexc/internal/storage"
+)
+
+// sessionName - имя сессии replay в Manager
+const sessionName = "replay"
+
+// SlaveSummary - итог replay по slave аккаунту
+type SlaveSummary struct {
+	AccountID   int    `json:"account_id"`
+	AccountName string `json:"account_name"`
+	Success     int    `json:"success"`
+	Failed      int    `json:"failed"`
+}
+
+// Report - результат прогона записанных событий
+type Report struct {
+	Events  int            `json:"events"`
+	Skipped int            `json:"skipped"`
+	Errors  []string       `json:"errors,omitempty"`
+	Actions map[string]int `json:"actions"`
+	Slaves  []SlaveSummary `json:"slaves"`
+	Trades  []models.Trade `json:"trades"`
+}
+
+// Run прогоняет записанные события master аккаунта через Engine в DRY_RUN с хранилищем в памяти.
+// Сделки не исполняются и в базу не пишутся; для открытия позиций Engine по-прежнему
+// читает текущий leverage slave аккаунтов с биржи.
+func Run(ctx context.Context, userID int, accounts []models.Account, events []models.MasterEvent, logger *slog.Logger) (Report, error) {
+	mem := storage.NewMemory(accounts)
+	engine := copytrading.NewEngine(mem, mem, mem, mem, logger, true)
+	manager := copytrading.NewManager(engine, true, logger)
+
+	session, err := manager.CreateOrGetActiveSession(userID, sessionName)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to create replay session: %w", err)
+	}
+	defer manager.StopSession(userID, sessionName)
+
+	svc := wscopytrading.NewService(session, logger)
+
+	report := Report{
+		Actions: make(map[string]int),
+		Slaves:  []SlaveSummary{},
+		Trades:  []models.Trade{},
+	}
+	for _, event := range events {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		report.Events++
+		if err := svc.Replay(ctx, event); err != nil {
+			report.Skipped++
+			report.Errors = append(report.Errors, fmt.Sprintf("event %d: %v", event.ID, err))
+		}
+	}
+
+	report.Trades = append(report.Trades, mem.Trades()...)
+	report.Slaves = summarize(report.Trades)
+	for _, trade := range report.Trades {
+		report.Actions[trade.Action]++
+	}
+
+	return report, nil
+}
+
+// summarize считает успешные и неуспешные исполнения по slave аккаунтам
+func summarize(trades []models.Trade) []SlaveSummary {
+	byAccount := make(map[int]*SlaveSummary)
+	for _, trade := range trades {
+		for _, detail := range trade.Details {
+			summary, ok := byAccount[detail.AccountID]
+			if !ok {
+				summary = &SlaveSummary{AccountID: detail.AccountID, AccountName: detail.AccountName}
+				byAccount[detail.AccountID] = summary
+			}
+
+			if detail.Status == "success" {
+				summary.Success++
+			} else {
+				summary.Failed++
+			}
+		}
+	}
+
+	slaves := make([]SlaveSummary, 0, len(byAccount))
+	for _, summary := range byAccount {
+		slaves = append(slaves, *summary)
+	}
+	slices.SortFunc(slaves, func(a, b SlaveSummary) int {
+		return cmp.Compare(a.AccountID, b.AccountID)
+	})
+
+	return slaves
+}
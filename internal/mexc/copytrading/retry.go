@@ -0,0 +1,312 @@
+package copytrading
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+)
+
+// RetryPollInterval - как часто очередь повторов проверяется на повторы, которым пришло время
+const RetryPollInterval = 500 * time.Millisecond
+
+// retryBatchSize - сколько повторов обрабатывается за одну проверку очереди
+const retryBatchSize = 50
+
+// errRetryFiltered - вход исключен фильтром символов пользователя после неудачной копии, повтор отменяется
+var errRetryFiltered = errors.New("entry excluded by symbol filter")
+
+// RetryStorage - очередь повторов копий (переживает перезапуск приложения)
+type RetryStorage interface {
+	AddCopyRetry(ctx context.Context, retry models.CopyRetry) error
+	GetDueCopyRetries(ctx context.Context, now time.Time, limit int) ([]models.CopyRetry, error)
+	UpdateCopyRetry(ctx context.Context, retry models.CopyRetry) error
+	DeleteCopyRetry(ctx context.Context, id int) error
+	DeleteCopyRetries(ctx context.Context, userID int, op, symbol string) error
+}
+
+// RetryConfig - повтор открытий и закрытий slave, не выполненных из-за временной ошибки
+// (rate limit, таймаут до отправки ордера)
+type RetryConfig struct {
+	MaxAttempts int           // Повторов на копию (0 - очередь выключена)
+	BaseDelay   time.Duration // Пауза перед первым повтором, перед каждым следующим - вдвое больше
+	MaxAge      time.Duration // Копия старше не повторяется: цена и позиция мастера уже другие
+}
+
+// backoff возвращает паузу перед повтором номер attempt (с 1)
+func (c RetryConfig) backoff(attempt int) time.Duration {
+	return c.BaseDelay << min(max(attempt-1, 0), 16)
+}
+
+// SetRetryQueue включает очередь повторов копий: slave, не получивший открытие или закрытие из-за временной
+// ошибки, получает его повторно с нарастающей паузой (Manager.RunRetries). Каждая попытка записывается
+// в детали сделки
+func (e *Engine) SetRetryQueue(storage RetryStorage, cfg RetryConfig) {
+	e.retries = storage
+	e.retryCfg = cfg
+}
+
+// orderRetryable сообщает, можно ли повторить ордер после ошибки отправки: только отказ биржи по rate limit.
+// Потерянный ответ (таймаут) не значит, что ордер не исполнен - повтор открыл бы позицию дважды
+func orderRetryable(err error) bool {
+	return mexc.ErrorKindOf(err) == mexc.ErrorKindRateLimit
+}
+
+// enqueueRetries ставит в очередь повторы копий, не выполненных на slave из-за временной ошибки.
+// payload - запрос engine (OpenPositionRequest, ClosePositionRequest), ratio - доля закрытия
+func (e *Engine) enqueueRetries(ctx context.Context, userID, tradeID int, op Operation, symbol string, payload any, ratio float64, result ExecutionResult) {
+	if e.retries == nil || e.retryCfg.MaxAttempts <= 0 {
+		return
+	}
+
+	request, err := json.Marshal(payload)
+	if err != nil {
+		e.logger.Error("Failed to encode copy retry", slog.Any("error", err))
+		return
+	}
+
+	now := e.clock.Now()
+	if t, ok := EventTime(ctx); ok {
+		now = t
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	for _, r := range result.Results {
+		if r.Success || !r.Retryable {
+			continue
+		}
+
+		retry := models.CopyRetry{
+			UserID:        userID,
+			AccountID:     r.AccountID,
+			TradeID:       tradeID,
+			Op:            string(op),
+			Symbol:        symbol,
+			Request:       string(request),
+			Ratio:         ratio,
+			LastError:     r.Error,
+			NextAttemptAt: e.clock.Now().Add(e.retryCfg.backoff(1)),
+			CreatedAt:     now,
+		}
+		if err := e.retries.AddCopyRetry(ctx, retry); err != nil {
+			e.logger.Error("Failed to enqueue copy retry",
+				slog.String("slave", r.AccountName),
+				slog.Any("error", err))
+			continue
+		}
+
+		e.logger.Info("Copy retry enqueued",
+			slog.String("slave", r.AccountName),
+			slog.String("op", string(op)),
+			slog.String("symbol", symbol),
+			slog.String("error", r.Error))
+	}
+}
+
+// dropOpenRetries отменяет повторы входов пользователя по символу: мастер уже закрыл позицию,
+// поздний вход оставил бы slave позицию, которой нет у мастера
+func (e *Engine) dropOpenRetries(ctx context.Context, userID int, symbol string) {
+	if e.retries == nil {
+		return
+	}
+
+	if err := e.retries.DeleteCopyRetries(context.WithoutCancel(ctx), userID, string(OpOpen), symbol); err != nil {
+		e.logger.Error("Failed to drop open retries",
+			slog.String("symbol", symbol),
+			slog.Any("error", err))
+	}
+}
+
+// RunRetries повторяет копии из очереди повторов, пока не отменен ctx. Повтор выполняется только
+// инстансом с активной сессией пользователя; без нее он ждет сессию до RetryConfig.MaxAge
+func (m *Manager) RunRetries(ctx context.Context) {
+	e := m.engine
+	if e.retries == nil || e.retryCfg.MaxAttempts <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(RetryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		retries, err := e.retries.GetDueCopyRetries(ctx, e.clock.Now(), retryBatchSize)
+		if err != nil {
+			m.logger.Error("Failed to get copy retries", slog.Any("error", err))
+			continue
+		}
+
+		active := m.ActiveUserIDs()
+		for _, retry := range retries {
+			if ctx.Err() != nil {
+				return
+			}
+			e.processRetry(ctx, retry, slices.Contains(active, retry.UserID))
+		}
+	}
+}
+
+// processRetry выполняет очередную попытку повтора и записывает ее в детали сделки. Повтор удаляется
+// после успеха, постоянной ошибки, последней попытки или по возрасту
+func (e *Engine) processRetry(ctx context.Context, retry models.CopyRetry, sessionActive bool) {
+	now := e.clock.Now()
+	if now.Sub(retry.CreatedAt) > e.retryCfg.MaxAge {
+		e.logger.Warn("Copy retry expired",
+			slog.Int("account_id", retry.AccountID),
+			slog.String("op", retry.Op),
+			slog.String("symbol", retry.Symbol),
+			slog.Int("attempts", retry.Attempts),
+			slog.String("last_error", retry.LastError))
+		e.deleteRetry(ctx, retry)
+		return
+	}
+
+	// Сессия пользователя может быть на другом инстансе: повтор выполнит он
+	if !sessionActive {
+		return
+	}
+
+	acc, err := e.retryAccount(ctx, retry)
+	if err != nil {
+		e.logger.Warn("Copy retry dropped", slog.Int("account_id", retry.AccountID), slog.Any("error", err))
+		e.deleteRetry(ctx, retry)
+		return
+	}
+
+	// Пока аккаунт приостановлен, попытка не тратится
+	if !e.pausedUntil(acc.ID).IsZero() {
+		return
+	}
+
+	retry.Attempts++
+	startTime := e.clock.Now()
+	result, err := e.retryOnce(ctx, retry, acc)
+	if err != nil {
+		level := slog.LevelError
+		if errors.Is(err, errRetryFiltered) {
+			level = slog.LevelInfo
+		}
+		e.logger.Log(ctx, level, "Copy retry dropped",
+			slog.String("slave", acc.Name),
+			slog.String("symbol", retry.Symbol),
+			slog.Any("error", err))
+		e.deleteRetry(ctx, retry)
+		return
+	}
+	if result.AckedAt.IsZero() {
+		result.AckedAt = e.clock.Now()
+	}
+
+	status := "success"
+	if !result.Success {
+		status = "failed"
+	}
+	detail := models.TradeDetail{
+		TradeID:      retry.TradeID,
+		AccountID:    acc.ID,
+		Status:       status,
+		Error:        result.Error,
+		OrderID:      result.OrderID,
+		LatencyMs:    int(result.AckedAt.Sub(startTime).Milliseconds()),
+		FillPrice:    result.FillPrice,
+		Capped:       result.Capped,
+		Attempt:      retry.Attempts,
+		DispatchedAt: timePtr(startTime),
+		AckedAt:      timePtr(result.AckedAt),
+	}
+	if err := e.tradeStorage.AddTradeDetail(context.WithoutCancel(ctx), detail); err != nil {
+		e.logger.Error("Failed to save copy retry attempt", slog.Any("error", err))
+	}
+
+	e.logger.Info("Copy retried",
+		slog.String("slave", acc.Name),
+		slog.String("op", retry.Op),
+		slog.String("symbol", retry.Symbol),
+		slog.Int("attempt", retry.Attempts),
+		slog.Bool("success", result.Success),
+		slog.String("error", result.Error))
+
+	if result.Success || !result.Retryable || retry.Attempts >= e.retryCfg.MaxAttempts {
+		e.deleteRetry(ctx, retry)
+		return
+	}
+
+	retry.LastError = result.Error
+	retry.NextAttemptAt = e.clock.Now().Add(e.retryCfg.backoff(retry.Attempts + 1))
+	e.updateRetry(ctx, retry)
+}
+
+// retryAccount находит slave аккаунт повтора (удаленный аккаунт или ставший мастером не повторяется)
+func (e *Engine) retryAccount(ctx context.Context, retry models.CopyRetry) (models.Account, error) {
+	slaves, err := e.userStorage.GetSlaveAccounts(retry.UserID, true)
+	if err != nil {
+		return models.Account{}, fmt.Errorf("failed to get slave accounts: %w", err)
+	}
+
+	for _, acc := range slaves {
+		if acc.ID == retry.AccountID {
+			return acc, nil
+		}
+	}
+
+	return models.Account{}, fmt.Errorf("slave account %d not found", retry.AccountID)
+}
+
+// retryOnce выполняет копию повтора на slave аккаунте в пределах таймаута операции. Вход проходит те же
+// проверки, что и в OpenPosition: фильтр символов (мог измениться после неудачи) и стакан
+func (e *Engine) retryOnce(ctx context.Context, retry models.CopyRetry, acc models.Account) (AccountResult, error) {
+	switch Operation(retry.Op) {
+	case OpOpen:
+		var req OpenPositionRequest
+		if err := json.Unmarshal([]byte(retry.Request), &req); err != nil {
+			return AccountResult{}, fmt.Errorf("invalid open retry: %w", err)
+		}
+
+		reqs := []OpenPositionRequest{req}
+		if !e.allowedEntries(retry.UserID, reqs)[0] {
+			return AccountResult{}, errRetryFiltered
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, e.timeouts.Open)
+		defer cancel()
+
+		blocked := e.checkDepth(ctx, retry.UserID, reqs)
+		masterEquity := e.masterEquity(ctx, retry.UserID)
+		return e.processOpenPositions(ctx, retry.UserID, acc, reqs, blocked, masterEquity)[0], nil
+	case OpClose:
+		var req ClosePositionRequest
+		if err := json.Unmarshal([]byte(retry.Request), &req); err != nil {
+			return AccountResult{}, fmt.Errorf("invalid close retry: %w", err)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, e.timeouts.Close)
+		defer cancel()
+
+		return e.processClosePosition(ctx, acc, req, retry.Ratio), nil
+	}
+
+	return AccountResult{}, fmt.Errorf("unknown retry op %q", retry.Op)
+}
+
+func (e *Engine) updateRetry(ctx context.Context, retry models.CopyRetry) {
+	if err := e.retries.UpdateCopyRetry(context.WithoutCancel(ctx), retry); err != nil {
+		e.logger.Error("Failed to update copy retry", slog.Int("id", retry.ID), slog.Any("error", err))
+	}
+}
+
+func (e *Engine) deleteRetry(ctx context.Context, retry models.CopyRetry) {
+	if err := e.retries.DeleteCopyRetry(context.WithoutCancel(ctx), retry.ID); err != nil {
+		e.logger.Error("Failed to delete copy retry", slog.Int("id", retry.ID), slog.Any("error", err))
+	}
+}
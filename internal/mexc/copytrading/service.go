@@ -8,80 +8,160 @@ import (
 	"sync"
 	"time"
 
-	models2 "tg_mexc/internal/models"
+	"tg_mexc/internal/models"
 )
 
+// eventKeyRetention - сколько сессия помнит обработанные события мастера: MEXC повторяет события
+// после переподключения, позже повтор уже не приходит
+const eventKeyRetention = 10 * time.Minute
+
 type Session struct {
-	userID int
-	active bool
-	engine *Engine
-	name   string
-	mu     sync.RWMutex
+	userID    int
+	active    bool
+	engine    *Engine
+	name      string
+	startedAt time.Time
+	mu        sync.RWMutex
+
+	eventKeys   map[string]time.Time // Ключ идемпотентности события мастера -> момент обработки
+	eventKeysMu sync.Mutex
+
+	// Несколько мастеров пользователя (masters.go): у сессии мастера root - сессия пользователя,
+	// у сессии пользователя root - она сама
+	master    *models.Account // Master аккаунт сессии мастера, nil - master аккаунт пользователя
+	root      *Session
+	masters   map[int]*Session // Сессии мастеров (только у сессии пользователя)
+	mastersMu sync.Mutex
 }
 
 func (s *Session) isActive() bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.active
+	s.root.mu.RLock()
+	defer s.root.mu.RUnlock()
+	return s.root.active
 }
 
 func (s *Session) ensureActive() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.root.mu.Lock()
+	defer s.root.mu.Unlock()
 
-	if !s.active {
+	if !s.root.active {
 		return errors.New("session is not active")
 	}
 
 	return nil
 }
 
-func (s *Session) GetMasterAccount() (models2.Account, error) {
-	return s.engine.userStorage.GetMasterAccount(s.userID)
+// UserID возвращает пользователя сессии
+func (s *Session) UserID() int {
+	return s.userID
+}
+
+// SetIncludeDisabled включает копирование и на slave аккаунты, отключенные из-за комиссии (ignore fees)
+func (s *Session) SetIncludeDisabled(include bool) {
+	s.engine.setIncludeDisabled(s.userID, include)
+}
+
+// Slaves возвращает slave аккаунты, на которые копируются сделки сессии
+func (s *Session) Slaves() ([]models.Account, error) {
+	return s.engine.slaves(s.context(context.Background()), s.userID)
+}
+
+func (s *Session) GetMasterAccount() (models.Account, error) {
+	return s.engine.masterAccount(s.context(context.Background()), s.userID)
 }
 
 func (s *Session) OpenPosition(ctx context.Context, req OpenPositionRequest) (ExecutionResult, error) {
 	return s.execute(func() (ExecutionResult, error) {
-		return s.engine.OpenPosition(ctx, s.userID, req)
+		return s.engine.OpenPosition(s.context(ctx), s.userID, req)
 	})
 }
 
 func (s *Session) ClosePosition(ctx context.Context, req ClosePositionRequest) (ExecutionResult, error) {
 	return s.execute(func() (ExecutionResult, error) {
-		return s.engine.ClosePosition(ctx, s.userID, req)
+		return s.engine.ClosePosition(s.context(ctx), s.userID, req)
 	})
 }
 
 func (s *Session) PlacePlanOrder(ctx context.Context, req PlacePlanOrderRequest) (ExecutionResult, error) {
 	return s.execute(func() (ExecutionResult, error) {
-		return s.engine.PlacePlanOrder(ctx, s.userID, req)
+		return s.engine.PlacePlanOrder(s.context(ctx), s.userID, req)
 	})
 }
 
 func (s *Session) ChangePlanPrice(ctx context.Context, req ChangePlanPriceRequest) (ExecutionResult, error) {
 	return s.execute(func() (ExecutionResult, error) {
-		return s.engine.ChangePlanPrice(ctx, s.userID, req)
+		return s.engine.ChangePlanPrice(s.context(ctx), s.userID, req)
 	})
 }
 
 func (s *Session) ChangeLeverage(ctx context.Context, req ChangeLeverageRequest) (ExecutionResult, error) {
 	return s.execute(func() (ExecutionResult, error) {
-		return s.engine.ChangeLeverage(ctx, s.userID, req)
+		return s.engine.ChangeLeverage(s.context(ctx), s.userID, req)
 	})
 }
 
 func (s *Session) CancelStopOrder(ctx context.Context, orderIDs []int) (ExecutionResult, error) {
 	return s.execute(func() (ExecutionResult, error) {
-		return s.engine.CancelStopOrder(ctx, s.userID, orderIDs)
+		return s.engine.CancelStopOrder(s.context(ctx), s.userID, orderIDs)
 	})
 }
 
 func (s *Session) CancelStopOrderBySymbol(ctx context.Context, symbol string) (ExecutionResult, error) {
 	return s.execute(func() (ExecutionResult, error) {
-		return s.engine.CancelStopOrderBySymbol(ctx, s.userID, symbol)
+		return s.engine.CancelStopOrderBySymbol(s.context(ctx), s.userID, symbol)
+	})
+}
+
+func (s *Session) PlaceTrailingStop(ctx context.Context, req PlaceTrailingStopRequest) (ExecutionResult, error) {
+	return s.execute(func() (ExecutionResult, error) {
+		return s.engine.PlaceTrailingStop(s.context(ctx), s.userID, req)
+	})
+}
+
+func (s *Session) ChangeTrailingStop(ctx context.Context, req ChangeTrailingStopRequest) (ExecutionResult, error) {
+	return s.execute(func() (ExecutionResult, error) {
+		return s.engine.ChangeTrailingStop(s.context(ctx), s.userID, req)
+	})
+}
+
+func (s *Session) CancelTrailingStop(ctx context.Context, req CancelTrailingStopRequest) (ExecutionResult, error) {
+	return s.execute(func() (ExecutionResult, error) {
+		return s.engine.CancelTrailingStop(s.context(ctx), s.userID, req)
 	})
 }
 
+func (s *Session) CancelOrders(ctx context.Context, req CancelOrderRequest) (ExecutionResult, error) {
+	return s.execute(func() (ExecutionResult, error) {
+		return s.engine.CancelOrders(s.context(ctx), s.userID, req)
+	})
+}
+
+// MasterState возвращает открытые позиции и SL/TP ордера master аккаунта
+// (сверка с последним известным состоянием после переподключения WebSocket)
+func (s *Session) MasterState(ctx context.Context) ([]models.Position, []models.StopOrder, error) {
+	master, err := s.GetMasterAccount()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get master account: %w", err)
+	}
+
+	client, err := s.engine.newClient(master)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	positions, err := client.GetPositions(ctx, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get master positions: %w", err)
+	}
+
+	stops, err := client.GetOpenStopOrders(ctx, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get master stop orders: %w", err)
+	}
+
+	return positions, stops, nil
+}
+
 // SaveStopOrder сохраняет stop order в кэш для оптимизации последующих lookup'ов
 func (s *Session) SaveStopOrder(orderID string, symbol string) error {
 	if s.engine.stopOrderCache == nil {
@@ -90,6 +170,52 @@ func (s *Session) SaveStopOrder(orderID string, symbol string) error {
 	return s.engine.stopOrderCache.SaveStopOrder(s.userID, orderID, symbol)
 }
 
+// RecordDeal передает fill master аккаунта всем подключенным получателям
+func (s *Session) RecordDeal(deal models.Deal) error {
+	var errs []error
+	for _, recorder := range s.engine.dealRecorders {
+		errs = append(errs, recorder.RecordDeal(s.userID, deal))
+	}
+	return errors.Join(errs...)
+}
+
+// MarkEvent отмечает событие мастера с ключом идемпотентности key как обработанное. false - событие
+// с этим ключом сессия уже обработала (повторная доставка), копировать его не нужно. Пустой key не проверяется
+func (s *Session) MarkEvent(key string) bool {
+	if key == "" {
+		return true
+	}
+
+	now := s.engine.clock.Now()
+
+	s.eventKeysMu.Lock()
+	defer s.eventKeysMu.Unlock()
+
+	if s.eventKeys == nil {
+		s.eventKeys = make(map[string]time.Time)
+	}
+	for k, at := range s.eventKeys {
+		if now.Sub(at) > eventKeyRetention {
+			delete(s.eventKeys, k)
+		}
+	}
+
+	if _, seen := s.eventKeys[key]; seen {
+		return false
+	}
+	s.eventKeys[key] = now
+
+	return true
+}
+
+// RecordEvent сохраняет событие master аккаунта в журнал (если запись включена)
+func (s *Session) RecordEvent(event models.MasterEvent) error {
+	if s.engine.eventStorage == nil {
+		return nil
+	}
+	return s.engine.eventStorage.AddMasterEvent(s.userID, event)
+}
+
 func (s *Session) execute(fn func() (ExecutionResult, error)) (ExecutionResult, error) {
 	if err := s.ensureActive(); err != nil {
 		return ExecutionResult{}, err
@@ -128,8 +254,14 @@ func (m *Manager) StopAllSessions() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for _, session := range m.sessions {
+	for userID, session := range m.sessions {
 		session.active = false
+		m.engine.setIncludeDisabled(userID, false)
+		m.engine.setCopyDelay(userID, CopyDelay{})
+	}
+
+	if m.engine.fills != nil {
+		m.engine.fills.Close()
 	}
 
 	m.sessions = make(map[int]*Session)
@@ -161,6 +293,39 @@ func (m *Manager) CreateOrGetActiveSession(userID int, name string) (*Session, e
 	return session, nil
 }
 
+// Slaves возвращает slave аккаунты, на которые engine копирует сделки пользователя
+func (m *Manager) Slaves(userID int) ([]models.Account, error) {
+	return m.engine.Slaves(userID)
+}
+
+// ActiveUserIDs возвращает пользователей с активной сессией copy trading
+func (m *Manager) ActiveUserIDs() []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	userIDs := make([]int, 0, len(m.sessions))
+	for userID, session := range m.sessions {
+		if session.isActive() {
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	return userIDs
+}
+
+// ActiveSince возвращает время старта активной сессии пользователя (false если сессии нет)
+func (m *Manager) ActiveSince(userID int) (time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[userID]
+	if !ok || !session.isActive() {
+		return time.Time{}, false
+	}
+
+	return session.startedAt, true
+}
+
 func (m *Manager) GetSession(userID int, name string) (*Session, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -196,18 +361,22 @@ func (m *Manager) startSession(userID int, name string) (*Session, error) {
 	}
 
 	session = &Session{
-		userID: userID,
-		engine: m.engine,
-		name:   name,
-		active: true,
+		userID:    userID,
+		engine:    m.engine,
+		name:      name,
+		active:    true,
+		startedAt: m.engine.clock.Now(),
+		masters:   make(map[int]*Session),
 	}
+	session.root = session
 
 	m.sessions[userID] = session
+	m.engine.startFillWatch(userID)
 
 	// Логируем старт сессии
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	m.engine.logStorage.AddLog(ctx, models2.ActivityLog{
+	m.engine.logStorage.AddLog(ctx, models.ActivityLog{
 		UserID:  &userID,
 		Level:   "info",
 		Action:  "copy_trading_start",
@@ -232,13 +401,16 @@ func (m *Manager) StopSession(userID int, name string) error {
 	}
 
 	session.active = false
+	m.engine.setIncludeDisabled(userID, false)
+	m.engine.setCopyDelay(userID, CopyDelay{})
+	m.engine.stopFillWatch(userID)
 
 	delete(m.sessions, userID)
 
 	// Логируем остановку сессии
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	m.engine.logStorage.AddLog(ctx, models2.ActivityLog{
+	m.engine.logStorage.AddLog(ctx, models.ActivityLog{
 		UserID:  &userID,
 		Level:   "info",
 		Action:  "copy_trading_stop",
@@ -0,0 +1,133 @@
+package copytrading
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"tg_mexc/internal/features"
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+)
+
+// SizingMode - как engine выбирает объём входа slave аккаунта
+type SizingMode string
+
+const (
+	// SizingFixed - slave открывает тот же объём (контрактов), что и мастер
+	SizingFixed SizingMode = "fixed"
+	// SizingProportional - объём мастера умножается на slave_equity / master_equity
+	// и округляется до шага контракта: маленький slave не открывает позицию размером с мастера
+	SizingProportional SizingMode = "proportional"
+)
+
+// ErrEquityUnknown - пропорциональный объём не посчитан: equity мастера или slave неизвестен.
+// Вход не копируется, чтобы не открыть slave объём мастера
+var ErrEquityUnknown = errors.New("equity unknown, proportional volume not computed")
+
+// ParseSizingMode разбирает режим объёма (пусто - SizingFixed)
+func ParseSizingMode(raw string) (SizingMode, error) {
+	switch mode := SizingMode(raw); mode {
+	case "":
+		return SizingFixed, nil
+	case SizingFixed, SizingProportional:
+		return mode, nil
+	}
+
+	return "", fmt.Errorf("unknown sizing mode %q", raw)
+}
+
+// SetSizingMode задает режим объёма входов slave аккаунтов (по умолчанию SizingFixed)
+func (e *Engine) SetSizingMode(mode SizingMode) {
+	e.sizing = mode
+}
+
+// FeatureFlags - feature flags пользователей (глобальные FEATURE_FLAGS с per-user переопределениями)
+type FeatureFlags interface {
+	IsEnabled(userID int, flag features.Flag) bool
+}
+
+// SetFeatureFlags подключает feature flags пользователей: features.ProportionalSizing включает
+// SizingProportional пользователю при глобальном SizingFixed
+func (e *Engine) SetFeatureFlags(flags FeatureFlags) {
+	e.features = flags
+}
+
+// SizingMode возвращает глобальный режим объёма входов slave аккаунтов
+func (e *Engine) SizingMode() SizingMode {
+	return e.sizing
+}
+
+// UserSizingMode возвращает режим объёма входов пользователя: глобальный или SizingProportional
+// по флагу features.ProportionalSizing
+func (e *Engine) UserSizingMode(userID int) SizingMode {
+	if e.sizing == SizingFixed && e.features != nil && e.features.IsEnabled(userID, features.ProportionalSizing) {
+		return SizingProportional
+	}
+
+	return e.sizing
+}
+
+// masterEquity возвращает equity мастера для пропорционального объёма: последнее событие счета
+// WebSocket, если оно свежее mexc.EquityCacheTTL, иначе баланс из REST (кэш клиента).
+// 0 - режим SizingFixed или equity неизвестен
+func (e *Engine) masterEquity(ctx context.Context, userID int) float64 {
+	if e.UserSizingMode(userID) != SizingProportional {
+		return 0
+	}
+
+	master, err := e.masterAccount(ctx, userID)
+	if err != nil {
+		return 0
+	}
+
+	if usage, ok := e.MasterMargin(master.ID); ok && usage.Equity > 0 && e.clock.Now().Sub(usage.UpdatedAt) < mexc.EquityCacheTTL {
+		return usage.Equity
+	}
+
+	client, err := e.newClient(master)
+	if err != nil {
+		return 0
+	}
+
+	equity, err := client.GetEquityCached(ctx)
+	if err != nil {
+		e.logger.Warn("Failed to get master equity for proportional sizing",
+			slog.String("master", master.Name),
+			slog.Any("error", err))
+		return 0
+	}
+
+	return equity
+}
+
+// scaleVolume пересчитывает объём мастера для slave аккаунта в режиме SizingProportional пользователя
+// (округление до шага контракта - в openOrder). masterEquity - результат masterEquity
+func (e *Engine) scaleVolume(ctx context.Context, client *mexc.Client, userID int, acc models.Account, volume, masterEquity float64) (float64, error) {
+	if e.UserSizingMode(userID) != SizingProportional {
+		return volume, nil
+	}
+	if masterEquity <= 0 {
+		return 0, fmt.Errorf("master: %w", ErrEquityUnknown)
+	}
+
+	slaveEquity, err := client.GetEquityCached(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrEquityUnknown, err)
+	}
+	if slaveEquity <= 0 {
+		return 0, fmt.Errorf("slave: %w", ErrEquityUnknown)
+	}
+
+	scaled := volume * slaveEquity / masterEquity
+
+	e.logger.Debug("Proportional volume",
+		slog.String("slave", acc.Name),
+		slog.Float64("master_volume", volume),
+		slog.Float64("master_equity", masterEquity),
+		slog.Float64("slave_equity", slaveEquity),
+		slog.Float64("volume", scaled))
+
+	return scaled, nil
+}
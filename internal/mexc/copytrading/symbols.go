@@ -0,0 +1,113 @@
+package copytrading
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"tg_mexc/internal/models"
+)
+
+// Режимы фильтра символов (models.SymbolFilter.Mode)
+const (
+	SymbolFilterOff       = "off"
+	SymbolFilterWhitelist = "whitelist" // Копируются только входы по символам списка
+	SymbolFilterBlacklist = "blacklist" // Входы по символам списка не копируются
+)
+
+// ErrInvalidSymbolFilter - неизвестный режим или пустой список символов
+var ErrInvalidSymbolFilter = errors.New("invalid symbol filter")
+
+// SymbolFilterStorage - фильтры символов пользователей
+type SymbolFilterStorage interface {
+	GetSymbolFilter(userID int) (models.SymbolFilter, error)
+}
+
+// SetSymbolFilterStorage включает фильтр символов пользователей: входы мастера по исключенным символам
+// не копируются. Выходы и защитные ордера копируются всегда - позиции, открытые до фильтра, закрываются
+func (e *Engine) SetSymbolFilterStorage(storage SymbolFilterStorage) {
+	e.symbolFilters = storage
+}
+
+// SymbolFilter возвращает фильтр символов пользователя: без сохраненного фильтра - SymbolFilterOff
+func SymbolFilter(storage SymbolFilterStorage, userID int) (models.SymbolFilter, error) {
+	filter, err := storage.GetSymbolFilter(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.SymbolFilter{UserID: userID, Mode: SymbolFilterOff, Symbols: []string{}}, nil
+	}
+
+	return filter, err
+}
+
+// NormalizeSymbolFilter приводит символы к виду MEXC (BTC_USDT, без повторов) и проверяет режим.
+// Для whitelist и blacklist нужен хотя бы один символ
+func NormalizeSymbolFilter(filter models.SymbolFilter) (models.SymbolFilter, error) {
+	symbols := make([]string, 0, len(filter.Symbols))
+	for _, symbol := range filter.Symbols {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol != "" && !slices.Contains(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	filter.Symbols = symbols
+
+	switch filter.Mode {
+	case "", SymbolFilterOff:
+		filter.Mode = SymbolFilterOff
+		filter.Symbols = []string{}
+	case SymbolFilterWhitelist, SymbolFilterBlacklist:
+		if len(symbols) == 0 {
+			return filter, fmt.Errorf("%w: %s needs at least one symbol", ErrInvalidSymbolFilter, filter.Mode)
+		}
+	default:
+		return filter, fmt.Errorf("%w: unknown mode %q", ErrInvalidSymbolFilter, filter.Mode)
+	}
+
+	return filter, nil
+}
+
+// symbolAllowed сообщает, копируется ли вход по symbol фильтром
+func symbolAllowed(filter models.SymbolFilter, symbol string) bool {
+	switch filter.Mode {
+	case SymbolFilterWhitelist:
+		return slices.Contains(filter.Symbols, symbol)
+	case SymbolFilterBlacklist:
+		return !slices.Contains(filter.Symbols, symbol)
+	}
+	return true
+}
+
+// allowedEntries возвращает признак копирования каждого входа reqs по фильтру символов пользователя.
+// Ошибка чтения фильтра не блокирует копирование
+func (e *Engine) allowedEntries(userID int, reqs []OpenPositionRequest) []bool {
+	allowed := make([]bool, len(reqs))
+	for i := range allowed {
+		allowed[i] = true
+	}
+	if e.symbolFilters == nil {
+		return allowed
+	}
+
+	filter, err := SymbolFilter(e.symbolFilters, userID)
+	if err != nil {
+		e.logger.Warn("Failed to get symbol filter, copying without it",
+			slog.Int("user_id", userID),
+			slog.Any("error", err))
+		return allowed
+	}
+
+	for i, req := range reqs {
+		allowed[i] = symbolAllowed(filter, req.Symbol)
+		if !allowed[i] {
+			e.logger.Info("Entry skipped by symbol filter",
+				slog.Int("user_id", userID),
+				slog.String("symbol", req.Symbol),
+				slog.String("mode", filter.Mode))
+		}
+	}
+
+	return allowed
+}
@@ -0,0 +1,58 @@
+package copytrading
+
+import "time"
+
+// Operation - тип операции copy trading (определяет таймаут)
+type Operation string
+
+const (
+	OpOpen      Operation = "open"       // Открытие позиции
+	OpClose     Operation = "close"      // Закрытие позиции
+	OpStopOrder Operation = "stop_order" // SL/TP: выставление, изменение, отмена
+	OpLeverage  Operation = "leverage"   // Изменение leverage
+)
+
+// Timeouts - таймауты операций по типу и их бюджет на fan-out по slave аккаунтам.
+// Бюджет операции = базовый таймаут + PerSlave за каждый slave аккаунт: на большом числе slave
+// (особенно через прокси) запросы конкурируют за соединения и отвечают дольше
+type Timeouts struct {
+	Open      time.Duration
+	Close     time.Duration
+	StopOrder time.Duration
+	Leverage  time.Duration
+	PerSlave  time.Duration
+	Command   time.Duration // Команда бота без fan-out через engine (баланс, позиции, /open)
+}
+
+// DefaultTimeouts возвращает таймауты по умолчанию
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Open:      10 * time.Second,
+		Close:     10 * time.Second,
+		StopOrder: 10 * time.Second,
+		Leverage:  10 * time.Second,
+		PerSlave:  500 * time.Millisecond,
+		Command:   15 * time.Second,
+	}
+}
+
+// For возвращает базовый таймаут операции
+func (t Timeouts) For(op Operation) time.Duration {
+	switch op {
+	case OpOpen:
+		return t.Open
+	case OpClose:
+		return t.Close
+	case OpStopOrder:
+		return t.StopOrder
+	case OpLeverage:
+		return t.Leverage
+	}
+
+	return t.Command
+}
+
+// Budget возвращает общий бюджет операции на fan-out по slaves аккаунтам
+func (t Timeouts) Budget(op Operation, slaves int) time.Duration {
+	return t.For(op) + time.Duration(slaves)*t.PerSlave
+}
@@ -0,0 +1,382 @@
+package copytrading
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+)
+
+// PlaceTrailingStop устанавливает trailing stop на всех slave аккаунтах. Объём - та же доля позиции slave,
+// что у мастера (как при частичном закрытии): объём мастера не учитывает множитель, пропорциональный объём
+// и ограничения slave
+func (e *Engine) PlaceTrailingStop(ctx context.Context, userID int, req PlaceTrailingStopRequest) (ExecutionResult, error) {
+	ratio, err := e.trailingRatio(ctx, userID, req.Symbol, req.Side, req.Volume)
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+
+	result, err := e.execute(ctx, OpStopOrder, userID, func(ctx context.Context, acc models.Account) AccountResult {
+		return e.processPlaceTrailingStop(ctx, acc, req, ratio)
+	})
+	if err != nil && !IsPartial(err) {
+		return ExecutionResult{}, err
+	}
+
+	record := models.Trade{
+		UserID:   userID,
+		Symbol:   req.Symbol,
+		Side:     req.Side,
+		Volume:   int(req.Volume),
+		Leverage: req.Leverage,
+		Action:   "place_trailing_stop",
+	}
+	if err := e.saveTrade(ctx, record, result); err != nil {
+		return ExecutionResult{}, fmt.Errorf("failed to save trade: %w", err)
+	}
+
+	return result, err
+}
+
+// processPlaceTrailingStop обрабатывает установку trailing stop для одного аккаунта (ratio - доля позиции slave)
+func (e *Engine) processPlaceTrailingStop(ctx context.Context, acc models.Account, req PlaceTrailingStopRequest, ratio float64) AccountResult {
+	result := AccountResult{
+		AccountID:   acc.ID,
+		AccountName: acc.Name,
+		Success:     false,
+	}
+
+	client, err := e.newClient(acc)
+	if err != nil {
+		e.logger.Error("Failed to create client",
+			slog.String("slave", acc.Name),
+			slog.Any("error", err))
+		result.setError(err)
+		return result
+	}
+
+	vol, err := slaveCloseVolume(ctx, client, req.Symbol, req.Side, ratio)
+	if err != nil {
+		e.logger.Error("Failed to get slave positions",
+			slog.String("slave", acc.Name),
+			slog.Any("error", err))
+		result.setError(err)
+		return result
+	}
+	if vol == 0 {
+		e.logger.Debug("No slave position for trailing stop",
+			slog.String("slave", acc.Name),
+			slog.String("symbol", req.Symbol))
+		result.Success = true // Нечего защищать - не ошибка
+		return result
+	}
+
+	if e.dryRun {
+		e.logger.Info("DRY_RUN - Would place trailing stop",
+			slog.String("slave", acc.Name),
+			slog.String("symbol", req.Symbol),
+			slog.Int("side", req.Side),
+			slog.Int("vol", vol),
+			slog.Float64("activePrice", req.ActivePrice),
+			slog.Float64("backValue", req.BackValue))
+		result.Success = true
+		return result
+	}
+
+	trackOrderID, err := client.PlaceTrailingStop(ctx, models.TrailingStopRequest{
+		Symbol:      req.Symbol,
+		Side:        req.Side,
+		Vol:         vol,
+		Leverage:    req.Leverage,
+		OpenType:    mexc.MarginOpenType(req.OpenType),
+		Trend:       req.Trend,
+		ActivePrice: req.ActivePrice,
+		BackType:    req.BackType,
+		BackValue:   req.BackValue,
+	})
+	if err != nil {
+		e.logger.Error("Failed to place trailing stop",
+			slog.String("slave", acc.Name),
+			slog.String("symbol", req.Symbol),
+			slog.Any("error", err))
+		result.setError(err)
+		return result
+	}
+
+	result.AckedAt = e.clock.Now()
+	result.Success = true
+	result.OrderID = fmt.Sprint(trackOrderID)
+
+	return result
+}
+
+// ChangeTrailingStop изменяет trailing stop на всех slave аккаунтах
+func (e *Engine) ChangeTrailingStop(ctx context.Context, userID int, req ChangeTrailingStopRequest) (ExecutionResult, error) {
+	// Символ и сторона trailing stop мастера - из запроса или из открытых ордеров мастера
+	if req.Symbol == "" || req.Side == 0 {
+		masterAccount, err := e.masterAccount(ctx, userID)
+		if err != nil {
+			return ExecutionResult{}, fmt.Errorf("failed to get master account: %w", err)
+		}
+
+		masterClient, err := e.newClient(masterAccount)
+		if err != nil {
+			return ExecutionResult{}, fmt.Errorf("failed to create master client: %w", err)
+		}
+
+		lookupCtx, cancel := context.WithTimeout(ctx, e.timeouts.StopOrder)
+		masterOrders, err := masterClient.GetOpenTrailingStops(lookupCtx, req.Symbol)
+		cancel()
+		if err != nil {
+			return ExecutionResult{}, fmt.Errorf("failed to get master trailing stops: %w", err)
+		}
+
+		for _, order := range masterOrders {
+			if order.ID == req.TrackOrderID {
+				req.Symbol, req.Side = order.Symbol, order.Side
+			}
+		}
+	}
+
+	if req.Symbol == "" {
+		return ExecutionResult{}, fmt.Errorf("trailing stop %d not found", req.TrackOrderID)
+	}
+
+	// Новый объём мастера - доля его позиции, у slave - та же доля своей позиции (0 - объём не меняется)
+	ratio := 0.0
+	if req.Volume > 0 {
+		var err error
+		if ratio, err = e.trailingRatio(ctx, userID, req.Symbol, req.Side, req.Volume); err != nil {
+			return ExecutionResult{}, err
+		}
+	}
+
+	result, err := e.execute(ctx, OpStopOrder, userID, func(ctx context.Context, acc models.Account) AccountResult {
+		return e.processChangeTrailingStop(ctx, acc, req, ratio)
+	})
+	if err != nil && !IsPartial(err) {
+		return ExecutionResult{}, err
+	}
+
+	record := models.Trade{
+		UserID: userID,
+		Symbol: req.Symbol,
+		Side:   req.Side,
+		Volume: int(req.Volume),
+		Action: "change_trailing_stop",
+	}
+	if err := e.saveTrade(ctx, record, result); err != nil {
+		return ExecutionResult{}, fmt.Errorf("failed to save trade: %w", err)
+	}
+
+	return result, err
+}
+
+// processChangeTrailingStop обрабатывает изменение trailing stop для одного аккаунта
+// (ratio - доля позиции slave, 0 - объём trailing stop slave не меняется)
+func (e *Engine) processChangeTrailingStop(ctx context.Context, acc models.Account, req ChangeTrailingStopRequest, ratio float64) AccountResult {
+	result := AccountResult{
+		AccountID:   acc.ID,
+		AccountName: acc.Name,
+		Success:     false,
+	}
+
+	client, err := e.newClient(acc)
+	if err != nil {
+		e.logger.Error("Failed to create client",
+			slog.String("slave", acc.Name),
+			slog.Any("error", err))
+		result.setError(err)
+		return result
+	}
+
+	slaveOrders, err := slaveTrailingStops(ctx, client, req.Symbol, req.Side)
+	if err != nil {
+		e.logger.Error("Failed to get slave trailing stops",
+			slog.String("slave", acc.Name),
+			slog.Any("error", err))
+		result.setError(err)
+		return result
+	}
+
+	if len(slaveOrders) == 0 {
+		e.logger.Debug("No trailing stops found for slave",
+			slog.String("slave", acc.Name),
+			slog.String("symbol", req.Symbol))
+		result.Success = true // Не считаем ошибкой
+		return result
+	}
+
+	slaveOrder := slaveOrders[0]
+
+	if e.dryRun {
+		e.logger.Info("DRY_RUN - Would update trailing stop",
+			slog.String("slave", acc.Name),
+			slog.String("symbol", req.Symbol),
+			slog.Float64("backValue", req.BackValue))
+		result.Success = true
+		return result
+	}
+
+	vol := slaveOrder.Vol
+	if ratio > 0 {
+		vol, err = slaveCloseVolume(ctx, client, req.Symbol, req.Side, ratio)
+		if err != nil {
+			e.logger.Error("Failed to get slave positions",
+				slog.String("slave", acc.Name),
+				slog.Any("error", err))
+			result.setError(err)
+			return result
+		}
+		if vol == 0 {
+			vol = slaveOrder.Vol
+		}
+	}
+
+	err = client.ChangeTrailingStop(ctx, models.ChangeTrailingStopRequest{
+		TrackOrderID: slaveOrder.ID,
+		Vol:          vol,
+		Trend:        req.Trend,
+		ActivePrice:  req.ActivePrice,
+		BackType:     req.BackType,
+		BackValue:    req.BackValue,
+	})
+	if err != nil {
+		e.logger.Error("Failed to update trailing stop",
+			slog.String("slave", acc.Name),
+			slog.String("symbol", req.Symbol),
+			slog.Any("error", err))
+		result.setError(err)
+		return result
+	}
+
+	result.Success = true
+
+	return result
+}
+
+// CancelTrailingStop отменяет trailing stop на всех slave аккаунтах
+func (e *Engine) CancelTrailingStop(ctx context.Context, userID int, req CancelTrailingStopRequest) (ExecutionResult, error) {
+	result, err := e.execute(ctx, OpStopOrder, userID, func(ctx context.Context, acc models.Account) AccountResult {
+		return e.processCancelTrailingStop(ctx, acc, req)
+	})
+	if err != nil && !IsPartial(err) {
+		return ExecutionResult{}, err
+	}
+
+	record := models.Trade{
+		UserID: userID,
+		Symbol: req.Symbol,
+		Side:   req.Side,
+		Action: "cancel_trailing_stop",
+	}
+	if err := e.saveTrade(ctx, record, result); err != nil {
+		return ExecutionResult{}, fmt.Errorf("failed to save trade: %w", err)
+	}
+
+	return result, err
+}
+
+// processCancelTrailingStop обрабатывает отмену trailing stop для одного аккаунта
+func (e *Engine) processCancelTrailingStop(ctx context.Context, acc models.Account, req CancelTrailingStopRequest) AccountResult {
+	result := AccountResult{
+		AccountID:   acc.ID,
+		AccountName: acc.Name,
+		Success:     false,
+	}
+
+	client, err := e.newClient(acc)
+	if err != nil {
+		e.logger.Error("Failed to create client",
+			slog.String("slave", acc.Name),
+			slog.Any("error", err))
+		result.setError(err)
+		return result
+	}
+
+	slaveOrders, err := slaveTrailingStops(ctx, client, req.Symbol, req.Side)
+	if err != nil {
+		e.logger.Error("Failed to get slave trailing stops",
+			slog.String("slave", acc.Name),
+			slog.Any("error", err))
+		result.setError(err)
+		return result
+	}
+
+	if e.dryRun {
+		e.logger.Info("DRY_RUN - Would cancel trailing stops",
+			slog.String("slave", acc.Name),
+			slog.String("symbol", req.Symbol),
+			slog.Int("count", len(slaveOrders)))
+		result.Success = true
+		return result
+	}
+
+	for _, order := range slaveOrders {
+		if err := client.CancelTrailingStop(ctx, order.Symbol, order.ID); err != nil {
+			e.logger.Error("Failed to cancel trailing stop",
+				slog.String("slave", acc.Name),
+				slog.String("symbol", req.Symbol),
+				slog.Any("error", err))
+			result.setError(err)
+			return result
+		}
+	}
+
+	result.Success = true
+
+	return result
+}
+
+// slaveTrailingStops возвращает открытые trailing stop slave по символу и стороне (side 0 - любая)
+func slaveTrailingStops(ctx context.Context, client *mexc.Client, symbol string, side int) ([]models.TrailingStop, error) {
+	orders, err := client.GetOpenTrailingStops(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := orders[:0]
+	for _, order := range orders {
+		if order.Symbol == symbol && (side == 0 || order.Side == side) {
+			matched = append(matched, order)
+		}
+	}
+
+	return matched, nil
+}
+
+// trailingRatio возвращает долю позиции мастера, которую защищает trailing stop объёмом volume
+// (0 - вся позиция: ratio 1)
+func (e *Engine) trailingRatio(ctx context.Context, userID int, symbol string, side int, volume float64) (float64, error) {
+	if volume <= 0 {
+		return 1, nil
+	}
+
+	return e.masterCloseRatio(ctx, userID, ClosePositionRequest{Symbol: symbol, Side: side, Volume: volume})
+}
+
+// slaveCloseVolume возвращает долю ratio позиции slave, закрываемой ордером side (2=close short, 4=close long),
+// не меньше одного контракта. 0 - позиции нет
+func slaveCloseVolume(ctx context.Context, client *mexc.Client, symbol string, side int, ratio float64) (int, error) {
+	positions, err := client.GetPositions(ctx, symbol)
+	if err != nil {
+		return 0, err
+	}
+
+	positionType := ClosePositionType(side)
+
+	var holdVol float64
+	for _, pos := range positions {
+		if pos.Symbol == symbol && (positionType == 0 || pos.PositionType == positionType) {
+			holdVol += pos.HoldVol
+		}
+	}
+	if holdVol <= 0 {
+		return 0, nil
+	}
+
+	return min(max(int(math.Round(holdVol*ratio)), 1), int(holdVol)), nil
+}
@@ -1,12 +1,24 @@
 package copytrading
 
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"tg_mexc/internal/mexc"
+)
+
 // OpenPositionRequest - запрос на открытие позиции
 type OpenPositionRequest struct {
 	Symbol        string
 	Side          int // 1=open long, 3=open short
 	Volume        float64
 	Leverage      int
+	OpenType      int     // 1=isolated, 2=cross (0 - isolated)
 	StopLossPrice float64 // optional, 0 если не нужен
+	LimitPrice    float64 // Цена limit ордера мастера, 0 - market ордер
+	MasterPrice   float64 // Цена исполнения у мастера (для измерения slippage), 0 если неизвестна
+	MarketPrice   float64 // Рыночная цена в момент события (публичный ticker WebSocket), 0 если неизвестна
 }
 
 // ClosePositionRequest - запрос на закрытие позиции
@@ -15,6 +27,9 @@ type ClosePositionRequest struct {
 	Side       int     // 2=close short, 4=close long
 	Volume     float64 // объём для закрытия (0 = закрыть всё)
 	PositionID int64   // ID позиции (опционально)
+	// MasterExecuted - закрытие уже исполнено на мастере (WebSocket): объём позиции мастера
+	// до закрытия = остаток + Volume. Иначе (mirror) - текущий объём позиции мастера
+	MasterExecuted bool
 }
 
 // PlacePlanOrderRequest - запрос на установку SL/TP
@@ -50,6 +65,46 @@ type CancelStopOrderRequest struct {
 	Symbol string
 }
 
+// PlaceTrailingStopRequest - запрос на установку trailing stop
+type PlaceTrailingStopRequest struct {
+	Symbol      string
+	Side        int // 2=close short, 4=close long
+	Volume      float64
+	Leverage    int
+	OpenType    int     // 1=isolated, 2=cross (0 - isolated)
+	Trend       int     // 1=last, 2=fair, 3=index price
+	ActivePrice float64 // 0 - отслеживание сразу
+	BackType    int     // 1=callback rate, 2=отступ в цене
+	BackValue   float64
+}
+
+// ChangeTrailingStopRequest - запрос на изменение trailing stop мастера
+// (trailing stop slave ищется по символу и стороне)
+type ChangeTrailingStopRequest struct {
+	TrackOrderID int64  // ID trailing stop мастера
+	Symbol       string // Опционально: если передан вместе с Side, не нужен API lookup
+	Side         int
+	Volume       float64
+	Trend        int
+	ActivePrice  float64
+	BackType     int
+	BackValue    float64
+}
+
+// CancelTrailingStopRequest - запрос на отмену trailing stop
+type CancelTrailingStopRequest struct {
+	Symbol string
+	Side   int // 0 - все trailing stop символа
+}
+
+// CancelOrderRequest - запрос на отмену открытых (не stop) ордеров slave, повторяющих ордер мастера
+type CancelOrderRequest struct {
+	MasterOrderID string  // ID ордера мастера: если Symbol пуст, символ, сторона и цена берутся из него
+	Symbol        string  // Пустой вместе с MasterOrderID - все символы
+	Side          int     // 0 - все ордера символа
+	Price         float64 // 0 - любая цена
+}
+
 // AccountResult - результат выполнения операции на одном аккаунте
 type AccountResult struct {
 	AccountID   int
@@ -58,16 +113,47 @@ type AccountResult struct {
 	Error       string
 	OrderID     string
 	LatencyMs   int64
+
+	DispatchedAt time.Time // Engine начал обработку аккаунта
+	AckedAt      time.Time // Биржа ответила на основной запрос
+	FillPrice    float64   // Средняя цена исполнения ордера slave (0 если неизвестна)
+	Skipped      bool      // Аккаунт не запускался: ctx отменен до старта или аккаунт приостановлен
+	Capped       bool      // Объём входа уменьшен ограничениями slave аккаунта (models.CopyCaps)
+	Retryable    bool      // Ошибка временная (rate limit, таймаут до отправки ордера): копию можно повторить
+
+	ErrorKind mexc.ErrorKind // Класс ошибки MEXC API, если она известна
+}
+
+// setError записывает ошибку аккаунта вместе с ее классом
+func (r *AccountResult) setError(err error) {
+	r.Error = err.Error()
+	r.ErrorKind = mexc.ErrorKindOf(err)
+	r.Retryable = mexc.IsTransient(err)
 }
 
 // ExecutionResult - результат выполнения операции на всех slave аккаунтах
 type ExecutionResult struct {
 	TotalCount   int
 	SuccessCount int
-	FailedCount  int
+	FailedCount  int // Включая SkippedCount
+	SkippedCount int
 	Results      []AccountResult
 }
 
+// add добавляет результат аккаунта и обновляет счетчики
+func (r *ExecutionResult) add(accResult AccountResult) {
+	switch {
+	case accResult.Success:
+		r.SuccessCount++
+	case accResult.Skipped:
+		r.SkippedCount++
+		r.FailedCount++
+	default:
+		r.FailedCount++
+	}
+	r.Results = append(r.Results, accResult)
+}
+
 // IsFullSuccess возвращает true если все операции успешны
 func (r *ExecutionResult) IsFullSuccess() bool {
 	return r.FailedCount == 0
@@ -82,3 +168,26 @@ func (r *ExecutionResult) IsPartialSuccess() bool {
 func (r *ExecutionResult) IsFullFailure() bool {
 	return r.SuccessCount == 0 && r.TotalCount > 0
 }
+
+// PartialResultError - fan-out прерван отменой ctx: Result содержит аккаунты, успевшие ответить,
+// остальные отмечены неуспешными (Skipped - если не запускались). errors.Is(err, context.Canceled) и
+// errors.Is(err, context.DeadlineExceeded) работают через Cause
+type PartialResultError struct {
+	Result ExecutionResult
+	Cause  error
+}
+
+func (e *PartialResultError) Error() string {
+	return fmt.Sprintf("partial execution: %d/%d accounts succeeded, %d skipped: %v",
+		e.Result.SuccessCount, e.Result.TotalCount, e.Result.SkippedCount, e.Cause)
+}
+
+func (e *PartialResultError) Unwrap() error {
+	return e.Cause
+}
+
+// IsPartial возвращает true если err - PartialResultError (результат частичный, но валидный)
+func IsPartial(err error) bool {
+	var partial *PartialResultError
+	return errors.As(err, &partial)
+}
@@ -0,0 +1,103 @@
+package wscopytrading
+
+import (
+	"encoding/json"
+	"time"
+
+	"tg_mexc/internal/mexc/websocket"
+	"tg_mexc/internal/models"
+)
+
+// frameKinds - каналы сырых сообщений, которые становятся событиями master аккаунта
+var frameKinds = map[string]string{
+	"push.personal.order":          EventOrder,
+	"push.personal.stop.order":     EventStopOrder,
+	"push.personal.stop.planorder": EventStopPlanOrder,
+	"push.personal.position":       EventPosition,
+}
+
+// EventsFromFrames переводит записанные сырые сообщения master аккаунта (websocket.SetFrameRecorder)
+// в события для Replay. Order и stop order одного ордера, полученные в пределах matchWindow,
+// склеиваются так же, как их матчит WebSocket клиент; остальные каналы (deal, asset) пропускаются
+func EventsFromFrames(frames []models.WSFrame, matchWindow time.Duration) []models.MasterEvent {
+	stops := make(map[int]websocket.StopOrderEvent) // индекс кадра -> разобранный stop order
+	for i, frame := range frames {
+		if frame.Channel != "push.personal.stop.order" {
+			continue
+		}
+
+		var stop websocket.StopOrderEvent
+		if err := json.Unmarshal([]byte(frame.Payload), &stop); err == nil && stop.OrderID != "" {
+			stops[i] = stop
+		}
+	}
+
+	// Сначала матчинг: stop order, пришедший раньше своего order, не должен попасть в события отдельно
+	matched := make(map[int]bool)         // stop order, прикрепленные к order
+	orderPayloads := make(map[int]string) // индекс order кадра -> payload с привязанным SL
+	for i, frame := range frames {
+		if frame.Channel != "push.personal.order" {
+			continue
+		}
+		if payload, ok := matchFrameStop(frames, i, stops, matched, matchWindow); ok {
+			orderPayloads[i] = payload
+		}
+	}
+
+	events := make([]models.MasterEvent, 0, len(frames))
+	for i, frame := range frames {
+		kind, ok := frameKinds[frame.Channel]
+		if !ok || matched[i] {
+			continue
+		}
+
+		event := models.MasterEvent{
+			ID:         frame.ID,
+			AccountID:  frame.AccountID,
+			Kind:       kind,
+			Payload:    frame.Payload,
+			ReceivedAt: frame.ReceivedAt,
+		}
+		if payload, ok := orderPayloads[i]; ok {
+			event.Payload = payload
+		}
+
+		events = append(events, event)
+	}
+
+	return events
+}
+
+// matchFrameStop ищет для order кадра i еще не прикрепленный stop order того же ордера в окне
+// и возвращает payload order с привязанным SL (recordedOrder)
+func matchFrameStop(frames []models.WSFrame, i int, stops map[int]websocket.StopOrderEvent, matched map[int]bool, window time.Duration) (string, bool) {
+	if window <= 0 {
+		return "", false
+	}
+
+	var order websocket.OrderEvent
+	if err := json.Unmarshal([]byte(frames[i].Payload), &order); err != nil || order.OrderID == "" {
+		return "", false
+	}
+
+	for j, stop := range stops {
+		if matched[j] || stop.OrderID != order.OrderID {
+			continue
+		}
+
+		gap := frames[j].ReceivedAt.Sub(frames[i].ReceivedAt)
+		if gap > window || gap < -window {
+			continue
+		}
+
+		payload, err := json.Marshal(recordedOrder{OrderEvent: order, Stop: &stop})
+		if err != nil {
+			return "", false
+		}
+		matched[j] = true
+
+		return string(payload), true
+	}
+
+	return "", false
+}
@@ -0,0 +1,263 @@
+package wscopytrading
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	copytrading "tg_mexc/internal/mexc/copytrading"
+	"tg_mexc/internal/mexc/websocket"
+	"tg_mexc/internal/models"
+)
+
+// Manager держит WebSocket соединения нескольких master аккаунтов пользователя: у каждого мастера
+// свой Service и своя сессия мастера (Session.ForMaster), события мастера копируются только через нее
+type Manager struct {
+	logger  *slog.Logger
+	onClose func(userID int, master models.Account, err error)
+	onState func(userID int, master models.Account, state websocket.State, err error)
+
+	mu       sync.Mutex
+	services map[int]map[int]*Service // userID -> master accountID -> соединение мастера
+	starting map[int]map[int]*Service // userID -> master accountID -> соединение, которое еще подключается (Start)
+}
+
+// NewManager создает менеджер WebSocket соединений мастеров
+func NewManager(logger *slog.Logger) *Manager {
+	return &Manager{
+		logger:   logger,
+		services: make(map[int]map[int]*Service),
+		starting: make(map[int]map[int]*Service),
+	}
+}
+
+// SetCloseHandler задает обработчик окончательного разрыва соединения мастера: соединение уже
+// убрано из менеджера, остальные мастера пользователя продолжают работать. Вызывается до Start
+func (m *Manager) SetCloseHandler(handler func(userID int, master models.Account, err error)) {
+	m.onClose = handler
+}
+
+// SetStateHandler задает обработчик смены состояния соединения мастера. Вызывается до Start
+func (m *Manager) SetStateHandler(handler func(userID int, master models.Account, state websocket.State, err error)) {
+	m.onState = handler
+}
+
+// Start подключает master аккаунт к сессии пользователя session. matchWindow - окно матчинга
+// order и stop order (0 - значение процесса). Уже подключенный или подключающийся мастер не переподключается.
+// Подключение идет без m.mu: слот мастера резервируется до него и публикуется после; мастер,
+// отключенный во время подключения, останавливается
+func (m *Manager) Start(session *copytrading.Session, master models.Account, matchWindow time.Duration) error {
+	userID := session.UserID()
+
+	m.mu.Lock()
+	if m.services[userID][master.ID] != nil || m.starting[userID][master.ID] != nil {
+		m.mu.Unlock()
+		return nil
+	}
+
+	masterSession, err := session.ForMaster(master)
+	if err != nil {
+		m.mu.Unlock()
+		return fmt.Errorf("failed to bind master %s: %w", master.Name, err)
+	}
+
+	svc := NewService(masterSession, m.logger)
+	svc.SetStopMatchWindow(matchWindow)
+	svc.SetCloseHandler(func(master models.Account, err error) {
+		if !m.forget(userID, master.ID, svc) {
+			return
+		}
+		session.ReleaseMaster(master.ID)
+
+		if m.onClose != nil {
+			m.onClose(userID, master, err)
+		}
+	})
+	svc.SetStateHandler(func(master models.Account, state websocket.State, err error) {
+		if m.onState != nil && m.current(userID, master.ID, svc) {
+			m.onState(userID, master, state, err)
+		}
+	})
+
+	setSlot(m.starting, userID, master.ID, svc)
+	m.mu.Unlock()
+
+	err = svc.Start()
+
+	m.mu.Lock()
+	reserved := m.starting[userID][master.ID] == svc
+	if reserved {
+		removeSlot(m.starting, userID, master.ID)
+		if err == nil {
+			setSlot(m.services, userID, master.ID, svc)
+		}
+	}
+	masters := len(m.services[userID])
+	m.mu.Unlock()
+
+	if err != nil {
+		if reserved {
+			session.ReleaseMaster(master.ID)
+		}
+		return fmt.Errorf("failed to start master %s: %w", master.Name, err)
+	}
+
+	// Мастер отключен (Stop, StopUser, разрыв) пока подключался: слот уже освобожден
+	if !reserved {
+		m.logger.Info("Master WebSocket stopped while starting",
+			slog.Int("user_id", userID),
+			slog.String("master", master.Name))
+
+		return svc.Stop()
+	}
+
+	m.logger.Info("Master WebSocket started",
+		slog.Int("user_id", userID),
+		slog.String("master", master.Name),
+		slog.Int("masters", masters))
+
+	return nil
+}
+
+// Stop отключает master аккаунт пользователя; его сессия мастера отвязывается
+func (m *Manager) Stop(session *copytrading.Session, masterID int) error {
+	userID := session.UserID()
+
+	m.mu.Lock()
+	svc, ok := m.services[userID][masterID]
+	if ok {
+		removeSlot(m.services, userID, masterID)
+	}
+	// Подключающийся мастер остановит Start, увидев освобожденный слот
+	_, starting := m.starting[userID][masterID]
+	if starting {
+		removeSlot(m.starting, userID, masterID)
+	}
+	m.mu.Unlock()
+
+	if !ok && !starting {
+		return nil
+	}
+
+	session.ReleaseMaster(masterID)
+
+	if !ok {
+		return nil
+	}
+
+	return svc.Stop()
+}
+
+// StopUser отключает все master аккаунты пользователя (перед остановкой его сессии)
+func (m *Manager) StopUser(userID int) error {
+	m.mu.Lock()
+	services := m.services[userID]
+	delete(m.services, userID)
+	delete(m.starting, userID)
+	m.mu.Unlock()
+
+	var errs []error
+	for _, svc := range services {
+		if err := svc.Stop(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// StopAll отключает все соединения (остановка приложения)
+func (m *Manager) StopAll() {
+	m.mu.Lock()
+	all := m.services
+	m.services = make(map[int]map[int]*Service)
+	m.starting = make(map[int]map[int]*Service)
+	m.mu.Unlock()
+
+	for _, services := range all {
+		for _, svc := range services {
+			_ = svc.Stop()
+		}
+	}
+}
+
+// Masters возвращает ID подключенных master аккаунтов пользователя
+func (m *Manager) Masters(userID int) []int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]int, 0, len(m.services[userID]))
+	for id := range m.services[userID] {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// Health возвращает задержки соединений подключенных master аккаунтов пользователя (master accountID -> задержки)
+func (m *Manager) Health(userID int) map[int]websocket.Health {
+	m.mu.Lock()
+	services := make(map[int]*Service, len(m.services[userID]))
+	for id, svc := range m.services[userID] {
+		services[id] = svc
+	}
+	m.mu.Unlock()
+
+	health := make(map[int]websocket.Health, len(services))
+	for id, svc := range services {
+		health[id] = svc.Health()
+	}
+
+	return health
+}
+
+// IsActive сообщает, подключен ли хотя бы один master аккаунт пользователя
+func (m *Manager) IsActive(userID int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.services[userID]) > 0
+}
+
+// current сообщает, что svc - текущее (в том числе подключающееся) соединение мастера: не остановлено и не заменено
+func (m *Manager) current(userID, masterID int, svc *Service) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.services[userID][masterID] == svc || m.starting[userID][masterID] == svc
+}
+
+// forget убирает соединение мастера после разрыва, если оно текущее
+func (m *Manager) forget(userID, masterID int, svc *Service) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch svc {
+	case m.services[userID][masterID]:
+		removeSlot(m.services, userID, masterID)
+	case m.starting[userID][masterID]:
+		removeSlot(m.starting, userID, masterID)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// setSlot записывает соединение мастера в slots (m.mu захвачен)
+func setSlot(slots map[int]map[int]*Service, userID, masterID int, svc *Service) {
+	if slots[userID] == nil {
+		slots[userID] = make(map[int]*Service)
+	}
+	slots[userID][masterID] = svc
+}
+
+// removeSlot удаляет соединение мастера из slots (m.mu захвачен)
+func removeSlot(slots map[int]map[int]*Service, userID, masterID int) {
+	delete(slots[userID], masterID)
+	if len(slots[userID]) == 0 {
+		delete(slots, userID)
+	}
+}
@@ -0,0 +1,314 @@
+package wscopytrading
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tg_mexc/internal/mexc"
+	copytrading "tg_mexc/internal/mexc/copytrading"
+	"tg_mexc/internal/mexc/websocket"
+	"tg_mexc/internal/models"
+)
+
+// reconcileTimeout - таймаут запроса состояния master аккаунта при сверке
+const reconcileTimeout = 10 * time.Second
+
+// positionKey - позиция master аккаунта по символу и направлению (1 long, 2 short)
+type positionKey struct {
+	symbol       string
+	positionType int
+}
+
+type masterPosition struct {
+	vol      float64
+	leverage int
+	openType int
+	avgPrice float64
+}
+
+type masterStop struct {
+	orderID     string
+	stopLoss    float64
+	takeProfit  float64
+	lossTrend   int
+	profitTrend int
+}
+
+// masterState - последнее известное состояние master аккаунта: снимок REST при подключении,
+// дальше обновляется событиями WebSocket. SL/TP - по символу, как их отменяет CancelStopOrderBySymbol
+type masterState struct {
+	positions map[positionKey]masterPosition
+	stops     map[string]masterStop
+}
+
+func newMasterState(positions []models.Position, stops []models.StopOrder) *masterState {
+	state := &masterState{
+		positions: make(map[positionKey]masterPosition, len(positions)),
+		stops:     make(map[string]masterStop, len(stops)),
+	}
+
+	for _, pos := range positions {
+		if pos.HoldVol <= 0 {
+			continue
+		}
+		state.positions[positionKey{symbol: pos.Symbol, positionType: pos.PositionType}] = masterPosition{
+			vol:      pos.HoldVol,
+			leverage: pos.Leverage,
+			openType: pos.OpenType,
+			avgPrice: pos.HoldAvgPrice,
+		}
+	}
+
+	for _, stop := range stops {
+		if _, ok := state.stops[stop.Symbol]; ok {
+			continue
+		}
+		state.stops[stop.Symbol] = masterStop{
+			orderID:     stop.OrderId,
+			stopLoss:    stop.StopLossPrice,
+			takeProfit:  stop.TakeProfitPrice,
+			lossTrend:   stop.LossTrend,
+			profitTrend: stop.ProfitTrend,
+		}
+	}
+
+	return state
+}
+
+// orderPositionType - направление позиции, которую меняет ордер стороны side
+func orderPositionType(side int) int {
+	if side == mexc.SideOpenShort || side == mexc.SideCloseShort {
+		return 2
+	}
+	return 1
+}
+
+// trackOrder учитывает исполненный объём ордера master аккаунта в последнем известном состоянии
+// и отмечает закрытие позиции ордером
+func (s *Service) trackOrder(order websocket.OrderEvent) {
+	if order.DealVol <= 0 || (order.State != orderStateCompleted && order.State != orderStateCancelled) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := positionKey{symbol: order.Symbol, positionType: orderPositionType(order.Side)}
+
+	// Открытие начинает новую позицию: прошлые отметки закрытия к ней не относятся
+	if copytrading.IsOpenOrder(order.Side) {
+		delete(s.closedByOrder, key)
+		delete(s.closedByPosition, key)
+	}
+
+	if s.state == nil {
+		// Объём позиции неизвестен (снимок при старте не удался): исполненное закрытие считаем полным,
+		// иначе событие закрытия позиции следом повторит уже скопированное закрытие
+		if !copytrading.IsOpenOrder(order.Side) && order.State == orderStateCompleted {
+			s.closedByOrder[key] = time.Now()
+		}
+		return
+	}
+
+	pos := s.state.positions[key]
+
+	if copytrading.IsOpenOrder(order.Side) {
+		pos.vol += order.DealVol
+		pos.leverage = order.Leverage
+		pos.openType = order.OpenType
+		pos.avgPrice = masterPrice(order)
+		s.state.positions[key] = pos
+		return
+	}
+
+	pos.vol -= order.DealVol
+	if pos.vol <= 0 {
+		delete(s.state.positions, key)
+		s.closedByOrder[key] = time.Now()
+		return
+	}
+	s.state.positions[key] = pos
+}
+
+// trackPosition удаляет закрытую позицию master аккаунта из последнего известного состояния
+func (s *Service) trackPosition(pos websocket.PositionEvent) {
+	if pos.State != 3 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state != nil {
+		delete(s.state.positions, positionKey{symbol: pos.Symbol, positionType: pos.PositionType})
+	}
+}
+
+// trackStop учитывает выставленный SL/TP master аккаунта
+func (s *Service) trackStop(stop websocket.StopOrderEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state != nil {
+		s.state.stops[stop.Symbol] = masterStop{
+			orderID:     stop.OrderID,
+			stopLoss:    stop.StopLossPrice,
+			takeProfit:  stop.TakeProfitPrice,
+			lossTrend:   stop.LossTrend,
+			profitTrend: stop.ProfitTrend,
+		}
+	}
+}
+
+// trackStopPlan учитывает изменение или отмену SL/TP master аккаунта
+func (s *Service) trackStopPlan(stopPlan websocket.StopPlanOrderEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state == nil {
+		return
+	}
+
+	if stopPlan.IsFinished == 1 {
+		delete(s.state.stops, stopPlan.Symbol)
+		return
+	}
+
+	stop := s.state.stops[stopPlan.Symbol]
+	stop.orderID = stopPlan.OrderId
+	stop.stopLoss = stopPlan.StopLossPrice
+	stop.lossTrend = stopPlan.LossTrend
+	stop.profitTrend = stopPlan.ProfitTrend
+	s.state.stops[stopPlan.Symbol] = stop
+}
+
+// snapshot запрашивает состояние master аккаунта через REST
+func (s *Service) snapshot() (*masterState, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), reconcileTimeout)
+	defer cancel()
+
+	positions, stops, err := s.session.MasterState(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMasterState(positions, stops), nil
+}
+
+// reconcile сверяет состояние master аккаунта после переподключения WebSocket с последним известным
+// и прогоняет через обработчики синтетические события пропущенных изменений: открытие и закрытие
+// объёма позиций, выставление, изменение и отмену SL/TP. Без известного состояния (снимок при старте
+// не удался) только запоминает текущее
+func (s *Service) reconcile(masterID int) {
+	current, err := s.snapshot()
+	if err != nil {
+		s.logger.Error("Failed to reconcile master state after reconnect", slog.Any("error", err))
+		return
+	}
+
+	s.mu.Lock()
+	last := s.state
+	s.state = current
+	s.mu.Unlock()
+
+	if last == nil {
+		return
+	}
+
+	ctx := copytrading.WithEventTime(context.Background(), time.Now())
+	now := time.Now().UnixMilli()
+	missed := 0
+
+	// Позиции: сначала объём, затем SL/TP (стоп ставится на уже открытую позицию slave)
+	for key, pos := range current.positions {
+		if delta := pos.vol - last.positions[key].vol; delta > 0 {
+			missed++
+			s.replayMissedOrder(ctx, masterID, key, delta, pos, now)
+		}
+	}
+	for key, pos := range last.positions {
+		if delta := pos.vol - current.positions[key].vol; delta > 0 {
+			missed++
+			s.replayMissedOrder(ctx, masterID, key, -delta, pos, now)
+		}
+	}
+
+	for symbol, stop := range current.stops {
+		lastStop, ok := last.stops[symbol]
+		switch {
+		case !ok:
+			missed++
+			event := websocket.StopOrderEvent{
+				Symbol:          symbol,
+				OrderID:         stop.orderID,
+				LossTrend:       stop.lossTrend,
+				ProfitTrend:     stop.profitTrend,
+				StopLossPrice:   stop.stopLoss,
+				TakeProfitPrice: stop.takeProfit,
+			}
+			s.record(masterID, EventStopOrder, event)
+			s.handleStopOrderEvent(ctx, event)
+		case lastStop.stopLoss != stop.stopLoss || lastStop.takeProfit != stop.takeProfit:
+			missed++
+			event := websocket.StopPlanOrderEvent{
+				OrderId:       stop.orderID,
+				Symbol:        symbol,
+				LossTrend:     stop.lossTrend,
+				ProfitTrend:   stop.profitTrend,
+				StopLossPrice: stop.stopLoss,
+			}
+			s.record(masterID, EventStopPlanOrder, event)
+			s.handleStopPlanOrderEvent(ctx, event)
+		}
+	}
+	for symbol, stop := range last.stops {
+		if _, ok := current.stops[symbol]; ok {
+			continue
+		}
+		missed++
+		event := websocket.StopPlanOrderEvent{IsFinished: 1, OrderId: stop.orderID, Symbol: symbol}
+		s.record(masterID, EventStopPlanOrder, event)
+		s.handleStopPlanOrderEvent(ctx, event)
+	}
+
+	if missed > 0 {
+		s.logger.Warn("Replayed master changes missed while WebSocket was disconnected",
+			slog.Int("changes", missed))
+	}
+}
+
+// replayMissedOrder копирует пропущенное изменение объёма позиции master аккаунта
+// синтетическим исполненным market ордером: delta > 0 - открытие, delta < 0 - закрытие
+func (s *Service) replayMissedOrder(ctx context.Context, masterID int, key positionKey, delta float64, pos masterPosition, now int64) {
+	side := mexc.SideOpenLong
+	switch {
+	case key.positionType == 2 && delta > 0:
+		side = mexc.SideOpenShort
+	case key.positionType == 1 && delta < 0:
+		side = mexc.SideCloseLong
+	case key.positionType == 2 && delta < 0:
+		side = mexc.SideCloseShort
+	}
+
+	vol := max(delta, -delta)
+	order := websocket.OrderEvent{
+		OrderID:      fmt.Sprintf("reconcile-%s-%d-%d", key.symbol, side, now),
+		Symbol:       key.symbol,
+		Price:        pos.avgPrice,
+		Vol:          vol,
+		Leverage:     pos.leverage,
+		OpenType:     pos.openType,
+		Side:         side,
+		OrderType:    mexc.OrderTypeMarket,
+		State:        orderStateCompleted,
+		DealVol:      vol,
+		DealAvgPrice: pos.avgPrice,
+		CreateTime:   now,
+		UpdateTime:   now,
+	}
+
+	s.record(masterID, EventOrder, recordedOrder{OrderEvent: order})
+	s.handleOrderEvent(ctx, order)
+}
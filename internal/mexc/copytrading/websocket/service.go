@@ -2,30 +2,94 @@ package wscopytrading
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"strconv"
+	"sync"
 	"time"
 
+	"tg_mexc/internal/mexc"
 	copytrading "tg_mexc/internal/mexc/copytrading"
 	"tg_mexc/internal/mexc/websocket"
+	"tg_mexc/internal/models"
 )
 
+// Типы событий master аккаунта в журнале (models.MasterEvent.Kind)
+const (
+	EventOrder         = "order"
+	EventStopOrder     = "stop_order"
+	EventStopPlanOrder = "stop_plan_order"
+	EventPosition      = "position"
+)
+
+// Состояния ордера в событиях master аккаунта
+const (
+	orderStateUncompleted = 2
+	orderStateCompleted   = 3
+	orderStateCancelled   = 4
+)
+
+// marginCurrency - валюта фьючерсного счета, по которой отслеживается маржа мастера
+const marginCurrency = "USDT"
+
+// positionCloseWindow - окно, в котором закрытие позиции мастера событием ордера и событием позиции
+// считаются одним закрытием (события приходят в любом порядке)
+const positionCloseWindow = 30 * time.Second
+
+// recordedOrder - ордер в журнале вместе с привязанным SL (StopOrderEvent не сериализуется)
+type recordedOrder struct {
+	websocket.OrderEvent
+	Stop *websocket.StopOrderEvent `json:"stopOrder,omitempty"`
+}
+
 // Service - сервис copy trading для Web App
 type Service struct {
 	wsClient *websocket.Client
 	logger   *slog.Logger
 	session  *copytrading.Session
+	onClose  func(master models.Account, err error)
+	onState  func(master models.Account, state websocket.State, err error)
+
+	matchWindow time.Duration // Окно матчинга order и stop order, 0 - значение по умолчанию клиента
+
+	mu           sync.Mutex
+	copiedOrders map[string]struct{} // Выставленные ордера мастера (limit, закрытие), уже скопированные на slave
+	state        *masterState        // Последнее известное состояние мастера для сверки после переподключения
+
+	closedByOrder    map[positionKey]time.Time // Позиции мастера, полностью закрытые исполненным ордером
+	closedByPosition map[positionKey]time.Time // Позиции мастера, закрытие которых скопировано по событию позиции
 }
 
 // NewService создает новый сервис copy trading для Web App
 func NewService(session *copytrading.Session, logger *slog.Logger) *Service {
 	return &Service{
-		logger:  logger,
-		session: session,
+		logger:           logger,
+		session:          session,
+		copiedOrders:     make(map[string]struct{}),
+		closedByOrder:    make(map[positionKey]time.Time),
+		closedByPosition: make(map[positionKey]time.Time),
 	}
 }
 
+// SetCloseHandler задает обработчик разрыва соединения master аккаунта:
+// err - websocket.ErrAuthFailed, если биржа отклонила авторизацию. Вызывается до Start
+func (s *Service) SetCloseHandler(handler func(master models.Account, err error)) {
+	s.onClose = handler
+}
+
+// SetStateHandler задает обработчик смены состояния соединения master аккаунта
+// (разрыв и попытки переподключения, восстановление). Вызывается до Start
+func (s *Service) SetStateHandler(handler func(master models.Account, state websocket.State, err error)) {
+	s.onState = handler
+}
+
+// SetStopMatchWindow задает окно матчинга order и stop order событий мастера для этой сессии
+// (0 - значение процесса, websocket.SetStopOrderMatchWindow). Вызывается до Start
+func (s *Service) SetStopMatchWindow(window time.Duration) {
+	s.matchWindow = window
+}
+
 func (s *Service) Start() error {
 	masterAccount, err := s.session.GetMasterAccount()
 	if err != nil {
@@ -33,49 +97,105 @@ func (s *Service) Start() error {
 	}
 
 	wsClient := websocket.New(masterAccount, s.logger)
+	if s.matchWindow > 0 {
+		wsClient.SetStopOrderMatchWindow(s.matchWindow)
+	}
+
+	// Снимок до подключения: изменения между снимком и login не приходят событиями
+	// и будут досланы сверкой после первого переподключения
+	if state, err := s.snapshot(); err != nil {
+		s.logger.Warn("Failed to get master state, missed events will not be reconciled until reconnect",
+			slog.Any("error", err))
+	} else {
+		s.state = state
+		for key := range state.positions {
+			wsClient.SubscribeTicker(key.symbol)
+		}
+	}
 
-	timeoutCtx := func() (context.Context, context.CancelFunc) {
-		return context.WithTimeout(context.Background(), 5*time.Second)
+	// Момент получения события - для latency аналитики (order события уточняют его временем биржи).
+	// Таймауты задает engine по типу операции и числу slave аккаунтов
+	eventCtx := func() context.Context {
+		return copytrading.WithEventTime(context.Background(), time.Now())
 	}
 
 	wsClient.SetOrderHandler(func(event any) {
 		if order, ok := event.(websocket.OrderEvent); ok {
-			ctx, cancel := timeoutCtx()
-			defer cancel()
-			s.handleOrderEvent(ctx, order)
+			if s.redelivered(order) {
+				return
+			}
+			s.record(masterAccount.ID, EventOrder, recordedOrder{OrderEvent: order, Stop: order.StopOrderEvent})
+			s.trackOrder(order)
+			s.watchPrice(order)
+			s.handleOrderEvent(eventCtx(), order)
 		}
 	})
 
 	wsClient.SetStopOrderHandler(func(event any) {
 		if stop, ok := event.(websocket.StopOrderEvent); ok {
-			ctx, cancel := timeoutCtx()
-			defer cancel()
-			s.handleStopOrderEvent(ctx, stop)
+			s.record(masterAccount.ID, EventStopOrder, stop)
+			s.trackStop(stop)
+			s.handleStopOrderEvent(eventCtx(), stop)
 		}
 	})
 
 	wsClient.SetStopPlanOrderHandler(func(event any) {
 		if stopPlan, ok := event.(websocket.StopPlanOrderEvent); ok {
-			ctx, cancel := timeoutCtx()
-			defer cancel()
-			s.handleStopPlanOrderEvent(ctx, stopPlan)
+			s.record(masterAccount.ID, EventStopPlanOrder, stopPlan)
+			s.trackStopPlan(stopPlan)
+			s.handleStopPlanOrderEvent(eventCtx(), stopPlan)
+		}
+	})
+
+	wsClient.SetDealHandler(func(event any) {
+		if deal, ok := event.(websocket.DealEvent); ok {
+			s.handleDealEvent(masterAccount.ID, deal)
+		}
+	})
+
+	wsClient.SetAssetHandler(func(event any) {
+		if asset, ok := event.(websocket.AssetEvent); ok {
+			s.handleAssetEvent(masterAccount, asset)
+		}
+	})
+
+	wsClient.SetLiquidateRiskHandler(func(event any) {
+		if risk, ok := event.(websocket.LiquidateRiskEvent); ok {
+			s.session.ReportLiquidationRisk(copytrading.LiquidationRiskFromEvent(masterAccount, risk))
 		}
 	})
 
 	wsClient.SetPositionHandler(func(event any) {
 		if pos, ok := event.(websocket.PositionEvent); ok {
-			ctx, cancel := timeoutCtx()
-			defer cancel()
-			s.handlePositionEvent(ctx, pos)
+			s.record(masterAccount.ID, EventPosition, pos)
+			s.trackPosition(pos)
+			s.handlePositionEvent(eventCtx(), pos)
 		}
 	})
 
-	if err := wsClient.Connect(); err != nil {
-		return fmt.Errorf("websocket connection error: %w", err)
+	if s.onClose != nil {
+		wsClient.SetCloseHandler(func(err error) {
+			s.onClose(masterAccount, err)
+		})
 	}
 
+	wsClient.SetStateHandler(func(state websocket.State, err error) {
+		// После переподключения досылаем изменения мастера, пропущенные за время разрыва
+		if state == websocket.StateConnected {
+			s.reconcile(masterAccount.ID)
+		}
+
+		if s.onState != nil {
+			s.onState(masterAccount, state, err)
+		}
+	})
+
 	s.wsClient = wsClient
 
+	if err := wsClient.Connect(context.Background()); err != nil {
+		return fmt.Errorf("websocket connection error: %w", err)
+	}
+
 	return nil
 }
 
@@ -83,8 +203,129 @@ func (s *Service) Stop() error {
 	return s.wsClient.Disconnect()
 }
 
+// Health возвращает задержки WebSocket соединения master аккаунта (до Start - пустые)
+func (s *Service) Health() websocket.Health {
+	if s.wsClient == nil {
+		return websocket.Health{}
+	}
+	return s.wsClient.Health()
+}
+
+// record сохраняет событие master аккаунта в журнал для offline replay
+func (s *Service) record(accountID int, kind string, event any) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		s.logger.Warn("Failed to encode master event", slog.String("kind", kind), slog.Any("error", err))
+		return
+	}
+
+	err = s.session.RecordEvent(models.MasterEvent{
+		AccountID:  accountID,
+		Kind:       kind,
+		Payload:    string(payload),
+		ReceivedAt: time.Now(),
+	})
+	if err != nil {
+		s.logger.Warn("Failed to record master event", slog.String("kind", kind), slog.Any("error", err))
+	}
+}
+
+// Replay прогоняет записанное событие master аккаунта через те же обработчики, что и live WebSocket
+func (s *Service) Replay(ctx context.Context, event models.MasterEvent) error {
+	ctx = copytrading.WithEventTime(ctx, event.ReceivedAt)
+
+	switch event.Kind {
+	case EventOrder:
+		var order recordedOrder
+		if err := json.Unmarshal([]byte(event.Payload), &order); err != nil {
+			return fmt.Errorf("failed to decode %s event: %w", event.Kind, err)
+		}
+		order.StopOrderEvent = order.Stop
+		if s.redelivered(order.OrderEvent) {
+			return nil
+		}
+		s.handleOrderEvent(ctx, order.OrderEvent)
+	case EventStopOrder:
+		var stop websocket.StopOrderEvent
+		if err := json.Unmarshal([]byte(event.Payload), &stop); err != nil {
+			return fmt.Errorf("failed to decode %s event: %w", event.Kind, err)
+		}
+		s.handleStopOrderEvent(ctx, stop)
+	case EventStopPlanOrder:
+		var stopPlan websocket.StopPlanOrderEvent
+		if err := json.Unmarshal([]byte(event.Payload), &stopPlan); err != nil {
+			return fmt.Errorf("failed to decode %s event: %w", event.Kind, err)
+		}
+		s.handleStopPlanOrderEvent(ctx, stopPlan)
+	case EventPosition:
+		var pos websocket.PositionEvent
+		if err := json.Unmarshal([]byte(event.Payload), &pos); err != nil {
+			return fmt.Errorf("failed to decode %s event: %w", event.Kind, err)
+		}
+		s.handlePositionEvent(ctx, pos)
+	default:
+		return fmt.Errorf("unknown event kind: %s", event.Kind)
+	}
+
+	return nil
+}
+
+// orderEventKey - ключ идемпотентности события ордера мастера: ордер и время его изменения на бирже.
+// Пусто, если биржа не передала время (такое событие не проверяется)
+func orderEventKey(order websocket.OrderEvent) string {
+	if order.OrderID == "" || order.UpdateTime <= 0 {
+		return ""
+	}
+	return "order:" + order.OrderID + ":" + strconv.FormatInt(order.UpdateTime, 10)
+}
+
+// redelivered сообщает, что событие ордера мастера сессия уже обработала: MEXC повторяет события
+// после переподключения, и тот же ордер не должен копироваться дважды
+func (s *Service) redelivered(order websocket.OrderEvent) bool {
+	if s.session.MarkEvent(orderEventKey(order)) {
+		return false
+	}
+
+	s.logger.Info("Skipping redelivered master order event",
+		slog.String("order_id", order.OrderID),
+		slog.Int64("update_time", order.UpdateTime),
+		slog.Int("state", order.State))
+
+	return true
+}
+
 // handleOrderEvent обрабатывает событие ордера для Service
 func (s *Service) handleOrderEvent(ctx context.Context, order websocket.OrderEvent) {
+	if order.CreateTime > 0 {
+		ctx = copytrading.WithEventTime(ctx, time.UnixMilli(order.CreateTime))
+	}
+
+	// Отмена скопированного limit ордера мастера отменяет limit ордера slave по той же цене
+	if order.State == orderStateCancelled && copytrading.IsOpenOrder(order.Side) && isLimitOrder(order) {
+		if !s.forgetOrder(order.OrderID) {
+			return
+		}
+
+		if _, err := s.session.CancelOrders(ctx, fromWebSocketCancel(order)); err != nil {
+			s.logger.Error("Failed to cancel orders", slog.Any("error", err))
+		}
+		return
+	}
+
+	// Limit ордер мастера приходит событием на каждое изменение (выставлен, частично исполнен, исполнен):
+	// slave получают свой limit ордер по первому событию. Закрытие копируется тоже один раз на ордер -
+	// повтор частичного закрытия закрыл бы у slave лишний объём
+	if (isLimitOrder(order) || !copytrading.IsOpenOrder(order.Side)) && !s.firstOrderEvent(order) {
+		return
+	}
+
+	// Закрытие, уже скопированное по событию позиции (оно пришло раньше ордера), не повторяется
+	if !copytrading.IsOpenOrder(order.Side) && s.closeCopiedFromPosition(order) {
+		s.logger.Debug("Master close already copied from position event",
+			slog.String("symbol", order.Symbol), slog.String("order_id", order.OrderID))
+		return
+	}
+
 	openReq, closeReq := fromWebSocketOrder(order)
 	if openReq == nil && closeReq == nil {
 		s.logger.Debug("Unknown order side", slog.Int("side", order.Side))
@@ -93,6 +334,7 @@ func (s *Service) handleOrderEvent(ctx context.Context, order websocket.OrderEve
 
 	var err error
 	if copytrading.IsOpenOrder(order.Side) {
+		openReq.MarketPrice = s.marketPrice(order.Symbol)
 		_, err = s.session.OpenPosition(ctx, *openReq)
 	} else {
 		_, err = s.session.ClosePosition(ctx, *closeReq)
@@ -103,6 +345,97 @@ func (s *Service) handleOrderEvent(ctx context.Context, order websocket.OrderEve
 	}
 }
 
+// watchPrice подписывает соединение мастера на ticker символа, в котором мастер открывает позицию:
+// следующие события символа получают рыночную цену без REST запроса
+func (s *Service) watchPrice(order websocket.OrderEvent) {
+	if !copytrading.IsOpenOrder(order.Side) {
+		return
+	}
+
+	if err := s.wsClient.SubscribeTicker(order.Symbol); err != nil {
+		s.logger.Warn("Failed to subscribe to ticker",
+			slog.String("symbol", order.Symbol), slog.Any("error", err))
+	}
+}
+
+// marketPrice возвращает рыночную цену символа из ticker соединения мастера (0 - неизвестна или устарела).
+// Без соединения (replay) цена неизвестна
+func (s *Service) marketPrice(symbol string) float64 {
+	if s.wsClient == nil {
+		return 0
+	}
+
+	price, ok := s.wsClient.Price(symbol)
+	if !ok {
+		return 0
+	}
+
+	return price.Ref()
+}
+
+// firstOrderEvent отмечает событие ордера мастера и сообщает, нужно ли копировать ордер:
+// только первое событие выставленного или исполненного ордера
+func (s *Service) firstOrderEvent(order websocket.OrderEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, copied := s.copiedOrders[order.OrderID]
+
+	if order.State == orderStateUncompleted {
+		s.copiedOrders[order.OrderID] = struct{}{}
+	} else {
+		delete(s.copiedOrders, order.OrderID)
+	}
+
+	// Отмененный или отклоненный до копирования ордер не копируется
+	if order.State != orderStateUncompleted && order.State != orderStateCompleted {
+		return false
+	}
+
+	return !copied
+}
+
+// markClosedByPosition отмечает закрытие позиции мастера по событию позиции и сообщает, нужно ли его копировать:
+// false, если позицию недавно полностью закрыл исполненный ордер мастера
+func (s *Service) markClosedByPosition(key positionKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if closedRecently(s.closedByOrder, key) {
+		return false
+	}
+
+	s.closedByPosition[key] = time.Now()
+	return true
+}
+
+// closeCopiedFromPosition сообщает, скопировано ли закрытие позиции, к которой относится ордер мастера,
+// по событию позиции
+func (s *Service) closeCopiedFromPosition(order websocket.OrderEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return closedRecently(s.closedByPosition, positionKey{symbol: order.Symbol, positionType: orderPositionType(order.Side)})
+}
+
+// closedRecently сообщает, отмечено ли закрытие позиции key в closed не раньше positionCloseWindow назад.
+// Вызывается под s.mu
+func closedRecently(closed map[positionKey]time.Time, key positionKey) bool {
+	at, ok := closed[key]
+	return ok && time.Since(at) < positionCloseWindow
+}
+
+// forgetOrder удаляет ордер мастера из скопированных, возвращает true если он был скопирован
+func (s *Service) forgetOrder(orderID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, copied := s.copiedOrders[orderID]
+	delete(s.copiedOrders, orderID)
+
+	return copied
+}
+
 // handleStopOrderEvent обрабатывает событие stop order для Service
 func (s *Service) handleStopOrderEvent(ctx context.Context, stop websocket.StopOrderEvent) {
 	// Кэшируем stop order для оптимизации последующих lookup'ов
@@ -142,18 +475,55 @@ func (s *Service) handleStopPlanOrderEvent(ctx context.Context, stopPlan websock
 	}
 }
 
-// handlePositionEvent обрабатывает событие позиции для Service
+// handlePositionEvent обрабатывает событие позиции для Service: закрытие позиции мастера без ордера
+// (ликвидация, закрытие в web интерфейсе) закрывает позицию slave. Закрытие, уже скопированное
+// по событию ордера, пропускается
 func (s *Service) handlePositionEvent(ctx context.Context, pos websocket.PositionEvent) {
 	closeReq := fromWebSocketPosition(pos)
 	if closeReq == nil {
 		return
 	}
 
+	if !s.markClosedByPosition(positionKey{symbol: pos.Symbol, positionType: pos.PositionType}) {
+		s.logger.Debug("Master position close already copied from order event",
+			slog.String("symbol", pos.Symbol), slog.Int("position_type", pos.PositionType))
+		return
+	}
+
 	if _, err := s.session.ClosePosition(ctx, *closeReq); err != nil {
 		s.logger.Error("Failed to execute close position", slog.Any("error", err))
 	}
 }
 
+// handleDealEvent учитывает fill master аккаунта (PnL, комиссии)
+func (s *Service) handleDealEvent(accountID int, deal websocket.DealEvent) {
+	if err := s.session.RecordDeal(fromWebSocketDeal(accountID, deal)); err != nil {
+		s.logger.Error("Failed to record deal", slog.Any("error", err))
+	}
+}
+
+// handleAssetEvent передает engine состояние счета мастера (маржа и equity в USDT)
+func (s *Service) handleAssetEvent(master models.Account, asset websocket.AssetEvent) {
+	if asset.Currency != marginCurrency {
+		return
+	}
+
+	s.session.UpdateMasterAsset(fromWebSocketAsset(master, asset))
+}
+
+// masterPrice возвращает цену исполнения ордера мастера: средняя цена fill'ов, иначе цена ордера
+func masterPrice(event websocket.OrderEvent) float64 {
+	if event.DealAvgPrice > 0 {
+		return event.DealAvgPrice
+	}
+	return event.Price
+}
+
+// isLimitOrder - limit и post only ордера мастера копируются limit ордером по той же цене
+func isLimitOrder(event websocket.OrderEvent) bool {
+	return event.OrderType == mexc.OrderTypeLimit || event.OrderType == mexc.OrderTypePostOnly
+}
+
 // fromWebSocketOrder конвертирует websocket.OrderEvent в запрос
 // Возвращает либо OpenPositionRequest, либо ClosePositionRequest
 func fromWebSocketOrder(event websocket.OrderEvent) (openReq *copytrading.OpenPositionRequest, closeReq *copytrading.ClosePositionRequest) {
@@ -163,23 +533,41 @@ func fromWebSocketOrder(event websocket.OrderEvent) (openReq *copytrading.OpenPo
 		if event.StopOrderEvent != nil && event.StopOrderEvent.StopLossPrice > 0 {
 			stopLoss = event.StopOrderEvent.StopLossPrice
 		}
+		var limitPrice float64
+		if isLimitOrder(event) {
+			limitPrice = event.Price
+		}
 		return &copytrading.OpenPositionRequest{
 			Symbol:        event.Symbol,
 			Side:          event.Side,
 			Volume:        event.Vol,
 			Leverage:      event.Leverage,
+			OpenType:      event.OpenType,
 			StopLossPrice: stopLoss,
+			LimitPrice:    limitPrice,
+			MasterPrice:   masterPrice(event),
 		}, nil
 	case 2, 4: // close short, close long
 		return nil, &copytrading.ClosePositionRequest{
-			Symbol: event.Symbol,
-			Side:   event.Side,
-			Volume: event.Vol,
+			Symbol:         event.Symbol,
+			Side:           event.Side,
+			Volume:         event.Vol,
+			MasterExecuted: event.State == orderStateCompleted,
 		}
 	}
 	return nil, nil
 }
 
+// fromWebSocketCancel конвертирует отмененный limit ордер мастера в CancelOrderRequest
+func fromWebSocketCancel(event websocket.OrderEvent) copytrading.CancelOrderRequest {
+	return copytrading.CancelOrderRequest{
+		MasterOrderID: event.OrderID,
+		Symbol:        event.Symbol,
+		Side:          event.Side,
+		Price:         event.Price,
+	}
+}
+
 // fromWebSocketStopOrder конвертирует websocket.StopOrderEvent в PlacePlanOrderRequest
 func fromWebSocketStopOrder(event websocket.StopOrderEvent) copytrading.PlacePlanOrderRequest {
 	return copytrading.PlacePlanOrderRequest{
@@ -205,11 +593,58 @@ func fromWebSocketStopPlanOrder(event websocket.StopPlanOrderEvent) copytrading.
 	}
 }
 
-// fromWebSocketPosition конвертирует websocket.PositionEvent в ClosePositionRequest
-// Возвращает nil если позиция не закрыта (state != 3)
+// fromWebSocketPosition конвертирует websocket.PositionEvent в ClosePositionRequest полного закрытия
+// позиции того же направления. Возвращает nil если позиция не закрыта (state != 3)
 func fromWebSocketPosition(event websocket.PositionEvent) *copytrading.ClosePositionRequest {
 	if event.State != 3 { // только закрытие позиций
 		return nil
 	}
-	return &copytrading.ClosePositionRequest{Symbol: event.Symbol}
+
+	side := mexc.SideCloseLong
+	if event.PositionType == 2 {
+		side = mexc.SideCloseShort
+	}
+	return &copytrading.ClosePositionRequest{Symbol: event.Symbol, Side: side}
+}
+
+// fromWebSocketAsset конвертирует websocket.AssetEvent в copytrading.MarginUsage. Equity, если биржа
+// его не передала, - сумма доступного, замороженного баланса и маржи позиций
+func fromWebSocketAsset(master models.Account, event websocket.AssetEvent) copytrading.MarginUsage {
+	equity := event.Equity
+	if equity <= 0 {
+		equity = event.AvailableBalance + event.FrozenBalance + event.PositionMargin
+	}
+
+	return copytrading.MarginUsage{
+		AccountID:        master.ID,
+		AccountName:      master.Name,
+		Currency:         event.Currency,
+		Equity:           equity,
+		AvailableBalance: event.AvailableBalance,
+		PositionMargin:   event.PositionMargin,
+		FrozenBalance:    event.FrozenBalance,
+		UpdatedAt:        time.Now(),
+	}
+}
+
+// fromWebSocketDeal конвертирует websocket.DealEvent в models.Deal
+func fromWebSocketDeal(accountID int, event websocket.DealEvent) models.Deal {
+	var at time.Time
+	if event.Timestamp > 0 {
+		at = time.UnixMilli(event.Timestamp).UTC()
+	}
+
+	return models.Deal{
+		AccountID: accountID,
+		ID:        event.ID,
+		OrderID:   event.OrderID,
+		Symbol:    event.Symbol,
+		Side:      event.Side,
+		Vol:       event.Vol,
+		Price:     event.Price,
+		Fee:       event.Fee,
+		Profit:    event.Profit,
+		Taker:     event.Taker,
+		At:        at,
+	}
 }
@@ -0,0 +1,91 @@
+package mexc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"tg_mexc/internal/models"
+)
+
+// ErrThinOrderBook - стакан не покрывает объём ордера или проскальзывание выше допустимого
+var ErrThinOrderBook = errors.New("order book too thin")
+
+// GetDepth получает стакан символа: limit уровней на сторону (0 - по умолчанию биржи)
+func (c *Client) GetDepth(ctx context.Context, symbol string, limit int) (*models.Depth, error) {
+	apiURL := c.baseURL + depthEndpoint + url.PathEscape(symbol)
+	if limit > 0 {
+		apiURL += "?limit=" + strconv.Itoa(limit)
+	}
+
+	var depth models.Depth
+	if err := c.getPublic(ctx, "GetDepth", apiURL, &depth); err != nil {
+		return nil, err
+	}
+
+	return &depth, nil
+}
+
+// SlippageEstimate - оценка исполнения market ордера по стакану
+type SlippageEstimate struct {
+	BestPrice   float64 // Лучшая цена стороны стакана, по которой исполняется ордер
+	AvgPrice    float64 // Средняя цена исполнения покрытого объёма
+	FilledVol   float64 // Объём, который покрывает стакан (меньше запрошенного - стакан тонкий)
+	Vol         float64 // Запрошенный объём
+	SlippagePct float64 // Отклонение средней цены от лучшей, %
+}
+
+// Covered сообщает, что стакан покрывает весь объём
+func (s SlippageEstimate) Covered() bool {
+	return s.Vol > 0 && s.FilledVol >= s.Vol
+}
+
+// EstimateSlippage оценивает исполнение market ордера объёмом vol контрактов: открытие long (SideOpenLong)
+// забирает asks, открытие short - bids
+func EstimateSlippage(depth models.Depth, side int, vol float64) SlippageEstimate {
+	levels := depth.Bids
+	if side == SideOpenLong || side == SideCloseShort {
+		levels = depth.Asks
+	}
+
+	estimate := SlippageEstimate{Vol: vol}
+	if len(levels) == 0 || vol <= 0 {
+		return estimate
+	}
+	estimate.BestPrice = levels[0].Price
+
+	var notional float64
+	for _, level := range levels {
+		fill := min(level.Vol, vol-estimate.FilledVol)
+		notional += fill * level.Price
+		estimate.FilledVol += fill
+		if estimate.FilledVol >= vol {
+			break
+		}
+	}
+
+	if estimate.FilledVol > 0 {
+		estimate.AvgPrice = notional / estimate.FilledVol
+		estimate.SlippagePct = (estimate.AvgPrice - estimate.BestPrice) / estimate.BestPrice * 100
+		if estimate.SlippagePct < 0 {
+			estimate.SlippagePct = -estimate.SlippagePct
+		}
+	}
+
+	return estimate
+}
+
+// CheckSlippage возвращает ErrThinOrderBook, если стакан не покрывает объём или
+// проскальзывание больше maxSlippagePct
+func CheckSlippage(estimate SlippageEstimate, maxSlippagePct float64) error {
+	if !estimate.Covered() {
+		return fmt.Errorf("%w: book covers %v of %v contracts", ErrThinOrderBook, estimate.FilledVol, estimate.Vol)
+	}
+	if estimate.SlippagePct > maxSlippagePct {
+		return fmt.Errorf("%w: estimated slippage %.3f%% exceeds %.3f%%", ErrThinOrderBook, estimate.SlippagePct, maxSlippagePct)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package mexc
+
+import (
+	"cmp"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"tg_mexc/internal/httpmiddleware"
+)
+
+// Адреса MEXC по умолчанию: web API (REST) и WebSocket
+const (
+	DefaultBaseURL = "https://www.mexc.com"
+	DefaultWSURL   = "wss://contract.mexc.com/edge"
+)
+
+// Адреса MEXC для новых клиентов. Переопределяются конфигурацией, например для sandbox -
+// локального mock сервера (internal/mexc/sandbox)
+var (
+	endpointMu sync.RWMutex
+	restURLs   = []string{DefaultBaseURL}
+	streamURL  = DefaultWSURL
+)
+
+// SetEndpoints задает адреса REST и WebSocket для новых клиентов (пусто - адрес по умолчанию).
+// baseURL - один адрес или список зеркал через запятую/пробел: первый - основной,
+// на остальные клиент переключается при DNS / TLS ошибках и 5xx (HostFailover).
+// Вызывается при старте приложения, до создания клиентов
+func SetEndpoints(baseURL, wsURL string) {
+	endpointMu.Lock()
+	defer endpointMu.Unlock()
+
+	restURLs = restURLs[:0:0]
+	for _, u := range strings.FieldsFunc(baseURL, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\t'
+	}) {
+		restURLs = append(restURLs, strings.TrimSuffix(u, "/"))
+	}
+	if len(restURLs) == 0 {
+		restURLs = []string{DefaultBaseURL}
+	}
+	streamURL = cmp.Or(wsURL, DefaultWSURL)
+}
+
+// BaseURL возвращает основной адрес REST API для новых клиентов
+func BaseURL() string {
+	endpointMu.RLock()
+	defer endpointMu.RUnlock()
+
+	return restURLs[0]
+}
+
+// BaseURLs возвращает основной адрес REST API и зеркала для новых клиентов
+func BaseURLs() []string {
+	endpointMu.RLock()
+	defer endpointMu.RUnlock()
+
+	return slices.Clone(restURLs)
+}
+
+// WSURL возвращает адрес WebSocket для новых клиентов
+func WSURL() string {
+	endpointMu.RLock()
+	defer endpointMu.RUnlock()
+
+	return streamURL
+}
+
+// SetBaseURL направляет клиент на другой адрес REST API без зеркал (cookies аккаунта переносятся)
+func (c *Client) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimSuffix(baseURL, "/")
+	c.hosts = nil
+	c.httpClient.Transport = c.wrapTransport()
+	c.setCookies()
+}
+
+// HostStatus возвращает состояние адресов REST API клиента (nil - зеркала не заданы)
+func (c *Client) HostStatus() []httpmiddleware.HostStatus {
+	if c.hosts == nil {
+		return nil
+	}
+
+	return c.hosts.Status()
+}
+
+// newHostPool создает пул адресов REST API для failover (nil - зеркала не заданы или адрес некорректен)
+func newHostPool(baseURLs []string, logger *slog.Logger) *httpmiddleware.HostPool {
+	if len(baseURLs) < 2 {
+		return nil
+	}
+
+	hosts := make([]*url.URL, 0, len(baseURLs))
+	for _, baseURL := range baseURLs {
+		u, err := url.Parse(baseURL)
+		if err != nil || u.Host == "" {
+			logger.Error("Invalid MEXC base URL, mirror failover disabled", slog.String("url", baseURL))
+			return nil
+		}
+		hosts = append(hosts, u)
+	}
+
+	return httpmiddleware.NewHostPool(hosts)
+}
+
+// cookieDomain - домен cookies аккаунта: cookies из браузера выданы для .mexc.com,
+// для другого хоста (sandbox) они привязываются к самому хосту
+func cookieDomain(u *url.URL) string {
+	host := u.Hostname()
+	if host == "mexc.com" || strings.HasSuffix(host, ".mexc.com") {
+		return ".mexc.com"
+	}
+
+	return ""
+}
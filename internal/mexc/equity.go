@@ -0,0 +1,58 @@
+package mexc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MarginCurrency - валюта фьючерсного счета, в которой считается equity для копирования
+const MarginCurrency = "USDT"
+
+// EquityCacheTTL - время жизни закэшированного equity аккаунта (GetEquityCached): пропорциональный
+// объём не требует точности до сделки, а запрос баланса на каждом входе удлиняет копирование
+const EquityCacheTTL = 30 * time.Second
+
+// equityEntry - equity аккаунта и момент запроса
+type equityEntry struct {
+	equity    float64
+	fetchedAt time.Time
+}
+
+// GetEquity запрашивает equity фьючерсного счета в MarginCurrency
+func (c *Client) GetEquity(ctx context.Context) (float64, error) {
+	balances, err := c.GetBalance(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, balance := range balances {
+		if balance.Currency == MarginCurrency {
+			return balance.Equity, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no %s balance", MarginCurrency)
+}
+
+// GetEquityCached возвращает equity из кэша клиента, после EquityCacheTTL - запросом к бирже
+func (c *Client) GetEquityCached(ctx context.Context) (float64, error) {
+	c.equityMu.Lock()
+	cached := c.equity
+	c.equityMu.Unlock()
+
+	if !cached.fetchedAt.IsZero() && c.clock.Now().Sub(cached.fetchedAt) < EquityCacheTTL {
+		return cached.equity, nil
+	}
+
+	equity, err := c.GetEquity(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	c.equityMu.Lock()
+	c.equity = equityEntry{equity: equity, fetchedAt: c.clock.Now()}
+	c.equityMu.Unlock()
+
+	return equity, nil
+}
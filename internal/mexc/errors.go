@@ -0,0 +1,128 @@
+package mexc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"tg_mexc/internal/httpmiddleware"
+)
+
+// ErrBotChallenge - вместо JSON пришла HTML страница (anti-bot challenge Cloudflare/Akamai).
+// Запросы аккаунта блокируются до смены IP/прокси или прохождения проверки в браузере
+var ErrBotChallenge = httpmiddleware.ErrBotChallenge
+
+// ErrorKind - класс ошибки MEXC API, по которому вызывающий код выбирает реакцию
+type ErrorKind string
+
+const (
+	ErrorKindUnknown            ErrorKind = ""
+	ErrorKindAuth               ErrorKind = "auth"                // Токен истек или недействителен - нужно обновить авторизацию
+	ErrorKindInsufficientMargin ErrorKind = "insufficient_margin" // Не хватает баланса/маржи
+	ErrorKindRateLimit          ErrorKind = "rate_limit"          // Слишком частые запросы - можно повторить позже
+	ErrorKindSymbolClosed       ErrorKind = "symbol_closed"       // Контракт не существует или торговля приостановлена
+	ErrorKindBotChallenge       ErrorKind = "bot_challenge"       // Anti-bot защита вернула HTML - аккаунт нужно приостановить
+)
+
+// errorKinds - коды ошибок MEXC futures API и их классы
+var errorKinds = map[int]ErrorKind{
+	401:  ErrorKindAuth,               // Not logged in / token invalid
+	402:  ErrorKindAuth,               // API key expired
+	602:  ErrorKindAuth,               // Signature verification failed
+	429:  ErrorKindRateLimit,          // Too many requests
+	510:  ErrorKindRateLimit,          // Request frequency too high
+	1001: ErrorKindSymbolClosed,       // Contract does not exist
+	1002: ErrorKindSymbolClosed,       // Contract not activated
+	2005: ErrorKindInsufficientMargin, // Balance insufficient
+	2018: ErrorKindInsufficientMargin, // Exceeding the maximum available margin
+}
+
+// codeOrderNotFound - ордера с запрошенным ID (externalOid) нет на бирже
+const codeOrderNotFound = 2040
+
+// ClassifyCode возвращает класс ошибки по коду MEXC API
+func ClassifyCode(code int) ErrorKind {
+	return errorKinds[code]
+}
+
+// APIError - ошибка, которую вернул MEXC API (success=false в ответе)
+type APIError struct {
+	Op      string // Операция клиента: "order", "close position", "GetPositions"...
+	Code    int
+	Message string
+	Kind    ErrorKind
+}
+
+func newAPIError(op string, code int, message string) *APIError {
+	return &APIError{
+		Op:      op,
+		Code:    code,
+		Message: message,
+		Kind:    ClassifyCode(code),
+	}
+}
+
+// apiErrorFromBody разбирает code/message из тела ответа; если тело не JSON - message = тело целиком
+func apiErrorFromBody(op string, body []byte) *APIError {
+	var envelope struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	json.Unmarshal(body, &envelope)
+
+	if envelope.Message == "" {
+		envelope.Message = string(body)
+	}
+
+	return newAPIError(op, envelope.Code, envelope.Message)
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s failed: %s (code %d)", e.Op, e.Message, e.Code)
+}
+
+// ErrorKindOf возвращает класс ошибки MEXC API из цепочки err (ErrorKindUnknown, если это не APIError).
+// ErrBotChallenge в цепочке - ErrorKindBotChallenge
+func ErrorKindOf(err error) ErrorKind {
+	if errors.Is(err, ErrBotChallenge) {
+		return ErrorKindBotChallenge
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind
+	}
+
+	return ErrorKindUnknown
+}
+
+// IsTransient сообщает, что запрос не выполнен по временной причине (rate limit, таймаут) и его можно
+// повторить позже. Для ордеров таймаут не означает, что ордер не дошел до биржи - это решает вызывающий
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if ErrorKindOf(err) == ErrorKindRateLimit || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsAuthError сообщает, что запрос отклонен из-за истекшей авторизации аккаунта.
+// Ошибки, восстановленные из строки (история health), распознаются по тексту
+func IsAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if ErrorKindOf(err) == ErrorKindAuth {
+		return true
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, `"code":401`) || strings.Contains(msg, "(code 401)")
+}
@@ -0,0 +1,83 @@
+package mexc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"tg_mexc/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const orderExternalEndpoint = "/api/platform/futures/api/v1/private/order/external/"
+
+// externalOidLookupTimeout - время на проверку ордера по externalOid после потерянного ответа.
+// Не зависит от ctx размещения: он обычно уже истек, из-за чего ответ и потерян
+const externalOidLookupTimeout = 5 * time.Second
+
+// externalOidResendTimeout - время на повторную отправку ордера, которого по externalOid нет на бирже.
+// Как и проверка, не зависит от истекшего ctx размещения
+const externalOidResendTimeout = 5 * time.Second
+
+// NewExternalOid генерирует клиентский ID ордера (32 символа - максимум MEXC).
+// Новый на каждую попытку копирования: по нему ордер находится, даже если ответ на размещение потерян
+func NewExternalOid() string {
+	return strings.ReplaceAll(uuid.NewString(), "-", "")
+}
+
+// GetOrderByExternalOid получает ордер символа по клиентскому ID (externalOid).
+// Пустой OrderID в ответе - ордера с таким externalOid нет
+func (c *Client) GetOrderByExternalOid(ctx context.Context, symbol, externalOid string) (*models.OpenOrder, error) {
+	apiURL := c.baseURL + orderExternalEndpoint + url.PathEscape(symbol) + "/" + url.PathEscape(externalOid)
+
+	var order models.OpenOrder
+	if err := c.getPublic(ctx, "GetOrderByExternalOid", apiURL, &order); err != nil {
+		return nil, err
+	}
+
+	return &order, nil
+}
+
+// recoverOrder выясняет судьбу ордера, ответ на который потерян (таймаут, обрыв соединения):
+// ордер, дошедший до биржи, возвращается как успешный; если биржа подтвердила, что ордера нет (пустой ответ
+// или код "ордер не найден"), он отправляется повторно с тем же externalOid под своим коротким таймаутом:
+// ctx размещения к этому моменту обычно уже истек. Любая другая ошибка проверки
+// (rate limit, авторизация, биржа занята) - возвращается sendErr без повтора: повтор вслепую может открыть
+// позицию дважды
+func (c *Client) recoverOrder(ctx context.Context, orderReq models.OpenPositionRequest, sendErr error) (string, error) {
+	lookupCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), externalOidLookupTimeout)
+	defer cancel()
+
+	order, err := c.GetOrderByExternalOid(lookupCtx, orderReq.Symbol, orderReq.ExternalOid)
+	var apiErr *APIError
+	switch {
+	case err == nil && order.OrderID != "":
+		c.logger.Warn("Order response lost, order found by externalOid",
+			slog.String("account", c.account.Name),
+			slog.String("externalOid", orderReq.ExternalOid),
+			slog.String("orderId", order.OrderID))
+
+		return order.OrderID, nil
+	case err != nil && !(errors.As(err, &apiErr) && apiErr.Code == codeOrderNotFound):
+		c.logger.Error("Order response lost, externalOid lookup failed",
+			slog.String("account", c.account.Name),
+			slog.String("externalOid", orderReq.ExternalOid),
+			slog.Any("error", err))
+
+		return "", sendErr
+	}
+
+	c.logger.Warn("Order did not reach exchange, resending with the same externalOid",
+		slog.String("account", c.account.Name),
+		slog.String("externalOid", orderReq.ExternalOid),
+		slog.Any("error", sendErr))
+
+	resendCtx, cancelResend := context.WithTimeout(context.WithoutCancel(ctx), externalOidResendTimeout)
+	defer cancelResend()
+
+	return c.sendOrder(resendCtx, orderReq)
+}
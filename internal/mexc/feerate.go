@@ -0,0 +1,74 @@
+package mexc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"tg_mexc/internal/models"
+)
+
+// DefaultFeeRateCacheTTL - время жизни закэшированных комиссий аккаунта по умолчанию
+const DefaultFeeRateCacheTTL = 5 * time.Minute
+
+var (
+	feeRateTTLMu sync.RWMutex
+	feeRateTTL   = DefaultFeeRateCacheTTL
+)
+
+// SetFeeRateCacheTTL задает время жизни кэша комиссий (GetTieredFeeRateCached), ttl <= 0 отключает кэш.
+// Вызывается при старте приложения
+func SetFeeRateCacheTTL(ttl time.Duration) {
+	feeRateTTLMu.Lock()
+	defer feeRateTTLMu.Unlock()
+
+	feeRateTTL = ttl
+}
+
+func feeRateCacheTTL() time.Duration {
+	feeRateTTLMu.RLock()
+	defer feeRateTTLMu.RUnlock()
+
+	return feeRateTTL
+}
+
+// feeRateEntry - комиссии аккаунта по символу и момент запроса
+type feeRateEntry struct {
+	rate      models.TieredFeeRateResponse
+	fetchedAt time.Time
+}
+
+// GetTieredFeeRateCached возвращает комиссии аккаунта из кэша клиента, после TTL - запросом к бирже.
+// Комиссия меняется редко, а проверяется при каждом открытии вручную и выводе списка аккаунтов
+func (c *Client) GetTieredFeeRateCached(ctx context.Context, symbol string) (*models.TieredFeeRateResponse, error) {
+	ttl := feeRateCacheTTL()
+	if ttl <= 0 {
+		return c.GetTieredFeeRate(ctx, symbol)
+	}
+
+	c.feeRateMu.Lock()
+	entry, ok := c.feeRates[symbol]
+	c.feeRateMu.Unlock()
+
+	if ok && c.clock.Now().Sub(entry.fetchedAt) < ttl {
+		rate := entry.rate
+		return &rate, nil
+	}
+
+	return c.RefreshTieredFeeRate(ctx, symbol)
+}
+
+// RefreshTieredFeeRate запрашивает комиссии аккаунта у биржи в обход кэша и обновляет кэш
+// (ручная проверка комиссий пользователем)
+func (c *Client) RefreshTieredFeeRate(ctx context.Context, symbol string) (*models.TieredFeeRateResponse, error) {
+	rate, err := c.GetTieredFeeRate(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	c.feeRateMu.Lock()
+	c.feeRates[symbol] = feeRateEntry{rate: *rate, fetchedAt: c.clock.Now()}
+	c.feeRateMu.Unlock()
+
+	return rate, nil
+}
@@ -0,0 +1,61 @@
+package mexc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"tg_mexc/internal/models"
+)
+
+// Состояния ордера MEXC (OpenOrder.State)
+const (
+	OrderStateUninformed  = 1
+	OrderStateUncompleted = 2 // Принят, не исполнен (полностью)
+	OrderStateCompleted   = 3 // Исполнен
+	OrderStateCancelled   = 4
+	OrderStateInvalid     = 5 // Отклонен биржей после приема
+)
+
+// fillPollInterval - пауза между запросами состояния ордера в WaitForFill
+const fillPollInterval = 200 * time.Millisecond
+
+// ErrOrderNotFilled - ордер отменен или отклонен биржей без исполнения
+var ErrOrderNotFilled = errors.New("order not filled")
+
+// WaitForFill опрашивает ордер orderID, пока он не исполнится, не более timeout.
+// Исполненный (в том числе частично, а затем отмененный) ордер возвращается без ошибки;
+// отмененный без исполнения - с ErrOrderNotFilled. По истечении timeout возвращается
+// последнее известное состояние ордера и ошибка с context.DeadlineExceeded
+func (c *Client) WaitForFill(ctx context.Context, orderID string, timeout time.Duration) (*models.OpenOrder, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var last *models.OpenOrder
+	for {
+		order, err := c.GetOrder(ctx, orderID)
+		if err == nil {
+			last = order
+
+			switch order.State {
+			case OrderStateCompleted:
+				return order, nil
+			case OrderStateCancelled, OrderStateInvalid:
+				if order.DealVol > 0 {
+					return order, nil
+				}
+				return order, fmt.Errorf("%w: order %s state %d", ErrOrderNotFilled, orderID, order.State)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+			return last, fmt.Errorf("order %s not confirmed within %v: %w", orderID, timeout, err)
+		case <-c.clock.After(fillPollInterval):
+		}
+	}
+}
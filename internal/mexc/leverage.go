@@ -0,0 +1,54 @@
+package mexc
+
+import (
+	"context"
+	"time"
+
+	"tg_mexc/internal/models"
+)
+
+// LeverageCacheTTL - сколько живет закэшированный leverage символа. Плечо, измененное через клиент
+// (ChangeLeverage, ChangeRiskLevel), сбрасывает кэш сразу; измененное на сайте - подхватывается по TTL
+const LeverageCacheTTL = time.Minute
+
+// leverageEntry - leverage символа по обеим сторонам и момент запроса
+type leverageEntry struct {
+	infos     []models.LeverageInfo
+	fetchedAt time.Time
+}
+
+// cachedLeverage возвращает leverage символа из кэша клиента, после TTL - запросом к бирже.
+// Клиент живет в пуле на аккаунт, поэтому ключ кэша - символ
+func (c *Client) cachedLeverage(ctx context.Context, symbol string) ([]models.LeverageInfo, error) {
+	c.leverageMu.Lock()
+	entry, ok := c.leverages[symbol]
+	c.leverageMu.Unlock()
+
+	if ok && c.clock.Now().Sub(entry.fetchedAt) < LeverageCacheTTL {
+		return entry.infos, nil
+	}
+
+	infos, err := c.GetLeverage(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	c.leverageMu.Lock()
+	c.leverages[symbol] = leverageEntry{infos: infos, fetchedAt: c.clock.Now()}
+	c.leverageMu.Unlock()
+
+	return infos, nil
+}
+
+// InvalidateLeverage сбрасывает закэшированный leverage символа (пустой symbol - всех символов)
+func (c *Client) InvalidateLeverage(symbol string) {
+	c.leverageMu.Lock()
+	defer c.leverageMu.Unlock()
+
+	if symbol == "" {
+		clear(c.leverages)
+		return
+	}
+
+	delete(c.leverages, symbol)
+}
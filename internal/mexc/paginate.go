@@ -0,0 +1,75 @@
+package mexc
+
+import (
+	"context"
+	"errors"
+
+	"tg_mexc/internal/models"
+)
+
+// MaxPageSize - максимальный размер страницы list endpoints MEXC (page_num / page_size)
+const MaxPageSize = 100
+
+// maxOpenOrderPages - предел страниц GetAllOpenOrders (MaxPageSize * 50 ордеров)
+const maxOpenOrderPages = 50
+
+// ErrStopPaging возвращается из callback Paginate, чтобы прекратить обход без ошибки
+var ErrStopPaging = errors.New("stop paging")
+
+// PageFetcher запрашивает страницу pageNum (с 1) размером pageSize, например Client.GetOpenOrders
+// или замыкание над GetHistoryOrders с символом
+type PageFetcher[T any] func(ctx context.Context, pageNum, pageSize int) ([]T, error)
+
+// Paginate обходит страницы fetch с первой и передает каждую непустую страницу в fn.
+// Обход заканчивается на странице короче pageSize (последней), после maxPages страниц (0 - без предела),
+// при ошибке запроса или fn. ErrStopPaging из fn останавливает обход без ошибки
+func Paginate[T any](ctx context.Context, fetch PageFetcher[T], pageSize, maxPages int, fn func(page []T) error) error {
+	if pageSize < 1 || pageSize > MaxPageSize {
+		pageSize = MaxPageSize
+	}
+
+	for pageNum := 1; maxPages <= 0 || pageNum <= maxPages; pageNum++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		page, err := fetch(ctx, pageNum, pageSize)
+		if err != nil {
+			return err
+		}
+
+		if len(page) > 0 {
+			if err := fn(page); err != nil {
+				if errors.Is(err, ErrStopPaging) {
+					return nil
+				}
+				return err
+			}
+		}
+
+		if len(page) < pageSize {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// CollectAll собирает записи всех страниц fetch (не больше maxPages страниц, 0 - без предела)
+func CollectAll[T any](ctx context.Context, fetch PageFetcher[T], maxPages int) ([]T, error) {
+	var all []T
+	err := Paginate(ctx, fetch, MaxPageSize, maxPages, func(page []T) error {
+		all = append(all, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// GetAllOpenOrders возвращает все открытые ордера аккаунта, а не только первую страницу GetOpenOrders
+func (c *Client) GetAllOpenOrders(ctx context.Context) ([]models.OpenOrder, error) {
+	return CollectAll(ctx, c.GetOpenOrders, maxOpenOrderPages)
+}
@@ -0,0 +1,91 @@
+package mexc
+
+import (
+	"log/slog"
+	"maps"
+	"sync"
+
+	"tg_mexc/internal/clock"
+	"tg_mexc/internal/models"
+)
+
+// pooledClient - клиент и данные аккаунта, с которыми он создан
+type pooledClient struct {
+	account models.Account
+	client  *Client
+}
+
+// ClientPool переиспользует MEXC клиентов по ID аккаунта: cookie jar, transport и
+// TLS соединения живут между операциями, а не создаются заново на каждое событие.
+// Клиент пересоздается, когда изменились данные авторизации или прокси аккаунта
+type ClientPool struct {
+	mu      sync.Mutex
+	clients map[int]pooledClient
+	logger  *slog.Logger
+	clock   clock.Clock
+}
+
+// NewClientPool создает пустой пул клиентов
+func NewClientPool(logger *slog.Logger) *ClientPool {
+	return &ClientPool{
+		clients: make(map[int]pooledClient),
+		logger:  logger,
+		clock:   clock.Real,
+	}
+}
+
+// SetClock подменяет источник времени новых клиентов пула
+func (p *ClientPool) SetClock(clk clock.Clock) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clock = clk
+	clear(p.clients)
+}
+
+// Get возвращает клиент аккаунта. Аккаунт читается из хранилища перед каждой операцией,
+// поэтому обновление токена, cookies или прокси само инвалидирует старый клиент
+func (p *ClientPool) Get(account models.Account) (*Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pooled, ok := p.clients[account.ID]
+	if ok && sameSession(pooled.account, account) {
+		return pooled.client, nil
+	}
+	if ok {
+		pooled.client.httpClient.CloseIdleConnections()
+	}
+
+	client, err := NewClient(account, p.logger)
+	if err != nil {
+		return nil, err
+	}
+	client.SetClock(p.clock)
+
+	p.clients[account.ID] = pooledClient{account: account, client: client}
+
+	return client, nil
+}
+
+// Invalidate удаляет клиент аккаунта (аккаунт удален или изменен)
+func (p *ClientPool) Invalidate(accountID int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pooled, ok := p.clients[accountID]; ok {
+		pooled.client.httpClient.CloseIdleConnections()
+		delete(p.clients, accountID)
+	}
+}
+
+// sameSession сообщает, что клиент, созданный для a, подходит для b
+func sameSession(a, b models.Account) bool {
+	return a.Name == b.Name &&
+		a.Token == b.Token &&
+		a.UserID == b.UserID &&
+		a.DeviceID == b.DeviceID &&
+		a.UserAgent == b.UserAgent &&
+		a.Proxy == b.Proxy &&
+		maps.Equal(a.Cookies, b.Cookies)
+}
@@ -0,0 +1,116 @@
+package mexc
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const positionModeEndpoint = "/api/platform/futures/api/v1/private/position/position_mode"
+
+// PositionModeCacheTTL - сколько живет закэшированный режим позиций аккаунта. Режим, измененный на сайте,
+// подхватывается по TTL или сразу после отказа биржи из-за несовпадения режима (invalidatePositionMode)
+const PositionModeCacheTTL = 5 * time.Minute
+
+// positionModeEntry - режим позиций аккаунта и момент запроса
+type positionModeEntry struct {
+	mode      int
+	fetchedAt time.Time
+}
+
+// Режим позиций аккаунта
+const (
+	PositionModeHedge  = 1 // Раздельные long и short позиции, закрытие - side 2/4
+	PositionModeOneWay = 2 // Одна позиция на символ, закрытие - встречный ордер с reduceOnly
+)
+
+// Стороны ордера MEXC
+const (
+	SideOpenLong   = 1
+	SideCloseShort = 2
+	SideOpenShort  = 3
+	SideCloseLong  = 4
+)
+
+// GetPositionMode возвращает режим позиций аккаунта (PositionModeHedge или PositionModeOneWay)
+func (c *Client) GetPositionMode(ctx context.Context) (int, error) {
+	var mode int
+	if err := c.getPublic(ctx, "GetPositionMode", c.baseURL+positionModeEndpoint, &mode); err != nil {
+		return 0, err
+	}
+
+	return mode, nil
+}
+
+// positionMode возвращает режим позиций аккаунта из кэша клиента, после PositionModeCacheTTL -
+// запросом к бирже. Если режим узнать не удалось, считается hedge (режим MEXC по умолчанию)
+func (c *Client) positionMode(ctx context.Context) int {
+	c.modeMu.Lock()
+	defer c.modeMu.Unlock()
+
+	if c.mode.mode != 0 && c.clock.Now().Sub(c.mode.fetchedAt) < PositionModeCacheTTL {
+		return c.mode.mode
+	}
+
+	mode, err := c.GetPositionMode(ctx)
+	if err != nil || (mode != PositionModeHedge && mode != PositionModeOneWay) {
+		c.logger.Warn("Failed to get position mode, assuming hedge",
+			slog.String("account", c.account.Name),
+			slog.Int("mode", mode),
+			slog.Any("error", err))
+
+		return PositionModeHedge
+	}
+
+	c.mode = positionModeEntry{mode: mode, fetchedAt: c.clock.Now()}
+
+	return mode
+}
+
+// invalidatePositionMode сбрасывает закэшированный режим позиций, если биржа отклонила ордер
+// из-за несовпадения режима: следующий ордер запросит режим заново
+func (c *Client) invalidatePositionMode(err error) {
+	if !isPositionModeMismatch(err) {
+		return
+	}
+
+	c.modeMu.Lock()
+	c.mode = positionModeEntry{}
+	c.modeMu.Unlock()
+
+	c.logger.Warn("Order rejected for position mode mismatch, position mode cache reset",
+		slog.String("account", c.account.Name),
+		slog.Any("error", err))
+}
+
+// isPositionModeMismatch сообщает, что биржа отклонила ордер из-за режима позиций аккаунта.
+// Отдельного кода у отказа нет, он распознается по тексту сообщения
+func isPositionModeMismatch(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	msg := strings.ToLower(apiErr.Message)
+	return strings.Contains(msg, "position mode") || strings.Contains(msg, "positionmode")
+}
+
+// orderSide переводит сторону ордера hedge режима (1-4) в сторону для режима позиций аккаунта.
+// В one-way режиме закрытие - встречный ордер с reduceOnly: close long → sell (3), close short → buy (1)
+func (c *Client) orderSide(ctx context.Context, side int) (int, bool) {
+	if side != SideCloseLong && side != SideCloseShort {
+		return side, false
+	}
+
+	if c.positionMode(ctx) != PositionModeOneWay {
+		return side, false
+	}
+
+	if side == SideCloseLong {
+		return SideOpenShort, true
+	}
+
+	return SideOpenLong, true
+}
@@ -0,0 +1,45 @@
+package mexc
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"tg_mexc/internal/models"
+)
+
+// contractCache - параметры контрактов по символу. Они общие для всех аккаунтов и почти не меняются,
+// поэтому кэшируются на время жизни процесса
+var contractCache sync.Map // symbol -> models.ContractDetail
+
+// ContractDetail возвращает параметры контракта символа из кэша, при первом обращении - запросом к бирже
+func (c *Client) ContractDetail(ctx context.Context, symbol string) (models.ContractDetail, error) {
+	if detail, ok := contractCache.Load(symbol); ok {
+		return detail.(models.ContractDetail), nil
+	}
+
+	detail, err := c.GetContractDetail(ctx, symbol)
+	if err != nil {
+		return models.ContractDetail{}, err
+	}
+	contractCache.Store(symbol, *detail)
+
+	return *detail, nil
+}
+
+// FormatPrice округляет цену до шага контракта и форматирует ее с точностью символа (priceScale).
+// Если параметры контракта недоступны, цена передается как есть (без потери знаков)
+func (c *Client) FormatPrice(ctx context.Context, symbol string, price float64) string {
+	detail, err := c.ContractDetail(ctx, symbol)
+	if err != nil {
+		c.logger.Warn("Failed to get contract detail, price sent as is",
+			slog.String("account", c.account.Name),
+			slog.String("symbol", symbol),
+			slog.Any("error", err))
+
+		return strconv.FormatFloat(price, 'f', -1, 64)
+	}
+
+	return detail.FormatPrice(price)
+}
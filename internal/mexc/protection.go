@@ -0,0 +1,42 @@
+package mexc
+
+import (
+	"strconv"
+
+	"tg_mexc/internal/models"
+)
+
+// OrderProtection - защита market ордеров от исполнения по плохой цене во время резких движений
+type OrderProtection struct {
+	PriceProtect   bool    // priceProtect=1: биржа отклоняет ордер, если цена ушла за порог защиты от fair price
+	MarketCeiling  bool    // marketCeiling: биржа ограничивает цену market ордера своим лимитом цены
+	MaxSlippagePct float64 // > 0: ордер отправляется FOK limit ордером по цене ориентира ± MaxSlippagePct%
+}
+
+// Apply применяет защиту к market ордеру (MarketOrder). refPrice - цена ориентир (исполнение мастера),
+// 0 - ограничение проскальзывания не применяется. Цена границы - по шагу контракта detail.
+// FOK ордер исполняется целиком не хуже границы или отменяется биржей (ErrOrderNotFilled в WaitForFill)
+func (p OrderProtection) Apply(order *models.OpenPositionRequest, refPrice float64, detail models.ContractDetail) {
+	if order.Type != strconv.Itoa(OrderTypeMarket) {
+		return
+	}
+
+	if p.PriceProtect {
+		order.PriceProtect = "1"
+	}
+	order.MarketCeiling = p.MarketCeiling
+
+	if p.MaxSlippagePct <= 0 || refPrice <= 0 {
+		return
+	}
+
+	// Покупка (open long) - не выше границы, продажа (open short) - не ниже
+	bound := refPrice * (1 + p.MaxSlippagePct/100)
+	if order.Side == SideOpenShort || order.Side == SideCloseLong {
+		bound = refPrice * (1 - p.MaxSlippagePct/100)
+	}
+
+	order.Type = strconv.Itoa(OrderTypeFOK)
+	order.Price = detail.FormatPrice(bound)
+	order.MarketCeiling = false
+}
@@ -0,0 +1,126 @@
+package mexc
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"sync"
+	"time"
+
+	"tg_mexc/internal/httpmiddleware"
+	"tg_mexc/internal/models"
+)
+
+// proxyProbeTimeout - таймаут проверки одного прокси
+const proxyProbeTimeout = 10 * time.Second
+
+// accountProxies - пул прокси аккаунта и список, из которого он создан
+type accountProxies struct {
+	list string
+	pool *httpmiddleware.ProxyPool
+}
+
+// Пулы прокси аккаунтов. Клиент создается на каждую операцию,
+// поэтому выбранный прокси и его здоровье общие для всех клиентов аккаунта
+var (
+	proxyPoolsMu sync.Mutex
+	proxyPools   = make(map[string]accountProxies)
+)
+
+// accountProxyPool возвращает пул прокси аккаунта (nil - без прокси).
+// Поле Proxy аккаунта - один прокси или список через запятую/пробел
+func accountProxyPool(account models.Account) (*httpmiddleware.ProxyPool, error) {
+	if account.Proxy == "" {
+		return nil, nil
+	}
+
+	proxyPoolsMu.Lock()
+	defer proxyPoolsMu.Unlock()
+
+	key := accountKey(account)
+	if entry, ok := proxyPools[key]; ok && entry.list == account.Proxy {
+		return entry.pool, nil
+	}
+
+	proxies, err := httpmiddleware.ParseProxies(account.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := httpmiddleware.NewProxyPool(proxies)
+	proxyPools[key] = accountProxies{list: account.Proxy, pool: pool}
+
+	return pool, nil
+}
+
+// AccountProxy возвращает текущий прокси аккаунта из его пула (nil - без прокси)
+func AccountProxy(account models.Account) (*url.URL, error) {
+	pool, err := accountProxyPool(account)
+	if pool == nil {
+		return nil, err
+	}
+
+	return pool.Current(), nil
+}
+
+// ReportProxyError сообщает об ошибке соединения через прокси аккаунта вне Client (WebSocket):
+// пул переключается на следующий прокси
+func ReportProxyError(account models.Account, proxy *url.URL, err error) {
+	pool, _ := accountProxyPool(account)
+	if pool != nil {
+		pool.MarkFailed(proxy, err)
+	}
+}
+
+// ProxyStatus возвращает состояние прокси аккаунта (nil - прокси не используются или клиент еще не создавался)
+func ProxyStatus(account models.Account) []httpmiddleware.ProxyStatus {
+	proxyPoolsMu.Lock()
+	entry, ok := proxyPools[accountKey(account)]
+	proxyPoolsMu.Unlock()
+
+	if !ok || entry.list != account.Proxy {
+		return nil
+	}
+
+	return entry.pool.Status()
+}
+
+// RunProxyHealthChecks проверяет все пулы прокси каждые interval до отмены ctx (interval <= 0 отключает).
+// Неработающие прокси исключаются из ротации, восстановившиеся - возвращаются
+func RunProxyHealthChecks(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	if interval <= 0 {
+		logger.Info("Proxy health checks disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		proxyPoolsMu.Lock()
+		pools := make(map[string]*httpmiddleware.ProxyPool, len(proxyPools))
+		for key, entry := range proxyPools {
+			pools[key] = entry.pool
+		}
+		proxyPoolsMu.Unlock()
+
+		for key, pool := range pools {
+			pool.Check(ctx, BaseURL(), proxyProbeTimeout)
+
+			for _, status := range pool.Status() {
+				if !status.Healthy {
+					logger.Warn("Proxy is unhealthy",
+						slog.String("account", key),
+						slog.String("proxy", status.URL),
+						slog.String("error", status.LastError))
+				}
+			}
+		}
+	}
+}
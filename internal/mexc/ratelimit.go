@@ -0,0 +1,56 @@
+package mexc
+
+import (
+	"sync"
+
+	"tg_mexc/internal/httpmiddleware"
+	"tg_mexc/internal/models"
+)
+
+// Лимит запросов к MEXC на аккаунт. Клиент создается на каждую операцию,
+// поэтому limiter общий для всех клиентов одного аккаунта
+var (
+	rateLimitMu sync.Mutex
+	rateRPS     float64
+	rateBurst   int
+	limiters    = make(map[string]*httpmiddleware.RateLimiter)
+)
+
+// SetRateLimit задает лимит запросов к MEXC на аккаунт: rps в секунду, пачкой до burst (rps <= 0 отключает).
+// Вызывается при старте приложения, до создания клиентов
+func SetRateLimit(rps float64, burst int) {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+
+	rateRPS, rateBurst = rps, burst
+	limiters = make(map[string]*httpmiddleware.RateLimiter)
+}
+
+// accountLimiter возвращает limiter аккаунта (nil - лимит отключен)
+func accountLimiter(account models.Account) *httpmiddleware.RateLimiter {
+	rateLimitMu.Lock()
+	defer rateLimitMu.Unlock()
+
+	if rateRPS <= 0 {
+		return nil
+	}
+
+	key := accountKey(account)
+
+	limiter, ok := limiters[key]
+	if !ok {
+		limiter = httpmiddleware.NewRateLimiter(rateRPS, rateBurst)
+		limiters[key] = limiter
+	}
+
+	return limiter
+}
+
+// accountKey - ключ общего состояния клиентов аккаунта: MEXC аккаунт (u_id), имя - для аккаунтов без u_id
+func accountKey(account models.Account) string {
+	if account.UserID != "" {
+		return account.UserID
+	}
+
+	return "name:" + account.Name
+}
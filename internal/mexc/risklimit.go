@@ -0,0 +1,63 @@
+package mexc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"tg_mexc/internal/models"
+)
+
+const (
+	riskLimitEndpoint       = "/api/platform/futures/api/v1/private/account/risk_limit"
+	changeRiskLevelEndpoint = "/api/platform/futures/api/v1/private/account/change_risk_level"
+)
+
+// ErrRiskLimitExceeded - объём ордера не помещается в текущую ступень риск-лимита аккаунта
+var ErrRiskLimitExceeded = errors.New("risk limit exceeded")
+
+// GetRiskLimits возвращает текущие ступени риск-лимита аккаунта по символу (по одной на long и short)
+func (c *Client) GetRiskLimits(ctx context.Context, symbol string) ([]models.RiskLimit, error) {
+	var limits map[string][]models.RiskLimit
+	if err := c.getPublic(ctx, "GetRiskLimits", c.baseURL+riskLimitEndpoint+"?symbol="+symbol, &limits); err != nil {
+		return nil, err
+	}
+
+	return limits[symbol], nil
+}
+
+// ChangeRiskLevel переводит позицию positionType (1 long, 2 short) символа на ступень риск-лимита level.
+// Более высокая ступень позволяет больший объём, но снижает максимальное плечо
+func (c *Client) ChangeRiskLevel(ctx context.Context, symbol string, positionType int, level int) error {
+	payload := map[string]any{
+		"symbol":       symbol,
+		"positionType": positionType,
+		"level":        level,
+	}
+
+	if err := c.postSigned(ctx, "ChangeRiskLevel", changeRiskLevelEndpoint, payload, nil); err != nil {
+		return err
+	}
+	c.InvalidateLeverage(symbol)
+
+	c.logger.Info("✅ ChangeRiskLevel success",
+		slog.String("account", c.account.Name),
+		slog.String("symbol", symbol),
+		slog.Int("positionType", positionType),
+		slog.Int("level", level))
+
+	return nil
+}
+
+// CheckRiskLimit проверяет, что после добавления vol контрактов позиция стороны side (1 open long, 3 open short)
+// остается в пределах ступени риск-лимита. info - leverage аккаунта по этой стороне (GetLeverageInfoForSide),
+// holdVol - текущий объём позиции. Превышение - ErrRiskLimitExceeded
+func CheckRiskLimit(info models.LeverageInfo, holdVol, vol float64) error {
+	if info.MaxVol <= 0 || holdVol+vol <= info.MaxVol {
+		return nil
+	}
+
+	return fmt.Errorf("%w: position %v + order %v > max %v contracts (tier %d, leverage x%d)",
+		ErrRiskLimitExceeded, holdVol, vol, info.MaxVol, info.Level, info.Leverage)
+}
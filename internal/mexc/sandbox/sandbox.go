@@ -0,0 +1,393 @@
+package sandbox
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultPrice - цена, по которой sandbox исполняет ордера и отдает ticker
+const DefaultPrice = 60000.0
+
+// position - позиция аккаунта sandbox по символу и направлению
+type position struct {
+	id       int64
+	holdVol  float64
+	leverage int
+	openType int
+}
+
+type positionKey struct {
+	symbol       string
+	positionType int // 1 long, 2 short
+}
+
+// pushMessage - событие мастера для WebSocket клиентов (POST /sandbox/push)
+type pushMessage struct {
+	Channel string          `json:"channel"`
+	Data    json.RawMessage `json:"data"`
+	Ts      int64           `json:"ts"`
+}
+
+// Server - mock MEXC для интеграционных прогонов всего конвейера копирования без mexc.com.
+// Web API исполняет ордера мгновенно по DefaultPrice и ведет позиции каждого аккаунта (по uc_token),
+// WebSocket (/edge) принимает login, ping и personal.filter, события мастера публикуются через POST /sandbox/push
+type Server struct {
+	mu        sync.Mutex
+	positions map[string]map[positionKey]*position // uc_token -> позиции
+	orders    map[string]string                    // uc_token + externalOid -> ID ордера
+	nextID    int64
+	conns     map[*websocket.Conn]*connState
+
+	upgrader websocket.Upgrader
+	logger   *slog.Logger
+}
+
+// connState - настройки WebSocket клиента
+type connState struct {
+	gzip    bool                // Клиент запросил gzip данные (login с gzip)
+	filters map[string]struct{} // personal.filter: приватные каналы без префикса, nil - все
+}
+
+// accepts сообщает, нужно ли клиенту событие channel (фильтруются только push.personal.*)
+func (c *connState) accepts(channel string) bool {
+	personal, ok := strings.CutPrefix(channel, "push.personal.")
+	if !ok || c.filters == nil {
+		return true
+	}
+
+	_, ok = c.filters[personal]
+	return ok
+}
+
+// New создает sandbox сервер без позиций и подключений
+func New(logger *slog.Logger) *Server {
+	return &Server{
+		positions: make(map[string]map[positionKey]*position),
+		orders:    make(map[string]string),
+		conns:     make(map[*websocket.Conn]*connState),
+		upgrader: websocket.Upgrader{
+			CheckOrigin:       func(*http.Request) bool { return true },
+			EnableCompression: true,
+		},
+		logger: logger,
+	}
+}
+
+// Handler возвращает HTTP handler sandbox: /edge - WebSocket, /sandbox/push - публикация события,
+// остальные пути - web API MEXC
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/edge", s.handleWebSocket)
+	mux.HandleFunc("POST /sandbox/push", s.handlePush)
+	mux.HandleFunc("/", s.handleAPI)
+
+	return mux
+}
+
+// Push отправляет событие channel (например push.personal.order) всем подключенным WebSocket клиентам,
+// кроме отфильтровавших канал через personal.filter. Возвращает количество получателей
+func (s *Server) Push(channel string, data json.RawMessage) int {
+	msg := pushMessage{Channel: channel, Data: data, Ts: time.Now().UnixMilli()}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sent := 0
+	for conn, state := range s.conns {
+		if !state.accepts(channel) {
+			continue
+		}
+		if err := writePush(conn, msg, state.gzip); err != nil {
+			s.logger.Warn("Sandbox push failed", slog.Any("error", err))
+			continue
+		}
+		sent++
+	}
+
+	return sent
+}
+
+// writePush отправляет событие клиенту: с gzip - сжатым бинарным фреймом, как MEXC
+func writePush(conn *websocket.Conn, msg pushMessage, gzipped bool) error {
+	if !gzipped {
+		return conn.WriteJSON(msg)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(msg); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return conn.WriteMessage(websocket.BinaryMessage, buf.Bytes())
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	var msg pushMessage
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || msg.Channel == "" {
+		http.Error(w, "expected {\"channel\": ..., \"data\": {...}}", http.StatusBadRequest)
+		return
+	}
+
+	sent := s.Push(msg.Channel, msg.Data)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"sent": sent})
+}
+
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.logger.Warn("Sandbox WebSocket upgrade failed", slog.Any("error", err))
+		return
+	}
+
+	s.mu.Lock()
+	state := &connState{}
+	s.conns[conn] = state
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		var msg struct {
+			Method string `json:"method"`
+			Param  struct {
+				Filters []struct {
+					Filter string `json:"filter"`
+				} `json:"filters"`
+			} `json:"param"`
+			Gzip bool `json:"gzip"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		var reply pushMessage
+		switch msg.Method {
+		case "login":
+			reply = pushMessage{Channel: "rs.login", Data: json.RawMessage(`"success"`)}
+			s.mu.Lock()
+			state.gzip = msg.Gzip
+			s.mu.Unlock()
+		case "personal.filter":
+			// Пустой список - все приватные каналы
+			filters := make(map[string]struct{}, len(msg.Param.Filters))
+			for _, f := range msg.Param.Filters {
+				filters[f.Filter] = struct{}{}
+			}
+			if len(filters) == 0 {
+				filters = nil
+			}
+			s.mu.Lock()
+			state.filters = filters
+			s.mu.Unlock()
+			reply = pushMessage{Channel: "rs.personal.filter", Data: json.RawMessage(`"success"`)}
+		case "ping":
+			reply = pushMessage{Channel: "pong", Data: json.RawMessage(fmt.Sprint(time.Now().UnixMilli()))}
+		case "sub.ticker", "sub.fair.price", "unsub.ticker", "unsub.fair.price":
+			// Цены публикуются через POST /sandbox/push (push.ticker, push.fair.price)
+			reply = pushMessage{Channel: "rs." + msg.Method, Data: json.RawMessage(`"success"`)}
+		default:
+			continue
+		}
+		reply.Ts = time.Now().UnixMilli()
+
+		s.mu.Lock()
+		err := conn.WriteJSON(reply)
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("Authorization")
+	path := r.URL.Path
+	symbol := r.URL.Query().Get("symbol")
+
+	var data any
+	switch {
+	case strings.HasSuffix(path, "/private/order/create"):
+		orderID, err := s.createOrder(token, r.Body)
+		if err != nil {
+			writeError(w, 2011, err.Error())
+			return
+		}
+		data = map[string]any{"orderId": orderID, "ts": time.Now().UnixMilli()}
+	case strings.HasSuffix(path, "/private/position/open_positions"):
+		data = s.openPositions(token, symbol)
+	case strings.HasSuffix(path, "/private/position/leverage"):
+		data = []models.LeverageInfo{
+			{PositionType: 1, OpenType: mexc.OpenTypeIsolated, Leverage: 20, Level: 1, MaxVol: 1_000_000},
+			{PositionType: 2, OpenType: mexc.OpenTypeIsolated, Leverage: 20, Level: 1, MaxVol: 1_000_000},
+		}
+	case strings.HasSuffix(path, "/private/position/position_mode"):
+		data = mexc.PositionModeHedge
+	case strings.HasSuffix(path, "/private/account/assets"):
+		data = []models.Balance{{Currency: "USDT", AvailableBalance: 10_000, Equity: 10_000}}
+	case strings.HasSuffix(path, "/ucenter/api/user_info"):
+		data = map[string]any{"kycLevel": 2, "frozen": false}
+	case strings.Contains(path, "/private/order/external/"):
+		data = map[string]any{"orderId": s.orderByExternalOid(token, path[strings.LastIndex(path, "/")+1:])}
+	case strings.Contains(path, "/private/order/get/"):
+		data = map[string]any{"dealAvgPrice": DefaultPrice, "state": 3}
+	case strings.HasSuffix(path, "/contract/detail"):
+		data = models.ContractDetail{Symbol: symbol, ContractSize: 0.0001, PriceScale: 1, PriceUnit: 0.1,
+			VolUnit: 1, MinVol: 1, MaxVol: 1_000_000, MinLeverage: 1, MaxLeverage: 125}
+	case strings.HasSuffix(path, "/contract/ping"):
+		data = time.Now().UnixMilli()
+	case strings.HasSuffix(path, "/contract/ticker"):
+		data = models.Ticker{Symbol: symbol, LastPrice: DefaultPrice, FairPrice: DefaultPrice, IndexPrice: DefaultPrice,
+			Bid1: DefaultPrice, Ask1: DefaultPrice, Timestamp: time.Now().UnixMilli()}
+	case strings.Contains(path, "/contract/depth/"):
+		data = map[string]any{
+			"asks": [][]float64{{DefaultPrice, 1_000_000, 1}},
+			"bids": [][]float64{{DefaultPrice, 1_000_000, 1}},
+		}
+	case strings.Contains(path, "/list/") || strings.HasSuffix(path, "/open_orders") || strings.HasSuffix(path, "/funding_records"):
+		data = []any{}
+	default:
+		data = map[string]any{}
+	}
+
+	writeJSON(w, map[string]any{"success": true, "code": 0, "data": data})
+}
+
+// createOrder исполняет ордер мгновенно: открытие (side 1/3) увеличивает позицию, закрытие (2/4) уменьшает
+func (s *Server) createOrder(token string, body io.Reader) (string, error) {
+	var order struct {
+		Symbol      string      `json:"symbol"`
+		Side        int         `json:"side"`
+		Vol         json.Number `json:"vol"`
+		Leverage    int         `json:"leverage"`
+		OpenType    int         `json:"openType"`
+		ReduceOnly  bool        `json:"reduceOnly"`
+		ExternalOid string      `json:"externalOid"`
+	}
+	if err := json.NewDecoder(body).Decode(&order); err != nil {
+		return "", fmt.Errorf("invalid order: %w", err)
+	}
+	vol, err := order.Vol.Float64()
+	if err != nil || vol <= 0 {
+		return "", fmt.Errorf("invalid vol %q", order.Vol)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	positions, ok := s.positions[token]
+	if !ok {
+		positions = make(map[positionKey]*position)
+		s.positions[token] = positions
+	}
+
+	// Повтор с тем же externalOid не исполняется второй раз
+	if orderID, ok := s.orders[token+order.ExternalOid]; ok && order.ExternalOid != "" {
+		return orderID, nil
+	}
+
+	s.nextID++
+	orderID := fmt.Sprint(s.nextID)
+	if order.ExternalOid != "" {
+		s.orders[token+order.ExternalOid] = orderID
+	}
+
+	switch order.Side {
+	case mexc.SideOpenLong, mexc.SideOpenShort:
+		key := positionKey{symbol: order.Symbol, positionType: 1}
+		if order.Side == mexc.SideOpenShort {
+			key.positionType = 2
+		}
+
+		pos, ok := positions[key]
+		if !ok {
+			s.nextID++
+			pos = &position{id: s.nextID}
+			positions[key] = pos
+		}
+		pos.holdVol += vol
+		pos.leverage = order.Leverage
+		pos.openType = mexc.MarginOpenType(order.OpenType)
+	case mexc.SideCloseLong, mexc.SideCloseShort:
+		key := positionKey{symbol: order.Symbol, positionType: 1}
+		if order.Side == mexc.SideCloseShort {
+			key.positionType = 2
+		}
+
+		pos, ok := positions[key]
+		if !ok {
+			return "", fmt.Errorf("position does not exist")
+		}
+		pos.holdVol -= min(vol, pos.holdVol)
+		if pos.holdVol == 0 {
+			delete(positions, key)
+		}
+	default:
+		return "", fmt.Errorf("invalid side %d", order.Side)
+	}
+
+	return orderID, nil
+}
+
+// orderByExternalOid возвращает ID ордера аккаунта по externalOid (пусто - ордера нет)
+func (s *Server) orderByExternalOid(token, externalOid string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.orders[token+externalOid]
+}
+
+func (s *Server) openPositions(token, symbol string) []models.Position {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []models.Position{}
+	for key, pos := range s.positions[token] {
+		if symbol != "" && key.symbol != symbol {
+			continue
+		}
+		result = append(result, models.Position{
+			PositionID:   pos.id,
+			Symbol:       key.symbol,
+			PositionType: key.positionType,
+			OpenType:     pos.openType,
+			HoldVol:      pos.holdVol,
+			HoldAvgPrice: DefaultPrice,
+			Leverage:     pos.leverage,
+		})
+	}
+
+	return result
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, map[string]any{"success": false, "code": code, "message": message})
+}
+
+func writeJSON(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,94 @@
+package mexc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+
+	"tg_mexc/internal/models"
+)
+
+// userInfoEndpoint - профиль пользователя на сайте: ответ продлевает web-сессию и
+// выставляет свежие cookies (uc_token, u_id), если старый токен близок к истечению
+const userInfoEndpoint = "/ucenter/api/user_info"
+
+// ErrSessionExpired - uc_token аккаунта истек или отозван, нужна новая авторизация
+var ErrSessionExpired = errors.New("session expired")
+
+// ValidateSession проверяет авторизацию аккаунта легким приватным запросом (баланс).
+// Истекшая авторизация возвращается как ErrSessionExpired, сетевые и прочие ошибки - как есть
+func (c *Client) ValidateSession(ctx context.Context) error {
+	_, err := c.GetBalance(ctx)
+	if IsAuthError(err) {
+		return fmt.Errorf("%w: %w", ErrSessionExpired, err)
+	}
+
+	return err
+}
+
+// RefreshSession продлевает web-сессию аккаунта запросом к сайту с сохраненными cookies.
+// Если сайт выдал новый uc_token, клиент сразу переключается на него, а обновленный аккаунт
+// возвращается с changed = true - его нужно сохранить. ErrSessionExpired - cookies уже недействительны,
+// токен можно получить только заново через браузерный скрипт
+func (c *Client) RefreshSession(ctx context.Context) (account models.Account, changed bool, err error) {
+	timestamp := c.timestamp()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", c.baseURL+userInfoEndpoint, http.NoBody)
+	c.setGetHeaders(req, timestamp)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("RefreshSession failed",
+			slog.String("account", c.account.Name),
+			slog.Any("error", err))
+
+		return c.account, false, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return c.account, false, fmt.Errorf("%w: %w", ErrSessionExpired, newAPIError("RefreshSession", 401, resp.Status))
+	}
+
+	// Ответы ucenter: {"code": 0, "data": {...}}; код 0 или 200 - успех
+	if apiErr := apiErrorFromBody("RefreshSession", body); apiErr.Code != 0 && apiErr.Code != http.StatusOK {
+		c.logger.Error("RefreshSession API error",
+			slog.String("account", c.account.Name),
+			slog.String("response", string(body)))
+
+		if apiErr.Kind == ErrorKindAuth {
+			return c.account, false, fmt.Errorf("%w: %w", ErrSessionExpired, apiErr)
+		}
+
+		return c.account, false, apiErr
+	}
+
+	u, _ := url.Parse(c.baseURL)
+	cookies := maps.Clone(c.account.Cookies)
+	if cookies == nil {
+		cookies = make(map[string]string)
+	}
+	for _, cookie := range c.httpClient.Jar.Cookies(u) {
+		cookies[cookie.Name] = cookie.Value
+	}
+
+	token := cookies["uc_token"]
+	if token == "" || token == sanitizeCookieValue(c.account.Token) {
+		return c.account, false, nil
+	}
+
+	c.account.Token = token
+	c.account.Cookies = cookies
+
+	c.logger.Info("✅ Session token refreshed",
+		slog.String("account", c.account.Name))
+
+	return c.account, true, nil
+}
@@ -0,0 +1,131 @@
+package mexc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"tg_mexc/internal/httpmiddleware"
+	"tg_mexc/internal/models"
+)
+
+// Spot endpoints web сессии: те же uc_token, cookies и подпись, что у futures
+const (
+	spotOrderPlaceEndpoint  = "/api/platform/spot/order/place"
+	spotOrderCancelEndpoint = "/api/platform/spot/order/cancel"
+	spotBalancesEndpoint    = "/api/platform/spot/asset/balances"
+)
+
+// Стороны spot ордера
+const (
+	SpotSideBuy  = "BUY"
+	SpotSideSell = "SELL"
+)
+
+// Типы spot ордеров
+const (
+	SpotOrderTypeLimit  = "LIMIT_ORDER"
+	SpotOrderTypeMarket = "MARKET_ORDER"
+)
+
+// SpotClient - spot операции аккаунта. Работает через HTTP клиент futures клиента:
+// cookie jar, прокси, rate limit и retry общие
+type SpotClient struct {
+	c *Client
+}
+
+// Spot возвращает spot sub-client аккаунта
+func (c *Client) Spot() *SpotClient {
+	return &SpotClient{c: c}
+}
+
+// PlaceOrder размещает spot ордер и возвращает его ID.
+// Limit ордер - Price и Quantity; market покупка - Amount (сумма в quote валюте) или Quantity; market продажа - Quantity
+func (s *SpotClient) PlaceOrder(ctx context.Context, req models.SpotOrderRequest) (string, error) {
+	if err := validateSpotOrder(req); err != nil {
+		return "", err
+	}
+
+	var orderID string
+	if err := s.c.postSigned(ctx, "SpotPlaceOrder", spotOrderPlaceEndpoint, req, &orderID); err != nil {
+		return "", err
+	}
+
+	s.c.logger.Info("✅ SpotPlaceOrder success",
+		slog.String("account", s.c.account.Name),
+		slog.String("symbol", req.Symbol),
+		slog.String("side", req.Side),
+		slog.String("type", req.OrderType),
+		slog.String("orderId", orderID))
+
+	return orderID, nil
+}
+
+// CancelOrder отменяет spot ордер orderID пары symbol
+func (s *SpotClient) CancelOrder(ctx context.Context, symbol, orderID string) error {
+	payload := map[string]string{
+		"symbol":  symbol,
+		"orderId": orderID,
+	}
+
+	if err := s.c.postSigned(httpmiddleware.Idempotent(ctx), "SpotCancelOrder", spotOrderCancelEndpoint, payload, nil); err != nil {
+		return err
+	}
+
+	s.c.logger.Info("✅ SpotCancelOrder success",
+		slog.String("account", s.c.account.Name),
+		slog.String("symbol", symbol),
+		slog.String("orderId", orderID))
+
+	return nil
+}
+
+// GetBalances возвращает балансы spot кошелька
+func (s *SpotClient) GetBalances(ctx context.Context) ([]models.SpotBalance, error) {
+	var balances []models.SpotBalance
+	if err := s.c.getPublic(ctx, "SpotGetBalances", s.c.baseURL+spotBalancesEndpoint, &balances); err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+// GetBalance возвращает spot баланс валюты currency (нулевой, если валюты нет в кошельке)
+func (s *SpotClient) GetBalance(ctx context.Context, currency string) (models.SpotBalance, error) {
+	balances, err := s.GetBalances(ctx)
+	if err != nil {
+		return models.SpotBalance{}, err
+	}
+
+	for _, balance := range balances {
+		if balance.Currency == currency {
+			return balance, nil
+		}
+	}
+
+	return models.SpotBalance{Currency: currency}, nil
+}
+
+func validateSpotOrder(req models.SpotOrderRequest) error {
+	if req.Symbol == "" {
+		return fmt.Errorf("spot order: symbol is required")
+	}
+	if req.Side != SpotSideBuy && req.Side != SpotSideSell {
+		return fmt.Errorf("spot order: invalid side %q", req.Side)
+	}
+
+	switch req.OrderType {
+	case SpotOrderTypeLimit:
+		if req.Price == "" || req.Quantity == "" {
+			return fmt.Errorf("spot order: limit order needs price and quantity")
+		}
+	case SpotOrderTypeMarket:
+		if req.Quantity == "" && (req.Side == SpotSideSell || req.Amount == "") {
+			return fmt.Errorf("spot order: market order needs quantity (or amount for buy)")
+		}
+	default:
+		return fmt.Errorf("spot order: invalid order type %q", req.OrderType)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package mexc
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"tg_mexc/internal/models"
+)
+
+// serverTimeEndpoint - ping фьючерсного API: data - время сервера в миллисекундах
+const serverTimeEndpoint = "/api/platform/futures/api/v1/contract/ping"
+
+// timeSyncWarnOffset - расхождение часов, о котором стоит предупредить: подписи с таким
+// смещением биржа отклоняет, пока смещение не применено
+const timeSyncWarnOffset = time.Second
+
+// serverOffset - смещение времени сервера MEXC относительно локальных часов, нс.
+// Общее для процесса: часы хоста одни на все аккаунты
+var serverOffset atomic.Int64
+
+// ServerTimeOffset возвращает текущее смещение времени сервера MEXC относительно локальных часов
+func ServerTimeOffset() time.Duration {
+	return time.Duration(serverOffset.Load())
+}
+
+// timestamp - время запроса для подписи и x-mxc-nonce: часы клиента с поправкой на время сервера
+func (c *Client) timestamp() int64 {
+	return c.clock.Now().Add(ServerTimeOffset()).UnixMilli()
+}
+
+// GetServerTime возвращает время сервера MEXC
+func (c *Client) GetServerTime(ctx context.Context) (time.Time, error) {
+	var ms int64
+	if err := c.getPublic(ctx, "GetServerTime", c.baseURL+serverTimeEndpoint, &ms); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.UnixMilli(ms), nil
+}
+
+// SyncServerTime запрашивает время сервера и обновляет смещение подписей.
+// Время ответа считается серединой запроса (половина RTT)
+func SyncServerTime(ctx context.Context, logger *slog.Logger) (time.Duration, error) {
+	client, err := NewClient(models.Account{Name: "timesync"}, logger)
+	if err != nil {
+		return 0, err
+	}
+	defer client.httpClient.CloseIdleConnections()
+
+	sent := time.Now()
+	serverTime, err := client.GetServerTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+
+	offset := serverTime.Sub(sent.Add(received.Sub(sent) / 2))
+	serverOffset.Store(int64(offset))
+
+	return offset, nil
+}
+
+// RunTimeSync синхронизирует смещение времени сервера сразу и затем каждые interval до отмены ctx
+// (interval <= 0 отключает). Ошибка синхронизации оставляет прежнее смещение
+func RunTimeSync(ctx context.Context, interval time.Duration, logger *slog.Logger) {
+	if interval <= 0 {
+		logger.Info("Server time sync disabled")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		offset, err := SyncServerTime(ctx, logger)
+		switch {
+		case err != nil:
+			logger.Warn("Server time sync failed", slog.Any("error", err))
+		case offset.Abs() >= timeSyncWarnOffset:
+			logger.Warn("Local clock drifts from MEXC server time, signatures use the adjusted time",
+				slog.Duration("offset", offset))
+		default:
+			logger.Debug("Server time synced", slog.Duration("offset", offset))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
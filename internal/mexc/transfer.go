@@ -0,0 +1,68 @@
+package mexc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"tg_mexc/internal/models"
+)
+
+const transferEndpoint = "/api/platform/asset/transfer"
+
+// Кошельки аккаунта для внутреннего перевода
+const (
+	WalletSpot    = "SPOT"
+	WalletFutures = "FUTURES"
+)
+
+// TransferAsset переводит amount валюты currency между кошельками аккаунта (WalletSpot, WalletFutures)
+// и возвращает ID перевода. Перевод не повторяется автоматически: при ошибке соединения
+// результат нужно проверить по балансам
+func (c *Client) TransferAsset(ctx context.Context, from, to, currency string, amount float64) (string, error) {
+	if err := validateTransfer(from, to, currency, amount); err != nil {
+		return "", err
+	}
+
+	req := models.TransferRequest{
+		FromAccountType: from,
+		ToAccountType:   to,
+		Currency:        currency,
+		Amount:          strconv.FormatFloat(amount, 'f', -1, 64),
+	}
+
+	var transferID string
+	if err := c.postSigned(ctx, "TransferAsset", transferEndpoint, req, &transferID); err != nil {
+		return "", err
+	}
+
+	c.logger.Info("✅ TransferAsset success",
+		slog.String("account", c.account.Name),
+		slog.String("from", from),
+		slog.String("to", to),
+		slog.String("currency", currency),
+		slog.Float64("amount", amount),
+		slog.String("transferId", transferID))
+
+	return transferID, nil
+}
+
+func validateTransfer(from, to, currency string, amount float64) error {
+	for _, wallet := range []string{from, to} {
+		if wallet != WalletSpot && wallet != WalletFutures {
+			return fmt.Errorf("transfer: invalid wallet %q", wallet)
+		}
+	}
+	if from == to {
+		return fmt.Errorf("transfer: source and destination wallets are the same")
+	}
+	if currency == "" {
+		return fmt.Errorf("transfer: currency is required")
+	}
+	if amount <= 0 {
+		return fmt.Errorf("transfer: invalid amount %v", amount)
+	}
+
+	return nil
+}
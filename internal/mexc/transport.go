@@ -0,0 +1,36 @@
+package mexc
+
+import (
+	"sync"
+
+	"tg_mexc/internal/httpmiddleware"
+)
+
+var (
+	transportMu  sync.RWMutex
+	transportCfg = httpmiddleware.DefaultTransportConfig()
+)
+
+// connStats - статистика соединений всех MEXC клиентов процесса (переиспользование, TLS handshakes)
+var connStats httpmiddleware.ConnStats
+
+// SetTransportConfig задает настройки соединений новых клиентов: keep-alive соединений на хост,
+// HTTP/2 и кэш TLS сессий. Вызывается при старте приложения, до создания клиентов
+func SetTransportConfig(cfg httpmiddleware.TransportConfig) {
+	transportMu.Lock()
+	defer transportMu.Unlock()
+
+	transportCfg = cfg
+}
+
+func transportConfig() httpmiddleware.TransportConfig {
+	transportMu.RLock()
+	defer transportMu.RUnlock()
+
+	return transportCfg
+}
+
+// TransportStats возвращает статистику соединений MEXC клиентов с момента старта
+func TransportStats() httpmiddleware.ConnStatsSnapshot {
+	return connStats.Snapshot()
+}
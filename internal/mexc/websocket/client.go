@@ -1,28 +1,125 @@
 package websocket
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"tg_mexc/internal/clock"
+	"tg_mexc/internal/mexc"
 	"tg_mexc/internal/models"
 
 	"github.com/gorilla/websocket"
 )
 
 const (
-	wsURL = "wss://contract.mexc.com/edge"
+	// DefaultStopOrderMatchWindow - окно матчинга order и stop order событий по умолчанию
+	DefaultStopOrderMatchWindow = 1 * time.Second
+
+	// pingInterval - период ping сообщений
+	pingInterval = 15 * time.Second
+
+	// DefaultPongTimeout - окно ожидания pong по умолчанию (три ping)
+	DefaultPongTimeout = 3 * pingInterval
+
+	// loginTimeout - сколько Connect ждет подтверждения login (rs.login)
+	loginTimeout = 10 * time.Second
 )
 
+// ErrAuthFailed - биржа отклонила авторизацию WebSocket (токен аккаунта истек)
+var ErrAuthFailed = errors.New("websocket authentication failed")
+
+// LoginError - биржа отклонила login (rs.login не success или rs.error до login): токен аккаунта
+// истек или неверен. errors.Is(err, ErrAuthFailed) для него true
+type LoginError struct {
+	Channel string // rs.login или rs.error
+	Code    int    // Код ошибки MEXC, 0 - биржа не передала
+	Message string // Текст ошибки MEXC
+}
+
+func (e *LoginError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("%s: %s (code %d)", ErrAuthFailed, e.Message, e.Code)
+	}
+	return fmt.Sprintf("%s: %s", ErrAuthFailed, e.Message)
+}
+
+func (e *LoginError) Unwrap() error {
+	return ErrAuthFailed
+}
+
+// parseLoginError разбирает ответ биржи на login: строка ("token is invalid") или объект {code, msg}
+func parseLoginError(channel string, data json.RawMessage) *LoginError {
+	loginErr := &LoginError{Channel: channel}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		loginErr.Message = text
+		return loginErr
+	}
+
+	var payload struct {
+		Code    int    `json:"code"`
+		Msg     string `json:"msg"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &payload); err == nil && (payload.Code != 0 || payload.Msg != "" || payload.Message != "") {
+		loginErr.Code = payload.Code
+		loginErr.Message = cmp.Or(payload.Msg, payload.Message)
+		return loginErr
+	}
+
+	loginErr.Message = string(data)
+	return loginErr
+}
+
+// ErrStaleConnection - за окно ожидания не пришел pong: соединение полуоткрыто и закрыто watchdog
+var ErrStaleConnection = errors.New("websocket connection is stale: no pong received")
+
+// pongTimeout - окно ожидания pong для новых клиентов (SetPongTimeout), нс
+var pongTimeout atomic.Int64
+
+// stopOrderMatchWindow - окно матчинга order и stop order событий для новых клиентов, нс
+var stopOrderMatchWindow atomic.Int64
+
+func init() {
+	pongTimeout.Store(int64(DefaultPongTimeout))
+	stopOrderMatchWindow.Store(int64(DefaultStopOrderMatchWindow))
+}
+
+// SetStopOrderMatchWindow задает окно матчинга order и stop order событий для новых клиентов
+// (window <= 0 отключает матчинг). Вызывается при старте приложения; для отдельного клиента -
+// Client.SetStopOrderMatchWindow
+func SetStopOrderMatchWindow(window time.Duration) {
+	stopOrderMatchWindow.Store(int64(window))
+}
+
+// StopOrderMatchWindow возвращает окно матчинга order и stop order событий для новых клиентов
+func StopOrderMatchWindow() time.Duration {
+	return time.Duration(stopOrderMatchWindow.Load())
+}
+
+// SetPongTimeout задает окно ожидания pong для новых клиентов: если за timeout после последнего pong
+// ответа нет, соединение закрывается и переподключается (timeout <= 0 отключает watchdog).
+// Вызывается при старте приложения
+func SetPongTimeout(timeout time.Duration) {
+	pongTimeout.Store(int64(timeout))
+}
+
 type Message struct {
 	Method  string          `json:"method,omitempty"`
 	Channel string          `json:"channel,omitempty"`
 	Param   json.RawMessage `json:"param,omitempty"`
 	Data    json.RawMessage `json:"data,omitempty"`
+	Ts      int64           `json:"ts,omitempty"`   // Время события на бирже, мс
+	Gzip    bool            `json:"gzip,omitempty"` // Запрос: присылать данные сжатыми gzip (SetCompression)
 }
 
 type LoginParam struct {
@@ -36,8 +133,10 @@ type OrderEvent struct {
 	Price        float64 `json:"price"`
 	Vol          float64 `json:"vol"`
 	Leverage     int     `json:"leverage"`
-	Side         int     `json:"side"` // 1 open long, 2 close short, 3 open short, 4 close long
-	State        int     `json:"state"`
+	OpenType     int     `json:"openType"`  // 1 isolated, 2 cross
+	Side         int     `json:"side"`      // 1 open long, 2 close short, 3 open short, 4 close long
+	OrderType    int     `json:"orderType"` // 1 limit, 2 post only, 5 market (mexc.OrderType*)
+	State        int     `json:"state"`     // 2 uncompleted, 3 completed, 4 cancelled, 5 invalid`
 	DealVol      float64 `json:"dealVol"`
 	DealAvgPrice float64 `json:"dealAvgPrice"`
 	Profit       float64 `json:"profit"`
@@ -63,6 +162,44 @@ type PositionEvent struct {
 	Realised        float64 `json:"realised"`
 }
 
+// DealEvent - исполнение (fill) ордера, содержит реализованный PnL и комиссию
+type DealEvent struct {
+	ID          string  `json:"id"`
+	OrderID     string  `json:"orderId"`
+	Symbol      string  `json:"symbol"`
+	Side        int     `json:"side"` // 1 open long, 2 close short, 3 open short, 4 close long
+	Vol         float64 `json:"vol"`
+	Price       float64 `json:"price"`
+	Fee         float64 `json:"fee"`
+	FeeCurrency string  `json:"feeCurrency"`
+	Profit      float64 `json:"profit"`
+	Taker       bool    `json:"taker"`
+	Timestamp   int64   `json:"timestamp"`
+}
+
+// AssetEvent - состояние фьючерсного счета аккаунта по валюте (push.personal.asset): приходит при
+// каждом изменении маржи и баланса
+type AssetEvent struct {
+	Currency         string  `json:"currency"`
+	PositionMargin   float64 `json:"positionMargin"`   // Маржа открытых позиций
+	FrozenBalance    float64 `json:"frozenBalance"`    // Заморожено под ордера
+	AvailableBalance float64 `json:"availableBalance"` // Доступно для новых позиций
+	CashBalance      float64 `json:"cashBalance"`
+	Equity           float64 `json:"equity"` // 0 - биржа не передала
+	Unrealized       float64 `json:"unrealized"`
+	Bonus            float64 `json:"bonus"`
+}
+
+// LiquidateRiskEvent - позиция аккаунта приближается к ликвидации (push.personal.liquidate.risk)
+type LiquidateRiskEvent struct {
+	Symbol         string  `json:"symbol"`
+	PositionID     int64   `json:"positionId"`
+	PositionType   int     `json:"positionType"` // 1 long, 2 short
+	LiquidatePrice float64 `json:"liquidatePrice"`
+	MarginRatio    float64 `json:"marginRatio"`
+	AdlLevel       int     `json:"adlLevel"`
+}
+
 type StopOrderEvent struct {
 	Symbol          string  `json:"symbol"`
 	OrderID         string  `json:"orderId"`
@@ -85,40 +222,146 @@ type StopPlanOrderEvent struct {
 
 type EventHandler func(event any)
 
+// CloseHandler вызывается, когда соединение закрыто не через Disconnect и переподключиться не удалось
+type CloseHandler func(err error)
+
+// State - состояние соединения для StateHandler
+type State string
+
+const (
+	StateConnected    State = "connected"    // Переподключение удалось, login отправлен заново
+	StateReconnecting State = "reconnecting" // Соединение разорвано, идут попытки переподключения
+	StateDisconnected State = "disconnected" // Переподключение не удалось или биржа отклонила авторизацию
+)
+
+// StateHandler вызывается при смене состояния соединения после первого Connect; err - причина разрыва
+type StateHandler func(state State, err error)
+
+// ReconnectConfig - переподключение после разрыва соединения
+type ReconnectConfig struct {
+	MaxAttempts int           // Попыток подряд до остановки клиента (0 - без переподключения)
+	BaseDelay   time.Duration // Пауза перед первой попыткой, удваивается для каждой следующей
+	MaxDelay    time.Duration // Предел паузы
+}
+
+// DefaultReconnectConfig - 10 попыток с паузой от 1 секунды до минуты (около 5 минут всего)
+func DefaultReconnectConfig() ReconnectConfig {
+	return ReconnectConfig{
+		MaxAttempts: 10,
+		BaseDelay:   time.Second,
+		MaxDelay:    time.Minute,
+	}
+}
+
+// delay - пауза перед попыткой attempt (с 1)
+func (cfg ReconnectConfig) delay(attempt int) time.Duration {
+	delay := cfg.BaseDelay << (attempt - 1)
+	if cfg.MaxDelay > 0 && (delay > cfg.MaxDelay || delay <= 0) {
+		delay = cfg.MaxDelay
+	}
+
+	return delay
+}
+
+// pendingStop - stop order, пришедший раньше своего order события
+type pendingStop struct {
+	stop  StopOrderEvent
+	ts    int64
+	timer clock.Timer
+}
+
 type pendingOrder struct {
 	order      OrderEvent
-	timer      *time.Timer
+	ts         int64 // Время события на бирже (Message.Ts) для метрик
+	timer      clock.Timer
 	cancelFunc context.CancelFunc
 }
 
 type Client struct {
 	account models.Account
+	url     string
 	conn    *websocket.Conn
 	logger  *slog.Logger
+	clock   clock.Clock
 
 	orderHandler         EventHandler
 	positionHandler      EventHandler
 	stopOrderHandler     EventHandler
 	stopPlanOrderHandler EventHandler
+	dealHandler          EventHandler
+	assetHandler         EventHandler
+	liquidateRiskHandler EventHandler
+	tickerHandler        EventHandler
+	fairPriceHandler     EventHandler
+	closeHandler         CloseHandler
+	stateHandler         StateHandler
+
+	reconnect      ReconnectConfig
+	pongTimeout    time.Duration // 0 - без watchdog
+	matchWindow    time.Duration // Окно матчинга order и stop order, 0 - без матчинга
+	compression    bool          // permessage-deflate и gzip данных MEXC
+	personalFilter bool          // personal.filter: только приватные каналы с обработчиками
+	queueConfig    EventQueueConfig
+
+	events atomic.Pointer[eventQueue] // Очередь обработчиков текущего Connect
+
+	lastPong atomic.Int64 // Время последнего pong текущего соединения, unix нс
+	health   clientHealth // Задержки ping/pong и событий (Health)
+
+	authenticated bool  // Биржа подтвердила login (только горутина чтения)
+	fatal         error // Причина закрытия соединения (только горутина чтения)
+
+	loginAck    chan error  // Ответ на login для Connect: nil - rs.login success (создается в Connect)
+	established atomic.Bool // Биржа подтвердила login после Connect: разрывы переподключаются и уходят в обработчики
+
+	writeMu sync.Mutex // gorilla/websocket допускает одного писателя: login и ping
 
 	// Для матчинга событий
 	pendingOrders map[string]*pendingOrder
+	pendingStops  map[string]*pendingStop
 	pendingMu     sync.Mutex
 
-	done   chan struct{}
-	mu     sync.Mutex
-	active bool
+	// Публичные каналы цен (prices.go)
+	subscriptions map[subscription]struct{}
+	subsMu        sync.Mutex
+	prices        map[string]Price
+	pricesMu      sync.RWMutex
+
+	cancel    context.CancelFunc // Останавливает горутины клиента (Disconnect)
+	stopAfter func() bool        // Отменяет Disconnect по отмене контекста Connect
+	mu        sync.Mutex
+	active    bool
 }
 
 func New(account models.Account, logger *slog.Logger) *Client {
 	return &Client{
-		account:       account,
-		logger:        logger,
-		done:          make(chan struct{}),
-		pendingOrders: make(map[string]*pendingOrder),
+		account:        account,
+		url:            mexc.WSURL(),
+		logger:         logger,
+		clock:          clock.Real,
+		reconnect:      DefaultReconnectConfig(),
+		pongTimeout:    time.Duration(pongTimeout.Load()),
+		matchWindow:    time.Duration(stopOrderMatchWindow.Load()),
+		compression:    compression.Load(),
+		personalFilter: personalFilter.Load(),
+		queueConfig:    processEventQueue(),
+		pendingOrders:  make(map[string]*pendingOrder),
+		pendingStops:   make(map[string]*pendingStop),
+		subscriptions:  make(map[subscription]struct{}),
+		prices:         make(map[string]Price),
 	}
 }
 
+// SetURL направляет клиент на другой адрес WebSocket (до Connect)
+func (c *Client) SetURL(wsURL string) {
+	c.url = wsURL
+}
+
+// SetClock подменяет источник времени (для таймеров матчинга событий)
+func (c *Client) SetClock(clk clock.Clock) {
+	c.clock = clk
+}
+
 func (c *Client) SetOrderHandler(handler EventHandler) {
 	c.orderHandler = handler
 }
@@ -135,47 +378,156 @@ func (c *Client) SetStopPlanOrderHandler(handler EventHandler) {
 	c.stopPlanOrderHandler = handler
 }
 
-func (c *Client) Connect() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Client) SetDealHandler(handler EventHandler) {
+	c.dealHandler = handler
+}
+
+// SetAssetHandler задает обработчик изменений счета аккаунта (AssetEvent)
+func (c *Client) SetAssetHandler(handler EventHandler) {
+	c.assetHandler = handler
+}
+
+// SetLiquidateRiskHandler задает обработчик предупреждений о риске ликвидации (LiquidateRiskEvent)
+func (c *Client) SetLiquidateRiskHandler(handler EventHandler) {
+	c.liquidateRiskHandler = handler
+}
+
+// SetCloseHandler задает обработчик окончательного разрыва соединения
+// (переподключение не удалось, отказ в авторизации)
+func (c *Client) SetCloseHandler(handler CloseHandler) {
+	c.closeHandler = handler
+}
+
+// SetStateHandler задает обработчик смены состояния соединения (разрыв, переподключение, остановка)
+func (c *Client) SetStateHandler(handler StateHandler) {
+	c.stateHandler = handler
+}
+
+// SetStopOrderMatchWindow задает окно матчинга order и stop order событий клиента (до Connect):
+// order ждет свой stop order столько же, сколько stop order ждет order. window <= 0 - события
+// отправляются обработчикам сразу, без матчинга
+func (c *Client) SetStopOrderMatchWindow(window time.Duration) {
+	c.matchWindow = max(window, 0)
+}
+
+// SetReconnectConfig задает переподключение после разрыва (до Connect)
+func (c *Client) SetReconnectConfig(cfg ReconnectConfig) {
+	c.reconnect = cfg
+}
 
+// Connect подключается, отправляет login и ждет его подтверждения (rs.login): ошибка - биржа
+// отклонила авторизацию (ErrAuthFailed), соединение разорвано, истек loginTimeout или отменен ctx.
+// ctx задает время жизни клиента: его отмена закрывает соединение так же, как Disconnect
+func (c *Client) Connect(ctx context.Context) error {
+	c.mu.Lock()
 	if c.active {
+		c.mu.Unlock()
 		return fmt.Errorf("already connected")
 	}
 
 	c.logger.Info("Connecting to WebSocket", slog.String("account", c.account.Name))
 
-	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	conn, err := c.dial(ctx)
 	if err != nil {
-		return fmt.Errorf("dial error: %w", err)
+		c.mu.Unlock()
+		return err
 	}
 
+	runCtx, cancel := context.WithCancel(ctx)
 	c.conn = conn
 	c.active = true
-	c.done = make(chan struct{})
-
-	go c.readMessages()
-	go c.sendPings()
+	c.cancel = cancel
+	c.stopAfter = context.AfterFunc(ctx, func() {
+		c.Disconnect()
+	})
+	c.loginAck = make(chan error, 1)
+	c.established.Store(false)
+	c.health.reset()
+	c.startEvents(runCtx)
+	c.mu.Unlock()
 
-	time.Sleep(500 * time.Millisecond)
+	go c.run(runCtx, conn)
 
-	if err := c.login(); err != nil {
+	if err := c.login(conn); err != nil {
 		return errors.Join(fmt.Errorf("login error: %w", err), c.Disconnect())
 	}
 
+	if err := c.awaitLogin(runCtx); err != nil {
+		return errors.Join(err, c.Disconnect())
+	}
+
+	if err := c.resubscribe(conn); err != nil {
+		return errors.Join(fmt.Errorf("subscribe error: %w", err), c.Disconnect())
+	}
+
+	metrics.connects.Add(1)
+
 	return nil
 }
 
+// awaitLogin ждет ответа биржи на login
+func (c *Client) awaitLogin(ctx context.Context) error {
+	select {
+	case err := <-c.loginAck:
+		if err != nil {
+			return fmt.Errorf("login error: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("login error: %w", context.Cause(ctx))
+	case <-c.clock.After(loginTimeout):
+		return fmt.Errorf("login error: no response in %s", loginTimeout)
+	}
+}
+
+// ackLogin передает Connect ответ на login (или разрыв до него); повторные ответы после
+// переподключений никто не ждет и они отбрасываются
+func (c *Client) ackLogin(err error) {
+	select {
+	case c.loginAck <- err:
+	default:
+	}
+}
+
+// dial открывает соединение через тот же прокси, что у REST клиента аккаунта (http или socks5 с авторизацией)
+func (c *Client) dial(ctx context.Context) (*websocket.Conn, error) {
+	dialer := *websocket.DefaultDialer
+	dialer.EnableCompression = c.compression
+	proxyURL, err := mexc.AccountProxy(c.account)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	if proxyURL != nil {
+		proxyDialer(&dialer, proxyURL)
+	}
+
+	conn, _, err := dialer.DialContext(ctx, c.url, nil)
+	if err != nil {
+		if proxyURL != nil {
+			mexc.ReportProxyError(c.account, proxyURL, err)
+		}
+		return nil, fmt.Errorf("dial error: %w", err)
+	}
+
+	return conn, nil
+}
+
 func (c *Client) Disconnect() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	return c.disconnectLocked()
+}
+
+// disconnectLocked закрывает соединение и останавливает клиент (c.mu захвачен)
+func (c *Client) disconnectLocked() error {
 	if !c.active {
 		return nil
 	}
 
 	c.active = false
-	close(c.done)
+	c.cancel()
+	c.stopAfter()
 
 	if c.conn != nil {
 		c.conn.WriteMessage(
@@ -198,7 +550,7 @@ func (c *Client) IsActive() bool {
 	return c.active
 }
 
-func (c *Client) login() error {
+func (c *Client) login(conn *websocket.Conn) error {
 	loginParam := LoginParam{
 		Token: c.account.Token,
 	}
@@ -207,31 +559,181 @@ func (c *Client) login() error {
 	loginMsg := Message{
 		Method: "login",
 		Param:  paramJSON,
+		Gzip:   c.compression,
 	}
 
 	c.logger.Info("Authenticating WebSocket", slog.String("account", c.account.Name))
 
-	return c.conn.WriteJSON(loginMsg)
+	return c.writeJSON(conn, loginMsg)
 }
 
-func (c *Client) readMessages() {
-	defer func() {
-		if err := c.Disconnect(); err != nil {
-			c.logger.Error("WebSocket disconnect error", slog.Any("error", err))
+func (c *Client) writeJSON(conn *websocket.Conn, v any) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return conn.WriteJSON(v)
+}
+
+// run читает соединение и после разрыва переподключается, пока клиент не остановлен.
+// Обработчики событий и ожидающие stop order ордера живут в клиенте и переживают переподключение
+func (c *Client) run(ctx context.Context, conn *websocket.Conn) {
+	for conn != nil {
+		metrics.connections.Add(1)
+		cause := c.readMessages(ctx, conn)
+		metrics.connections.Add(-1)
+		if cause == nil {
+			return // Соединение закрыто через Disconnect
 		}
-	}()
+
+		if errors.Is(cause, ErrAuthFailed) {
+			metrics.authFailures.Add(1)
+		}
+
+		// Разрыв до подтверждения login: ошибку вернет Connect, обработчики не вызываются
+		if !c.established.Load() {
+			c.ackLogin(cause)
+			return
+		}
+
+		metrics.drops.Add(1)
+		if errors.Is(cause, ErrStaleConnection) {
+			metrics.staleCloses.Add(1)
+		}
+
+		if errors.Is(cause, ErrAuthFailed) || c.reconnect.MaxAttempts <= 0 {
+			c.stop(cause)
+			return
+		}
+
+		conn = c.redial(ctx, cause)
+	}
+}
+
+// redial переподключается с экспоненциальной паузой и заново отправляет login.
+// Возвращает новое соединение или nil, если клиент остановлен
+func (c *Client) redial(ctx context.Context, cause error) *websocket.Conn {
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	c.mu.Unlock()
+
+	c.notifyState(StateReconnecting, cause)
+
+	for attempt := 1; attempt <= c.reconnect.MaxAttempts; attempt++ {
+		delay := c.reconnect.delay(attempt)
+
+		c.logger.Warn("WebSocket reconnecting",
+			slog.String("account", c.account.Name),
+			slog.Int("attempt", attempt),
+			slog.Duration("delay", delay),
+			slog.Any("cause", cause))
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.clock.After(delay):
+		}
+
+		conn, err := c.dial(ctx)
+		if err != nil {
+			cause = err
+			continue
+		}
+
+		c.mu.Lock()
+		if !c.active {
+			c.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		c.conn = conn
+		c.mu.Unlock()
+
+		if err := c.login(conn); err != nil {
+			cause = fmt.Errorf("login error: %w", err)
+			conn.Close()
+			continue
+		}
+
+		if err := c.resubscribe(conn); err != nil {
+			cause = fmt.Errorf("subscribe error: %w", err)
+			conn.Close()
+			continue
+		}
+
+		metrics.reconnects.Add(1)
+		c.logger.Info("✅ WebSocket reconnected",
+			slog.String("account", c.account.Name),
+			slog.Int("attempt", attempt))
+		c.notifyState(StateConnected, nil)
+
+		return conn
+	}
+
+	c.stop(fmt.Errorf("websocket reconnect failed after %d attempts: %w", c.reconnect.MaxAttempts, cause))
+
+	return nil
+}
+
+// stop окончательно останавливает клиент после разрыва: Disconnect, StateDisconnected и CloseHandler
+func (c *Client) stop(cause error) {
+	if err := c.Disconnect(); err != nil {
+		c.logger.Error("WebSocket disconnect error", slog.Any("error", err))
+	}
+
+	c.notifyState(StateDisconnected, cause)
+
+	if c.closeHandler != nil {
+		go c.closeHandler(cause)
+	}
+}
+
+func (c *Client) notifyState(state State, err error) {
+	if c.stateHandler != nil {
+		go c.stateHandler(state, err)
+	}
+}
+
+// readMessages читает соединение до разрыва и возвращает его причину (nil - закрыто через Disconnect)
+func (c *Client) readMessages(ctx context.Context, conn *websocket.Conn) error {
+	pingDone := make(chan struct{})
+	defer close(pingDone)
+
+	var stale atomic.Bool
+	c.lastPong.Store(time.Now().UnixNano())
+	go c.sendPings(ctx, conn, pingDone, &stale)
+
+	c.authenticated = false
+	c.fatal = nil
 
 	for {
 		select {
-		case <-c.done:
-			return
+		case <-ctx.Done():
+			return nil
 		default:
 		}
 
-		_, message, err := c.conn.ReadMessage()
+		_, frame, err := conn.ReadMessage()
 		if err != nil {
-			c.logger.Error("WebSocket read error", slog.Any("error", err))
-			return
+			select {
+			case <-ctx.Done():
+				// Соединение закрыто через Disconnect
+				return nil
+			default:
+				if stale.Load() {
+					return ErrStaleConnection
+				}
+				c.logger.Error("WebSocket read error", slog.Any("error", err))
+				return fmt.Errorf("websocket read error: %w", err)
+			}
+		}
+
+		message, err := decodeFrame(frame)
+		if err != nil {
+			c.logger.Error("Failed to decode WebSocket message", slog.Any("error", err))
+			continue
 		}
 
 		c.logger.Debug("📥 WebSocket READ", slog.String("raw", string(message)))
@@ -246,7 +748,15 @@ func (c *Client) readMessages() {
 			continue
 		}
 
+		metrics.message(msg.Channel)
+		recordFrame(c.account.ID, msg)
+		if strings.HasPrefix(msg.Channel, recordedChannelPrefix) {
+			c.health.event(msg.Ts, time.Now())
+		}
 		c.handleMessage(msg)
+		if c.fatal != nil {
+			return c.fatal
+		}
 	}
 }
 
@@ -254,8 +764,32 @@ func (c *Client) readMessages() {
 func (c *Client) handleMessage(msg Message) {
 	switch msg.Channel {
 	case "rs.login":
+		if string(msg.Data) != `"success"` {
+			loginErr := parseLoginError(msg.Channel, msg.Data)
+			c.logger.Error("WebSocket login rejected",
+				slog.String("account", c.account.Name),
+				slog.Int("code", loginErr.Code),
+				slog.String("message", loginErr.Message))
+			c.fatal = loginErr
+			c.ackLogin(loginErr)
+			return
+		}
+
+		c.authenticated = true
+		c.established.Store(true)
+		c.ackLogin(nil)
 		c.logger.Info("✅ WebSocket authenticated")
 
+	case "rs.error":
+		c.logger.Error("WebSocket error", slog.String("data", string(msg.Data)))
+
+		// Ошибка до подтверждения login - отказ в авторизации
+		if !c.authenticated {
+			loginErr := parseLoginError(msg.Channel, msg.Data)
+			c.fatal = loginErr
+			c.ackLogin(loginErr)
+		}
+
 	case "push.personal.order":
 		var order OrderEvent
 		if err := json.Unmarshal(msg.Data, &order); err != nil {
@@ -267,15 +801,20 @@ func (c *Client) handleMessage(msg Message) {
 			return
 		}
 
-		c.handleOrderEventMatching(order)
+		c.handleOrderEventMatching(order, msg.Ts)
 
 	case "push.personal.position":
-		// var pos PositionEvent
-		// if json.Unmarshal(msg.Data, &pos) == nil {
-		// 	if c.positionHandler != nil {
-		// 		c.positionHandler(pos)
-		// 	}
-		// }
+		var pos PositionEvent
+		if err := json.Unmarshal(msg.Data, &pos); err != nil {
+			c.logger.Error("Failed to unmarshal push.personal.position",
+				slog.Any("error", err),
+				slog.String("data", string(msg.Data)),
+			)
+
+			return
+		}
+
+		c.enqueue(c.positionHandler, pos, msg.Ts, coalesceKey("position", pos.PositionID))
 
 	case "push.personal.stop.order":
 		var stop StopOrderEvent
@@ -288,7 +827,7 @@ func (c *Client) handleMessage(msg Message) {
 			return
 		}
 
-		c.handleStopOrderEventMatching(stop)
+		c.handleStopOrderEventMatching(stop, msg.Ts)
 
 	case "push.personal.stop.planorder":
 		var stopPlan StopPlanOrderEvent
@@ -301,11 +840,60 @@ func (c *Client) handleMessage(msg Message) {
 			return
 		}
 
-		if c.stopPlanOrderHandler != nil {
-			c.stopPlanOrderHandler(stopPlan)
+		c.enqueue(c.stopPlanOrderHandler, stopPlan, msg.Ts, "")
+
+	case "push.personal.order.deal":
+		var deal DealEvent
+		if err := json.Unmarshal(msg.Data, &deal); err != nil {
+			c.logger.Error("Failed to unmarshal push.personal.order.deal",
+				slog.Any("error", err),
+				slog.String("data", string(msg.Data)),
+			)
+
+			return
 		}
 
-	case "pong", "push.personal.asset", "push.personal.liquidate.risk", "rs.personal.filter", "rs.sub.order", "rs.sub.position", "push.personal.order.deal":
+		c.enqueue(c.dealHandler, deal, msg.Ts, "")
+
+	case "push.personal.asset":
+		var asset AssetEvent
+		if err := json.Unmarshal(msg.Data, &asset); err != nil {
+			c.logger.Error("Failed to unmarshal push.personal.asset",
+				slog.Any("error", err),
+				slog.String("data", string(msg.Data)),
+			)
+
+			return
+		}
+
+		c.enqueue(c.assetHandler, asset, msg.Ts, "asset:"+asset.Currency)
+
+	case "push.personal.liquidate.risk":
+		var risk LiquidateRiskEvent
+		if err := json.Unmarshal(msg.Data, &risk); err != nil {
+			c.logger.Error("Failed to unmarshal push.personal.liquidate.risk",
+				slog.Any("error", err),
+				slog.String("data", string(msg.Data)),
+			)
+
+			return
+		}
+
+		c.enqueue(c.liquidateRiskHandler, risk, msg.Ts, coalesceKey("risk", risk.PositionID))
+
+	case "push.ticker":
+		c.handleTicker(msg.Data)
+
+	case "push.fair.price":
+		c.handleFairPrice(msg.Data)
+
+	case "pong":
+		now := time.Now()
+		c.lastPong.Store(now.UnixNano())
+		c.health.pong(now)
+
+	case "rs.personal.filter", "rs.sub.order", "rs.sub.position",
+		"rs.sub.ticker", "rs.sub.fair.price", "rs.unsub.ticker", "rs.unsub.fair.price":
 		return
 
 	default:
@@ -313,26 +901,47 @@ func (c *Client) handleMessage(msg Message) {
 	}
 }
 
-// handleOrderEventMatching обрабатывает событие ордера с ожиданием stop order
-func (c *Client) handleOrderEventMatching(order OrderEvent) {
+// handleOrderEventMatching обрабатывает событие ордера с ожиданием stop order.
+// Stop order, пришедший раньше, прикрепляется сразу; иначе order ждет его окно матчинга
+func (c *Client) handleOrderEventMatching(order OrderEvent, ts int64) {
 	c.logger.Debug("📦 Received order event",
 		slog.String("orderId", order.OrderID),
 		slog.String("symbol", order.Symbol),
 		slog.Int("side", order.Side))
 
 	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	// Stop order уже пришел - отправляем составное событие без ожидания
+	if stop, exists := c.pendingStops[order.OrderID]; exists {
+		c.logger.Debug("✅ Matched order with earlier stop order",
+			slog.String("orderId", order.OrderID))
+
+		stop.timer.Stop()
+		delete(c.pendingStops, order.OrderID)
+
+		order.StopOrderEvent = &stop.stop
+		c.dispatchOrder(order, ts)
+		return
+	}
+
+	if c.matchWindow <= 0 {
+		c.dispatchOrder(order, ts)
+		return
+	}
 
 	// Создаем контекст для таймера
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), c.matchWindow)
 
 	// Сохраняем заказ в pending
 	pending := &pendingOrder{
 		order:      order,
+		ts:         ts,
 		cancelFunc: cancel,
 	}
 
-	// Создаем таймер на 1 секунду
-	pending.timer = time.AfterFunc(1*time.Second, func() {
+	// Создаем таймер на окно матчинга
+	pending.timer = c.clock.AfterFunc(c.matchWindow, func() {
 		c.pendingMu.Lock()
 		defer c.pendingMu.Unlock()
 
@@ -346,14 +955,11 @@ func (c *Client) handleOrderEventMatching(order OrderEvent) {
 			p.cancelFunc()
 
 			// Отправляем событие без StopOrderEvent
-			if c.orderHandler != nil {
-				c.orderHandler(p.order)
-			}
+			c.dispatchOrder(p.order, p.ts)
 		}
 	})
 
 	c.pendingOrders[order.OrderID] = pending
-	c.pendingMu.Unlock()
 
 	// Ждем завершения контекста (либо таймаут, либо отмена)
 	go func() {
@@ -361,8 +967,10 @@ func (c *Client) handleOrderEventMatching(order OrderEvent) {
 	}()
 }
 
-// handleStopOrderEventMatching обрабатывает событие стоп-ордера и матчит с order
-func (c *Client) handleStopOrderEventMatching(stop StopOrderEvent) {
+// handleStopOrderEventMatching обрабатывает событие стоп-ордера и матчит с order.
+// Stop order без ожидающего order ждет его окно матчинга (order может прийти позже),
+// затем отправляется отдельным событием
+func (c *Client) handleStopOrderEventMatching(stop StopOrderEvent, ts int64) {
 	c.logger.Debug("🛑 Received stop order event",
 		slog.String("orderId", stop.OrderID),
 		slog.String("symbol", stop.Symbol),
@@ -386,37 +994,84 @@ func (c *Client) handleStopOrderEventMatching(stop StopOrderEvent) {
 		// Удаляем из pending
 		delete(c.pendingOrders, stop.OrderID)
 
-		// Добавляем StopOrderEvent к OrderEvent
+		// Добавляем StopOrderEvent к OrderEvent и отправляем составное событие
 		pending.order.StopOrderEvent = &stop
+		c.dispatchOrder(pending.order, pending.ts)
+		return
+	}
+
+	// Order уже отправлен без stop (окно истекло) или матчинг отключен - отдельный обработчик
+	if c.matchWindow <= 0 || stop.OrderID == "" {
+		c.dispatchStopOrder(stop, ts)
+		return
+	}
+
+	// Order еще не пришел: ждем его окно матчинга
+	c.logger.Debug("⏳ Stop order received before its order, waiting",
+		slog.String("orderId", stop.OrderID))
+
+	if earlier, exists := c.pendingStops[stop.OrderID]; exists {
+		earlier.timer.Stop()
+	}
 
-		// Отправляем составное событие
-		if c.orderHandler != nil {
-			c.orderHandler(pending.order)
+	parked := &pendingStop{stop: stop, ts: ts}
+	parked.timer = c.clock.AfterFunc(c.matchWindow, func() {
+		c.pendingMu.Lock()
+		defer c.pendingMu.Unlock()
+
+		if c.pendingStops[stop.OrderID] != parked {
+			return
 		}
-	} else {
-		// Если нет соответствующего order (пришел раньше или отдельно)
-		c.logger.Debug("⚠️ Stop order received without matching pending order",
+		delete(c.pendingStops, stop.OrderID)
+
+		c.logger.Debug("⚠️ Stop order received without matching order",
 			slog.String("orderId", stop.OrderID))
 
-		// Вызываем отдельный обработчик для stop order
-		if c.stopOrderHandler != nil {
-			c.stopOrderHandler(stop)
-		}
-	}
+		c.dispatchStopOrder(stop, ts)
+	})
+	c.pendingStops[stop.OrderID] = parked
 }
 
-func (c *Client) sendPings() {
-	ticker := time.NewTicker(15 * time.Second)
+// dispatchOrder ставит order событие в очередь обработчиков (c.pendingMu захвачен)
+func (c *Client) dispatchOrder(order OrderEvent, ts int64) {
+	c.enqueue(c.orderHandler, order, ts, "")
+}
+
+// dispatchStopOrder ставит отдельное stop order событие в очередь обработчиков (c.pendingMu захвачен)
+func (c *Client) dispatchStopOrder(stop StopOrderEvent, ts int64) {
+	c.enqueue(c.stopOrderHandler, stop, ts, "")
+}
+
+// sendPings отправляет ping в conn, пока соединение не закрыто (stop) или клиент не остановлен.
+// Watchdog: если pong не приходил дольше pongTimeout, соединение считается полуоткрытым и закрывается -
+// чтение завершается с ErrStaleConnection и клиент переподключается
+func (c *Client) sendPings(ctx context.Context, conn *websocket.Conn, stop <-chan struct{}, stale *atomic.Bool) {
+	ticker := time.NewTicker(pingInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-c.done:
+		case <-ctx.Done():
+			return
+		case <-stop:
 			return
 		case <-ticker.C:
+			if c.pongTimeout > 0 {
+				if silence := time.Since(time.Unix(0, c.lastPong.Load())); silence > c.pongTimeout {
+					c.logger.Warn("No WebSocket pong, closing stale connection",
+						slog.String("account", c.account.Name),
+						slog.Duration("silence", silence))
+
+					stale.Store(true)
+					conn.Close()
+					return
+				}
+			}
+
 			ping := Message{Method: "ping"}
+			c.health.pingSentAt.Store(time.Now().UnixNano())
 
-			if err := c.conn.WriteJSON(ping); err != nil {
+			if err := c.writeJSON(conn, ping); err != nil {
 				c.logger.Error("WebSocket ping error", slog.Any("error", err))
 				return
 			}
@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// compression - сжатие для новых клиентов (SetCompression)
+var compression atomic.Bool
+
+// SetCompression включает сжатие WebSocket для новых клиентов: permessage-deflate на уровне
+// соединения и gzip данных MEXC (поле gzip в login и подписках). Вызывается при старте приложения;
+// для отдельного клиента - Client.SetCompression
+func SetCompression(enabled bool) {
+	compression.Store(enabled)
+}
+
+// SetCompression включает сжатие соединения клиента (до Connect)
+func (c *Client) SetCompression(enabled bool) {
+	c.compression = enabled
+}
+
+// gzipMagic - первые байты gzip потока
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decodeFrame возвращает JSON сообщения: данные по запросу gzip MEXC присылает сжатыми (бинарным
+// фреймом), их узнаем по сигнатуре gzip. permessage-deflate gorilla/websocket распаковывает сам
+func decodeFrame(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("gzip frame: %w", err)
+	}
+	defer reader.Close()
+
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("gzip frame: %w", err)
+	}
+
+	metrics.compressed.Add(1)
+
+	return decoded, nil
+}
@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// personalFilter - фильтр приватных каналов для новых клиентов (SetPersonalFilter)
+var personalFilter atomic.Bool
+
+// SetPersonalFilter включает для новых клиентов фильтр приватных каналов (personal.filter после login):
+// биржа присылает только push.personal.* каналы, на которые у клиента есть обработчики. Вызывается при
+// старте приложения; для отдельного клиента - Client.SetPersonalFilter
+func SetPersonalFilter(enabled bool) {
+	personalFilter.Store(enabled)
+}
+
+// SetPersonalFilter включает фильтр приватных каналов клиента (до Connect)
+func (c *Client) SetPersonalFilter(enabled bool) {
+	c.personalFilter = enabled
+}
+
+type filterParam struct {
+	Filters []filterRule `json:"filters"`
+}
+
+type filterRule struct {
+	Filter string `json:"filter"` // Канал без префикса push.personal. (order, position, ...)
+}
+
+// personalChannels возвращает приватные каналы, которые клиент обрабатывает. Stop order нужен
+// и одному обработчику ордеров: SL склеивается с ордером
+func (c *Client) personalChannels() []string {
+	var channels []string
+	add := func(handler EventHandler, channel string) {
+		if handler != nil {
+			channels = append(channels, channel)
+		}
+	}
+
+	add(c.orderHandler, "order")
+	if c.orderHandler != nil || c.stopOrderHandler != nil {
+		channels = append(channels, "stop.order")
+	}
+	add(c.stopPlanOrderHandler, "stop.planorder")
+	add(c.positionHandler, "position")
+	add(c.dealHandler, "order.deal")
+	add(c.assetHandler, "asset")
+	add(c.liquidateRiskHandler, "liquidate.risk")
+
+	return channels
+}
+
+// sendPersonalFilter отправляет personal.filter с каналами клиента, если фильтр включен.
+// Без приватных обработчиков фильтр не отправляется: пустой список биржа считает «все каналы»
+func (c *Client) sendPersonalFilter(conn *websocket.Conn) error {
+	if !c.personalFilter {
+		return nil
+	}
+
+	channels := c.personalChannels()
+	if len(channels) == 0 {
+		return nil
+	}
+
+	rules := make([]filterRule, 0, len(channels))
+	for _, channel := range channels {
+		rules = append(rules, filterRule{Filter: channel})
+	}
+
+	param, _ := json.Marshal(filterParam{Filters: rules})
+	msg := Message{
+		Method: "personal.filter",
+		Param:  param,
+		Gzip:   c.compression,
+	}
+
+	if err := c.writeJSON(conn, msg); err != nil {
+		return fmt.Errorf("personal.filter: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package websocket
+
+import (
+	"sync/atomic"
+	"time"
+
+	"tg_mexc/internal/mexc"
+)
+
+// SlowFeedThreshold - задержка ping/pong или событий, с которой соединение считается медленным
+// для копирования: сделка мастера доходит до slave позже, чем меняется цена
+const SlowFeedThreshold = time.Second
+
+// Health - задержки соединения клиента с момента Connect
+type Health struct {
+	Connected      bool       `json:"connected"`
+	PingRTTMs      float64    `json:"ping_rtt_ms"` // Последний ping -> pong, 0 - pong еще не было
+	LastPongAt     *time.Time `json:"last_pong_at,omitempty"`
+	Events         int64      `json:"events"`            // Приватных событий с временем биржи
+	LastEventLagMs float64    `json:"last_event_lag_ms"` // Время события на бирже -> получение
+	AvgEventLagMs  float64    `json:"avg_event_lag_ms"`
+	MaxEventLagMs  float64    `json:"max_event_lag_ms"`
+	LastEventAt    *time.Time `json:"last_event_at,omitempty"`
+	Slow           bool       `json:"slow"` // RTT или средняя задержка событий выше SlowFeedThreshold
+}
+
+// clientHealth - счетчики задержек клиента (пишут горутины чтения и ping)
+type clientHealth struct {
+	pingSentAt  atomic.Int64 // unix нс последнего ping
+	pingRTT     atomic.Int64 // нс
+	events      atomic.Int64
+	lagSum      atomic.Int64 // нс
+	lastLag     atomic.Int64 // нс
+	maxLag      atomic.Int64 // нс
+	lastEventAt atomic.Int64 // unix нс
+}
+
+// reset обнуляет счетчики (новый Connect)
+func (h *clientHealth) reset() {
+	h.pingSentAt.Store(0)
+	h.pingRTT.Store(0)
+	h.events.Store(0)
+	h.lagSum.Store(0)
+	h.lastLag.Store(0)
+	h.maxLag.Store(0)
+	h.lastEventAt.Store(0)
+}
+
+// pong учитывает ответ на последний ping
+func (h *clientHealth) pong(now time.Time) {
+	if sent := h.pingSentAt.Load(); sent > 0 {
+		h.pingRTT.Store(now.UnixNano() - sent)
+	}
+}
+
+// event учитывает приватное событие: ts - время события на бирже (мс), сравнивается с часами
+// с поправкой на время сервера. 0 - время неизвестно
+func (h *clientHealth) event(ts int64, now time.Time) {
+	if ts <= 0 {
+		return
+	}
+
+	lag := max(now.Add(mexc.ServerTimeOffset()).Sub(time.UnixMilli(ts)), 0)
+
+	h.events.Add(1)
+	h.lagSum.Add(int64(lag))
+	h.lastLag.Store(int64(lag))
+	h.lastEventAt.Store(now.UnixNano())
+	for {
+		current := h.maxLag.Load()
+		if int64(lag) <= current || h.maxLag.CompareAndSwap(current, int64(lag)) {
+			return
+		}
+	}
+}
+
+// Health возвращает задержки соединения: RTT ping/pong и отставание событий от времени биржи
+func (c *Client) Health() Health {
+	health := Health{
+		Connected:      c.IsActive(),
+		PingRTTMs:      nanosToMs(c.health.pingRTT.Load()),
+		Events:         c.health.events.Load(),
+		LastEventLagMs: nanosToMs(c.health.lastLag.Load()),
+		MaxEventLagMs:  nanosToMs(c.health.maxLag.Load()),
+	}
+
+	if health.Events > 0 {
+		health.AvgEventLagMs = nanosToMs(c.health.lagSum.Load()) / float64(health.Events)
+	}
+	if health.PingRTTMs > 0 {
+		at := time.Unix(0, c.lastPong.Load())
+		health.LastPongAt = &at
+	}
+	if ns := c.health.lastEventAt.Load(); ns > 0 {
+		at := time.Unix(0, ns)
+		health.LastEventAt = &at
+	}
+
+	threshold := nanosToMs(int64(SlowFeedThreshold))
+	health.Slow = health.PingRTTMs > threshold || health.AvgEventLagMs > threshold
+
+	return health
+}
@@ -0,0 +1,137 @@
+package websocket
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"tg_mexc/internal/mexc"
+)
+
+// metrics - статистика WebSocket соединений процесса (все master и slave клиенты)
+var metrics = &wsMetrics{messages: make(map[string]int64)}
+
+type wsMetrics struct {
+	connections   atomic.Int64 // Открытые соединения (gauge)
+	connects      atomic.Int64
+	reconnects    atomic.Int64
+	drops         atomic.Int64
+	staleCloses   atomic.Int64
+	authFailures  atomic.Int64
+	lastMessageAt atomic.Int64 // unix нс
+
+	compressed     atomic.Int64
+	recordedFrames atomic.Int64
+	droppedFrames  atomic.Int64
+
+	queueDropped   atomic.Int64
+	queueCoalesced atomic.Int64
+	queueBlocked   atomic.Int64
+
+	dispatched     atomic.Int64
+	dispatchNanos  atomic.Int64
+	lastDispatchNs atomic.Int64
+	maxDispatchNs  atomic.Int64
+
+	mu       sync.Mutex
+	messages map[string]int64 // channel -> сообщений
+}
+
+// Stats - снимок статистики WebSocket соединений
+type Stats struct {
+	Connections    int64            `json:"connections"`     // Открытые сейчас
+	Connects       int64            `json:"connects"`        // Успешные Connect
+	Reconnects     int64            `json:"reconnects"`      // Успешные переподключения после разрыва
+	Drops          int64            `json:"drops"`           // Разрывы соединения (не через Disconnect)
+	StaleCloses    int64            `json:"stale_closes"`    // Из них закрыто watchdog (нет pong)
+	AuthFailures   int64            `json:"auth_failures"`   // Биржа отклонила login
+	Messages       map[string]int64 `json:"messages"`        // Сообщений по каналам
+	LastMessageAt  *time.Time       `json:"last_message_at"` // Последнее сообщение любого канала
+	Dispatched     int64            `json:"dispatched"`      // Приватных событий передано обработчикам
+	AvgDispatchMs  float64          `json:"avg_dispatch_ms"` // Время события на бирже -> передача обработчику
+	LastDispatchMs float64          `json:"last_dispatch_ms"`
+	MaxDispatchMs  float64          `json:"max_dispatch_ms"`
+	Compressed     int64            `json:"compressed"`      // Из них пришло сжатыми gzip (SetCompression)
+	RecordedFrames int64            `json:"recorded_frames"` // Записано сырых сообщений (SetFrameRecorder)
+	DroppedFrames  int64            `json:"dropped_frames"`  // Не записано: очередь переполнена или ошибка записи
+	QueueDropped   int64            `json:"queue_dropped"`   // Событий отброшено переполненной очередью (drop-oldest)
+	QueueCoalesced int64            `json:"queue_coalesced"` // Событий заменено более новым событием того же объекта (coalesce)
+	QueueBlocked   int64            `json:"queue_blocked"`   // Сколько раз чтение ждало места в очереди
+}
+
+// GetStats возвращает статистику WebSocket соединений процесса с момента старта
+func GetStats() Stats {
+	stats := Stats{
+		Connections:    metrics.connections.Load(),
+		Connects:       metrics.connects.Load(),
+		Reconnects:     metrics.reconnects.Load(),
+		Drops:          metrics.drops.Load(),
+		StaleCloses:    metrics.staleCloses.Load(),
+		AuthFailures:   metrics.authFailures.Load(),
+		Dispatched:     metrics.dispatched.Load(),
+		LastDispatchMs: nanosToMs(metrics.lastDispatchNs.Load()),
+		MaxDispatchMs:  nanosToMs(metrics.maxDispatchNs.Load()),
+		Compressed:     metrics.compressed.Load(),
+		RecordedFrames: metrics.recordedFrames.Load(),
+		DroppedFrames:  metrics.droppedFrames.Load(),
+		QueueDropped:   metrics.queueDropped.Load(),
+		QueueCoalesced: metrics.queueCoalesced.Load(),
+		QueueBlocked:   metrics.queueBlocked.Load(),
+	}
+
+	if stats.Dispatched > 0 {
+		stats.AvgDispatchMs = nanosToMs(metrics.dispatchNanos.Load()) / float64(stats.Dispatched)
+	}
+	if ns := metrics.lastMessageAt.Load(); ns > 0 {
+		at := time.Unix(0, ns)
+		stats.LastMessageAt = &at
+	}
+
+	metrics.mu.Lock()
+	stats.Messages = make(map[string]int64, len(metrics.messages))
+	for channel, count := range metrics.messages {
+		stats.Messages[channel] = count
+	}
+	metrics.mu.Unlock()
+
+	return stats
+}
+
+func nanosToMs(ns int64) float64 {
+	return float64(ns) / float64(time.Millisecond)
+}
+
+// message учитывает сообщение канала
+func (m *wsMetrics) message(channel string) {
+	m.lastMessageAt.Store(time.Now().UnixNano())
+	if channel == "" {
+		return
+	}
+
+	m.mu.Lock()
+	m.messages[channel]++
+	m.mu.Unlock()
+}
+
+// dispatch учитывает передачу приватного события обработчику: ts - время события на бирже (мс,
+// поле ts сообщения), сравнивается с часами с поправкой на время сервера. 0 - время неизвестно
+func (m *wsMetrics) dispatch(ts int64) {
+	if ts <= 0 {
+		return
+	}
+
+	latency := time.Now().Add(mexc.ServerTimeOffset()).Sub(time.UnixMilli(ts))
+	if latency < 0 {
+		latency = 0
+	}
+
+	m.dispatched.Add(1)
+	m.dispatchNanos.Add(int64(latency))
+	m.lastDispatchNs.Store(int64(latency))
+	for {
+		current := m.maxDispatchNs.Load()
+		if int64(latency) <= current || m.maxDispatchNs.CompareAndSwap(current, int64(latency)) {
+			return
+		}
+	}
+}
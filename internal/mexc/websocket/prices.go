@@ -0,0 +1,231 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tg_mexc/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+// Публичные каналы цен: подписка по символу на том же соединении, что и приватные события
+const (
+	ChannelTicker    = "ticker"     // push.ticker: последняя цена, mark price, лучшие bid / ask (models.Ticker)
+	ChannelFairPrice = "fair.price" // push.fair.price: mark price (FairPriceEvent)
+)
+
+// priceMaxAge - цена старше считается устаревшей: ticker приходит примерно раз в секунду
+const priceMaxAge = 10 * time.Second
+
+// FairPriceEvent - mark price символа из push.fair.price
+type FairPriceEvent struct {
+	Symbol string  `json:"symbol"`
+	Price  float64 `json:"price"`
+}
+
+// Price - последние цены символа из публичных каналов
+type Price struct {
+	Last      float64 // Последняя сделка (ticker), 0 - не приходила
+	Fair      float64 // Mark price (ticker или fair.price), 0 - не приходила
+	UpdatedAt time.Time
+}
+
+// Ref возвращает цену ориентир для расчетов: последнюю сделку, иначе mark price
+func (p Price) Ref() float64 {
+	if p.Last > 0 {
+		return p.Last
+	}
+	return p.Fair
+}
+
+// subscription - подписка на публичный канал символа
+type subscription struct {
+	channel string
+	symbol  string
+}
+
+type symbolParam struct {
+	Symbol string `json:"symbol"`
+}
+
+func (c *Client) SetTickerHandler(handler EventHandler) {
+	c.tickerHandler = handler
+}
+
+func (c *Client) SetFairPriceHandler(handler EventHandler) {
+	c.fairPriceHandler = handler
+}
+
+// SubscribeTicker подписывает клиент на ticker символа (ChannelTicker)
+func (c *Client) SubscribeTicker(symbol string) error {
+	return c.Subscribe(ChannelTicker, symbol)
+}
+
+// SubscribeFairPrice подписывает клиент на mark price символа (ChannelFairPrice)
+func (c *Client) SubscribeFairPrice(symbol string) error {
+	return c.Subscribe(ChannelFairPrice, symbol)
+}
+
+// Subscribe подписывает клиент на публичный канал символа. Подписка запоминается и отправляется
+// заново после каждого переподключения; до Connect только запоминается. Повторная подписка ничего не делает
+func (c *Client) Subscribe(channel, symbol string) error {
+	sub := subscription{channel: channel, symbol: symbol}
+
+	c.subsMu.Lock()
+	if _, ok := c.subscriptions[sub]; ok {
+		c.subsMu.Unlock()
+		return nil
+	}
+	c.subscriptions[sub] = struct{}{}
+	c.subsMu.Unlock()
+
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	return c.sendSubscription(conn, "sub", sub)
+}
+
+// Unsubscribe отменяет подписку на публичный канал символа, последняя цена символа забывается
+func (c *Client) Unsubscribe(channel, symbol string) error {
+	sub := subscription{channel: channel, symbol: symbol}
+
+	c.subsMu.Lock()
+	if _, ok := c.subscriptions[sub]; !ok {
+		c.subsMu.Unlock()
+		return nil
+	}
+	delete(c.subscriptions, sub)
+	c.subsMu.Unlock()
+
+	c.pricesMu.Lock()
+	delete(c.prices, symbol)
+	c.pricesMu.Unlock()
+
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+
+	return c.sendSubscription(conn, "unsub", sub)
+}
+
+// Price возвращает последние цены символа; false - подписки нет, цены не приходили или устарели
+func (c *Client) Price(symbol string) (Price, bool) {
+	c.pricesMu.RLock()
+	defer c.pricesMu.RUnlock()
+
+	price, ok := c.prices[symbol]
+	if !ok || c.clock.Now().Sub(price.UpdatedAt) > priceMaxAge {
+		return Price{}, false
+	}
+
+	return price, true
+}
+
+// resubscribe отправляет фильтр приватных каналов и все подписки на новое соединение (после login)
+func (c *Client) resubscribe(conn *websocket.Conn) error {
+	if err := c.sendPersonalFilter(conn); err != nil {
+		return err
+	}
+
+	c.subsMu.Lock()
+	subs := make([]subscription, 0, len(c.subscriptions))
+	for sub := range c.subscriptions {
+		subs = append(subs, sub)
+	}
+	c.subsMu.Unlock()
+
+	for _, sub := range subs {
+		if err := c.sendSubscription(conn, "sub", sub); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendSubscription отправляет sub.<channel> / unsub.<channel> с символом
+func (c *Client) sendSubscription(conn *websocket.Conn, action string, sub subscription) error {
+	param, _ := json.Marshal(symbolParam{Symbol: sub.symbol})
+	msg := Message{
+		Method: action + "." + sub.channel,
+		Param:  param,
+		Gzip:   c.compression,
+	}
+
+	if err := c.writeJSON(conn, msg); err != nil {
+		return fmt.Errorf("%s %s %s: %w", action, sub.channel, sub.symbol, err)
+	}
+
+	return nil
+}
+
+// handleTicker обновляет цены символа из push.ticker
+func (c *Client) handleTicker(data json.RawMessage) {
+	var ticker models.Ticker
+	if err := json.Unmarshal(data, &ticker); err != nil {
+		c.logger.Error("Failed to unmarshal push.ticker",
+			slog.Any("error", err),
+			slog.String("data", string(data)),
+		)
+
+		return
+	}
+
+	c.updatePrice(ticker.Symbol, func(price *Price) {
+		price.Last = ticker.LastPrice
+		if ticker.FairPrice > 0 {
+			price.Fair = ticker.FairPrice
+		}
+	})
+
+	if c.tickerHandler != nil {
+		c.tickerHandler(ticker)
+	}
+}
+
+// handleFairPrice обновляет mark price символа из push.fair.price
+func (c *Client) handleFairPrice(data json.RawMessage) {
+	var fair FairPriceEvent
+	if err := json.Unmarshal(data, &fair); err != nil {
+		c.logger.Error("Failed to unmarshal push.fair.price",
+			slog.Any("error", err),
+			slog.String("data", string(data)),
+		)
+
+		return
+	}
+
+	c.updatePrice(fair.Symbol, func(price *Price) {
+		price.Fair = fair.Price
+	})
+
+	if c.fairPriceHandler != nil {
+		c.fairPriceHandler(fair)
+	}
+}
+
+func (c *Client) updatePrice(symbol string, update func(price *Price)) {
+	if symbol == "" {
+		return
+	}
+
+	c.pricesMu.Lock()
+	defer c.pricesMu.Unlock()
+
+	price := c.prices[symbol]
+	update(&price)
+	price.UpdatedAt = c.clock.Now()
+	c.prices[symbol] = price
+}
@@ -0,0 +1,94 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// proxyDialer настраивает dialer на прокси аккаунта: http (CONNECT) и socks5 dialer поддерживает сам,
+// для https прокси туннель CONNECT открывается поверх TLS соединения с прокси (httpsProxyDial)
+func proxyDialer(dialer *websocket.Dialer, proxyURL *url.URL) {
+	if proxyURL.Scheme == "https" {
+		dialer.NetDialContext = httpsProxyDial(proxyURL)
+		return
+	}
+
+	dialer.Proxy = http.ProxyURL(dialerProxyURL(proxyURL))
+}
+
+// dialerProxyURL приводит прокси к схемам websocket.Dialer: socks5h - это socks5
+// (dialer и так передает прокси имя хоста)
+func dialerProxyURL(proxyURL *url.URL) *url.URL {
+	if proxyURL.Scheme != "socks5h" {
+		return proxyURL
+	}
+
+	converted := *proxyURL
+	converted.Scheme = "socks5"
+
+	return &converted
+}
+
+// httpsProxyDial возвращает функцию соединения через https прокси: TLS до прокси, затем CONNECT addr
+// с Basic авторизацией из user:password@ прокси
+func httpsProxyDial(proxyURL *url.URL) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	proxyAddr := proxyURL.Host
+	if proxyURL.Port() == "" {
+		proxyAddr = net.JoinHostPort(proxyURL.Hostname(), "443")
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		tlsDialer := &tls.Dialer{Config: &tls.Config{ServerName: proxyURL.Hostname()}}
+		conn, err := tlsDialer.DialContext(ctx, network, proxyAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+
+		connectReq := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: addr},
+			Host:   addr,
+			Header: make(http.Header),
+		}
+		if user := proxyURL.User; user != nil {
+			password, _ := user.Password()
+			credential := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+			connectReq.Header.Set("Proxy-Authorization", "Basic "+credential)
+		}
+
+		if err := connectReq.Write(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		// Прокси не пишет в туннель до запроса клиента: буфер reader после ответа пуст
+		resp, err := http.ReadResponse(bufio.NewReader(conn), connectReq)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			conn.Close()
+			return nil, fmt.Errorf("proxy CONNECT: %s", resp.Status)
+		}
+
+		conn.SetDeadline(time.Time{})
+
+		return conn, nil
+	}
+}
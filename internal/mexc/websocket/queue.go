@@ -0,0 +1,216 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy - что делает клиент с новым событием, когда очередь обработчиков заполнена
+type OverflowPolicy string
+
+const (
+	// OverflowBlock - чтение соединения ждет, пока обработчики освободят место (события не теряются)
+	OverflowBlock OverflowPolicy = "block"
+	// OverflowDropOldest - самое старое событие очереди отбрасывается
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+	// OverflowCoalesce - событие состояния (позиция, счет, риск ликвидации) заменяет еще не обработанное
+	// событие того же объекта; ордера и fill'ы не склеиваются и при заполненной очереди ждут, как в block
+	OverflowCoalesce OverflowPolicy = "coalesce"
+)
+
+// ParseOverflowPolicy разбирает политику переполнения (пусто - OverflowBlock)
+func ParseOverflowPolicy(raw string) (OverflowPolicy, error) {
+	switch policy := OverflowPolicy(raw); policy {
+	case "":
+		return OverflowBlock, nil
+	case OverflowBlock, OverflowDropOldest, OverflowCoalesce:
+		return policy, nil
+	}
+
+	return "", fmt.Errorf("unknown overflow policy %q", raw)
+}
+
+// EventQueueConfig - очередь между чтением соединения и обработчиками событий клиента: обработчики
+// вызываются по одному в порядке событий, чтение не ждет копирования
+type EventQueueConfig struct {
+	Size   int            // Событий в очереди (<= 0 - DefaultEventQueueConfig().Size)
+	Policy OverflowPolicy // Поведение при заполненной очереди
+}
+
+// DefaultEventQueueConfig - 1024 события, чтение ждет обработчиков
+func DefaultEventQueueConfig() EventQueueConfig {
+	return EventQueueConfig{
+		Size:   1024,
+		Policy: OverflowBlock,
+	}
+}
+
+// eventQueueConfig - очередь для новых клиентов (SetEventQueue)
+var eventQueueConfig atomic.Pointer[EventQueueConfig]
+
+// SetEventQueue задает очередь событий для новых клиентов. Вызывается при старте приложения;
+// для отдельного клиента - Client.SetEventQueue
+func SetEventQueue(cfg EventQueueConfig) {
+	eventQueueConfig.Store(&cfg)
+}
+
+// processEventQueue возвращает очередь событий для нового клиента
+func processEventQueue() EventQueueConfig {
+	if cfg := eventQueueConfig.Load(); cfg != nil {
+		return *cfg
+	}
+	return DefaultEventQueueConfig()
+}
+
+// SetEventQueue задает очередь событий клиента (до Connect)
+func (c *Client) SetEventQueue(cfg EventQueueConfig) {
+	c.queueConfig = cfg
+}
+
+// queuedEvent - событие, ожидающее обработчика
+type queuedEvent struct {
+	handler EventHandler
+	event   any
+	ts      int64  // Время события на бирже для метрик
+	key     string // Объект события для OverflowCoalesce, пусто - не склеивается
+}
+
+// eventQueue - ограниченная очередь событий клиента с одним обработчиком (dispatchEvents)
+type eventQueue struct {
+	size   int
+	policy OverflowPolicy
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	events []queuedEvent
+	closed bool
+}
+
+func newEventQueue(cfg EventQueueConfig) *eventQueue {
+	if cfg.Size <= 0 {
+		cfg.Size = DefaultEventQueueConfig().Size
+	}
+
+	q := &eventQueue{size: cfg.Size, policy: cfg.Policy}
+	q.cond = sync.NewCond(&q.mu)
+
+	return q
+}
+
+// push ставит событие в очередь по политике переполнения; в закрытую очередь (клиент остановлен)
+// событие не попадает
+func (q *eventQueue) push(ev queuedEvent) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	// Необработанное событие того же объекта устарело: новое встает в конец, чтобы не обогнать
+	// события, пришедшие между ними
+	if q.policy == OverflowCoalesce && ev.key != "" {
+		for i := range q.events {
+			if q.events[i].key == ev.key {
+				q.events = append(q.events[:i], q.events[i+1:]...)
+				metrics.queueCoalesced.Add(1)
+				break
+			}
+		}
+	}
+
+	for len(q.events) >= q.size {
+		if q.policy == OverflowDropOldest {
+			q.events = q.events[1:]
+			metrics.queueDropped.Add(1)
+			continue
+		}
+
+		metrics.queueBlocked.Add(1)
+		q.cond.Wait()
+		if q.closed {
+			return
+		}
+	}
+
+	q.events = append(q.events, ev)
+	q.cond.Broadcast()
+}
+
+// pop ждет событие. false - очередь закрыта, оставшиеся события не обрабатываются
+func (q *eventQueue) pop() (queuedEvent, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.events) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return queuedEvent{}, false
+	}
+
+	ev := q.events[0]
+	q.events[0] = queuedEvent{}
+	q.events = q.events[1:]
+	q.cond.Broadcast()
+
+	return ev, true
+}
+
+// close останавливает очередь и будит ожидающих
+func (q *eventQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.events = nil
+	q.cond.Broadcast()
+}
+
+// dispatchEvents вызывает обработчики событий очереди по одному, пока клиент не остановлен
+func (c *Client) dispatchEvents(q *eventQueue) {
+	for {
+		ev, ok := q.pop()
+		if !ok {
+			return
+		}
+
+		metrics.dispatch(ev.ts)
+		ev.handler(ev.event)
+	}
+}
+
+// startEvents создает очередь событий соединения: она закрывается вместе с ctx (Disconnect)
+func (c *Client) startEvents(ctx context.Context) {
+	q := newEventQueue(c.queueConfig)
+	c.events.Store(q)
+	context.AfterFunc(ctx, q.close)
+
+	go c.dispatchEvents(q)
+}
+
+// enqueue передает событие обработчику через очередь клиента (handler nil - событие не нужно).
+// key - объект события для OverflowCoalesce
+func (c *Client) enqueue(handler EventHandler, event any, ts int64, key string) {
+	if handler == nil {
+		return
+	}
+
+	q := c.events.Load()
+	if q == nil {
+		return
+	}
+
+	q.push(queuedEvent{handler: handler, event: event, ts: ts, key: key})
+}
+
+// coalesceKey - объект события состояния для OverflowCoalesce (пусто - объект неизвестен)
+func coalesceKey(kind string, id int64) string {
+	if id <= 0 {
+		return ""
+	}
+	return kind + ":" + strconv.FormatInt(id, 10)
+}
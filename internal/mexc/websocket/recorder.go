@@ -0,0 +1,196 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"tg_mexc/internal/models"
+)
+
+// recordedChannelPrefix - записываются только приватные события аккаунта
+const recordedChannelPrefix = "push.personal."
+
+// frameQueueSize - буфер записи: при переполнении кадры теряются, чтение соединения не ждет записи
+const frameQueueSize = 4096
+
+// FrameRecorder - хранилище сырых push.personal.* сообщений (WebStorage.RecordWSFrame, FileRecorder)
+type FrameRecorder interface {
+	RecordWSFrame(frame models.WSFrame) error
+}
+
+// FrameRecorders пишет каждый кадр во все хранилища (файл и база одновременно)
+type FrameRecorders []FrameRecorder
+
+func (rs FrameRecorders) RecordWSFrame(frame models.WSFrame) error {
+	var errs []error
+	for _, recorder := range rs {
+		if err := recorder.RecordWSFrame(frame); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// frameQueue - фоновая запись кадров всех клиентов процесса
+type frameQueue struct {
+	recorder FrameRecorder
+	logger   *slog.Logger
+	frames   chan models.WSFrame
+	done     chan struct{}
+}
+
+// frameSink - текущая запись (SetFrameRecorder), nil - запись выключена. frameMu на чтение держат
+// клиенты при постановке кадра: канал закрывается только под записью
+var (
+	frameSink *frameQueue
+	frameMu   sync.RWMutex
+)
+
+// SetFrameRecorder включает запись сырых push.personal.* сообщений всех WebSocket клиентов процесса
+// (master и slave) в recorder. Запись идет в фоне; вызывается при старте приложения, до остановки -
+// StopFrameRecorder
+func SetFrameRecorder(recorder FrameRecorder, logger *slog.Logger) {
+	queue := &frameQueue{
+		recorder: recorder,
+		logger:   logger,
+		frames:   make(chan models.WSFrame, frameQueueSize),
+		done:     make(chan struct{}),
+	}
+	go queue.run()
+
+	swapFrameSink(queue)
+}
+
+// StopFrameRecorder выключает запись и дожидается записи уже полученных кадров
+func StopFrameRecorder() {
+	swapFrameSink(nil)
+}
+
+// swapFrameSink заменяет очередь записи; прежняя закрывается и дописывается
+func swapFrameSink(queue *frameQueue) {
+	frameMu.Lock()
+	previous := frameSink
+	frameSink = queue
+	if previous != nil {
+		close(previous.frames)
+	}
+	frameMu.Unlock()
+
+	if previous != nil {
+		<-previous.done
+	}
+}
+
+// recordFrame ставит приватное сообщение аккаунта в очередь записи (если запись включена)
+func recordFrame(accountID int, msg Message) {
+	if !strings.HasPrefix(msg.Channel, recordedChannelPrefix) {
+		return
+	}
+
+	frameMu.RLock()
+	defer frameMu.RUnlock()
+
+	if frameSink == nil {
+		return
+	}
+
+	frame := models.WSFrame{
+		AccountID:  accountID,
+		Channel:    msg.Channel,
+		Ts:         msg.Ts,
+		Payload:    string(msg.Data),
+		ReceivedAt: time.Now(),
+	}
+
+	select {
+	case frameSink.frames <- frame:
+	default:
+		metrics.droppedFrames.Add(1)
+	}
+}
+
+// run пишет кадры очереди, пока она не закрыта
+func (q *frameQueue) run() {
+	defer close(q.done)
+
+	for frame := range q.frames {
+		if err := q.recorder.RecordWSFrame(frame); err != nil {
+			metrics.droppedFrames.Add(1)
+			q.logger.Warn("Failed to record WebSocket frame",
+				slog.Int("account_id", frame.AccountID),
+				slog.String("channel", frame.Channel),
+				slog.Any("error", err))
+
+			continue
+		}
+
+		metrics.recordedFrames.Add(1)
+	}
+}
+
+// FileRecorder пишет кадры в файл JSON Lines (один models.WSFrame на строку), дописывая в конец
+type FileRecorder struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewFileRecorder открывает (или создает) файл записи кадров
+func NewFileRecorder(path string) (*FileRecorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open frame record file: %w", err)
+	}
+
+	return &FileRecorder{file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// RecordWSFrame дописывает кадр строкой JSON
+func (r *FileRecorder) RecordWSFrame(frame models.WSFrame) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.enc.Encode(frame)
+}
+
+// Close закрывает файл записи
+func (r *FileRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+// ReadFrameFile читает кадры, записанные FileRecorder, в порядке записи (для offline replay)
+func ReadFrameFile(path string) ([]models.WSFrame, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var frames []models.WSFrame
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var frame models.WSFrame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		frames = append(frames, frame)
+	}
+
+	return frames, scanner.Err()
+}
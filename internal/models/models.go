@@ -1,9 +1,16 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
 // Account представляет аккаунт пользователя на MEXC
 type Account struct {
 	ID        int
-	ChatID    int64             // Telegram chat ID владельца
 	Name      string            // Имя аккаунта
 	Token     string            // uc_token из браузера
 	UserID    string            // u_id из браузера
@@ -13,6 +20,57 @@ type Account struct {
 	Proxy     string            // Прокси (опционально)
 	IsMaster  bool              // Главный аккаунт для copy trading
 	Disabled  bool              // Отключен из-за наличия комиссии
+
+	SessionInvalid bool // uc_token истек: приватные запросы отклоняются (периодическая проверка сессий)
+
+	CopyMultiplier float64  // Множитель объёма входов мастера на slave аккаунте (0 - не задан, как 1)
+	Caps           CopyCaps // Ограничения объёма входов мастера на slave аккаунте
+}
+
+// CopyCaps - ограничения скопированного входа на slave аккаунте (0 - без ограничения).
+// Вход, превышающий ограничение, уменьшается до него
+type CopyCaps struct {
+	MaxOrderVol       float64 `json:"max_order_vol"`       // Контрактов в одном ордере
+	MaxSymbolNotional float64 `json:"max_symbol_notional"` // USDT в позициях символа после входа
+	MaxExposure       float64 `json:"max_exposure"`        // USDT во всех позициях аккаунта после входа
+	MaxPositions      int     `json:"max_positions"`       // Символов с открытой позицией: вход по новому символу сверх предела не копируется
+}
+
+// Notional сообщает, задано ли ограничение в USDT (нужны позиции аккаунта и цена входа)
+func (c CopyCaps) Notional() bool {
+	return c.MaxSymbolNotional > 0 || c.MaxExposure > 0
+}
+
+// Validate проверяет ограничения: неотрицательные числа
+func (c CopyCaps) Validate() error {
+	for _, v := range []float64{c.MaxOrderVol, c.MaxSymbolNotional, c.MaxExposure} {
+		if math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+			return fmt.Errorf("caps must be non-negative numbers (0 - no cap)")
+		}
+	}
+	if c.MaxPositions < 0 {
+		return fmt.Errorf("caps must be non-negative numbers (0 - no cap)")
+	}
+	return nil
+}
+
+// MaxCopyMultiplier - наибольший множитель объёма slave аккаунта
+const MaxCopyMultiplier = 10.0
+
+// VolumeMultiplier возвращает множитель объёма входов мастера на аккаунте (не задан - 1)
+func (a Account) VolumeMultiplier() float64 {
+	if a.CopyMultiplier <= 0 {
+		return 1
+	}
+	return a.CopyMultiplier
+}
+
+// ValidateCopyMultiplier проверяет множитель объёма slave аккаунта: 0 < multiplier <= MaxCopyMultiplier
+func ValidateCopyMultiplier(multiplier float64) error {
+	if math.IsNaN(multiplier) || multiplier <= 0 || multiplier > MaxCopyMultiplier {
+		return fmt.Errorf("multiplier must be in (0, %g]", MaxCopyMultiplier)
+	}
+	return nil
 }
 
 // BrowserData - данные из браузера
@@ -30,13 +88,16 @@ type OpenPositionRequest struct {
 	Symbol        string `json:"symbol"`
 	Side          int    `json:"side"`
 	OpenType      int    `json:"openType"`
-	Type          string `json:"type"` // "5" для market order (СТРОКА!)
+	Type          string `json:"type"`            // "5" для market order, "1" для limit (СТРОКА!)
+	Price         string `json:"price,omitempty"` // Цена limit ордера (СТРОКА!)
 	Vol           int    `json:"vol"`
 	Leverage      int    `json:"leverage"`
 	MarketCeiling bool   `json:"marketCeiling"`
 	StopLossPrice string `json:"stopLossPrice,omitempty"` // СТРОКА!
 	LossTrend     string `json:"lossTrend,omitempty"`     // "1" (СТРОКА!)
 	PriceProtect  string `json:"priceProtect"`            // "0" (СТРОКА!)
+	ReduceOnly    bool   `json:"reduceOnly,omitempty"`    // Только уменьшение позиции: ордер не может открыть новую позицию
+	ExternalOid   string `json:"externalOid,omitempty"`   // Клиентский ID ордера: по нему ордер находится после потерянного ответа
 
 	// Технические поля для шифрования
 	P0     string `json:"p0,omitempty"`
@@ -56,7 +117,8 @@ type ClosePositionRequest struct {
 	Type         int    `json:"type"` // 5 для market order (ЧИСЛО!)
 	Vol          int    `json:"vol"`
 	Side         int    `json:"side"`
-	PriceProtect string `json:"priceProtect"` // "0" (СТРОКА!)
+	ReduceOnly   bool   `json:"reduceOnly,omitempty"` // Всегда true: закрытие не может открыть встречную позицию
+	PriceProtect string `json:"priceProtect"`         // "0" (СТРОКА!)
 
 	// Технические поля для шифрования
 	P0     string `json:"p0,omitempty"`
@@ -83,6 +145,7 @@ type Position struct {
 	PositionID   int64   `json:"positionId"`
 	Symbol       string  `json:"symbol"`
 	PositionType int     `json:"positionType"`
+	OpenType     int     `json:"openType"` // 1: isolated, 2: cross
 	HoldVol      float64 `json:"holdVol"`
 	HoldAvgPrice float64 `json:"holdAvgPrice"`
 	Leverage     int     `json:"leverage"`
@@ -95,6 +158,15 @@ type Balance struct {
 	Equity           float64 `json:"equity"`
 }
 
+// AccountStatus - состояние аккаунта на бирже: ограничения, уровень KYC и доступ к фьючерсам
+type AccountStatus struct {
+	KYCLevel       int       `json:"kyc_level"`       // 0 - KYC не пройден
+	Restricted     bool      `json:"restricted"`      // Аккаунт заморожен или ограничен биржей
+	FuturesEnabled bool      `json:"futures_enabled"` // Фьючерсный счет открыт и отвечает
+	Issues         []string  `json:"issues,omitempty"`
+	CheckedAt      time.Time `json:"checked_at"`
+}
+
 // LeverageInfo - информация о leverage
 type LeverageInfo struct {
 	Level           int     `json:"level"`
@@ -111,9 +183,9 @@ type LeverageInfo struct {
 
 // StopLossRequest - запрос на установку SL/TP
 type StopLossRequest struct {
-	Symbol          string  `json:"symbol"`
-	StopLossPrice   float64 `json:"stopLossPrice,omitempty"`
-	TakeProfitPrice float64 `json:"takeProfitPrice,omitempty"`
+	Symbol          string      `json:"symbol"`
+	StopLossPrice   json.Number `json:"stopLossPrice,omitempty"`   // Цена с точностью символа (ContractDetail.FormatPrice)
+	TakeProfitPrice json.Number `json:"takeProfitPrice,omitempty"` // Пусто - не устанавливается
 }
 
 // StopOrderCancelItem - элемент для отмены стоп-ордера
@@ -137,6 +209,7 @@ type StopOrder struct {
 	LossTrend                  int     `json:"lossTrend"`
 	ProfitTrend                int     `json:"profitTrend"`
 	StopLossPrice              float64 `json:"stopLossPrice"`
+	TakeProfitPrice            float64 `json:"takeProfitPrice"`
 	State                      int     `json:"state"`
 	TriggerSide                int     `json:"triggerSide"`
 	PositionType               int     `json:"positionType"`
@@ -171,6 +244,71 @@ type ChangePlanPriceRequest struct {
 	StopLossPrice     float64 `json:"stopLossPrice,omitempty"`
 }
 
+// TrailingStopRequest - запрос на создание trailing stop ордера (закрытие позиции с отступом от экстремума цены)
+type TrailingStopRequest struct {
+	Symbol      string  `json:"symbol"`
+	Side        int     `json:"side"` // 2 close short, 4 close long
+	Vol         int     `json:"vol"`
+	Leverage    int     `json:"leverage"`
+	OpenType    int     `json:"openType"`              // 1: isolated
+	Trend       int     `json:"trend"`                 // Цена срабатывания: 1 last, 2 fair, 3 index
+	ActivePrice float64 `json:"activePrice,omitempty"` // Цена активации, 0 - отслеживание сразу
+	BackType    int     `json:"backType"`              // 1 - callback rate, 2 - отступ в цене
+	BackValue   float64 `json:"backValue"`             // Callback rate (0.01 = 1%) или отступ в цене
+}
+
+// ChangeTrailingStopRequest - запрос на изменение trailing stop ордера
+type ChangeTrailingStopRequest struct {
+	TrackOrderID int64   `json:"trackOrderId"`
+	Vol          int     `json:"vol"`
+	Trend        int     `json:"trend"`
+	ActivePrice  float64 `json:"activePrice,omitempty"`
+	BackType     int     `json:"backType"`
+	BackValue    float64 `json:"backValue"`
+}
+
+// TrailingStopCancelItem - элемент для отмены trailing stop ордера
+type TrailingStopCancelItem struct {
+	Symbol       string `json:"symbol"`
+	TrackOrderID int64  `json:"trackOrderId"`
+}
+
+// TrailingStop - открытый trailing stop ордер
+type TrailingStop struct {
+	ID          int64   `json:"id"`
+	Symbol      string  `json:"symbol"`
+	Side        int     `json:"side"`
+	Vol         int     `json:"vol"`
+	Leverage    int     `json:"leverage"`
+	Trend       int     `json:"trend"`
+	ActivePrice float64 `json:"activePrice"`
+	BackType    int     `json:"backType"`
+	BackValue   float64 `json:"backValue"`
+	State       int     `json:"state"`
+	CreateTime  int64   `json:"createTime"`
+	UpdateTime  int64   `json:"updateTime"`
+}
+
+// FilledOrder - исполненный ордер из истории ордеров биржи
+type FilledOrder struct {
+	OrderID      string  `json:"orderId"`
+	Symbol       string  `json:"symbol"`
+	PositionID   int64   `json:"positionId"`
+	Side         int     `json:"side"`      // 1 open long, 2 close short, 3 open short, 4 close long
+	OrderType    int     `json:"orderType"` // 1 limit, 2 post only, 5 market
+	Vol          float64 `json:"vol"`
+	DealVol      float64 `json:"dealVol"`
+	DealAvgPrice float64 `json:"dealAvgPrice"`
+	Leverage     int     `json:"leverage"`
+	Profit       float64 `json:"profit"` // Реализованный PnL (для закрывающих ордеров)
+	TakerFee     float64 `json:"takerFee"`
+	MakerFee     float64 `json:"makerFee"`
+	FeeCurrency  string  `json:"feeCurrency"`
+	State        int     `json:"state"`
+	CreateTime   int64   `json:"createTime"`
+	UpdateTime   int64   `json:"updateTime"`
+}
+
 // OpenOrder - открытый ордер
 type OpenOrder struct {
 	OrderID                  string  `json:"orderId"`
@@ -211,6 +349,162 @@ type OpenOrder struct {
 	TotalFee                 float64 `json:"totalFee"`
 }
 
+// HistoryPosition - закрытая позиция из истории
+type HistoryPosition struct {
+	PositionID      int64   `json:"positionId"`
+	Symbol          string  `json:"symbol"`
+	PositionType    int     `json:"positionType"` // 1 long, 2 short
+	OpenType        int     `json:"openType"`
+	State           int     `json:"state"` // 3 closed
+	OpenAvgPrice    float64 `json:"openAvgPrice"`
+	CloseAvgPrice   float64 `json:"closeAvgPrice"`
+	CloseVol        float64 `json:"closeVol"`
+	Leverage        int     `json:"leverage"`
+	Realised        float64 `json:"realised"`        // Реализованный PnL с учетом комиссий и funding
+	CloseProfitLoss float64 `json:"closeProfitLoss"` // PnL по цене без комиссий
+	Fee             float64 `json:"fee"`
+	TotalFee        float64 `json:"totalFee"`
+	HoldFee         float64 `json:"holdFee"` // Funding: положительный - получен, отрицательный - уплачен
+	CreateTime      int64   `json:"createTime"`
+	UpdateTime      int64   `json:"updateTime"`
+}
+
+// FundingRecord - funding платеж по позиции
+type FundingRecord struct {
+	ID            int64   `json:"id"`
+	Symbol        string  `json:"symbol"`
+	PositionType  int     `json:"positionType"` // 1 long, 2 short
+	PositionValue float64 `json:"positionValue"`
+	Funding       float64 `json:"funding"` // Положительный - получен, отрицательный - уплачен
+	Rate          float64 `json:"rate"`
+	SettleTime    int64   `json:"settleTime"`
+}
+
+// ContractDetail - параметры фьючерсного контракта
+type ContractDetail struct {
+	Symbol       string  `json:"symbol"`
+	ContractSize float64 `json:"contractSize"` // Размер одного контракта в базовой валюте
+	PriceScale   int     `json:"priceScale"`   // Знаков после запятой в цене
+	PriceUnit    float64 `json:"priceUnit"`    // Шаг цены
+	VolUnit      float64 `json:"volUnit"`      // Шаг объёма, контрактов
+	MinVol       float64 `json:"minVol"`
+	MaxVol       float64 `json:"maxVol"`
+	MinLeverage  int     `json:"minLeverage"`
+	MaxLeverage  int     `json:"maxLeverage"`
+}
+
+// RoundPrice округляет цену до шага цены контракта (без известного шага - без изменений)
+func (d ContractDetail) RoundPrice(price float64) float64 {
+	if d.PriceUnit > 0 {
+		price = math.Round(price/d.PriceUnit) * d.PriceUnit
+	}
+
+	// Убираем погрешность float после умножения на шаг
+	if d.PriceScale > 0 {
+		scale := math.Pow10(d.PriceScale)
+		price = math.Round(price*scale) / scale
+	}
+
+	return price
+}
+
+// FormatPrice округляет цену до шага контракта и форматирует с PriceScale знаками после запятой
+// (без экспоненты и без обрезания знаков для символов с ценой 0.0001 и меньше).
+// Без известного шага - все значащие знаки
+func (d ContractDetail) FormatPrice(price float64) string {
+	if d.PriceUnit <= 0 {
+		return strconv.FormatFloat(price, 'f', -1, 64)
+	}
+
+	return strconv.FormatFloat(d.RoundPrice(price), 'f', d.PriceScale, 64)
+}
+
+// RoundVolume округляет объём вниз до шага объёма и ограничивает максимумом контракта.
+// Объём меньше минимального - 0
+func (d ContractDetail) RoundVolume(vol float64) float64 {
+	if d.VolUnit > 0 {
+		// Погрешность float не должна уменьшать объём на целый шаг
+		vol = math.Floor(vol/d.VolUnit+1e-9) * d.VolUnit
+	}
+
+	if d.MaxVol > 0 && vol > d.MaxVol {
+		vol = d.MaxVol
+	}
+
+	if vol < d.MinVol {
+		return 0
+	}
+
+	return vol
+}
+
+// Ticker - рыночные данные контракта
+type Ticker struct {
+	Symbol       string  `json:"symbol"`
+	LastPrice    float64 `json:"lastPrice"`
+	FairPrice    float64 `json:"fairPrice"` // Mark price: по ней считаются PnL и ликвидация
+	IndexPrice   float64 `json:"indexPrice"`
+	Bid1         float64 `json:"bid1"`
+	Ask1         float64 `json:"ask1"`
+	Volume24     float64 `json:"volume24"` // Объём за 24ч, контрактов
+	HoldVol      float64 `json:"holdVol"`  // Открытый интерес, контрактов
+	FundingRate  float64 `json:"fundingRate"`
+	RiseFallRate float64 `json:"riseFallRate"` // Изменение за 24ч (0.01 = 1%)
+	Timestamp    int64   `json:"timestamp"`
+}
+
+// DepthLevel - уровень стакана: цена и объём в контрактах
+type DepthLevel struct {
+	Price float64
+	Vol   float64
+}
+
+// UnmarshalJSON разбирает уровень стакана MEXC: массив [price, vol, orderCount]
+func (l *DepthLevel) UnmarshalJSON(data []byte) error {
+	var level []float64
+	if err := json.Unmarshal(data, &level); err != nil {
+		return err
+	}
+	if len(level) < 2 {
+		return fmt.Errorf("invalid depth level %s", data)
+	}
+
+	l.Price, l.Vol = level[0], level[1]
+
+	return nil
+}
+
+// Depth - стакан контракта: asks по возрастанию цены, bids по убыванию
+type Depth struct {
+	Asks      []DepthLevel `json:"asks"`
+	Bids      []DepthLevel `json:"bids"`
+	Version   int64        `json:"version"`
+	Timestamp int64        `json:"timestamp"`
+}
+
+// Kline - свеча
+type Kline struct {
+	Time   int64 // Время открытия (секунды)
+	Open   float64
+	Close  float64
+	High   float64
+	Low    float64
+	Volume float64 // Объём, контрактов
+}
+
+// FundingRate - ставка финансирования контракта
+type FundingRate struct {
+	Symbol         string  `json:"symbol"`
+	FundingRate    float64 `json:"fundingRate"` // Прогнозная ставка ближайшего расчета (0.0001 = 0.01%)
+	MaxFundingRate float64 `json:"maxFundingRate"`
+	MinFundingRate float64 `json:"minFundingRate"`
+	CollectCycle   int     `json:"collectCycle"`   // Период расчета, часов
+	NextSettleTime int64   `json:"nextSettleTime"` // Время ближайшего расчета (ms)
+
+	LastFundingRate float64 `json:"-"` // Ставка последнего расчета
+	LastSettleTime  int64   `json:"-"` // Время последнего расчета (ms), 0 - расчетов еще не было
+}
+
 // TieredFeeRate - конфигурация ступенчатой комиссии
 type TieredFeeRate struct {
 	TieredDealAmount        float64 `json:"tieredDealAmount"`
@@ -248,3 +542,39 @@ type TieredFeeRateResponse struct {
 	FixedStartTime   int64             `json:"fixedStartTime"`
 	FixedEndTime     int64             `json:"fixedEndTime"`
 }
+
+// SpotOrderRequest - запрос на создание spot ордера
+type SpotOrderRequest struct {
+	Symbol    string `json:"symbol"`             // Пара в формате BTC_USDT
+	Side      string `json:"side"`               // BUY или SELL
+	OrderType string `json:"orderType"`          // LIMIT_ORDER или MARKET_ORDER
+	Price     string `json:"price,omitempty"`    // Цена limit ордера (СТРОКА!)
+	Quantity  string `json:"quantity,omitempty"` // Объём в базовой валюте (СТРОКА!)
+	Amount    string `json:"amount,omitempty"`   // Сумма в quote валюте для market покупки (СТРОКА!)
+}
+
+// SpotBalance - баланс spot кошелька по валюте
+type SpotBalance struct {
+	Currency  string  `json:"currency"`
+	Available float64 `json:"available,string"`
+	Frozen    float64 `json:"frozen,string"`
+}
+
+// TransferRequest - перевод между кошельками аккаунта (spot <-> futures)
+type TransferRequest struct {
+	FromAccountType string `json:"fromAccountType"` // SPOT или FUTURES
+	ToAccountType   string `json:"toAccountType"`
+	Currency        string `json:"currency"`
+	Amount          string `json:"amount"` // СТРОКА!
+}
+
+// RiskLimit - ступень риск-лимита позиции: максимальный объём и плечо
+type RiskLimit struct {
+	Symbol       string  `json:"symbol"`
+	PositionType int     `json:"positionType"` // 1: long, 2: short
+	Level        int     `json:"level"`
+	MaxVol       float64 `json:"maxVol"` // Максимальный объём позиции на ступени (контракты)
+	MaxLeverage  int     `json:"maxLeverage"`
+	MMR          float64 `json:"mmr"`
+	IMR          float64 `json:"imr"`
+}
@@ -4,10 +4,20 @@ import "time"
 
 // User представляет пользователя веб-приложения
 type User struct {
-	ID           int
-	Username     string
-	PasswordHash string
-	CreatedAt    time.Time
+	ID            int
+	Username      string
+	PasswordHash  string
+	IsAdmin       bool
+	Email         string
+	EmailVerified bool
+	CreatedAt     time.Time
+}
+
+// LoginLockout представляет временную блокировку входа по username или IP
+type LoginLockout struct {
+	Scope       string    `json:"scope"` // "username", "ip"
+	Key         string    `json:"key"`
+	LockedUntil time.Time `json:"locked_until"`
 }
 
 // Trade представляет сделку в истории
@@ -20,7 +30,8 @@ type Trade struct {
 	Side               int           `json:"side"`
 	Volume             int           `json:"volume"`
 	Leverage           int           `json:"leverage"`
-	Action             string        `json:"action"` // "open_position", "close_position", "change_leverage", etc.
+	MasterPrice        float64       `json:"master_price,omitempty"` // Цена исполнения у мастера
+	Action             string        `json:"action"`                 // "open_position", "close_position", "change_leverage", etc.
 	SentAt             time.Time     `json:"sent_at"`
 	ReceivedAt         *time.Time    `json:"received_at,omitempty"`
 	ExchangeAcceptedAt *time.Time    `json:"exchange_accepted_at,omitempty"`
@@ -40,7 +51,154 @@ type TradeDetail struct {
 	Error       string    `json:"error,omitempty"`
 	OrderID     string    `json:"order_id,omitempty"`
 	LatencyMs   int       `json:"latency_ms"`
+	FillPrice   float64   `json:"fill_price,omitempty"` // Средняя цена исполнения slave
+	FilledVol   float64   `json:"filled_vol,omitempty"` // Исполненный объём по fill'ам slave (WebSocket)
+	Fee         float64   `json:"fee,omitempty"`        // Комиссия fill'ов slave (WebSocket)
+	Capped      bool      `json:"capped,omitempty"`     // Объём входа уменьшен ограничениями slave (CopyCaps)
+	Attempt     int       `json:"attempt,omitempty"`    // Номер повтора копии (0 - исходная копия)
 	CreatedAt   time.Time `json:"created_at"`
+
+	// Тайминги для latency аналитики
+	MasterEventAt *time.Time `json:"master_event_at,omitempty"` // Событие у мастера
+	DispatchedAt  *time.Time `json:"dispatched_at,omitempty"`   // Engine начал обработку slave
+	AckedAt       *time.Time `json:"acked_at,omitempty"`        // Биржа ответила slave
+}
+
+// CopyRetry - повтор копии на slave аккаунте, не выполненной из-за временной ошибки (очередь повторов engine)
+type CopyRetry struct {
+	ID            int
+	UserID        int
+	AccountID     int
+	TradeID       int
+	Op            string  // "open", "close"
+	Symbol        string  // Символ копии: закрытие мастера отменяет повторы входов по нему
+	Request       string  // JSON запроса engine
+	Ratio         float64 // Доля позиции для частичного закрытия (close)
+	Attempts      int     // Выполненных повторов
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time // Момент исходной копии: повтор старше RetryConfig.MaxAge отбрасывается
+}
+
+// AlertRule - пользовательское правило алерта
+type AlertRule struct {
+	ID              int       `json:"id"`
+	UserID          int       `json:"-"`
+	Type            string    `json:"type"`                 // "slave_failures", "balance_below", "master_silence", "auth_expired"
+	AccountID       *int      `json:"account_id,omitempty"` // nil - все подходящие аккаунты
+	Threshold       float64   `json:"threshold"`            // Количество ошибок (slave_failures) или USDT (balance_below)
+	WindowMinutes   int       `json:"window_minutes"`       // Окно подсчета ошибок, период тишины или период проверки авторизации
+	CooldownMinutes int       `json:"cooldown_minutes"`     // Минимальный интервал между повторными срабатываниями
+	Channels        []string  `json:"channels"`             // "telegram", "webhook", "email"
+	WebhookURL      string    `json:"webhook_url,omitempty"`
+	Enabled         bool      `json:"enabled"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// AlertEvent - срабатывание правила алерта
+type AlertEvent struct {
+	ID          int       `json:"id"`
+	RuleID      int       `json:"rule_id"`
+	UserID      int       `json:"-"`
+	AccountID   int       `json:"account_id,omitempty"` // 0 - правило не привязано к аккаунту
+	AccountName string    `json:"account_name,omitempty"`
+	Type        string    `json:"type"`
+	Message     string    `json:"message"`
+	FiredAt     time.Time `json:"fired_at"`
+}
+
+// AccountCount - счетчик по аккаунту
+type AccountCount struct {
+	AccountID   int
+	AccountName string
+	Count       int
+}
+
+// ReportSettings - подписка пользователя на периодический отчет
+type ReportSettings struct {
+	UserID     int       `json:"-"`
+	Period     string    `json:"period"`   // "daily", "weekly"
+	Channels   []string  `json:"channels"` // "telegram", "email"
+	LastSentAt time.Time `json:"last_sent_at"`
+}
+
+// NotificationSettings - каналы уведомлений пользователя о скопированных сделках
+type NotificationSettings struct {
+	UserID            int      `json:"-"`
+	Channels          []string `json:"channels"` // "telegram", "discord", "slack"
+	DiscordWebhookURL string   `json:"discord_webhook_url,omitempty"`
+	SlackWebhookURL   string   `json:"slack_webhook_url,omitempty"`
+}
+
+// SymbolFilter - символы, входы мастера по которым копируются (whitelist) или пропускаются (blacklist)
+type SymbolFilter struct {
+	UserID  int      `json:"-"`
+	Mode    string   `json:"mode"`    // "off", "whitelist", "blacklist"
+	Symbols []string `json:"symbols"` // BTC_USDT, ...
+}
+
+// TradeStats - скопированные сделки и исполнения на slave аккаунтах за период
+type TradeStats struct {
+	Trades    int                 `json:"trades"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	Accounts  []AccountExecutions `json:"accounts"`
+}
+
+// AccountExecutions - исполнения на slave аккаунте за период
+type AccountExecutions struct {
+	AccountID   int    `json:"account_id"`
+	AccountName string `json:"account_name"`
+	Succeeded   int    `json:"succeeded"`
+	Failed      int    `json:"failed"`
+}
+
+// ExecutionSample - исполнение ордера на slave аккаунте (для оценки здоровья аккаунта)
+type ExecutionSample struct {
+	AccountID    int
+	Status       string // "success", "failed"
+	Error        string
+	DispatchedAt time.Time
+	AckedAt      *time.Time
+}
+
+// MasterEvent - записанное WebSocket событие master аккаунта (для offline replay)
+type MasterEvent struct {
+	ID         int       `json:"id"`
+	AccountID  int       `json:"account_id"`
+	Kind       string    `json:"kind"`    // "order", "stop_order", "stop_plan_order", "position"
+	Payload    string    `json:"payload"` // JSON события
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// WSFrame - сырое push.personal.* сообщение WebSocket аккаунта (запись для отладки и backtesting)
+type WSFrame struct {
+	ID         int       `json:"id,omitempty"`
+	AccountID  int       `json:"account_id"`
+	Channel    string    `json:"channel"` // push.personal.order, push.personal.stop.order, ...
+	Ts         int64     `json:"ts"`      // Время события на бирже, мс (0 - биржа не передала)
+	Payload    string    `json:"payload"` // Поле data сообщения как пришло
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// SlippageSample - цены исполнения мастера и slave по скопированному входу
+type SlippageSample struct {
+	AccountID   int
+	AccountName string
+	Symbol      string
+	Side        int
+	MasterPrice float64
+	FillPrice   float64
+}
+
+// LatencySample - тайминги выполнения сделки на slave аккаунте
+type LatencySample struct {
+	AccountID     int
+	AccountName   string
+	Proxy         string
+	MasterEventAt *time.Time
+	DispatchedAt  time.Time
+	AckedAt       time.Time
 }
 
 // ActivityLog представляет запись в логе активности
@@ -54,36 +212,100 @@ type ActivityLog struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
-// CopyTradingSession представляет сессию copy trading
-type CopyTradingSession struct {
-	ID               int
-	UserID           int
-	MasterAccountID  int
-	IsActive         bool
-	IgnoreFees       bool
-	StartedAt        time.Time
-	StoppedAt        *time.Time
-	MasterAccount    *Account // Joined field
-	ActiveSlaveCount int      // Computed field
-}
-
-// AccountWithFee расширяет Account дополнительной информацией о комиссиях
-type AccountWithFee struct {
-	Account
-	MakerFee float64
-	TakerFee float64
-	Balance  float64
-}
-
-// DashboardStats статистика для дашборда
-type DashboardStats struct {
-	TotalAccounts     int
-	ActiveAccounts    int
-	DisabledAccounts  int
-	MasterAccount     *Account
-	CopyTradingActive bool
-	TodayTrades       int
-	TodaySuccessRate  float64
-	TotalBalance      float64
-	RecentErrors      []ActivityLog
+// Deal - исполнение (fill) ордера на аккаунте
+type Deal struct {
+	AccountID int
+	ID        string
+	OrderID   string
+	Symbol    string
+	Side      int
+	Vol       float64
+	Price     float64
+	Fee       float64
+	Profit    float64
+	Taker     bool
+	At        time.Time
+}
+
+// OrderFill - исполнение ордера, сведенное по его fill'ам
+type OrderFill struct {
+	Vol      float64 // Исполненный объём, контрактов
+	AvgPrice float64 // Средняя цена исполнения (взвешенная по объёму)
+	Fee      float64 // Сумма комиссий
+}
+
+// PnLEntry - единичное событие реализованного PnL (fill ордера или закрытая позиция)
+type PnLEntry struct {
+	AccountID  int
+	Source     string // "deal", "position", "funding"
+	Ref        string // ID fill'а, позиции или funding платежа на бирже (уникален в пределах аккаунта и источника)
+	Symbol     string
+	Realized   float64
+	Fee        float64
+	Funding    float64 // Funding платеж: положительный - получен, отрицательный - уплачен
+	Volume     float64
+	OccurredAt time.Time
+}
+
+// PnLRecord - агрегированный PnL аккаунта по символу за день (UTC)
+type PnLRecord struct {
+	AccountID   int     `json:"account_id"`
+	AccountName string  `json:"account_name,omitempty"` // Joined field
+	Symbol      string  `json:"symbol"`
+	Day         string  `json:"day"` // YYYY-MM-DD
+	RealizedPnL float64 `json:"realized_pnl"`
+	Fees        float64 `json:"fees"`
+	Funding     float64 `json:"funding"`
+	Volume      float64 `json:"volume"`
+	Trades      int     `json:"trades"`
+}
+
+// NetPnL возвращает PnL за вычетом комиссий с учетом funding
+func (r PnLRecord) NetPnL() float64 {
+	return r.RealizedPnL - r.Fees + r.Funding
+}
+
+// PositionStats - итоги закрытых позиций аккаунта за период
+type PositionStats struct {
+	AccountID   int
+	AccountName string
+	Positions   int
+	Wins        int     // Позиции с положительным realised
+	Losses      int     // Позиции с отрицательным realised
+	Realised    float64 // Реализованный PnL позиций с учетом комиссий и funding
+}
+
+// FeeEntry - комиссия, уплаченная за fill или исполненный ордер
+type FeeEntry struct {
+	AccountID  int
+	Source     string // "deal", "order"
+	Ref        string // ID fill'а или ордера на бирже
+	Symbol     string
+	TakerFee   float64
+	MakerFee   float64
+	OccurredAt time.Time
+}
+
+// FeeRecord - комиссии аккаунта за день (UTC)
+type FeeRecord struct {
+	AccountID   int     `json:"account_id"`
+	AccountName string  `json:"account_name,omitempty"` // Joined field
+	Day         string  `json:"day"`                    // YYYY-MM-DD
+	TakerFees   float64 `json:"taker_fees"`
+	MakerFees   float64 `json:"maker_fees"`
+	Fills       int     `json:"fills"`
+}
+
+// BalanceSnapshot - снимок баланса аккаунта (USDT)
+type BalanceSnapshot struct {
+	AccountID int
+	Equity    float64
+	Available float64
+	TakenAt   time.Time
+}
+
+// EquityPoint - точка кривой equity
+type EquityPoint struct {
+	Time   time.Time `json:"time"`
+	Equity float64   `json:"equity"`
 }
@@ -0,0 +1,79 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/mexc/copytrading"
+	"tg_mexc/internal/models"
+)
+
+// maxErrors - сколько ошибок аккаунтов выводится в уведомлении
+const maxErrors = 5
+
+var actionTitles = map[string]string{
+	"open_position":     "Открытие позиции",
+	"close_position":    "Закрытие позиции",
+	"place_plan_order":  "Установка SL/TP",
+	"change_plan_price": "Изменение SL/TP",
+	"change_leverage":   "Изменение leverage",
+	"cancel_stop_order": "Отмена SL/TP",
+	"cancel_order":      "Отмена ордера",
+
+	"place_trailing_stop":  "Установка trailing stop",
+	"change_trailing_stop": "Изменение trailing stop",
+	"cancel_trailing_stop": "Отмена trailing stop",
+}
+
+// errorHints - подсказка пользователю по классу ошибки MEXC API
+var errorHints = map[mexc.ErrorKind]string{
+	mexc.ErrorKindAuth:               "обновите токен аккаунта",
+	mexc.ErrorKindInsufficientMargin: "недостаточно маржи",
+	mexc.ErrorKindRateLimit:          "превышен лимит запросов",
+	mexc.ErrorKindSymbolClosed:       "торговля контрактом недоступна",
+	mexc.ErrorKindBotChallenge:       "anti-bot проверка биржи, аккаунт приостановлен",
+}
+
+// formatTrade формирует уведомление о результате копирования сделки
+func formatTrade(trade models.Trade, result copytrading.ExecutionResult) string {
+	icon := "✅"
+	switch {
+	case result.IsFullFailure():
+		icon = "❌"
+	case !result.IsFullSuccess():
+		icon = "⚠️"
+	}
+
+	title, ok := actionTitles[trade.Action]
+	if !ok {
+		title = trade.Action
+	}
+	if trade.Symbol != "" {
+		title += " " + trade.Symbol
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s: %d/%d", icon, title, result.SuccessCount, result.TotalCount)
+	if result.SkippedCount > 0 {
+		fmt.Fprintf(&b, " (пропущено: %d)", result.SkippedCount)
+	}
+
+	shown := 0
+	for _, r := range result.Results {
+		if r.Success || r.Skipped {
+			continue
+		}
+		if shown == maxErrors {
+			fmt.Fprintf(&b, "\n… и еще %d", result.FailedCount-result.SkippedCount-shown)
+			break
+		}
+		fmt.Fprintf(&b, "\n• %s: %s", r.AccountName, r.Error)
+		if hint, ok := errorHints[r.ErrorKind]; ok {
+			fmt.Fprintf(&b, " (%s)", hint)
+		}
+		shown++
+	}
+
+	return b.String()
+}
@@ -0,0 +1,259 @@
+package notifier
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tg_mexc/internal/clock"
+	"tg_mexc/internal/mexc/copytrading"
+	"tg_mexc/internal/models"
+)
+
+const (
+	// queueSize - сколько недоставленных уведомлений хранится на пользователя (старые вытесняются)
+	queueSize = 100
+	// Повтор доставки при ошибке канала: от minBackoff, удваиваясь до maxBackoff
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Sender отправляет сообщения в Telegram
+type Sender interface {
+	SendMessage(chatID int64, text string) error
+}
+
+// queueKey - очередь уведомлений пользователя в одном канале
+type queueKey struct {
+	userID  int
+	channel string
+}
+
+// queue - недоставленные уведомления пользователя в канале
+type queue struct {
+	messages []string
+	dropped  int // Вытеснено из-за переполнения с последней доставки
+}
+
+// Service доставляет уведомления о скопированных сделках всех сессий engine в каналы,
+// выбранные пользователем (Telegram, Discord, Slack).
+// Подписывается на engine (copytrading.TradeNotifier), а не на конкретную сессию: уведомления
+// не зависят от того, кто и как запустил сессию. NotifyTrade не блокирует fan-out - сообщения
+// копятся в ограниченной очереди пользователя по каналу и отправляются из Run; пока канал недоступен,
+// доставка повторяется с backoff, а накопившиеся сообщения склеиваются.
+// Через те же очереди доставляются сообщения о событиях пользователя (NotifyEvent)
+type Service struct {
+	storage SettingsStorage
+	sinks   map[string]Sink // Канал -> доставка (нет подключенных - уведомления отключены)
+	logger  *slog.Logger
+	clock   clock.Clock
+
+	mu     sync.Mutex
+	queues map[queueKey]*queue
+	wake   chan struct{}
+}
+
+// New создает сервис уведомлений о сделках
+func New(storage SettingsStorage, logger *slog.Logger) *Service {
+	return &Service{
+		storage: storage,
+		sinks:   make(map[string]Sink),
+		logger:  logger,
+		clock:   clock.Real,
+		queues:  make(map[queueKey]*queue),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// AddSink подключает канал доставки (ChannelTelegram, ChannelDiscord, ChannelSlack)
+func (s *Service) AddSink(channel string, sink Sink) {
+	s.sinks[channel] = sink
+}
+
+// SetClock подменяет источник времени
+func (s *Service) SetClock(clk clock.Clock) {
+	s.clock = clk
+}
+
+// NotifyTrade ставит уведомление о сделке в очереди каналов пользователя (copytrading.TradeNotifier)
+func (s *Service) NotifyTrade(trade models.Trade, result copytrading.ExecutionResult) {
+	if len(s.sinks) == 0 || result.TotalCount == 0 {
+		return
+	}
+
+	s.notify(trade.UserID, formatTrade(trade, result), nil)
+}
+
+// NotifyEvent ставит сообщение о событии пользователя (не сделке: расхождение позиций и т.п.) в очереди
+// его каналов, кроме skip - каналов, куда сообщение уже доставлено иначе (Telegram с inline кнопками)
+func (s *Service) NotifyEvent(userID int, text string, skip ...string) {
+	if len(s.sinks) == 0 {
+		return
+	}
+
+	s.notify(userID, text, skip)
+}
+
+// notify ставит сообщение в очереди выбранных пользователем каналов
+func (s *Service) notify(userID int, message string, skip []string) {
+	settings, err := Settings(s.storage, userID)
+	if err != nil {
+		s.logger.Error("Failed to get notification settings", slog.Int("user_id", userID), slog.Any("error", err))
+		return
+	}
+
+	for _, channel := range settings.Channels {
+		if _, ok := s.sinks[channel]; ok && !slices.Contains(skip, channel) {
+			s.enqueue(queueKey{userID: userID, channel: channel}, message)
+		}
+	}
+}
+
+func (s *Service) enqueue(key queueKey, message string) {
+	s.mu.Lock()
+	q, ok := s.queues[key]
+	if !ok {
+		q = &queue{}
+		s.queues[key] = q
+	}
+	if len(q.messages) >= queueSize {
+		q.messages = q.messages[1:]
+		q.dropped++
+	}
+	q.messages = append(q.messages, message)
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run доставляет уведомления до отмены ctx
+func (s *Service) Run(ctx context.Context) {
+	if len(s.sinks) == 0 {
+		s.logger.Info("Trade notifications disabled")
+		return
+	}
+
+	backoff := minBackoff
+	for {
+		if s.deliverAll(ctx) {
+			backoff = minBackoff
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wake:
+			}
+			continue
+		}
+
+		// Канал недоступен: очередь сохраняется, повтор после backoff
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// deliverAll отправляет очереди всех пользователей (false - была ошибка отправки, нужен повтор)
+func (s *Service) deliverAll(ctx context.Context) bool {
+	s.mu.Lock()
+	keys := make([]queueKey, 0, len(s.queues))
+	for key := range s.queues {
+		keys = append(keys, key)
+	}
+	s.mu.Unlock()
+	slices.SortFunc(keys, func(a, b queueKey) int {
+		return cmp.Or(cmp.Compare(a.userID, b.userID), strings.Compare(a.channel, b.channel))
+	})
+
+	ok := true
+	for _, key := range keys {
+		if !s.deliver(ctx, key) {
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+// deliver отправляет очередь пользователя в канал, склеивая сообщения до лимита канала
+func (s *Service) deliver(ctx context.Context, key queueKey) bool {
+	sink := s.sinks[key.channel]
+
+	for {
+		text, taken, dropped := s.batch(key, sink.MaxMessageLen())
+		if taken == 0 {
+			return true
+		}
+
+		err := sink.Send(ctx, key.userID, text)
+		if errors.Is(err, ErrNotConfigured) {
+			// Канал не настроен (Telegram не привязан, нет webhook): уведомлять некуда
+			s.mu.Lock()
+			delete(s.queues, key)
+			s.mu.Unlock()
+			return true
+		}
+		if err != nil {
+			s.logger.Warn("Failed to deliver trade notification, will retry",
+				slog.Int("user_id", key.userID),
+				slog.String("channel", key.channel),
+				slog.Any("error", err))
+			return false
+		}
+
+		// Пока шла отправка, переполнение могло вытеснить из очереди уже отправленные сообщения
+		s.mu.Lock()
+		q := s.queues[key]
+		evicted := q.dropped - dropped
+		sentEvicted := min(evicted, taken)
+		q.messages = q.messages[min(taken-sentEvicted, len(q.messages)):]
+		q.dropped = evicted - sentEvicted
+		if len(q.messages) == 0 {
+			delete(s.queues, key)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// batch склеивает начало очереди в одно сообщение не длиннее maxLen
+// (taken - сколько уведомлений вошло, dropped - сколько вытесненных упомянуто)
+func (s *Service) batch(key queueKey, maxLen int) (string, int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, ok := s.queues[key]
+	if !ok || len(q.messages) == 0 {
+		return "", 0, 0
+	}
+
+	var b strings.Builder
+	if q.dropped > 0 {
+		fmt.Fprintf(&b, "⚠️ Пропущено уведомлений: %d (очередь переполнена)\n\n", q.dropped)
+	}
+
+	taken := 0
+	for _, message := range q.messages {
+		if taken > 0 && b.Len()+len(message)+2 > maxLen {
+			break
+		}
+		if taken > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(message)
+		taken++
+	}
+
+	return b.String(), taken, q.dropped
+}
@@ -0,0 +1,181 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"tg_mexc/internal/models"
+)
+
+// Каналы уведомлений
+const (
+	ChannelTelegram = "telegram"
+	ChannelDiscord  = "discord"
+	ChannelSlack    = "slack"
+)
+
+const webhookTimeout = 10 * time.Second
+
+// ErrNotConfigured - канал не настроен у пользователя (уведомление отбрасывается, повтор не нужен)
+var ErrNotConfigured = errors.New("notification channel is not configured")
+
+// ErrInvalidSettings возвращается для некорректных настроек уведомлений
+var ErrInvalidSettings = errors.New("invalid notification settings")
+
+// Sink - канал доставки текстовых уведомлений пользователю
+type Sink interface {
+	Send(ctx context.Context, userID int, text string) error
+	// MaxMessageLen - лимит длины сообщения канала (очередь склеивается до него)
+	MaxMessageLen() int
+}
+
+// ChatStorage - привязка пользователей к Telegram
+type ChatStorage interface {
+	GetTelegramChatID(userID int) (int64, error)
+}
+
+// SettingsStorage - настройки каналов уведомлений пользователей
+type SettingsStorage interface {
+	GetNotificationSettings(userID int) (models.NotificationSettings, error)
+}
+
+// Settings возвращает настройки уведомлений пользователя: без сохраненных настроек - только Telegram
+func Settings(storage SettingsStorage, userID int) (models.NotificationSettings, error) {
+	settings, err := storage.GetNotificationSettings(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.NotificationSettings{UserID: userID, Channels: []string{ChannelTelegram}}, nil
+	}
+
+	return settings, err
+}
+
+// ValidateSettings проверяет каналы и webhook URL выбранных каналов
+func ValidateSettings(settings models.NotificationSettings) error {
+	for _, channel := range settings.Channels {
+		if channel != ChannelTelegram && channel != ChannelDiscord && channel != ChannelSlack {
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalidSettings, channel)
+		}
+	}
+
+	if slices.Contains(settings.Channels, ChannelDiscord) || settings.DiscordWebhookURL != "" {
+		if !isWebhookURL(settings.DiscordWebhookURL, "discord.com", "discordapp.com") {
+			return fmt.Errorf("%w: discord_webhook_url must be https://discord.com/api/webhooks/...", ErrInvalidSettings)
+		}
+	}
+
+	if slices.Contains(settings.Channels, ChannelSlack) || settings.SlackWebhookURL != "" {
+		if !isWebhookURL(settings.SlackWebhookURL, "hooks.slack.com") {
+			return fmt.Errorf("%w: slack_webhook_url must be https://hooks.slack.com/...", ErrInvalidSettings)
+		}
+	}
+
+	return nil
+}
+
+func isWebhookURL(raw string, hosts ...string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Path == "" {
+		return false
+	}
+
+	return slices.Contains(hosts, u.Host)
+}
+
+// TelegramSink доставляет уведомления в привязанный Telegram чат пользователя
+type TelegramSink struct {
+	storage ChatStorage
+	sender  Sender
+}
+
+// NewTelegramSink создает канал доставки в Telegram
+func NewTelegramSink(storage ChatStorage, sender Sender) *TelegramSink {
+	return &TelegramSink{storage: storage, sender: sender}
+}
+
+func (t *TelegramSink) Send(_ context.Context, userID int, text string) error {
+	chatID, err := t.storage.GetTelegramChatID(userID)
+	if err != nil {
+		return err
+	}
+	if chatID == 0 {
+		return ErrNotConfigured
+	}
+
+	return t.sender.SendMessage(chatID, text)
+}
+
+func (t *TelegramSink) MaxMessageLen() int {
+	return 4000
+}
+
+// WebhookSink доставляет уведомления во входящий webhook Discord или Slack из настроек пользователя
+type WebhookSink struct {
+	channel    string
+	storage    SettingsStorage
+	httpClient *http.Client
+}
+
+// NewDiscordSink создает канал доставки в Discord webhook
+func NewDiscordSink(storage SettingsStorage) *WebhookSink {
+	return &WebhookSink{channel: ChannelDiscord, storage: storage, httpClient: &http.Client{Timeout: webhookTimeout}}
+}
+
+// NewSlackSink создает канал доставки в Slack incoming webhook
+func NewSlackSink(storage SettingsStorage) *WebhookSink {
+	return &WebhookSink{channel: ChannelSlack, storage: storage, httpClient: &http.Client{Timeout: webhookTimeout}}
+}
+
+func (w *WebhookSink) Send(ctx context.Context, userID int, text string) error {
+	settings, err := Settings(w.storage, userID)
+	if err != nil {
+		return err
+	}
+
+	// Discord: {"content": ...}, Slack: {"text": ...}
+	webhookURL, payload := settings.SlackWebhookURL, map[string]string{"text": text}
+	if w.channel == ChannelDiscord {
+		webhookURL, payload = settings.DiscordWebhookURL, map[string]string{"content": text}
+	}
+	if webhookURL == "" {
+		return ErrNotConfigured
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s webhook returned %s", w.channel, resp.Status)
+	}
+
+	return nil
+}
+
+func (w *WebhookSink) MaxMessageLen() int {
+	if w.channel == ChannelDiscord {
+		return 1900 // Лимит Discord - 2000 символов
+	}
+
+	return 4000
+}
@@ -0,0 +1,433 @@
+package pnl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"tg_mexc/internal/clock"
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+)
+
+const (
+	// SourceDeal - fill'ы ордеров из WebSocket (push.personal.order.deal)
+	SourceDeal = "deal"
+	// SourcePosition - закрытые позиции из истории (backfill)
+	SourcePosition = "position"
+	// SourceFunding - funding платежи по удерживаемым позициям (backfill)
+	SourceFunding = "funding"
+
+	backfillPageSize = mexc.PositionHistoryPageSize
+	backfillMaxPages = 10
+)
+
+// Storage - хранилище PnL
+type Storage interface {
+	AddPnLEntry(userID int, entry models.PnLEntry) (bool, error)
+	GetFirstPnLEntryTime(accountID int, source string) (time.Time, error)
+	GetPnLRecords(userID int, fromDay, toDay string) ([]models.PnLRecord, error)
+	SavePositionHistory(userID int, accountID int, pos models.HistoryPosition) (bool, error)
+	GetPositionStats(userID int, fromDay, toDay string) ([]models.PositionStats, error)
+	GetAccounts(userID int) ([]models.Account, error)
+	GetUserIDsWithAccounts() ([]int, error)
+}
+
+// Totals - суммарные показатели PnL
+type Totals struct {
+	RealizedPnL float64 `json:"realized_pnl"`
+	Fees        float64 `json:"fees"`
+	Funding     float64 `json:"funding"`
+	NetPnL      float64 `json:"net_pnl"`
+	Volume      float64 `json:"volume"`
+	Trades      int     `json:"trades"`
+}
+
+func (t *Totals) add(record models.PnLRecord) {
+	t.RealizedPnL += record.RealizedPnL
+	t.Fees += record.Fees
+	t.Funding += record.Funding
+	t.NetPnL += record.NetPnL()
+	t.Volume += record.Volume
+	t.Trades += record.Trades
+}
+
+// AccountSummary - PnL аккаунта за период
+type AccountSummary struct {
+	AccountID   int    `json:"account_id"`
+	AccountName string `json:"account_name"`
+	Totals
+	Positions int     `json:"positions"` // Закрытые позиции из истории биржи
+	Wins      int     `json:"wins"`
+	Losses    int     `json:"losses"`
+	WinRate   float64 `json:"win_rate"` // Доля прибыльных позиций, %
+}
+
+// SymbolSummary - PnL по символу за период
+type SymbolSummary struct {
+	Symbol string `json:"symbol"`
+	Totals
+}
+
+// DaySummary - PnL всех аккаунтов за день
+type DaySummary struct {
+	Day string `json:"day"`
+	Totals
+}
+
+// Summary - агрегированный PnL пользователя за период
+type Summary struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Totals
+	Accounts []AccountSummary `json:"accounts"`
+	Symbols  []SymbolSummary  `json:"symbols"`
+	Days     []DaySummary     `json:"days"`
+}
+
+// Service собирает реализованный PnL по аккаунтам и агрегирует его по дням
+type Service struct {
+	storage Storage
+	logger  *slog.Logger
+	clock   clock.Clock
+}
+
+// New создает новый PnL сервис
+func New(storage Storage, logger *slog.Logger) *Service {
+	return &Service{
+		storage: storage,
+		logger:  logger,
+		clock:   clock.Real,
+	}
+}
+
+// SetClock подменяет источник времени
+func (s *Service) SetClock(clk clock.Clock) {
+	s.clock = clk
+}
+
+// RecordDeal учитывает fill из WebSocket (повторная запись того же fill'а игнорируется)
+func (s *Service) RecordDeal(userID int, deal models.Deal) error {
+	entry := fromDeal(deal)
+	if entry.OccurredAt.IsZero() {
+		entry.OccurredAt = s.clock.Now()
+	}
+
+	if _, err := s.storage.AddPnLEntry(userID, entry); err != nil {
+		return fmt.Errorf("failed to add pnl entry: %w", err)
+	}
+
+	return nil
+}
+
+// Backfill догружает закрытые позиции и funding платежи из истории биржи по всем аккаунтам пользователя.
+// Все закрытые позиции сохраняются в историю позиций; в PnL позиции, закрытые после первого fill'а
+// из WebSocket, не добавляются - они уже учтены по deal событиям.
+// Возвращает количество новых записей.
+func (s *Service) Backfill(ctx context.Context, userID int) (int, error) {
+	accounts, err := s.storage.GetAccounts(userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	var (
+		added int
+		errs  []error
+	)
+	for _, acc := range accounts {
+		n, err := s.backfillAccount(ctx, userID, acc)
+		added += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", acc.Name, err))
+		}
+
+		n, err = s.backfillFunding(ctx, userID, acc)
+		added += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: funding: %w", acc.Name, err))
+		}
+	}
+
+	return added, errors.Join(errs...)
+}
+
+// RunFundingSync догружает funding платежи всех пользователей каждые interval до отмены ctx.
+// Funding не приходит по WebSocket, поэтому без периодической синхронизации он виден только после /pnl sync.
+func (s *Service) RunFundingSync(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		s.logger.Info("Funding sync disabled")
+		return
+	}
+
+	for {
+		s.syncFunding(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(interval):
+		}
+	}
+}
+
+func (s *Service) syncFunding(ctx context.Context) {
+	userIDs, err := s.storage.GetUserIDsWithAccounts()
+	if err != nil {
+		s.logger.Error("Failed to list users for funding sync", slog.Any("error", err))
+		return
+	}
+
+	for _, userID := range userIDs {
+		accounts, err := s.storage.GetAccounts(userID)
+		if err != nil {
+			s.logger.Error("Failed to get accounts for funding sync",
+				slog.Int("user_id", userID),
+				slog.Any("error", err))
+			continue
+		}
+
+		for _, acc := range accounts {
+			if ctx.Err() != nil {
+				return
+			}
+
+			if _, err := s.backfillFunding(ctx, userID, acc); err != nil {
+				s.logger.Warn("Funding sync failed",
+					slog.String("account", acc.Name),
+					slog.Any("error", err))
+			}
+		}
+	}
+}
+
+func (s *Service) backfillAccount(ctx context.Context, userID int, acc models.Account) (int, error) {
+	dealsSince, err := s.storage.GetFirstPnLEntryTime(acc.ID, SourceDeal)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get first deal time: %w", err)
+	}
+
+	client, err := mexc.NewClient(acc, s.logger)
+	if err != nil {
+		return 0, err
+	}
+	client.SetClock(s.clock)
+
+	// История отдается от новых к старым: страница без новых записей означает, что дальше все уже учтено
+	added := 0
+	fetch := func(ctx context.Context, pageNum, pageSize int) ([]models.HistoryPosition, error) {
+		return client.GetHistoryPositions(ctx, "", pageNum, pageSize)
+	}
+	err = mexc.Paginate(ctx, fetch, backfillPageSize, backfillMaxPages, func(positions []models.HistoryPosition) error {
+		pageAdded := 0
+		for _, pos := range positions {
+			saved, err := s.storage.SavePositionHistory(userID, acc.ID, pos)
+			if err != nil {
+				return fmt.Errorf("failed to save position history: %w", err)
+			}
+			if saved {
+				pageAdded++
+			}
+
+			entry := fromHistoryPosition(acc.ID, pos)
+			if !dealsSince.IsZero() && !entry.OccurredAt.Before(dealsSince) {
+				pageAdded++ // учтено по deal событиям, продолжаем листать
+				continue
+			}
+
+			ok, err := s.storage.AddPnLEntry(userID, entry)
+			if err != nil {
+				return fmt.Errorf("failed to add pnl entry: %w", err)
+			}
+			if ok {
+				added++
+				pageAdded++
+			}
+		}
+
+		if pageAdded == 0 {
+			return mexc.ErrStopPaging
+		}
+		return nil
+	})
+
+	return added, err
+}
+
+// backfillFunding догружает funding платежи аккаунта (единственный источник funding, поэтому без отсечки по времени)
+func (s *Service) backfillFunding(ctx context.Context, userID int, acc models.Account) (int, error) {
+	client, err := mexc.NewClient(acc, s.logger)
+	if err != nil {
+		return 0, err
+	}
+	client.SetClock(s.clock)
+
+	// История отдается от новых к старым: страница без новых записей означает, что дальше все уже учтено
+	added := 0
+	fetch := func(ctx context.Context, pageNum, pageSize int) ([]models.FundingRecord, error) {
+		return client.GetFundingRecords(ctx, "", pageNum, pageSize)
+	}
+	err = mexc.Paginate(ctx, fetch, backfillPageSize, backfillMaxPages, func(records []models.FundingRecord) error {
+		pageAdded := 0
+		for _, record := range records {
+			ok, err := s.storage.AddPnLEntry(userID, fromFundingRecord(acc.ID, record))
+			if err != nil {
+				return fmt.Errorf("failed to add pnl entry: %w", err)
+			}
+			if ok {
+				added++
+				pageAdded++
+			}
+		}
+
+		if pageAdded == 0 {
+			return mexc.ErrStopPaging
+		}
+		return nil
+	})
+
+	return added, err
+}
+
+// Daily возвращает дневные записи PnL за последние days дней (включая сегодня, UTC)
+func (s *Service) Daily(userID int, days int) ([]models.PnLRecord, error) {
+	from, to := s.period(days)
+	return s.storage.GetPnLRecords(userID, from, to)
+}
+
+// Summary возвращает PnL за последние days дней (включая сегодня, UTC) в разрезе аккаунтов, символов и дней
+func (s *Service) Summary(userID int, days int) (Summary, error) {
+	from, to := s.period(days)
+	return s.SummaryBetween(userID, from, to)
+}
+
+// SummaryBetween возвращает PnL за дни from..to включительно (YYYY-MM-DD, UTC)
+func (s *Service) SummaryBetween(userID int, from, to string) (Summary, error) {
+	records, err := s.storage.GetPnLRecords(userID, from, to)
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed to get pnl records: %w", err)
+	}
+
+	summary := Summary{
+		From:     from,
+		To:       to,
+		Accounts: []AccountSummary{},
+		Symbols:  []SymbolSummary{},
+		Days:     []DaySummary{},
+	}
+
+	accountIdx := make(map[int]int)
+	symbolIdx := make(map[string]int)
+	dayIdx := make(map[string]int)
+
+	for _, record := range records {
+		summary.add(record)
+
+		i, ok := accountIdx[record.AccountID]
+		if !ok {
+			i = len(summary.Accounts)
+			accountIdx[record.AccountID] = i
+			summary.Accounts = append(summary.Accounts, AccountSummary{AccountID: record.AccountID, AccountName: record.AccountName})
+		}
+		summary.Accounts[i].add(record)
+
+		i, ok = symbolIdx[record.Symbol]
+		if !ok {
+			i = len(summary.Symbols)
+			symbolIdx[record.Symbol] = i
+			summary.Symbols = append(summary.Symbols, SymbolSummary{Symbol: record.Symbol})
+		}
+		summary.Symbols[i].add(record)
+
+		// Записи отсортированы по дню, поэтому дни идут по порядку
+		i, ok = dayIdx[record.Day]
+		if !ok {
+			i = len(summary.Days)
+			dayIdx[record.Day] = i
+			summary.Days = append(summary.Days, DaySummary{Day: record.Day})
+		}
+		summary.Days[i].add(record)
+	}
+
+	stats, err := s.storage.GetPositionStats(userID, from, to)
+	if err != nil {
+		return Summary{}, fmt.Errorf("failed to get position stats: %w", err)
+	}
+
+	for _, stat := range stats {
+		i, ok := accountIdx[stat.AccountID]
+		if !ok {
+			i = len(summary.Accounts)
+			accountIdx[stat.AccountID] = i
+			summary.Accounts = append(summary.Accounts, AccountSummary{AccountID: stat.AccountID, AccountName: stat.AccountName})
+		}
+		summary.Accounts[i].addPositions(stat)
+	}
+
+	return summary, nil
+}
+
+// addPositions добавляет итоги закрытых позиций аккаунта
+func (a *AccountSummary) addPositions(stat models.PositionStats) {
+	a.Positions += stat.Positions
+	a.Wins += stat.Wins
+	a.Losses += stat.Losses
+	if a.Positions > 0 {
+		a.WinRate = float64(a.Wins) / float64(a.Positions) * 100
+	}
+}
+
+// period возвращает границы периода в формате YYYY-MM-DD
+func (s *Service) period(days int) (string, string) {
+	if days < 1 {
+		days = 1
+	}
+
+	to := s.clock.Now().UTC()
+	from := to.AddDate(0, 0, -(days - 1))
+
+	return from.Format(time.DateOnly), to.Format(time.DateOnly)
+}
+
+// fromDeal конвертирует fill в событие PnL
+func fromDeal(deal models.Deal) models.PnLEntry {
+	return models.PnLEntry{
+		AccountID:  deal.AccountID,
+		Source:     SourceDeal,
+		Ref:        deal.ID,
+		Symbol:     deal.Symbol,
+		Realized:   deal.Profit,
+		Fee:        deal.Fee,
+		Volume:     deal.Vol,
+		OccurredAt: deal.At,
+	}
+}
+
+// fromHistoryPosition конвертирует закрытую позицию в событие PnL.
+// Комиссии считаются как разница PnL по цене и итогового realised без funding (он учитывается отдельно).
+func fromHistoryPosition(accountID int, pos models.HistoryPosition) models.PnLEntry {
+	return models.PnLEntry{
+		AccountID:  accountID,
+		Source:     SourcePosition,
+		Ref:        strconv.FormatInt(pos.PositionID, 10),
+		Symbol:     pos.Symbol,
+		Realized:   pos.CloseProfitLoss,
+		Fee:        pos.CloseProfitLoss + pos.HoldFee - pos.Realised,
+		Volume:     pos.CloseVol,
+		OccurredAt: time.UnixMilli(pos.UpdateTime).UTC(),
+	}
+}
+
+// fromFundingRecord конвертирует funding платеж в событие PnL
+func fromFundingRecord(accountID int, record models.FundingRecord) models.PnLEntry {
+	return models.PnLEntry{
+		AccountID:  accountID,
+		Source:     SourceFunding,
+		Ref:        strconv.FormatInt(record.ID, 10),
+		Symbol:     record.Symbol,
+		Funding:    record.Funding,
+		OccurredAt: time.UnixMilli(record.SettleTime).UTC(),
+	}
+}
@@ -0,0 +1,74 @@
+package reconcile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Виды расхождений позиций slave аккаунта с master
+const (
+	KindOrphan  = "orphan"  // slave держит позицию, которой нет у master
+	KindMissing = "missing" // у slave нет позиции, открытой у master
+	KindSize    = "size"    // объём позиции slave не соответствует позиции master
+)
+
+// Действия исправления (inline кнопки в Telegram)
+const (
+	ActionClose  = "close"  // закрыть лишнюю позицию slave
+	ActionOpen   = "open"   // открыть недостающую позицию на slave
+	ActionResize = "resize" // довести объём позиции slave до ожидаемого
+)
+
+// callbackPrefix - префикс callback data кнопок исправления
+const callbackPrefix = "fix"
+
+// Action - исправление расхождения по нажатию inline кнопки
+type Action struct {
+	Type         string // ActionClose, ActionOpen или ActionResize
+	AccountID    int
+	Symbol       string
+	PositionType int // 1 = long, 2 = short
+}
+
+// Data кодирует действие в callback data: fix:<type>:<account_id>:<symbol>:<position_type>
+func (a Action) Data() string {
+	return fmt.Sprintf("%s:%s:%d:%s:%d", callbackPrefix, a.Type, a.AccountID, a.Symbol, a.PositionType)
+}
+
+// ParseAction разбирает callback data кнопки исправления (false - чужой или некорректный callback)
+func ParseAction(data string) (Action, bool) {
+	parts := strings.Split(data, ":")
+	if len(parts) != 5 || parts[0] != callbackPrefix {
+		return Action{}, false
+	}
+
+	if parts[1] != ActionClose && parts[1] != ActionOpen && parts[1] != ActionResize {
+		return Action{}, false
+	}
+
+	accountID, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return Action{}, false
+	}
+
+	positionType, err := strconv.Atoi(parts[4])
+	if err != nil || (positionType != 1 && positionType != 2) || parts[3] == "" {
+		return Action{}, false
+	}
+
+	return Action{
+		Type:         parts[1],
+		AccountID:    accountID,
+		Symbol:       parts[3],
+		PositionType: positionType,
+	}, true
+}
+
+func positionTypeText(positionType int) string {
+	if positionType == 2 {
+		return "SHORT"
+	}
+
+	return "LONG"
+}
@@ -0,0 +1,694 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"tg_mexc/internal/clock"
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/mexc/copytrading"
+	"tg_mexc/internal/models"
+	"tg_mexc/internal/notifier"
+	"tg_mexc/internal/telegram"
+)
+
+const (
+	checkTimeout = 30 * time.Second
+)
+
+// ErrNoDivergence - расхождение уже устранено (исправлять нечего)
+var ErrNoDivergence = errors.New("divergence already resolved")
+
+// ErrDryRun - engine работает в DRY_RUN: исправление не отправляет ордера
+var ErrDryRun = errors.New("DRY_RUN: orders are not sent")
+
+// Storage - аккаунты пользователей, привязка к Telegram и журнал активности
+type Storage interface {
+	GetMasterAccount(userID int) (models.Account, error)
+	GetSlaveAccounts(userID int, includeDisabled bool) ([]models.Account, error)
+	GetAccounts(userID int) ([]models.Account, error)
+	GetTelegramChatID(userID int) (int64, error)
+	AddLog(ctx context.Context, log models.ActivityLog) error
+}
+
+// SessionLister - источник пользователей с активной сессией copy trading
+type SessionLister interface {
+	ActiveUserIDs() []int
+}
+
+// ButtonSender отправляет сообщения с inline кнопками в Telegram
+type ButtonSender interface {
+	SendMessageWithButtons(chatID int64, text string, rows [][]telegram.Button) error
+}
+
+// EntryGate - проверки входа copy trading engine (copytrading.Engine): недостающая позиция, которую engine
+// не открыл намеренно (фильтр символов, дневной убыток, предел позиций, DRY_RUN), не расхождение,
+// и исправление ее не открывает. Объём недостающей позиции считает engine (пропорциональный объём, множитель,
+// ограничения аккаунта). Клиенты аккаунтов берутся из пула engine. Объём не сверяется у пользователей
+// с пропорциональным объёмом (флаг proportional_sizing)
+type EntryGate interface {
+	CheckEntry(ctx context.Context, userID int, acc models.Account, symbol string) error
+	Client(acc models.Account) (*mexc.Client, error)
+	DryRun() bool
+	UserSizingMode(userID int) copytrading.SizingMode
+	EntryVolume(ctx context.Context, userID int, acc models.Account, symbol string, side int, masterVol float64) (float64, error)
+}
+
+// EventNotifier доставляет сообщения в каналы уведомлений пользователя (notifier.Service)
+type EventNotifier interface {
+	NotifyEvent(userID int, text string, skip ...string)
+}
+
+// Divergence - позиция, которая есть только у master или только у slave, или позиция slave
+// с объёмом, не соответствующим master
+type Divergence struct {
+	UserID       int
+	AccountID    int
+	AccountName  string
+	Kind         string // KindOrphan, KindMissing или KindSize
+	Symbol       string
+	PositionType int
+	Volume       float64 // Объем позиции у того, кто ее держит (KindSize - у slave)
+	Expected     float64 // KindSize: объём позиции slave по позиции master
+}
+
+func (d Divergence) key() string {
+	return fmt.Sprintf("%d:%d:%s:%d:%s", d.UserID, d.AccountID, d.Symbol, d.PositionType, d.Kind)
+}
+
+// Fix возвращает действие, устраняющее расхождение
+func (d Divergence) Fix() Action {
+	action := Action{
+		Type:         ActionOpen,
+		AccountID:    d.AccountID,
+		Symbol:       d.Symbol,
+		PositionType: d.PositionType,
+	}
+	switch d.Kind {
+	case KindOrphan:
+		action.Type = ActionClose
+	case KindSize:
+		action.Type = ActionResize
+	}
+
+	return action
+}
+
+// text описывает расхождение для пользователя (grace - сколько оно держится)
+func (d Divergence) text(grace time.Duration) string {
+	side := positionTypeText(d.PositionType)
+	switch d.Kind {
+	case KindOrphan:
+		return fmt.Sprintf("⚠️ РАСХОЖДЕНИЕ ПОЗИЦИЙ\n\nАккаунт: %s\n%s %s (vol %v) открыта на slave, но ее нет у мастера дольше %s",
+			d.AccountName, d.Symbol, side, d.Volume, grace)
+	case KindSize:
+		return fmt.Sprintf("⚠️ РАСХОЖДЕНИЕ ПОЗИЦИЙ\n\nАккаунт: %s\nОбъём %s %s на slave %v, а по позиции мастера должен быть %v дольше %s",
+			d.AccountName, d.Symbol, side, d.Volume, d.Expected, grace)
+	}
+
+	return fmt.Sprintf("⚠️ РАСХОЖДЕНИЕ ПОЗИЦИЙ\n\nАккаунт: %s\nУ мастера открыта %s %s (vol %v), а на slave ее нет дольше %s",
+		d.AccountName, d.Symbol, side, d.Volume, grace)
+}
+
+// button - inline кнопка исправления расхождения
+func (d Divergence) button() telegram.Button {
+	text := fmt.Sprintf("➕ Открыть на %s", d.AccountName)
+	switch d.Kind {
+	case KindOrphan:
+		text = fmt.Sprintf("❌ Закрыть на %s", d.AccountName)
+	case KindSize:
+		text = fmt.Sprintf("⚖️ Выровнять на %s", d.AccountName)
+	}
+
+	return telegram.Button{Text: text, Data: d.Fix().Data()}
+}
+
+// logMessage описывает расхождение для журнала активности
+func (d Divergence) logMessage() string {
+	side := positionTypeText(d.PositionType)
+	switch d.Kind {
+	case KindOrphan:
+		return fmt.Sprintf("Position drift on %s: %s %s (vol %v) is open on the slave but not on the master",
+			d.AccountName, d.Symbol, side, d.Volume)
+	case KindSize:
+		return fmt.Sprintf("Position drift on %s: %s %s volume is %v on the slave, %v expected from the master",
+			d.AccountName, d.Symbol, side, d.Volume, d.Expected)
+	}
+
+	return fmt.Sprintf("Position drift on %s: %s %s (vol %v) is open on the master but missing on the slave",
+		d.AccountName, d.Symbol, side, d.Volume)
+}
+
+// tracked - расхождение, наблюдаемое с firstSeen
+type tracked struct {
+	userID    int
+	firstSeen time.Time
+	notified  bool
+}
+
+// Service периодически сверяет позиции slave аккаунтов с master для активных сессий
+// и сообщает о расхождениях, которые держатся дольше grace периода (или исправляет их сам, SetAutoFix)
+type Service struct {
+	storage       Storage
+	sessions      SessionLister
+	telegram      ButtonSender  // nil - без сообщений с кнопками исправления
+	events        EventNotifier // nil - без сообщений в каналы уведомлений
+	gate          EntryGate     // nil - недостающие позиции не сверяются с проверками входа engine
+	interval      time.Duration
+	grace         time.Duration
+	autoFix       bool
+	sizeTolerance float64 // Допустимое отклонение объёма slave, % (0 - объём не сверяется)
+	logger        *slog.Logger
+	clock         clock.Clock
+
+	mu   sync.Mutex
+	seen map[string]*tracked // Divergence.key() -> наблюдение
+}
+
+// New создает сервис сверки позиций.
+// interval - период сверки (0 отключает фоновую задачу), grace - сколько расхождение
+// должно держаться до уведомления (отсекает ордера, которые еще исполняются)
+func New(storage Storage, sessions SessionLister, interval, grace time.Duration, logger *slog.Logger) *Service {
+	return &Service{
+		storage:  storage,
+		sessions: sessions,
+		interval: interval,
+		grace:    grace,
+		logger:   logger,
+		clock:    clock.Real,
+		seen:     make(map[string]*tracked),
+	}
+}
+
+// SetTelegram включает уведомления о расхождениях с кнопками исправления
+func (s *Service) SetTelegram(sender ButtonSender) {
+	s.telegram = sender
+}
+
+// SetEntryGate подключает проверки входа engine и его пул MEXC клиентов
+func (s *Service) SetEntryGate(gate EntryGate) {
+	s.gate = gate
+}
+
+// SetNotifier включает сообщения о расхождениях в каналы уведомлений пользователя (Discord, Slack,
+// Telegram без кнопок - если сообщение с кнопками уже отправлено, в Telegram оно не дублируется)
+func (s *Service) SetNotifier(events EventNotifier) {
+	s.events = events
+}
+
+// SetAutoFix включает исправление расхождений без подтверждения: расхождение старше grace периода
+// исправляется сразу (Apply), пользователь получает сообщение с результатом
+func (s *Service) SetAutoFix(enabled bool) {
+	s.autoFix = enabled
+}
+
+// SetSizeTolerance включает сверку объёма: позиция slave, отличающаяся от объёма master × множитель
+// аккаунта больше чем на tolerancePct % (и хотя бы на контракт), - расхождение KindSize
+func (s *Service) SetSizeTolerance(tolerancePct float64) {
+	s.sizeTolerance = tolerancePct
+}
+
+// SetClock подменяет источник времени
+func (s *Service) SetClock(clk clock.Clock) {
+	s.clock = clk
+}
+
+// Run сверяет позиции каждые interval до отмены ctx
+func (s *Service) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		s.logger.Info("Position reconciliation disabled")
+		return
+	}
+
+	for {
+		s.CheckAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.interval):
+		}
+	}
+}
+
+// CheckAll сверяет позиции всех пользователей с активной сессией и сообщает
+// о расхождениях старше grace периода (каждое расхождение - один раз, пока оно не устранено)
+func (s *Service) CheckAll(ctx context.Context) {
+	active := make(map[int]bool)
+	checked := make(map[int]bool)
+	current := make(map[string]bool)
+
+	for _, userID := range s.sessions.ActiveUserIDs() {
+		active[userID] = true
+		if ctx.Err() != nil {
+			return
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		divergences, err := s.Detect(checkCtx, userID)
+		cancel()
+		if err != nil {
+			// Наблюдения пользователя сохраняются: сбой API не должен сбрасывать grace период
+			s.logger.Warn("Failed to reconcile positions", slog.Int("user_id", userID), slog.Any("error", err))
+			continue
+		}
+		checked[userID] = true
+
+		for _, d := range divergences {
+			current[d.key()] = true
+			if s.due(d) {
+				fixCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+				s.report(fixCtx, d)
+				cancel()
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, t := range s.seen {
+		if !current[key] && (checked[t.userID] || !active[t.userID]) {
+			delete(s.seen, key)
+		}
+	}
+}
+
+// Detect сравнивает открытые позиции master и включенных slave аккаунтов пользователя: пропущенные
+// открытия и закрытия и, с SetSizeTolerance, расхождение объёма
+func (s *Service) Detect(ctx context.Context, userID int) ([]Divergence, error) {
+	master, err := s.storage.GetMasterAccount(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get master account: %w", err)
+	}
+
+	slaves, err := s.storage.GetSlaveAccounts(userID, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get slave accounts: %w", err)
+	}
+
+	masterPositions, err := s.openPositions(ctx, master, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get master positions: %w", err)
+	}
+
+	var divergences []Divergence
+	for _, slave := range slaves {
+		slavePositions, err := s.openPositions(ctx, slave, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get positions of %s: %w", slave.Name, err)
+		}
+
+		for key, pos := range slavePositions {
+			if _, ok := masterPositions[key]; !ok {
+				divergences = append(divergences, newDivergence(userID, slave, KindOrphan, pos))
+			}
+		}
+
+		for key, pos := range masterPositions {
+			slavePos, ok := slavePositions[key]
+			if !ok {
+				if s.entryBlocked(ctx, userID, slave, pos.Symbol) == nil {
+					divergences = append(divergences, newDivergence(userID, slave, KindMissing, pos))
+				}
+				continue
+			}
+
+			expected := expectedVolume(slave, pos.HoldVol)
+			if !s.sizeMismatch(userID, slave, expected, slavePos.HoldVol) {
+				continue
+			}
+			// Меньший объём добирается входом: если engine его не скопировал бы, это не расхождение
+			if expected > slavePos.HoldVol && s.entryBlocked(ctx, userID, slave, pos.Symbol) != nil {
+				continue
+			}
+
+			d := newDivergence(userID, slave, KindSize, slavePos)
+			d.Expected = expected
+			divergences = append(divergences, d)
+		}
+	}
+
+	return divergences, nil
+}
+
+// Apply выполняет исправление расхождения на slave аккаунте пользователя.
+// Перед исправлением расхождение проверяется заново: если оно устранено, возвращается ErrNoDivergence
+func (s *Service) Apply(ctx context.Context, userID int, action Action) (string, error) {
+	slave, err := s.slaveAccount(userID, action.AccountID)
+	if err != nil {
+		return "", err
+	}
+
+	master, err := s.storage.GetMasterAccount(userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get master account: %w", err)
+	}
+
+	key := positionKey{symbol: action.Symbol, positionType: action.PositionType}
+
+	masterPositions, err := s.openPositions(ctx, master, action.Symbol)
+	if err != nil {
+		return "", fmt.Errorf("failed to get master positions: %w", err)
+	}
+	slavePositions, err := s.openPositions(ctx, slave, action.Symbol)
+	if err != nil {
+		return "", fmt.Errorf("failed to get positions of %s: %w", slave.Name, err)
+	}
+
+	client, err := s.client(slave)
+	if err != nil {
+		return "", fmt.Errorf("failed to create client: %w", err)
+	}
+
+	masterPos, masterHas := masterPositions[key]
+	slavePos, slaveHas := slavePositions[key]
+	side := positionTypeText(action.PositionType)
+
+	if s.gate != nil && s.gate.DryRun() {
+		return "", ErrDryRun
+	}
+
+	switch action.Type {
+	case ActionClose:
+		if masterHas || !slaveHas {
+			return "", ErrNoDivergence
+		}
+
+		if err := client.ClosePositionType(ctx, action.Symbol, action.PositionType); err != nil {
+			return "", err
+		}
+
+		s.logger.Info("✅ Orphan position closed",
+			slog.String("account", slave.Name),
+			slog.String("symbol", action.Symbol),
+			slog.String("type", side))
+
+		return fmt.Sprintf("✅ Позиция %s %s на %s закрыта", action.Symbol, side, slave.Name), nil
+
+	case ActionOpen:
+		if !masterHas || slaveHas {
+			return "", ErrNoDivergence
+		}
+		if err := s.entryBlocked(ctx, userID, slave, action.Symbol); err != nil {
+			return "", fmt.Errorf("entry is not copied by engine: %w", err)
+		}
+
+		vol, err := s.entryVolume(ctx, userID, slave, action, masterPos.HoldVol)
+		if err != nil {
+			return "", fmt.Errorf("entry is not copied by engine: %w", err)
+		}
+		leverage, err := s.openVolume(ctx, client, action, vol, masterPos)
+		if err != nil {
+			return "", err
+		}
+
+		s.logger.Info("✅ Missing position opened",
+			slog.String("account", slave.Name),
+			slog.String("symbol", action.Symbol),
+			slog.String("type", side),
+			slog.Float64("vol", vol))
+
+		return fmt.Sprintf("✅ Позиция %s %s x%d (vol %v) открыта на %s", action.Symbol, side, leverage, vol, slave.Name), nil
+
+	case ActionResize:
+		if !masterHas || !slaveHas {
+			return "", ErrNoDivergence
+		}
+
+		expected := expectedVolume(slave, masterPos.HoldVol)
+		if !s.sizeMismatch(userID, slave, expected, slavePos.HoldVol) {
+			return "", ErrNoDivergence
+		}
+
+		// Добор объёма не проходит ограничения аккаунта и пропорциональный объём: sizeMismatch не считает
+		// меньший объём таких slave расхождением, и сюда они не доходят
+		diff := expected - slavePos.HoldVol
+		if diff > 0 {
+			if err := s.entryBlocked(ctx, userID, slave, action.Symbol); err != nil {
+				return "", fmt.Errorf("entry is not copied by engine: %w", err)
+			}
+			if _, err := s.openVolume(ctx, client, action, diff, slavePos); err != nil {
+				return "", err
+			}
+		} else if err := client.ClosePositionPartial(ctx, action.Symbol, int(-diff), slavePos.PositionID); err != nil {
+			return "", err
+		}
+
+		s.logger.Info("✅ Position volume aligned",
+			slog.String("account", slave.Name),
+			slog.String("symbol", action.Symbol),
+			slog.String("type", side),
+			slog.Float64("from", slavePos.HoldVol),
+			slog.Float64("to", expected))
+
+		return fmt.Sprintf("✅ Объём %s %s на %s приведен к %v (был %v)", action.Symbol, side, slave.Name, expected, slavePos.HoldVol), nil
+	}
+
+	return "", fmt.Errorf("unknown action %q", action.Type)
+}
+
+// entryBlocked возвращает причину, по которой engine не скопировал бы вход в symbol на slave (nil - скопировал бы).
+// В DRY_RUN engine ордера не отправляет: позиции slave не повторяют master намеренно
+func (s *Service) entryBlocked(ctx context.Context, userID int, slave models.Account, symbol string) error {
+	if s.gate == nil {
+		return nil
+	}
+	if s.gate.DryRun() {
+		return ErrDryRun
+	}
+
+	if err := s.gate.CheckEntry(ctx, userID, slave, symbol); err != nil {
+		s.logger.Debug("Missing position skipped, engine does not copy the entry",
+			slog.String("account", slave.Name),
+			slog.String("symbol", symbol),
+			slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// client возвращает MEXC клиент аккаунта: из пула engine (SetEntryGate) или новый
+func (s *Service) client(acc models.Account) (*mexc.Client, error) {
+	if s.gate != nil {
+		return s.gate.Client(acc)
+	}
+
+	return mexc.NewClient(acc, s.logger)
+}
+
+// openVolume открывает vol контрактов в направлении action на slave. Плечо - текущее плечо slave,
+// при ошибке - плечо ref (позиции master или slave), как и тип маржи. Возвращает плечо ордера
+func (s *Service) openVolume(ctx context.Context, client *mexc.Client, action Action, vol float64, ref models.Position) (int, error) {
+	orderSide := openSide(action.PositionType)
+
+	leverage, err := client.GetLeverageForSide(ctx, action.Symbol, orderSide)
+	if err != nil {
+		leverage = ref.Leverage
+	}
+
+	if _, err := client.PlaceOrder(ctx, action.Symbol, orderSide, int(vol), leverage, ref.OpenType); err != nil {
+		return 0, err
+	}
+
+	return leverage, nil
+}
+
+// expectedVolume - объём позиции slave, соответствующий позиции master (множитель объёма аккаунта)
+func expectedVolume(slave models.Account, masterVol float64) float64 {
+	return math.Round(masterVol * slave.VolumeMultiplier())
+}
+
+// entryVolume возвращает объём недостающей позиции slave: через engine, как при копировании входа, без него -
+// объём мастера × множитель аккаунта
+func (s *Service) entryVolume(ctx context.Context, userID int, slave models.Account, action Action, masterVol float64) (float64, error) {
+	if s.gate == nil {
+		return max(expectedVolume(slave, masterVol), 1), nil
+	}
+
+	return s.gate.EntryVolume(ctx, userID, slave, action.Symbol, openSide(action.PositionType), masterVol)
+}
+
+// openSide возвращает сторону ордера открытия позиции positionType (1=long, 2=short)
+func openSide(positionType int) int {
+	if positionType == 2 {
+		return 3 // open short
+	}
+	return 1 // open long
+}
+
+// sizeMismatch сообщает, что объём позиции slave отличается от ожидаемого больше допуска SetSizeTolerance
+func (s *Service) sizeMismatch(userID int, slave models.Account, expected, actual float64) bool {
+	if s.sizeTolerance <= 0 {
+		return false
+	}
+	// Пропорциональный объём зависит от equity, а не от объёма мастера
+	if s.gate != nil && s.gate.UserSizingMode(userID) == copytrading.SizingProportional {
+		return false
+	}
+
+	diff := actual - expected
+	// Ограничения slave (CopyCaps) намеренно уменьшают входы: меньший объём - не расхождение
+	if diff < 0 && (slave.Caps.MaxOrderVol > 0 || slave.Caps.Notional()) {
+		return false
+	}
+
+	return math.Abs(diff) >= 1 && math.Abs(diff) > expected*s.sizeTolerance/100
+}
+
+// due отмечает наблюдение расхождения и сообщает, пора ли о нем уведомить
+func (s *Service) due(d Divergence) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	t, ok := s.seen[d.key()]
+	if !ok {
+		s.seen[d.key()] = &tracked{userID: d.UserID, firstSeen: now}
+		return s.grace <= 0
+	}
+
+	if t.notified || now.Sub(t.firstSeen) < s.grace {
+		return false
+	}
+
+	t.notified = true
+	return true
+}
+
+// report записывает расхождение в журнал активности и сообщает о нем пользователю: в Telegram - с кнопкой
+// исправления, в каналы уведомлений - текстом. С SetAutoFix расхождение сначала исправляется,
+// кнопка остается только при неудаче
+func (s *Service) report(ctx context.Context, d Divergence) {
+	s.logger.Warn("⚠️ Position divergence",
+		slog.Int("user_id", d.UserID),
+		slog.String("account", d.AccountName),
+		slog.String("kind", d.Kind),
+		slog.String("symbol", d.Symbol),
+		slog.String("type", positionTypeText(d.PositionType)))
+
+	s.addLog(ctx, d.UserID, "warn", "position_drift", d.logMessage())
+
+	text := d.text(s.grace)
+	rows := [][]telegram.Button{{d.button()}}
+
+	if s.autoFix {
+		result, err := s.Apply(ctx, d.UserID, d.Fix())
+		switch {
+		case errors.Is(err, ErrNoDivergence):
+			return
+		case errors.Is(err, ErrDryRun):
+			text += "\n\n🧪 DRY_RUN: исправление не отправлено"
+			rows = nil
+		case err != nil:
+			s.logger.Error("Divergence auto-fix failed",
+				slog.Int("user_id", d.UserID),
+				slog.String("account", d.AccountName),
+				slog.String("symbol", d.Symbol),
+				slog.Any("error", err))
+			s.addLog(ctx, d.UserID, "error", "position_drift_fix_failed", fmt.Sprintf("%s: auto-fix failed: %v", d.logMessage(), err))
+			text += fmt.Sprintf("\n\n❌ Автоисправление не удалось: %v", err)
+		default:
+			s.addLog(ctx, d.UserID, "info", "position_drift_fixed", fmt.Sprintf("%s: fixed automatically", d.logMessage()))
+			text += "\n\n" + result
+			rows = nil
+		}
+	}
+
+	s.send(d.UserID, text, rows)
+}
+
+// send доставляет сообщение о расхождении: с кнопками - в Telegram, затем в каналы уведомлений пользователя
+// (кроме Telegram, если сообщение с кнопками туда уже ушло)
+func (s *Service) send(userID int, text string, rows [][]telegram.Button) {
+	var skip []string
+	if s.telegram != nil && len(rows) > 0 {
+		chatID, err := s.storage.GetTelegramChatID(userID)
+		if err == nil && chatID != 0 {
+			if err := s.telegram.SendMessageWithButtons(chatID, text, rows); err != nil {
+				s.logger.Warn("Failed to send divergence alert", slog.Int("user_id", userID), slog.Any("error", err))
+			} else {
+				skip = append(skip, notifier.ChannelTelegram)
+			}
+		}
+	}
+
+	if s.events != nil {
+		s.events.NotifyEvent(userID, text, skip...)
+	}
+}
+
+// addLog записывает расхождение или его исправление в журнал активности пользователя
+func (s *Service) addLog(ctx context.Context, userID int, level, action, message string) {
+	err := s.storage.AddLog(context.WithoutCancel(ctx), models.ActivityLog{
+		UserID:  &userID,
+		Level:   level,
+		Action:  action,
+		Message: message,
+	})
+	if err != nil {
+		s.logger.Warn("Failed to write divergence log", slog.Int("user_id", userID), slog.Any("error", err))
+	}
+}
+
+func (s *Service) slaveAccount(userID int, accountID int) (models.Account, error) {
+	accounts, err := s.storage.GetAccounts(userID)
+	if err != nil {
+		return models.Account{}, fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	for _, acc := range accounts {
+		if acc.ID == accountID && !acc.IsMaster {
+			return acc, nil
+		}
+	}
+
+	return models.Account{}, fmt.Errorf("slave account %d not found", accountID)
+}
+
+// positionKey - позиция по символу и направлению
+type positionKey struct {
+	symbol       string
+	positionType int
+}
+
+// openPositions возвращает открытые позиции аккаунта (symbol = "" - по всем символам)
+func (s *Service) openPositions(ctx context.Context, acc models.Account, symbol string) (map[positionKey]models.Position, error) {
+	client, err := s.client(acc)
+	if err != nil {
+		return nil, err
+	}
+
+	positions, err := client.GetPositions(ctx, symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[positionKey]models.Position, len(positions))
+	for _, pos := range positions {
+		if pos.HoldVol <= 0 || (symbol != "" && pos.Symbol != symbol) {
+			continue
+		}
+		result[positionKey{symbol: pos.Symbol, positionType: pos.PositionType}] = pos
+	}
+
+	return result, nil
+}
+
+func newDivergence(userID int, slave models.Account, kind string, pos models.Position) Divergence {
+	return Divergence{
+		UserID:       userID,
+		AccountID:    slave.ID,
+		AccountName:  slave.Name,
+		Kind:         kind,
+		Symbol:       pos.Symbol,
+		PositionType: pos.PositionType,
+		Volume:       pos.HoldVol,
+	}
+}
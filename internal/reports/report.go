@@ -0,0 +1,155 @@
+package reports
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"slices"
+	"time"
+
+	"tg_mexc/internal/analytics"
+	"tg_mexc/internal/fees"
+	"tg_mexc/internal/models"
+	"tg_mexc/internal/pnl"
+)
+
+// Периоды отчета
+const (
+	PeriodDaily  = "daily"
+	PeriodWeekly = "weekly"
+)
+
+// Каналы доставки
+const (
+	ChannelTelegram = "telegram"
+	ChannelEmail    = "email"
+)
+
+// ErrInvalidSettings возвращается для некорректной подписки на отчеты
+var ErrInvalidSettings = errors.New("invalid report settings")
+
+//go:embed report.html
+var reportHTML string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"usd": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"ms":  func(v float64) string { return fmt.Sprintf("%.0f", v) },
+	"ts":  func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04") },
+}).Parse(reportHTML))
+
+// Report - сводка пользователя за период [From, To)
+type Report struct {
+	Period      string                  `json:"period"`
+	From        time.Time               `json:"from"`
+	To          time.Time               `json:"to"`
+	GeneratedAt time.Time               `json:"generated_at"`
+	Trades      models.TradeStats       `json:"trades"`
+	PnL         pnl.Summary             `json:"pnl"`
+	Fees        fees.Summary            `json:"fees"`
+	Latency     analytics.LatencyStages `json:"latency"`
+	Incidents   []models.AlertEvent     `json:"incidents"`
+}
+
+// Title возвращает заголовок отчета (тема письма / подпись документа)
+func (r Report) Title() string {
+	if r.Period == PeriodWeekly {
+		return fmt.Sprintf("MEXC weekly report %s – %s", r.From.Format(time.DateOnly), r.LastDay())
+	}
+	return fmt.Sprintf("MEXC daily report %s", r.LastDay())
+}
+
+// LastDay возвращает последний день периода (YYYY-MM-DD)
+func (r Report) LastDay() string {
+	return r.To.AddDate(0, 0, -1).Format(time.DateOnly)
+}
+
+// FileName возвращает имя HTML файла отчета
+func (r Report) FileName() string {
+	return fmt.Sprintf("report-%s-%s.html", r.Period, r.LastDay())
+}
+
+// Render рендерит отчет в HTML
+func Render(report Report) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, report); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// ValidateSettings проверяет подписку на отчеты
+func ValidateSettings(settings models.ReportSettings) error {
+	if settings.Period != PeriodDaily && settings.Period != PeriodWeekly {
+		return fmt.Errorf("%w: period must be %q or %q", ErrInvalidSettings, PeriodDaily, PeriodWeekly)
+	}
+
+	if len(settings.Channels) == 0 {
+		return fmt.Errorf("%w: at least one channel is required", ErrInvalidSettings)
+	}
+	for _, channel := range settings.Channels {
+		if channel != ChannelTelegram && channel != ChannelEmail {
+			return fmt.Errorf("%w: unknown channel %q", ErrInvalidSettings, channel)
+		}
+	}
+
+	return nil
+}
+
+// window возвращает границы отчета: полные UTC дни до начала текущего дня
+func window(period string, now time.Time) (time.Time, time.Time) {
+	to := now.UTC().Truncate(24 * time.Hour)
+
+	days := 1
+	if period == PeriodWeekly {
+		days = 7
+	}
+
+	return to.AddDate(0, 0, -days), to
+}
+
+// lastSlot возвращает последнее запланированное время отправки отчета не позже now
+// (ежедневно в hour:00 UTC, еженедельно - по понедельникам)
+func lastSlot(period string, hour int, now time.Time) time.Time {
+	now = now.UTC()
+	slot := now.Truncate(24 * time.Hour).Add(time.Duration(hour) * time.Hour)
+
+	if period == PeriodWeekly {
+		offset := (int(slot.Weekday()) - int(time.Monday) + 7) % 7
+		slot = slot.AddDate(0, 0, -offset)
+		if slot.After(now) {
+			slot = slot.AddDate(0, 0, -7)
+		}
+		return slot
+	}
+
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+
+	return slot
+}
+
+// incidentsBetween оставляет срабатывания алертов в [from, to) (от старых к новым)
+func incidentsBetween(events []models.AlertEvent, from, to time.Time) []models.AlertEvent {
+	incidents := []models.AlertEvent{}
+	for _, event := range events {
+		if !event.FiredAt.Before(from) && event.FiredAt.Before(to) {
+			incidents = append(incidents, event)
+		}
+	}
+	slices.Reverse(incidents)
+
+	return incidents
+}
+
+// latencyBetween оставляет задержки исполнений, отправленных до to
+func latencyBetween(samples []models.LatencySample, to time.Time) analytics.LatencyStages {
+	samples = slices.DeleteFunc(samples, func(sample models.LatencySample) bool {
+		return !sample.DispatchedAt.Before(to)
+	})
+
+	return analytics.BuildLatencyReport(samples).Overall
+}
@@ -0,0 +1,243 @@
+package reports
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"tg_mexc/internal/clock"
+	"tg_mexc/internal/fees"
+	"tg_mexc/internal/mailer"
+	"tg_mexc/internal/models"
+	"tg_mexc/internal/pnl"
+)
+
+const (
+	// checkInterval - период проверки подписок, у которых подошло время отчета
+	checkInterval = 10 * time.Minute
+	// incidentsLimit - сколько последних срабатываний алертов просматривается для отчета
+	incidentsLimit  = 500
+	deliveryTimeout = 30 * time.Second
+)
+
+// Storage - данные для отчетов и подписки пользователей
+type Storage interface {
+	GetAllReportSettings() ([]models.ReportSettings, error)
+	MarkReportSent(userID int, sentAt time.Time) error
+	GetTradeStats(userID int, from, to time.Time) (models.TradeStats, error)
+	GetLatencySamples(userID int, since time.Time) ([]models.LatencySample, error)
+	GetAlertEvents(userID int, limit int) ([]models.AlertEvent, error)
+	GetTelegramChatID(userID int) (int64, error)
+	GetUserContact(userID int) (email string, emailVerified bool, telegramLinked bool, err error)
+}
+
+// DocumentSender отправляет файлы в Telegram
+type DocumentSender interface {
+	SendDocument(chatID int64, fileName string, data []byte, caption string) error
+}
+
+// Service собирает периодические отчеты (сделки, PnL, комиссии, задержки, инциденты)
+// и рассылает их подписанным пользователям
+type Service struct {
+	storage  Storage
+	pnl      *pnl.Service
+	fees     *fees.Service
+	telegram DocumentSender // nil - доставка в Telegram недоступна
+	mailer   mailer.Mailer
+	hour     int // Час отправки (UTC), < 0 отключает рассылку
+	logger   *slog.Logger
+	clock    clock.Clock
+}
+
+// New создает сервис отчетов; отчеты уходят в hour:00 UTC (еженедельные - по понедельникам)
+func New(storage Storage, pnlSvc *pnl.Service, feesSvc *fees.Service, mail mailer.Mailer, hour int, logger *slog.Logger) *Service {
+	return &Service{
+		storage: storage,
+		pnl:     pnlSvc,
+		fees:    feesSvc,
+		mailer:  mail,
+		hour:    hour,
+		logger:  logger,
+		clock:   clock.Real,
+	}
+}
+
+// SetTelegram включает доставку отчетов в Telegram
+func (s *Service) SetTelegram(sender DocumentSender) {
+	s.telegram = sender
+}
+
+// SetClock подменяет источник времени
+func (s *Service) SetClock(clk clock.Clock) {
+	s.clock = clk
+}
+
+// Run рассылает отчеты по расписанию до отмены ctx
+func (s *Service) Run(ctx context.Context) {
+	if s.hour < 0 || s.hour > 23 {
+		s.logger.Info("Scheduled reports disabled")
+		return
+	}
+
+	for {
+		s.SendDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(checkInterval):
+		}
+	}
+}
+
+// SendDue отправляет отчеты подписчикам, для которых наступило время очередного отчета
+func (s *Service) SendDue(ctx context.Context) {
+	all, err := s.storage.GetAllReportSettings()
+	if err != nil {
+		s.logger.Error("Failed to get report settings", slog.Any("error", err))
+		return
+	}
+
+	now := s.clock.Now()
+	for _, settings := range all {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if !settings.LastSentAt.Before(lastSlot(settings.Period, s.hour, now)) {
+			continue
+		}
+
+		report, err := s.Build(settings.UserID, settings.Period)
+		if err != nil {
+			s.logger.Error("Failed to build report", slog.Int("user_id", settings.UserID), slog.Any("error", err))
+			continue
+		}
+
+		// Отметка ставится и при ошибке доставки, чтобы не повторять рассылку каждые checkInterval
+		s.Deliver(ctx, settings, report)
+		if err := s.storage.MarkReportSent(settings.UserID, now); err != nil {
+			s.logger.Error("Failed to mark report sent", slog.Int("user_id", settings.UserID), slog.Any("error", err))
+		}
+	}
+}
+
+// Build собирает отчет пользователя за последний полный день (daily) или 7 дней (weekly)
+func (s *Service) Build(userID int, period string) (Report, error) {
+	now := s.clock.Now().UTC()
+	from, to := window(period, now)
+	fromDay, toDay := from.Format(time.DateOnly), to.AddDate(0, 0, -1).Format(time.DateOnly)
+
+	report := Report{
+		Period:      period,
+		From:        from,
+		To:          to,
+		GeneratedAt: now,
+	}
+
+	var err error
+	if report.Trades, err = s.storage.GetTradeStats(userID, from, to); err != nil {
+		return Report{}, fmt.Errorf("failed to get trade stats: %w", err)
+	}
+
+	if report.PnL, err = s.pnl.SummaryBetween(userID, fromDay, toDay); err != nil {
+		return Report{}, err
+	}
+
+	if report.Fees, err = s.fees.SummaryBetween(userID, fromDay, toDay); err != nil {
+		return Report{}, err
+	}
+
+	samples, err := s.storage.GetLatencySamples(userID, from)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to get latency samples: %w", err)
+	}
+	report.Latency = latencyBetween(samples, to)
+
+	events, err := s.storage.GetAlertEvents(userID, incidentsLimit)
+	if err != nil {
+		return Report{}, fmt.Errorf("failed to get alert events: %w", err)
+	}
+	report.Incidents = incidentsBetween(events, from, to)
+
+	return report, nil
+}
+
+// Deliver отправляет отчет по каналам подписки (ошибки каналов логируются)
+func (s *Service) Deliver(ctx context.Context, settings models.ReportSettings, report Report) {
+	html, err := Render(report)
+	if err != nil {
+		s.logger.Error("Failed to render report", slog.Int("user_id", settings.UserID), slog.Any("error", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+
+	for _, channel := range settings.Channels {
+		var err error
+		switch channel {
+		case ChannelTelegram:
+			err = s.sendTelegram(settings.UserID, report, html)
+		case ChannelEmail:
+			err = s.sendEmail(ctx, settings.UserID, report, html)
+		}
+
+		// Telegram не доставил отчет - запасной канал email, если он не выбран в подписке
+		if err != nil && channel == ChannelTelegram && !slices.Contains(settings.Channels, ChannelEmail) {
+			s.logger.Warn("Failed to deliver report to Telegram, falling back to email",
+				slog.Int("user_id", settings.UserID),
+				slog.Any("error", err))
+
+			channel = ChannelEmail
+			err = s.sendEmail(ctx, settings.UserID, report, html)
+		}
+
+		if err != nil {
+			s.logger.Warn("Failed to deliver report",
+				slog.Int("user_id", settings.UserID),
+				slog.String("channel", channel),
+				slog.Any("error", err))
+			continue
+		}
+
+		s.logger.Info("📊 Report sent",
+			slog.Int("user_id", settings.UserID),
+			slog.String("period", report.Period),
+			slog.String("channel", channel))
+	}
+}
+
+func (s *Service) sendTelegram(userID int, report Report, html []byte) error {
+	if s.telegram == nil {
+		return fmt.Errorf("telegram is not configured")
+	}
+
+	chatID, err := s.storage.GetTelegramChatID(userID)
+	if err != nil {
+		return err
+	}
+	if chatID == 0 {
+		return fmt.Errorf("telegram is not linked")
+	}
+
+	caption := fmt.Sprintf("📊 %s\nTrades: %d (failed executions: %d)\nNet PnL: %.2f USDT, fees: %.2f USDT\nIncidents: %d",
+		report.Title(), report.Trades.Trades, report.Trades.Failed, report.PnL.NetPnL, report.Fees.TotalFees,
+		len(report.Incidents))
+
+	return s.telegram.SendDocument(chatID, report.FileName(), html, caption)
+}
+
+func (s *Service) sendEmail(ctx context.Context, userID int, report Report, html []byte) error {
+	email, verified, _, err := s.storage.GetUserContact(userID)
+	if err != nil {
+		return err
+	}
+	if email == "" || !verified {
+		return fmt.Errorf("no verified email")
+	}
+
+	return s.mailer.SendHTML(ctx, email, report.Title(), string(html))
+}
@@ -0,0 +1,179 @@
+package sessioncheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"tg_mexc/internal/clock"
+	"tg_mexc/internal/mexc"
+	"tg_mexc/internal/models"
+)
+
+// checkTimeout - таймаут проверки одного аккаунта
+const checkTimeout = 15 * time.Second
+
+// Storage - аккаунты пользователей и отметка истекшей сессии
+type Storage interface {
+	GetUserIDsWithAccounts() ([]int, error)
+	GetAccounts(userID int) ([]models.Account, error)
+	UpdateSessionInvalid(userID int, accountID int, invalid bool) error
+	UpdateAccountSession(userID int, accountID int, token string, cookies map[string]string) error
+}
+
+// Notifier уведомляет пользователя об истекшей авторизации аккаунта (mailer.Alerter)
+type Notifier interface {
+	AccountAuthExpired(ctx context.Context, userID int, accountName string)
+}
+
+// Service периодически продлевает и проверяет uc_token всех аккаунтов: обновленный сайтом токен
+// сохраняется, истекшие сессии отмечаются в хранилище, пользователь получает уведомление
+// до того, как copy trading начнет молча падать
+type Service struct {
+	storage  Storage
+	notifier Notifier
+	interval time.Duration
+	logger   *slog.Logger
+	clock    clock.Clock
+}
+
+// New создает сервис проверки сессий. interval - период проверки (0 отключает фоновую задачу)
+func New(storage Storage, notifier Notifier, interval time.Duration, logger *slog.Logger) *Service {
+	return &Service{
+		storage:  storage,
+		notifier: notifier,
+		interval: interval,
+		logger:   logger,
+		clock:    clock.Real,
+	}
+}
+
+// SetClock подменяет источник времени
+func (s *Service) SetClock(clk clock.Clock) {
+	s.clock = clk
+}
+
+// Run запускает проверку сессий каждые interval до отмены ctx
+func (s *Service) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		s.logger.Info("Session checks disabled")
+		return
+	}
+
+	for {
+		s.CheckAll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(s.interval):
+		}
+	}
+}
+
+// CheckAll проверяет сессии аккаунтов всех пользователей
+func (s *Service) CheckAll(ctx context.Context) {
+	userIDs, err := s.storage.GetUserIDsWithAccounts()
+	if err != nil {
+		s.logger.Error("Failed to list users for session check", slog.Any("error", err))
+		return
+	}
+
+	for _, userID := range userIDs {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err := s.checkUser(ctx, userID); err != nil {
+			s.logger.Warn("Session check finished with errors",
+				slog.Int("user_id", userID),
+				slog.Any("error", err))
+		}
+	}
+}
+
+func (s *Service) checkUser(ctx context.Context, userID int) error {
+	accounts, err := s.storage.GetAccounts(userID)
+	if err != nil {
+		return fmt.Errorf("failed to get accounts: %w", err)
+	}
+
+	var errs []error
+	for _, acc := range accounts {
+		if ctx.Err() != nil {
+			break
+		}
+
+		expired, err := s.check(ctx, userID, &acc)
+		if err != nil {
+			// Сетевая ошибка или сбой биржи - состояние сессии неизвестно, отметку не меняем
+			errs = append(errs, fmt.Errorf("%s: %w", acc.Name, err))
+			continue
+		}
+
+		if expired == acc.SessionInvalid {
+			continue
+		}
+
+		if err := s.storage.UpdateSessionInvalid(userID, acc.ID, expired); err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to update session status: %w", acc.Name, err))
+			continue
+		}
+
+		if !expired {
+			s.logger.Info("✅ Account session is valid again",
+				slog.Int("user_id", userID),
+				slog.String("account", acc.Name))
+			continue
+		}
+
+		s.logger.Warn("Account session expired",
+			slog.Int("user_id", userID),
+			slog.String("account", acc.Name))
+		s.notifier.AccountAuthExpired(ctx, userID, acc.Name)
+	}
+
+	return errors.Join(errs...)
+}
+
+// check продлевает сессию аккаунта и проверяет uc_token; ошибка - проверить не удалось.
+// Обновленный токен сохраняется сразу (отметка истекшей сессии при этом снимается)
+func (s *Service) check(ctx context.Context, userID int, acc *models.Account) (bool, error) {
+	client, err := mexc.NewClient(*acc, s.logger)
+	if err != nil {
+		return false, err
+	}
+	client.SetClock(s.clock)
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	refreshed, changed, err := client.RefreshSession(ctx)
+	switch {
+	case errors.Is(err, mexc.ErrSessionExpired):
+		return true, nil
+	case err != nil:
+		// Продление необязательно: проверяем текущий токен
+		s.logger.Debug("Failed to refresh account session",
+			slog.String("account", acc.Name),
+			slog.Any("error", err))
+	case changed:
+		if err := s.storage.UpdateAccountSession(userID, acc.ID, refreshed.Token, refreshed.Cookies); err != nil {
+			return false, fmt.Errorf("failed to save refreshed token: %w", err)
+		}
+		acc.SessionInvalid = false
+
+		s.logger.Info("✅ Account session token refreshed",
+			slog.Int("user_id", userID),
+			slog.String("account", acc.Name))
+	}
+
+	err = client.ValidateSession(ctx)
+	if errors.Is(err, mexc.ErrSessionExpired) {
+		return true, nil
+	}
+
+	return false, err
+}
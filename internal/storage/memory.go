@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"sync"
+	"time"
+
+	"tg_mexc/internal/models"
+)
+
+// MemoryStorage - хранилище Engine в памяти (replay/симуляция без записи в базу)
+type MemoryStorage struct {
+	mu         sync.Mutex
+	accounts   []models.Account
+	trades     []models.Trade
+	logs       []models.ActivityLog
+	stopOrders map[string]string
+}
+
+// NewMemory создает хранилище в памяти с заданными аккаунтами пользователя
+func NewMemory(accounts []models.Account) *MemoryStorage {
+	return &MemoryStorage{
+		accounts:   slices.Clone(accounts),
+		stopOrders: make(map[string]string),
+	}
+}
+
+// GetMasterAccount возвращает master аккаунт
+func (s *MemoryStorage) GetMasterAccount(_ int) (models.Account, error) {
+	for _, acc := range s.accounts {
+		if acc.IsMaster {
+			return acc, nil
+		}
+	}
+	return models.Account{}, sql.ErrNoRows
+}
+
+// GetSlaveAccounts возвращает slave аккаунты
+func (s *MemoryStorage) GetSlaveAccounts(_ int, includeDisabled bool) ([]models.Account, error) {
+	var accounts []models.Account
+	for _, acc := range s.accounts {
+		if acc.IsMaster || (acc.Disabled && !includeDisabled) {
+			continue
+		}
+		accounts = append(accounts, acc)
+	}
+	return accounts, nil
+}
+
+// CreateTrade сохраняет сделку и возвращает ее ID
+func (s *MemoryStorage) CreateTrade(_ context.Context, trade models.Trade) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trade.ID = len(s.trades) + 1
+	trade.CreatedAt = time.Now()
+	s.trades = append(s.trades, trade)
+
+	return trade.ID, nil
+}
+
+// AddTradeDetail добавляет детали выполнения сделки на аккаунте
+func (s *MemoryStorage) AddTradeDetail(_ context.Context, detail models.TradeDetail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trade := s.trade(detail.TradeID)
+	if trade == nil {
+		return sql.ErrNoRows
+	}
+
+	detail.ID = len(trade.Details) + 1
+	detail.CreatedAt = time.Now()
+	if i := slices.IndexFunc(s.accounts, func(acc models.Account) bool { return acc.ID == detail.AccountID }); i >= 0 {
+		detail.AccountName = s.accounts[i].Name
+	}
+	trade.Details = append(trade.Details, detail)
+
+	return nil
+}
+
+// UpdateTradeStatus обновляет статус сделки
+func (s *MemoryStorage) UpdateTradeStatus(_ context.Context, tradeID int, status string, errorMsg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	trade := s.trade(tradeID)
+	if trade == nil {
+		return sql.ErrNoRows
+	}
+
+	trade.Status = status
+	trade.Error = errorMsg
+
+	return nil
+}
+
+func (s *MemoryStorage) trade(tradeID int) *models.Trade {
+	if tradeID < 1 || tradeID > len(s.trades) {
+		return nil
+	}
+	return &s.trades[tradeID-1]
+}
+
+// Trades возвращает все сохраненные сделки с деталями в порядке создания
+func (s *MemoryStorage) Trades() []models.Trade {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.trades)
+}
+
+// AddLog добавляет запись в лог активности
+func (s *MemoryStorage) AddLog(_ context.Context, log models.ActivityLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.logs = append(s.logs, log)
+
+	return nil
+}
+
+// GetStopOrderSymbol получает symbol по order_id из кэша
+func (s *MemoryStorage) GetStopOrderSymbol(_ int, orderID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	symbol, ok := s.stopOrders[orderID]
+	if !ok {
+		return "", sql.ErrNoRows
+	}
+	return symbol, nil
+}
+
+// SaveStopOrder сохраняет маппинг order_id -> symbol в кэш
+func (s *MemoryStorage) SaveStopOrder(_ int, orderID string, symbol string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopOrders[orderID] = symbol
+
+	return nil
+}
+
+// SaveStopOrders сохраняет несколько маппингов order_id -> symbol
+func (s *MemoryStorage) SaveStopOrders(_ int, orders map[string]string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for orderID, symbol := range orders {
+		s.stopOrders[orderID] = symbol
+	}
+
+	return nil
+}
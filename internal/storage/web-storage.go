@@ -9,9 +9,9 @@ import (
 	"strings"
 	"time"
 
-	models2 "tg_mexc/internal/models"
+	"tg_mexc/internal/models"
 
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
 )
 
 // WebStorage управляет базой данных веб-приложения
@@ -191,6 +191,332 @@ CREATE INDEX if NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id);
 		)
 	`)
 
+	// Миграция: per-user переопределения feature flags
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS feature_flag_overrides (
+			user_id INTEGER NOT NULL,
+			flag TEXT NOT NULL,
+			enabled INTEGER NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (user_id, flag),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+
+	// Миграция: начало сессии у refresh token (для абсолютного лимита сессии)
+	_, _ = s.db.Exec(`ALTER TABLE refresh_tokens ADD COLUMN session_started_at DATETIME`)
+
+	// Миграция: флаг администратора
+	_, _ = s.db.Exec(`ALTER TABLE users ADD COLUMN is_admin INTEGER NOT NULL DEFAULT 0`)
+
+	// Миграция: попытки входа и временные блокировки (защита от перебора паролей)
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS login_attempts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT NOT NULL,
+			ip TEXT NOT NULL,
+			success INTEGER NOT NULL,
+			created_at DATETIME NOT NULL
+		)
+	`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_login_attempts_username ON login_attempts(username, created_at)`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(ip, created_at)`)
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS login_lockouts (
+			scope TEXT NOT NULL,
+			key TEXT NOT NULL,
+			locked_until DATETIME NOT NULL,
+			PRIMARY KEY (scope, key)
+		)
+	`)
+
+	// Миграция: email пользователя и одноразовые токены (подтверждение email, сброс пароля)
+	_, _ = s.db.Exec(`ALTER TABLE users ADD COLUMN email TEXT`)
+	_, _ = s.db.Exec(`ALTER TABLE users ADD COLUMN email_verified INTEGER NOT NULL DEFAULT 0`)
+	_, _ = s.db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(email)`)
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS email_tokens (
+			token_hash TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL,
+			purpose TEXT NOT NULL,
+			email TEXT NOT NULL,
+			expires_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+
+	// Миграция: внешние identity (OIDC) привязанные к локальным пользователям
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS user_identities (
+			issuer TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			user_id INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (issuer, subject),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+
+	// Миграция: PnL - сырые события (дедупликация) и дневные агрегаты по аккаунту/символу
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS pnl_entries (
+			account_id INTEGER NOT NULL,
+			source TEXT NOT NULL,
+			ref TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			realized REAL NOT NULL DEFAULT 0,
+			fee REAL NOT NULL DEFAULT 0,
+			volume REAL NOT NULL DEFAULT 0,
+			occurred_at DATETIME NOT NULL,
+			PRIMARY KEY (account_id, source, ref),
+			FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+		)
+	`)
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS pnl_records (
+			user_id INTEGER NOT NULL,
+			account_id INTEGER NOT NULL,
+			symbol TEXT NOT NULL,
+			day TEXT NOT NULL,
+			realized_pnl REAL NOT NULL DEFAULT 0,
+			fees REAL NOT NULL DEFAULT 0,
+			volume REAL NOT NULL DEFAULT 0,
+			trades INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (account_id, symbol, day),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+		)
+	`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_pnl_records_user_day ON pnl_records(user_id, day)`)
+
+	// Миграция: периодические снимки баланса для кривой equity
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS balance_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			account_id INTEGER NOT NULL,
+			equity REAL NOT NULL,
+			available REAL NOT NULL,
+			taken_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+		)
+	`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_balance_snapshots_account ON balance_snapshots(account_id, taken_at)`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_balance_snapshots_user ON balance_snapshots(user_id, taken_at)`)
+
+	// Миграция: тайминги выполнения для latency аналитики
+	_, _ = s.db.Exec(`ALTER TABLE trade_details ADD COLUMN master_event_at DATETIME`)
+	_, _ = s.db.Exec(`ALTER TABLE trade_details ADD COLUMN dispatched_at DATETIME`)
+	_, _ = s.db.Exec(`ALTER TABLE trade_details ADD COLUMN acked_at DATETIME`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_trade_details_dispatched ON trade_details(dispatched_at)`)
+
+	// Миграция: цены исполнения мастера и slave для slippage аналитики
+	_, _ = s.db.Exec(`ALTER TABLE trades ADD COLUMN master_price REAL`)
+	_, _ = s.db.Exec(`ALTER TABLE trade_details ADD COLUMN fill_price REAL`)
+
+	// Миграция: уплаченные комиссии (дедупликация fill'ов/ордеров и дневные суммы по аккаунту)
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS fee_entries (
+			account_id INTEGER NOT NULL,
+			source TEXT NOT NULL,
+			ref TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			taker_fee REAL NOT NULL DEFAULT 0,
+			maker_fee REAL NOT NULL DEFAULT 0,
+			occurred_at DATETIME NOT NULL,
+			PRIMARY KEY (account_id, source, ref),
+			FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+		)
+	`)
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS fee_records (
+			user_id INTEGER NOT NULL,
+			account_id INTEGER NOT NULL,
+			day TEXT NOT NULL,
+			taker_fees REAL NOT NULL DEFAULT 0,
+			maker_fees REAL NOT NULL DEFAULT 0,
+			fills INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (account_id, day),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+		)
+	`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_fee_records_user_day ON fee_records(user_id, day)`)
+
+	// Миграция: отметка истекшей сессии (uc_token) аккаунта
+	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN session_invalid INTEGER NOT NULL DEFAULT 0`)
+
+	// Миграция: funding платежи в PnL
+	_, _ = s.db.Exec(`ALTER TABLE pnl_entries ADD COLUMN funding REAL NOT NULL DEFAULT 0`)
+	_, _ = s.db.Exec(`ALTER TABLE pnl_records ADD COLUMN funding REAL NOT NULL DEFAULT 0`)
+
+	// Миграция: журнал WebSocket событий master аккаунта для offline replay
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS master_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			account_id INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			received_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_master_events_user ON master_events(user_id, received_at)`)
+
+	// Миграция: пользовательские правила алертов и история срабатываний
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS alert_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			type TEXT NOT NULL,
+			account_id INTEGER,
+			threshold REAL NOT NULL DEFAULT 0,
+			window_minutes INTEGER NOT NULL DEFAULT 0,
+			cooldown_minutes INTEGER NOT NULL DEFAULT 60,
+			channels TEXT NOT NULL,
+			webhook_url TEXT NOT NULL DEFAULT '',
+			enabled INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+		)
+	`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_alert_rules_user ON alert_rules(user_id)`)
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS alert_events (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			rule_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			account_id INTEGER NOT NULL DEFAULT 0,
+			message TEXT NOT NULL,
+			fired_at DATETIME NOT NULL,
+			FOREIGN KEY (rule_id) REFERENCES alert_rules(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_alert_events_rule ON alert_events(rule_id, account_id, fired_at)`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_alert_events_user ON alert_events(user_id, fired_at)`)
+
+	// Подписки на периодические отчеты (period: daily / weekly)
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS report_settings (
+			user_id INTEGER PRIMARY KEY,
+			period TEXT NOT NULL,
+			channels TEXT NOT NULL DEFAULT '',
+			last_sent_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+
+	// Каналы уведомлений о сделках (Discord/Slack webhooks используются и алертами)
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS notification_settings (
+			user_id INTEGER PRIMARY KEY,
+			channels TEXT NOT NULL DEFAULT '',
+			discord_webhook_url TEXT NOT NULL DEFAULT '',
+			slack_webhook_url TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+
+	// Состояние приложений между перезапусками (например, последний режим DRY_RUN)
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS runtime_state (
+			key TEXT PRIMARY KEY,
+			value TEXT NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+
+	// Миграция: исполнение ордеров slave по fill'ам WebSocket (COPY_SLAVE_FILL_WS)
+	_, _ = s.db.Exec(`ALTER TABLE trade_details ADD COLUMN filled_vol REAL`)
+	_, _ = s.db.Exec(`ALTER TABLE trade_details ADD COLUMN fee REAL`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_trade_details_order ON trade_details(account_id, order_id)`)
+
+	// Миграция: история закрытых позиций аккаунтов (win rate и результат по позициям)
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS position_history (
+			account_id INTEGER NOT NULL,
+			position_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			symbol TEXT NOT NULL,
+			position_type INTEGER NOT NULL,
+			leverage INTEGER NOT NULL DEFAULT 0,
+			open_avg_price REAL NOT NULL DEFAULT 0,
+			close_avg_price REAL NOT NULL DEFAULT 0,
+			close_vol REAL NOT NULL DEFAULT 0,
+			realised REAL NOT NULL DEFAULT 0,
+			close_profit_loss REAL NOT NULL DEFAULT 0,
+			total_fee REAL NOT NULL DEFAULT 0,
+			hold_fee REAL NOT NULL DEFAULT 0,
+			opened_at DATETIME NOT NULL,
+			closed_at DATETIME NOT NULL,
+			PRIMARY KEY (account_id, position_id),
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+		)
+	`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_position_history_user_closed ON position_history(user_id, closed_at)`)
+
+	// Миграция: сырые push.personal.* сообщения WebSocket (WS_RECORD_DB) для отладки и backtesting
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS ws_frames (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			account_id INTEGER NOT NULL,
+			channel TEXT NOT NULL,
+			ts INTEGER NOT NULL DEFAULT 0,
+			payload TEXT NOT NULL,
+			received_at DATETIME NOT NULL,
+			FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+		)
+	`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_ws_frames_account ON ws_frames(account_id, received_at)`)
+
+	// Миграция: множитель объёма входов мастера на slave аккаунте
+	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN copy_multiplier REAL NOT NULL DEFAULT 1`)
+
+	// Миграция: ограничения объёма входов на slave аккаунте и отметка уменьшенного входа
+	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN cap_order_vol REAL NOT NULL DEFAULT 0`)
+	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN cap_symbol_notional REAL NOT NULL DEFAULT 0`)
+	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN cap_exposure REAL NOT NULL DEFAULT 0`)
+	_, _ = s.db.Exec(`ALTER TABLE trade_details ADD COLUMN capped INTEGER NOT NULL DEFAULT 0`)
+
+	// Миграция: предел символов с открытой позицией на slave аккаунте
+	_, _ = s.db.Exec(`ALTER TABLE accounts ADD COLUMN cap_positions INTEGER NOT NULL DEFAULT 0`)
+
+	// Фильтр символов копирования (whitelist / blacklist входов мастера)
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS symbol_filters (
+			user_id INTEGER PRIMARY KEY,
+			mode TEXT NOT NULL,
+			symbols TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+
+	// Миграция: очередь повторов копий slave, не выполненных из-за временной ошибки, и номер попытки
+	_, _ = s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS copy_retries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			account_id INTEGER NOT NULL,
+			trade_id INTEGER NOT NULL,
+			op TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			request TEXT NOT NULL,
+			ratio REAL NOT NULL DEFAULT 0,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at DATETIME NOT NULL,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		)
+	`)
+	_, _ = s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_copy_retries_next ON copy_retries(next_attempt_at)`)
+	_, _ = s.db.Exec(`ALTER TABLE trade_details ADD COLUMN attempt INTEGER NOT NULL DEFAULT 0`)
+
 	s.logger.Info("✅ Web database initialized")
 
 	return nil
@@ -199,7 +525,7 @@ CREATE INDEX if NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id);
 // === User Management ===
 
 // CreateUser создает нового пользователя
-func (s *WebStorage) CreateUser(username, passwordHash string) (*models2.User, error) {
+func (s *WebStorage) CreateUser(username, passwordHash string) (*models.User, error) {
 	result, err := s.db.Exec(`
 		INSERT INTO users (username, password_hash)
 		VALUES (?, ?)
@@ -210,7 +536,7 @@ func (s *WebStorage) CreateUser(username, passwordHash string) (*models2.User, e
 
 	id, _ := result.LastInsertId()
 
-	return &models2.User{
+	return &models.User{
 		ID:           int(id),
 		Username:     username,
 		PasswordHash: passwordHash,
@@ -219,14 +545,16 @@ func (s *WebStorage) CreateUser(username, passwordHash string) (*models2.User, e
 }
 
 // GetUserByUsername получает пользователя по имени
-func (s *WebStorage) GetUserByUsername(username string) (*models2.User, error) {
-	var user models2.User
+func (s *WebStorage) GetUserByUsername(username string) (*models.User, error) {
+	var user models.User
 
 	err := s.db.QueryRow(`
-		SELECT id, username, password_hash, created_at
+		SELECT id, coalesce(username, ''), coalesce(password_hash, ''), is_admin,
+		       coalesce(email, ''), email_verified, created_at
 		FROM users
 		WHERE username = ?
-	`, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt)
+	`, username).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.IsAdmin,
+		&user.Email, &user.EmailVerified, &user.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -235,14 +563,16 @@ func (s *WebStorage) GetUserByUsername(username string) (*models2.User, error) {
 }
 
 // GetUserByID получает пользователя по ID
-func (s *WebStorage) GetUserByID(id int) (*models2.User, error) {
-	var user models2.User
+func (s *WebStorage) GetUserByID(id int) (*models.User, error) {
+	var user models.User
 
 	err := s.db.QueryRow(`
-		SELECT id, username, password_hash, created_at
+		SELECT id, coalesce(username, ''), coalesce(password_hash, ''), is_admin,
+		       coalesce(email, ''), email_verified, created_at
 		FROM users
 		WHERE id = ?
-	`, id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt)
+	`, id).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.IsAdmin,
+		&user.Email, &user.EmailVerified, &user.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -250,6 +580,139 @@ func (s *WebStorage) GetUserByID(id int) (*models2.User, error) {
 	return &user, nil
 }
 
+// GetUserByVerifiedEmail получает пользователя по подтвержденному email
+func (s *WebStorage) GetUserByVerifiedEmail(email string) (*models.User, error) {
+	var userID int
+	err := s.db.QueryRow(`
+		SELECT id FROM users WHERE email = ? AND email_verified = 1
+	`, email).Scan(&userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetUserByID(userID)
+}
+
+// SetUserEmail задает email пользователя (сбрасывает подтверждение)
+func (s *WebStorage) SetUserEmail(userID int, email string) error {
+	_, err := s.db.Exec(`
+		UPDATE users SET email = ?, email_verified = 0 WHERE id = ?
+	`, email, userID)
+	return err
+}
+
+// MarkEmailVerified подтверждает email, если он не менялся после отправки ссылки
+func (s *WebStorage) MarkEmailVerified(userID int, email string) (bool, error) {
+	result, err := s.db.Exec(`
+		UPDATE users SET email_verified = 1 WHERE id = ? AND email = ?
+	`, userID, email)
+	if err != nil {
+		return false, err
+	}
+
+	rows, _ := result.RowsAffected()
+
+	return rows > 0, nil
+}
+
+// GetUserContact возвращает контакты для уведомлений
+func (s *WebStorage) GetUserContact(userID int) (email string, emailVerified bool, telegramLinked bool, err error) {
+	err = s.db.QueryRow(`
+		SELECT coalesce(email, ''), email_verified, telegram_chat_id IS NOT NULL
+		FROM users WHERE id = ?
+	`, userID).Scan(&email, &emailVerified, &telegramLinked)
+	return email, emailVerified, telegramLinked, err
+}
+
+// SaveEmailToken сохраняет одноразовый токен (хранится только хеш)
+func (s *WebStorage) SaveEmailToken(tokenHash string, userID int, purpose, email string, expiresAt time.Time) error {
+	_, err := s.db.Exec(`
+		INSERT INTO email_tokens (token_hash, user_id, purpose, email, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, tokenHash, userID, purpose, email, expiresAt)
+	return err
+}
+
+// ConsumeEmailToken удаляет токен и возвращает его владельца (sql.ErrNoRows если токен не найден или истек)
+func (s *WebStorage) ConsumeEmailToken(tokenHash, purpose string) (userID int, email string, err error) {
+	var expiresAt time.Time
+	err = s.db.QueryRow(`
+		DELETE FROM email_tokens WHERE token_hash = ? AND purpose = ?
+		RETURNING user_id, email, expires_at
+	`, tokenHash, purpose).Scan(&userID, &email, &expiresAt)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if time.Now().After(expiresAt) {
+		return 0, "", sql.ErrNoRows
+	}
+
+	return userID, email, nil
+}
+
+// DeleteEmailTokens удаляет все токены пользователя с указанным назначением
+func (s *WebStorage) DeleteEmailTokens(userID int, purpose string) error {
+	_, err := s.db.Exec("DELETE FROM email_tokens WHERE user_id = ? AND purpose = ?", userID, purpose)
+	return err
+}
+
+// GetUserIDByIdentity возвращает ID пользователя, привязанного к внешней identity
+func (s *WebStorage) GetUserIDByIdentity(issuer, subject string) (int, error) {
+	var userID int
+	err := s.db.QueryRow(`
+		SELECT user_id FROM user_identities WHERE issuer = ? AND subject = ?
+	`, issuer, subject).Scan(&userID)
+	return userID, err
+}
+
+// LinkIdentity привязывает внешнюю identity к пользователю
+func (s *WebStorage) LinkIdentity(userID int, issuer, subject string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO user_identities (issuer, subject, user_id)
+		VALUES (?, ?, ?)
+	`, issuer, subject, userID)
+	return err
+}
+
+// UpdatePasswordHash обновляет хеш пароля пользователя
+func (s *WebStorage) UpdatePasswordHash(userID int, passwordHash string) error {
+	_, err := s.db.Exec("UPDATE users SET password_hash = ? WHERE id = ?", passwordHash, userID)
+	return err
+}
+
+// IsUserAdmin проверяет, является ли пользователь администратором
+func (s *WebStorage) IsUserAdmin(userID int) (bool, error) {
+	var isAdmin bool
+	err := s.db.QueryRow("SELECT is_admin FROM users WHERE id = ?", userID).Scan(&isAdmin)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return isAdmin, nil
+}
+
+// CountAdmins возвращает количество администраторов
+func (s *WebStorage) CountAdmins() (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT count(*) FROM users WHERE is_admin = 1").Scan(&count)
+	return count, err
+}
+
+// SetUserAdmin выдает или снимает права администратора
+func (s *WebStorage) SetUserAdmin(userID int, isAdmin bool) error {
+	isAdminInt := 0
+	if isAdmin {
+		isAdminInt = 1
+	}
+
+	_, err := s.db.Exec("UPDATE users SET is_admin = ? WHERE id = ?", isAdminInt, userID)
+	return err
+}
+
 // === Account Management ===
 
 // AccountExistsByMexcUID проверяет, существует ли аккаунт с таким MEXC UID
@@ -266,7 +729,7 @@ func (s *WebStorage) AccountExistsByMexcUID(userID int, mexcUID string) (bool, e
 }
 
 // AddAccount добавляет аккаунт для пользователя
-func (s *WebStorage) AddAccount(userID int, name string, data models2.BrowserData, proxy string) error {
+func (s *WebStorage) AddAccount(userID int, name string, data models.BrowserData, proxy string) error {
 	cookiesJSON, _ := json.Marshal(data.AllCookies)
 
 	_, err := s.db.Exec(`
@@ -285,11 +748,13 @@ func (s *WebStorage) AddAccount(userID int, name string, data models2.BrowserDat
 }
 
 // GetAccounts возвращает все аккаунты пользователя
-func (s *WebStorage) GetAccounts(userID int) ([]models2.Account, error) {
+func (s *WebStorage) GetAccounts(userID int) ([]models.Account, error) {
 	rows, err := s.db.Query(`
 		SELECT id, name, token, user_id_mexc, device_id,
 		       coalesce(cookies, '{}'), coalesce(user_agent, ''), coalesce(proxy, ''),
-		       coalesce(is_master, 0), coalesce(disabled, 0)
+		       coalesce(is_master, 0), coalesce(disabled, 0), coalesce(session_invalid, 0),
+		       coalesce(copy_multiplier, 1), coalesce(cap_order_vol, 0), coalesce(cap_symbol_notional, 0),
+		       coalesce(cap_exposure, 0), coalesce(cap_positions, 0)
 		FROM accounts
 		WHERE user_id = ?
 		ORDER BY id
@@ -299,14 +764,15 @@ func (s *WebStorage) GetAccounts(userID int) ([]models2.Account, error) {
 	}
 	defer rows.Close()
 
-	var accounts []models2.Account
+	var accounts []models.Account
 	for rows.Next() {
-		var acc models2.Account
+		var acc models.Account
 		var cookiesJSON string
-		var isMasterInt, disabledInt int
+		var isMasterInt, disabledInt, sessionInvalidInt int
 
 		err := rows.Scan(&acc.ID, &acc.Name, &acc.Token, &acc.UserID,
-			&acc.DeviceID, &cookiesJSON, &acc.UserAgent, &acc.Proxy, &isMasterInt, &disabledInt)
+			&acc.DeviceID, &cookiesJSON, &acc.UserAgent, &acc.Proxy, &isMasterInt, &disabledInt, &sessionInvalidInt,
+			&acc.CopyMultiplier, &acc.Caps.MaxOrderVol, &acc.Caps.MaxSymbolNotional, &acc.Caps.MaxExposure, &acc.Caps.MaxPositions)
 		if err != nil {
 			continue
 		}
@@ -314,6 +780,7 @@ func (s *WebStorage) GetAccounts(userID int) ([]models2.Account, error) {
 		json.Unmarshal([]byte(cookiesJSON), &acc.Cookies)
 		acc.IsMaster = isMasterInt == 1
 		acc.Disabled = disabledInt == 1
+		acc.SessionInvalid = sessionInvalidInt == 1
 		accounts = append(accounts, acc)
 	}
 
@@ -385,6 +852,75 @@ func (s *WebStorage) UpdateDisabledStatus(userID int, accountID int, disabled bo
 	return nil
 }
 
+// UpdateSessionInvalid отмечает, что сессия (uc_token) аккаунта истекла или снова действительна
+func (s *WebStorage) UpdateSessionInvalid(userID int, accountID int, invalid bool) error {
+	invalidInt := 0
+	if invalid {
+		invalidInt = 1
+	}
+
+	result, err := s.db.Exec("UPDATE accounts SET session_invalid = ? WHERE user_id = ? AND id = ?", invalidInt, userID, accountID)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("account not found")
+	}
+
+	return nil
+}
+
+// UpdateCopyMultiplier задает множитель объёма входов мастера на slave аккаунте
+func (s *WebStorage) UpdateCopyMultiplier(userID int, accountID int, multiplier float64) error {
+	result, err := s.db.Exec("UPDATE accounts SET copy_multiplier = ? WHERE user_id = ? AND id = ?", multiplier, userID, accountID)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("account not found")
+	}
+
+	return nil
+}
+
+// UpdateCopyCaps задает ограничения объёма входов мастера на slave аккаунте
+func (s *WebStorage) UpdateCopyCaps(userID int, accountID int, caps models.CopyCaps) error {
+	result, err := s.db.Exec("UPDATE accounts SET cap_order_vol = ?, cap_symbol_notional = ?, cap_exposure = ?, cap_positions = ? WHERE user_id = ? AND id = ?",
+		caps.MaxOrderVol, caps.MaxSymbolNotional, caps.MaxExposure, caps.MaxPositions, userID, accountID)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("account not found")
+	}
+
+	return nil
+}
+
+// UpdateAccountSession сохраняет обновленный uc_token и cookies аккаунта и снимает отметку истекшей сессии
+func (s *WebStorage) UpdateAccountSession(userID int, accountID int, token string, cookies map[string]string) error {
+	cookiesJSON, _ := json.Marshal(cookies)
+
+	result, err := s.db.Exec("UPDATE accounts SET token = ?, cookies = ?, session_invalid = 0 WHERE user_id = ? AND id = ?",
+		token, string(cookiesJSON), userID, accountID)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("account not found")
+	}
+
+	return nil
+}
+
 // UpdateAutoDisableOnFee обновляет auto_disable_on_fee статус аккаунта
 func (s *WebStorage) UpdateAutoDisableOnFee(userID int, accountID int, autoDisable bool) error {
 	autoDisableInt := 0
@@ -406,37 +942,43 @@ func (s *WebStorage) UpdateAutoDisableOnFee(userID int, accountID int, autoDisab
 }
 
 // GetMasterAccount возвращает главный аккаунт
-func (s *WebStorage) GetMasterAccount(userID int) (models2.Account, error) {
-	var acc models2.Account
+func (s *WebStorage) GetMasterAccount(userID int) (models.Account, error) {
+	var acc models.Account
 	var cookiesJSON string
-	var isMasterInt, disabledInt int
+	var isMasterInt, disabledInt, sessionInvalidInt int
 
 	err := s.db.QueryRow(`
 		SELECT id, name, token, user_id_mexc, device_id,
 		       coalesce(cookies, '{}'), coalesce(user_agent, ''), coalesce(proxy, ''),
-		       coalesce(is_master, 0), coalesce(disabled, 0)
+		       coalesce(is_master, 0), coalesce(disabled, 0), coalesce(session_invalid, 0),
+		       coalesce(copy_multiplier, 1), coalesce(cap_order_vol, 0), coalesce(cap_symbol_notional, 0),
+		       coalesce(cap_exposure, 0), coalesce(cap_positions, 0)
 		FROM accounts
 		WHERE user_id = ? AND is_master = 1
 		LIMIT 1
 	`, userID).Scan(&acc.ID, &acc.Name, &acc.Token, &acc.UserID,
-		&acc.DeviceID, &cookiesJSON, &acc.UserAgent, &acc.Proxy, &isMasterInt, &disabledInt)
+		&acc.DeviceID, &cookiesJSON, &acc.UserAgent, &acc.Proxy, &isMasterInt, &disabledInt, &sessionInvalidInt,
+		&acc.CopyMultiplier, &acc.Caps.MaxOrderVol, &acc.Caps.MaxSymbolNotional, &acc.Caps.MaxExposure, &acc.Caps.MaxPositions)
 	if err != nil {
-		return models2.Account{}, err
+		return models.Account{}, err
 	}
 
 	json.Unmarshal([]byte(cookiesJSON), &acc.Cookies)
 	acc.IsMaster = isMasterInt == 1
 	acc.Disabled = disabledInt == 1
+	acc.SessionInvalid = sessionInvalidInt == 1
 
 	return acc, nil
 }
 
 // GetSlaveAccounts возвращает все slave аккаунты
-func (s *WebStorage) GetSlaveAccounts(userID int, includeDisabled bool) ([]models2.Account, error) {
+func (s *WebStorage) GetSlaveAccounts(userID int, includeDisabled bool) ([]models.Account, error) {
 	query := `
 		SELECT id, name, token, user_id_mexc, device_id,
 		       coalesce(cookies, '{}'), coalesce(user_agent, ''), coalesce(proxy, ''),
-		       coalesce(is_master, 0), coalesce(disabled, 0)
+		       coalesce(is_master, 0), coalesce(disabled, 0), coalesce(session_invalid, 0),
+		       coalesce(copy_multiplier, 1), coalesce(cap_order_vol, 0), coalesce(cap_symbol_notional, 0),
+		       coalesce(cap_exposure, 0), coalesce(cap_positions, 0)
 		FROM accounts
 		WHERE user_id = ? AND is_master = 0`
 
@@ -452,14 +994,15 @@ func (s *WebStorage) GetSlaveAccounts(userID int, includeDisabled bool) ([]model
 	}
 	defer rows.Close()
 
-	var accounts []models2.Account
+	var accounts []models.Account
 	for rows.Next() {
-		var acc models2.Account
+		var acc models.Account
 		var cookiesJSON string
-		var isMasterInt, disabledInt int
+		var isMasterInt, disabledInt, sessionInvalidInt int
 
 		err := rows.Scan(&acc.ID, &acc.Name, &acc.Token, &acc.UserID,
-			&acc.DeviceID, &cookiesJSON, &acc.UserAgent, &acc.Proxy, &isMasterInt, &disabledInt)
+			&acc.DeviceID, &cookiesJSON, &acc.UserAgent, &acc.Proxy, &isMasterInt, &disabledInt, &sessionInvalidInt,
+			&acc.CopyMultiplier, &acc.Caps.MaxOrderVol, &acc.Caps.MaxSymbolNotional, &acc.Caps.MaxExposure, &acc.Caps.MaxPositions)
 		if err != nil {
 			continue
 		}
@@ -467,6 +1010,7 @@ func (s *WebStorage) GetSlaveAccounts(userID int, includeDisabled bool) ([]model
 		json.Unmarshal([]byte(cookiesJSON), &acc.Cookies)
 		acc.IsMaster = isMasterInt == 1
 		acc.Disabled = disabledInt == 1
+		acc.SessionInvalid = sessionInvalidInt == 1
 		accounts = append(accounts, acc)
 	}
 
@@ -476,11 +1020,12 @@ func (s *WebStorage) GetSlaveAccounts(userID int, includeDisabled bool) ([]model
 // === Trades History ===
 
 // CreateTrade создает новую запись сделки
-func (s *WebStorage) CreateTrade(_ context.Context, trade models2.Trade) (int, error) {
+func (s *WebStorage) CreateTrade(_ context.Context, trade models.Trade) (int, error) {
 	result, err := s.db.Exec(`
-		INSERT INTO trades (user_id, master_account_id, symbol, side, volume, leverage, action, sent_at, status)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, trade.UserID, trade.MasterAccountID, trade.Symbol, trade.Side, trade.Volume, trade.Leverage, trade.Action, trade.SentAt, trade.Status)
+		INSERT INTO trades (user_id, master_account_id, symbol, side, volume, leverage, master_price, action, sent_at, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, trade.UserID, trade.MasterAccountID, trade.Symbol, trade.Side, trade.Volume, trade.Leverage, nullFloat(trade.MasterPrice),
+		trade.Action, trade.SentAt, trade.Status)
 	if err != nil {
 		return 0, err
 	}
@@ -503,41 +1048,118 @@ func (s *WebStorage) UpdateTradeStatus(_ context.Context, tradeID int, status st
 }
 
 // AddTradeDetail добавляет детали выполнения сделки на аккаунте
-func (s *WebStorage) AddTradeDetail(_ context.Context, detail models2.TradeDetail) error {
+func (s *WebStorage) AddTradeDetail(_ context.Context, detail models.TradeDetail) error {
 	_, err := s.db.Exec(`
-		INSERT INTO trade_details (trade_id, account_id, status, error, order_id, latency_ms)
-		VALUES (?, ?, ?, ?, ?, ?)
-	`, detail.TradeID, detail.AccountID, detail.Status, detail.Error, detail.OrderID, detail.LatencyMs)
+		INSERT INTO trade_details (trade_id, account_id, status, error, order_id, latency_ms,
+		                           master_event_at, dispatched_at, acked_at, fill_price, filled_vol, fee, capped, attempt)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, detail.TradeID, detail.AccountID, detail.Status, detail.Error, detail.OrderID, detail.LatencyMs,
+		utcPtr(detail.MasterEventAt), utcPtr(detail.DispatchedAt), utcPtr(detail.AckedAt), nullFloat(detail.FillPrice),
+		nullFloat(detail.FilledVol), nullFloat(detail.Fee), detail.Capped, detail.Attempt)
 
 	return err
 }
 
-// GetTrades получает историю сделок с пагинацией
-func (s *WebStorage) GetTrades(userID int, limit, offset int) ([]models2.Trade, error) {
+// UpdateTradeDetailFill записывает фактическое исполнение ордера slave (fill'ы WebSocket)
+// в детали сделки с этим ордером
+func (s *WebStorage) UpdateTradeDetailFill(_ context.Context, accountID int, orderID string, fill models.OrderFill) error {
+	_, err := s.db.Exec(`
+		UPDATE trade_details SET fill_price = ?, filled_vol = ?, fee = ?
+		WHERE account_id = ? AND order_id = ?
+	`, nullFloat(fill.AvgPrice), nullFloat(fill.Vol), nullFloat(fill.Fee), accountID, orderID)
+
+	return err
+}
+
+// GetLatencySamples возвращает тайминги успешных исполнений на slave аккаунтах пользователя начиная с since
+func (s *WebStorage) GetLatencySamples(userID int, since time.Time) ([]models.LatencySample, error) {
 	rows, err := s.db.Query(`
-		SELECT t.id, t.user_id, t.master_account_id, coalesce(a.name, ''), t.symbol, t.side, t.volume, t.leverage,
-		       coalesce(t.action, ''), t.sent_at, t.received_at, t.exchange_accepted_at, t.status, coalesce(t.error, ''), t.created_at
-		FROM trades t
-		LEFT JOIN accounts a ON t.master_account_id = a.id
-		WHERE t.user_id = ?
-		ORDER BY t.sent_at DESC
-		LIMIT ? OFFSET ?
-	`, userID, limit, offset)
+		SELECT td.account_id, coalesce(a.name, ''), coalesce(a.proxy, ''),
+		       td.master_event_at, td.dispatched_at, td.acked_at
+		FROM trade_details td
+		JOIN trades t ON t.id = td.trade_id
+		LEFT JOIN accounts a ON a.id = td.account_id
+		WHERE t.user_id = ? AND td.status = 'success'
+		  AND td.dispatched_at IS NOT NULL AND td.acked_at IS NOT NULL
+		  AND td.dispatched_at >= ?
+		ORDER BY td.dispatched_at
+	`, userID, since.UTC())
 	if err != nil {
 		return nil, err
 	}
-
 	defer rows.Close()
 
-	var trades []models2.Trade
+	var samples []models.LatencySample
 	for rows.Next() {
-		var trade models2.Trade
-		err := rows.Scan(
-			&trade.ID, &trade.UserID, &trade.MasterAccountID, &trade.MasterAccountName,
-			&trade.Symbol, &trade.Side, &trade.Volume, &trade.Leverage,
-			&trade.Action, &trade.SentAt, &trade.ReceivedAt, &trade.ExchangeAcceptedAt,
-			&trade.Status, &trade.Error, &trade.CreatedAt,
-		)
+		var sample models.LatencySample
+		err := rows.Scan(&sample.AccountID, &sample.AccountName, &sample.Proxy,
+			&sample.MasterEventAt, &sample.DispatchedAt, &sample.AckedAt)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+
+	return samples, nil
+}
+
+// GetSlippageSamples возвращает цены исполнения мастера и slave по скопированным входам начиная с since
+func (s *WebStorage) GetSlippageSamples(userID int, since time.Time) ([]models.SlippageSample, error) {
+	rows, err := s.db.Query(`
+		SELECT td.account_id, coalesce(a.name, ''), t.symbol, t.side, t.master_price, td.fill_price
+		FROM trade_details td
+		JOIN trades t ON t.id = td.trade_id
+		LEFT JOIN accounts a ON a.id = td.account_id
+		WHERE t.user_id = ? AND td.status = 'success'
+		  AND t.master_price > 0 AND td.fill_price > 0
+		  AND td.dispatched_at >= ?
+		ORDER BY td.dispatched_at
+	`, userID, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []models.SlippageSample
+	for rows.Next() {
+		var sample models.SlippageSample
+		err := rows.Scan(&sample.AccountID, &sample.AccountName, &sample.Symbol, &sample.Side,
+			&sample.MasterPrice, &sample.FillPrice)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+
+	return samples, nil
+}
+
+// GetTrades получает историю сделок с пагинацией
+func (s *WebStorage) GetTrades(userID int, limit, offset int) ([]models.Trade, error) {
+	rows, err := s.db.Query(`
+		SELECT t.id, t.user_id, t.master_account_id, coalesce(a.name, ''), t.symbol, t.side, t.volume, t.leverage,
+		       coalesce(t.action, ''), t.sent_at, t.received_at, t.exchange_accepted_at, t.status, coalesce(t.error, ''), t.created_at
+		FROM trades t
+		LEFT JOIN accounts a ON t.master_account_id = a.id
+		WHERE t.user_id = ?
+		ORDER BY t.sent_at DESC
+		LIMIT ? OFFSET ?
+	`, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var trades []models.Trade
+	for rows.Next() {
+		var trade models.Trade
+		err := rows.Scan(
+			&trade.ID, &trade.UserID, &trade.MasterAccountID, &trade.MasterAccountName,
+			&trade.Symbol, &trade.Side, &trade.Volume, &trade.Leverage,
+			&trade.Action, &trade.SentAt, &trade.ReceivedAt, &trade.ExchangeAcceptedAt,
+			&trade.Status, &trade.Error, &trade.CreatedAt,
+		)
 		if err != nil {
 			continue
 		}
@@ -551,7 +1173,7 @@ func (s *WebStorage) GetTrades(userID int, limit, offset int) ([]models2.Trade,
 }
 
 // GetTradesFeed получает ленту сделок с фильтрацией по аккаунтам
-func (s *WebStorage) GetTradesFeed(userID int, accountIDs []int, limit int) ([]models2.Trade, error) {
+func (s *WebStorage) GetTradesFeed(userID int, accountIDs []int, limit int) ([]models.Trade, error) {
 	var query string
 	var args []interface{}
 
@@ -601,9 +1223,9 @@ func (s *WebStorage) GetTradesFeed(userID int, accountIDs []int, limit int) ([]m
 	}
 	defer rows.Close()
 
-	var trades []models2.Trade
+	var trades []models.Trade
 	for rows.Next() {
-		var trade models2.Trade
+		var trade models.Trade
 		err := rows.Scan(
 			&trade.ID, &trade.UserID, &trade.MasterAccountID, &trade.MasterAccountName,
 			&trade.Symbol, &trade.Side, &trade.Volume, &trade.Leverage,
@@ -627,7 +1249,7 @@ func (s *WebStorage) GetTradesFeed(userID int, accountIDs []int, limit int) ([]m
 }
 
 // GetTradeDetailsFiltered получает детали сделки с фильтрацией по аккаунтам
-func (s *WebStorage) GetTradeDetailsFiltered(tradeID int, accountIDs []int) ([]models2.TradeDetail, error) {
+func (s *WebStorage) GetTradeDetailsFiltered(tradeID int, accountIDs []int) ([]models.TradeDetail, error) {
 	placeholders := make([]string, len(accountIDs))
 	args := []interface{}{tradeID}
 	for i, id := range accountIDs {
@@ -638,7 +1260,10 @@ func (s *WebStorage) GetTradeDetailsFiltered(tradeID int, accountIDs []int) ([]m
 
 	query := `
 		SELECT td.id, td.trade_id, td.account_id, coalesce(a.name, ''), td.status, coalesce(td.error, ''),
-		       coalesce(td.order_id, ''), coalesce(td.latency_ms, 0), td.created_at
+		       coalesce(td.order_id, ''), coalesce(td.latency_ms, 0), td.created_at,
+		       td.master_event_at, td.dispatched_at, td.acked_at, coalesce(td.fill_price, 0),
+		       coalesce(td.filled_vol, 0), coalesce(td.fee, 0), coalesce(td.capped, 0),
+		       coalesce(td.attempt, 0)
 		FROM trade_details td
 		LEFT JOIN accounts a ON td.account_id = a.id
 		WHERE td.trade_id = ? AND td.account_id IN ` + inClause + `
@@ -651,12 +1276,14 @@ func (s *WebStorage) GetTradeDetailsFiltered(tradeID int, accountIDs []int) ([]m
 	}
 	defer rows.Close()
 
-	var details []models2.TradeDetail
+	var details []models.TradeDetail
 	for rows.Next() {
-		var detail models2.TradeDetail
+		var detail models.TradeDetail
 		err := rows.Scan(
 			&detail.ID, &detail.TradeID, &detail.AccountID, &detail.AccountName,
 			&detail.Status, &detail.Error, &detail.OrderID, &detail.LatencyMs, &detail.CreatedAt,
+			&detail.MasterEventAt, &detail.DispatchedAt, &detail.AckedAt, &detail.FillPrice,
+			&detail.FilledVol, &detail.Fee, &detail.Capped, &detail.Attempt,
 		)
 		if err != nil {
 			continue
@@ -668,8 +1295,8 @@ func (s *WebStorage) GetTradeDetailsFiltered(tradeID int, accountIDs []int) ([]m
 }
 
 // GetAccountTrades получает историю сделок для конкретного аккаунта
-func (s *WebStorage) GetAccountTrades(userID int, accountID int, isMaster bool, limit int) ([]models2.Trade, error) {
-	var trades []models2.Trade
+func (s *WebStorage) GetAccountTrades(userID int, accountID int, isMaster bool, limit int) ([]models.Trade, error) {
+	var trades []models.Trade
 
 	if isMaster {
 		// Для мастера - все Trade где он источник
@@ -688,7 +1315,7 @@ func (s *WebStorage) GetAccountTrades(userID int, accountID int, isMaster bool,
 		defer rows.Close()
 
 		for rows.Next() {
-			var trade models2.Trade
+			var trade models.Trade
 			err := rows.Scan(
 				&trade.ID, &trade.UserID, &trade.MasterAccountID, &trade.MasterAccountName,
 				&trade.Symbol, &trade.Side, &trade.Volume, &trade.Leverage,
@@ -719,7 +1346,7 @@ func (s *WebStorage) GetAccountTrades(userID int, accountID int, isMaster bool,
 		defer rows.Close()
 
 		for rows.Next() {
-			var trade models2.Trade
+			var trade models.Trade
 			err := rows.Scan(
 				&trade.ID, &trade.UserID, &trade.MasterAccountID, &trade.MasterAccountName,
 				&trade.Symbol, &trade.Side, &trade.Volume, &trade.Leverage,
@@ -739,10 +1366,13 @@ func (s *WebStorage) GetAccountTrades(userID int, accountID int, isMaster bool,
 }
 
 // GetTradeDetails получает детали сделки
-func (s *WebStorage) GetTradeDetails(tradeID int) ([]models2.TradeDetail, error) {
+func (s *WebStorage) GetTradeDetails(tradeID int) ([]models.TradeDetail, error) {
 	rows, err := s.db.Query(`
 		SELECT td.id, td.trade_id, td.account_id, a.name, td.status, coalesce(td.error, ''),
-		       coalesce(td.order_id, ''), coalesce(td.latency_ms, 0), td.created_at
+		       coalesce(td.order_id, ''), coalesce(td.latency_ms, 0), td.created_at,
+		       td.master_event_at, td.dispatched_at, td.acked_at, coalesce(td.fill_price, 0),
+		       coalesce(td.filled_vol, 0), coalesce(td.fee, 0), coalesce(td.capped, 0),
+		       coalesce(td.attempt, 0)
 		FROM trade_details td
 		LEFT JOIN accounts a ON td.account_id = a.id
 		WHERE td.trade_id = ?
@@ -754,12 +1384,14 @@ func (s *WebStorage) GetTradeDetails(tradeID int) ([]models2.TradeDetail, error)
 
 	defer rows.Close()
 
-	var details []models2.TradeDetail
+	var details []models.TradeDetail
 	for rows.Next() {
-		var detail models2.TradeDetail
+		var detail models.TradeDetail
 		err := rows.Scan(
 			&detail.ID, &detail.TradeID, &detail.AccountID, &detail.AccountName,
 			&detail.Status, &detail.Error, &detail.OrderID, &detail.LatencyMs, &detail.CreatedAt,
+			&detail.MasterEventAt, &detail.DispatchedAt, &detail.AckedAt, &detail.FillPrice,
+			&detail.FilledVol, &detail.Fee, &detail.Capped, &detail.Attempt,
 		)
 		if err != nil {
 			continue
@@ -771,10 +1403,27 @@ func (s *WebStorage) GetTradeDetails(tradeID int) ([]models2.TradeDetail, error)
 	return details, nil
 }
 
+// nullFloat сохраняет 0 как NULL (значение неизвестно)
+func nullFloat(v float64) *float64 {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
+
+// utcPtr приводит необязательное время к UTC для хранения
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
 // === Activity Log ===
 
 // AddLog добавляет запись в лог
-func (s *WebStorage) AddLog(_ context.Context, log models2.ActivityLog) error {
+func (s *WebStorage) AddLog(_ context.Context, log models.ActivityLog) error {
 	_, err := s.db.Exec(`
 		INSERT INTO activity_log (user_id, level, action, message, details)
 		VALUES (?, ?, ?, ?, ?)
@@ -784,7 +1433,7 @@ func (s *WebStorage) AddLog(_ context.Context, log models2.ActivityLog) error {
 }
 
 // GetLogs получает логи с пагинацией
-func (s *WebStorage) GetLogs(userID int, limit, offset int) ([]models2.ActivityLog, error) {
+func (s *WebStorage) GetLogs(userID int, limit, offset int) ([]models.ActivityLog, error) {
 	rows, err := s.db.Query(`
 		SELECT id, user_id, level, ACTION, message, COALESCE(details, ''), created_at
 		FROM activity_log
@@ -798,9 +1447,9 @@ func (s *WebStorage) GetLogs(userID int, limit, offset int) ([]models2.ActivityL
 
 	defer rows.Close()
 
-	var logs []models2.ActivityLog
+	var logs []models.ActivityLog
 	for rows.Next() {
-		var log models2.ActivityLog
+		var log models.ActivityLog
 		err := rows.Scan(
 			&log.ID, &log.UserID, &log.Level, &log.Action, &log.Message, &log.Details, &log.CreatedAt,
 		)
@@ -832,6 +1481,16 @@ func (s *WebStorage) HasActiveCopyTradingSession(userID int) (bool, error) {
 
 // === Telegram Integration ===
 
+// GetUserIDByTelegramChatID возвращает пользователя с привязанным Telegram chat_id (sql.ErrNoRows - не привязан)
+func (s *WebStorage) GetUserIDByTelegramChatID(chatID int64) (int, error) {
+	var userID int
+	err := s.db.QueryRow(`
+		SELECT id FROM users WHERE telegram_chat_id = ?
+	`, chatID).Scan(&userID)
+
+	return userID, err
+}
+
 // GetOrCreateUserByTelegramChatID получает или создает пользователя по Telegram chat_id
 func (s *WebStorage) GetOrCreateUserByTelegramChatID(chatID int64) (int, error) {
 	// Пытаемся найти существующего пользователя
@@ -867,20 +1526,23 @@ func (s *WebStorage) GetOrCreateUserByTelegramChatID(chatID int64) (int, error)
 }
 
 // GetAccountByName получает аккаунт по имени
-func (s *WebStorage) GetAccountByName(userID int, name string) (*models2.Account, error) {
-	var acc models2.Account
+func (s *WebStorage) GetAccountByName(userID int, name string) (*models.Account, error) {
+	var acc models.Account
 	var cookiesJSON string
-	var isMasterInt, disabledInt int
+	var isMasterInt, disabledInt, sessionInvalidInt int
 
 	err := s.db.QueryRow(`
 		SELECT id, name, token, user_id_mexc, device_id,
 		       coalesce(cookies, '{}'), coalesce(user_agent, ''), coalesce(proxy, ''),
-		       coalesce(is_master, 0), coalesce(disabled, 0)
+		       coalesce(is_master, 0), coalesce(disabled, 0), coalesce(session_invalid, 0),
+		       coalesce(copy_multiplier, 1), coalesce(cap_order_vol, 0), coalesce(cap_symbol_notional, 0),
+		       coalesce(cap_exposure, 0), coalesce(cap_positions, 0)
 		FROM accounts
 		WHERE user_id = ? AND name = ?
 		LIMIT 1
 	`, userID, name).Scan(&acc.ID, &acc.Name, &acc.Token, &acc.UserID,
-		&acc.DeviceID, &cookiesJSON, &acc.UserAgent, &acc.Proxy, &isMasterInt, &disabledInt)
+		&acc.DeviceID, &cookiesJSON, &acc.UserAgent, &acc.Proxy, &isMasterInt, &disabledInt, &sessionInvalidInt,
+		&acc.CopyMultiplier, &acc.Caps.MaxOrderVol, &acc.Caps.MaxSymbolNotional, &acc.Caps.MaxExposure, &acc.Caps.MaxPositions)
 	if err != nil {
 		return nil, err
 	}
@@ -888,6 +1550,7 @@ func (s *WebStorage) GetAccountByName(userID int, name string) (*models2.Account
 	json.Unmarshal([]byte(cookiesJSON), &acc.Cookies)
 	acc.IsMaster = isMasterInt == 1
 	acc.Disabled = disabledInt == 1
+	acc.SessionInvalid = sessionInvalidInt == 1
 
 	return &acc, nil
 }
@@ -957,32 +1620,48 @@ func (s *WebStorage) UpdateDisabledStatusByName(userID int, name string, disable
 	return nil
 }
 
+// UpdateCopyMultiplierByName задает множитель объёма входов мастера на slave аккаунте по имени
+func (s *WebStorage) UpdateCopyMultiplierByName(userID int, name string, multiplier float64) error {
+	result, err := s.db.Exec("UPDATE accounts SET copy_multiplier = ? WHERE user_id = ? AND name = ?", multiplier, userID, name)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("аккаунт %s не найден", name)
+	}
+
+	return nil
+}
+
 // === Refresh Tokens ===
 
-// SaveRefreshToken сохраняет refresh token
-func (s *WebStorage) SaveRefreshToken(userID int, token string, expiresAt time.Time) error {
+// SaveRefreshToken сохраняет refresh token (sessionStartedAt переносится при ротации)
+func (s *WebStorage) SaveRefreshToken(userID int, token string, expiresAt, sessionStartedAt time.Time) error {
 	_, err := s.db.Exec(`
-		INSERT INTO refresh_tokens (user_id, token, expires_at)
-		VALUES (?, ?, ?)
-	`, userID, token, expiresAt)
+		INSERT INTO refresh_tokens (user_id, token, expires_at, session_started_at)
+		VALUES (?, ?, ?, ?)
+	`, userID, token, expiresAt, sessionStartedAt)
 	return err
 }
 
-// GetRefreshToken получает refresh token и проверяет его валидность
-func (s *WebStorage) GetRefreshToken(token string) (userID int, err error) {
+// GetRefreshToken получает refresh token, проверяет его валидность и возвращает начало сессии
+func (s *WebStorage) GetRefreshToken(token string) (userID int, sessionStartedAt time.Time, err error) {
 	var expiresAt time.Time
 	err = s.db.QueryRow(`
-		SELECT user_id, expires_at FROM refresh_tokens WHERE token = ?
-	`, token).Scan(&userID, &expiresAt)
+		SELECT user_id, expires_at, coalesce(session_started_at, created_at)
+		FROM refresh_tokens WHERE token = ?
+	`, token).Scan(&userID, &expiresAt, &sessionStartedAt)
 	if err != nil {
-		return 0, err
+		return 0, time.Time{}, err
 	}
 	if time.Now().After(expiresAt) {
 		// Удаляем просроченный токен
 		s.db.Exec("DELETE FROM refresh_tokens WHERE token = ?", token)
-		return 0, sql.ErrNoRows
+		return 0, time.Time{}, sql.ErrNoRows
 	}
-	return userID, nil
+	return userID, sessionStartedAt, nil
 }
 
 // DeleteRefreshToken удаляет refresh token
@@ -1059,3 +1738,1103 @@ func (s *WebStorage) SaveStopOrders(userID int, orders map[string]string) error
 
 	return tx.Commit()
 }
+
+// === Feature Flags ===
+
+// GetFeatureOverrides возвращает переопределения feature flags пользователя
+func (s *WebStorage) GetFeatureOverrides(userID int) (map[string]bool, error) {
+	rows, err := s.db.Query(`
+		SELECT flag, enabled FROM feature_flag_overrides
+		WHERE user_id = ?
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := make(map[string]bool)
+	for rows.Next() {
+		var flag string
+		var enabledInt int
+		if err := rows.Scan(&flag, &enabledInt); err != nil {
+			continue
+		}
+		overrides[flag] = enabledInt == 1
+	}
+
+	return overrides, nil
+}
+
+// SetFeatureOverride сохраняет переопределение feature flag для пользователя (upsert)
+func (s *WebStorage) SetFeatureOverride(userID int, flag string, enabled bool) error {
+	enabledInt := 0
+	if enabled {
+		enabledInt = 1
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO feature_flag_overrides (user_id, flag, enabled)
+		VALUES (?, ?, ?)
+		ON CONFLICT(user_id, flag) DO UPDATE SET enabled = excluded.enabled, updated_at = CURRENT_TIMESTAMP
+	`, userID, flag, enabledInt)
+	return err
+}
+
+// DeleteFeatureOverride удаляет переопределение feature flag
+func (s *WebStorage) DeleteFeatureOverride(userID int, flag string) error {
+	_, err := s.db.Exec("DELETE FROM feature_flag_overrides WHERE user_id = ? AND flag = ?", userID, flag)
+	return err
+}
+
+// === Login Attempts ===
+
+// loginAttemptColumns сопоставляет scope блокировки с колонкой login_attempts
+var loginAttemptColumns = map[string]string{
+	"username": "username",
+	"ip":       "ip",
+}
+
+// RecordLoginAttempt сохраняет попытку входа
+func (s *WebStorage) RecordLoginAttempt(username, ip string, success bool, at time.Time) error {
+	successInt := 0
+	if success {
+		successInt = 1
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO login_attempts (username, ip, success, created_at)
+		VALUES (?, ?, ?, ?)
+	`, username, ip, successInt, at)
+	return err
+}
+
+// CountFailedLoginAttempts считает неудачные попытки входа по username или IP начиная с since
+func (s *WebStorage) CountFailedLoginAttempts(scope string, key string, since time.Time) (int, error) {
+	column, ok := loginAttemptColumns[scope]
+	if !ok {
+		return 0, fmt.Errorf("unknown lockout scope: %s", scope)
+	}
+
+	var count int
+	err := s.db.QueryRow(`
+		SELECT count(*) FROM login_attempts
+		WHERE `+column+` = ? AND success = 0 AND created_at >= ?
+	`, key, since).Scan(&count)
+	return count, err
+}
+
+// ClearFailedLoginAttempts удаляет неудачные попытки входа по username или IP
+func (s *WebStorage) ClearFailedLoginAttempts(scope string, key string) error {
+	column, ok := loginAttemptColumns[scope]
+	if !ok {
+		return fmt.Errorf("unknown lockout scope: %s", scope)
+	}
+
+	_, err := s.db.Exec("DELETE FROM login_attempts WHERE "+column+" = ? AND success = 0", key)
+	return err
+}
+
+// SetLoginLockout блокирует вход по username или IP до until (upsert)
+func (s *WebStorage) SetLoginLockout(scope string, key string, until time.Time) error {
+	_, err := s.db.Exec(`
+		INSERT INTO login_lockouts (scope, key, locked_until)
+		VALUES (?, ?, ?)
+		ON CONFLICT(scope, key) DO UPDATE SET locked_until = excluded.locked_until
+	`, scope, key, until)
+	return err
+}
+
+// GetLoginLockout возвращает время окончания блокировки (нулевое, если блокировки нет)
+func (s *WebStorage) GetLoginLockout(scope string, key string) (time.Time, error) {
+	var until time.Time
+	err := s.db.QueryRow(`
+		SELECT locked_until FROM login_lockouts WHERE scope = ? AND key = ?
+	`, scope, key).Scan(&until)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return until, err
+}
+
+// DeleteLoginLockout снимает блокировку входа
+func (s *WebStorage) DeleteLoginLockout(scope string, key string) error {
+	_, err := s.db.Exec("DELETE FROM login_lockouts WHERE scope = ? AND key = ?", scope, key)
+	return err
+}
+
+// GetActiveLoginLockouts возвращает действующие блокировки входа
+func (s *WebStorage) GetActiveLoginLockouts(now time.Time) ([]models.LoginLockout, error) {
+	rows, err := s.db.Query(`
+		SELECT scope, key, locked_until FROM login_lockouts
+		WHERE locked_until > ?
+		ORDER BY locked_until DESC
+	`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lockouts []models.LoginLockout
+	for rows.Next() {
+		var lockout models.LoginLockout
+		if err := rows.Scan(&lockout.Scope, &lockout.Key, &lockout.LockedUntil); err != nil {
+			continue
+		}
+		lockouts = append(lockouts, lockout)
+	}
+
+	return lockouts, nil
+}
+
+// === PnL ===
+
+// AddPnLEntry сохраняет событие PnL и добавляет его в дневной агрегат.
+// Возвращает false, если событие уже было учтено ранее.
+func (s *WebStorage) AddPnLEntry(userID int, entry models.PnLEntry) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	occurredAt := entry.OccurredAt.UTC()
+
+	result, err := tx.Exec(`
+		INSERT OR IGNORE INTO pnl_entries (account_id, source, ref, symbol, realized, fee, funding, volume, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, entry.AccountID, entry.Source, entry.Ref, entry.Symbol, entry.Realized, entry.Fee, entry.Funding, entry.Volume, occurredAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	// Сделкой считаются только события с объемом (funding платежи объема не имеют)
+	trades := 0
+	if entry.Volume > 0 {
+		trades = 1
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO pnl_records (user_id, account_id, symbol, day, realized_pnl, fees, funding, volume, trades)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, symbol, day) DO UPDATE SET
+			realized_pnl = realized_pnl + excluded.realized_pnl,
+			fees = fees + excluded.fees,
+			funding = funding + excluded.funding,
+			volume = volume + excluded.volume,
+			trades = trades + excluded.trades
+	`, userID, entry.AccountID, entry.Symbol, occurredAt.Format(time.DateOnly), entry.Realized, entry.Fee, entry.Funding,
+		entry.Volume, trades)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetFirstPnLEntryTime возвращает время самого раннего события PnL аккаунта из источника (zero если событий нет)
+func (s *WebStorage) GetFirstPnLEntryTime(accountID int, source string) (time.Time, error) {
+	var first time.Time
+	err := s.db.QueryRow(`
+		SELECT occurred_at FROM pnl_entries
+		WHERE account_id = ? AND source = ?
+		ORDER BY occurred_at
+		LIMIT 1
+	`, accountID, source).Scan(&first)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return first, err
+}
+
+// GetPnLRecords возвращает дневные агрегаты PnL пользователя за период [fromDay, toDay] (YYYY-MM-DD)
+func (s *WebStorage) GetPnLRecords(userID int, fromDay, toDay string) ([]models.PnLRecord, error) {
+	rows, err := s.db.Query(`
+		SELECT p.account_id, coalesce(a.name, ''), p.symbol, p.day,
+		       p.realized_pnl, p.fees, p.funding, p.volume, p.trades
+		FROM pnl_records p
+		LEFT JOIN accounts a ON a.id = p.account_id
+		WHERE p.user_id = ? AND p.day >= ? AND p.day <= ?
+		ORDER BY p.day, p.account_id, p.symbol
+	`, userID, fromDay, toDay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []models.PnLRecord
+	for rows.Next() {
+		var record models.PnLRecord
+		err := rows.Scan(&record.AccountID, &record.AccountName, &record.Symbol, &record.Day,
+			&record.RealizedPnL, &record.Fees, &record.Funding, &record.Volume, &record.Trades)
+		if err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// SavePositionHistory сохраняет закрытую позицию аккаунта из истории биржи.
+// Возвращает false, если позиция уже была сохранена ранее.
+func (s *WebStorage) SavePositionHistory(userID int, accountID int, pos models.HistoryPosition) (bool, error) {
+	result, err := s.db.Exec(`
+		INSERT OR IGNORE INTO position_history (account_id, position_id, user_id, symbol, position_type, leverage,
+			open_avg_price, close_avg_price, close_vol, realised, close_profit_loss, total_fee, hold_fee, opened_at, closed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, accountID, pos.PositionID, userID, pos.Symbol, pos.PositionType, pos.Leverage,
+		pos.OpenAvgPrice, pos.CloseAvgPrice, pos.CloseVol, pos.Realised, pos.CloseProfitLoss, pos.TotalFee, pos.HoldFee,
+		time.UnixMilli(pos.CreateTime).UTC(), time.UnixMilli(pos.UpdateTime).UTC())
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// GetPositionStats возвращает итоги закрытых позиций по аккаунтам пользователя за дни [fromDay, toDay] (YYYY-MM-DD, UTC)
+func (s *WebStorage) GetPositionStats(userID int, fromDay, toDay string) ([]models.PositionStats, error) {
+	from, err := time.Parse(time.DateOnly, fromDay)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from day: %w", err)
+	}
+	to, err := time.Parse(time.DateOnly, toDay)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to day: %w", err)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT p.account_id, coalesce(a.name, ''), count(*),
+		       coalesce(sum(CASE WHEN p.realised > 0 THEN 1 ELSE 0 END), 0),
+		       coalesce(sum(CASE WHEN p.realised < 0 THEN 1 ELSE 0 END), 0),
+		       coalesce(sum(p.realised), 0)
+		FROM position_history p
+		LEFT JOIN accounts a ON a.id = p.account_id
+		WHERE p.user_id = ? AND p.closed_at >= ? AND p.closed_at < ?
+		GROUP BY p.account_id
+		ORDER BY p.account_id
+	`, userID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.PositionStats
+	for rows.Next() {
+		var stat models.PositionStats
+		err := rows.Scan(&stat.AccountID, &stat.AccountName, &stat.Positions, &stat.Wins, &stat.Losses, &stat.Realised)
+		if err != nil {
+			continue
+		}
+		stats = append(stats, stat)
+	}
+
+	return stats, nil
+}
+
+// === Fees ===
+
+// AddFeeEntry сохраняет уплаченную комиссию и добавляет ее в дневную сумму.
+// Возвращает false, если запись уже была учтена ранее.
+func (s *WebStorage) AddFeeEntry(userID int, entry models.FeeEntry) (bool, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	occurredAt := entry.OccurredAt.UTC()
+
+	result, err := tx.Exec(`
+		INSERT OR IGNORE INTO fee_entries (account_id, source, ref, symbol, taker_fee, maker_fee, occurred_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, entry.AccountID, entry.Source, entry.Ref, entry.Symbol, entry.TakerFee, entry.MakerFee, occurredAt)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO fee_records (user_id, account_id, day, taker_fees, maker_fees, fills)
+		VALUES (?, ?, ?, ?, ?, 1)
+		ON CONFLICT(account_id, day) DO UPDATE SET
+			taker_fees = taker_fees + excluded.taker_fees,
+			maker_fees = maker_fees + excluded.maker_fees,
+			fills = fills + 1
+	`, userID, entry.AccountID, occurredAt.Format(time.DateOnly), entry.TakerFee, entry.MakerFee)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetFirstFeeEntryTime возвращает время самой ранней комиссии аккаунта из источника (zero если записей нет)
+func (s *WebStorage) GetFirstFeeEntryTime(accountID int, source string) (time.Time, error) {
+	var first time.Time
+	err := s.db.QueryRow(`
+		SELECT occurred_at FROM fee_entries
+		WHERE account_id = ? AND source = ?
+		ORDER BY occurred_at
+		LIMIT 1
+	`, accountID, source).Scan(&first)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return first, err
+}
+
+// GetFeeRecords возвращает дневные суммы комиссий пользователя за период [fromDay, toDay] (YYYY-MM-DD)
+func (s *WebStorage) GetFeeRecords(userID int, fromDay, toDay string) ([]models.FeeRecord, error) {
+	rows, err := s.db.Query(`
+		SELECT f.account_id, coalesce(a.name, ''), f.day, f.taker_fees, f.maker_fees, f.fills
+		FROM fee_records f
+		LEFT JOIN accounts a ON a.id = f.account_id
+		WHERE f.user_id = ? AND f.day >= ? AND f.day <= ?
+		ORDER BY f.day, f.account_id
+	`, userID, fromDay, toDay)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []models.FeeRecord
+	for rows.Next() {
+		var record models.FeeRecord
+		err := rows.Scan(&record.AccountID, &record.AccountName, &record.Day,
+			&record.TakerFees, &record.MakerFees, &record.Fills)
+		if err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// === Balance Snapshots ===
+
+// GetUserIDsWithAccounts возвращает ID пользователей, у которых есть хотя бы один аккаунт
+func (s *WebStorage) GetUserIDsWithAccounts() ([]int, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT user_id FROM accounts ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			continue
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, nil
+}
+
+// AddBalanceSnapshot сохраняет снимок баланса аккаунта
+func (s *WebStorage) AddBalanceSnapshot(userID int, snapshot models.BalanceSnapshot) error {
+	_, err := s.db.Exec(`
+		INSERT INTO balance_snapshots (user_id, account_id, equity, available, taken_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, userID, snapshot.AccountID, snapshot.Equity, snapshot.Available, snapshot.TakenAt.UTC())
+	return err
+}
+
+// DeleteBalanceSnapshotsBefore удаляет снимки старше before
+func (s *WebStorage) DeleteBalanceSnapshotsBefore(before time.Time) error {
+	_, err := s.db.Exec("DELETE FROM balance_snapshots WHERE taken_at < ?", before.UTC())
+	return err
+}
+
+// GetEquityCurve возвращает кривую equity аккаунта пользователя начиная с since
+func (s *WebStorage) GetEquityCurve(userID int, accountID int, since time.Time) ([]models.EquityPoint, error) {
+	rows, err := s.db.Query(`
+		SELECT taken_at, equity FROM balance_snapshots
+		WHERE user_id = ? AND account_id = ? AND taken_at >= ?
+		ORDER BY taken_at
+	`, userID, accountID, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEquityPoints(rows)
+}
+
+// GetSlavesEquityCurve возвращает суммарную кривую equity всех slave аккаунтов пользователя.
+// Снимки одного прогона имеют одинаковый taken_at, поэтому суммируются по нему.
+func (s *WebStorage) GetSlavesEquityCurve(userID int, since time.Time) ([]models.EquityPoint, error) {
+	rows, err := s.db.Query(`
+		SELECT b.taken_at, sum(b.equity) FROM balance_snapshots b
+		JOIN accounts a ON a.id = b.account_id
+		WHERE b.user_id = ? AND coalesce(a.is_master, 0) = 0 AND b.taken_at >= ?
+		GROUP BY b.taken_at
+		ORDER BY b.taken_at
+	`, userID, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanEquityPoints(rows)
+}
+
+func scanEquityPoints(rows *sql.Rows) ([]models.EquityPoint, error) {
+	points := []models.EquityPoint{}
+	for rows.Next() {
+		var point models.EquityPoint
+		if err := rows.Scan(&point.Time, &point.Equity); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+
+	return points, rows.Err()
+}
+
+// === Copy Retries ===
+
+// AddCopyRetry ставит повтор копии в очередь
+func (s *WebStorage) AddCopyRetry(_ context.Context, retry models.CopyRetry) error {
+	_, err := s.db.Exec(`
+		INSERT INTO copy_retries (user_id, account_id, trade_id, op, symbol, request, ratio, attempts,
+		                          last_error, next_attempt_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, retry.UserID, retry.AccountID, retry.TradeID, retry.Op, retry.Symbol, retry.Request, retry.Ratio,
+		retry.Attempts, retry.LastError, retry.NextAttemptAt.UTC(), retry.CreatedAt.UTC())
+	return err
+}
+
+// GetDueCopyRetries возвращает повторы, которым пришло время к моменту now, в порядке очереди
+func (s *WebStorage) GetDueCopyRetries(_ context.Context, now time.Time, limit int) ([]models.CopyRetry, error) {
+	rows, err := s.db.Query(`
+		SELECT id, user_id, account_id, trade_id, op, symbol, request, ratio, attempts, last_error,
+		       next_attempt_at, created_at
+		FROM copy_retries
+		WHERE next_attempt_at <= ?
+		ORDER BY next_attempt_at, id
+		LIMIT ?
+	`, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var retries []models.CopyRetry
+	for rows.Next() {
+		var retry models.CopyRetry
+		err := rows.Scan(&retry.ID, &retry.UserID, &retry.AccountID, &retry.TradeID, &retry.Op, &retry.Symbol,
+			&retry.Request, &retry.Ratio, &retry.Attempts, &retry.LastError, &retry.NextAttemptAt, &retry.CreatedAt)
+		if err != nil {
+			continue
+		}
+		retries = append(retries, retry)
+	}
+
+	return retries, rows.Err()
+}
+
+// UpdateCopyRetry записывает попытки, ошибку и время следующего повтора
+func (s *WebStorage) UpdateCopyRetry(_ context.Context, retry models.CopyRetry) error {
+	_, err := s.db.Exec(`
+		UPDATE copy_retries SET attempts = ?, last_error = ?, next_attempt_at = ? WHERE id = ?
+	`, retry.Attempts, retry.LastError, retry.NextAttemptAt.UTC(), retry.ID)
+	return err
+}
+
+// DeleteCopyRetry удаляет повтор из очереди
+func (s *WebStorage) DeleteCopyRetry(_ context.Context, id int) error {
+	_, err := s.db.Exec("DELETE FROM copy_retries WHERE id = ?", id)
+	return err
+}
+
+// DeleteCopyRetries удаляет повторы пользователя по операции и символу
+func (s *WebStorage) DeleteCopyRetries(_ context.Context, userID int, op, symbol string) error {
+	_, err := s.db.Exec("DELETE FROM copy_retries WHERE user_id = ? AND op = ? AND symbol = ?", userID, op, symbol)
+	return err
+}
+
+// === Master Events ===
+
+// AddMasterEvent сохраняет WebSocket событие master аккаунта
+func (s *WebStorage) AddMasterEvent(userID int, event models.MasterEvent) error {
+	_, err := s.db.Exec(`
+		INSERT INTO master_events (user_id, account_id, kind, payload, received_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, userID, event.AccountID, event.Kind, event.Payload, event.ReceivedAt.UTC())
+	return err
+}
+
+// GetMasterEvents возвращает события master аккаунта пользователя в порядке получения начиная с since
+func (s *WebStorage) GetMasterEvents(userID int, since time.Time, limit int) ([]models.MasterEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT id, account_id, kind, payload, received_at FROM master_events
+		WHERE user_id = ? AND received_at >= ?
+		ORDER BY received_at, id
+		LIMIT ?
+	`, userID, since.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.MasterEvent
+	for rows.Next() {
+		var event models.MasterEvent
+		if err := rows.Scan(&event.ID, &event.AccountID, &event.Kind, &event.Payload, &event.ReceivedAt); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// === WebSocket Frames ===
+
+// RecordWSFrame сохраняет сырое push.personal.* сообщение аккаунта (websocket.FrameRecorder)
+func (s *WebStorage) RecordWSFrame(frame models.WSFrame) error {
+	_, err := s.db.Exec(`
+		INSERT INTO ws_frames (account_id, channel, ts, payload, received_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, frame.AccountID, frame.Channel, frame.Ts, frame.Payload, frame.ReceivedAt.UTC())
+	return err
+}
+
+// GetWSFrames возвращает сырые сообщения аккаунта в порядке получения начиная с since
+func (s *WebStorage) GetWSFrames(accountID int, since time.Time, limit int) ([]models.WSFrame, error) {
+	rows, err := s.db.Query(`
+		SELECT id, account_id, channel, ts, payload, received_at FROM ws_frames
+		WHERE account_id = ? AND received_at >= ?
+		ORDER BY received_at, id
+		LIMIT ?
+	`, accountID, since.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var frames []models.WSFrame
+	for rows.Next() {
+		var frame models.WSFrame
+		if err := rows.Scan(&frame.ID, &frame.AccountID, &frame.Channel, &frame.Ts, &frame.Payload, &frame.ReceivedAt); err != nil {
+			continue
+		}
+		frames = append(frames, frame)
+	}
+
+	return frames, nil
+}
+
+// === Alerts ===
+
+const alertRuleColumns = `id, user_id, type, account_id, threshold, window_minutes, cooldown_minutes,
+	channels, webhook_url, enabled, created_at`
+
+// CreateAlertRule создает правило алерта и возвращает его ID
+func (s *WebStorage) CreateAlertRule(rule models.AlertRule) (int, error) {
+	result, err := s.db.Exec(`
+		INSERT INTO alert_rules (user_id, type, account_id, threshold, window_minutes, cooldown_minutes,
+		                         channels, webhook_url, enabled, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, rule.UserID, rule.Type, rule.AccountID, rule.Threshold, rule.WindowMinutes, rule.CooldownMinutes,
+		strings.Join(rule.Channels, ","), rule.WebhookURL, rule.Enabled, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+
+	id, _ := result.LastInsertId()
+
+	return int(id), nil
+}
+
+// UpdateAlertRule обновляет правило алерта пользователя
+func (s *WebStorage) UpdateAlertRule(rule models.AlertRule) error {
+	result, err := s.db.Exec(`
+		UPDATE alert_rules SET type = ?, account_id = ?, threshold = ?, window_minutes = ?, cooldown_minutes = ?,
+		                       channels = ?, webhook_url = ?, enabled = ?
+		WHERE id = ? AND user_id = ?
+	`, rule.Type, rule.AccountID, rule.Threshold, rule.WindowMinutes, rule.CooldownMinutes,
+		strings.Join(rule.Channels, ","), rule.WebhookURL, rule.Enabled, rule.ID, rule.UserID)
+	if err != nil {
+		return err
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// DeleteAlertRule удаляет правило алерта пользователя
+func (s *WebStorage) DeleteAlertRule(userID int, ruleID int) error {
+	result, err := s.db.Exec("DELETE FROM alert_rules WHERE id = ? AND user_id = ?", ruleID, userID)
+	if err != nil {
+		return err
+	}
+
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetAlertRules возвращает правила алертов пользователя
+func (s *WebStorage) GetAlertRules(userID int) ([]models.AlertRule, error) {
+	rows, err := s.db.Query(`SELECT `+alertRuleColumns+` FROM alert_rules WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAlertRules(rows)
+}
+
+// GetEnabledAlertRules возвращает включенные правила алертов всех пользователей
+func (s *WebStorage) GetEnabledAlertRules() ([]models.AlertRule, error) {
+	rows, err := s.db.Query(`SELECT ` + alertRuleColumns + ` FROM alert_rules WHERE enabled = 1 ORDER BY user_id, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanAlertRules(rows)
+}
+
+func scanAlertRules(rows *sql.Rows) ([]models.AlertRule, error) {
+	rules := []models.AlertRule{}
+	for rows.Next() {
+		var rule models.AlertRule
+		var channels string
+		err := rows.Scan(&rule.ID, &rule.UserID, &rule.Type, &rule.AccountID, &rule.Threshold, &rule.WindowMinutes,
+			&rule.CooldownMinutes, &channels, &rule.WebhookURL, &rule.Enabled, &rule.CreatedAt)
+		if err != nil {
+			continue
+		}
+		rule.Channels = strings.Split(channels, ",")
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// AddAlertEvent сохраняет срабатывание правила алерта
+func (s *WebStorage) AddAlertEvent(event models.AlertEvent) error {
+	_, err := s.db.Exec(`
+		INSERT INTO alert_events (rule_id, user_id, account_id, message, fired_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, event.RuleID, event.UserID, event.AccountID, event.Message, event.FiredAt.UTC())
+	return err
+}
+
+// GetLastAlertTime возвращает время последнего срабатывания правила по аккаунту (zero если не срабатывало)
+func (s *WebStorage) GetLastAlertTime(ruleID int, accountID int) (time.Time, error) {
+	var last time.Time
+	err := s.db.QueryRow(`
+		SELECT fired_at FROM alert_events
+		WHERE rule_id = ? AND account_id = ?
+		ORDER BY fired_at DESC
+		LIMIT 1
+	`, ruleID, accountID).Scan(&last)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return last, err
+}
+
+// GetAlertEvents возвращает последние срабатывания алертов пользователя
+func (s *WebStorage) GetAlertEvents(userID int, limit int) ([]models.AlertEvent, error) {
+	rows, err := s.db.Query(`
+		SELECT e.id, e.rule_id, e.user_id, e.account_id, coalesce(a.name, ''), coalesce(r.type, ''), e.message, e.fired_at
+		FROM alert_events e
+		LEFT JOIN alert_rules r ON r.id = e.rule_id
+		LEFT JOIN accounts a ON a.id = e.account_id
+		WHERE e.user_id = ?
+		ORDER BY e.fired_at DESC
+		LIMIT ?
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AlertEvent{}
+	for rows.Next() {
+		var event models.AlertEvent
+		err := rows.Scan(&event.ID, &event.RuleID, &event.UserID, &event.AccountID, &event.AccountName,
+			&event.Type, &event.Message, &event.FiredAt)
+		if err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// GetSlaveFailureCounts возвращает количество неуспешных исполнений по slave аккаунтам пользователя начиная с since
+func (s *WebStorage) GetSlaveFailureCounts(userID int, since time.Time) ([]models.AccountCount, error) {
+	rows, err := s.db.Query(`
+		SELECT td.account_id, coalesce(a.name, ''), count(*)
+		FROM trade_details td
+		JOIN trades t ON t.id = td.trade_id
+		LEFT JOIN accounts a ON a.id = td.account_id
+		WHERE t.user_id = ? AND td.status = 'failed' AND td.dispatched_at >= ?
+		GROUP BY td.account_id
+	`, userID, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []models.AccountCount
+	for rows.Next() {
+		var count models.AccountCount
+		if err := rows.Scan(&count.AccountID, &count.AccountName, &count.Count); err != nil {
+			continue
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, nil
+}
+
+// GetLatestBalanceSnapshots возвращает последний снимок баланса каждого аккаунта пользователя
+func (s *WebStorage) GetLatestBalanceSnapshots(userID int) ([]models.BalanceSnapshot, error) {
+	rows, err := s.db.Query(`
+		SELECT b.account_id, b.equity, b.available, b.taken_at
+		FROM balance_snapshots b
+		WHERE b.user_id = ? AND b.id = (
+			SELECT id FROM balance_snapshots WHERE account_id = b.account_id ORDER BY taken_at DESC LIMIT 1
+		)
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []models.BalanceSnapshot
+	for rows.Next() {
+		var snapshot models.BalanceSnapshot
+		if err := rows.Scan(&snapshot.AccountID, &snapshot.Equity, &snapshot.Available, &snapshot.TakenAt); err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+// GetLastMasterActivity возвращает время последнего события master аккаунта: записанного WebSocket события
+// или исполнения сделки (mirror режим не журналирует события). Zero если активности не было.
+func (s *WebStorage) GetLastMasterActivity(userID int) (time.Time, error) {
+	var last time.Time
+
+	for _, query := range []string{
+		`SELECT received_at FROM master_events WHERE user_id = ? ORDER BY received_at DESC LIMIT 1`,
+		`SELECT td.dispatched_at FROM trade_details td JOIN trades t ON t.id = td.trade_id
+		 WHERE t.user_id = ? AND td.dispatched_at IS NOT NULL ORDER BY td.dispatched_at DESC LIMIT 1`,
+	} {
+		var at time.Time
+		err := s.db.QueryRow(query, userID).Scan(&at)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+
+	return last, nil
+}
+
+// GetTelegramChatID возвращает Telegram chat_id пользователя (0 если Telegram не привязан)
+func (s *WebStorage) GetTelegramChatID(userID int) (int64, error) {
+	var chatID sql.NullInt64
+	err := s.db.QueryRow("SELECT telegram_chat_id FROM users WHERE id = ?", userID).Scan(&chatID)
+	if err != nil {
+		return 0, err
+	}
+	return chatID.Int64, nil
+}
+
+// === Reports ===
+
+// GetReportSettings возвращает подписку пользователя на отчеты (sql.ErrNoRows если не настроена)
+func (s *WebStorage) GetReportSettings(userID int) (models.ReportSettings, error) {
+	settings := models.ReportSettings{UserID: userID}
+
+	var channels string
+	err := s.db.QueryRow(`
+		SELECT period, channels, last_sent_at FROM report_settings WHERE user_id = ?
+	`, userID).Scan(&settings.Period, &channels, &settings.LastSentAt)
+	if err != nil {
+		return settings, err
+	}
+	settings.Channels = strings.Split(channels, ",")
+
+	return settings, nil
+}
+
+// SetReportSettings сохраняет подписку на отчеты.
+// Для новой подписки last_sent_at = now: первый отчет уйдет по расписанию, а не сразу.
+func (s *WebStorage) SetReportSettings(settings models.ReportSettings) error {
+	_, err := s.db.Exec(`
+		INSERT INTO report_settings (user_id, period, channels, last_sent_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET period = excluded.period, channels = excluded.channels
+	`, settings.UserID, settings.Period, strings.Join(settings.Channels, ","), time.Now().UTC())
+	return err
+}
+
+// DeleteReportSettings отключает отчеты пользователя
+func (s *WebStorage) DeleteReportSettings(userID int) error {
+	_, err := s.db.Exec("DELETE FROM report_settings WHERE user_id = ?", userID)
+	return err
+}
+
+// GetAllReportSettings возвращает подписки на отчеты всех пользователей
+func (s *WebStorage) GetAllReportSettings() ([]models.ReportSettings, error) {
+	rows, err := s.db.Query(`SELECT user_id, period, channels, last_sent_at FROM report_settings ORDER BY user_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []models.ReportSettings
+	for rows.Next() {
+		var settings models.ReportSettings
+		var channels string
+		if err := rows.Scan(&settings.UserID, &settings.Period, &channels, &settings.LastSentAt); err != nil {
+			continue
+		}
+		settings.Channels = strings.Split(channels, ",")
+		all = append(all, settings)
+	}
+
+	return all, nil
+}
+
+// MarkReportSent запоминает время отправки отчета
+func (s *WebStorage) MarkReportSent(userID int, sentAt time.Time) error {
+	_, err := s.db.Exec("UPDATE report_settings SET last_sent_at = ? WHERE user_id = ?", sentAt.UTC(), userID)
+	return err
+}
+
+// GetTradeStats возвращает число скопированных сделок и исполнений по slave аккаунтам за [from, to)
+func (s *WebStorage) GetTradeStats(userID int, from, to time.Time) (models.TradeStats, error) {
+	stats := models.TradeStats{Accounts: []models.AccountExecutions{}}
+
+	rows, err := s.db.Query(`
+		SELECT td.account_id, coalesce(a.name, ''),
+		       sum(CASE WHEN td.status = 'success' THEN 1 ELSE 0 END),
+		       sum(CASE WHEN td.status = 'failed' THEN 1 ELSE 0 END)
+		FROM trade_details td
+		JOIN trades t ON t.id = td.trade_id
+		LEFT JOIN accounts a ON a.id = td.account_id
+		WHERE t.user_id = ? AND td.dispatched_at >= ? AND td.dispatched_at < ?
+		GROUP BY td.account_id
+		ORDER BY td.account_id
+	`, userID, from.UTC(), to.UTC())
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var acc models.AccountExecutions
+		if err := rows.Scan(&acc.AccountID, &acc.AccountName, &acc.Succeeded, &acc.Failed); err != nil {
+			continue
+		}
+		stats.Succeeded += acc.Succeeded
+		stats.Failed += acc.Failed
+		stats.Accounts = append(stats.Accounts, acc)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	err = s.db.QueryRow(`
+		SELECT count(DISTINCT td.trade_id)
+		FROM trade_details td
+		JOIN trades t ON t.id = td.trade_id
+		WHERE t.user_id = ? AND td.dispatched_at >= ? AND td.dispatched_at < ?
+	`, userID, from.UTC(), to.UTC()).Scan(&stats.Trades)
+
+	return stats, err
+}
+
+// === Account health ===
+
+// GetExecutionSamples возвращает исполнения на аккаунтах пользователя начиная с since (по времени отправки)
+func (s *WebStorage) GetExecutionSamples(userID int, since time.Time) ([]models.ExecutionSample, error) {
+	rows, err := s.db.Query(`
+		SELECT td.account_id, td.status, coalesce(td.error, ''), td.dispatched_at, td.acked_at
+		FROM trade_details td
+		JOIN trades t ON t.id = td.trade_id
+		WHERE t.user_id = ? AND td.dispatched_at IS NOT NULL AND td.dispatched_at >= ?
+		ORDER BY td.dispatched_at
+	`, userID, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []models.ExecutionSample
+	for rows.Next() {
+		var sample models.ExecutionSample
+		err := rows.Scan(&sample.AccountID, &sample.Status, &sample.Error, &sample.DispatchedAt, &sample.AckedAt)
+		if err != nil {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+
+	return samples, rows.Err()
+}
+
+// === Notification settings ===
+
+// GetNotificationSettings возвращает каналы уведомлений пользователя (sql.ErrNoRows если не настроены)
+func (s *WebStorage) GetNotificationSettings(userID int) (models.NotificationSettings, error) {
+	settings := models.NotificationSettings{UserID: userID}
+
+	var channels string
+	err := s.db.QueryRow(`
+		SELECT channels, discord_webhook_url, slack_webhook_url FROM notification_settings WHERE user_id = ?
+	`, userID).Scan(&channels, &settings.DiscordWebhookURL, &settings.SlackWebhookURL)
+	if err != nil {
+		return settings, err
+	}
+
+	settings.Channels = []string{}
+	if channels != "" {
+		settings.Channels = strings.Split(channels, ",")
+	}
+
+	return settings, nil
+}
+
+// SetNotificationSettings сохраняет каналы уведомлений пользователя
+func (s *WebStorage) SetNotificationSettings(settings models.NotificationSettings) error {
+	_, err := s.db.Exec(`
+		INSERT INTO notification_settings (user_id, channels, discord_webhook_url, slack_webhook_url)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET
+			channels = excluded.channels,
+			discord_webhook_url = excluded.discord_webhook_url,
+			slack_webhook_url = excluded.slack_webhook_url
+	`, settings.UserID, strings.Join(settings.Channels, ","), settings.DiscordWebhookURL, settings.SlackWebhookURL)
+	return err
+}
+
+// === Symbol filters ===
+
+// GetSymbolFilter возвращает фильтр символов копирования пользователя (sql.ErrNoRows если не настроен)
+func (s *WebStorage) GetSymbolFilter(userID int) (models.SymbolFilter, error) {
+	filter := models.SymbolFilter{UserID: userID}
+
+	var symbols string
+	err := s.db.QueryRow(`SELECT mode, symbols FROM symbol_filters WHERE user_id = ?`, userID).Scan(&filter.Mode, &symbols)
+	if err != nil {
+		return filter, err
+	}
+
+	filter.Symbols = []string{}
+	if symbols != "" {
+		filter.Symbols = strings.Split(symbols, ",")
+	}
+
+	return filter, nil
+}
+
+// SetSymbolFilter сохраняет фильтр символов копирования пользователя
+func (s *WebStorage) SetSymbolFilter(filter models.SymbolFilter) error {
+	_, err := s.db.Exec(`
+		INSERT INTO symbol_filters (user_id, mode, symbols)
+		VALUES (?, ?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET mode = excluded.mode, symbols = excluded.symbols
+	`, filter.UserID, filter.Mode, strings.Join(filter.Symbols, ","))
+	return err
+}
+
+// === Runtime state ===
+
+// GetRuntimeState возвращает сохраненное значение key (sql.ErrNoRows если не сохранялось)
+func (s *WebStorage) GetRuntimeState(key string) (string, error) {
+	var value string
+	err := s.db.QueryRow(`SELECT value FROM runtime_state WHERE key = ?`, key).Scan(&value)
+	return value, err
+}
+
+// SetRuntimeState сохраняет значение key
+func (s *WebStorage) SetRuntimeState(key, value string) error {
+	_, err := s.db.Exec(`
+		INSERT INTO runtime_state (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP
+	`, key, value)
+	return err
+}
+
+// === Backup ===
+
+// Backup записывает согласованный снимок базы в файл path (SQLite online backup API:
+// запись в базу во время снимка не блокируется)
+func (s *WebStorage) Backup(ctx context.Context, path string) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		src, ok := driverConn.(interface {
+			NewBackup(dstURI string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return fmt.Errorf("sqlite driver does not support backup")
+		}
+
+		bck, err := src.NewBackup(path)
+		if err != nil {
+			return fmt.Errorf("failed to start backup: %w", err)
+		}
+
+		for more := true; more; {
+			if more, err = bck.Step(-1); err != nil {
+				_ = bck.Finish()
+				return fmt.Errorf("failed to copy database: %w", err)
+			}
+		}
+
+		return bck.Finish()
+	})
+}
@@ -31,9 +31,12 @@ func New(token string, logger *slog.Logger) (*Service, error) {
 		{Command: "balance", Description: "Баланс всех аккаунтов"},
 		{Command: "fee_rates", Description: "Проверить комиссии всех аккаунтов"},
 		{Command: "set_master", Description: "Установить главный аккаунт"},
+		{Command: "set_multiplier", Description: "Множитель объёма копирования slave"},
 		{Command: "start_copy", Description: "Запустить copy trading [ignore_fees]"},
 		{Command: "stop_copy", Description: "Остановить copy trading"},
 		{Command: "copy_status", Description: "Статус copy trading"},
+		{Command: "ws_health", Description: "Задержки WebSocket мастера"},
+		{Command: "set_symbol_filter", Description: "Фильтр символов копирования"},
 		{Command: "open", Description: "Открыть на аккаунте"},
 		{Command: "close", Description: "Закрыть на аккаунте"},
 		{Command: "open_all", Description: "Открыть на всех аккаунтах"},
@@ -41,6 +44,8 @@ func New(token string, logger *slog.Logger) (*Service, error) {
 		{Command: "positions", Description: "Показать открытые позиции"},
 		{Command: "open_orders", Description: "Показать открытые ордера"},
 		{Command: "open_stop_orders", Description: "Показать стоп-ордера"},
+		{Command: "funding", Description: "Ставка финансирования символа"},
+		{Command: "transfer", Description: "Перевод spot ↔ futures на аккаунте"},
 		{Command: "delete", Description: "Удалить аккаунт"},
 		{Command: "help", Description: "Помощь"},
 	}
@@ -60,6 +65,20 @@ func New(token string, logger *slog.Logger) (*Service, error) {
 	}, nil
 }
 
+// NewSender создает Telegram сервис только для отправки сообщений (без настройки меню команд)
+func NewSender(token string, logger *slog.Logger) (*Service, error) {
+	bot, err := tgbotapi.NewBotAPI(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		bot:         bot,
+		logger:      logger,
+		updatesChan: make(chan tgbotapi.Update, 100),
+	}, nil
+}
+
 // GetUpdatesChan возвращает канал обновлений
 func (s *Service) GetUpdatesChan() tgbotapi.UpdatesChannel {
 	u := tgbotapi.NewUpdate(0)
@@ -85,6 +104,60 @@ func (s *Service) SendHTMLMessage(chatID int64, text string) error {
 	return err
 }
 
+// SendDocument отправляет файл документом с подписью
+func (s *Service) SendDocument(chatID int64, fileName string, data []byte, caption string) error {
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: fileName, Bytes: data})
+	doc.Caption = caption
+	_, err := s.bot.Send(doc)
+
+	return err
+}
+
+// Button - inline кнопка: текст и callback data (до 64 байт)
+type Button struct {
+	Text string
+	Data string
+}
+
+// SendMessageWithButtons отправляет сообщение с inline клавиатурой (каждый элемент rows - ряд кнопок)
+func (s *Service) SendMessageWithButtons(chatID int64, text string, rows [][]Button) error {
+	keyboard := make([][]tgbotapi.InlineKeyboardButton, 0, len(rows))
+	for _, row := range rows {
+		buttons := make([]tgbotapi.InlineKeyboardButton, 0, len(row))
+		for _, b := range row {
+			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(b.Text, b.Data))
+		}
+		keyboard = append(keyboard, buttons)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+	_, err := s.bot.Send(msg)
+
+	return err
+}
+
+// AnswerCallback подтверждает нажатие inline кнопки (text показывается всплывающим уведомлением)
+func (s *Service) AnswerCallback(callbackID string, text string) error {
+	_, err := s.bot.Request(tgbotapi.NewCallback(callbackID, text))
+
+	return err
+}
+
+// DeleteMessage удаляет сообщение из чата
+func (s *Service) DeleteMessage(chatID int64, messageID int) error {
+	_, err := s.bot.Request(tgbotapi.NewDeleteMessage(chatID, messageID))
+
+	return err
+}
+
+// EditMessageText заменяет текст сообщения и убирает его inline клавиатуру
+func (s *Service) EditMessageText(chatID int64, messageID int, text string) error {
+	_, err := s.bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, text))
+
+	return err
+}
+
 // GetFileDirectURL получает прямую ссылку на файл
 func (s *Service) GetFileDirectURL(fileID string) (string, error) {
 	return s.bot.GetFileDirectURL(fileID)
@@ -2,13 +2,15 @@ package telegramcopytrading
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
-	"time"
 
+	"tg_mexc/internal/mailer"
 	"tg_mexc/internal/mexc/copytrading"
 	wscopytrading "tg_mexc/internal/mexc/copytrading/websocket"
+	"tg_mexc/internal/mexc/websocket"
 	"tg_mexc/internal/models"
 	"tg_mexc/internal/storage"
 )
@@ -18,6 +20,7 @@ import (
 type Service struct {
 	manager *copytrading.Manager
 	storage *storage.WebStorage
+	alerter *mailer.Alerter
 	logger  *slog.Logger
 
 	mu       sync.RWMutex
@@ -27,26 +30,29 @@ type Service struct {
 type telegramSession struct {
 	userID     int
 	wsService  *wscopytrading.Service
-	eventChan  chan string
 	ignoreFees bool
+	delay      copytrading.CopyDelay
 }
 
-// New создает новый Telegram copy trading сервис
+// New создает новый Telegram copy trading сервис.
+// alerter уведомляет об автоматической остановке сессий (nil - без уведомлений)
 func New(
 	manager *copytrading.Manager,
 	storage *storage.WebStorage,
+	alerter *mailer.Alerter,
 	logger *slog.Logger,
 ) *Service {
 	return &Service{
 		manager:  manager,
 		storage:  storage,
+		alerter:  alerter,
 		logger:   logger,
 		sessions: make(map[int64]*telegramSession),
 	}
 }
 
-// Start запускает copy trading для Telegram чата
-func (s *Service) Start(chatID int64, ignoreFees bool) (string, error) {
+// Start запускает copy trading для Telegram чата. delay - задержка копирования сессии (zero - без задержки)
+func (s *Service) Start(chatID int64, ignoreFees bool, delay copytrading.CopyDelay) (string, error) {
 	// Получаем или создаем пользователя
 	userID, err := s.storage.GetOrCreateUserByTelegramChatID(chatID)
 	if err != nil {
@@ -59,16 +65,6 @@ func (s *Service) Start(chatID int64, ignoreFees bool) (string, error) {
 		return "", fmt.Errorf("мастер аккаунт не установлен. Используй /set_master <name>")
 	}
 
-	// Проверяем, что есть slave аккаунты
-	slaves, err := s.storage.GetSlaveAccounts(userID, ignoreFees)
-	if err != nil {
-		return "", fmt.Errorf("ошибка получения slave аккаунтов: %w", err)
-	}
-
-	if len(slaves) == 0 {
-		return "", fmt.Errorf("нет активных slave аккаунтов для копирования")
-	}
-
 	// Проверяем, не запущена ли уже сессия
 	s.mu.Lock()
 	if _, ok := s.sessions[chatID]; ok {
@@ -83,23 +79,49 @@ func (s *Service) Start(chatID int64, ignoreFees bool) (string, error) {
 		return "", fmt.Errorf("не удалось создать сессию: %w", err)
 	}
 
+	// Выбор slave аккаунтов - в engine: ignore fees включает и отключенные из-за комиссии
+	session.SetIncludeDisabled(ignoreFees)
+	session.SetCopyDelay(delay)
+
+	// Проверяем, что есть slave аккаунты
+	slaves, err := session.Slaves()
+	if err != nil {
+		s.manager.StopSession(userID, "websocket")
+		return "", fmt.Errorf("ошибка получения slave аккаунтов: %w", err)
+	}
+
+	if len(slaves) == 0 {
+		s.manager.StopSession(userID, "websocket")
+		return "", fmt.Errorf("нет активных slave аккаунтов для копирования")
+	}
+
 	// Создаем WebSocket сервис
 	wsService := wscopytrading.NewService(session, s.logger)
+	wsService.SetCloseHandler(func(master models.Account, err error) {
+		s.autoStop(chatID, wsService, master, err)
+	})
+	wsService.SetStateHandler(func(master models.Account, state websocket.State, err error) {
+		s.connectionState(chatID, wsService, master, state, err)
+	})
 	if err := wsService.Start(); err != nil {
 		s.manager.StopSession(userID, "websocket")
+
+		// Отказ в авторизации - пользователю нужно обновить токен мастера, а не повторять запуск
+		var loginErr *websocket.LoginError
+		if errors.As(err, &loginErr) {
+			return "", fmt.Errorf("MEXC отклонила авторизацию мастер аккаунта %s (%s): токен истек или неверен. "+
+				"Обнови данные аккаунта через /add_browser и запусти copy trading снова", master.Name, loginErr.Message)
+		}
 		return "", fmt.Errorf("ошибка WebSocket подключения: %w", err)
 	}
 
-	// Создаем канал для событий
-	eventChan := make(chan string, 100)
-
 	// Сохраняем сессию
 	s.mu.Lock()
 	s.sessions[chatID] = &telegramSession{
 		userID:     userID,
 		wsService:  wsService,
-		eventChan:  eventChan,
 		ignoreFees: ignoreFees,
+		delay:      delay,
 	}
 	s.mu.Unlock()
 
@@ -109,6 +131,8 @@ func (s *Service) Start(chatID int64, ignoreFees bool) (string, error) {
 		slog.String("master", master.Name),
 		slog.Int("slaves", len(slaves)),
 		slog.Bool("ignore_fees", ignoreFees),
+		slog.Duration("copy_delay", delay.Delay),
+		slog.Duration("copy_jitter", delay.Jitter),
 		slog.Bool("dry_run", s.manager.IsDryRun()))
 
 	dryRunInfo := ""
@@ -120,8 +144,20 @@ func (s *Service) Start(chatID int64, ignoreFees bool) (string, error) {
 
 👑 Мастер: %s
 📊 Slave аккаунтов: %d
-🔄 Ignore fees: %v%s`,
-		master.Name, len(slaves), ignoreFees, dryRunInfo), nil
+🔄 Ignore fees: %v%s%s`,
+		master.Name, len(slaves), ignoreFees, delayText(delay), dryRunInfo), nil
+}
+
+// delayText описывает задержку копирования сессии для сообщений (пусто - без задержки)
+func delayText(delay copytrading.CopyDelay) string {
+	switch {
+	case delay.IsZero():
+		return ""
+	case delay.Jitter > 0:
+		return fmt.Sprintf("\n⏳ Задержка копирования: %s + до %s", delay.Delay, delay.Jitter)
+	default:
+		return fmt.Sprintf("\n⏳ Задержка копирования: %s", delay.Delay)
+	}
 }
 
 // Stop останавливает copy trading для Telegram чата
@@ -143,9 +179,6 @@ func (s *Service) Stop(chatID int64) (string, error) {
 	// Останавливаем сессию в менеджере
 	s.manager.StopSession(session.userID, "websocket")
 
-	// Закрываем канал событий
-	close(session.eventChan)
-
 	s.logger.Info("Copy trading stopped for Telegram",
 		slog.Int64("chat_id", chatID),
 		slog.Int("user_id", session.userID))
@@ -153,6 +186,82 @@ func (s *Service) Stop(chatID int64) (string, error) {
 	return "✅ Copy Trading остановлен", nil
 }
 
+// StopUser останавливает copy trading пользователя по его userID (автоматическая остановка engine,
+// например по дневному убытку slave)
+func (s *Service) StopUser(userID int) error {
+	s.mu.RLock()
+	chatID, found := int64(0), false
+	for id, session := range s.sessions {
+		if session.userID == userID {
+			chatID, found = id, true
+			break
+		}
+	}
+	s.mu.RUnlock()
+
+	if !found {
+		return fmt.Errorf("copy trading не активен")
+	}
+
+	_, err := s.Stop(chatID)
+	return err
+}
+
+// autoStop останавливает сессию после разрыва WebSocket master аккаунта и уведомляет пользователя
+func (s *Service) autoStop(chatID int64, wsService *wscopytrading.Service, master models.Account, cause error) {
+	s.mu.Lock()
+	session, ok := s.sessions[chatID]
+	// Сессия уже остановлена или перезапущена - разрыв относится к старому соединению
+	if !ok || session.wsService != wsService {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.sessions, chatID)
+	s.mu.Unlock()
+
+	s.manager.StopSession(session.userID, "websocket")
+
+	s.logger.Warn("Copy trading stopped automatically",
+		slog.Int64("chat_id", chatID),
+		slog.Int("user_id", session.userID),
+		slog.String("master", master.Name),
+		slog.Any("cause", cause))
+
+	ctx := context.Background()
+	if errors.Is(cause, websocket.ErrAuthFailed) {
+		s.alerter.AccountAuthExpired(ctx, session.userID, master.Name)
+		return
+	}
+
+	s.alerter.SessionStopped(ctx, session.userID, master.Name)
+}
+
+// connectionState уведомляет пользователя о разрыве и восстановлении WebSocket master аккаунта.
+// Окончательный разрыв обрабатывает autoStop
+func (s *Service) connectionState(chatID int64, wsService *wscopytrading.Service, master models.Account, state websocket.State, cause error) {
+	s.mu.RLock()
+	session, ok := s.sessions[chatID]
+	s.mu.RUnlock()
+	// Сессия уже остановлена или перезапущена - событие относится к старому соединению
+	if !ok || session.wsService != wsService {
+		return
+	}
+
+	s.logger.Info("WebSocket connection state changed",
+		slog.Int64("chat_id", chatID),
+		slog.String("master", master.Name),
+		slog.String("state", string(state)),
+		slog.Any("cause", cause))
+
+	ctx := context.Background()
+	switch state {
+	case websocket.StateReconnecting:
+		s.alerter.SessionReconnecting(ctx, session.userID, master.Name)
+	case websocket.StateConnected:
+		s.alerter.SessionReconnected(ctx, session.userID, master.Name)
+	}
+}
+
 // IsActive проверяет, активен ли copy trading
 func (s *Service) IsActive(chatID int64) bool {
 	s.mu.RLock()
@@ -176,7 +285,12 @@ func (s *Service) GetStatus(chatID int64) string {
 		return "📊 Copy Trading: ✅ АКТИВЕН\n❌ Ошибка получения мастера"
 	}
 
-	slaves, _ := s.storage.GetSlaveAccounts(session.userID, session.ignoreFees)
+	slaves, _ := s.manager.Slaves(session.userID)
+
+	marginInfo := ""
+	if usage, ok := s.manager.MasterMargin(master.ID); ok {
+		marginInfo = fmt.Sprintf("\n💰 Equity мастера: %.2f USDT, маржа занята на %.1f%%", usage.Equity, usage.Ratio()*100)
+	}
 
 	dryRunInfo := ""
 	if s.manager.IsDryRun() {
@@ -185,22 +299,53 @@ func (s *Service) GetStatus(chatID int64) string {
 
 	return fmt.Sprintf(`📊 Copy Trading: ✅ АКТИВЕН
 
-👑 Мастер: %s
+👑 Мастер: %s%s
 📊 Slave аккаунтов: %d
-🔄 Ignore fees: %v%s`,
-		master.Name, len(slaves), session.ignoreFees, dryRunInfo)
+🔄 Ignore fees: %v%s%s`,
+		master.Name, marginInfo, len(slaves), session.ignoreFees, delayText(session.delay), dryRunInfo)
 }
 
-// GetEventChannel возвращает канал событий для чата
-func (s *Service) GetEventChannel(chatID int64) <-chan string {
+// GetHealth возвращает задержки WebSocket соединения master аккаунта: RTT ping/pong и отставание
+// событий от времени биржи
+func (s *Service) GetHealth(chatID int64) string {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
-
 	session, ok := s.sessions[chatID]
+	s.mu.RUnlock()
+
 	if !ok {
-		return nil
+		return "📡 Copy trading не активен. Запусти /start_copy"
+	}
+
+	health := session.wsService.Health()
+
+	verdict := "✅ Соединение достаточно быстрое для копирования"
+	if health.Slow {
+		verdict = fmt.Sprintf("⚠️ Задержка выше %s: сделки мастера копируются с опозданием. "+
+			"Проверь сеть и прокси master аккаунта", websocket.SlowFeedThreshold)
+	}
+
+	rtt := "нет данных (ping раз в 15 секунд)"
+	if health.PingRTTMs > 0 {
+		rtt = fmt.Sprintf("%.0f мс", health.PingRTTMs)
+	}
+
+	events := "событий еще не было"
+	if health.Events > 0 {
+		events = fmt.Sprintf("последнее %.0f мс, среднее %.0f мс, максимум %.0f мс (%d событий)",
+			health.LastEventLagMs, health.AvgEventLagMs, health.MaxEventLagMs, health.Events)
+	}
+
+	connected := "✅ подключен"
+	if !health.Connected {
+		connected = "❌ отключен"
 	}
-	return session.eventChan
+
+	return fmt.Sprintf(`📡 WebSocket мастера: %s
+
+🏓 Ping/pong: %s
+⏱ Задержка событий: %s
+
+%s`, connected, rtt, events, verdict)
 }
 
 // StopAll останавливает все сессии (для graceful shutdown)
@@ -209,43 +354,18 @@ func (s *Service) StopAll() {
 	defer s.mu.Unlock()
 
 	for chatID, session := range s.sessions {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		_ = ctx
-
 		if err := session.wsService.Stop(); err != nil {
 			s.logger.Error("Error stopping WebSocket", slog.Any("error", err))
 		}
 		s.manager.StopSession(session.userID, "websocket")
-		close(session.eventChan)
 
 		s.logger.Info("Copy trading stopped",
 			slog.Int64("chat_id", chatID))
-
-		cancel()
 	}
 
 	s.sessions = make(map[int64]*telegramSession)
 }
 
-// SendEvent отправляет событие в канал (для уведомлений о сделках)
-func (s *Service) SendEvent(chatID int64, message string) {
-	s.mu.RLock()
-	session, ok := s.sessions[chatID]
-	s.mu.RUnlock()
-
-	if !ok {
-		return
-	}
-
-	select {
-	case session.eventChan <- message:
-	default:
-		// Канал переполнен, пропускаем
-		s.logger.Warn("Event channel full, dropping message",
-			slog.Int64("chat_id", chatID))
-	}
-}
-
 // GetMasterAccount возвращает мастер аккаунт для чата
 func (s *Service) GetMasterAccount(chatID int64) (*models.Account, error) {
 	userID, err := s.storage.GetOrCreateUserByTelegramChatID(chatID)